@@ -0,0 +1,208 @@
+package autosetup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchivedExtractionNote is reported for every GGUF found inside an archive
+const ArchivedExtractionNote = "GGUF is stored inside an archive; extract it before it can be loaded by llama-server"
+
+// ArchivedModelInfo describes a GGUF file found inside a .zip or .tar(.gz) archive.
+// llama-server cannot load these directly, so they are reported but never added to the config.
+type ArchivedModelInfo struct {
+	ArchivePath   string `json:"archivePath"`
+	EntryName     string `json:"entryName"`
+	SizeBytes     int64  `json:"size"`
+	Name          string `json:"name"`
+	Architecture  string `json:"architecture"`
+	ContextLength int    `json:"contextLength"`
+	NumLayers     int    `json:"numLayers"`
+	Quantization  string `json:"quantization"`
+	Archived      bool   `json:"archived"`
+	Note          string `json:"note"`
+	Error         string `json:"error,omitempty"`
+}
+
+// isModelArchive reports whether the file name is an archive format we can look into
+func isModelArchive(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".zip") ||
+		strings.HasSuffix(lower, ".tar") ||
+		strings.HasSuffix(lower, ".tar.gz") ||
+		strings.HasSuffix(lower, ".tgz")
+}
+
+// scannedArchive is what DetectArchivedModels found in an archive of that size
+// and modification time
+type scannedArchive struct {
+	size    int64
+	modTime time.Time
+	models  []ArchivedModelInfo
+}
+
+// scannedArchives caches the scans by archive path, a folder rescan only reads
+// the archives that changed
+var (
+	scannedArchivesMu sync.Mutex
+	scannedArchives   = map[string]scannedArchive{}
+)
+
+// DetectArchivedModels scans a directory for archives containing GGUF files and
+// reads their metadata from the archive without extracting to disk
+func DetectArchivedModels(modelsDir string) ([]ArchivedModelInfo, error) {
+	archives := map[string]os.FileInfo{}
+	var archivePaths []string
+	err := filepath.Walk(modelsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isModelArchive(info.Name()) {
+			archives[path] = info
+			archivePaths = append(archivePaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan models directory: %v", err)
+	}
+
+	var results []ArchivedModelInfo
+	for _, archivePath := range archivePaths {
+		info := archives[archivePath]
+		scannedArchivesMu.Lock()
+		scanned, found := scannedArchives[archivePath]
+		scannedArchivesMu.Unlock()
+		if found && scanned.size == info.Size() && scanned.modTime.Equal(info.ModTime()) {
+			results = append(results, scanned.models...)
+			continue
+		}
+
+		models, err := ReadArchivedModels(archivePath)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping archive %s: %v\n", archivePath, err)
+			continue
+		}
+		scannedArchivesMu.Lock()
+		scannedArchives[archivePath] = scannedArchive{size: info.Size(), modTime: info.ModTime(), models: models}
+		scannedArchivesMu.Unlock()
+		results = append(results, models...)
+	}
+
+	return results, nil
+}
+
+// ReadArchivedModels lists the GGUF files inside a single archive. A compressed
+// tar has to be decompressed up to each entry, so only its first GGUF is read
+// and reported.
+func ReadArchivedModels(archivePath string) ([]ArchivedModelInfo, error) {
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		return readZipArchivedModels(archivePath)
+	}
+	return readTarArchivedModels(archivePath)
+}
+
+func readZipArchivedModels(archivePath string) ([]ArchivedModelInfo, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %v", err)
+	}
+	defer archive.Close()
+
+	var results []ArchivedModelInfo
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name), ".gguf") {
+			continue
+		}
+
+		stream, err := entry.Open()
+		if err != nil {
+			results = append(results, newArchivedModelInfo(archivePath, entry.Name, int64(entry.UncompressedSize64), nil, err))
+			continue
+		}
+		metadata, err := NewGGUFStreamReader(stream).ReadMetadata()
+		stream.Close()
+		results = append(results, newArchivedModelInfo(archivePath, entry.Name, int64(entry.UncompressedSize64), metadata, err))
+	}
+
+	return results, nil
+}
+
+func readTarArchivedModels(archivePath string) ([]ArchivedModelInfo, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	// an uncompressed tar reader seeks past the entries in the file
+	var stream io.Reader = file
+	lower := strings.ToLower(archivePath)
+	compressed := strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz")
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+		}
+		defer gz.Close()
+		stream = gz
+	}
+
+	var results []ArchivedModelInfo
+	tarReader := tar.NewReader(stream)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, fmt.Errorf("failed to read tar entry: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(strings.ToLower(header.Name), ".gguf") {
+			continue
+		}
+
+		// the tar reader skips the rest of the entry on the next call to Next
+		metadata, err := NewGGUFStreamReader(tarReader).ReadMetadata()
+		results = append(results, newArchivedModelInfo(archivePath, header.Name, header.Size, metadata, err))
+		if compressed {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func newArchivedModelInfo(archivePath, entryName string, size int64, metadata *GGUFMetadata, err error) ArchivedModelInfo {
+	filename := filepath.Base(entryName)
+	info := ArchivedModelInfo{
+		ArchivePath:  archivePath,
+		EntryName:    entryName,
+		SizeBytes:    size,
+		Name:         strings.TrimSuffix(filename, filepath.Ext(filename)),
+		Quantization: detectQuantizationFromFilename(filename),
+		Archived:     true,
+		Note:         ArchivedExtractionNote,
+	}
+
+	if err != nil {
+		info.Error = fmt.Sprintf("failed to read GGUF header: %v", err)
+		return info
+	}
+
+	if metadata.ModelName != "" {
+		info.Name = metadata.ModelName
+	}
+	info.Architecture = metadata.Architecture
+	info.ContextLength = int(metadata.ContextLength)
+	info.NumLayers = int(metadata.BlockCount)
+	return info
+}
@@ -0,0 +1,210 @@
+package autosetup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTestGGUF writes a minimal GGUF header with a few metadata keys
+func buildTestGGUF(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(GGUFMagic))
+	write(uint32(3)) // version
+	write(uint64(0)) // tensor count
+	write(uint64(4)) // metadata kv count
+
+	writeString("general.architecture")
+	write(uint32(GGUFTypeString))
+	writeString("llama")
+
+	writeString("general.name")
+	write(uint32(GGUFTypeString))
+	writeString("Archived Llama")
+
+	writeString("llama.context_length")
+	write(uint32(GGUFTypeUInt32))
+	write(uint32(8192))
+
+	writeString("llama.block_count")
+	write(uint32(GGUFTypeUInt32))
+	write(uint32(32))
+
+	// trailing bytes stand in for tensor data
+	buf.Write(make([]byte, 128))
+	return buf.Bytes()
+}
+
+func assertArchivedModel(t *testing.T, models []ArchivedModelInfo, entryName string) {
+	t.Helper()
+	if len(models) != 1 {
+		t.Fatalf("expected 1 archived model, got %d", len(models))
+	}
+	model := models[0]
+	if model.Error != "" {
+		t.Fatalf("unexpected error: %s", model.Error)
+	}
+	if !model.Archived || model.Note == "" {
+		t.Errorf("expected archived model with extraction note, got %+v", model)
+	}
+	if model.EntryName != entryName {
+		t.Errorf("expected entry %s, got %s", entryName, model.EntryName)
+	}
+	if model.Name != "Archived Llama" || model.Architecture != "llama" {
+		t.Errorf("unexpected metadata: %+v", model)
+	}
+	if model.ContextLength != 8192 || model.NumLayers != 32 {
+		t.Errorf("unexpected context/layers: %d/%d", model.ContextLength, model.NumLayers)
+	}
+	if model.Quantization != "Q4_K_M" {
+		t.Errorf("expected Q4_K_M quantization, got %s", model.Quantization)
+	}
+}
+
+func TestDetectArchivedModels_Zip(t *testing.T) {
+	dir := t.TempDir()
+	gguf := buildTestGGUF(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("models/llama-Q4_K_M.gguf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(gguf)
+	readme, _ := zw.Create("README.md")
+	readme.Write([]byte("not a model"))
+	zw.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "llama.zip"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := DetectArchivedModels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertArchivedModel(t, models, "models/llama-Q4_K_M.gguf")
+}
+
+func TestDetectArchivedModels_TarGz(t *testing.T) {
+	dir := t.TempDir()
+	gguf := buildTestGGUF(t)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "llama-Q4_K_M.gguf", Mode: 0644, Size: int64(len(gguf)), Typeflag: tar.TypeReg})
+	tw.Write(gguf)
+	tw.Close()
+	gz.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "llama.tar.gz"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := DetectArchivedModels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertArchivedModel(t, models, "llama-Q4_K_M.gguf")
+}
+
+func TestDetectArchivedModels_TarEntries(t *testing.T) {
+	dir := t.TempDir()
+	gguf := buildTestGGUF(t)
+	writeTar := func(path string, compress bool) {
+		var buf bytes.Buffer
+		var out io.Writer = &buf
+		gz := gzip.NewWriter(&buf)
+		if compress {
+			out = gz
+		}
+		tw := tar.NewWriter(out)
+		for _, name := range []string{"llama-Q4_K_M.gguf", "llama-Q8_0.gguf"} {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(gguf)), Typeflag: tar.TypeReg})
+			tw.Write(gguf)
+		}
+		tw.Close()
+		if compress {
+			gz.Close()
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a plain tar is read entry by entry, a compressed one only up to its first GGUF
+	writeTar(filepath.Join(dir, "plain.tar"), false)
+	models, err := ReadArchivedModels(filepath.Join(dir, "plain.tar"))
+	if err != nil || len(models) != 2 || models[1].Quantization != "Q8_0" {
+		t.Fatalf("expected both GGUFs of the tar, got %+v, %v", models, err)
+	}
+	writeTar(filepath.Join(dir, "packed.tar.gz"), true)
+	models, err = ReadArchivedModels(filepath.Join(dir, "packed.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertArchivedModel(t, models, "llama-Q4_K_M.gguf")
+}
+
+func TestDetectArchivedModels_CachesUnchangedArchives(t *testing.T) {
+	dir := t.TempDir()
+	gguf := buildTestGGUF(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "llama-Q4_K_M.gguf", Mode: 0644, Size: int64(len(gguf)), Typeflag: tar.TypeReg})
+	tw.Write(gguf)
+	tw.Close()
+	archivePath := filepath.Join(dir, "llama.tar")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	models, err := DetectArchivedModels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertArchivedModel(t, models, "llama-Q4_K_M.gguf")
+
+	// same size and modification time, the archive is not read again
+	stat, _ := os.Stat(archivePath)
+	if err := os.WriteFile(archivePath, make([]byte, buf.Len()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(archivePath, stat.ModTime(), stat.ModTime())
+	models, err = DetectArchivedModels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertArchivedModel(t, models, "llama-Q4_K_M.gguf")
+
+	// a changed archive is
+	later := stat.ModTime().Add(time.Minute)
+	os.Chtimes(archivePath, later, later)
+	models, err = DetectArchivedModels(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 0 {
+		t.Fatalf("expected the rewritten archive to have no models, got %+v", models)
+	}
+}
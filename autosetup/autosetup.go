@@ -10,17 +10,24 @@ import (
 type SetupOptions struct {
 	EnableDraftModels    bool
 	EnableJinja          bool
-	EnableParallel       bool    // Enable parallel processing (should be renamed to EnableDeployment)
-	EnableRealtime       bool    // Enable real-time hardware monitoring for dynamic allocation
-	ThroughputFirst      bool    // Prioritize speed over maximum context
-	MaxSpeed             bool    // Maximum GPU utilization, minimum context
-	MinContext           int     // Minimum context size (default: 16384)
-	PreferredContext     int     // Preferred context size (default: 32768)
-	ForceBackend         string  // Force specific backend (cuda, rocm, cpu, vulkan) - overrides auto-detection
-	ForceRAM             float64 // Force total RAM in GB - overrides auto-detection
-	ForceVRAM            float64 // Force total VRAM in GB - overrides auto-detection
-	MinFreeMemoryPercent float64 // Minimum percentage of memory to keep free (default: 10%)
-	LlamaServerPath      string  // Custom path to llama-server binary - overrides auto-download
+	EnableParallel       bool                     // Enable parallel processing (should be renamed to EnableDeployment)
+	EnableRealtime       bool                     // Enable real-time hardware monitoring for dynamic allocation
+	ThroughputFirst      bool                     // Prioritize speed over maximum context
+	MaxSpeed             bool                     // Maximum GPU utilization, minimum context
+	MinContext           int                      // Minimum context size (default: 16384)
+	PreferredContext     int                      // Preferred context size (default: 32768)
+	ForceBackend         string                   // Force specific backend (cuda, rocm, cpu, vulkan) - overrides auto-detection
+	ForceRAM             float64                  // Force total RAM in GB - overrides auto-detection
+	ForceVRAM            float64                  // Force total VRAM in GB - overrides auto-detection
+	MinFreeMemoryPercent float64                  // Minimum percentage of memory to keep free (default: 10%)
+	LlamaServerPath      string                   // Custom path to llama-server binary - overrides auto-download
+	AutoAliases          bool                     // Enable short aliases derived from model IDs (e.g. "llama3")
+	ConfigPath           string                   // Config file to write (default: config.yaml)
+	ModelOverrides       []ModelOverride          // Custom flags for matching models, applied with KnownModelOverrides
+	CmdTemplates         map[string]string        // Architecture to extra llama-server flags, replacing DefaultCmdTemplates entries
+	BinaryMirrors        []string                 // Mirror base URLs tried in order when a llama.cpp binary download from GitHub fails
+	LoRAAdapters         map[string][]LoRAAdapter // Model name or ID to the LoRA adapters attached to it
+	MaxModels            int                      // Generation fails when it would write more models than this, 0 is no limit
 }
 
 // AutoSetup performs automatic model detection and configuration with default options
@@ -70,13 +77,13 @@ func AutoSetupWithOptions(modelsFolder string, options SetupOptions) error {
 		fmt.Printf("   3. Use huggingface-cli to download models:\n")
 		fmt.Printf("      huggingface-cli download <model-name> --include '*.gguf' --local-dir %s\n", modelsFolder)
 		fmt.Printf("\n📝 Creating basic configuration file for when you add models...\n")
-		
+
 		// Create a basic config with just the folder path for future use
-		err = createBasicConfig(modelsFolder)
+		err = createBasicConfig(options.ConfigPath, modelsFolder)
 		if err != nil {
 			return fmt.Errorf("failed to create basic configuration: %v", err)
 		}
-		
+
 		fmt.Printf("✅ Basic configuration created. Add models to %s and restart FrogLLM.\n", modelsFolder)
 		return nil
 	}
@@ -191,6 +198,9 @@ func AutoSetupWithOptions(modelsFolder string, options SetupOptions) error {
 		// Create binaries directory
 		binariesDir := filepath.Join(".", "binaries")
 		var err error
+		if len(options.BinaryMirrors) > 0 {
+			SetBinaryMirrors(options.BinaryMirrors)
+		}
 		binary, err = DownloadBinary(binariesDir, system, options.ForceBackend)
 		if err != nil {
 			return fmt.Errorf("failed to download binary: %v", err)
@@ -237,7 +247,10 @@ func AutoSetupWithOptions(modelsFolder string, options SetupOptions) error {
 	}
 
 	// Use config generator with smart GPU allocation
-	configPath := "config.yaml"
+	configPath := options.ConfigPath
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
 	generator := NewConfigGenerator(modelsFolder, binary.Path, configPath, options)
 	generator.SetAvailableVRAM(totalVRAM)
 	generator.SetBinaryType(binary.Type)
@@ -409,6 +422,9 @@ func AutoSetupMultiFoldersWithOptions(modelsFolders []string, options SetupOptio
 		// Create binaries directory
 		binariesDir := filepath.Join(".", "binaries")
 		var err error
+		if len(options.BinaryMirrors) > 0 {
+			SetBinaryMirrors(options.BinaryMirrors)
+		}
 		binary, err = DownloadBinary(binariesDir, system, options.ForceBackend)
 		if err != nil {
 			return fmt.Errorf("failed to download binary: %v", err)
@@ -456,7 +472,10 @@ func AutoSetupMultiFoldersWithOptions(modelsFolders []string, options SetupOptio
 
 	// Use config generator with smart GPU allocation
 	// For multi-folder, use the first valid folder as the primary folder for config generation
-	configPath := "config.yaml"
+	configPath := options.ConfigPath
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
 	generator := NewConfigGenerator(validFolders[0], binary.Path, configPath, options)
 	generator.SetAvailableVRAM(totalVRAM)
 	generator.SetBinaryType(binary.Type)
@@ -502,11 +521,14 @@ func AutoSetupMultiFoldersWithOptions(modelsFolders []string, options SetupOptio
 	return nil
 }
 
-// ValidateSetup checks if auto-setup has been run and is valid
-func ValidateSetup() error {
-	// Check if config.yaml exists
-	if _, err := os.Stat("config.yaml"); os.IsNotExist(err) {
-		return fmt.Errorf("config.yaml not found - run with --models-folder to auto-generate")
+// ValidateSetup checks if auto-setup has been run and is valid, configPath is
+// the config file, config.yaml when empty
+func ValidateSetup(configPath string) error {
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("%s not found - run with --models-folder to auto-generate", configPath)
 	}
 
 	// Check if binaries directory exists
@@ -517,8 +539,12 @@ func ValidateSetup() error {
 	return nil
 }
 
-// createBasicConfig creates a minimal config.yaml with the models folder path
-func createBasicConfig(modelsFolder string) error {
+// createBasicConfig creates a minimal config at configPath, config.yaml when
+// empty, with the models folder path
+func createBasicConfig(configPath, modelsFolder string) error {
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
 	basicConfig := fmt.Sprintf(`# FrogLLM Configuration
 # Generated automatically - add models to %s and regenerate
 
@@ -540,9 +566,9 @@ model_folders:
   - "%s"
 `, modelsFolder, modelsFolder, modelsFolder, modelsFolder)
 
-	err := os.WriteFile("config.yaml", []byte(basicConfig), 0644)
+	err := os.WriteFile(configPath, []byte(basicConfig), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write config.yaml: %v", err)
+		return fmt.Errorf("failed to write %s: %v", configPath, err)
 	}
 
 	return nil
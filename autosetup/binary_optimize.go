@@ -0,0 +1,56 @@
+package autosetup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// forceDownloadBinary downloads a llama-server build, replaceable for testing
+var forceDownloadBinary = ForceDownloadBinary
+
+// OptimalBinaryType returns the llama-server build that suits the hardware of
+// system best among the builds published for version, e.g. vulkan on a Linux
+// CUDA machine when the release has no CUDA build
+func OptimalBinaryType(system SystemInfo, version string) (string, error) {
+	_, binaryType, err := GetOptimalBinaryURL(system, "", version)
+	return binaryType, err
+}
+
+// ReplaceBinary replaces the installed llama-server with the backend build. The
+// installed build is set aside first and put back when the download fails, so a
+// failed replacement never leaves the machine without a working binary.
+func ReplaceBinary(downloadDir string, system SystemInfo, backend string) (*BinaryInfo, error) {
+	extractDir := filepath.Join(downloadDir, "llama-server")
+	previousDir := extractDir + ".previous"
+
+	staged := false
+	if _, err := os.Stat(extractDir); err == nil {
+		if err := removeDirectoryRobust(previousDir); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %v", previousDir, err)
+		}
+		if err := os.Rename(extractDir, previousDir); err != nil {
+			return nil, fmt.Errorf("failed to set the installed binary aside: %v", err)
+		}
+		staged = true
+	}
+
+	binary, err := forceDownloadBinary(downloadDir, system, backend)
+	if err != nil {
+		if staged {
+			fmt.Printf("↩️  Restoring the previous llama-server binary\n")
+			removeDirectoryRobust(extractDir)
+			if restoreErr := os.Rename(previousDir, extractDir); restoreErr != nil {
+				return nil, fmt.Errorf("%v, and restoring the previous binary failed: %v", err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	if staged {
+		if err := removeDirectoryRobust(previousDir); err != nil {
+			fmt.Printf("⚠️  Failed to remove the previous binary at %s: %v\n", previousDir, err)
+		}
+	}
+	return binary, nil
+}
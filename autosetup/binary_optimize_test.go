@@ -0,0 +1,146 @@
+package autosetup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptimalBinaryType(t *testing.T) {
+	const version = "b6527"
+	tests := []struct {
+		name      string
+		system    SystemInfo
+		published []string
+		want      string
+	}{
+		{
+			name:      "linux cuda",
+			system:    SystemInfo{OS: "linux", Architecture: "amd64", HasCUDA: true, HasVulkan: true},
+			published: []string{"llama-b6527-bin-ubuntu-x64-cuda.zip", "llama-b6527-bin-ubuntu-x64-vulkan.zip", "llama-b6527-bin-ubuntu-x64.zip"},
+			want:      "cuda",
+		},
+		{
+			name:      "linux cuda without a cuda build",
+			system:    SystemInfo{OS: "linux", Architecture: "amd64", HasCUDA: true, HasVulkan: true},
+			published: []string{"llama-b6527-bin-ubuntu-x64-vulkan.zip", "llama-b6527-bin-ubuntu-x64.zip"},
+			want:      "vulkan",
+		},
+		{
+			name:      "linux rocm",
+			system:    SystemInfo{OS: "linux", Architecture: "amd64", HasROCm: true},
+			published: []string{"llama-b6527-bin-ubuntu-x64-rocm.zip"},
+			want:      "rocm",
+		},
+		{
+			name:      "windows cuda",
+			system:    SystemInfo{OS: "windows", Architecture: "amd64", HasCUDA: true},
+			published: []string{"llama-b6527-bin-win-cuda-12.4-x64.zip"},
+			want:      "cuda",
+		},
+		{
+			name:   "apple silicon",
+			system: SystemInfo{OS: "darwin", Architecture: "arm64", HasMetal: true},
+			want:   "metal",
+		},
+		{
+			name:      "no gpu",
+			system:    SystemInfo{OS: "linux", Architecture: "amd64"},
+			published: []string{"llama-b6527-bin-ubuntu-x64.zip"},
+			want:      "cpu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubBinaryExists(t, tt.published...)
+			got, err := OptimalBinaryType(tt.system, version)
+			if err != nil {
+				t.Fatalf("OptimalBinaryType returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("OptimalBinaryType = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubForceDownloadBinary replaces the binary download with one that installs a
+// fake build of the requested backend, or fails with err
+func stubForceDownloadBinary(t *testing.T, err error) *[]string {
+	t.Helper()
+	var backends []string
+	original := forceDownloadBinary
+	forceDownloadBinary = func(downloadDir string, system SystemInfo, backend string) (*BinaryInfo, error) {
+		backends = append(backends, backend)
+		extractDir := filepath.Join(downloadDir, "llama-server")
+		if mkErr := os.MkdirAll(extractDir, 0755); mkErr != nil {
+			return nil, mkErr
+		}
+		os.WriteFile(filepath.Join(extractDir, "llama-server"), []byte(backend), 0755)
+		if err != nil {
+			return nil, err
+		}
+		binary := &BinaryInfo{Path: filepath.Join(extractDir, "llama-server"), Version: "b6527", Type: backend}
+		return binary, saveBinaryMetadata(extractDir, binary)
+	}
+	t.Cleanup(func() { forceDownloadBinary = original })
+	return &backends
+}
+
+func installFakeBinary(t *testing.T, downloadDir, backend string) {
+	t.Helper()
+	extractDir := filepath.Join(downloadDir, "llama-server")
+	if err := os.MkdirAll(extractDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(extractDir, "llama-server"), []byte(backend), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveBinaryMetadata(extractDir, &BinaryInfo{Type: backend, Version: "b6000"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplaceBinary(t *testing.T) {
+	downloadDir := t.TempDir()
+	installFakeBinary(t, downloadDir, "cpu")
+	backends := stubForceDownloadBinary(t, nil)
+
+	binary, err := ReplaceBinary(downloadDir, SystemInfo{OS: "linux"}, "cuda")
+	if err != nil {
+		t.Fatalf("ReplaceBinary returned error: %v", err)
+	}
+	if binary.Type != "cuda" || len(*backends) != 1 || (*backends)[0] != "cuda" {
+		t.Errorf("expected a cuda download, got %+v after %v", binary, *backends)
+	}
+	metadata, err := LoadBinaryMetadata(filepath.Join(downloadDir, "llama-server"))
+	if err != nil || metadata.Type != "cuda" {
+		t.Errorf("expected cuda metadata, got %+v, %v", metadata, err)
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "llama-server.previous")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous binary to be removed, got %v", err)
+	}
+}
+
+func TestReplaceBinary_RestoresPreviousOnFailure(t *testing.T) {
+	downloadDir := t.TempDir()
+	installFakeBinary(t, downloadDir, "cpu")
+	stubForceDownloadBinary(t, fmt.Errorf("download failed"))
+
+	if _, err := ReplaceBinary(downloadDir, SystemInfo{OS: "linux"}, "cuda"); err == nil {
+		t.Fatal("expected the failed download to be returned")
+	}
+	metadata, err := LoadBinaryMetadata(filepath.Join(downloadDir, "llama-server"))
+	if err != nil || metadata.Type != "cpu" || metadata.Version != "b6000" {
+		t.Errorf("expected the previous cpu binary back, got %+v, %v", metadata, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(downloadDir, "llama-server", "llama-server"))
+	if string(data) != "cpu" {
+		t.Errorf("expected the previous executable back, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "llama-server.previous")); !os.IsNotExist(err) {
+		t.Errorf("expected no previous directory left, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package autosetup
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Capability tags derived while scanning models. They only describe a model
+// so UIs can filter on them, nothing in config generation depends on them.
+const (
+	TagVision      = "vision"
+	TagEmbedding   = "embedding"
+	TagReranker    = "reranker"
+	TagCode        = "code"
+	TagMoE         = "moe"
+	TagLongContext = "long-context"
+)
+
+// models with at least this context length are tagged long-context
+const longContextThreshold = 100000
+
+// llama.cpp pooling type of reranking models
+const ggufPoolingTypeRank = 4
+
+// names of code models, e.g. Qwen2.5-Coder, CodeLlama, StarCoder2 or Devstral.
+// The word boundary keeps cross-encoder rerankers out.
+var codeModelPattern = regexp.MustCompile(`\b(code|starcoder|devstral)`)
+
+// name fragments of mixture of experts models, e.g. Mixtral or Qwen3-30B-A3B
+var moeModelNames = []string{"moe", "mixtral", "-a3b", "-a22b", "-a35b"}
+
+// capabilityTags returns the tags of a model from its detected info, name and
+// GGUF metadata, which may be nil. Vision is added by TagVisionModels since it
+// depends on the mmproj files next to the model.
+func capabilityTags(model ModelInfo, metadata map[string]interface{}) []string {
+	lower := strings.ToLower(model.Name)
+	arch := strings.ToLower(getStringValue(metadata, "general.architecture"))
+	metaName := strings.ToLower(getStringValue(metadata, "general.name"))
+
+	tags := []string{}
+	isReranker := strings.Contains(lower, "rerank") || strings.Contains(metaName, "rerank") ||
+		getIntValue(metadata, fmt.Sprintf("%s.pooling_type", arch)) == ggufPoolingTypeRank
+	if isReranker {
+		tags = append(tags, TagReranker)
+	} else if model.IsEmbedding {
+		tags = append(tags, TagEmbedding)
+	}
+
+	if codeModelPattern.MatchString(lower) || codeModelPattern.MatchString(metaName) {
+		tags = append(tags, TagCode)
+	}
+
+	isMoE := model.IsMoE || getIntValue(metadata, fmt.Sprintf("%s.expert_count", arch)) > 1
+	for _, name := range moeModelNames {
+		if strings.Contains(lower, name) {
+			isMoE = true
+			break
+		}
+	}
+	if isMoE {
+		tags = append(tags, TagMoE)
+	}
+
+	if model.ContextLength >= longContextThreshold {
+		tags = append(tags, TagLongContext)
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// TagVisionModels adds the vision tag to the models with a matching mmproj file
+func TagVisionModels(models []ModelInfo, matches []MMProjMatch) {
+	withMMProj := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		withMMProj[match.ModelPath] = true
+	}
+
+	for i := range models {
+		if !withMMProj[models[i].Path] || hasTag(models[i].Tags, TagVision) {
+			continue
+		}
+		models[i].Tags = append(models[i].Tags, TagVision)
+		sort.Strings(models[i].Tags)
+	}
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
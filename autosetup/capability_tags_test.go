@@ -0,0 +1,96 @@
+package autosetup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapabilityTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    ModelInfo
+		metadata map[string]interface{}
+		want     []string
+	}{
+		{
+			name:  "plain chat model",
+			model: ModelInfo{Name: "Llama-3.2-3B-Instruct-Q4_K_M", ContextLength: 8192},
+			want:  []string{},
+		},
+		{
+			name:  "embedding model",
+			model: ModelInfo{Name: "nomic-embed-text-v1.5-Q8_0", IsEmbedding: true, ContextLength: 2048},
+			want:  []string{TagEmbedding},
+		},
+		{
+			name:  "reranker by name is not tagged embedding",
+			model: ModelInfo{Name: "bge-reranker-v2-m3-Q8_0", IsEmbedding: true},
+			want:  []string{TagReranker},
+		},
+		{
+			name:     "reranker by pooling type",
+			model:    ModelInfo{Name: "model-Q8_0", IsEmbedding: true},
+			metadata: map[string]interface{}{"general.architecture": "qwen3", "qwen3.pooling_type": uint32(4)},
+			want:     []string{TagReranker},
+		},
+		{
+			name:  "cross-encoder is not a code model",
+			model: ModelInfo{Name: "ms-marco-MiniLM-L6-cross-encoder-reranker-Q8_0"},
+			want:  []string{TagReranker},
+		},
+		{
+			name:  "long context coder",
+			model: ModelInfo{Name: "Qwen2.5-Coder-7B-Instruct-Q4_K_M", ContextLength: 131072},
+			want:  []string{TagCode, TagLongContext},
+		},
+		{
+			name:     "code model by metadata name",
+			model:    ModelInfo{Name: "model-Q4_K_M"},
+			metadata: map[string]interface{}{"general.name": "Codestral 22B v0.1"},
+			want:     []string{TagCode},
+		},
+		{
+			name:     "moe by expert count",
+			model:    ModelInfo{Name: "model-Q4_K_M", ContextLength: 32768},
+			metadata: map[string]interface{}{"general.architecture": "qwen3moe", "qwen3moe.expert_count": uint32(128)},
+			want:     []string{TagMoE},
+		},
+		{
+			name:  "moe by name",
+			model: ModelInfo{Name: "Qwen3-30B-A3B-Instruct-2507-Q4_K_M", ContextLength: 262144},
+			want:  []string{TagLongContext, TagMoE},
+		},
+		{
+			name:  "context just under the threshold",
+			model: ModelInfo{Name: "Mistral-7B-Instruct-v0.3-Q4_K_M", ContextLength: 99999},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capabilityTags(tt.model, tt.metadata)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("capabilityTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagVisionModels(t *testing.T) {
+	models := []ModelInfo{
+		{Name: "gemma-3-4b-it-Q4_K_M", Path: "/models/gemma-3-4b-it-Q4_K_M.gguf", Tags: []string{TagLongContext}},
+		{Name: "Llama-3.2-3B-Instruct-Q4_K_M", Path: "/models/Llama-3.2-3B-Instruct-Q4_K_M.gguf", Tags: []string{}},
+	}
+	matches := []MMProjMatch{{ModelPath: "/models/gemma-3-4b-it-Q4_K_M.gguf", MMProjPath: "/models/mmproj-gemma-3-4b-it-f16.gguf"}}
+
+	TagVisionModels(models, matches)
+	TagVisionModels(models, matches)
+
+	if want := []string{TagLongContext, TagVision}; !reflect.DeepEqual(models[0].Tags, want) {
+		t.Errorf("matched model tags = %v, want %v", models[0].Tags, want)
+	}
+	if len(models[1].Tags) != 0 {
+		t.Errorf("unmatched model tags = %v, want none", models[1].Tags)
+	}
+}
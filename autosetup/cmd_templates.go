@@ -0,0 +1,93 @@
+package autosetup
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultCmdTemplates are llama-server flags for models of one GGUF architecture,
+// added on top of the generic llama-server-base template. Custom templates from
+// SetupOptions.CmdTemplates replace the default of the same architecture.
+var DefaultCmdTemplates = map[string]string{
+	// keep the whole sliding window cache so prompts can be reused between requests
+	"gemma2": "--swa-full",
+	"gemma3": "--swa-full",
+}
+
+var invalidMacroNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// cmdTemplates merges the default and custom templates, keyed by lowercase
+// architecture. An empty custom template turns the default off.
+func (scg *ConfigGenerator) cmdTemplates() map[string]string {
+	templates := make(map[string]string)
+	for architecture, flags := range DefaultCmdTemplates {
+		templates[architecture] = flags
+	}
+	for architecture, flags := range scg.Options.CmdTemplates {
+		architecture = strings.ToLower(strings.TrimSpace(architecture))
+		if strings.TrimSpace(flags) == "" {
+			delete(templates, architecture)
+		} else {
+			templates[architecture] = flags
+		}
+	}
+	return templates
+}
+
+// modelArchitecture returns the general.architecture of a model, empty when the
+// GGUF can't be read
+func modelArchitecture(model ModelInfo) string {
+	metadata, err := ReadGGUFMetadata(model.Path)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(metadata.Architecture)
+}
+
+// cmdTemplateMacro returns the macro to start a chat model's cmd with, the one of
+// its architecture's template or llama-server-base
+func (scg *ConfigGenerator) cmdTemplateMacro(model ModelInfo) string {
+	architecture := modelArchitecture(model)
+	if scg.cmdTemplates()[architecture] == "" {
+		return "llama-server-base"
+	}
+	return cmdTemplateMacroName(architecture)
+}
+
+func cmdTemplateMacroName(architecture string) string {
+	return "llama-server-" + invalidMacroNameChars.ReplaceAllString(architecture, "-")
+}
+
+// writeCmdTemplateMacros writes a macro for each architecture template used by
+// the chat models, the base flags followed by the template's own
+func (scg *ConfigGenerator) writeCmdTemplateMacros(config *strings.Builder, models []ModelInfo) {
+	templates := scg.cmdTemplates()
+	used := make(map[string]bool)
+	for _, model := range models {
+		if model.IsDraft || scg.isEmbeddingModel(model) {
+			continue
+		}
+		if architecture := modelArchitecture(model); templates[architecture] != "" {
+			used[architecture] = true
+		}
+	}
+
+	architectures := make([]string, 0, len(used))
+	for architecture := range used {
+		architectures = append(architectures, architecture)
+	}
+	sort.Strings(architectures)
+
+	for _, architecture := range architectures {
+		config.WriteString("\n")
+		config.WriteString(fmt.Sprintf("  \"%s\": >\n", cmdTemplateMacroName(architecture)))
+		scg.writeServerBaseFlags(config)
+		for _, line := range strings.Split(templates[architecture], "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				config.WriteString(fmt.Sprintf("    %s\n", line))
+			}
+		}
+	}
+}
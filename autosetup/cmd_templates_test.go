@@ -0,0 +1,88 @@
+package autosetup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeArchitectureGGUF writes a GGUF header with only general.architecture
+func writeArchitectureGGUF(t *testing.T, path, architecture string) {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(GGUFMagic))
+	write(uint32(3)) // version
+	write(uint64(0)) // tensor count
+	write(uint64(1)) // metadata kv count
+	writeString("general.architecture")
+	write(uint32(GGUFTypeString))
+	writeString(architecture)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateConfig_CmdTemplates(t *testing.T) {
+	dir := t.TempDir()
+	gemmaPath := filepath.Join(dir, "gemma-3-4b-it-Q4_K_M.gguf")
+	llamaPath := filepath.Join(dir, "llama-3.2-3b-instruct-Q4_K_M.gguf")
+	writeArchitectureGGUF(t, gemmaPath, "gemma3")
+	writeArchitectureGGUF(t, llamaPath, "llama")
+	models := []ModelInfo{
+		{Name: "gemma-3-4b-it", Path: gemmaPath, Size: "4B"},
+		{Name: "llama-3.2-3b-instruct", Path: llamaPath, Size: "3B"},
+	}
+
+	generate := func(options SetupOptions) string {
+		t.Helper()
+		outputPath := filepath.Join(dir, "config.yaml")
+		scg := NewConfigGenerator(dir, "llama-server", outputPath, options)
+		if err := scg.GenerateConfig(models); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	config := generate(SetupOptions{})
+	if !strings.Contains(config, "  \"llama-server-gemma3\": >\n    llama-server\n") ||
+		!strings.Contains(config, "    --ubatch-size 512\n    --swa-full\n") {
+		t.Errorf("expected a gemma3 macro with the base and SWA flags:\n%s", config)
+	}
+	if !strings.Contains(config, "    cmd: |\n      ${llama-server-gemma3}\n      --model "+gemmaPath) {
+		t.Errorf("expected the gemma model to use its template:\n%s", config)
+	}
+	if !strings.Contains(config, "    cmd: |\n      ${llama-server-base}\n      --model "+llamaPath) {
+		t.Errorf("expected the llama model to fall back to the generic template:\n%s", config)
+	}
+	if strings.Contains(config, "llama-server-qwen") {
+		t.Errorf("expected no macros for architectures without models:\n%s", config)
+	}
+
+	// custom templates replace the default and can turn it off
+	config = generate(SetupOptions{CmdTemplates: map[string]string{"Gemma3": "--swa-full\n--override-tensor exps=CPU", "llama": ""}})
+	if !strings.Contains(config, "    --swa-full\n    --override-tensor exps=CPU\n") {
+		t.Errorf("expected the custom gemma3 template:\n%s", config)
+	}
+	config = generate(SetupOptions{CmdTemplates: map[string]string{"gemma3": ""}})
+	if strings.Contains(config, "llama-server-gemma3") {
+		t.Errorf("expected the disabled template to fall back to llama-server-base:\n%s", config)
+	}
+}
@@ -55,6 +55,11 @@ func (scg *ConfigGenerator) SetSystemInfo(systemInfo *SystemInfo) {
 
 // GenerateConfig generates a simple configuration file
 func (scg *ConfigGenerator) GenerateConfig(models []ModelInfo) error {
+	if scg.Options.MaxModels > 0 && len(models) > scg.Options.MaxModels {
+		return fmt.Errorf("found %d models, more than the limit of %d: prune the model folders, split them into config profiles, or raise modelLimits.max",
+			len(models), scg.Options.MaxModels)
+	}
+
 	pm := GetProgressManager()
 	pm.UpdateStatus("generating")
 	pm.UpdateStep("Starting configuration generation...")
@@ -90,6 +95,7 @@ func (scg *ConfigGenerator) GenerateConfig(models []ModelInfo) error {
 
 	// Write macros
 	scg.writeMacros(&config)
+	scg.writeCmdTemplateMacros(&config, models)
 
 	pm.UpdateStep("Processing model configurations...")
 	// Generate model IDs consistently (first pass)
@@ -170,12 +176,28 @@ func (scg *ConfigGenerator) writeHeader(config *strings.Builder) {
 	if scg.Options.MinFreeMemoryPercent > 0 {
 		config.WriteString(fmt.Sprintf("minFreeMemoryPercent: %.1f\n", scg.Options.MinFreeMemoryPercent))
 	}
+	if scg.Options.AutoAliases {
+		config.WriteString("autoAliases: true\n")
+	}
 }
 
 // writeMacros writes the base macros
 func (scg *ConfigGenerator) writeMacros(config *strings.Builder) {
 	config.WriteString("\nmacros:\n")
 	config.WriteString("  \"llama-server-base\": >\n")
+	scg.writeServerBaseFlags(config)
+	config.WriteString("\n")
+	config.WriteString("  \"llama-embed-base\": >\n")
+	config.WriteString(fmt.Sprintf("    %s\n", scg.BinaryPath))
+	config.WriteString("    --host 127.0.0.1\n")
+	config.WriteString("    --port ${PORT}\n")
+	config.WriteString("    --embedding\n")
+	// Pooling type will be set per model based on model family
+	// KV cache types are now set per model based on optimal calculation
+}
+
+// writeServerBaseFlags writes the flags of the generic llama-server template
+func (scg *ConfigGenerator) writeServerBaseFlags(config *strings.Builder) {
 	config.WriteString(fmt.Sprintf("    %s\n", scg.BinaryPath))
 	config.WriteString("    --host 127.0.0.1\n")
 	config.WriteString("    --port ${PORT}\n")
@@ -185,14 +207,6 @@ func (scg *ConfigGenerator) writeMacros(config *strings.Builder) {
 	config.WriteString("    --dry-penalty-last-n 0\n")
 	config.WriteString("    --batch-size 2048\n")
 	config.WriteString("    --ubatch-size 512\n")
-	config.WriteString("\n")
-	config.WriteString("  \"llama-embed-base\": >\n")
-	config.WriteString(fmt.Sprintf("    %s\n", scg.BinaryPath))
-	config.WriteString("    --host 127.0.0.1\n")
-	config.WriteString("    --port ${PORT}\n")
-	config.WriteString("    --embedding\n")
-	// Pooling type will be set per model based on model family
-	// KV cache types are now set per model based on optimal calculation
 }
 
 // writeModel writes a single model configuration
@@ -216,7 +230,8 @@ func (scg *ConfigGenerator) writeModel(config *strings.Builder, model ModelInfo,
 	if scg.isEmbeddingModel(model) {
 		config.WriteString("      ${llama-embed-base}\n")
 	} else {
-		config.WriteString("      ${llama-server-base}\n")
+		// architecture specific template, falling back to llama-server-base
+		config.WriteString(fmt.Sprintf("      ${%s}\n", scg.cmdTemplateMacro(model)))
 	}
 	// For split models, use the first part (llama.cpp will auto-detect the rest)
 	modelPath := model.Path
@@ -232,6 +247,12 @@ func (scg *ConfigGenerator) writeModel(config *strings.Builder, model ModelInfo,
 		config.WriteString(fmt.Sprintf("      --mmproj %s\n", quotePath(mmprojPath)))
 	}
 
+	// Flags known to be required by this model's architecture or conversion
+	overrideArgs := scg.modelOverrideArgs(model)
+	for _, arg := range overrideArgs {
+		config.WriteString(fmt.Sprintf("      %s\n", arg))
+	}
+
 	// Smart GPU layer allocation algorithm (applies to all models including embeddings)
 	nglValue := scg.calculateOptimalNGL(model)
 
@@ -242,12 +263,23 @@ func (scg *ConfigGenerator) writeModel(config *strings.Builder, model ModelInfo,
 		modelSizeGB = modelInfo.ActualSizeGB
 	}
 
+	// attached LoRA adapters take memory next to the model
+	loraAdapters := scg.modelLoRAAdapters(model, modelID)
+	var adapterPaths []string
+	for _, adapter := range loraAdapters {
+		adapterPaths = append(adapterPaths, adapter.Path)
+	}
+	modelSizeGB += LoRAAdaptersSizeGB(adapterPaths)
+
 	// Calculate optimal context size and KV cache type for use in optimizations
 	optimalContext, kvCacheType := scg.calculateOptimalContext(model, nglValue, modelSizeGB)
 
 	// For embedding models, skip base context and ngl as they'll be handled in writeOptimizations
 	if !scg.isEmbeddingModel(model) {
 		config.WriteString(fmt.Sprintf("      --ctx-size %d\n", optimalContext))
+		for _, arg := range scg.modelRopeArgs(model, optimalContext, overrideArgs) {
+			config.WriteString(fmt.Sprintf("      %s\n", arg))
+		}
 		config.WriteString(fmt.Sprintf("      -ngl %d\n", nglValue))
 
 		// Set KV cache type
@@ -260,6 +292,7 @@ func (scg *ConfigGenerator) writeModel(config *strings.Builder, model ModelInfo,
 
 	// Add proxy
 	config.WriteString("    proxy: \"http://127.0.0.1:${PORT}\"\n")
+	writeLoRAAdapters(config, loraAdapters)
 	
 	// Add TTL (Time To Live) - default 300 seconds
 	config.WriteString("    ttl: 300\n")
@@ -278,12 +311,35 @@ func (scg *ConfigGenerator) calculateOptimalNGL(model ModelInfo) int {
 		return 0
 	}
 
-	// ALWAYS force all layers to GPU for maximum performance
-	// User has 300GB RTX 6000 GPUs - use them!
+	// Offload only the layers that fit when the model and its KV cache at the
+	// target context don't fit the VRAM, -ngl 999 would run out of memory
+	if scg.TotalVRAMGB > 0 {
+		offload, err := NewMemoryEstimator().CalculateOptimalLayers(model.Path, scg.TotalVRAMGB, scg.targetContext(model))
+		if err == nil && offload.GPULayers < int(offload.TotalLayers) {
+			fmt.Printf("   🧮 %d of %d layers fit in %.1f GB VRAM at %d context (-ngl %d)\n",
+				offload.GPULayers, offload.TotalLayers, scg.TotalVRAMGB, offload.ContextSize, offload.GPULayers)
+			return offload.GPULayers
+		}
+	}
+
+	// Everything fits, or the model can't be measured: all layers to GPU for maximum performance
 	fmt.Printf("   🚀 FORCING all layers to GPU (-ngl 999) for maximum performance\n")
 	return 999
 }
 
+// targetContext returns the context the GPU layers are sized for, the minimum
+// context capped at the model's trained context
+func (scg *ConfigGenerator) targetContext(model ModelInfo) int {
+	contextSize := scg.Options.MinContext
+	if contextSize <= 0 {
+		contextSize = 16384
+	}
+	if metadata, err := ReadGGUFMetadata(model.Path); err == nil && metadata.ContextLength > 0 && int(metadata.ContextLength) < contextSize {
+		contextSize = int(metadata.ContextLength)
+	}
+	return contextSize
+}
+
 // calculateKVCacheSize calculates VRAM usage for KV cache in GB
 func calculateKVCacheSize(contextSize int, layers int, kvCacheType string) float64 {
 	// KV cache size calculation: 2 * layers * hiddenSize * contextSize * bytesPerElement
@@ -0,0 +1,51 @@
+package autosetup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateConfig_PartialOffload(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "big-model-13b-Q4_K_M.gguf")
+	writeRopeGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"llama.context_length":          uint32(32768),
+		"llama.block_count":             uint32(32),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+	// 8 GB of weights, a sparse file
+	if err := os.Truncate(modelPath, 8<<30); err != nil {
+		t.Fatal(err)
+	}
+	models := []ModelInfo{{Name: "big-model-13b", Path: modelPath, Size: "13B"}}
+
+	outputPath := filepath.Join(dir, "config.yaml")
+	generate := func(vramGB float64) string {
+		scg := NewConfigGenerator(dir, "llama-server", outputPath, SetupOptions{MinContext: 16384})
+		scg.SetAvailableVRAM(vramGB)
+		if err := scg.GenerateConfig(models); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return modelCmd(t, string(data), modelPath)
+	}
+
+	// 0.25 GB of weights and 0.0625 GB of KV cache at 16K per layer, 2 GB
+	// overhead: 12 of 32 layers fit in 6 GB
+	if cmd := generate(6); !strings.Contains(cmd, "      -ngl 12\n") {
+		t.Errorf("expected a partial offload of 12 layers:\n%s", cmd)
+	}
+
+	// everything fits
+	if cmd := generate(24); !strings.Contains(cmd, "      -ngl 999\n") {
+		t.Errorf("expected a full offload:\n%s", cmd)
+	}
+}
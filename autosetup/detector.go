@@ -21,10 +21,12 @@ type ModelInfo struct {
 	IsDraft       bool
 	IsEmbedding   bool // Whether this is an embedding model
 	Quantization  string
-	ContextLength int  // Maximum context length supported by the model
-	EmbeddingSize int  // Embedding dimension size
-	NumLayers     int  // Number of transformer layers
-	IsMoE         bool // Whether this is a Mixture of Experts model
+	ContextLength int      // Maximum context length supported by the model
+	EmbeddingSize int      // Embedding dimension size
+	NumLayers     int      // Number of transformer layers
+	IsMoE         bool     // Whether this is a Mixture of Experts model
+	IsLoRA        bool     // LoRA adapter, only loaded attached to a model with --lora
+	Tags          []string // Derived capability tags such as "code" or "long-context"
 }
 
 // DetectModels scans a directory for GGUF files and returns model information
@@ -147,7 +149,7 @@ func DetectModelsWithProgress(modelsDir string, options SetupOptions, progressCa
 	}
 
 	// Combine split models into single entries
-	finalModels := CombineSplitModels(splitModels, regularModels)
+	finalModels := skipLoRAAdapters(CombineSplitModels(splitModels, regularModels))
 
 	pm.UpdateStatus("completed")
 	if progressCallback != nil {
@@ -238,7 +240,7 @@ func DetectModelsWithOptions(modelsDir string, options SetupOptions) ([]ModelInf
 	if len(splitModels) > 0 {
 		fmt.Printf("🔗 Found %d split model groups\n", len(splitModels))
 	}
-	finalModels := CombineSplitModels(splitModels, regularModels)
+	finalModels := skipLoRAAdapters(CombineSplitModels(splitModels, regularModels))
 
 	return finalModels, nil
 }
@@ -262,6 +264,12 @@ func parseGGUFFilename(fullPath, filename string) ModelInfo {
 		return model
 	}
 
+	// LoRA adapters are not models, they are left out of the model list
+	if IsLoRAAdapter(fullPath) {
+		model.IsLoRA = true
+		return model
+	}
+
 	// Read GGUF metadata for embedding detection
 	lowerPath := strings.ToLower(fullPath)
 
@@ -279,7 +287,8 @@ func parseGGUFFilename(fullPath, filename string) ModelInfo {
 	}
 
 	// Now read full metadata for embedding detection
-	if metadata, err := ReadAllGGUFKeys(fullPath); err == nil {
+	metadata, metadataErr := ReadAllGGUFKeys(fullPath)
+	if metadataErr == nil {
 		arch := ""
 		if val, exists := metadata["general.architecture"]; exists {
 			if str, ok := val.(string); ok {
@@ -333,18 +342,8 @@ func parseGGUFFilename(fullPath, filename string) ModelInfo {
 		model.IsInstruct = true
 	}
 
-	// Detect quantization level with more patterns
-	quantizations := []string{
-		"iq4_xs", "iq4_nl", "iq3_m", "iq3_s", "iq2_m", "iq2_s", "iq1_m", "iq1_s",
-		"q8_0", "q6_k", "q5_k_m", "q5_k_s", "q4_k_m", "q4_k_s", "q4_0", "q3_k_m", "q2_k",
-		"f32", "f16", "bf16",
-	}
-	for _, quant := range quantizations {
-		if strings.Contains(lower, quant) {
-			model.Quantization = strings.ToUpper(quant)
-			break
-		}
-	}
+	// Detect quantization level
+	model.Quantization = DetectQuantization(lower)
 
 	// Detect size with more patterns
 	// Look for patterns like "3b", "7b", "8b", "13b", "30b", "32b", "70b", etc.
@@ -378,6 +377,8 @@ func parseGGUFFilename(fullPath, filename string) ModelInfo {
 		}
 	}
 
+	model.Tags = capabilityTags(model, metadata)
+
 	return model
 }
 
@@ -10,7 +10,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -41,14 +43,56 @@ type GPUInfo struct {
 	Name     string
 	VRAMGB   float64
 	Type     string // "CUDA", "ROCm", "MLX", "Intel"
+	Kind     string // GPUKindDiscrete or GPUKindIntegrated
 	DeviceID int
+	// DetectedVRAMGB is the detected VRAM when VRAMGB was overridden by the user, 0 otherwise
+	DetectedVRAMGB float64
+}
+
+const (
+	GPUKindDiscrete   = "discrete"
+	GPUKindIntegrated = "integrated"
+)
+
+// PrimaryGPU returns the GPU used for inference, the first discrete GPU when
+// there is one so a laptop iGPU is never preferred over its dGPU
+func (s SystemInfo) PrimaryGPU() (GPUInfo, bool) {
+	for _, gpu := range s.VRAMDetails {
+		if gpu.Kind == GPUKindDiscrete {
+			return gpu, true
+		}
+	}
+	if len(s.VRAMDetails) > 0 {
+		return s.VRAMDetails[0], true
+	}
+	return GPUInfo{}, false
+}
+
+// IntelIsPrimaryGPU reports if an Intel GPU is the one used for inference
+func (s SystemInfo) IntelIsPrimaryGPU() bool {
+	primary, ok := s.PrimaryGPU()
+	return !ok || primary.Type == "Intel"
+}
+
+// HasMixedGPUs reports if both integrated and discrete GPUs were detected
+func (s SystemInfo) HasMixedGPUs() bool {
+	hasIntegrated, hasDiscrete := false, false
+	for _, gpu := range s.VRAMDetails {
+		switch gpu.Kind {
+		case GPUKindIntegrated:
+			hasIntegrated = true
+		case GPUKindDiscrete:
+			hasDiscrete = true
+		}
+	}
+	return hasIntegrated && hasDiscrete
 }
 
 // BinaryInfo contains information about the downloaded binary
 type BinaryInfo struct {
 	Path    string
 	Version string
-	Type    string // "cpu", "cuda", "rocm", "vulkan", "metal"
+	Type    string // "cpu", "cuda", "rocm", "vulkan", "metal", "sycl"
 }
 
 // BinaryMetadata stores information about the currently installed binary
@@ -81,9 +125,7 @@ const (
 func GetLatestReleaseVersion() (string, error) {
 	fmt.Printf("🔍 Checking for latest llama.cpp release...\n")
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := NewHTTPClient(10 * time.Second)
 
 	resp, err := client.Get(LLAMA_CPP_GITHUB_API)
 	if err != nil {
@@ -110,6 +152,9 @@ func GetLatestReleaseVersion() (string, error) {
 		return LLAMA_CPP_CURRENT_VERSION, nil
 	}
 
+	// the latest release already lists its assets, saving a call when checking binaries
+	cacheReleaseAssets(release)
+
 	fmt.Printf("✅ Latest release found: %s\n", version)
 	return version, nil
 }
@@ -182,9 +227,7 @@ func DetectSystem() SystemInfo {
 
 // checkBinaryExists checks if a binary URL exists on GitHub
 func checkBinaryExists(url string) bool {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := NewHTTPClient(10 * time.Second)
 
 	req, err := http.NewRequest("HEAD", url, nil)
 	if err != nil {
@@ -200,6 +243,9 @@ func checkBinaryExists(url string) bool {
 	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusFound
 }
 
+// binaryExists reports if a release asset exists, replaceable for testing
+var binaryExists = releaseAssetExists
+
 // GetOptimalBinaryURL returns the best binary download URL for the system with fallback support
 func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string) (string, string, error) {
 	var filename, binaryType string
@@ -217,18 +263,28 @@ func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string)
 	// If a backend is forced, use that instead of auto-detection
 	if forceBackend != "" {
 		binaryType = forceBackend
+		if binaryType == "intel" {
+			// the UI lists the Intel backend as "intel"
+			binaryType = "sycl"
+		}
+		if binaryType == "sycl" {
+			fallbackTypes = []string{"vulkan", "cpu"}
+		}
 		fmt.Printf("🎯 Using forced backend: %s\n", forceBackend)
 	} else {
 		// Auto-detect best backend for the system and set fallbacks
 		switch system.OS {
 		case "windows":
-			// Windows: CUDA > ROCm > Vulkan > CPU
+			// Windows: CUDA > ROCm > Intel SYCL > Vulkan > CPU
 			if system.HasCUDA {
 				binaryType = "cuda"
 				fallbackTypes = []string{"vulkan", "cpu"}
 			} else if system.HasROCm {
 				binaryType = "rocm"
 				fallbackTypes = []string{"vulkan", "cpu"}
+			} else if system.HasIntel && system.IntelIsPrimaryGPU() {
+				binaryType = "sycl"
+				fallbackTypes = []string{"vulkan", "cpu"}
 			} else if system.HasVulkan {
 				binaryType = "vulkan"
 				fallbackTypes = []string{"cpu"}
@@ -245,6 +301,11 @@ func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string)
 				fmt.Printf("   🐸 ROCm detected! Using ROCm backend for AMD GPUs\n")
 				binaryType = "rocm"
 				fallbackTypes = []string{"vulkan", "cpu"}
+			} else if system.HasIntel && system.IntelIsPrimaryGPU() {
+				// llama.cpp only publishes SYCL builds for Windows
+				fmt.Printf("   🐸 Intel GPU detected! Using Vulkan backend for Intel GPUs\n")
+				binaryType = "vulkan"
+				fallbackTypes = []string{"cpu"}
 			} else if system.HasVulkan {
 				fmt.Printf("   🐸 Vulkan detected! Using Vulkan backend\n")
 				binaryType = "vulkan"
@@ -275,6 +336,8 @@ func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string)
 			filename = fmt.Sprintf("llama-%s-bin-win-rocm-x64.zip", version)
 		case "vulkan":
 			filename = fmt.Sprintf("llama-%s-bin-win-vulkan-x64.zip", version)
+		case "sycl":
+			filename = fmt.Sprintf("llama-%s-bin-win-sycl-x64.zip", version)
 		case "cpu":
 			filename = fmt.Sprintf("llama-%s-bin-win-cpu-x64.zip", version)
 		default:
@@ -294,6 +357,12 @@ func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string)
 			// Try ROCm-specific binary
 			filename = fmt.Sprintf("llama-%s-bin-ubuntu-x64-rocm.zip", version)
 			fmt.Printf("   🐸 Downloading ROCm-enabled binary for AMD GPUs\n")
+		case "sycl":
+			// No SYCL build is published for Linux, Vulkan also supports Intel GPUs
+			binaryType = "vulkan"
+			fallbackTypes = []string{"cpu"}
+			filename = fmt.Sprintf("llama-%s-bin-ubuntu-x64-vulkan.zip", version)
+			fmt.Printf("   🐸 SYCL binary not published for Linux, downloading Vulkan-enabled binary for Intel GPUs\n")
 		case "cpu":
 			// CPU-only binary
 			filename = fmt.Sprintf("llama-%s-bin-ubuntu-x64.zip", version)
@@ -318,30 +387,34 @@ func GetOptimalBinaryURL(system SystemInfo, forceBackend string, version string)
 		return "", "", fmt.Errorf("unsupported operating system: %s", system.OS)
 	}
 
-	downloadBase := fmt.Sprintf("https://github.com/ggml-org/llama.cpp/releases/download/%s", version)
+	downloadBase := fmt.Sprintf("%s/%s", llamaCppDownloadBase, version)
 	url := fmt.Sprintf("%s/%s", downloadBase, filename)
 
 	// Check if the primary binary exists
-	if binaryType == "cuda" || binaryType == "vulkan" || binaryType == "rocm" {
+	if binaryType == "cuda" || binaryType == "vulkan" || binaryType == "rocm" || binaryType == "sycl" {
 		fmt.Printf("   🔍 Checking if %s binary is available...\n", binaryType)
-		if !checkBinaryExists(url) {
+		if !binaryAvailable(url) {
 			fmt.Printf("   ⚠️  %s binary not available in release %s\n", binaryType, version)
 
-			// Try fallbacks for Linux
-			if system.OS == "linux" && len(fallbackTypes) > 0 {
+			// Try fallbacks for Linux, and for SYCL on Windows
+			if (system.OS == "linux" || binaryType == "sycl") && len(fallbackTypes) > 0 {
 				for _, fallback := range fallbackTypes {
 					fmt.Printf("   🔄 Trying fallback: %s...\n", fallback)
 					var fallbackFilename string
-					switch fallback {
-					case "vulkan":
+					switch {
+					case system.OS == "windows" && fallback == "vulkan":
+						fallbackFilename = fmt.Sprintf("llama-%s-bin-win-vulkan-x64.zip", version)
+					case system.OS == "windows" && fallback == "cpu":
+						fallbackFilename = fmt.Sprintf("llama-%s-bin-win-cpu-x64.zip", version)
+					case fallback == "vulkan":
 						fallbackFilename = fmt.Sprintf("llama-%s-bin-ubuntu-x64-vulkan.zip", version)
-					case "cpu":
+					case fallback == "cpu":
 						fallbackFilename = fmt.Sprintf("llama-%s-bin-ubuntu-x64.zip", version)
 					}
 					fallbackURL := fmt.Sprintf("%s/%s", downloadBase, fallbackFilename)
-					if checkBinaryExists(fallbackURL) {
+					if binaryAvailable(fallbackURL) {
 						fmt.Printf("   ✅ Using %s binary as fallback\n", fallback)
-						if fallback == "vulkan" && binaryType == "cuda" {
+						if fallback == "vulkan" && (binaryType == "cuda" || binaryType == "sycl") {
 							fmt.Printf("   🐸 Vulkan will still provide GPU acceleration\n")
 						}
 						return fallbackURL, fallback, nil
@@ -498,7 +571,7 @@ func DownloadBinary(downloadDir string, system SystemInfo, forceBackend string)
 
 	// For CUDA on Windows, download both runtime and binary
 	if system.HasCUDA && system.OS == "windows" {
-		cudartURL := fmt.Sprintf("https://github.com/ggml-org/llama.cpp/releases/download/%s/cudart-llama-bin-win-cuda-12.4-x64.zip", version)
+		cudartURL := fmt.Sprintf("%s/%s/cudart-llama-bin-win-cuda-12.4-x64.zip", llamaCppDownloadBase, version)
 		fmt.Printf("Downloading CUDA runtime from: %s\n", cudartURL)
 
 		// Download CUDA runtime
@@ -548,7 +621,7 @@ func DownloadBinary(downloadDir string, system SystemInfo, forceBackend string)
 				fallbackTypes = []string{"vulkan", "cpu"}
 			} else if binaryType == "vulkan" {
 				fallbackTypes = []string{"cpu"}
-			} else if binaryType == "rocm" {
+			} else if binaryType == "rocm" || binaryType == "sycl" {
 				fallbackTypes = []string{"vulkan", "cpu"}
 			}
 
@@ -578,7 +651,7 @@ func DownloadBinary(downloadDir string, system SystemInfo, forceBackend string)
 					continue
 				}
 
-				fallbackURL := fmt.Sprintf("https://github.com/ggml-org/llama.cpp/releases/download/%s/%s", version, fallbackFilename)
+				fallbackURL := fmt.Sprintf("%s/%s/%s", llamaCppDownloadBase, version, fallbackFilename)
 				fmt.Printf("   Downloading %s binary from: %s\n", fallback, fallbackURL)
 
 				downloadErr = downloadFile(fallbackURL, zipPath)
@@ -681,7 +754,7 @@ func ForceDownloadBinary(downloadDir string, system SystemInfo, forceBackend str
 
 	// For CUDA on Windows, download both runtime and binary
 	if system.HasCUDA && system.OS == "windows" {
-		cudartURL := fmt.Sprintf("https://github.com/ggml-org/llama.cpp/releases/download/%s/cudart-llama-bin-win-cuda-12.4-x64.zip", version)
+		cudartURL := fmt.Sprintf("%s/%s/cudart-llama-bin-win-cuda-12.4-x64.zip", llamaCppDownloadBase, version)
 		fmt.Printf("Downloading CUDA runtime from: %s\n", cudartURL)
 
 		// Download CUDA runtime
@@ -731,7 +804,7 @@ func ForceDownloadBinary(downloadDir string, system SystemInfo, forceBackend str
 				fallbackTypes = []string{"vulkan", "cpu"}
 			} else if binaryType == "vulkan" {
 				fallbackTypes = []string{"cpu"}
-			} else if binaryType == "rocm" {
+			} else if binaryType == "rocm" || binaryType == "sycl" {
 				fallbackTypes = []string{"vulkan", "cpu"}
 			}
 
@@ -761,7 +834,7 @@ func ForceDownloadBinary(downloadDir string, system SystemInfo, forceBackend str
 					continue
 				}
 
-				fallbackURL := fmt.Sprintf("https://github.com/ggml-org/llama.cpp/releases/download/%s/%s", version, fallbackFilename)
+				fallbackURL := fmt.Sprintf("%s/%s/%s", llamaCppDownloadBase, version, fallbackFilename)
 				fmt.Printf("   Downloading %s binary from: %s\n", fallback, fallbackURL)
 
 				downloadErr = downloadFile(fallbackURL, zipPath)
@@ -825,9 +898,9 @@ func ForceDownloadBinary(downloadDir string, system SystemInfo, forceBackend str
 	return binaryInfo, nil
 }
 
-// downloadFile downloads a file from URL to local path
-func downloadFile(url, filepath string) error {
-	resp, err := http.Get(url)
+// downloadFileFrom downloads a file from URL to local path
+func downloadFileFrom(url, filepath string) error {
+	resp, err := NewHTTPClient(0).Get(url)
 	if err != nil {
 		return err
 	}
@@ -865,6 +938,9 @@ func extractZip(src, dest string) error {
 		defer rc.Close()
 
 		path := filepath.Join(dest, f.Name)
+		if err := CheckPathLength(path); err != nil {
+			return err
+		}
 		if f.FileInfo().IsDir() {
 			os.MkdirAll(path, f.FileInfo().Mode())
 			continue
@@ -1387,12 +1463,81 @@ func enhanceGPUDetection(info *SystemInfo) {
 	// Intel GPU detection
 	enhanceIntelGPUDetection(info)
 
-	// Calculate total VRAM
-	for _, gpu := range info.VRAMDetails {
-		info.TotalVRAMGB += gpu.VRAMGB
+	if len(info.VRAMDetails) > 0 {
+		info.TotalVRAMGB = budgetVRAM(info.VRAMDetails)
 	}
 }
 
+// budgetVRAM returns the VRAM usable for models. When a discrete GPU is present
+// integrated GPUs are left out, their shared memory is system RAM and llama.cpp
+// runs on the discrete GPU.
+func budgetVRAM(gpus []GPUInfo) float64 {
+	var discrete, all float64
+	hasDiscrete := false
+	for _, gpu := range gpus {
+		all += gpu.VRAMGB
+		if gpu.Kind == GPUKindDiscrete {
+			discrete += gpu.VRAMGB
+			hasDiscrete = true
+		}
+	}
+	if hasDiscrete {
+		return discrete
+	}
+	return all
+}
+
+// ApplyVRAMOverrides replaces the detected VRAM of GPUs the user corrected, for
+// example a shared or virtualized GPU reporting the wrong size. Overrides are keyed
+// by the GPU's position in VRAMDetails ("0", "1", ...) or its name, case-insensitive.
+// The detected value is kept in DetectedVRAMGB and TotalVRAMGB is budgeted again.
+func ApplyVRAMOverrides(info *SystemInfo, overrides map[string]float64) {
+	if len(overrides) == 0 || len(info.VRAMDetails) == 0 {
+		return
+	}
+	for i := range info.VRAMDetails {
+		gpu := &info.VRAMDetails[i]
+		vram, ok := overrides[strconv.Itoa(i)]
+		if !ok {
+			for key, v := range overrides {
+				if strings.EqualFold(strings.TrimSpace(key), gpu.Name) {
+					vram, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok || vram <= 0 {
+			continue
+		}
+		if gpu.DetectedVRAMGB == 0 {
+			gpu.DetectedVRAMGB = gpu.VRAMGB
+		}
+		gpu.VRAMGB = vram
+	}
+	info.TotalVRAMGB = budgetVRAM(info.VRAMDetails)
+}
+
+// HasVRAMOverrides reports if the VRAM of any GPU was overridden by the user
+func (s SystemInfo) HasVRAMOverrides() bool {
+	for _, gpu := range s.VRAMDetails {
+		if gpu.DetectedVRAMGB > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matches "Arc" as a word in Intel GPU names, e.g. "Intel(R) Arc(TM) A770 Graphics"
+var intelArcPattern = regexp.MustCompile(`(?i)\barc\b`)
+
+// intelGPUKind returns GPUKindDiscrete for Intel Arc cards, other Intel GPUs are integrated
+func intelGPUKind(name string) string {
+	if intelArcPattern.MatchString(name) {
+		return GPUKindDiscrete
+	}
+	return GPUKindIntegrated
+}
+
 // enhanceCUDADetection gets detailed NVIDIA GPU information
 func enhanceCUDADetection(info *SystemInfo) {
 	// Try nvidia-smi for detailed info
@@ -1437,6 +1582,7 @@ func enhanceCUDADetection(info *SystemInfo) {
 					Name:     name,
 					VRAMGB:   vramMB / 1024.0,
 					Type:     "CUDA",
+					Kind:     GPUKindDiscrete,
 					DeviceID: i,
 				})
 			}
@@ -1455,26 +1601,93 @@ func enhanceROCmDetection(info *SystemInfo) {
 			Name:     "AMD GPU",
 			VRAMGB:   8.0, // Conservative estimate
 			Type:     "ROCm",
+			Kind:     GPUKindDiscrete,
 			DeviceID: 0,
 		})
 		return
 	}
 
-	// Parse ROCm GPU info (simplified)
-	lines := strings.Split(string(output), "\n")
-	deviceID := 0
-	for _, line := range lines {
-		if strings.Contains(line, "GPU") && strings.Contains(line, "MB") {
-			// Basic parsing - would need more sophisticated parsing
-			info.VRAMDetails = append(info.VRAMDetails, GPUInfo{
-				Name:     "AMD GPU",
-				VRAMGB:   8.0, // Placeholder
-				Type:     "ROCm",
-				DeviceID: deviceID,
-			})
-			deviceID++
+	info.VRAMDetails = append(info.VRAMDetails, parseROCmSMIOutput(string(output), info.TotalRAMGB)...)
+}
+
+// matches AMD integrated GPU names and codenames, e.g. "AMD Radeon 780M",
+// "AMD Radeon Graphics", "Radeon Vega 8 Graphics" or a "PHOENIX" card SKU
+var amdAPUPattern = regexp.MustCompile(`(?i)radeon\s+(\d{3,4}[ms]\b|vega\s*\d+|graphics\b)|\b(phoenix|rembrandt|renoir|cezanne|lucienne|barcelo|mendocino|hawk\s*point|strix)\b`)
+
+// apuSharedMemoryFraction is the share of system RAM budgeted as VRAM for an AMD APU,
+// the same 70% the Apple Silicon path uses for unified memory
+const apuSharedMemoryFraction = 0.7
+
+// isAMDAPU reports whether an AMD GPU name or SKU is an integrated GPU sharing system RAM
+func isAMDAPU(name string) bool {
+	return amdAPUPattern.MatchString(name)
+}
+
+// matches rocm-smi lines like "GPU[0]		: Card Series: 		AMD Radeon 780M"
+var rocmSMILinePattern = regexp.MustCompile(`^GPU\[(\d+)\]\s*:\s*([^:]+):\s*(.*)$`)
+
+// parseROCmSMIOutput parses `rocm-smi --showproductname --showmeminfo vram` output.
+// APUs report only their small BIOS carve-out as VRAM, so they get a share of
+// system RAM instead, like Apple Silicon unified memory.
+func parseROCmSMIOutput(output string, totalRAMGB float64) []GPUInfo {
+	type rocmGPU struct {
+		name, sku string
+		vramBytes float64
+	}
+	gpus := make(map[int]*rocmGPU)
+	var ids []int
+	for _, line := range strings.Split(output, "\n") {
+		m := rocmSMILinePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		gpu, ok := gpus[id]
+		if !ok {
+			gpu = &rocmGPU{}
+			gpus[id] = gpu
+			ids = append(ids, id)
+		}
+		value := strings.TrimSpace(m[3])
+		switch strings.ToLower(strings.TrimSpace(m[2])) {
+		case "card series":
+			gpu.name = value
+		case "card sku":
+			gpu.sku = value
+		case "vram total memory (b)":
+			gpu.vramBytes, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	sort.Ints(ids)
+
+	var result []GPUInfo
+	for _, id := range ids {
+		gpu := gpus[id]
+		if gpu.name == "" && gpu.vramBytes == 0 {
+			continue
+		}
+		detail := GPUInfo{
+			Name:     gpu.name,
+			VRAMGB:   gpu.vramBytes / (1024 * 1024 * 1024),
+			Type:     "ROCm",
+			Kind:     GPUKindDiscrete,
+			DeviceID: id,
+		}
+		if detail.Name == "" {
+			detail.Name = "AMD GPU"
 		}
+		if isAMDAPU(gpu.name) || isAMDAPU(gpu.sku) {
+			detail.Kind = GPUKindIntegrated
+			if shared := totalRAMGB * apuSharedMemoryFraction; shared > detail.VRAMGB {
+				detail.VRAMGB = shared
+			}
+		}
+		if detail.VRAMGB == 0 {
+			detail.VRAMGB = 8.0 // Conservative estimate
+		}
+		result = append(result, detail)
 	}
+	return result
 }
 
 // enhanceMLXDetection detects Apple Metal/MLX capabilities
@@ -1512,6 +1725,7 @@ func enhanceMLXDetection(info *SystemInfo) {
 			Name:     "Apple GPU",
 			VRAMGB:   getAppleSiliconUnifiedMemory(),
 			Type:     "MLX",
+			Kind:     GPUKindIntegrated,
 			DeviceID: 0,
 		})
 		return
@@ -1595,6 +1809,7 @@ func enhanceMLXDetection(info *SystemInfo) {
 		Name:     gpuName,
 		VRAMGB:   unifiedMemoryGB,
 		Type:     "MLX",
+		Kind:     GPUKindIntegrated,
 		DeviceID: 0,
 	})
 }
@@ -1695,7 +1910,10 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 					parts := strings.Fields(line)
 					if len(parts) > 0 {
 						// Extract GPU name and memory estimate
-						if strings.Contains(strings.ToLower(line), "iris xe") {
+						if strings.Contains(strings.ToLower(line), "arc") {
+							gpuName = "Intel Arc"
+							sharedMemoryGB = 8.0 // Discrete GPU with dedicated VRAM
+						} else if strings.Contains(strings.ToLower(line), "iris xe") {
 							gpuName = "Intel Iris Xe"
 							sharedMemoryGB = 8.0 // Modern integrated GPU
 						} else if strings.Contains(strings.ToLower(line), "iris") {
@@ -1715,6 +1933,7 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 				Name:     gpuName,
 				VRAMGB:   sharedMemoryGB,
 				Type:     "Intel",
+				Kind:     intelGPUKind(gpuName),
 				DeviceID: 0,
 			})
 		}
@@ -1727,13 +1946,17 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 			return
 		}
 
-		outputStr := strings.ToLower(string(output))
-		if strings.Contains(outputStr, "intel") && strings.Contains(outputStr, "graphics") {
+		// only look at display controllers, the host bridge of an Intel CPU also mentions Intel
+		outputStr := intelDisplayControllers(string(output))
+		if outputStr != "" {
 			var gpuName string
 			var sharedMemoryGB float64 = 4.0
 
 			// Parse for specific Intel GPU types
-			if strings.Contains(outputStr, "iris xe") {
+			if strings.Contains(outputStr, "arc") {
+				gpuName = "Intel Arc"
+				sharedMemoryGB = 8.0
+			} else if strings.Contains(outputStr, "iris xe") {
 				gpuName = "Intel Iris Xe"
 				sharedMemoryGB = 8.0
 			} else if strings.Contains(outputStr, "iris") {
@@ -1752,6 +1975,7 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 				Name:     gpuName,
 				VRAMGB:   sharedMemoryGB,
 				Type:     "Intel",
+				Kind:     intelGPUKind(gpuName),
 				DeviceID: 0,
 			})
 		}
@@ -1783,6 +2007,7 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 					Name:     gpuName,
 					VRAMGB:   sharedMemoryGB,
 					Type:     "Intel",
+					Kind:     intelGPUKind(gpuName),
 					DeviceID: 0,
 				})
 			}
@@ -1790,6 +2015,23 @@ func enhanceIntelGPUDetection(info *SystemInfo) {
 	}
 }
 
+// intelDisplayControllers returns the lowercased lspci device lines of Intel
+// VGA, display or 3D controllers, or "" when there are none
+func intelDisplayControllers(lspciOutput string) string {
+	var controllers []string
+	for _, line := range strings.Split(strings.ToLower(lspciOutput), "\n") {
+		if !strings.Contains(line, "intel") {
+			continue
+		}
+		if strings.Contains(line, "vga compatible controller") ||
+			strings.Contains(line, "display controller") ||
+			strings.Contains(line, "3d controller") {
+			controllers = append(controllers, line)
+		}
+	}
+	return strings.Join(controllers, "\n")
+}
+
 // ModelFileInfo contains detailed information about a model file
 type ModelFileInfo struct {
 	Path           string
@@ -1871,16 +2113,9 @@ func getTotalMultiPartSize(modelPath string) float64 {
 
 // detectQuantizationFromFilename detects quantization type from filename
 func detectQuantizationFromFilename(filename string) string {
-	filename = strings.ToUpper(filename)
-
-	quantTypes := []string{"Q4_K_M", "Q4_K_S", "Q5_K_M", "Q5_K_S", "Q8_0", "Q6_K", "IQ4_XS", "F16", "F32"}
-
-	for _, qtype := range quantTypes {
-		if strings.Contains(filename, qtype) {
-			return qtype
-		}
+	if quant := DetectQuantization(filename); quant != "" {
+		return quant
 	}
-
 	return "Unknown"
 }
 
@@ -1970,9 +2205,15 @@ func PrintSystemInfo(info *SystemInfo) {
 	if len(info.VRAMDetails) > 0 {
 		fmt.Printf("\n💾 GPU Memory Information:\n")
 		fmt.Printf("   Total Available: %.1f GB\n", info.TotalVRAMGB)
+		primary, _ := info.PrimaryGPU()
 		for i, gpu := range info.VRAMDetails {
 			emoji := getGPUEmoji(gpu.Type)
-			fmt.Printf("   %s GPU %d: %s (%.1f GB)\n", emoji, i, gpu.Name, gpu.VRAMGB)
+			fmt.Printf("   %s GPU %d: %s (%.1f GB, %s)\n", emoji, i, gpu.Name, gpu.VRAMGB, gpu.Kind)
+			if info.HasMixedGPUs() && gpu == primary {
+				fmt.Printf("      🎯 Discrete GPU preferred for inference\n")
+			} else if info.HasMixedGPUs() && gpu.Kind == GPUKindIntegrated {
+				fmt.Printf("      💤 Integrated GPU not used for inference or VRAM budget\n")
+			}
 
 			// Add platform-specific notes
 			switch gpu.Type {
@@ -0,0 +1,304 @@
+package autosetup
+
+import (
+	"strings"
+	"testing"
+)
+
+// stubBinaryExists replaces the release asset check with a fixed set of published files
+func stubBinaryExists(t *testing.T, published ...string) {
+	t.Helper()
+	original := binaryExists
+	binaryExists = func(url string) bool {
+		for _, name := range published {
+			if strings.HasSuffix(url, "/"+name) {
+				return true
+			}
+		}
+		return false
+	}
+	t.Cleanup(func() { binaryExists = original })
+}
+
+func TestGetOptimalBinaryURL_IntelSYCL(t *testing.T) {
+	const version = "b6527"
+	windowsIntel := SystemInfo{OS: "windows", Architecture: "amd64", HasIntel: true, HasVulkan: true}
+
+	tests := []struct {
+		name         string
+		system       SystemInfo
+		forceBackend string
+		published    []string
+		wantType     string
+		wantFile     string
+	}{
+		{
+			name:      "windows intel uses sycl",
+			system:    windowsIntel,
+			published: []string{"llama-b6527-bin-win-sycl-x64.zip", "llama-b6527-bin-win-vulkan-x64.zip"},
+			wantType:  "sycl",
+			wantFile:  "llama-b6527-bin-win-sycl-x64.zip",
+		},
+		{
+			name:      "windows intel falls back to vulkan",
+			system:    windowsIntel,
+			published: []string{"llama-b6527-bin-win-vulkan-x64.zip", "llama-b6527-bin-win-cpu-x64.zip"},
+			wantType:  "vulkan",
+			wantFile:  "llama-b6527-bin-win-vulkan-x64.zip",
+		},
+		{
+			name:      "windows intel falls back to cpu",
+			system:    windowsIntel,
+			published: []string{"llama-b6527-bin-win-cpu-x64.zip"},
+			wantType:  "cpu",
+			wantFile:  "llama-b6527-bin-win-cpu-x64.zip",
+		},
+		{
+			name:      "cuda is preferred over intel",
+			system:    SystemInfo{OS: "windows", Architecture: "amd64", HasIntel: true, HasCUDA: true},
+			published: []string{"llama-b6527-bin-win-cuda-12.4-x64.zip", "llama-b6527-bin-win-sycl-x64.zip"},
+			wantType:  "cuda",
+			wantFile:  "llama-b6527-bin-win-cuda-12.4-x64.zip",
+		},
+		{
+			name:      "no intel gpu detected does not use sycl",
+			system:    SystemInfo{OS: "windows", Architecture: "amd64", HasVulkan: true},
+			published: []string{"llama-b6527-bin-win-sycl-x64.zip", "llama-b6527-bin-win-vulkan-x64.zip"},
+			wantType:  "vulkan",
+			wantFile:  "llama-b6527-bin-win-vulkan-x64.zip",
+		},
+		{
+			name:      "linux intel uses vulkan",
+			system:    SystemInfo{OS: "linux", Architecture: "amd64", HasIntel: true},
+			published: []string{"llama-b6527-bin-ubuntu-x64-vulkan.zip"},
+			wantType:  "vulkan",
+			wantFile:  "llama-b6527-bin-ubuntu-x64-vulkan.zip",
+		},
+		{
+			name:         "forced intel backend maps to sycl",
+			system:       SystemInfo{OS: "windows", Architecture: "amd64"},
+			forceBackend: "intel",
+			published:    []string{"llama-b6527-bin-win-sycl-x64.zip"},
+			wantType:     "sycl",
+			wantFile:     "llama-b6527-bin-win-sycl-x64.zip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubBinaryExists(t, tt.published...)
+
+			url, binaryType, err := GetOptimalBinaryURL(tt.system, tt.forceBackend, version)
+			if err != nil {
+				t.Fatalf("GetOptimalBinaryURL returned error: %v", err)
+			}
+			if binaryType != tt.wantType {
+				t.Errorf("binary type = %s, want %s", binaryType, tt.wantType)
+			}
+			if !strings.HasSuffix(url, "/"+tt.wantFile) {
+				t.Errorf("url = %s, want file %s", url, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestIntelDisplayControllers(t *testing.T) {
+	lspci := `00:00.0 Host bridge: Intel Corporation 12th Gen Core Processor Host Bridge/DRAM Registers (rev 02)
+	Subsystem: Lenovo Device 3b0b
+00:02.0 VGA compatible controller: Intel Corporation Alder Lake-P GT2 [Iris Xe Graphics] (rev 0c)
+	Kernel driver in use: i915
+01:00.0 VGA compatible controller: NVIDIA Corporation GA107M [GeForce RTX 3050 Mobile] (rev a1)`
+
+	controllers := intelDisplayControllers(lspci)
+	if !strings.Contains(controllers, "iris xe") {
+		t.Errorf("expected Iris Xe controller, got %q", controllers)
+	}
+	if strings.Contains(controllers, "host bridge") || strings.Contains(controllers, "nvidia") {
+		t.Errorf("unexpected non Intel GPU lines in %q", controllers)
+	}
+
+	// an Intel CPU with an NVIDIA GPU has no Intel GPU
+	lspci = `00:00.0 Host bridge: Intel Corporation Device 4660 (rev 02)
+01:00.0 VGA compatible controller: NVIDIA Corporation AD104 [GeForce RTX 4070] (rev a1)
+	Subsystem: Intel Corporation Graphics Device`
+	if controllers := intelDisplayControllers(lspci); controllers != "" {
+		t.Errorf("expected no Intel GPU, got %q", controllers)
+	}
+}
+
+func TestSystemInfo_MixedGPUs(t *testing.T) {
+	system := SystemInfo{
+		OS:        "windows",
+		HasCUDA:   true,
+		HasIntel:  true,
+		HasVulkan: true,
+		VRAMDetails: []GPUInfo{
+			{Name: "Intel Iris Xe", VRAMGB: 8.0, Type: "Intel", Kind: intelGPUKind("Intel Iris Xe")},
+			{Name: "NVIDIA GeForce RTX 3050 Laptop GPU", VRAMGB: 4.0, Type: "CUDA", Kind: GPUKindDiscrete},
+		},
+	}
+
+	if !system.HasMixedGPUs() {
+		t.Fatal("expected mixed GPUs")
+	}
+
+	primary, ok := system.PrimaryGPU()
+	if !ok || primary.Type != "CUDA" {
+		t.Errorf("primary GPU = %+v, want the discrete CUDA GPU", primary)
+	}
+	if system.IntelIsPrimaryGPU() {
+		t.Error("expected the Intel iGPU not to be the primary GPU")
+	}
+
+	// the iGPU's shared memory is not part of the VRAM budget
+	if vram := budgetVRAM(system.VRAMDetails); vram != 4.0 {
+		t.Errorf("VRAM budget = %.1f, want 4.0", vram)
+	}
+
+	// the iGPU is still reported
+	if system.VRAMDetails[0].Kind != GPUKindIntegrated {
+		t.Errorf("Iris Xe kind = %s, want %s", system.VRAMDetails[0].Kind, GPUKindIntegrated)
+	}
+
+	stubBinaryExists(t, "llama-b6527-bin-win-cuda-12.4-x64.zip", "llama-b6527-bin-win-sycl-x64.zip")
+	if _, binaryType, _ := GetOptimalBinaryURL(system, "", "b6527"); binaryType != "cuda" {
+		t.Errorf("binary type = %s, want cuda", binaryType)
+	}
+
+	// an Intel iGPU next to a discrete GPU only usable through Vulkan does not select SYCL
+	system.HasCUDA = false
+	system.VRAMDetails[1] = GPUInfo{Name: "AMD Radeon RX 6600M", VRAMGB: 8.0, Type: "ROCm", Kind: GPUKindDiscrete}
+	stubBinaryExists(t, "llama-b6527-bin-win-vulkan-x64.zip", "llama-b6527-bin-win-sycl-x64.zip")
+	if _, binaryType, _ := GetOptimalBinaryURL(system, "", "b6527"); binaryType != "vulkan" {
+		t.Errorf("binary type = %s, want vulkan", binaryType)
+	}
+}
+
+func TestSystemInfo_IntegratedOnly(t *testing.T) {
+	system := SystemInfo{
+		VRAMDetails: []GPUInfo{
+			{Name: "Intel UHD Graphics", VRAMGB: 5.0, Type: "Intel", Kind: intelGPUKind("Intel UHD Graphics")},
+		},
+	}
+
+	if system.HasMixedGPUs() {
+		t.Error("expected no mixed GPUs")
+	}
+	if primary, ok := system.PrimaryGPU(); !ok || primary.Name != "Intel UHD Graphics" {
+		t.Errorf("primary GPU = %+v, want the integrated GPU", primary)
+	}
+	if vram := budgetVRAM(system.VRAMDetails); vram != 5.0 {
+		t.Errorf("VRAM budget = %.1f, want 5.0", vram)
+	}
+	if !system.IntelIsPrimaryGPU() {
+		t.Error("expected the Intel iGPU to be the primary GPU")
+	}
+
+	for name, want := range map[string]string{
+		"Intel Arc":                        GPUKindDiscrete,
+		"Intel(R) Arc(TM) A770 Graphics":   GPUKindDiscrete,
+		"Intel(R) Graphics Searchlight":    GPUKindIntegrated,
+		"Intel(R) UHD Graphics 770 Monarc": GPUKindIntegrated,
+	} {
+		if kind := intelGPUKind(name); kind != want {
+			t.Errorf("%s kind = %s, want %s", name, kind, want)
+		}
+	}
+}
+
+func TestParseROCmSMIOutput_APU(t *testing.T) {
+	output := `============================ ROCm System Management Interface ============================
+================================== Memory Usage (Bytes) ==================================
+GPU[0]		: VRAM Total Memory (B): 536870912
+GPU[0]		: VRAM Total Used Memory (B): 104857600
+==========================================================================================
+====================================== Product Info ======================================
+GPU[0]		: Card Series: 		AMD Radeon 780M
+GPU[0]		: Card Model: 		0x15bf
+GPU[0]		: Card Vendor: 		Advanced Micro Devices, Inc. [AMD/ATI]
+GPU[0]		: Card SKU: 		PHOENIX
+==========================================================================================`
+
+	gpus := parseROCmSMIOutput(output, 32.0)
+	if len(gpus) != 1 {
+		t.Fatalf("got %d GPUs, want 1", len(gpus))
+	}
+	apu := gpus[0]
+	if apu.Name != "AMD Radeon 780M" || apu.Kind != GPUKindIntegrated {
+		t.Errorf("APU = %+v, want an integrated AMD Radeon 780M", apu)
+	}
+	// the 512MB carve-out is replaced by a share of system RAM
+	if apu.VRAMGB != 32.0*apuSharedMemoryFraction {
+		t.Errorf("APU VRAM = %.1f, want %.1f", apu.VRAMGB, 32.0*apuSharedMemoryFraction)
+	}
+
+	// an APU next to a discrete card is left out of the VRAM budget
+	output = `GPU[0]		: VRAM Total Memory (B): 25753026560
+GPU[1]		: VRAM Total Memory (B): 536870912
+GPU[0]		: Card Series: 		Radeon RX 7900 XTX
+GPU[1]		: Card Series: 		AMD Radeon Graphics`
+	gpus = parseROCmSMIOutput(output, 64.0)
+	if len(gpus) != 2 {
+		t.Fatalf("got %d GPUs, want 2", len(gpus))
+	}
+	if gpus[0].Kind != GPUKindDiscrete || gpus[0].VRAMGB < 23.9 || gpus[0].VRAMGB > 24.0 {
+		t.Errorf("discrete GPU = %+v, want a 24GB discrete GPU", gpus[0])
+	}
+	if gpus[1].Kind != GPUKindIntegrated {
+		t.Errorf("Radeon Graphics kind = %s, want %s", gpus[1].Kind, GPUKindIntegrated)
+	}
+	if vram := budgetVRAM(gpus); vram != gpus[0].VRAMGB {
+		t.Errorf("VRAM budget = %.1f, want the discrete GPU's %.1f", vram, gpus[0].VRAMGB)
+	}
+
+	for name, want := range map[string]bool{
+		"AMD Radeon 890M":      true,
+		"AMD Radeon 8060S":     true,
+		"AMD Radeon Vega 8":    true,
+		"AMD Radeon Graphics":  true,
+		"STRIX":                true,
+		"AMD Radeon RX 6600M":  false,
+		"AMD Radeon Pro W7900": false,
+		"Radeon RX 7900 XTX":   false,
+		"AMD Instinct MI300X":  false,
+	} {
+		if got := isAMDAPU(name); got != want {
+			t.Errorf("isAMDAPU(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestApplyVRAMOverrides(t *testing.T) {
+	system := SystemInfo{
+		VRAMDetails: []GPUInfo{
+			{Name: "NVIDIA A16", VRAMGB: 2.0, Type: "CUDA", Kind: GPUKindDiscrete},
+			{Name: "NVIDIA GeForce RTX 3060", VRAMGB: 12.0, Type: "CUDA", Kind: GPUKindDiscrete, DeviceID: 1},
+			{Name: "Intel UHD Graphics", VRAMGB: 4.0, Type: "Intel", Kind: GPUKindIntegrated},
+		},
+	}
+	system.TotalVRAMGB = budgetVRAM(system.VRAMDetails)
+
+	ApplyVRAMOverrides(&system, map[string]float64{"0": 16.0, "nvidia geforce rtx 3060": 11.5, "Missing GPU": 8.0})
+
+	if !system.HasVRAMOverrides() {
+		t.Fatal("expected VRAM overrides")
+	}
+	if gpu := system.VRAMDetails[0]; gpu.VRAMGB != 16.0 || gpu.DetectedVRAMGB != 2.0 {
+		t.Errorf("GPU 0 = %+v, want 16GB overriding the detected 2GB", gpu)
+	}
+	if gpu := system.VRAMDetails[1]; gpu.VRAMGB != 11.5 || gpu.DetectedVRAMGB != 12.0 {
+		t.Errorf("GPU 1 = %+v, want 11.5GB overriding the detected 12GB", gpu)
+	}
+	if gpu := system.VRAMDetails[2]; gpu.VRAMGB != 4.0 || gpu.DetectedVRAMGB != 0 {
+		t.Errorf("GPU 2 = %+v, want it unchanged", gpu)
+	}
+	if system.TotalVRAMGB != 27.5 {
+		t.Errorf("TotalVRAMGB = %.1f, want 27.5", system.TotalVRAMGB)
+	}
+
+	// applying again keeps the originally detected value
+	ApplyVRAMOverrides(&system, map[string]float64{"0": 20.0})
+	if gpu := system.VRAMDetails[0]; gpu.VRAMGB != 20.0 || gpu.DetectedVRAMGB != 2.0 {
+		t.Errorf("GPU 0 = %+v, want 20GB overriding the detected 2GB", gpu)
+	}
+}
@@ -36,11 +36,32 @@ type GGUFMetadata struct {
 	KeyLength     uint32
 	ValueLength   uint32
 	SlidingWindow uint32
+
+	// RoPE settings llama-server reads from the file, zero when not set
+	RopeFreqBase           float32
+	RopeScalingType        string
+	RopeScalingFactor      float32
+	RopeScalingOrigContext uint32
+
+	// Quantization is the file type from general.file_type, e.g. "Q4_K_M", ""
+	// when it isn't set or unknown
+	Quantization string
+}
+
+// ggufFileTypes are the general.file_type values of llama.cpp's llama_ftype
+var ggufFileTypes = map[uint32]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16",
+	36: "TQ1_0", 37: "TQ2_0", 38: "MXFP4",
 }
 
 // GGUFReader reads GGUF file metadata
 type GGUFReader struct {
-	file     *os.File
+	file     io.ReadSeeker
+	closer   io.Closer
 	metadata *GGUFMetadata
 }
 
@@ -53,18 +74,48 @@ func NewGGUFReader(filepath string) (*GGUFReader, error) {
 
 	return &GGUFReader{
 		file:     file,
+		closer:   file,
 		metadata: &GGUFMetadata{},
 	}, nil
 }
 
+// NewGGUFStreamReader creates a GGUF reader over a non-seekable stream, such as
+// a file inside an archive. Skipped values are read and discarded.
+func NewGGUFStreamReader(stream io.Reader) *GGUFReader {
+	return &GGUFReader{
+		file:     &forwardSeeker{reader: stream},
+		metadata: &GGUFMetadata{},
+	}
+}
+
 // Close closes the file
 func (r *GGUFReader) Close() error {
-	if r.file != nil {
-		return r.file.Close()
+	if r.closer != nil {
+		return r.closer.Close()
 	}
 	return nil
 }
 
+// forwardSeeker adapts an io.Reader to io.ReadSeeker for forward relative seeks,
+// which is all the metadata reader needs to skip values
+type forwardSeeker struct {
+	reader io.Reader
+}
+
+func (f *forwardSeeker) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *forwardSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekCurrent || offset < 0 {
+		return 0, fmt.Errorf("stream only supports forward relative seeks")
+	}
+	if _, err := io.CopyN(io.Discard, f.reader, offset); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
 // ReadMetadata reads and parses the GGUF metadata
 func (r *GGUFReader) ReadMetadata() (*GGUFMetadata, error) {
 	// Read GGUF header
@@ -104,6 +155,7 @@ func (r *GGUFReader) readMetadataKVs(count uint64) error {
 	keysToRead := map[string]bool{
 		"general.architecture": true,
 		"general.name":         true,
+		"general.file_type":    true,
 	}
 
 	archSpecificKeysAdded := false
@@ -130,6 +182,10 @@ func (r *GGUFReader) readMetadataKVs(count uint64) error {
 			keysToRead[prefix+".attention.key_length"] = true
 			keysToRead[prefix+".attention.value_length"] = true
 			keysToRead[prefix+".attention.sliding_window_size"] = true
+			keysToRead[prefix+".rope.freq_base"] = true
+			keysToRead[prefix+".rope.scaling.type"] = true
+			keysToRead[prefix+".rope.scaling.factor"] = true
+			keysToRead[prefix+".rope.scaling.original_context_length"] = true
 
 			// Additional sliding window keys that some models might use
 			keysToRead[prefix+".attention.sliding_window"] = true
@@ -198,6 +254,16 @@ func (r *GGUFReader) readAndStoreValue(key string, valueType uint32) error {
 		}
 		r.metadata.ModelName = name
 
+	case "general.file_type":
+		if valueType != GGUFTypeUInt32 {
+			return r.skipValue(valueType)
+		}
+		var value uint32
+		if err := binary.Read(r.file, binary.LittleEndian, &value); err != nil {
+			return err
+		}
+		r.metadata.Quantization = ggufFileTypes[value]
+
 	default:
 		// Architecture-specific keys
 		if strings.HasSuffix(key, ".block_count") {
@@ -277,6 +343,42 @@ func (r *GGUFReader) readAndStoreValue(key string, valueType uint32) error {
 				return r.skipValue(valueType)
 			}
 
+		} else if strings.HasSuffix(key, ".rope.freq_base") || strings.HasSuffix(key, ".rope.scaling.factor") {
+			if valueType == GGUFTypeFloat32 {
+				var value float32
+				if err := binary.Read(r.file, binary.LittleEndian, &value); err != nil {
+					return err
+				}
+				if strings.HasSuffix(key, ".rope.freq_base") {
+					r.metadata.RopeFreqBase = value
+				} else {
+					r.metadata.RopeScalingFactor = value
+				}
+			} else {
+				return r.skipValue(valueType)
+			}
+
+		} else if strings.HasSuffix(key, ".rope.scaling.type") {
+			if valueType != GGUFTypeString {
+				return r.skipValue(valueType)
+			}
+			scalingType, err := r.readString()
+			if err != nil {
+				return err
+			}
+			r.metadata.RopeScalingType = scalingType
+
+		} else if strings.HasSuffix(key, ".rope.scaling.original_context_length") {
+			if valueType == GGUFTypeUInt32 {
+				var value uint32
+				if err := binary.Read(r.file, binary.LittleEndian, &value); err != nil {
+					return err
+				}
+				r.metadata.RopeScalingOrigContext = value
+			} else {
+				return r.skipValue(valueType)
+			}
+
 		} else if key == "general.sliding_window_size" || key == "attention.sliding_window_size" || key == "sliding_window_size" {
 			if valueType == GGUFTypeUInt32 {
 				var value uint32
@@ -471,3 +573,24 @@ func (r *GGUFReader) readAnyValue(valueType uint32) (interface{}, error) {
 		return nil, err
 	}
 }
+
+// ReadEmbeddingLength returns the <arch>.embedding_length of a GGUF file, which
+// is the output vector dimension for embedding models
+func ReadEmbeddingLength(filepath string) (int, error) {
+	metadata, err := ReadAllGGUFKeys(filepath)
+	if err != nil {
+		return 0, err
+	}
+
+	architecture := getStringValue(metadata, "general.architecture")
+	if architecture == "" {
+		return 0, fmt.Errorf("general.architecture not found")
+	}
+
+	embeddingLength := getIntValue(metadata, architecture+".embedding_length")
+	if embeddingLength <= 0 {
+		return 0, fmt.Errorf("%s.embedding_length not found", architecture)
+	}
+
+	return embeddingLength, nil
+}
@@ -0,0 +1,130 @@
+package autosetup
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// remoteGGUFChunkSize is how much of a remote GGUF file one ranged request
+// fetches. Most headers fit in one or two chunks, ones with a large tokenizer
+// vocabulary take a few more.
+const remoteGGUFChunkSize = 1024 * 1024
+
+// RemoteGGUFMetadataLimit caps how many bytes are fetched for the metadata of a
+// remote GGUF file, so a corrupt header can't turn into a full download
+var RemoteGGUFMetadataLimit int64 = 64 * 1024 * 1024
+
+// rangeReader reads a remote file through HTTP Range requests, one chunk at a
+// time as the reader gets to it
+type rangeReader struct {
+	client  *http.Client
+	url     string
+	token   string
+	offset  int64
+	chunk   []byte
+	start   int64 // offset of chunk in the file
+	size    int64 // file size once known, -1 before
+	fetched int64
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset < r.start || r.offset >= r.start+int64(len(r.chunk)) {
+		if err := r.fetch(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.offset-r.start:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Seek moves the read offset, skipped bytes aren't fetched
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	default:
+		return 0, fmt.Errorf("unsupported seek whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative seek offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// fetch gets the chunk that starts at the read offset
+func (r *rangeReader) fetch() error {
+	if r.size >= 0 && r.offset >= r.size {
+		return io.EOF
+	}
+	if r.fetched >= RemoteGGUFMetadataLimit {
+		return fmt.Errorf("GGUF metadata is larger than %d bytes", RemoteGGUFMetadataLimit)
+	}
+
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+remoteGGUFChunkSize-1))
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return io.EOF
+	case http.StatusOK:
+		// the whole file would follow
+		return fmt.Errorf("server does not support range requests")
+	default:
+		return fmt.Errorf("status %d fetching %s", resp.StatusCode, r.url)
+	}
+
+	// Content-Range: bytes 0-1048575/4368439296
+	if _, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/"); found {
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			r.size = size
+		}
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(resp.Body, remoteGGUFChunkSize))
+	if err != nil {
+		return err
+	}
+	if len(chunk) == 0 {
+		return io.EOF
+	}
+	r.chunk = chunk
+	r.start = r.offset
+	r.fetched += int64(len(chunk))
+	return nil
+}
+
+// ReadGGUFMetadataFromURL reads the metadata of a GGUF file from a URL, fetching
+// only its header with ranged requests, so a model can be inspected before it
+// is downloaded. token is sent as a bearer token when set, e.g. for gated
+// HuggingFace repos.
+func ReadGGUFMetadataFromURL(url, token string) (*GGUFMetadata, error) {
+	reader := &GGUFReader{
+		file: &rangeReader{
+			client: NewHTTPClient(60 * time.Second),
+			url:    url,
+			token:  token,
+			size:   -1,
+		},
+		metadata: &GGUFMetadata{},
+	}
+	return reader.ReadMetadata()
+}
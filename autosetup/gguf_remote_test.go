@@ -0,0 +1,67 @@
+package autosetup
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadGGUFMetadataFromURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	writeRopeGGUF(t, path, map[string]interface{}{
+		"general.architecture": "llama",
+		"general.file_type":    uint32(15),
+		// sorts before the context length and pushes it past the first chunk
+		"llama.chat_template":  strings.Repeat("x", remoteGGUFChunkSize+100),
+		"llama.context_length": uint32(131072),
+	})
+	header, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stands in for gigabytes of tensor data
+	file := append(header, make([]byte, 8*remoteGGUFChunkSize)...)
+
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if r.URL.Path == "/no-ranges.gguf" {
+			w.Write(file)
+			return
+		}
+		http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(file))
+	}))
+	defer server.Close()
+
+	metadata, err := ReadGGUFMetadataFromURL(server.URL+"/model.gguf", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Architecture != "llama" || metadata.ContextLength != 131072 || metadata.Quantization != "Q4_K_M" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+	// the first chunk and the one with the context length, the template is skipped
+	if len(ranges) != 2 || ranges[0] != "bytes=0-1048575" {
+		t.Errorf("expected two ranged requests starting at 0, got %v", ranges)
+	}
+
+	if _, err := ReadGGUFMetadataFromURL(server.URL+"/no-ranges.gguf", "secret"); err == nil || !strings.Contains(err.Error(), "range requests") {
+		t.Errorf("expected an error for a server without range support, got %v", err)
+	}
+	if _, err := ReadGGUFMetadataFromURL(server.URL+"/model.gguf", ""); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error without the token, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package autosetup
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// PhysicalGPU is a GPU found on the PCI bus or by the OS, whether or not a
+// backend can use it
+type PhysicalGPU struct {
+	Name       string `json:"name"`
+	Vendor     string `json:"vendor"` // nvidia, amd, intel, apple or ""
+	DriverHint string `json:"driverHint"`
+}
+
+// lspciRevision is the " (rev a1)" suffix of an lspci device line
+var lspciRevision = regexp.MustCompile(`\s*\(rev [0-9a-fA-F]+\)\s*$`)
+
+// EnumeratePhysicalGPUs lists the GPUs of the machine without a GPU library:
+// lspci on Linux, WMI on Windows and system_profiler on macOS. It tells a
+// machine without a GPU apart from one whose driver or backend is missing.
+func EnumeratePhysicalGPUs() []PhysicalGPU {
+	var gpus []PhysicalGPU
+	switch runtime.GOOS {
+	case "linux":
+		if output, err := exec.Command("lspci").Output(); err == nil {
+			gpus = parseLspciGPUs(string(output))
+		}
+	case "windows":
+		// wmic is deprecated and missing from recent Windows 11 installs, it is
+		// only the fallback for systems without PowerShell
+		if output, err := exec.Command("powershell", "-Command",
+			"Get-CimInstance -ClassName Win32_VideoController | Select-Object -ExpandProperty Name").Output(); err == nil {
+			gpus = parseWMIGPUs(string(output))
+		}
+		if len(gpus) == 0 {
+			if output, err := exec.Command("wmic", "path", "win32_VideoController", "get", "name").Output(); err == nil {
+				gpus = parseWMIGPUs(string(output))
+			}
+		}
+	case "darwin":
+		if output, err := exec.Command("system_profiler", "SPDisplaysDataType").Output(); err == nil {
+			gpus = parseSystemProfilerGPUs(string(output))
+		}
+	}
+	return gpus
+}
+
+// parseLspciGPUs returns the VGA, display and 3D controllers of lspci output
+func parseLspciGPUs(output string) []PhysicalGPU {
+	var gpus []PhysicalGPU
+	for _, line := range strings.Split(output, "\n") {
+		// device lines start with the bus address, detail lines are indented
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "vga compatible controller") &&
+			!strings.Contains(lower, "display controller") &&
+			!strings.Contains(lower, "3d controller") {
+			continue
+		}
+		_, name, found := strings.Cut(line, "controller: ")
+		if !found {
+			continue
+		}
+		gpus = append(gpus, newPhysicalGPU(lspciRevision.ReplaceAllString(name, "")))
+	}
+	return gpus
+}
+
+// parseWMIGPUs returns the GPUs of the Win32_VideoController names, one per line
+// from Get-CimInstance or under a Name header from wmic
+func parseWMIGPUs(output string) []PhysicalGPU {
+	var gpus []PhysicalGPU
+	for _, line := range strings.Split(output, "\n") {
+		// without a driver Windows lists the GPU as Microsoft Basic Display
+		// Adapter, it gets the generic hint
+		name := strings.TrimSpace(line)
+		if name == "" || strings.EqualFold(name, "Name") {
+			continue
+		}
+		gpus = append(gpus, newPhysicalGPU(name))
+	}
+	return gpus
+}
+
+// parseSystemProfilerGPUs returns the GPUs of `system_profiler SPDisplaysDataType`
+func parseSystemProfilerGPUs(output string) []PhysicalGPU {
+	var gpus []PhysicalGPU
+	for _, line := range strings.Split(output, "\n") {
+		if name, found := strings.CutPrefix(strings.TrimSpace(line), "Chipset Model:"); found {
+			gpus = append(gpus, newPhysicalGPU(strings.TrimSpace(name)))
+		}
+	}
+	return gpus
+}
+
+func newPhysicalGPU(name string) PhysicalGPU {
+	vendor := physicalGPUVendor(name)
+	return PhysicalGPU{Name: name, Vendor: vendor, DriverHint: driverInstallHint(vendor)}
+}
+
+// physicalGPUVendor returns the vendor of a GPU name
+func physicalGPUVendor(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "nvidia"):
+		return "nvidia"
+	case strings.Contains(lower, "advanced micro devices") || strings.Contains(lower, "amd") ||
+		strings.Contains(lower, "radeon") || strings.Contains(lower, "ati technologies"):
+		return "amd"
+	case strings.Contains(lower, "intel"):
+		return "intel"
+	case strings.Contains(lower, "apple"):
+		return "apple"
+	}
+	return ""
+}
+
+// driverInstallHint returns what to install so a backend can use a GPU of vendor
+func driverInstallHint(vendor string) string {
+	switch vendor {
+	case "nvidia":
+		return "Install the NVIDIA driver and the CUDA toolkit, or a Vulkan driver, then restart FrogLLM"
+	case "amd":
+		return "Install ROCm, or a Vulkan driver such as Mesa RADV or AMD Adrenalin, then restart FrogLLM"
+	case "intel":
+		return "Install the Intel graphics driver with Vulkan support, then restart FrogLLM"
+	case "apple":
+		return "Metal ships with macOS, update macOS if it is not detected"
+	}
+	return "Install the GPU vendor's driver with Vulkan support, then restart FrogLLM"
+}
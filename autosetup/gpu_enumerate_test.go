@@ -0,0 +1,63 @@
+package autosetup
+
+import (
+	"testing"
+)
+
+func TestParsePhysicalGPUs(t *testing.T) {
+	lspci := `00:00.0 Host bridge: Intel Corporation 12th Gen Core Processor Host Bridge/DRAM Registers (rev 02)
+00:02.0 VGA compatible controller: Intel Corporation Alder Lake-P GT2 [Iris Xe Graphics] (rev 0c)
+	Subsystem: Lenovo Device 3b0b
+01:00.0 3D controller: NVIDIA Corporation GA107M [GeForce RTX 3050 Mobile] (rev a1)
+03:00.0 Display controller: Advanced Micro Devices, Inc. [AMD/ATI] Navi 21 [Radeon RX 6800]
+04:00.0 Ethernet controller: Realtek Semiconductor Co., Ltd. RTL8111/8168/8411 (rev 15)`
+	cim := "NVIDIA GeForce RTX 4070\r\nMicrosoft Basic Display Adapter\r\n"
+	wmi := "Name                     \r\nNVIDIA GeForce RTX 4070  \r\nAMD Radeon(TM) Graphics  \r\n\r\n"
+	systemProfiler := `Graphics/Displays:
+
+    Apple M2:
+
+      Chipset Model: Apple M2
+      Type: GPU
+      Total Number of Cores: 10
+`
+
+	tests := []struct {
+		name   string
+		gpus   []PhysicalGPU
+		expect [][2]string // name, vendor
+	}{
+		{"lspci", parseLspciGPUs(lspci), [][2]string{
+			{"Intel Corporation Alder Lake-P GT2 [Iris Xe Graphics]", "intel"},
+			{"NVIDIA Corporation GA107M [GeForce RTX 3050 Mobile]", "nvidia"},
+			{"Advanced Micro Devices, Inc. [AMD/ATI] Navi 21 [Radeon RX 6800]", "amd"},
+		}},
+		{"cim", parseWMIGPUs(cim), [][2]string{
+			{"NVIDIA GeForce RTX 4070", "nvidia"},
+			{"Microsoft Basic Display Adapter", ""},
+		}},
+		{"wmi", parseWMIGPUs(wmi), [][2]string{
+			{"NVIDIA GeForce RTX 4070", "nvidia"},
+			{"AMD Radeon(TM) Graphics", "amd"},
+		}},
+		{"system_profiler", parseSystemProfilerGPUs(systemProfiler), [][2]string{
+			{"Apple M2", "apple"},
+		}},
+		{"no gpus", parseLspciGPUs("00:00.0 Host bridge: Intel Corporation Device 4660 (rev 02)\n"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.gpus) != len(tt.expect) {
+				t.Fatalf("expected %d GPUs, got %+v", len(tt.expect), tt.gpus)
+			}
+			for i, gpu := range tt.gpus {
+				if gpu.Name != tt.expect[i][0] || gpu.Vendor != tt.expect[i][1] {
+					t.Errorf("GPU %d: expected %q (%s), got %q (%s)", i, tt.expect[i][0], tt.expect[i][1], gpu.Name, gpu.Vendor)
+				}
+				if gpu.DriverHint == "" {
+					t.Errorf("GPU %d has no driver hint", i)
+				}
+			}
+		})
+	}
+}
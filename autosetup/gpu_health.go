@@ -0,0 +1,122 @@
+package autosetup
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GPUHealthIssue is an error state of a GPU that makes model loads fail until
+// the GPU is reset
+type GPUHealthIssue struct {
+	GPU     string `json:"gpu"` // PCI bus ID, empty when nvidia-smi could not name the GPU
+	Name    string `json:"name,omitempty"`
+	Problem string `json:"problem"`
+}
+
+var (
+	nvidiaSMIGPUPattern  = regexp.MustCompile(`^GPU ([0-9A-Fa-f]+:[0-9A-Fa-f]+:[0-9A-Fa-f]+\.[0-9A-Fa-f]+)\s*$`)
+	nvidiaSMILostPattern = regexp.MustCompile(`(?i)GPU is lost|unable to determine the device handle|fallen off the bus`)
+)
+
+// QueryNvidiaSMIHealth runs nvidia-smi -q and returns its health issues. A wedged
+// GPU can make nvidia-smi fail, its output is parsed anyway.
+func QueryNvidiaSMIHealth() ([]GPUHealthIssue, error) {
+	output, err := exec.Command("nvidia-smi", "-q").CombinedOutput()
+	issues := ParseNvidiaSMIHealth(string(output))
+	if err != nil && len(issues) == 0 {
+		return nil, fmt.Errorf("nvidia-smi -q failed: %v", err)
+	}
+	return issues, nil
+}
+
+// ParseNvidiaSMIHealth finds the error states in nvidia-smi -q output: volatile
+// uncorrectable ECC errors, pending page retirement or row remapping, a required
+// reset and GPUs that fell off the bus (Xid 79)
+func ParseNvidiaSMIHealth(output string) []GPUHealthIssue {
+	type section struct {
+		indent int
+		name   string
+	}
+	var issues []GPUHealthIssue
+	var sections []section
+	gpu, name := "", ""
+	volatileUncorrectable := 0
+
+	flushGPU := func() {
+		if gpu != "" && volatileUncorrectable > 0 {
+			issues = append(issues, GPUHealthIssue{GPU: gpu, Name: name,
+				Problem: fmt.Sprintf("%d volatile uncorrectable ECC errors", volatileUncorrectable)})
+		}
+		volatileUncorrectable = 0
+	}
+	addIssue := func(problem string) {
+		issues = append(issues, GPUHealthIssue{GPU: gpu, Name: name, Problem: problem})
+	}
+	inSection := func(names ...string) bool {
+		for _, want := range names {
+			found := false
+			for _, s := range sections {
+				if s.name == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, " \r\t")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if nvidiaSMILostPattern.MatchString(trimmed) {
+			addIssue("GPU is lost: " + trimmed)
+			continue
+		}
+		if m := nvidiaSMIGPUPattern.FindStringSubmatch(line); m != nil {
+			flushGPU()
+			gpu, name, sections = m[1], "", nil
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(sections) > 0 && sections[len(sections)-1].indent >= indent {
+			sections = sections[:len(sections)-1]
+		}
+		key, value, isValue := strings.Cut(trimmed, ":")
+		if !isValue {
+			sections = append(sections, section{indent: indent, name: trimmed})
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		pending := strings.EqualFold(value, "Yes")
+
+		switch {
+		case key == "Product Name":
+			name = value
+		case inSection("ECC Errors", "Volatile") &&
+			(strings.Contains(key, "Uncorrectable") || (inSection("Double Bit") && key == "Total")):
+			if n, err := strconv.Atoi(value); err == nil {
+				volatileUncorrectable += n
+			}
+		case inSection("Retired Pages") && strings.HasPrefix(key, "Pending Page") && pending:
+			addIssue("page retirement pending, reset the GPU")
+		case inSection("Remapped Rows") && key == "Pending" && pending:
+			addIssue("row remapping pending, reset the GPU")
+		case inSection("Remapped Rows") && key == "Remapping Failure Occurred" && pending:
+			addIssue("row remapping failed, the GPU needs service")
+		case inSection("GPU Reset Status") && (key == "Reset Required" || key == "Drain and Reset Recommended") && pending:
+			addIssue(strings.ToLower(key))
+		}
+	}
+	flushGPU()
+	return issues
+}
@@ -0,0 +1,109 @@
+package autosetup
+
+import (
+	"reflect"
+	"testing"
+)
+
+const nvidiaSMIHealthy = `
+==============NVSMI LOG==============
+
+Timestamp                                 : Tue Mar  5 10:12:01 2024
+Driver Version                            : 535.104.05
+CUDA Version                              : 12.2
+
+Attached GPUs                             : 1
+GPU 00000000:01:00.0
+    Product Name                          : NVIDIA A100-SXM4-40GB
+    ECC Mode
+        Current                           : Enabled
+        Pending                           : Enabled
+    ECC Errors
+        Volatile
+            SRAM Correctable              : 0
+            SRAM Uncorrectable            : 0
+            DRAM Correctable              : 4
+            DRAM Uncorrectable            : 0
+        Aggregate
+            SRAM Correctable              : 0
+            SRAM Uncorrectable            : 0
+            DRAM Correctable              : 12
+            DRAM Uncorrectable            : 3
+    Retired Pages
+        Single Bit ECC                    : N/A
+        Double Bit ECC                    : N/A
+        Pending Page Blacklist            : N/A
+    Remapped Rows
+        Correctable Error                 : 0
+        Uncorrectable Error               : 0
+        Pending                           : No
+        Remapping Failure Occurred        : No
+        Bank Remap Availability Histogram
+            Max                           : 640 bank(s)
+            High                          : 0 bank(s)
+`
+
+const nvidiaSMIWedged = `
+==============NVSMI LOG==============
+
+Attached GPUs                             : 2
+GPU 00000000:01:00.0
+    Product Name                          : NVIDIA A100-SXM4-40GB
+    GPU Reset Status
+        Reset Required                    : Yes
+        Drain and Reset Recommended       : No
+    ECC Errors
+        Volatile
+            SRAM Correctable              : 0
+            SRAM Uncorrectable            : 1
+            DRAM Correctable              : 0
+            DRAM Uncorrectable            : 2
+        Aggregate
+            DRAM Uncorrectable            : 9
+    Remapped Rows
+        Correctable Error                 : 0
+        Uncorrectable Error               : 2
+        Pending                           : Yes
+        Remapping Failure Occurred        : No
+
+GPU 00000000:41:00.0
+    Product Name                          : Tesla V100-PCIE-16GB
+    ECC Errors
+        Volatile
+            Single Bit
+                Device Memory             : 0
+                Total                     : 0
+            Double Bit
+                Device Memory             : 5
+                Total                     : 5
+        Aggregate
+            Double Bit
+                Total                     : 7
+    Retired Pages
+        Single Bit ECC                    : 0
+        Double Bit ECC                    : 2
+        Pending Page Blacklist            : Yes
+`
+
+func TestParseNvidiaSMIHealth(t *testing.T) {
+	if issues := ParseNvidiaSMIHealth(nvidiaSMIHealthy); len(issues) != 0 {
+		t.Errorf("expected a healthy GPU, aggregate and correctable errors don't count: %v", issues)
+	}
+
+	want := []GPUHealthIssue{
+		{GPU: "00000000:01:00.0", Name: "NVIDIA A100-SXM4-40GB", Problem: "reset required"},
+		{GPU: "00000000:01:00.0", Name: "NVIDIA A100-SXM4-40GB", Problem: "row remapping pending, reset the GPU"},
+		{GPU: "00000000:01:00.0", Name: "NVIDIA A100-SXM4-40GB", Problem: "3 volatile uncorrectable ECC errors"},
+		{GPU: "00000000:41:00.0", Name: "Tesla V100-PCIE-16GB", Problem: "page retirement pending, reset the GPU"},
+		{GPU: "00000000:41:00.0", Name: "Tesla V100-PCIE-16GB", Problem: "5 volatile uncorrectable ECC errors"},
+	}
+	if issues := ParseNvidiaSMIHealth(nvidiaSMIWedged); !reflect.DeepEqual(issues, want) {
+		t.Errorf("issues = %+v\nwant %+v", issues, want)
+	}
+
+	lost := "Unable to determine the device handle for GPU0000:01:00.0: Unknown Error\n"
+	issues := ParseNvidiaSMIHealth(lost)
+	if len(issues) != 1 || issues[0].Problem != "GPU is lost: Unable to determine the device handle for GPU0000:01:00.0: Unknown Error" {
+		t.Errorf("expected a lost GPU, got %+v", issues)
+	}
+}
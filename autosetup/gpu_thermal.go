@@ -0,0 +1,71 @@
+package autosetup
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GPUThermalSample is the temperature, load and clock of a GPU at one point in time
+type GPUThermalSample struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Temperature int    `json:"temperature"` // Celsius
+	Utilization int    `json:"utilization"` // percent
+	SMClock     int    `json:"smClock"`     // MHz
+	BaseClock   int    `json:"baseClock"`   // MHz, the default applications clock, 0 when unknown
+
+	// the driver reports a hardware or software thermal slowdown
+	ThermalSlowdown bool `json:"thermalSlowdown"`
+}
+
+const nvidiaSMIThermalQuery = "index,name,temperature.gpu,utilization.gpu,clocks.sm,clocks.default_applications.graphics," +
+	"clocks_throttle_reasons.hw_thermal_slowdown,clocks_throttle_reasons.sw_thermal_slowdown"
+
+// QueryNvidiaSMIThermal samples the temperature and clocks of the NVIDIA GPUs
+func QueryNvidiaSMIThermal() ([]GPUThermalSample, error) {
+	output, err := exec.Command("nvidia-smi", "--query-gpu="+nvidiaSMIThermalQuery, "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi not available: %v", err)
+	}
+	samples := ParseNvidiaSMIThermal(string(output))
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no NVIDIA GPUs found")
+	}
+	return samples, nil
+}
+
+// ParseNvidiaSMIThermal parses the CSV output of QueryNvidiaSMIThermal. Values
+// the GPU doesn't support, [N/A], are left 0.
+func ParseNvidiaSMIThermal(output string) []GPUThermalSample {
+	var samples []GPUThermalSample
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.Split(line, ",")
+		if len(parts) < 8 {
+			continue
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		number := func(value string) int {
+			n, _ := strconv.Atoi(value)
+			return n
+		}
+
+		index, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		samples = append(samples, GPUThermalSample{
+			Index:           index,
+			Name:            parts[1],
+			Temperature:     number(parts[2]),
+			Utilization:     number(parts[3]),
+			SMClock:         number(parts[4]),
+			BaseClock:       number(parts[5]),
+			ThermalSlowdown: parts[6] == "Active" || parts[7] == "Active",
+		})
+	}
+	return samples
+}
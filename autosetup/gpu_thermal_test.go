@@ -0,0 +1,25 @@
+package autosetup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNvidiaSMIThermal(t *testing.T) {
+	output := `0, NVIDIA GeForce RTX 4090, 84, 98, 2235, 2235, Not Active, Not Active
+1, NVIDIA A100-SXM4-40GB, 91, 100, 1095, 1410, Active, Not Active
+2, Tesla T4, 45, 0, 300, [N/A], [N/A], [N/A]
+`
+	want := []GPUThermalSample{
+		{Index: 0, Name: "NVIDIA GeForce RTX 4090", Temperature: 84, Utilization: 98, SMClock: 2235, BaseClock: 2235},
+		{Index: 1, Name: "NVIDIA A100-SXM4-40GB", Temperature: 91, Utilization: 100, SMClock: 1095, BaseClock: 1410, ThermalSlowdown: true},
+		{Index: 2, Name: "Tesla T4", Temperature: 45, SMClock: 300},
+	}
+	if samples := ParseNvidiaSMIThermal(output); !reflect.DeepEqual(samples, want) {
+		t.Errorf("samples = %+v\nwant %+v", samples, want)
+	}
+
+	if samples := ParseNvidiaSMIThermal("No devices were found\n"); len(samples) != 0 {
+		t.Errorf("expected no samples, got %+v", samples)
+	}
+}
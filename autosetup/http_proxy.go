@@ -0,0 +1,97 @@
+package autosetup
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpProxy is the proxy outbound requests to GitHub and HuggingFace go
+// through. Without one the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables decide.
+var httpProxy = struct {
+	sync.RWMutex
+	url *url.URL
+}{}
+
+// SetHTTPProxy sets the proxy URL for outbound requests, replacing the one set
+// before. An empty URL goes back to the proxy environment variables. Hosts in
+// NO_PROXY and loopback addresses are still reached directly.
+func SetHTTPProxy(proxyURL string) error {
+	var parsed *url.URL
+	if proxyURL = strings.TrimSpace(proxyURL); proxyURL != "" {
+		if !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+		var err error
+		if parsed, err = url.Parse(proxyURL); err != nil || parsed.Host == "" {
+			return fmt.Errorf("invalid HTTP proxy URL %q", proxyURL)
+		}
+	}
+	httpProxy.Lock()
+	httpProxy.url = parsed
+	httpProxy.Unlock()
+	return nil
+}
+
+// proxyForRequest returns the proxy for an outbound request, nil to connect directly
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	httpProxy.RLock()
+	proxyURL := httpProxy.url
+	httpProxy.RUnlock()
+	if proxyURL == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	if bypassProxy(req.URL.Hostname(), os.Getenv("NO_PROXY")+","+os.Getenv("no_proxy")) {
+		return nil, nil
+	}
+	return proxyURL, nil
+}
+
+// bypassProxy reports if host is a loopback address or matches a NO_PROXY
+// entry, "*" or a domain that also matches its subdomains
+func bypassProxy(host, noProxy string) bool {
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, "*")
+		if host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundTransport is shared by every outbound client so connections are reused
+var outboundTransport = func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyForRequest
+	return transport
+}()
+
+// NewHTTPClient returns a client for requests to GitHub, HuggingFace and other
+// outside services that goes through the configured proxy. A zero timeout
+// doesn't time out, e.g. for model downloads.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: outboundTransport}
+}
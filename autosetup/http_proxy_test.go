@@ -0,0 +1,74 @@
+package autosetup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient_UsesConfiguredProxy(t *testing.T) {
+	var proxiedHost string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxy is sent the absolute URL
+		proxiedHost = r.URL.Host
+		w.Write([]byte(`{"tag_name":"b9999"}`))
+	}))
+	defer proxyServer.Close()
+
+	if err := SetHTTPProxy(proxyServer.URL); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetHTTPProxy("") })
+
+	resp, err := NewHTTPClient(5 * time.Second).Get("http://api.github.test/repos/ggml-org/llama.cpp/releases/latest")
+	if err != nil {
+		t.Fatalf("request through the proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if proxiedHost != "api.github.test" {
+		t.Errorf("expected the request to go through the proxy, proxy saw host %q", proxiedHost)
+	}
+
+	// loopback addresses are reached directly
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer direct.Close()
+	proxiedHost = ""
+	resp, err = NewHTTPClient(5 * time.Second).Get(direct.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxiedHost != "" {
+		t.Errorf("expected a loopback request to skip the proxy, proxy saw host %q", proxiedHost)
+	}
+
+	if err := SetHTTPProxy("http://"); err == nil {
+		t.Error("expected an error for a proxy URL without a host")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		host    string
+		noProxy string
+		want    bool
+	}{
+		{"huggingface.co", "", false},
+		{"localhost", "", true},
+		{"127.0.0.1", "", true},
+		{"::1", "", true},
+		{"huggingface.co", "*", true},
+		{"huggingface.co", "github.com, huggingface.co", true},
+		{"cdn-lfs.huggingface.co", ".huggingface.co", true},
+		{"cdn-lfs.huggingface.co", "huggingface.co", true},
+		{"nothuggingface.co", "huggingface.co", false},
+		{"mirror.corp", "mirror.corp:8080", true},
+		{"HuggingFace.co", "huggingface.co", true},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q, %q) = %v, want %v", tt.host, tt.noProxy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package autosetup
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+)
+
+// windowsMaxPath is MAX_PATH. Paths this long fail in Windows programs that don't
+// opt into long paths, llama-server included, even where Go could write them.
+const windowsMaxPath = 260
+
+// CheckPathLength returns a clear error for a path too long for Windows, so a
+// download or extraction fails up front instead of with a cryptic write error
+// or a model llama-server can't open. It always passes on other platforms.
+func CheckPathLength(path string) error {
+	return checkPathLength(runtime.GOOS, path)
+}
+
+func checkPathLength(goos, path string) error {
+	if goos != "windows" {
+		return nil
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if len(path) < windowsMaxPath {
+		return nil
+	}
+	return fmt.Errorf("path is %d characters, Windows allows at most %d: %s. Choose a shorter download directory",
+		len(path), windowsMaxPath-1, path)
+}
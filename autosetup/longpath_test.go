@@ -0,0 +1,22 @@
+package autosetup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPathLength(t *testing.T) {
+	short := `C:\models\bartowski_Qwen2.5-7B-Instruct-GGUF\Qwen2.5-7B-Instruct-Q4_K_M.gguf`
+	long := `C:\models\` + strings.Repeat("deeply-nested-folder\\", 12) + "Qwen2.5-7B-Instruct-Q4_K_M.gguf"
+
+	if err := checkPathLength("windows", short); err != nil {
+		t.Errorf("short path rejected: %v", err)
+	}
+	err := checkPathLength("windows", long)
+	if err == nil || !strings.Contains(err.Error(), "Choose a shorter download directory") {
+		t.Errorf("expected a clear error for a %d character path, got %v", len(long), err)
+	}
+	if err := checkPathLength("linux", "/"+strings.Repeat("a/", 200)); err != nil {
+		t.Errorf("long paths are fine outside of Windows: %v", err)
+	}
+}
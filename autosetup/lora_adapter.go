@@ -0,0 +1,183 @@
+package autosetup
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loraTensorScanLimit is how many tensor names are checked for LoRA tensors,
+// an adapter has them from the first tensor on
+const loraTensorScanLimit = 8
+
+// IsLoRAAdapter reports if a GGUF file is a LoRA adapter rather than a model.
+// Adapters converted by llama.cpp set general.type to "adapter" and adapter.type
+// to "lora"; older conversions are recognized by their lora_a/lora_b tensors.
+func IsLoRAAdapter(path string) bool {
+	metadata, err := ReadAllGGUFKeys(path)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(getStringValue(metadata, "adapter.type"), "lora") {
+		return true
+	}
+	if strings.EqualFold(getStringValue(metadata, "general.type"), "adapter") {
+		return true
+	}
+
+	names, err := readGGUFTensorNames(path, loraTensorScanLimit)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, ".lora_a") || strings.HasSuffix(name, ".lora_b") {
+			return true
+		}
+	}
+	return false
+}
+
+// readGGUFTensorNames returns the names of up to limit tensors of a GGUF file
+func readGGUFTensorNames(path string, limit int) ([]string, error) {
+	reader, err := NewGGUFReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var magic, version uint32
+	var tensorCount, metadataKVCount uint64
+	for _, field := range []interface{}{&magic, &version, &tensorCount, &metadataKVCount} {
+		if err := binary.Read(reader.file, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	}
+	if magic != GGUFMagic {
+		return nil, fmt.Errorf("invalid GGUF magic number: 0x%x", magic)
+	}
+
+	for i := uint64(0); i < metadataKVCount; i++ {
+		if _, err := reader.readString(); err != nil {
+			return nil, fmt.Errorf("failed to read key %d: %w", i, err)
+		}
+		var valueType uint32
+		if err := binary.Read(reader.file, binary.LittleEndian, &valueType); err != nil {
+			return nil, fmt.Errorf("failed to read value type of key %d: %w", i, err)
+		}
+		if err := reader.skipValue(valueType); err != nil {
+			return nil, fmt.Errorf("failed to skip value of key %d: %w", i, err)
+		}
+	}
+
+	var names []string
+	for i := uint64(0); i < tensorCount && len(names) < limit; i++ {
+		name, err := reader.readString()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tensor %d: %w", i, err)
+		}
+		names = append(names, name)
+
+		// dimension count, the dimensions, the type and the data offset
+		var dimensions uint32
+		if err := binary.Read(reader.file, binary.LittleEndian, &dimensions); err != nil {
+			return nil, fmt.Errorf("failed to read tensor %s: %w", name, err)
+		}
+		if _, err := reader.file.Seek(int64(dimensions)*8+4+8, io.SeekCurrent); err != nil {
+			return nil, fmt.Errorf("failed to read tensor %s: %w", name, err)
+		}
+	}
+	return names, nil
+}
+
+// skipLoRAAdapters removes the LoRA adapters from detected models, they can't
+// be loaded on their own and are only attached to a model with --lora
+func skipLoRAAdapters(models []ModelInfo) []ModelInfo {
+	kept := models[:0]
+	for _, model := range models {
+		if model.IsLoRA {
+			fmt.Printf("🧩 Skipping LoRA adapter: %s\n", filepath.Base(model.Path))
+			continue
+		}
+		kept = append(kept, model)
+	}
+	return kept
+}
+
+// DetectLoRAAdapters scans a directory for GGUF files that are LoRA adapters
+func DetectLoRAAdapters(modelsDir string) ([]ModelInfo, error) {
+	var adapters []ModelInfo
+	err := filepath.Walk(modelsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".gguf") {
+			return nil
+		}
+		if IsLoRAAdapter(path) {
+			adapters = append(adapters, ModelInfo{
+				Name:   strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())),
+				Path:   path,
+				IsLoRA: true,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan models directory: %v", err)
+	}
+	return adapters, nil
+}
+
+// LoRAAdapter is a LoRA adapter attached to a model, written to the model's
+// loraAdapters in the config. A Scale of 0 applies the adapter at full strength.
+type LoRAAdapter struct {
+	Path  string  `json:"path"`
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// LoRAAdaptersSizeGB returns the size of adapter files, which llama-server keeps
+// in memory next to the model. Missing files count as 0.
+func LoRAAdaptersSizeGB(paths []string) float64 {
+	var size int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return float64(size) / (1024 * 1024 * 1024)
+}
+
+// modelLoRAAdapters returns the adapters SetupOptions.LoRAAdapters attaches to
+// a model, matched by model name or ID
+func (scg *ConfigGenerator) modelLoRAAdapters(model ModelInfo, modelID string) []LoRAAdapter {
+	var attached []LoRAAdapter
+	for name, adapters := range scg.Options.LoRAAdapters {
+		if !strings.EqualFold(name, model.Name) && !strings.EqualFold(name, modelID) {
+			continue
+		}
+		for _, adapter := range adapters {
+			fmt.Printf("   🧩 %s: attaching LoRA adapter %s\n", model.Name, filepath.Base(adapter.Path))
+			attached = append(attached, adapter)
+		}
+	}
+	return attached
+}
+
+// writeLoRAAdapters writes the loraAdapters of a model, the config turns them
+// into --lora flags when it is loaded
+func writeLoRAAdapters(config *strings.Builder, adapters []LoRAAdapter) {
+	if len(adapters) == 0 {
+		return
+	}
+	config.WriteString("    loraAdapters:\n")
+	for _, adapter := range adapters {
+		config.WriteString(fmt.Sprintf("      - path: %s\n", quotePath(adapter.Path)))
+		if adapter.Scale != 0 {
+			config.WriteString(fmt.Sprintf("        scale: %s\n", strconv.FormatFloat(adapter.Scale, 'f', -1, 64)))
+		}
+	}
+}
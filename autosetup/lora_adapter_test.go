@@ -0,0 +1,168 @@
+package autosetup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTensorGGUF writes a GGUF header with an architecture and the tensor infos
+// of the given tensor names, without tensor data
+func writeTensorGGUF(t *testing.T, path, architecture string, tensorNames ...string) {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(GGUFMagic))
+	write(uint32(3)) // version
+	write(uint64(len(tensorNames)))
+	write(uint64(1)) // metadata count
+	writeString("general.architecture")
+	write(uint32(GGUFTypeString))
+	writeString(architecture)
+	for i, name := range tensorNames {
+		writeString(name)
+		write(uint32(2))                 // dimensions
+		write([]uint64{4096, 16})        // shape
+		write(uint32(0))                 // F32
+		write(uint64(i * 4096 * 16 * 4)) // offset
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsLoRAAdapter(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "llama-3-8b-Q4_K_M.gguf")
+	writeRopeGGUF(t, basePath, map[string]interface{}{
+		"general.architecture": "llama",
+		"general.type":         "model",
+		"llama.context_length": uint32(8192),
+		"llama.block_count":    uint32(32),
+	})
+	adapterPath := filepath.Join(dir, "llama-3-8b-sql-lora-F16.gguf")
+	writeRopeGGUF(t, adapterPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"general.type":         "adapter",
+		"adapter.type":         "lora",
+		"adapter.lora.alpha":   float32(16),
+	})
+	// older conversions without the adapter metadata, told apart by their tensors
+	tensorBasePath := filepath.Join(dir, "tensor-base.gguf")
+	writeTensorGGUF(t, tensorBasePath, "llama", "token_embd.weight", "blk.0.attn_q.weight")
+	tensorAdapterPath := filepath.Join(dir, "tensor-adapter.gguf")
+	writeTensorGGUF(t, tensorAdapterPath, "llama", "blk.0.attn_q.weight.lora_a", "blk.0.attn_q.weight.lora_b")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{basePath, false},
+		{adapterPath, true},
+		{tensorBasePath, false},
+		{tensorAdapterPath, true},
+	}
+	for _, tt := range tests {
+		if got := IsLoRAAdapter(tt.path); got != tt.want {
+			t.Errorf("IsLoRAAdapter(%s) = %v, want %v", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+
+	models, err := DetectModelsWithOptions(dir, SetupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range models {
+		if model.Path == adapterPath || model.Path == tensorAdapterPath {
+			t.Errorf("LoRA adapter %s listed as a model", model.Name)
+		}
+	}
+	if len(models) != 2 {
+		t.Errorf("expected the 2 base models, got %d", len(models))
+	}
+
+	adapters, err := DetectLoRAAdapters(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adapters) != 2 || !adapters[0].IsLoRA || !adapters[1].IsLoRA {
+		t.Errorf("expected the 2 LoRA adapters, got %+v", adapters)
+	}
+}
+
+func TestGenerateConfig_LoRAAdapters(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, basePath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(32768),
+		"llama.block_count":    uint32(32),
+	})
+	sqlPath := filepath.Join(dir, "sql-lora.gguf")
+	stylePath := filepath.Join(dir, "style-lora.gguf")
+
+	outputPath := filepath.Join(dir, "config.yaml")
+	scg := NewConfigGenerator(dir, "llama-server", outputPath, SetupOptions{
+		LoRAAdapters: map[string][]LoRAAdapter{
+			"base-7b-Q4_K_M": {{Path: sqlPath}, {Path: stylePath, Scale: 0.5}},
+			"other-model":    {{Path: sqlPath}},
+		},
+	})
+	if err := scg.GenerateConfig([]ModelInfo{{Name: "base-7b-Q4_K_M", Path: basePath, Size: "7B"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "    loraAdapters:\n      - path: " + sqlPath + "\n      - path: " + stylePath + "\n        scale: 0.5\n"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("expected the adapters attached to the model:\n%s", data)
+	}
+}
+
+func TestMemoryEstimator_LoRAAdapters(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "base-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"llama.context_length":          uint32(8192),
+		"llama.block_count":             uint32(32),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+	adapterPath := filepath.Join(dir, "sql-lora.gguf")
+	if err := os.WriteFile(adapterPath, make([]byte, 64*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	estimator := NewMemoryEstimator()
+	memInfo, err := estimator.GetModelMemoryInfo(modelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	without := estimator.CalculateMemoryForContext(memInfo, 4096, 32).TotalMemoryGB
+
+	estimator.AddLoRAAdapters(memInfo, []string{adapterPath, filepath.Join(dir, "missing.gguf")})
+	if memInfo.LoRASizeGB != 0.0625 {
+		t.Errorf("expected 64MB of adapters, got %vGB", memInfo.LoRASizeGB)
+	}
+	with := estimator.CalculateMemoryForContext(memInfo, 4096, 32).TotalMemoryGB
+	if diff := with - without; diff < 0.0624 || diff > 0.0626 {
+		t.Errorf("expected the adapter footprint added to the total, got %v more", diff)
+	}
+}
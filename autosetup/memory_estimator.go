@@ -31,6 +31,7 @@ type ModelMemoryInfo struct {
 	HasSlidingWindow  bool
 	SlidingWindowSize uint32
 	IsScout           bool
+	LoRASizeGB        float64 // attached LoRA adapters, see AddLoRAAdapters
 }
 
 // ContextMemoryResult contains the result of context memory calculation
@@ -89,6 +90,11 @@ func (me *MemoryEstimator) GetModelMemoryInfo(modelPath string) (*ModelMemoryInf
 	}, nil
 }
 
+// AddLoRAAdapters counts the LoRA adapters attached to a model in its memory
+func (me *MemoryEstimator) AddLoRAAdapters(memInfo *ModelMemoryInfo, adapterPaths []string) {
+	memInfo.LoRASizeGB += LoRAAdaptersSizeGB(adapterPaths)
+}
+
 // CalculateMemoryForContext calculates memory usage for a specific context size
 func (me *MemoryEstimator) CalculateMemoryForContext(memInfo *ModelMemoryInfo, contextSize int, blockCount uint32) *ContextMemoryResult {
 	var kvCacheBytes int64
@@ -103,7 +109,7 @@ func (me *MemoryEstimator) CalculateMemoryForContext(memInfo *ModelMemoryInfo, c
 	}
 
 	kvCacheGB := float64(kvCacheBytes) / (1024 * 1024 * 1024)
-	totalMemoryGB := memInfo.ModelSizeGB + kvCacheGB + me.OverheadGB
+	totalMemoryGB := memInfo.ModelSizeGB + memInfo.LoRASizeGB + kvCacheGB + me.OverheadGB
 
 	return &ContextMemoryResult{
 		ContextSize:     contextSize,
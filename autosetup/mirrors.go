@@ -0,0 +1,83 @@
+package autosetup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// llamaCppDownloadBase is where llama.cpp release assets are downloaded from,
+// replaceable for testing
+var llamaCppDownloadBase = "https://github.com/ggml-org/llama.cpp/releases/download"
+
+// binaryMirrors are the base URLs tried in order when a llama.cpp release asset
+// can't be downloaded from GitHub. A mirror serves the same <version>/<file>
+// layout as llamaCppDownloadBase.
+var binaryMirrors = struct {
+	sync.RWMutex
+	bases []string
+}{}
+
+// SetBinaryMirrors sets the mirror base URLs for llama.cpp binary downloads,
+// replacing the ones set before. Empty entries are ignored.
+func SetBinaryMirrors(mirrors []string) {
+	var bases []string
+	for _, mirror := range mirrors {
+		if mirror = strings.TrimRight(strings.TrimSpace(mirror), "/"); mirror != "" {
+			bases = append(bases, mirror)
+		}
+	}
+	binaryMirrors.Lock()
+	binaryMirrors.bases = bases
+	binaryMirrors.Unlock()
+}
+
+// mirrorURLs returns url followed by the same release asset on each mirror.
+// URLs that aren't llama.cpp release assets have no mirrors.
+func mirrorURLs(url string) []string {
+	path, found := strings.CutPrefix(url, llamaCppDownloadBase+"/")
+	if !found {
+		return []string{url}
+	}
+	binaryMirrors.RLock()
+	defer binaryMirrors.RUnlock()
+	urls := make([]string, 0, len(binaryMirrors.bases)+1)
+	urls = append(urls, url)
+	for _, base := range binaryMirrors.bases {
+		urls = append(urls, base+"/"+path)
+	}
+	return urls
+}
+
+// binaryAvailable reports if a release asset exists on GitHub or any mirror
+func binaryAvailable(url string) bool {
+	for _, candidate := range mirrorURLs(url) {
+		if binaryExists(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadFile downloads a file from URL to local path, trying the mirrors in
+// order when the download fails. The error joins the error of every attempt,
+// so a 404 on any of them can still be told apart.
+func downloadFile(url, filepath string) error {
+	candidates := mirrorURLs(url)
+	var errs []error
+	for i, candidate := range candidates {
+		if i > 0 {
+			fmt.Printf("🔄 Trying mirror: %s\n", candidate)
+		}
+		err := downloadFileFrom(candidate, filepath)
+		if err == nil {
+			return nil
+		}
+		if len(candidates) == 1 {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", candidate, err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package autosetup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubDownloadBase serves the llama.cpp release downloads from base and sets the mirrors
+func stubDownloadBase(t *testing.T, base string, mirrors ...string) {
+	t.Helper()
+	original := llamaCppDownloadBase
+	llamaCppDownloadBase = base
+	SetBinaryMirrors(mirrors)
+	t.Cleanup(func() {
+		llamaCppDownloadBase = original
+		SetBinaryMirrors(nil)
+	})
+}
+
+func TestDownloadFile_MirrorFailover(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+	var mirrorPath string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/llama.cpp/") {
+			http.NotFound(w, r)
+			return
+		}
+		mirrorPath = r.URL.Path
+		w.Write([]byte("llama-server zip"))
+	}))
+	defer mirror.Close()
+
+	stubDownloadBase(t, primary.URL, "", mirror.URL+"/llama.cpp/")
+	path := filepath.Join(t.TempDir(), "llama-server.zip")
+	if err := downloadFile(primary.URL+"/b6527/llama-b6527-bin-ubuntu-x64.zip", path); err != nil {
+		t.Fatalf("expected the mirror to serve the download: %v", err)
+	}
+	if mirrorPath != "/llama.cpp/b6527/llama-b6527-bin-ubuntu-x64.zip" {
+		t.Errorf("unexpected mirror path %q", mirrorPath)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "llama-server zip" {
+		t.Errorf("unexpected download content %q", data)
+	}
+
+	// with every URL failing, each attempt is reported and a 404 stays visible
+	SetBinaryMirrors([]string{mirror.URL + "/missing"})
+	err := downloadFile(primary.URL+"/b6527/llama-b6527-bin-ubuntu-x64.zip", path)
+	if err == nil || !strings.Contains(err.Error(), "500") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the errors of the primary and the mirror, got %v", err)
+	}
+}
+
+func TestGetOptimalBinaryURL_MirrorAvailable(t *testing.T) {
+	stubDownloadBase(t, "https://github.example/releases/download", "https://mirror.example/llama.cpp")
+	// only the mirror is reachable, the release check against GitHub fails
+	original := binaryExists
+	binaryExists = func(url string) bool {
+		return url == "https://mirror.example/llama.cpp/b6527/llama-b6527-bin-ubuntu-x64-vulkan.zip"
+	}
+	t.Cleanup(func() { binaryExists = original })
+
+	url, binaryType, err := GetOptimalBinaryURL(SystemInfo{OS: "linux", Architecture: "amd64", HasVulkan: true}, "", "b6527")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binaryType != "vulkan" || url != "https://github.example/releases/download/b6527/llama-b6527-bin-ubuntu-x64-vulkan.zip" {
+		t.Errorf("expected the vulkan binary found on the mirror, got %s %s", binaryType, url)
+	}
+}
@@ -0,0 +1,99 @@
+package autosetup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelOverride adds flags a model needs to load or run correctly, typically
+// --override-kv for metadata that older conversions got wrong or left out
+type ModelOverride struct {
+	Architecture string   `json:"architecture,omitempty"` // general.architecture, empty matches any
+	NameContains string   `json:"nameContains,omitempty"` // case-insensitive model name or filename substring
+	MissingKey   string   `json:"missingKey,omitempty"`   // only applies when the GGUF lacks this metadata key
+	Args         []string `json:"args"`
+	Reason       string   `json:"reason,omitempty"`
+}
+
+// KnownModelOverrides are the quirks of published GGUFs the generator fixes automatically
+var KnownModelOverrides = []ModelOverride{
+	{
+		Architecture: "gemma2",
+		MissingKey:   "gemma2.attn_logit_softcapping",
+		Args: []string{
+			"--override-kv gemma2.attn_logit_softcapping=float:50.0",
+			"--override-kv gemma2.final_logit_softcapping=float:30.0",
+		},
+		Reason: "early Gemma 2 conversions lack logit soft-capping, which degrades output",
+	},
+	{
+		Architecture: "llama",
+		NameContains: "llama-3",
+		MissingKey:   "tokenizer.ggml.pre",
+		Args:         []string{"--override-kv tokenizer.ggml.pre=str:llama3"},
+		Reason:       "early Llama 3 conversions lack the pre-tokenizer type, which degrades generation",
+	},
+	{
+		Architecture: "command-r",
+		MissingKey:   "tokenizer.ggml.pre",
+		Args:         []string{"--override-kv tokenizer.ggml.pre=str:command-r"},
+		Reason:       "early Command-R conversions lack the pre-tokenizer type",
+	},
+}
+
+// matches reports if the override applies to a model with the given architecture, name and metadata
+func (o ModelOverride) matches(architecture, name string, metadata map[string]interface{}) bool {
+	if len(o.Args) == 0 {
+		return false
+	}
+	if o.Architecture != "" && !strings.EqualFold(o.Architecture, architecture) {
+		return false
+	}
+	if o.NameContains != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(o.NameContains)) {
+		return false
+	}
+	if o.MissingKey != "" {
+		if _, exists := metadata[o.MissingKey]; exists {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchModelOverrides returns the built-in and custom overrides that apply to a model
+func MatchModelOverrides(architecture, name string, metadata map[string]interface{}, custom []ModelOverride) []ModelOverride {
+	var matched []ModelOverride
+	for _, override := range append(append([]ModelOverride(nil), KnownModelOverrides...), custom...) {
+		if override.matches(architecture, name, metadata) {
+			matched = append(matched, override)
+		}
+	}
+	return matched
+}
+
+// modelOverrideArgs returns the override flags for a model, logging each applied override
+func (scg *ConfigGenerator) modelOverrideArgs(model ModelInfo) []string {
+	metadata, err := ReadAllGGUFKeys(model.Path)
+	if err != nil {
+		return nil
+	}
+	architecture, _ := metadata["general.architecture"].(string)
+	name := model.Name + " " + model.Path
+
+	var args []string
+	seen := make(map[string]bool)
+	for _, override := range MatchModelOverrides(architecture, name, metadata, scg.Options.ModelOverrides) {
+		reason := override.Reason
+		if reason == "" {
+			reason = "custom override"
+		}
+		fmt.Printf("   🔧 %s: applying %s (%s)\n", model.Name, strings.Join(override.Args, " "), reason)
+		for _, arg := range override.Args {
+			if !seen[arg] {
+				seen[arg] = true
+				args = append(args, arg)
+			}
+		}
+	}
+	return args
+}
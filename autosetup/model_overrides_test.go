@@ -0,0 +1,41 @@
+package autosetup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchModelOverrides(t *testing.T) {
+	oldGemma := map[string]interface{}{"general.architecture": "gemma2"}
+	matched := MatchModelOverrides("gemma2", "gemma-2-9b-it", oldGemma, nil)
+	if len(matched) != 1 {
+		t.Fatalf("expected the gemma2 soft-capping override, got %v", matched)
+	}
+	want := []string{
+		"--override-kv gemma2.attn_logit_softcapping=float:50.0",
+		"--override-kv gemma2.final_logit_softcapping=float:30.0",
+	}
+	if !reflect.DeepEqual(matched[0].Args, want) {
+		t.Errorf("args = %v, want %v", matched[0].Args, want)
+	}
+
+	// fixed conversions carry the key and need no override
+	fixedGemma := map[string]interface{}{"general.architecture": "gemma2", "gemma2.attn_logit_softcapping": float32(50)}
+	if matched := MatchModelOverrides("gemma2", "gemma-2-9b-it", fixedGemma, nil); len(matched) != 0 {
+		t.Errorf("expected no override for a fixed conversion, got %v", matched)
+	}
+
+	// the Llama 3 override also needs the name to match
+	if matched := MatchModelOverrides("llama", "Mistral-7B", map[string]interface{}{}, nil); len(matched) != 0 {
+		t.Errorf("expected no override for a non Llama 3 model, got %v", matched)
+	}
+	if matched := MatchModelOverrides("llama", "Meta-Llama-3-8B-Instruct", map[string]interface{}{}, nil); len(matched) != 1 {
+		t.Errorf("expected the llama 3 pre-tokenizer override, got %v", matched)
+	}
+
+	custom := []ModelOverride{{NameContains: "CODER", Args: []string{"--override-kv tokenizer.ggml.add_bos_token=bool:false"}}}
+	matched = MatchModelOverrides("qwen2", "qwen2.5-coder-7b", map[string]interface{}{}, custom)
+	if len(matched) != 1 || matched[0].Args[0] != custom[0].Args[0] {
+		t.Errorf("expected the custom override, got %v", matched)
+	}
+}
@@ -2,6 +2,7 @@ package autosetup
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -19,6 +20,8 @@ type ProgressState struct {
 	Completed       bool      `json:"completed"`
 	StartedAt       time.Time `json:"started_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+	JobID           string    `json:"job_id,omitempty"` // config generation holding the progress, see BeginGeneration
+	JobSource       string    `json:"job_source,omitempty"`
 }
 
 type ProgressWrapper struct {
@@ -29,6 +32,20 @@ type ProgressManager struct {
 	filePath string
 	state    ProgressState
 	mutex    sync.RWMutex
+	// numbers the job IDs of BeginGeneration
+	nextJobID int
+}
+
+// GenerationInProgressError is returned by BeginGeneration while another
+// config generation is running
+type GenerationInProgressError struct {
+	JobID     string
+	Source    string
+	StartedAt time.Time
+}
+
+func (e *GenerationInProgressError) Error() string {
+	return fmt.Sprintf("generation already in progress (job %s, %s)", e.JobID, e.Source)
 }
 
 func NewProgressManager() *ProgressManager {
@@ -110,6 +127,7 @@ func (pm *ProgressManager) SetError(err string) {
 	pm.saveToFile()
 }
 
+// Reset clears the progress, a running generation keeps its claim
 func (pm *ProgressManager) Reset() {
 	pm.mutex.Lock()
 	defer pm.mutex.Unlock()
@@ -117,10 +135,50 @@ func (pm *ProgressManager) Reset() {
 	pm.state = ProgressState{
 		Status:    "idle",
 		UpdatedAt: time.Now(),
+		JobID:     pm.state.JobID,
+		JobSource: pm.state.JobSource,
 	}
 	pm.saveToFile()
 }
 
+// BeginGeneration claims the progress for a config generation so only one runs at
+// a time, they all write the config file. It returns the job ID to pass to
+// EndGeneration, or a *GenerationInProgressError while another job runs.
+func (pm *ProgressManager) BeginGeneration(source string) (string, error) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if pm.state.JobID != "" {
+		return "", &GenerationInProgressError{JobID: pm.state.JobID, Source: pm.state.JobSource, StartedAt: pm.state.StartedAt}
+	}
+
+	pm.nextJobID++
+	jobID := fmt.Sprintf("gen-%d-%d", time.Now().Unix(), pm.nextJobID)
+	pm.state = ProgressState{
+		Status:    "idle",
+		StartedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		JobID:     jobID,
+		JobSource: source,
+	}
+	pm.saveToFile()
+	return jobID, nil
+}
+
+// EndGeneration releases the claim of BeginGeneration, the progress is kept for polling
+func (pm *ProgressManager) EndGeneration(jobID string) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if pm.state.JobID != jobID {
+		return
+	}
+	pm.state.JobID = ""
+	pm.state.JobSource = ""
+	pm.state.UpdatedAt = time.Now()
+	pm.saveToFile()
+}
+
 func (pm *ProgressManager) GetState() ProgressState {
 	pm.mutex.RLock()
 	defer pm.mutex.RUnlock()
@@ -0,0 +1,62 @@
+package autosetup
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestProgressManager_OneGenerationAtATime(t *testing.T) {
+	pm := &ProgressManager{filePath: filepath.Join(t.TempDir(), "progress_state.json")}
+
+	var wg sync.WaitGroup
+	jobIDs := make([]string, 8)
+	errs := make([]error, 8)
+	for i := range jobIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jobIDs[i], errs[i] = pm.BeginGeneration("test")
+		}(i)
+	}
+	wg.Wait()
+
+	running := ""
+	for i, err := range errs {
+		if err == nil {
+			if running != "" {
+				t.Fatalf("jobs %s and %s both started", running, jobIDs[i])
+			}
+			running = jobIDs[i]
+		}
+	}
+	if running == "" {
+		t.Fatal("no generation started")
+	}
+	for _, err := range errs {
+		var inProgress *GenerationInProgressError
+		if err != nil && (!errors.As(err, &inProgress) || inProgress.JobID != running) {
+			t.Errorf("expected the running job %s in %v", running, err)
+		}
+	}
+
+	pm.Reset()
+	if pm.GetState().JobID != running {
+		t.Errorf("Reset dropped the claim of %s", running)
+	}
+
+	pm.EndGeneration("gen-other")
+	if _, err := pm.BeginGeneration("test"); err == nil {
+		t.Error("a different job ID released the running generation")
+	}
+
+	pm.EndGeneration(running)
+	next, err := pm.BeginGeneration("test")
+	if err != nil {
+		t.Fatalf("generation after the running one ended: %v", err)
+	}
+	if next == running {
+		t.Errorf("job ID %s was reused", next)
+	}
+}
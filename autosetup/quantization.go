@@ -0,0 +1,43 @@
+package autosetup
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// KnownQuantizations lists the GGUF quantization types recognized in filenames,
+// including the importance matrix (IQ), ternary (TQ) and unsloth dynamic (_XL) variants
+var KnownQuantizations = []string{
+	"F32", "F16", "BF16", "MXFP4",
+	"Q8_0", "Q8_K_XL",
+	"Q6_K", "Q6_K_L", "Q6_K_XL",
+	"Q5_0", "Q5_1", "Q5_K", "Q5_K_S", "Q5_K_M", "Q5_K_L", "Q5_K_XL",
+	"Q4_0", "Q4_1", "Q4_K", "Q4_K_S", "Q4_K_M", "Q4_K_L", "Q4_K_XL",
+	"Q4_0_4_4", "Q4_0_4_8", "Q4_0_8_8",
+	"Q3_K", "Q3_K_S", "Q3_K_M", "Q3_K_L", "Q3_K_XL",
+	"Q2_K", "Q2_K_S", "Q2_K_L", "Q2_K_XL",
+	"IQ1_S", "IQ1_M",
+	"IQ2_XXS", "IQ2_XS", "IQ2_S", "IQ2_M",
+	"IQ3_XXS", "IQ3_XS", "IQ3_S", "IQ3_M",
+	"IQ4_XS", "IQ4_NL",
+	"TQ1_0", "TQ2_0",
+}
+
+// quantizationPattern matches a known quantization not surrounded by letters or
+// digits. Alternatives are ordered longest first so Q4_K_M wins over Q4_K, and
+// the boundaries keep IQ4_XS from matching Q4 or BF16 from matching F16.
+var quantizationPattern = func() *regexp.Regexp {
+	quants := append([]string(nil), KnownQuantizations...)
+	sort.SliceStable(quants, func(i, j int) bool { return len(quants[i]) > len(quants[j]) })
+	return regexp.MustCompile(`(?:^|[^A-Z0-9])(` + strings.Join(quants, "|") + `)(?:[^A-Z0-9]|$)`)
+}()
+
+// DetectQuantization returns the quantization type in a model filename, e.g.
+// "IQ2_XXS" for "Qwen3-32B-IQ2_XXS.gguf", or "" when there is none
+func DetectQuantization(filename string) string {
+	if m := quantizationPattern.FindStringSubmatch(strings.ToUpper(filename)); m != nil {
+		return m[1]
+	}
+	return ""
+}
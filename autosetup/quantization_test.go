@@ -0,0 +1,39 @@
+package autosetup
+
+import "testing"
+
+func TestDetectQuantization(t *testing.T) {
+	tests := map[string]string{
+		"Llama-3.2-3B-Instruct-Q4_K_M.gguf":            "Q4_K_M",
+		"llama-3.2-3b-instruct-q4_k_s.gguf":            "Q4_K_S",
+		"Qwen3-32B-IQ2_XXS.gguf":                       "IQ2_XXS",
+		"Qwen3-32B-IQ2_XS.gguf":                        "IQ2_XS",
+		"Mistral-Small-24B-IQ3_M.gguf":                 "IQ3_M",
+		"gemma-3-27b-it-IQ4_NL.gguf":                   "IQ4_NL",
+		"bitnet-b1.58-2B-4T-TQ1_0.gguf":                "TQ1_0",
+		"TriLM_3.9B_Unpacked-TQ2_0.gguf":               "TQ2_0",
+		"Qwen3-30B-A3B-UD-Q4_K_XL.gguf":                "Q4_K_XL",
+		"Meta-Llama-3.1-8B-Q4_0_4_8.gguf":              "Q4_0_4_8",
+		"DeepSeek-R1-Q8_0-00001-of-00015.gguf":         "Q8_0",
+		"gpt-oss-20b-MXFP4.gguf":                       "MXFP4",
+		"Qwen2.5-0.5B-Instruct-BF16.gguf":              "BF16",
+		"phi-4-f16.gguf":                               "F16",
+		"Meta-Llama-3.1-8B-Instruct-Q4_K_M/model.gguf": "Q4_K_M",
+		"mmproj-model.gguf":                            "",
+		"Qwen2.5-Coder-14B-Instruct.gguf":              "",
+		"bf16model-Q4.gguf":                            "",
+	}
+
+	for filename, want := range tests {
+		if got := DetectQuantization(filename); got != want {
+			t.Errorf("DetectQuantization(%q) = %q, want %q", filename, got, want)
+		}
+	}
+
+	if got := detectQuantizationFromFilename("/models/Qwen3-8B-IQ3_XXS.gguf"); got != "IQ3_XXS" {
+		t.Errorf("detectQuantizationFromFilename() = %q, want IQ3_XXS", got)
+	}
+	if got := detectQuantizationFromFilename("/models/model.gguf"); got != "Unknown" {
+		t.Errorf("detectQuantizationFromFilename() = %q, want Unknown", got)
+	}
+}
@@ -45,6 +45,13 @@ func GetRealtimeHardwareInfo() (*RealtimeHardwareInfo, error) {
 	return info, nil
 }
 
+// MeasureVRAM returns the free and total VRAM in GB as nvidia-smi reports it
+// right now. Unlike GetRealtimeHardwareInfo it doesn't fall back to an
+// estimate, it fails when the VRAM can't be measured.
+func MeasureVRAM() (free, total float64, err error) {
+	return getCurrentVRAM()
+}
+
 // getCurrentVRAM gets current available and total VRAM using nvidia-smi
 func getCurrentVRAM() (available, total float64, err error) {
 	// Try nvidia-smi first
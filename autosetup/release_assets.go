@@ -0,0 +1,85 @@
+package autosetup
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// llamaCppReleasesAPI is the GitHub API for llama.cpp releases, replaceable for testing
+var llamaCppReleasesAPI = "https://api.github.com/repos/ggml-org/llama.cpp/releases"
+
+// releaseAssets caches the asset names of each release for the process lifetime,
+// so checking the backends and their fallbacks costs one GitHub API call per release
+var releaseAssets = struct {
+	sync.Mutex
+	byVersion map[string]map[string]bool
+}{byVersion: make(map[string]map[string]bool)}
+
+// cacheReleaseAssets stores the asset names of a fetched release
+func cacheReleaseAssets(release GitHubRelease) {
+	if release.TagName == "" {
+		return
+	}
+	names := make(map[string]bool, len(release.Assets))
+	for _, asset := range release.Assets {
+		names[asset.Name] = true
+	}
+	releaseAssets.Lock()
+	releaseAssets.byVersion[release.TagName] = names
+	releaseAssets.Unlock()
+}
+
+// getReleaseAssets returns the asset names of a release, fetching the release once
+func getReleaseAssets(version string) (map[string]bool, error) {
+	releaseAssets.Lock()
+	names, cached := releaseAssets.byVersion[version]
+	releaseAssets.Unlock()
+	if cached {
+		return names, nil
+	}
+
+	client := NewHTTPClient(10 * time.Second)
+	resp, err := client.Get(fmt.Sprintf("%s/tags/%s", llamaCppReleasesAPI, version))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned %d for release %s", resp.StatusCode, version)
+	}
+
+	var release GitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to parse release %s: %v", version, err)
+	}
+	if release.TagName == "" {
+		release.TagName = version
+	}
+	cacheReleaseAssets(release)
+
+	releaseAssets.Lock()
+	defer releaseAssets.Unlock()
+	return releaseAssets.byVersion[release.TagName], nil
+}
+
+// releaseAssetExists checks a release download URL against the cached asset list
+// of its release, falling back to a HEAD request when the list can't be fetched
+func releaseAssetExists(url string) bool {
+	_, path, found := strings.Cut(url, "/releases/download/")
+	version, filename, ok := strings.Cut(path, "/")
+	if !found || !ok {
+		return checkBinaryExists(url)
+	}
+
+	names, err := getReleaseAssets(version)
+	if err != nil {
+		fmt.Printf("   ⚠️  Could not list release assets (%v), checking the binary directly\n", err)
+		return checkBinaryExists(url)
+	}
+	return names[filename]
+}
@@ -0,0 +1,89 @@
+package autosetup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReleaseAssetExists_CachedList(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodGet || r.URL.Path != "/tags/b9999" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tag_name": "b9999",
+			"assets": []map[string]string{
+				{"name": "llama-b9999-bin-ubuntu-x64-vulkan.zip"},
+				{"name": "llama-b9999-bin-ubuntu-x64.zip"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	originalAPI := llamaCppReleasesAPI
+	llamaCppReleasesAPI = server.URL
+	t.Cleanup(func() {
+		llamaCppReleasesAPI = originalAPI
+		releaseAssets.Lock()
+		delete(releaseAssets.byVersion, "b9999")
+		releaseAssets.Unlock()
+	})
+
+	base := "https://github.com/ggml-org/llama.cpp/releases/download/b9999/"
+	checks := map[string]bool{
+		"llama-b9999-bin-ubuntu-x64-cuda.zip":   false,
+		"llama-b9999-bin-ubuntu-x64-vulkan.zip": true,
+		"llama-b9999-bin-ubuntu-x64.zip":        true,
+	}
+	for filename, want := range checks {
+		if got := releaseAssetExists(base + filename); got != want {
+			t.Errorf("releaseAssetExists(%s) = %v, want %v", filename, got, want)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected the release to be fetched once, got %d requests", got)
+	}
+
+	// GetOptimalBinaryURL falls back from CUDA using the same cached list
+	url, binaryType, err := GetOptimalBinaryURL(SystemInfo{OS: "linux", Architecture: "amd64", HasCUDA: true}, "", "b9999")
+	if err != nil {
+		t.Fatalf("GetOptimalBinaryURL: %v", err)
+	}
+	if binaryType != "vulkan" || url != base+"llama-b9999-bin-ubuntu-x64-vulkan.zip" {
+		t.Errorf("got %s %s, want the vulkan fallback", binaryType, url)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected no further requests, got %d", got)
+	}
+}
+
+func TestCacheReleaseAssets(t *testing.T) {
+	var release GitHubRelease
+	if err := json.Unmarshal([]byte(`{"tag_name":"b9998","assets":[{"name":"llama-b9998-bin-macos-arm64.zip"}]}`), &release); err != nil {
+		t.Fatal(err)
+	}
+	cacheReleaseAssets(release)
+	t.Cleanup(func() {
+		releaseAssets.Lock()
+		delete(releaseAssets.byVersion, "b9998")
+		releaseAssets.Unlock()
+	})
+
+	originalAPI := llamaCppReleasesAPI
+	llamaCppReleasesAPI = "http://127.0.0.1:0" // any request would fail
+	t.Cleanup(func() { llamaCppReleasesAPI = originalAPI })
+
+	names, err := getReleaseAssets("b9998")
+	if err != nil {
+		t.Fatalf("expected cached assets, got %v", err)
+	}
+	if !names["llama-b9998-bin-macos-arm64.zip"] {
+		t.Errorf("cached asset missing: %v", names)
+	}
+}
@@ -0,0 +1,103 @@
+package autosetup
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ropeScalingArgs returns the llama-server flags to run a model at contextSize.
+// Within the trained context, or when the GGUF already carries a rope scaling
+// that covers contextSize, llama-server's values from the file are right and no
+// flags are needed. Beyond it the context is extended with YaRN from the trained
+// context, keeping the model's own rope.freq_base instead of llama-server's default.
+func ropeScalingArgs(metadata *GGUFMetadata, contextSize int) []string {
+	if metadata == nil || metadata.ContextLength == 0 || contextSize <= int(metadata.ContextLength) {
+		return nil
+	}
+
+	scalingType := strings.ToLower(metadata.RopeScalingType)
+	if scalingType != "" && scalingType != "none" && metadata.RopeScalingFactor > 1 {
+		origContext := metadata.RopeScalingOrigContext
+		if origContext == 0 {
+			origContext = metadata.ContextLength
+		}
+		if float64(contextSize) <= float64(origContext)*float64(metadata.RopeScalingFactor) {
+			return nil
+		}
+	}
+
+	scale := int(math.Ceil(float64(contextSize) / float64(metadata.ContextLength)))
+	args := []string{
+		"--rope-scaling yarn",
+		fmt.Sprintf("--rope-scale %d", scale),
+		fmt.Sprintf("--yarn-orig-ctx %d", metadata.ContextLength),
+	}
+	if metadata.RopeFreqBase > 0 {
+		args = append(args, "--rope-freq-base "+strconv.FormatFloat(float64(metadata.RopeFreqBase), 'f', -1, 32))
+	}
+	return args
+}
+
+// llama-server's rope defaults for models whose GGUF doesn't say otherwise
+const (
+	defaultRopeFreqBase  = 10000
+	defaultRopeFreqScale = 1
+)
+
+// ropeFreqArgs returns --rope-freq-base and --rope-freq-scale for a model run
+// within the context its rope settings cover, when the GGUF's values differ
+// from llama-server's defaults. llama-server reads them from the file too, the
+// flags pin them in the cmd so builds or wrappers that don't still get the
+// right values, and show them in the config.
+func ropeFreqArgs(metadata *GGUFMetadata) []string {
+	if metadata == nil {
+		return nil
+	}
+	var args []string
+	if metadata.RopeFreqBase > 0 && metadata.RopeFreqBase != defaultRopeFreqBase {
+		args = append(args, "--rope-freq-base "+strconv.FormatFloat(float64(metadata.RopeFreqBase), 'f', -1, 32))
+	}
+	// linear scaling is a frequency scale of 1/factor, YaRN has its own flags
+	if strings.EqualFold(metadata.RopeScalingType, "linear") && metadata.RopeScalingFactor > 0 &&
+		1/metadata.RopeScalingFactor != defaultRopeFreqScale {
+		args = append(args, "--rope-freq-scale "+strconv.FormatFloat(1/float64(metadata.RopeScalingFactor), 'f', -1, 32))
+	}
+	return args
+}
+
+// modelRopeArgs returns the rope flags of a chat model at contextSize. A cmd
+// template that sets --rope-scaling itself is left to decide, and a template or
+// model override that sets --rope-freq-base or --rope-freq-scale overrides the
+// value from the GGUF.
+func (scg *ConfigGenerator) modelRopeArgs(model ModelInfo, contextSize int, overrideArgs []string) []string {
+	modelPath := model.Path
+	if isSplitModel(modelPath) {
+		modelPath = getFirstPartOfSplitModel(modelPath)
+	}
+	metadata, err := ReadGGUFMetadata(modelPath)
+	if err != nil {
+		return nil
+	}
+	template := scg.cmdTemplates()[strings.ToLower(metadata.Architecture)]
+	if strings.Contains(template, "--rope-scaling") {
+		return nil
+	}
+	args := ropeScalingArgs(metadata, contextSize)
+	if len(args) > 0 {
+		fmt.Printf("   🌀 Context %d exceeds the trained %d, extending with YaRN\n", contextSize, metadata.ContextLength)
+		return args
+	}
+
+	overridden := template + " " + strings.Join(overrideArgs, " ")
+	for _, arg := range ropeFreqArgs(metadata) {
+		flag, _, _ := strings.Cut(arg, " ")
+		if strings.Contains(overridden, flag) {
+			continue
+		}
+		fmt.Printf("   🌀 %s: applying %s from the GGUF rope metadata\n", model.Name, arg)
+		args = append(args, arg)
+	}
+	return args
+}
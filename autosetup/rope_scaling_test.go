@@ -0,0 +1,269 @@
+package autosetup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeRopeGGUF writes a GGUF header with the given string, uint32 and float32 metadata
+func writeRopeGGUF(t *testing.T, path string, metadata map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	// general.architecture first, the reader only looks for architecture keys after it
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] == "general.architecture" || (keys[j] != "general.architecture" && keys[i] < keys[j])
+	})
+
+	write(uint32(GGUFMagic))
+	write(uint32(3)) // version
+	write(uint64(0)) // tensor count
+	write(uint64(len(keys)))
+	for _, key := range keys {
+		writeString(key)
+		switch value := metadata[key].(type) {
+		case string:
+			write(uint32(GGUFTypeString))
+			writeString(value)
+		case uint32:
+			write(uint32(GGUFTypeUInt32))
+			write(value)
+		case float32:
+			write(uint32(GGUFTypeFloat32))
+			write(value)
+		default:
+			t.Fatalf("unsupported metadata type %T for %s", value, key)
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadGGUFMetadata_Rope(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	writeRopeGGUF(t, path, map[string]interface{}{
+		"general.architecture":                       "llama",
+		"llama.context_length":                       uint32(8192),
+		"llama.rope.freq_base":                       float32(500000),
+		"llama.rope.scaling.type":                    "yarn",
+		"llama.rope.scaling.factor":                  float32(4),
+		"llama.rope.scaling.original_context_length": uint32(2048),
+	})
+
+	metadata, err := ReadGGUFMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.ContextLength != 8192 || metadata.RopeFreqBase != 500000 || metadata.RopeScalingType != "yarn" ||
+		metadata.RopeScalingFactor != 4 || metadata.RopeScalingOrigContext != 2048 {
+		t.Errorf("unexpected rope metadata: %+v", metadata)
+	}
+}
+
+func TestRopeScalingArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    *GGUFMetadata
+		contextSize int
+		want        []string
+	}{
+		{"within trained context", &GGUFMetadata{ContextLength: 32768, RopeFreqBase: 1000000}, 32768, nil},
+		{"unknown trained context", &GGUFMetadata{RopeFreqBase: 1000000}, 65536, nil},
+		{
+			"extended with custom base",
+			&GGUFMetadata{ContextLength: 32768, RopeFreqBase: 1000000},
+			131072,
+			[]string{"--rope-scaling yarn", "--rope-scale 4", "--yarn-orig-ctx 32768", "--rope-freq-base 1000000"},
+		},
+		{
+			"extended without base in the file",
+			&GGUFMetadata{ContextLength: 4096},
+			10000,
+			[]string{"--rope-scaling yarn", "--rope-scale 3", "--yarn-orig-ctx 4096"},
+		},
+		{
+			"embedded scaling covers the context",
+			&GGUFMetadata{ContextLength: 32768, RopeScalingType: "yarn", RopeScalingFactor: 4},
+			131072,
+			nil,
+		},
+		{
+			"embedded scaling too small",
+			&GGUFMetadata{ContextLength: 8192, RopeFreqBase: 10000, RopeScalingType: "linear", RopeScalingFactor: 2, RopeScalingOrigContext: 4096},
+			16384,
+			[]string{"--rope-scaling yarn", "--rope-scale 2", "--yarn-orig-ctx 8192", "--rope-freq-base 10000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ropeScalingArgs(tt.metadata, tt.contextSize); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ropeScalingArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateConfig_CustomRopeBase(t *testing.T) {
+	dir := t.TempDir()
+	// trained on 4K with a custom base, the generator's 16K minimum context extends it
+	customPath := filepath.Join(dir, "custom-rope-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, customPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(4096),
+		"llama.block_count":    uint32(32),
+		"llama.rope.freq_base": float32(500000),
+	})
+	// the file's own scaling already covers the context
+	scaledPath := filepath.Join(dir, "scaled-rope-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, scaledPath, map[string]interface{}{
+		"general.architecture":      "llama",
+		"llama.context_length":      uint32(4096),
+		"llama.block_count":         uint32(32),
+		"llama.rope.freq_base":      float32(500000),
+		"llama.rope.scaling.type":   "yarn",
+		"llama.rope.scaling.factor": float32(8),
+	})
+	models := []ModelInfo{
+		{Name: "custom-rope-7b", Path: customPath, Size: "7B"},
+		{Name: "scaled-rope-7b", Path: scaledPath, Size: "7B"},
+	}
+
+	outputPath := filepath.Join(dir, "config.yaml")
+	scg := NewConfigGenerator(dir, "llama-server", outputPath, SetupOptions{})
+	if err := scg.GenerateConfig(models); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := string(data)
+
+	customCmd := modelCmd(t, config, customPath)
+	if !strings.Contains(customCmd, "--ctx-size 16384\n      --rope-scaling yarn\n      --rope-scale 4\n      --yarn-orig-ctx 4096\n      --rope-freq-base 500000\n") {
+		t.Errorf("expected YaRN flags matching the custom rope base:\n%s", customCmd)
+	}
+	scaledCmd := modelCmd(t, config, scaledPath)
+	if !strings.Contains(scaledCmd, "--ctx-size 16384\n      --rope-freq-base 500000\n") || strings.Contains(scaledCmd, "--rope-scaling") {
+		t.Errorf("expected the embedded rope scaling to be used as it is, with the custom base pinned:\n%s", scaledCmd)
+	}
+
+	// a template with its own rope scaling decides for the architecture
+	scg = NewConfigGenerator(dir, "llama-server", outputPath, SetupOptions{CmdTemplates: map[string]string{"llama": "--rope-scaling linear"}})
+	if err := scg.GenerateConfig(models[:1]); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customCmd := modelCmd(t, string(data), customPath); strings.Contains(customCmd, "--rope") {
+		t.Errorf("expected no per-model rope flags with a rope scaling template:\n%s", customCmd)
+	}
+}
+
+func TestRopeFreqArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata *GGUFMetadata
+		want     []string
+	}{
+		{"no rope metadata", &GGUFMetadata{ContextLength: 4096}, nil},
+		{"default base", &GGUFMetadata{RopeFreqBase: 10000}, nil},
+		{"custom base", &GGUFMetadata{RopeFreqBase: 1000000}, []string{"--rope-freq-base 1000000"}},
+		{"linear scaling", &GGUFMetadata{RopeScalingType: "linear", RopeScalingFactor: 4}, []string{"--rope-freq-scale 0.25"}},
+		{"yarn scaling", &GGUFMetadata{RopeFreqBase: 10000, RopeScalingType: "yarn", RopeScalingFactor: 4}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ropeFreqArgs(tt.metadata); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ropeFreqArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateConfig_RopeFreqCorrection(t *testing.T) {
+	dir := t.TempDir()
+	// trained on 32K with a base of 1M, run within its context
+	customPath := filepath.Join(dir, "long-rope-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, customPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(32768),
+		"llama.block_count":    uint32(32),
+		"llama.rope.freq_base": float32(1000000),
+	})
+	defaultPath := filepath.Join(dir, "plain-rope-7b-Q4_K_M.gguf")
+	writeRopeGGUF(t, defaultPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(32768),
+		"llama.block_count":    uint32(32),
+		"llama.rope.freq_base": float32(10000),
+	})
+	models := []ModelInfo{
+		{Name: "long-rope-7b", Path: customPath, Size: "7B"},
+		{Name: "plain-rope-7b", Path: defaultPath, Size: "7B"},
+	}
+
+	outputPath := filepath.Join(dir, "config.yaml")
+	generate := func(options SetupOptions) string {
+		scg := NewConfigGenerator(dir, "llama-server", outputPath, options)
+		if err := scg.GenerateConfig(models); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	config := generate(SetupOptions{})
+	if cmd := modelCmd(t, config, customPath); !strings.Contains(cmd, "      --rope-freq-base 1000000\n") || strings.Contains(cmd, "--rope-scaling") {
+		t.Errorf("expected the GGUF rope base to be applied:\n%s", cmd)
+	}
+	if cmd := modelCmd(t, config, defaultPath); strings.Contains(cmd, "--rope") {
+		t.Errorf("expected no rope flags for llama-server's default base:\n%s", cmd)
+	}
+
+	// a model override sets its own base
+	config = generate(SetupOptions{ModelOverrides: []ModelOverride{{NameContains: "long-rope", Args: []string{"--rope-freq-base 500000"}}}})
+	if cmd := modelCmd(t, config, customPath); strings.Contains(cmd, "--rope-freq-base 1000000") || !strings.Contains(cmd, "--rope-freq-base 500000") {
+		t.Errorf("expected the override's rope base only:\n%s", cmd)
+	}
+}
+
+// modelCmd returns the cmd block of the model with the given path in a generated config
+func modelCmd(t *testing.T, config, modelPath string) string {
+	t.Helper()
+	start := strings.Index(config, "      --model "+modelPath+"\n")
+	if start < 0 {
+		t.Fatalf("model %s not found in config:\n%s", modelPath, config)
+	}
+	end := strings.Index(config[start:], "    proxy:")
+	if end < 0 {
+		t.Fatalf("cmd of %s has no end:\n%s", modelPath, config)
+	}
+	return config[start : start+end]
+}
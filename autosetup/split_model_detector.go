@@ -154,6 +154,7 @@ func CombineSplitModels(splitModels []SplitModelInfo, regularModels []ModelInfo)
 			combinedModel.EmbeddingSize = firstPart.EmbeddingSize
 			combinedModel.NumLayers = firstPart.NumLayers
 			combinedModel.IsMoE = firstPart.IsMoE
+			combinedModel.Tags = firstPart.Tags
 		}
 
 		// Add size information
@@ -171,21 +172,7 @@ func CombineSplitModels(splitModels []SplitModelInfo, regularModels []ModelInfo)
 
 // extractQuantization extracts quantization type from filename
 func extractQuantization(filename string) string {
-	upper := strings.ToUpper(filename)
-	quantPatterns := []string{
-		"Q2_K", "Q3_K_S", "Q3_K_M", "Q3_K_L",
-		"Q4_0", "Q4_1", "Q4_K_S", "Q4_K_M",
-		"Q5_0", "Q5_1", "Q5_K_S", "Q5_K_M",
-		"Q6_K", "Q8_0", "F16", "F32",
-	}
-
-	for _, pattern := range quantPatterns {
-		if strings.Contains(upper, pattern) {
-			return pattern
-		}
-	}
-
-	return ""
+	return DetectQuantization(filename)
 }
 
 // parseInt safely parses a string to int
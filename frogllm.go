@@ -32,7 +32,11 @@ var (
 
 func main() {
 	// Define a command-line flag for the port
-	configPath := flag.String("config", "config.yaml", "config file name")
+	configPath := flag.String("config", "", "config file name (default config.yaml, or $FROGLLM_CONFIG)")
+	settingsPath := flag.String("settings", "", "system settings file name (default settings.json, or $FROGLLM_SETTINGS)")
+	folderDBPath := flag.String("folder-db", "", "tracked model folders file name (default model_folders.json, or $FROGLLM_FOLDER_DB)")
+	activityStatsPath := flag.String("activity-stats", "", "activity stats file name (default activity_stats.json next to the config, or $FROGLLM_ACTIVITY_STATS)")
+	downloadJournalPath := flag.String("download-journal", "", "unfinished downloads file name (default downloads_in_progress.json in the download directory, or $FROGLLM_DOWNLOAD_JOURNAL)")
 	listenStr := flag.String("listen", ":5800", "listen ip/port for FrogLLM web interface")
 	showVersion := flag.Bool("version", false, "show version of build")
 	watchConfig := flag.Bool("watch-config", true, "Automatically reload config file on change (default: true)")
@@ -50,9 +54,24 @@ func main() {
 	llamaServerPath := flag.String("llama-server-path", "", "custom path to llama-server binary - overrides auto-download")
 	llamaServer := flag.String("llama-server", "", "replace llama-server binary path in existing config and rebuild")
 	hfToken := flag.String("hf-token", "", "Hugging Face API token for downloading private models")
+	autoAliases := flag.Bool("auto-aliases", false, "generate short model aliases (e.g. llama3) for client naming conventions")
+	profile := flag.String("profile", "", "config profile to use, read from config.<profile>.yaml next to the config file")
+	binaryMirrors := flag.String("binary-mirrors", "", "comma separated mirror base URLs tried in order when downloading llama-server from GitHub fails")
+	httpProxy := flag.String("http-proxy", "", "proxy URL for requests to GitHub and HuggingFace - overrides httpProxy in the config and the HTTP_PROXY/HTTPS_PROXY environment variables")
 
 	flag.Parse() // Parse the command-line flags
 
+	// flags, then environment variables, then the default names
+	filePaths := proxy.ResolveFilePaths(proxy.FilePaths{
+		Config:          *configPath,
+		Settings:        *settingsPath,
+		FolderDatabase:  *folderDBPath,
+		ActivityStats:   *activityStatsPath,
+		DownloadJournal: *downloadJournalPath,
+	})
+	proxy.SetFilePaths(filePaths)
+	*configPath = filePaths.Config
+
 	if *showVersion {
 		fmt.Printf("version: %s (%s), built at %s\n", version, commit, date)
 		os.Exit(0)
@@ -77,23 +96,9 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Ensure config file exists; create an empty one if missing
-	if _, statErr := os.Stat(*configPath); statErr != nil {
-		if os.IsNotExist(statErr) {
-			// Create parent directory if necessary
-			if err := os.MkdirAll(filepath.Dir(*configPath), 0755); err != nil {
-				fmt.Printf("Error creating config directory: %v\n", err)
-				os.Exit(1)
-			}
-			if err := os.WriteFile(*configPath, []byte{}, 0644); err != nil {
-				fmt.Printf("Error creating empty config file: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Printf("Created empty config at %s\n", *configPath)
-		} else {
-			fmt.Printf("Error checking config file: %v\n", statErr)
-			os.Exit(1)
-		}
+	if err := autosetup.SetHTTPProxy(*httpProxy); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Handle auto-setup mode
@@ -109,6 +114,9 @@ func main() {
 			ForceVRAM:            *forceVRAM,
 			MinFreeMemoryPercent: *minFreeMemoryPercent,
 			LlamaServerPath:      *llamaServerPath,
+			AutoAliases:          *autoAliases,
+			BinaryMirrors:        strings.FieldsFunc(*binaryMirrors, func(r rune) bool { return r == ',' }),
+			ConfigPath:           *configPath,
 		})
 		if err != nil {
 			fmt.Printf("Auto-setup failed: %v\n", err)
@@ -116,18 +124,32 @@ func main() {
 		}
 		fmt.Println("✅ Auto-setup completed successfully!")
 		fmt.Println("🚀 Starting FrogLLM server with the generated configuration...")
-		fmt.Println("📁 Config watching is enabled - any changes to config.yaml will trigger automatic reloads")
+		fmt.Printf("📁 Config watching is enabled - any changes to %s will trigger automatic reloads\n", *configPath)
 		fmt.Printf("🌐 Server will be available at: http://localhost%s\n", *listenStr)
 		fmt.Printf("🎛️  Web interface: http://localhost%s/ui/\n", *listenStr)
-		fmt.Println("💡 You can now edit config.yaml manually or use the web interface - changes will auto-reload!")
+		fmt.Printf("💡 You can now edit %s manually or use the web interface - changes will auto-reload!\n", *configPath)
 		// Continue to start the server instead of exiting
 	}
 
-	config, err := proxy.LoadConfig(*configPath)
+	if err := proxy.ValidateProfile(*configPath, *profile); err != nil {
+		fmt.Printf("Error selecting config profile: %v\n", err)
+		os.Exit(1)
+	}
+	activeProfile := *profile
+	if activeProfile != "" {
+		fmt.Printf("📋 Using config profile %s (%s)\n", activeProfile, proxy.ProfileConfigPath(*configPath, activeProfile))
+	}
+
+	// a missing config is created as a default one without models
+	activeConfigPath := proxy.ProfileConfigPath(*configPath, activeProfile)
+	config, created, err := proxy.LoadOrCreateConfig(activeConfigPath)
+	if created {
+		fmt.Printf("Created default config at %s\n", activeConfigPath)
+	}
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		// Attempt auto-regeneration from DB to self-heal common config errors
-		if selfHealReconfigure(*configPath) {
+		if activeProfile == "" && selfHealReconfigure(*configPath) {
 			fmt.Println("Self-heal: regenerated configuration from tracked folders. Retrying load...")
 			config, err = proxy.LoadConfig(*configPath)
 		}
@@ -136,14 +158,26 @@ func main() {
 		}
 	}
 
+	for _, warning := range config.Warnings {
+		fmt.Printf("⚠️  Config: %s\n", warning)
+	}
+
+	if len(config.Models) == 0 {
+		fmt.Println("⚠️  No models are configured yet")
+		fmt.Printf("💡 Add models from the web interface (http://localhost%s/ui/), with POST /api/config/scan-folder or POST /api/config/generate-all, or restart with --models-folder\n", *listenStr)
+	}
+
 	// Override minFreeMemoryPercent from command line if provided
 	if *minFreeMemoryPercent > 0 {
 		config.MinFreeMemoryPercent = *minFreeMemoryPercent
 		fmt.Printf("✅ Memory threshold set to %.1f%% (overriding config)\n", *minFreeMemoryPercent)
 	}
+	if *httpProxy != "" {
+		config.HTTPProxy = *httpProxy
+	}
 
 	if len(config.Profiles) > 0 {
-		fmt.Println("WARNING: Profile functionality has been removed in favor of Groups. See the README for more information.")
+		fmt.Println("WARNING: The profiles config key has been removed in favor of Groups and is ignored. See the README for more information.")
 	}
 
 	if mode := os.Getenv("GIN_MODE"); mode != "" {
@@ -165,7 +199,9 @@ func main() {
 	// Support for watching config and reloading when it changes
 	reloadProxyManager := func() {
 		if currentPM, ok := srv.Handler.(*proxy.ProxyManager); ok {
-			config, err = proxy.LoadConfig(*configPath)
+			// keep the profile activated through the API
+			activeProfile = currentPM.ActiveProfile()
+			config, err = proxy.LoadConfig(proxy.ProfileConfigPath(*configPath, activeProfile))
 			if err != nil {
 				fmt.Printf("Warning, unable to reload configuration: %v\n", err)
 				return
@@ -175,11 +211,15 @@ func main() {
 			if *minFreeMemoryPercent > 0 {
 				config.MinFreeMemoryPercent = *minFreeMemoryPercent
 			}
+			if *httpProxy != "" {
+				config.HTTPProxy = *httpProxy
+			}
 
 			fmt.Println("📝 Configuration file changed - reloading...")
 			currentPM.Shutdown()
 			pm := proxy.New(config)
-			pm.SetConfigPath(*configPath)
+			pm.SetConfigProfile(*configPath, activeProfile)
+			pm.WatchSystemSettings()
 			srv.Handler = pm
 			fmt.Println("✅ Configuration reloaded successfully")
 
@@ -190,10 +230,10 @@ func main() {
 				})
 			})
 		} else {
-			config, err = proxy.LoadConfig(*configPath)
+			config, err = proxy.LoadConfig(proxy.ProfileConfigPath(*configPath, activeProfile))
 			if err != nil {
 				fmt.Printf("Error, unable to load configuration: %v\n", err)
-				if selfHealReconfigure(*configPath) {
+				if activeProfile == "" && selfHealReconfigure(*configPath) {
 					fmt.Println("Self-heal: regenerated configuration from tracked folders. Retrying load...")
 					config, err = proxy.LoadConfig(*configPath)
 				}
@@ -202,7 +242,8 @@ func main() {
 				}
 			}
 			pm := proxy.New(config)
-			pm.SetConfigPath(*configPath)
+			pm.SetConfigProfile(*configPath, activeProfile)
+			pm.WatchSystemSettings()
 			srv.Handler = pm
 		}
 	}
@@ -210,6 +251,11 @@ func main() {
 	// load the initial proxy manager
 	reloadProxyManager()
 	debouncedReload := debounce(time.Second, reloadProxyManager)
+
+	// an activated config profile is loaded like a changed config file
+	defer event.On(func(e proxy.ConfigProfileActivatedEvent) {
+		debouncedReload()
+	})()
 	if *watchConfig {
 		defer event.On(func(e proxy.ConfigFileChangedEvent) {
 			if e.ReloadingState == proxy.ReloadingStateStart {
@@ -241,6 +287,10 @@ func main() {
 			for {
 				select {
 				case changeEvent := <-watcher.Events:
+					// profiles are stored next to the base config
+					if currentPM, ok := srv.Handler.(*proxy.ProxyManager); ok {
+						absConfigPath, _ = filepath.Abs(proxy.ProfileConfigPath(*configPath, currentPM.ActiveProfile()))
+					}
 					if changeEvent.Name == absConfigPath && (changeEvent.Has(fsnotify.Write) || changeEvent.Has(fsnotify.Create) || changeEvent.Has(fsnotify.Remove)) {
 						event.Emit(proxy.ConfigFileChangedEvent{
 							ReloadingState: proxy.ReloadingStateStart,
@@ -309,13 +359,13 @@ func debounce(interval time.Duration, f func()) func() {
 	}
 }
 
-// selfHealReconfigure regenerates config.yaml from tracked folders using saved settings.
+// selfHealReconfigure regenerates the config from tracked folders using saved settings.
 // Returns true if regeneration succeeded.
 func selfHealReconfigure(configPath string) bool {
 	// Instantiate a temporary ProxyManager-like helper by reusing functions in proxy package via HTTP API is not available here.
 	// So we inline minimal logic: read folder DB, scan and run autosetup generator.
 	// Load folder database
-	dbPath := "model_folders.json"
+	dbPath := proxy.CurrentFilePaths().FolderDatabase
 	data, err := os.ReadFile(dbPath)
 	if err != nil {
 		fmt.Printf("Self-heal: no folder DB (%s): %v\n", dbPath, err)
@@ -343,7 +393,7 @@ func selfHealReconfigure(configPath string) bool {
 	}
 
 	// Load saved settings if present
-	settingsPath := "settings.json"
+	settingsPath := proxy.CurrentFilePaths().Settings
 	var opts autosetup.SetupOptions = autosetup.SetupOptions{
 		EnableJinja:      true,
 		ThroughputFirst:  true,
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// activityExportColumns is the header row of the CSV export
+var activityExportColumns = []string{
+	"model_id", "request_count", "prompt_tokens", "completion_tokens", "total_tokens",
+	"tokens_per_second", "first_used", "last_used", "vram_gb",
+}
+
+// ActivityExportRow is one model's activity stats in an export
+type ActivityExportRow struct {
+	ModelID          string     `json:"model_id"`
+	RequestCount     int64      `json:"request_count"`
+	PromptTokens     int64      `json:"prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens"`
+	TotalTokens      int64      `json:"total_tokens"`
+	TokensPerSecond  float64    `json:"tokens_per_second"`
+	FirstUsed        *time.Time `json:"first_used,omitempty"`
+	LastUsed         *time.Time `json:"last_used,omitempty"`
+	VRAMGB           float64    `json:"vram_gb,omitempty"` // only set while the model is loaded
+}
+
+func (r ActivityExportRow) csvRecord() []string {
+	vram := ""
+	if r.VRAMGB > 0 {
+		vram = strconv.FormatFloat(r.VRAMGB, 'f', 2, 64)
+	}
+	return []string{
+		r.ModelID,
+		strconv.FormatInt(r.RequestCount, 10),
+		strconv.FormatInt(r.PromptTokens, 10),
+		strconv.FormatInt(r.CompletionTokens, 10),
+		strconv.FormatInt(r.TotalTokens, 10),
+		strconv.FormatFloat(r.TokensPerSecond, 'f', 2, 64),
+		formatExportTime(r.FirstUsed),
+		formatExportTime(r.LastUsed),
+		vram,
+	}
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func exportTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// activityExportRows returns the per-model activity stats, sorted by model ID
+func (pm *ProxyManager) activityExportRows() []ActivityExportRow {
+	rows := []ActivityExportRow{}
+	if pm.metricsMonitor == nil || pm.metricsMonitor.ActivityStats == nil {
+		return rows
+	}
+
+	loaded := make(map[string]bool)
+	pm.Lock()
+	for _, processGroup := range pm.processGroups {
+		if processGroup == nil {
+			continue
+		}
+		for modelID, process := range processGroup.processes {
+			if process.CurrentState() == StateReady {
+				loaded[modelID] = true
+			}
+		}
+	}
+	pm.Unlock()
+
+	for modelID, stats := range pm.metricsMonitor.ActivityStats.GetStats() {
+		if modelID == "_global_" {
+			continue
+		}
+
+		row := ActivityExportRow{
+			ModelID:          modelID,
+			RequestCount:     stats.RequestCount,
+			PromptTokens:     stats.PromptTokens,
+			CompletionTokens: stats.CompletionTokens,
+			TotalTokens:      stats.TotalTokens,
+			FirstUsed:        exportTime(stats.FirstUsed),
+			LastUsed:         exportTime(stats.LastUsed),
+		}
+		if stats.TotalDurationMs > 0 {
+			row.TokensPerSecond = float64(stats.CompletionTokens) / (float64(stats.TotalDurationMs) / 1000)
+		}
+		if loaded[modelID] {
+			row.VRAMGB = pm.getModelVRAMUsage(modelID)
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ModelID < rows[j].ModelID })
+	return rows
+}
+
+// apiExportActivityStats downloads the per-model activity stats, ?format=csv (default) or json
+func (pm *ProxyManager) apiExportActivityStats(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+
+	rows := pm.activityExportRows()
+	c.Header("Content-Disposition", `attachment; filename="activity-stats.`+format+`"`)
+
+	if format == "json" {
+		c.JSON(http.StatusOK, rows)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write(activityExportColumns)
+	for _, row := range rows {
+		w.Write(row.csvRecord())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		pm.proxyLogger.Errorf("Failed to write activity stats export: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiResetActivityStats handles POST /api/activity/stats/reset. It clears the
+// activity stats of ?model=, or of all models, in memory and in the stats file.
+// Stats can't be recovered once reset, so the body has to confirm it.
+func (pm *ProxyManager) apiResetActivityStats(c *gin.Context) {
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if !req.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm must be true to reset activity stats"})
+		return
+	}
+
+	modelID := c.Query("model")
+	if pm.metricsMonitor == nil || pm.metricsMonitor.ActivityStats == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "reset", "model": modelID})
+		return
+	}
+
+	found, err := pm.metricsMonitor.ActivityStats.ResetStats(modelID)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no statistics found for model"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("stats were reset but saving them failed: %v", err)})
+		return
+	}
+
+	if modelID == "" {
+		pm.proxyLogger.Info("Reset the activity stats of all models")
+	} else {
+		pm.proxyLogger.Infof("Reset the activity stats of %s", modelID)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "reset", "model": modelID})
+}
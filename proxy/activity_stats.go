@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,14 +18,34 @@ type ActivityStats struct {
 	LastUsed         time.Time `json:"last_used"`
 	FirstUsed        time.Time `json:"first_used"`
 	TotalDurationMs  int64     `json:"total_duration_ms"`
+	// ContextUsage counts requests by prompt+completion tokens, bucketed by contextUsageBuckets
+	ContextUsage     []int64 `json:"context_usage,omitempty"`
+	MaxContextTokens int64   `json:"max_context_tokens,omitempty"`
+	// ThrottleEvents counts the times a GPU started throttling while the model generated, see gpu_thermal.go
+	ThrottleEvents int64 `json:"throttle_events,omitempty"`
+	ThrottledMs    int64 `json:"throttled_ms,omitempty"`
+}
+
+// copy returns a copy of the stats that shares no memory with them
+func (s *ActivityStats) copy() *ActivityStats {
+	statsCopy := *s
+	statsCopy.ContextUsage = append([]int64(nil), s.ContextUsage...)
+	return &statsCopy
 }
 
 // ActivityStatsManager handles persistent statistics storage
 type ActivityStatsManager struct {
-	mu         sync.RWMutex
-	stats      map[string]*ActivityStats
+	mu          sync.RWMutex
+	stats       map[string]*ActivityStats
 	globalStats *ActivityStats
-	filePath   string
+	filePath    string
+
+	// flushing, see activity_stats_flush.go
+	saveMu        sync.Mutex   // one write of the file at a time
+	pending       atomic.Int64 // changes recorded since the last save
+	flushInterval atomic.Int64 // time.Duration
+	flushRequests atomic.Int64
+	flushNow      chan struct{}
 }
 
 // NewActivityStatsManager creates a new activity stats manager
@@ -40,7 +61,9 @@ func NewActivityStatsManager(filePath string) *ActivityStatsManager {
 			ModelID:   "_global_",
 			FirstUsed: time.Now(),
 		},
+		flushNow: make(chan struct{}, 1),
 	}
+	manager.setFlushConfig(ActivityStatsConfig{})
 
 	// Load existing stats from file
 	manager.loadFromFile()
@@ -63,8 +86,8 @@ func (m *ActivityStatsManager) loadFromFile() error {
 	}
 
 	var savedData struct {
-		Stats      map[string]*ActivityStats `json:"stats"`
-		GlobalStats *ActivityStats           `json:"global_stats"`
+		Stats       map[string]*ActivityStats `json:"stats"`
+		GlobalStats *ActivityStats            `json:"global_stats"`
 	}
 
 	if err := json.Unmarshal(data, &savedData); err != nil {
@@ -81,28 +104,36 @@ func (m *ActivityStatsManager) loadFromFile() error {
 	return nil
 }
 
-// SaveToFile persists statistics to storage
+// SaveToFile persists statistics to storage. The file is replaced in one step so
+// a crash while writing leaves the previous stats intact.
 func (m *ActivityStatsManager) SaveToFile() error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
 
+	m.mu.RLock()
+	pending := m.pending.Swap(0)
 	data := struct {
-		Stats      map[string]*ActivityStats `json:"stats"`
-		GlobalStats *ActivityStats           `json:"global_stats"`
+		Stats       map[string]*ActivityStats `json:"stats"`
+		GlobalStats *ActivityStats            `json:"global_stats"`
 	}{
-		Stats:      m.stats,
+		Stats:       m.stats,
 		GlobalStats: m.globalStats,
 	}
 
 	jsonData, err := json.MarshalIndent(data, "", "  ")
+	m.mu.RUnlock()
 	if err != nil {
+		m.pending.Add(pending)
 		return err
 	}
 
-	err = os.WriteFile(m.filePath, jsonData, 0644)
+	tmpPath := m.filePath + ".tmp"
+	err = os.WriteFile(tmpPath, jsonData, 0644)
 	if err == nil {
-		// Log successful save (for debugging)
-		// fmt.Printf("[DEBUG] Activity stats saved to %s (global tokens: %d)\n", m.filePath, m.globalStats.TotalTokens)
+		err = os.Rename(tmpPath, m.filePath)
+	}
+	if err != nil {
+		m.pending.Add(pending)
 	}
 	return err
 }
@@ -131,6 +162,7 @@ func (m *ActivityStatsManager) RecordActivity(modelID string, promptTokens, comp
 	stats.RequestCount++
 	stats.LastUsed = now
 	stats.TotalDurationMs += int64(durationMs)
+	stats.recordContextUsage(totalTokens)
 
 	// Update global stats
 	m.globalStats.TotalTokens += totalTokens
@@ -140,8 +172,37 @@ func (m *ActivityStatsManager) RecordActivity(modelID string, promptTokens, comp
 	m.globalStats.LastUsed = now
 	m.globalStats.TotalDurationMs += int64(durationMs)
 
-	// Save to file (could be throttled in production)
-	go m.SaveToFile()
+	// Saved by the flusher, early once enough requests are pending
+	if m.pending.Add(1) >= m.flushRequests.Load() {
+		m.requestFlush()
+	}
+}
+
+// RecordThrottling records that a GPU throttled for duration while the models
+// generated, started is set when the throttling began with this sample
+func (m *ActivityStatsManager) RecordThrottling(modelIDs []string, started bool, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := func(stats *ActivityStats) {
+		if started {
+			stats.ThrottleEvents++
+		}
+		stats.ThrottledMs += duration.Milliseconds()
+	}
+	for _, modelID := range modelIDs {
+		stats, exists := m.stats[modelID]
+		if !exists {
+			stats = &ActivityStats{
+				ModelID:   modelID,
+				FirstUsed: time.Now(),
+			}
+			m.stats[modelID] = stats
+		}
+		record(stats)
+	}
+	record(m.globalStats)
+	m.pending.Add(1)
 }
 
 // GetStats returns a copy of all statistics
@@ -152,8 +213,7 @@ func (m *ActivityStatsManager) GetStats() map[string]*ActivityStats {
 	result := make(map[string]*ActivityStats)
 	for k, v := range m.stats {
 		// Create a copy
-		statsCopy := *v
-		result[k] = &statsCopy
+		result[k] = v.copy()
 	}
 
 	// Include global stats
@@ -174,8 +234,7 @@ func (m *ActivityStatsManager) GetModelStats(modelID string) (*ActivityStats, bo
 	}
 
 	// Return a copy
-	statsCopy := *stats
-	return &statsCopy, true
+	return stats.copy(), true
 }
 
 // GetGlobalStats returns global statistics
@@ -188,11 +247,11 @@ func (m *ActivityStatsManager) GetGlobalStats() *ActivityStats {
 	return &statsCopy
 }
 
-// ResetStats resets statistics for a model or all models
-func (m *ActivityStatsManager) ResetStats(modelID string) {
+// ResetStats resets statistics for a model or all models and saves the result.
+// Resetting a model also takes its counts out of the global statistics. It
+// reports false when the model has no statistics.
+func (m *ActivityStatsManager) ResetStats(modelID string) (bool, error) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	if modelID == "" {
 		// Reset all stats
 		m.stats = make(map[string]*ActivityStats)
@@ -201,10 +260,21 @@ func (m *ActivityStatsManager) ResetStats(modelID string) {
 			FirstUsed: time.Now(),
 		}
 	} else {
-		// Reset specific model stats
+		stats, exists := m.stats[modelID]
+		if !exists {
+			m.mu.Unlock()
+			return false, nil
+		}
+		m.globalStats.TotalTokens -= stats.TotalTokens
+		m.globalStats.PromptTokens -= stats.PromptTokens
+		m.globalStats.CompletionTokens -= stats.CompletionTokens
+		m.globalStats.RequestCount -= stats.RequestCount
+		m.globalStats.TotalDurationMs -= stats.TotalDurationMs
+		m.globalStats.ThrottleEvents -= stats.ThrottleEvents
+		m.globalStats.ThrottledMs -= stats.ThrottledMs
 		delete(m.stats, modelID)
 	}
+	m.mu.Unlock()
 
-	// Save to file
-	go m.SaveToFile()
-}
\ No newline at end of file
+	return true, m.SaveToFile()
+}
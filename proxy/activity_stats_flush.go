@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"time"
+)
+
+// ActivityStatsConfig sets how often changed activity stats are written to disk.
+// They are kept in memory between flushes, so a crash loses at most the requests
+// of one interval or flushRequests requests.
+type ActivityStatsConfig struct {
+	FlushInterval int `yaml:"flushInterval"` // seconds between flushes, default 60
+	FlushRequests int `yaml:"flushRequests"` // requests recorded before an early flush, default 50
+}
+
+func (a ActivityStatsConfig) flushInterval() time.Duration {
+	if a.FlushInterval <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(a.FlushInterval) * time.Second
+}
+
+func (a ActivityStatsConfig) flushRequests() int64 {
+	if a.FlushRequests <= 0 {
+		return 50
+	}
+	return int64(a.FlushRequests)
+}
+
+// setFlushConfig changes when the stats are flushed, a new interval takes effect
+// after the current one
+func (m *ActivityStatsManager) setFlushConfig(config ActivityStatsConfig) {
+	m.flushInterval.Store(int64(config.flushInterval()))
+	m.flushRequests.Store(config.flushRequests())
+}
+
+// requestFlush wakes the flusher without waiting for it, request handling never
+// blocks on disk writes
+func (m *ActivityStatsManager) requestFlush() {
+	select {
+	case m.flushNow <- struct{}{}:
+	default: // a flush is already pending
+	}
+}
+
+// runFlusher writes the stats to disk every flush interval and when requestFlush
+// is called, skipping flushes when nothing changed, until ctx is done
+func (m *ActivityStatsManager) runFlusher(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(m.flushInterval.Load())):
+		case <-m.flushNow:
+		}
+		if m.pending.Load() == 0 {
+			continue
+		}
+		m.SaveToFile()
+	}
+}
+
+// startFlushing configures flushing and flushes in the background until ctx is done
+func (m *ActivityStatsManager) startFlushing(ctx context.Context, config ActivityStatsConfig) {
+	m.setFlushConfig(config)
+	go m.runFlusher(ctx)
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin context key holding the *ScopedAPIKey of a request authenticated with a keyring key
+const apiKeyScopeContextKey = "apiKeyScope"
+
+// ScopedAPIKey is a keyring entry that can only access the listed models and
+// the members of the listed groups
+type ScopedAPIKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key,omitempty"`
+	Models []string `json:"models,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// allowsModel reports if the key may use realModelName. Models may be listed
+// by id or alias.
+func (k *ScopedAPIKey) allowsModel(config Config, realModelName string) bool {
+	for _, model := range k.Models {
+		if real, found := config.RealModelName(model); found && real == realModelName {
+			return true
+		}
+	}
+	for _, groupID := range k.Groups {
+		for _, member := range config.Groups[groupID].Members {
+			if member == realModelName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiKeyFromRequest reads the API key from the Authorization or X-API-Key
+// headers, or the api_key query param for EventSource and limited clients
+func apiKeyFromRequest(c *gin.Context) string {
+	key := c.GetHeader("Authorization")
+	if key == "" {
+		key = c.GetHeader("X-API-Key")
+	}
+	if key == "" {
+		key = c.Query("api_key")
+	}
+	if strings.HasPrefix(strings.ToLower(key), "bearer ") {
+		key = strings.TrimSpace(key[7:])
+	}
+	return strings.TrimSpace(key)
+}
+
+// authenticateAPIKey checks key against the global key and the keyring. The
+// returned scope is nil for the global key, which can access everything.
+func (s *SystemSettings) authenticateAPIKey(key string) (scope *ScopedAPIKey, ok bool) {
+	if key == "" {
+		return nil, false
+	}
+	if strings.TrimSpace(s.APIKey) != "" && key == s.APIKey {
+		return nil, true
+	}
+	for i := range s.APIKeys {
+		if s.APIKeys[i].Key == key {
+			return &s.APIKeys[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateAPIKeys checks that keyring entries are named, have unique keys and
+// grant access to at least one model or group
+func (s *SystemSettings) validateAPIKeys() error {
+	seen := make(map[string]bool)
+	for _, entry := range s.APIKeys {
+		if strings.TrimSpace(entry.Name) == "" {
+			return fmt.Errorf("apiKeys entries need a name")
+		}
+		if strings.TrimSpace(entry.Key) == "" {
+			return fmt.Errorf("apiKeys entry %s needs a non-empty key", entry.Name)
+		}
+		if entry.Key == s.APIKey || seen[entry.Key] {
+			return fmt.Errorf("apiKeys entry %s reuses an existing key", entry.Name)
+		}
+		if len(entry.Models) == 0 && len(entry.Groups) == 0 {
+			return fmt.Errorf("apiKeys entry %s must allow at least one model or group", entry.Name)
+		}
+		seen[entry.Key] = true
+	}
+	return nil
+}
+
+// apiKeyScope returns the scope of the request's keyring key, nil when the
+// request is unrestricted
+func apiKeyScope(c *gin.Context) *ScopedAPIKey {
+	if scope, exists := c.Get(apiKeyScopeContextKey); exists {
+		return scope.(*ScopedAPIKey)
+	}
+	return nil
+}
+
+// allowedModel reports if the request's API key may use realModelName
+func (pm *ProxyManager) allowedModel(c *gin.Context, realModelName string) bool {
+	scope := apiKeyScope(c)
+	return scope == nil || scope.allowsModel(pm.config, realModelName)
+}
+
+// modelIDAllowed reports if the request's API key may use modelID, an id or
+// alias. Keyring keys can not use models that are not configured.
+func (pm *ProxyManager) modelIDAllowed(c *gin.Context, modelID string) bool {
+	if apiKeyScope(c) == nil {
+		return true
+	}
+	realModelName, found := pm.config.RealModelName(modelID)
+	return found && pm.allowedModel(c, realModelName)
+}
+
+// requireModelAccess sends a 403 and returns false when the request's API key
+// may not use realModelName
+func (pm *ProxyManager) requireModelAccess(c *gin.Context, realModelName string) bool {
+	if pm.allowedModel(c, realModelName) {
+		return true
+	}
+	pm.sendErrorResponse(c, http.StatusForbidden, fmt.Sprintf("API key is not permitted to access model %s", realModelName))
+	return false
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errAutoDownloadCancelled is returned by autoDownloadModel when its auto-download
+// was cancelled through DELETE /api/models/auto-download/queue
+var errAutoDownloadCancelled = errors.New("auto-download cancelled")
+
+// autoDownload is a pending auto-download of a model that isn't configured,
+// started by the requests that wait for it
+type autoDownload struct {
+	Model     string    `json:"model"` // as requested, repo or repo:file
+	Repo      string    `json:"repo"`
+	QueuedAt  time.Time `json:"queuedAt"`
+	Requests  int       `json:"requests"` // requests waiting for the download
+	cancelled bool
+}
+
+// autoDownloadQueue tracks the auto-downloads in progress. Requests for a model
+// that is already being auto-downloaded share its entry.
+type autoDownloadQueue struct {
+	sync.Mutex
+	entries []*autoDownload
+}
+
+// add queues an auto-download of model, or joins the pending one
+func (q *autoDownloadQueue) add(model string) *autoDownload {
+	q.Lock()
+	defer q.Unlock()
+	for _, entry := range q.entries {
+		if entry.Model == model && !entry.cancelled {
+			entry.Requests++
+			return entry
+		}
+	}
+	repo, _, _ := strings.Cut(model, ":")
+	entry := &autoDownload{Model: model, Repo: repo, QueuedAt: time.Now(), Requests: 1}
+	q.entries = append(q.entries, entry)
+	return entry
+}
+
+// finish removes a request from entry, and the entry once no request waits for
+// it. It reports if the entry was cancelled.
+func (q *autoDownloadQueue) finish(entry *autoDownload) bool {
+	q.Lock()
+	defer q.Unlock()
+	entry.Requests--
+	if entry.Requests <= 0 {
+		for i, e := range q.entries {
+			if e == entry {
+				q.entries = append(q.entries[:i], q.entries[i+1:]...)
+				break
+			}
+		}
+	}
+	return entry.cancelled
+}
+
+func (q *autoDownloadQueue) isCancelled(entry *autoDownload) bool {
+	q.Lock()
+	defer q.Unlock()
+	return entry.cancelled
+}
+
+// repoCancelled reports if the auto-download of a model from repo was cancelled
+// while requests still wait for it
+func (q *autoDownloadQueue) repoCancelled(repo string) bool {
+	q.Lock()
+	defer q.Unlock()
+	for _, entry := range q.entries {
+		if entry.Repo == repo && entry.cancelled {
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns copies of the auto-downloads that weren't cancelled, oldest first
+func (q *autoDownloadQueue) pending() []autoDownload {
+	q.Lock()
+	defer q.Unlock()
+	pending := make([]autoDownload, 0, len(q.entries))
+	for _, entry := range q.entries {
+		if !entry.cancelled {
+			pending = append(pending, *entry)
+		}
+	}
+	return pending
+}
+
+// cancel marks the pending auto-downloads of model as cancelled, all of them
+// when model is empty, and returns them
+func (q *autoDownloadQueue) cancel(model string) []autoDownload {
+	q.Lock()
+	defer q.Unlock()
+	var cancelled []autoDownload
+	for _, entry := range q.entries {
+		if entry.cancelled || (model != "" && entry.Model != model) {
+			continue
+		}
+		entry.cancelled = true
+		cancelled = append(cancelled, *entry)
+	}
+	return cancelled
+}
+
+// repoDownloads returns the unfinished downloads of a repo, by filename
+func (pm *ProxyManager) repoDownloads(repo string) []*DownloadInfo {
+	var downloads []*DownloadInfo
+	for _, info := range pm.downloadManager.GetDownloads() {
+		if info.ModelID != repo {
+			continue
+		}
+		switch info.Status {
+		case StatusPending, StatusDownloading, StatusPaused, StatusVerified:
+			downloads = append(downloads, info)
+		}
+	}
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].Filename < downloads[j].Filename })
+	return downloads
+}
+
+// apiGetAutoDownloadQueue handles GET /api/models/auto-download/queue, the
+// auto-downloads inference requests for unconfigured models are waiting on
+func (pm *ProxyManager) apiGetAutoDownloadQueue(c *gin.Context) {
+	pending := pm.autoDownloads.pending()
+	queue := make([]gin.H, 0, len(pending))
+	for _, entry := range pending {
+		downloads := pm.repoDownloads(entry.Repo)
+		if downloads == nil {
+			downloads = []*DownloadInfo{}
+		}
+		queue = append(queue, gin.H{
+			"model":     entry.Model,
+			"repo":      entry.Repo,
+			"queuedAt":  entry.QueuedAt,
+			"requests":  entry.Requests,
+			"downloads": downloads,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"queue": queue, "count": len(queue)})
+}
+
+// apiClearAutoDownloadQueue handles DELETE /api/models/auto-download/queue. It
+// cancels the pending auto-downloads, or only the one of ?model=, and their
+// downloads. The waiting requests fail.
+func (pm *ProxyManager) apiClearAutoDownloadQueue(c *gin.Context) {
+	model := strings.TrimSpace(c.Query("model"))
+	cancelled := pm.autoDownloads.cancel(model)
+	if model != "" && len(cancelled) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no pending auto-download of " + model})
+		return
+	}
+
+	models := make([]string, 0, len(cancelled))
+	downloadIDs := []string{}
+	for _, entry := range cancelled {
+		models = append(models, entry.Model)
+		for _, info := range pm.repoDownloads(entry.Repo) {
+			if err := pm.downloadManager.CancelDownload(info.ID); err != nil {
+				pm.proxyLogger.Warnf("Failed to cancel download %s of %s: %v", info.ID, entry.Model, err)
+				continue
+			}
+			downloadIDs = append(downloadIDs, info.ID)
+		}
+		pm.proxyLogger.Infof("Cancelled the auto-download of %s, %d requests were waiting for it", entry.Model, entry.Requests)
+	}
+	c.JSON(http.StatusOK, gin.H{"cancelled": models, "cancelledDownloads": downloadIDs})
+}
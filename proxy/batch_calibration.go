@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// batchCandidate is a --batch-size/--ubatch-size combination tried by calibration
+type batchCandidate struct {
+	BatchSize  int `json:"batchSize"`
+	UBatchSize int `json:"ubatchSize"`
+}
+
+// defaultBatchCandidates are tried when a calibration request names none
+var defaultBatchCandidates = []batchCandidate{
+	{BatchSize: 512, UBatchSize: 512},
+	{BatchSize: 1024, UBatchSize: 512},
+	{BatchSize: 2048, UBatchSize: 512},
+	{BatchSize: 2048, UBatchSize: 1024},
+}
+
+// calibrationPrompt is long enough for the batch sizes to matter for prompt processing
+var calibrationPrompt = strings.Repeat("The quick brown fox jumps over the lazy dog. ", 150)
+
+type batchCalibrationResult struct {
+	batchCandidate
+	TokensPerSecond float64 `json:"tokensPerSecond"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// calibrateBatchSizes runs benchmark for each candidate and returns every result
+// and the fastest candidate that ran, nil when none did. A candidate that does
+// not fit in memory fails to start and is skipped.
+func calibrateBatchSizes(candidates []batchCandidate, benchmark func(batchCandidate) (float64, error)) ([]batchCalibrationResult, *batchCalibrationResult) {
+	results := make([]batchCalibrationResult, 0, len(candidates))
+	var best *batchCalibrationResult
+	for _, candidate := range candidates {
+		result := batchCalibrationResult{batchCandidate: candidate}
+		if tokensPerSecond, err := benchmark(candidate); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.TokensPerSecond = tokensPerSecond
+		}
+		results = append(results, result)
+	}
+	for i := range results {
+		if results[i].Error == "" && (best == nil || results[i].TokensPerSecond > best.TokensPerSecond) {
+			best = &results[i]
+		}
+	}
+	return results, best
+}
+
+// setCmdFlag sets flag to value in a cmd, replacing the flag or one of its
+// aliases when present and appending it otherwise, on its own line for
+// multi-line cmds
+func setCmdFlag(cmd string, value int, flag string, aliases ...string) string {
+	names := make([]string, 0, len(aliases)+1)
+	for _, name := range append([]string{flag}, aliases...) {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	pattern := regexp.MustCompile(`(^|\s)(?:` + strings.Join(names, "|") + `)(?:=|\s+)\S+`)
+	replacement := fmt.Sprintf("%s %d", flag, value)
+	if pattern.MatchString(cmd) {
+		return pattern.ReplaceAllString(cmd, "${1}"+replacement)
+	}
+
+	trimmed := strings.TrimRight(cmd, "\n")
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) == 1 {
+		return trimmed + " " + replacement + cmd[len(trimmed):]
+	}
+	last := lines[len(lines)-1]
+	indent := last[:len(last)-len(strings.TrimLeft(last, " \t"))]
+	return trimmed + "\n" + indent + replacement + cmd[len(trimmed):]
+}
+
+func withBatchSizes(cmd string, candidate batchCandidate) string {
+	cmd = setCmdFlag(cmd, candidate.BatchSize, "--batch-size", "-b")
+	return setCmdFlag(cmd, candidate.UBatchSize, "--ubatch-size", "-ub")
+}
+
+// benchmarkBatchSize loads the model with the candidate's batch sizes, processes
+// calibrationPrompt and returns the prompt processing speed in tokens per second.
+// The model loads through its process group like for a request, so exclusive
+// groups and the members sharing its port are unloaded first.
+func (pm *ProxyManager) benchmarkBatchSize(modelID string, candidate batchCandidate) (float64, error) {
+	process := pm.modelProcess(modelID)
+	if process == nil {
+		return 0, fmt.Errorf("model %s not found", modelID)
+	}
+	cmd := withBatchSizes(process.config.Cmd, candidate)
+	process.Stop()
+	process.setNextLaunchCmd(cmd)
+	defer process.Stop()
+
+	processGroup, _, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		process.setNextLaunchCmd("")
+		return 0, fmt.Errorf("model did not start: %v", err)
+	}
+	loadReq, _ := http.NewRequest("GET", "/", nil)
+	processGroup.ProxyRequest(modelID, &DiscardWriter{}, loadReq)
+	if state := process.CurrentState(); state != StateReady {
+		process.setNextLaunchCmd("")
+		return 0, fmt.Errorf("model did not start, state: %s", state)
+	}
+	if runningCmd, _ := process.runningCmd(); runningCmd != cmd {
+		return 0, fmt.Errorf("model was restarted without the batch sizes by another request")
+	}
+
+	body, _ := json.Marshal(gin.H{"prompt": calibrationPrompt, "n_predict": 1, "cache_prompt": false})
+	startTime := time.Now()
+	resp, err := http.Post(strings.TrimSuffix(process.config.Proxy, "/")+"/completion", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("upstream returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	// prefer llama-server's own timing, it leaves out the HTTP round trip
+	if promptPerSecond := gjson.GetBytes(data, "timings.prompt_per_second").Float(); promptPerSecond > 0 {
+		return promptPerSecond, nil
+	}
+	tokens := gjson.GetBytes(data, "tokens_evaluated").Float()
+	if tokens <= 0 {
+		return 0, fmt.Errorf("response has no timings")
+	}
+	return tokens / time.Since(startTime).Seconds(), nil
+}
+
+// persistBatchSizes writes the batch sizes into the model's cmd in the config
+// file and reloads the config
+func (pm *ProxyManager) persistBatchSizes(modelID string, candidate batchCandidate) error {
+	return pm.updateModelFieldsInConfig(modelID, func(model *yaml.Node) error {
+		var raw struct {
+			Cmd string `yaml:"cmd"`
+		}
+		if err := model.Decode(&raw); err != nil {
+			return err
+		}
+		setMappingField(model, "cmd", cmdNode(withBatchSizes(raw.Cmd, candidate)))
+		return nil
+	})
+}
+
+// apiCalibrateModel handles POST /api/models/:id/calibrate. With "calibrate": true
+// it loads the model once per batch size candidate, keeps the fastest that fits
+// and writes it into the config. Without it the candidates are only listed, as
+// calibration stops the model and takes a while.
+func (pm *ProxyManager) apiCalibrateModel(c *gin.Context) {
+	var req struct {
+		Calibrate  bool             `json:"calibrate"`
+		Candidates []batchCandidate `json:"candidates"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	modelConfig, _, _ := pm.config.FindConfig(modelID)
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+	if modelConfig.IsRemote() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch sizes of remote model " + modelID + " are set on its server"})
+		return
+	}
+
+	candidates := req.Candidates
+	if len(candidates) == 0 {
+		candidates = defaultBatchCandidates
+	}
+	for _, candidate := range candidates {
+		if candidate.BatchSize <= 0 || candidate.UBatchSize <= 0 || candidate.UBatchSize > candidate.BatchSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "candidates need 0 < ubatchSize <= batchSize, got " +
+				strconv.Itoa(candidate.BatchSize) + "/" + strconv.Itoa(candidate.UBatchSize)})
+			return
+		}
+	}
+
+	if !req.Calibrate {
+		c.JSON(http.StatusOK, gin.H{
+			"model":      modelID,
+			"calibrate":  false,
+			"candidates": candidates,
+			"message":    "set calibrate to true to run the benchmark, it stops the model and loads it once per candidate",
+		})
+		return
+	}
+
+	pm.proxyLogger.Infof("Calibrating batch sizes of %s with %d candidates", modelID, len(candidates))
+	results, best := calibrateBatchSizes(candidates, func(candidate batchCandidate) (float64, error) {
+		return pm.benchmarkBatchSize(modelID, candidate)
+	})
+	if best == nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "no batch size candidate could be benchmarked", "results": results})
+		return
+	}
+
+	if err := pm.persistBatchSizes(modelID, best.batchCandidate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calibration: " + err.Error(), "results": results})
+		return
+	}
+	pm.proxyLogger.Infof("Calibrated %s: --batch-size %d --ubatch-size %d at %.1f tokens/s",
+		modelID, best.BatchSize, best.UBatchSize, best.TokensPerSecond)
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":     modelID,
+		"calibrate": true,
+		"results":   results,
+		"best":      best,
+	})
+}
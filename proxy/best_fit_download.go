@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// bestFitCandidate is a quantization of a repo with its estimated memory at the target context
+type bestFitCandidate struct {
+	Quantization string   `json:"quantization"`
+	Files        []string `json:"files"`
+	IsSplit      bool     `json:"isSplit"`
+	SizeBytes    int64    `json:"sizeBytes"`
+	WeightsGB    float64  `json:"weightsGB"`
+	KVCacheGB    float64  `json:"kvCacheGB"`
+	TotalGB      float64  `json:"totalGB"`
+	Fits         bool     `json:"fits"`
+}
+
+// bestFitCandidates groups the GGUF files of a repo by quantization, summing the
+// shards of split models. Vision projectors and files without a size are left out.
+func bestFitCandidates(files []HuggingFaceFile) []*bestFitCandidate {
+	byQuant := make(map[string]*bestFitCandidate)
+	var candidates []*bestFitCandidate
+	for _, file := range files {
+		label := quantizationLabel(file.Filename)
+		if label == "mmproj" || label == "unknown" || file.Size == 0 {
+			continue
+		}
+		candidate, found := byQuant[label]
+		if !found {
+			candidate = &bestFitCandidate{Quantization: label}
+			byQuant[label] = candidate
+			candidates = append(candidates, candidate)
+		}
+		// only split models need more than one file
+		if len(candidate.Files) > 0 && !(candidate.IsSplit && file.IsSplit) {
+			continue
+		}
+		candidate.Files = append(candidate.Files, file.Filename)
+		candidate.IsSplit = file.IsSplit
+		candidate.SizeBytes += file.Size
+	}
+	for _, candidate := range candidates {
+		// the metadata is in the first shard
+		sort.Strings(candidate.Files)
+	}
+	return candidates
+}
+
+// estimateBestFit sizes each candidate at contextSize and returns the largest one
+// that fits in vramGB, the highest quality the GPU can hold, or nil when none fits.
+// With a preference the most preferred one that fits is returned instead.
+// Without metadata the KV cache is left out of the estimate.
+func estimateBestFit(candidates []*bestFitCandidate, metadata *autosetup.GGUFMetadata, contextSize int, vramGB float64, preference quantPreference) *bestFitCandidate {
+	estimator := autosetup.NewMemoryEstimator()
+	var best *bestFitCandidate
+	for _, candidate := range candidates {
+		memInfo := &autosetup.ModelMemoryInfo{ModelSizeGB: float64(candidate.SizeBytes) / (1024 * 1024 * 1024)}
+		var blockCount uint32
+		if metadata != nil {
+			memInfo.BytesPerToken = int64(metadata.HeadCountKV) * int64(metadata.KeyLength+metadata.ValueLength) * 2
+			memInfo.HasSlidingWindow = metadata.SlidingWindow > 0
+			memInfo.SlidingWindowSize = metadata.SlidingWindow
+			blockCount = metadata.BlockCount
+		}
+		memory := estimator.CalculateMemoryForContext(memInfo, contextSize, blockCount)
+
+		candidate.WeightsGB = math.Round(memInfo.ModelSizeGB*100) / 100
+		candidate.KVCacheGB = math.Round(memory.KVCacheGB*100) / 100
+		candidate.TotalGB = math.Round(memory.TotalMemoryGB*100) / 100
+		candidate.Fits = memory.TotalMemoryGB <= vramGB
+		if !candidate.Fits {
+			continue
+		}
+		// without a preference every rank is the same
+		if best == nil {
+			best = candidate
+			continue
+		}
+		rank, bestRank := preference.rank(candidate.Quantization), preference.rank(best.Quantization)
+		if rank < bestRank || (rank == bestRank && candidate.SizeBytes > best.SizeBytes) {
+			best = candidate
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].SizeBytes < candidates[j].SizeBytes })
+	return best
+}
+
+// readRemoteGGUFMetadata reads the metadata at the head of a GGUF file of a
+// HuggingFace repo without downloading the rest of it
+func (pm *ProxyManager) readRemoteGGUFMetadata(repo, filename, hfToken string) (*autosetup.GGUFMetadata, error) {
+	return autosetup.ReadGGUFMetadataFromURL(fmt.Sprintf("%s/%s/resolve/main/%s", pm.huggingFaceURL, repo, filename), hfToken)
+}
+
+// apiDownloadBestFit handles POST /api/models/download-best-fit. It picks the
+// highest quality quantization of a repo that fits the VRAM at the target
+// context and starts downloading it, unless dryRun is set.
+func (pm *ProxyManager) apiDownloadBestFit(c *gin.Context) {
+	var req struct {
+		Repo            string  `json:"repo"`
+		ContextSize     int     `json:"contextSize"`
+		VRAMGB          float64 `json:"vramGB"`
+		DestinationPath string  `json:"destinationPath,omitempty"`
+		AutoLoad        bool    `json:"autoLoad,omitempty"`
+		DryRun          bool    `json:"dryRun,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	req.Repo = strings.TrimSpace(req.Repo)
+	if strings.Count(req.Repo, "/") != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repo must be in owner/name format"})
+		return
+	}
+	if req.ContextSize < 0 || req.VRAMGB < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contextSize and vramGB must not be negative"})
+		return
+	}
+	if req.ContextSize == 0 {
+		req.ContextSize = 32768
+	}
+
+	vramGB, vramSource := req.VRAMGB, "request"
+	if vramGB == 0 {
+		detected, err := autosetup.NewMemoryEstimator().GetAvailableVRAM()
+		if err != nil || detected <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vramGB is required, VRAM could not be detected"})
+			return
+		}
+		vramGB, vramSource = detected, "detected"
+	}
+
+	hfToken := c.GetHeader("HF-Token")
+	if hfToken == "" {
+		hfToken = c.GetHeader("X-HF-Token")
+	}
+	if hfToken == "" {
+		if settings := pm.getSystemSettings(); settings != nil {
+			hfToken = settings.HuggingFaceApiKey
+		}
+	}
+
+	result, err := pm.searchHuggingFaceModel(req.Repo, hfToken, 0)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	candidates := bestFitCandidates(result.GGUFFiles)
+	if len(candidates) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no quantized GGUF files with known sizes in %s", req.Repo)})
+		return
+	}
+
+	reasoning := []string{fmt.Sprintf("%.1f GB VRAM (%s), %d context", vramGB, vramSource, req.ContextSize)}
+
+	// the architecture, and so the KV cache size, is the same for every quantization
+	smallest := candidates[0]
+	for _, candidate := range candidates {
+		if candidate.SizeBytes < smallest.SizeBytes {
+			smallest = candidate
+		}
+	}
+	metadata, err := pm.readRemoteGGUFMetadata(req.Repo, smallest.Files[0], hfToken)
+	if err != nil {
+		pm.proxyLogger.Warnf("Failed to read GGUF metadata of %s/%s: %v", req.Repo, smallest.Files[0], err)
+		reasoning = append(reasoning, fmt.Sprintf("KV cache not estimated, the GGUF metadata could not be read: %v", err))
+		metadata = nil
+	}
+
+	preference := pm.configuredQuantPreference()
+	best := estimateBestFit(candidates, metadata, req.ContextSize, vramGB, preference)
+	if metadata != nil {
+		reasoning = append(reasoning, fmt.Sprintf("KV cache at %d context: %.2f GB, from the GGUF metadata of %s", req.ContextSize, candidates[0].KVCacheGB, smallest.Files[0]))
+	}
+	for _, candidate := range candidates {
+		if !candidate.Fits {
+			reasoning = append(reasoning, fmt.Sprintf("%s needs %.2f GB, more than the %.1f GB available", candidate.Quantization, candidate.TotalGB, vramGB))
+		}
+	}
+	if best == nil {
+		reasoning = append(reasoning, "no quantization fits, lower contextSize or pick a smaller model")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      fmt.Sprintf("no quantization of %s fits in %.1f GB VRAM at %d context", req.Repo, vramGB, req.ContextSize),
+			"candidates": candidates,
+			"reasoning":  reasoning,
+		})
+		return
+	}
+	if preference != nil {
+		reasoning = append(reasoning, fmt.Sprintf("%s needs %.2f GB and is the most preferred quantization that fits, by the quantizationPreference setting", best.Quantization, best.TotalGB))
+	} else {
+		reasoning = append(reasoning, fmt.Sprintf("%s needs %.2f GB and is the largest quantization that fits", best.Quantization, best.TotalGB))
+	}
+
+	response := gin.H{
+		"repo":         req.Repo,
+		"quantization": best.Quantization,
+		"files":        best.Files,
+		"chosen":       best,
+		"candidates":   candidates,
+		"reasoning":    reasoning,
+		"vramGB":       vramGB,
+		"contextSize":  req.ContextSize,
+		"dryRun":       req.DryRun,
+	}
+	if req.DryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	options := DownloadOptions{AutoLoad: req.AutoLoad}
+	if best.IsSplit {
+		downloadIDs, err := pm.downloadManager.StartMultiPartDownloadWithOptions(req.Repo, best.Quantization, best.Files, hfToken, req.DestinationPath, options)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response["downloadIds"] = downloadIDs
+	} else {
+		url := fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s", req.Repo, best.Files[0])
+		downloadID, err := pm.downloadManager.StartDownloadWithOptions(req.Repo, best.Files[0], url, hfToken, req.DestinationPath, options)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response["downloadIds"] = []string{downloadID}
+	}
+	pm.proxyLogger.Infof("Downloading %s %s, best fit for %.1f GB VRAM at %d context", req.Repo, best.Quantization, vramGB, req.ContextSize)
+	c.JSON(http.StatusOK, response)
+}
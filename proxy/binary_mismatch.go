@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// managedBinaryDir is where FrogLLM installs llama-server and its metadata
+var managedBinaryDir = filepath.Join("binaries", "llama-server")
+
+// managedServerPath returns the llama-server executable in managedBinaryDir
+func managedServerPath() string {
+	path := filepath.Join(managedBinaryDir, "build", "bin", "llama-server")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	return path
+}
+
+// loadInstalledBinary reads the metadata of the installed llama-server,
+// replaceable for testing
+var loadInstalledBinary = func() (*autosetup.BinaryMetadata, error) {
+	return autosetup.LoadBinaryMetadata(managedBinaryDir)
+}
+
+// gpuLayerFlags are the llama-server flags that offload layers to the GPU
+var gpuLayerFlags = map[string]bool{"-ngl": true, "--n-gpu-layers": true, "--gpu-layers": true}
+
+// gpuOffloadFlag returns the flag of args that offloads layers to the GPU, "" when
+// the model runs on the CPU
+func gpuOffloadFlag(args []string) string {
+	for i, arg := range args {
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !gpuLayerFlags[flag] {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				continue
+			}
+			value = args[i+1]
+		}
+		if layers, err := strconv.Atoi(value); (err == nil && layers > 0) || value == "all" {
+			return flag + " " + value
+		}
+	}
+	return ""
+}
+
+// usesManagedBinary reports if the executable of a cmd is the installed
+// llama-server. A bare name is looked up in PATH and may be any build, so it
+// doesn't count.
+func usesManagedBinary(executable string, binary *autosetup.BinaryMetadata) bool {
+	if !strings.ContainsAny(executable, `/\`) {
+		return false
+	}
+	executableAbs, err := filepath.Abs(executable)
+	if err != nil {
+		return false
+	}
+	if binary.Path != "" {
+		if pathAbs, err := filepath.Abs(binary.Path); err == nil && pathAbs == executableAbs {
+			return true
+		}
+	}
+	dirAbs, err := filepath.Abs(managedBinaryDir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dirAbs, executableAbs)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// binaryMismatchWarnings warns about models that ask for GPU offload but run
+// with an installed CPU-only llama-server, where the offload silently does
+// nothing and generation is a lot slower than expected
+func binaryMismatchWarnings(binary *autosetup.BinaryMetadata, config Config) []string {
+	if binary == nil || !strings.EqualFold(binary.Type, "cpu") {
+		return nil
+	}
+
+	modelIDs := make([]string, 0, len(config.Models))
+	for modelID := range config.Models {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	var warnings []string
+	for _, modelID := range modelIDs {
+		args, err := SanitizeCommand(config.Models[modelID].Cmd)
+		if err != nil || len(args) == 0 || !usesManagedBinary(args[0], binary) {
+			continue
+		}
+		if flag := gpuOffloadFlag(args); flag != "" {
+			warnings = append(warnings, fmt.Sprintf("model %s: cmd offloads layers to the GPU (%s) but the installed llama-server %s is a CPU build, "+
+				"the model will run on the CPU. Install the GPU build for this machine with POST /api/binary/optimize", modelID, flag, binary.Version))
+		}
+	}
+	return warnings
+}
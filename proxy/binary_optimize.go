@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+	"github.com/prave/FrogLLM/event"
+)
+
+// detectBinarySystem detects the hardware a llama-server build is picked for,
+// replaceable for testing like the functions below
+var detectBinarySystem = func() (autosetup.SystemInfo, error) {
+	system := autosetup.DetectSystem()
+	err := autosetup.EnhanceSystemInfo(&system)
+	return system, err
+}
+
+var (
+	latestBinaryVersion = autosetup.GetLatestReleaseVersion
+	optimalBinaryType   = autosetup.OptimalBinaryType
+	replaceBinary       = autosetup.ReplaceBinary
+)
+
+// apiOptimizeBinary handles POST /api/binary/optimize. When the installed
+// llama-server isn't the build that suits the hardware best, e.g. a CPU build
+// forced on a CUDA machine, it stops all models and replaces it with that build.
+// A failed download puts the installed build back.
+func (pm *ProxyManager) apiOptimizeBinary(c *gin.Context) {
+	system, err := detectBinarySystem()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to detect system: %v", err)})
+		return
+	}
+
+	version, err := latestBinaryVersion()
+	if err != nil {
+		version = autosetup.LLAMA_CPP_CURRENT_VERSION
+	}
+	optimalType, err := optimalBinaryType(system, version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to pick a binary for this system: %v", err)})
+		return
+	}
+
+	currentType := "none"
+	if metadata, err := loadInstalledBinary(); err == nil {
+		currentType = metadata.Type
+	}
+	if currentType == optimalType {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "optimal",
+			"message": fmt.Sprintf("The installed %s binary is already the best fit for this system", optimalType),
+			"type":    optimalType,
+		})
+		return
+	}
+
+	pm.proxyLogger.Infof("Replacing the %s llama-server binary with the %s build for this system", currentType, optimalType)
+	pm.StopProcesses(StopWaitForInflightRequest)
+
+	binary, err := replaceBinary("binaries", system, optimalType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":        fmt.Sprintf("Failed to download the %s binary: %v", optimalType, err),
+			"previousType": currentType,
+		})
+		return
+	}
+
+	pm.proxyLogger.Infof("Successfully replaced binary with version %s (%s)", binary.Version, binary.Type)
+	event.Emit(BinaryUpdatedEvent{Version: binary.Version, BinaryType: binary.Type})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "optimized",
+		"message":      fmt.Sprintf("Replaced the %s binary with the %s build", currentType, binary.Type),
+		"previousType": currentType,
+		"type":         binary.Type,
+		"version":      binary.Version,
+		"path":         binary.Path,
+	})
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+var chatTemplateFlagPattern = regexp.MustCompile(`(^|\s)--chat-template(-file)?(=|\s)`)
+
+// checkChatTemplateFile makes sure a chat template file exists and can be read
+func checkChatTemplateFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("chatTemplateFile %s: %v", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("chatTemplateFile %s: %v", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("chatTemplateFile %s is a directory", path)
+	}
+	return nil
+}
+
+// withChatTemplateFile adds --chat-template-file to a cmd after checking the file.
+// A cmd that sets its own template conflicts with chatTemplateFile.
+func withChatTemplateFile(cmd, path string) (string, error) {
+	if err := checkChatTemplateFile(path); err != nil {
+		return "", err
+	}
+	if chatTemplateFlagPattern.MatchString(StripComments(cmd)) {
+		return "", fmt.Errorf("cmd sets --chat-template or --chat-template-file, remove it to use chatTemplateFile")
+	}
+	if strings.ContainsAny(path, " \t'\"") {
+		path = `"` + strings.ReplaceAll(path, `"`, `\"`) + `"`
+	}
+	return strings.TrimRight(cmd, "\n") + "\n--chat-template-file " + path, nil
+}
+
+// apiSetModelChatTemplate handles POST /api/models/:id/chat-template. It saves the
+// model's chatTemplateFile, an empty path removes it, and reloads the config so
+// the model restarts with the template.
+func (pm *ProxyManager) apiSetModelChatTemplate(c *gin.Context) {
+	var req struct {
+		ChatTemplateFile *string `json:"chatTemplateFile"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.ChatTemplateFile == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chatTemplateFile is required"})
+		return
+	}
+	path := strings.TrimSpace(*req.ChatTemplateFile)
+	if path != "" {
+		if err := checkChatTemplateFile(path); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path}
+	err := pm.updateModelFieldsInConfig(modelID, func(model *yaml.Node) error {
+		setMappingField(model, "chatTemplateFile", value)
+		return nil
+	})
+	if err != nil {
+		c.JSON(configEditStatus(err), gin.H{"error": "Failed to update chat template: " + err.Error()})
+		return
+	}
+
+	pm.proxyLogger.Infof("Set model %s chatTemplateFile=%q", modelID, path)
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":            modelID,
+		"chatTemplateFile": path,
+	})
+}
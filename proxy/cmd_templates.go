@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateCmdTemplates checks the custom architecture templates. Their flags are
+// written into a macro after the base flags, so they must stay under the macro
+// length limit and leave --model and --port to the generator.
+func (s *SystemSettings) validateCmdTemplates() error {
+	for architecture, flags := range s.CmdTemplates {
+		if strings.TrimSpace(architecture) == "" {
+			return fmt.Errorf("cmdTemplates needs an architecture for each template")
+		}
+		if len(flags) > 512 {
+			return fmt.Errorf("cmdTemplates[%s] exceeds 512 characters", architecture)
+		}
+		for _, field := range strings.Fields(flags) {
+			switch field {
+			case "--model", "-m", "--port":
+				return fmt.Errorf("cmdTemplates[%s] can not set %s", architecture, field)
+			}
+		}
+	}
+	return nil
+}
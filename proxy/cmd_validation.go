@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cmdValidationResult is the outcome of POST /api/models/validate-cmd
+type cmdValidationResult struct {
+	Valid           bool     `json:"valid"`
+	Errors          []string `json:"errors"`
+	Warnings        []string `json:"warnings"`
+	Binary          string   `json:"binary"`
+	BinaryFound     bool     `json:"binaryFound"`
+	ModelPath       string   `json:"modelPath,omitempty"`
+	ModelFound      bool     `json:"modelFound"`
+	HasPort         bool     `json:"hasPort"`
+	FlagsChecked    bool     `json:"flagsChecked"` // false when the binary's --help could not be read
+	RecognizedFlags []string `json:"recognizedFlags"`
+	UnknownFlags    []string `json:"unknownFlags"`
+	Normalized      string   `json:"normalized"`
+}
+
+// helpFlagPattern matches the flags listed at the start of a --help line, e.g. "-m,    --model FNAME"
+var helpFlagPattern = regexp.MustCompile(`^\s*(-{1,2}[A-Za-z0-9][\w.-]*(?:\s*,\s*-{1,2}[A-Za-z0-9][\w.-]*)*)`)
+
+// parseHelpFlags returns the flags a binary lists in its --help output
+func parseHelpFlags(help string) map[string]bool {
+	flags := make(map[string]bool)
+	for _, line := range strings.Split(help, "\n") {
+		m := helpFlagPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, flag := range strings.Split(m[1], ",") {
+			flags[strings.TrimSpace(flag)] = true
+		}
+	}
+	return flags
+}
+
+// binaryHelpFlags caches the parsed --help of each binary by path and modification time
+var binaryHelpFlags = struct {
+	sync.Mutex
+	byBinary map[string]map[string]bool
+}{byBinary: make(map[string]map[string]bool)}
+
+func helpFlagsOf(binary string) (map[string]bool, error) {
+	key := binary
+	if info, err := os.Stat(binary); err == nil {
+		key += "@" + info.ModTime().String()
+	}
+	binaryHelpFlags.Lock()
+	flags, found := binaryHelpFlags.byBinary[key]
+	binaryHelpFlags.Unlock()
+	if found {
+		return flags, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// llama-server exits non-zero after --help in some versions, the output is all that counts
+	output, _ := exec.CommandContext(ctx, binary, "--help").CombinedOutput()
+	flags = parseHelpFlags(string(output))
+	if len(flags) == 0 {
+		return nil, fmt.Errorf("%s --help listed no flags", binary)
+	}
+
+	binaryHelpFlags.Lock()
+	binaryHelpFlags.byBinary[key] = flags
+	binaryHelpFlags.Unlock()
+	return flags, nil
+}
+
+// isFlag tells flags apart from values, so negative numbers such as "-ngl -1" stay values
+func isFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") || arg == "-" {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+// normalizeCmdArgs writes a command with the binary on the first line and each flag
+// with its values on its own line, quoting arguments that need it
+func normalizeCmdArgs(args []string) string {
+	var b strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			if isFlag(arg) {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t'\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		b.WriteString(arg)
+	}
+	return b.String()
+}
+
+// validateCmd checks a cmd after macro expansion, ${PORT} and ${MODEL_ID} are left as is
+func validateCmd(cmd string) (cmdValidationResult, error) {
+	result := cmdValidationResult{Errors: []string{}, Warnings: []string{}, RecognizedFlags: []string{}, UnknownFlags: []string{}}
+
+	args, err := SanitizeCommand(StripComments(cmd))
+	if err != nil {
+		return result, err
+	}
+	result.Normalized = normalizeCmdArgs(args)
+
+	result.Binary = args[0]
+	if _, err := exec.LookPath(result.Binary); err == nil {
+		result.BinaryFound = true
+	} else {
+		result.Errors = append(result.Errors, fmt.Sprintf("binary %s not found", result.Binary))
+	}
+
+	result.HasPort = strings.Contains(cmd, "${PORT}")
+	if !result.HasPort {
+		result.Errors = append(result.Errors, "cmd does not use ${PORT}, the proxy could not reach the process")
+	}
+
+	remoteModel := false
+	for i, arg := range args[1:] {
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !cmdModelFlags[flag] {
+			continue
+		}
+		if !hasValue && i+2 < len(args) {
+			value = args[i+2]
+		}
+		if flag == "-hf" || flag == "--hf-repo" {
+			remoteModel = true
+		} else if value != "" {
+			result.ModelPath = value
+		}
+	}
+	switch {
+	case result.ModelPath != "" && strings.Contains(result.ModelPath, "${MODEL_ID}"):
+		result.Warnings = append(result.Warnings, "model path uses ${MODEL_ID}, it is not checked")
+	case result.ModelPath != "":
+		if info, err := os.Stat(result.ModelPath); err == nil && !info.IsDir() {
+			result.ModelFound = true
+		} else {
+			result.Errors = append(result.Errors, fmt.Sprintf("model file %s not found", result.ModelPath))
+		}
+	case remoteModel:
+		result.Warnings = append(result.Warnings, "model is downloaded with -hf, it is not checked")
+	default:
+		result.Errors = append(result.Errors, "cmd has no --model/-m argument")
+	}
+
+	if result.BinaryFound {
+		if known, err := helpFlagsOf(result.Binary); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("flags not checked: %v", err))
+		} else {
+			result.FlagsChecked = true
+			seen := make(map[string]bool)
+			for _, arg := range args[1:] {
+				flag, _, _ := strings.Cut(arg, "=")
+				if !isFlag(flag) || seen[flag] {
+					continue
+				}
+				seen[flag] = true
+				if known[flag] {
+					result.RecognizedFlags = append(result.RecognizedFlags, flag)
+				} else {
+					result.UnknownFlags = append(result.UnknownFlags, flag)
+				}
+			}
+			sort.Strings(result.UnknownFlags)
+			if len(result.UnknownFlags) > 0 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s does not list %s in --help", result.Binary, strings.Join(result.UnknownFlags, ", ")))
+			}
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// apiValidateCmd handles POST /api/models/validate-cmd, checking a pasted cmd before
+// it is saved. Macros of the current config are expanded first.
+func (pm *ProxyManager) apiValidateCmd(c *gin.Context) {
+	var req struct {
+		Cmd string `json:"cmd"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Cmd) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cmd is required"})
+		return
+	}
+
+	cmd := req.Cmd
+	for macroName, macroValue := range pm.config.Macros {
+		cmd = strings.ReplaceAll(cmd, "${"+macroName+"}", macroValue)
+	}
+	for _, match := range regexp.MustCompile(`\$\{([a-zA-Z0-9_-]+)\}`).FindAllStringSubmatch(cmd, -1) {
+		if match[1] != "PORT" && match[1] != "MODEL_ID" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown macro '${%s}'", match[1])})
+			return
+		}
+	}
+
+	result, err := validateCmd(cmd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
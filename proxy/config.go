@@ -3,12 +3,15 @@ package proxy
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/billziss-gh/golib/shlex"
 	"gopkg.in/yaml.v3"
@@ -16,11 +19,23 @@ import (
 
 const DEFAULT_GROUP_ID = "(default)"
 
+const (
+	SystemPromptModePrepend  = "prepend"
+	SystemPromptModeOverride = "override"
+)
+
+// how startup handles downloads interrupted by a crash or restart
+const (
+	StaleDownloadsResume = "resume"
+	StaleDownloadsFail   = "fail"
+)
+
 type ModelConfig struct {
 	Cmd           string   `yaml:"cmd"`
 	CmdStop       string   `yaml:"cmdStop"`
 	Proxy         string   `yaml:"proxy"`
 	Aliases       []string `yaml:"aliases"`
+	AliasPatterns []string `yaml:"aliasPatterns"`
 	Env           []string `yaml:"env"`
 	CheckEndpoint string   `yaml:"checkEndpoint"`
 	UnloadAfter   int      `yaml:"ttl"`
@@ -36,23 +51,71 @@ type ModelConfig struct {
 
 	// Model filters see issue #174
 	Filters ModelFilters `yaml:"filters"`
+
+	// System prompt enforced on every chat request, regardless of client input.
+	// forceSystemPromptMode is either "prepend" (default) or "override"
+	ForceSystemPrompt     string `yaml:"forceSystemPrompt"`
+	ForceSystemPromptMode string `yaml:"forceSystemPromptMode"`
+
+	// Adaptive ttl for recently busy models
+	KeepWarm KeepWarmConfig `yaml:"keepWarm"`
+
+	// Retry transient upstream errors
+	Retry RetryConfig `yaml:"retry"`
+
+	// Seconds the process has to write output or open its port before start
+	// fails, defaults to the global processStartTimeout
+	ProcessStartTimeout int `yaml:"processStartTimeout"`
+
+	// Seconds the process has to exit after SIGTERM, or cmdStop, before it is
+	// killed, defaults to the global stopGracePeriod
+	StopGracePeriod int `yaml:"stopGracePeriod"`
+
+	// Seconds between health checks of the running process, defaults to the global
+	// healthCheckInterval. 0 turns them off. Requests to an unhealthy process fail fast.
+	HealthCheckInterval int `yaml:"healthCheckInterval"`
+
+	// Models that take the requests while this one fails its health checks, the
+	// first one that is healthy is used
+	FallbackModels []string `yaml:"fallbackModels"`
+
+	// Jinja chat template passed to llama-server as --chat-template-file, for
+	// models with a broken or missing embedded template
+	ChatTemplateFile string `yaml:"chatTemplateFile"`
+
+	// LoRA adapters passed to llama-server as --lora or --lora-scaled, see lora_adapters.go
+	LoRAAdapters []LoRAAdapterConfig `yaml:"loraAdapters"`
+
+	// CPU priority of the process from -20 (highest) to 19 (lowest), unset uses the
+	// global niceness. See process_priority_*.go for the platform differences.
+	Niceness *int `yaml:"niceness"`
+
+	// Order of hooks.on_startup.preload, higher priorities load first and equal
+	// ones keep their order in the list
+	PreloadPriority int `yaml:"preloadPriority"`
+
+	// keep this model warm when hooks.on_startup.preload lists models that would
+	// stop it, they are skipped instead, see resolvePreloadConflicts
+	PreloadPreferred bool `yaml:"preloadPreferred"`
 }
 
 func (m *ModelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawModelConfig ModelConfig
 	defaults := rawModelConfig{
-		Cmd:              "",
-		CmdStop:          "",
-		Proxy:            "http://localhost:${PORT}",
-		Aliases:          []string{},
-		Env:              []string{},
-		CheckEndpoint:    "/health",
-		UnloadAfter:      0,
-		Unlisted:         false,
-		UseModelName:     "",
-		ConcurrencyLimit: 0,
-		Name:             "",
-		Description:      "",
+		Cmd:                   "",
+		CmdStop:               "",
+		Proxy:                 "http://localhost:${PORT}",
+		Aliases:               []string{},
+		Env:                   []string{},
+		CheckEndpoint:         "/health",
+		UnloadAfter:           0,
+		Unlisted:              false,
+		UseModelName:          "",
+		ConcurrencyLimit:      0,
+		Name:                  "",
+		Description:           "",
+		ForceSystemPrompt:     "",
+		ForceSystemPromptMode: SystemPromptModePrepend,
 	}
 
 	// the default cmdStop to taskkill /f /t /pid ${PID}
@@ -72,6 +135,13 @@ func (m *ModelConfig) SanitizedCommand() ([]string, error) {
 	return SanitizeCommand(m.Cmd)
 }
 
+// IsRemote is true for a model without a cmd, its proxy is a server that runs
+// elsewhere, e.g. llama-server on another machine. No process is spawned for it,
+// it is only health checked before requests are routed to it.
+func (m *ModelConfig) IsRemote() bool {
+	return strings.TrimSpace(m.Cmd) == ""
+}
+
 // ModelFilters see issue #174
 type ModelFilters struct {
 	StripParams string `yaml:"strip_params"`
@@ -112,11 +182,103 @@ func (f ModelFilters) SanitizedStripParams() ([]string, error) {
 	return cleaned, nil
 }
 
+// KeepWarmConfig extends a model's ttl based on its recent request rate so busy
+// models are not unloaded and slowly reloaded between bursts of traffic.
+// A model with BusyRequests or more requests in the last Window seconds gets
+// the full ExtraTTL, quieter models get a proportional share.
+type KeepWarmConfig struct {
+	ExtraTTL     int `yaml:"extraTTL"`     // max seconds added to ttl, 0 disables
+	Window       int `yaml:"window"`       // seconds of request history considered, default 600
+	BusyRequests int `yaml:"busyRequests"` // requests within window for the full ExtraTTL, default 10
+}
+
+func (k KeepWarmConfig) Enabled() bool {
+	return k.ExtraTTL > 0
+}
+
+func (k KeepWarmConfig) WindowDuration() time.Duration {
+	if k.Window <= 0 {
+		return 600 * time.Second
+	}
+	return time.Duration(k.Window) * time.Second
+}
+
+// EffectiveTTL returns ttl extended by the keep warm policy for the number of
+// requests seen in the window
+func (k KeepWarmConfig) EffectiveTTL(ttl time.Duration, recentRequests int) time.Duration {
+	if !k.Enabled() || recentRequests <= 0 {
+		return ttl
+	}
+
+	busyRequests := k.BusyRequests
+	if busyRequests <= 0 {
+		busyRequests = 10
+	}
+
+	share := float64(recentRequests) / float64(busyRequests)
+	if share > 1 {
+		share = 1
+	}
+
+	return ttl + time.Duration(share*float64(k.ExtraTTL)*float64(time.Second))
+}
+
+// RetryConfig retries requests the upstream answered with a transient status
+// code, e.g. 503 while all llama-server slots are busy. A retry only happens
+// before any part of the response has been sent to the client.
+type RetryConfig struct {
+	MaxAttempts int   `yaml:"maxAttempts"` // total attempts including the first, 0 or 1 disables
+	StatusCodes []int `yaml:"statusCodes"` // upstream status codes to retry, default [503]
+	Backoff     int   `yaml:"backoff"`     // milliseconds before the first retry, doubled on each retry, default 500
+}
+
+func (r RetryConfig) Enabled() bool {
+	return r.MaxAttempts > 1
+}
+
+// ShouldRetry reports if a response with statusCode received on the given
+// attempt (starting at 1) should be retried
+func (r RetryConfig) ShouldRetry(attempt int, statusCode int) bool {
+	if attempt >= r.MaxAttempts {
+		return false
+	}
+
+	statusCodes := r.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = []int{http.StatusServiceUnavailable}
+	}
+	for _, code := range statusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffDuration returns the delay before the retry following attempt, capped at 30s
+func (r RetryConfig) BackoffDuration(attempt int) time.Duration {
+	backoff := time.Duration(r.Backoff) * time.Millisecond
+	if r.Backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	for i := 1; i < attempt && backoff < 30*time.Second; i++ {
+		backoff *= 2
+	}
+	if backoff > 30*time.Second {
+		backoff = 30 * time.Second
+	}
+	return backoff
+}
+
 type GroupConfig struct {
 	Swap       bool     `yaml:"swap"`
 	Exclusive  bool     `yaml:"exclusive"`
 	Persistent bool     `yaml:"persistent"`
 	Members    []string `yaml:"members"`
+
+	// members run one at a time on a single shared port, see shared_process.go
+	SharedProcess bool `yaml:"sharedProcess"`
 }
 
 // set default values for GroupConfig
@@ -137,16 +299,59 @@ func (c *GroupConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// GPUHealthConfig polls the GPUs for error states such as uncorrectable ECC
+// errors that make every model load fail until the GPU is reset
+type GPUHealthConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	Interval     int  `yaml:"interval"`     // seconds between polls, default 60
+	PauseLoading bool `yaml:"pauseLoading"` // refuse to load models while a GPU is unhealthy
+}
+
+func (g GPUHealthConfig) IntervalDuration() time.Duration {
+	if g.Interval <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(g.Interval) * time.Second
+}
+
+// GPUThermalConfig samples the GPUs while models generate to detect thermal
+// throttling, which slows generation without any error
+type GPUThermalConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Interval int  `yaml:"interval"` // seconds between samples, default 5
+}
+
+func (g GPUThermalConfig) IntervalDuration() time.Duration {
+	if g.Interval <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(g.Interval) * time.Second
+}
+
+// LogHistoryConfig caps the log history each log stream keeps in memory for new
+// UI connections, the oldest output is dropped first
+type LogHistoryConfig struct {
+	MaxBytes int `yaml:"maxBytes"` // default 1MB
+	MaxLines int `yaml:"maxLines"` // 0 is no line limit
+}
+
 type HooksConfig struct {
 	OnStartup HookOnStartup `yaml:"on_startup"`
 }
 
 type HookOnStartup struct {
 	Preload []string `yaml:"preload"`
+	// load models of the same preloadPriority together when they fit in memory
+	// and their groups allow it, see preload.go
+	Parallel bool `yaml:"parallel"`
 }
 
 type Config struct {
 	HealthCheckTimeout   int                    `yaml:"healthCheckTimeout"`
+	ProcessStartTimeout  int                    `yaml:"processStartTimeout"`
+	StopGracePeriod      int                    `yaml:"stopGracePeriod"`
+	HealthCheckInterval  int                    `yaml:"healthCheckInterval"`
+	Niceness             int                    `yaml:"niceness"`
 	LogRequests          bool                   `yaml:"logRequests"`
 	LogLevel             string                 `yaml:"logLevel"`
 	MetricsMaxInMemory   int                    `yaml:"metricsMaxInMemory"`
@@ -161,6 +366,9 @@ type Config struct {
 	// map aliases to actual model IDs
 	Aliases map[string]string
 
+	// glob alias patterns, most specific first
+	AliasPatterns []AliasPattern `yaml:"-"`
+
 	// automatic port assignments
 	StartPort int `yaml:"startPort"`
 
@@ -169,6 +377,76 @@ type Config struct {
 
 	// download management
 	DownloadDir string `yaml:"downloadDir"`
+
+	// what happens on startup to downloads a crash or restart interrupted,
+	// StaleDownloadsResume (default) or StaleDownloadsFail, see download_journal.go
+	StaleDownloads string `yaml:"staleDownloads"`
+
+	// mirror base URLs tried in order when a download fails, see download_mirrors.go
+	BinaryMirrors []string `yaml:"binaryMirrors"`
+	HFMirrors     []string `yaml:"hfMirrors"`
+
+	// proxy URL for requests to GitHub, HuggingFace and webhooks, empty uses the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, see autosetup/http_proxy.go
+	HTTPProxy string `yaml:"httpProxy"`
+
+	// combined speed cap of all model downloads in MB/s, 0 is unlimited, see download_throttle.go
+	MaxDownloadMBps float64 `yaml:"maxDownloadMBps"`
+
+	// parts of a multi-part download fetched at once, default 3, see download_sets.go
+	MultiPartConcurrency int `yaml:"multiPartConcurrency"`
+
+	// folder archived model files are moved to, see model_archive.go
+	ArchiveDir string `yaml:"archiveDir"`
+
+	// reject /api requests that change anything, inference keeps working, see read_only.go
+	ReadOnlyMode bool `yaml:"readOnlyMode"`
+
+	// derive short aliases (e.g. "llama3") from model IDs, opt-in
+	AutoAliases bool `yaml:"autoAliases"`
+
+	// POST a JSON payload to these URLs when their events fire, see webhooks.go
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+
+	// poll nvidia-smi for GPU error states, see gpu_health.go
+	GPUHealth GPUHealthConfig `yaml:"gpuHealth"`
+
+	// sample GPU temperature and clocks while models generate, see gpu_thermal.go
+	GPUThermal GPUThermalConfig `yaml:"gpuThermal"`
+
+	// seconds to wait after unloading models for a load until their VRAM is free
+	// again, 0 disables the check, see vram_reclaim.go
+	VRAMReclaimTimeout int `yaml:"vramReclaimTimeout"`
+
+	// GB of VRAM a load must leave free, unloading other models when needed,
+	// 0 disables, see min_free_vram.go
+	MinFreeVRAMGB float64 `yaml:"minFreeVRAMGB"`
+
+	// what a load does when the KV cache of all --parallel slots would not fit
+	// in free VRAM: warn (default), reduce or off, see slot_memory.go
+	SlotMemoryCheck string `yaml:"slotMemoryCheck"`
+
+	// seconds a loaded model stays before a swap may unload it for another model,
+	// 0 disables, see swap_cooldown.go
+	SwapCooldown int `yaml:"swapCooldown"`
+
+	// in memory log history limits, see logMonitor.go
+	LogHistory LogHistoryConfig `yaml:"logHistory"`
+
+	// soft and hard limits on the number of models, see model_limits.go
+	ModelLimits ModelLimitsConfig `yaml:"modelLimits"`
+
+	// per model upstream log files on disk, see model_logs.go
+	ModelLogs ModelLogsConfig `yaml:"modelLogs"`
+
+	// how often activity stats are written to disk, see activity_stats_flush.go
+	ActivityStats ActivityStatsConfig `yaml:"activityStats"`
+
+	// keep the most used models from the activity stats warm, see predictive_preload.go
+	PredictivePreload PredictivePreloadConfig `yaml:"predictivePreload"`
+
+	// problems found while loading that do not stop the config from working
+	Warnings []string `yaml:"-"`
 }
 
 func (c *Config) RealModelName(search string) (string, bool) {
@@ -176,9 +454,98 @@ func (c *Config) RealModelName(search string) (string, bool) {
 		return search, true
 	} else if name, found := c.Aliases[search]; found {
 		return name, found
-	} else {
-		return "", false
 	}
+
+	for _, pattern := range c.AliasPatterns {
+		if pattern.matcher != nil && pattern.matcher.MatchString(search) {
+			return pattern.ModelID, true
+		}
+	}
+	return "", false
+}
+
+// AliasPattern routes model names matching a glob pattern, e.g. "llama3-*", to ModelID.
+// Unlike path.Match a * also matches /, so "meta-llama/*" matches owner/name model names.
+type AliasPattern struct {
+	Pattern string
+	ModelID string
+
+	matcher *regexp.Regexp
+}
+
+// compileAliasPattern translates a glob pattern into an anchored regexp. * matches
+// any characters, ? a single character and [...] a character class, [!...] negated.
+func compileAliasPattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("trailing escape")
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// buildAliasPatterns collects the aliasPatterns of all models into
+// config.AliasPatterns. Patterns with more literal characters are more
+// specific and matched first, ties are ordered by pattern and model ID so
+// the precedence does not depend on map iteration order.
+func buildAliasPatterns(config *Config) error {
+	config.AliasPatterns = nil
+	seen := make(map[string]string)
+	for modelID, modelConfig := range config.Models {
+		for _, pattern := range modelConfig.AliasPatterns {
+			matcher, err := compileAliasPattern(pattern)
+			if err != nil {
+				return fmt.Errorf("model %s: invalid alias pattern %s: %v", modelID, pattern, err)
+			}
+			if other, found := seen[pattern]; found {
+				return fmt.Errorf("duplicate alias pattern %s found in models: %s, %s", pattern, other, modelID)
+			}
+			seen[pattern] = modelID
+			config.AliasPatterns = append(config.AliasPatterns, AliasPattern{Pattern: pattern, ModelID: modelID, matcher: matcher})
+		}
+	}
+
+	sort.Slice(config.AliasPatterns, func(i, j int) bool {
+		a, b := config.AliasPatterns[i], config.AliasPatterns[j]
+		if la, lb := aliasPatternLiterals(a.Pattern), aliasPatternLiterals(b.Pattern); la != lb {
+			return la > lb
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		return a.ModelID < b.ModelID
+	})
+	return nil
+}
+
+// aliasPatternLiterals counts the characters of a glob pattern that are not wildcards
+func aliasPatternLiterals(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
 }
 
 func (c *Config) FindConfig(modelName string) (ModelConfig, string, bool) {
@@ -189,13 +556,75 @@ func (c *Config) FindConfig(modelName string) (ModelConfig, string, bool) {
 	}
 }
 
+// DefaultMacros returns the llama-server macros FrogLLM uses in the configs it writes
+func DefaultMacros() map[string]string {
+	binaryPath := managedServerPath()
+
+	return map[string]string{
+		"llama-embed-base":  fmt.Sprintf("%s --host 127.0.0.1 --port ${PORT} --embedding", binaryPath),
+		"llama-server-base": fmt.Sprintf("%s --host 127.0.0.1 --port ${PORT} --metrics --flash-attn auto --no-warmup --dry-penalty-last-n 0 --batch-size 2048 --ubatch-size 512", binaryPath),
+	}
+}
+
+// WriteDefaultConfig writes a minimal valid config without any models to path
+func WriteDefaultConfig(configPath string) error {
+	defaultConfig := struct {
+		HealthCheckTimeout int                    `yaml:"healthCheckTimeout"`
+		LogLevel           string                 `yaml:"logLevel"`
+		StartPort          int                    `yaml:"startPort"`
+		Macros             map[string]string      `yaml:"macros"`
+		Models             map[string]ModelConfig `yaml:"models"`
+	}{
+		HealthCheckTimeout: 300,
+		LogLevel:           "info",
+		StartPort:          8100,
+		Macros:             DefaultMacros(),
+		Models:             map[string]ModelConfig{},
+	}
+
+	data, err := yaml.Marshal(defaultConfig)
+	if err != nil {
+		return err
+	}
+	header := "# Default FrogLLM configuration, no models are configured yet.\n" +
+		"# Add models with POST /api/config/scan-folder or POST /api/config/generate-all, or from the web interface.\n"
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, append([]byte(header), data...), 0644)
+}
+
+// LoadOrCreateConfig loads the config at configPath, writing a default config
+// first when the file does not exist. created reports if the default was written.
+func LoadOrCreateConfig(configPath string) (config Config, created bool, err error) {
+	if _, statErr := os.Stat(configPath); os.IsNotExist(statErr) {
+		if err := WriteDefaultConfig(configPath); err != nil {
+			return Config{}, false, fmt.Errorf("failed to create default config: %v", err)
+		}
+		created = true
+	}
+
+	config, err = LoadConfig(configPath)
+	return config, created, err
+}
+
 func LoadConfig(path string) (Config, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return Config{}, err
 	}
 	defer file.Close()
-	return LoadConfigFromReader(file)
+	config, err := LoadConfigFromReader(file)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// the installed llama-server is on disk like the config, see binary_mismatch.go
+	if binary, err := loadInstalledBinary(); err == nil {
+		config.Warnings = append(config.Warnings, binaryMismatchWarnings(binary, config)...)
+	}
+	return config, nil
 }
 
 func LoadConfigFromReader(r io.Reader) (Config, error) {
@@ -206,10 +635,14 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 
 	// default configuration values
 	config := Config{
-		HealthCheckTimeout: 120,
-		StartPort:          8100,
-		LogLevel:           "info",
-		MetricsMaxInMemory: 1000,
+		HealthCheckTimeout:  120,
+		ProcessStartTimeout: defaultProcessStartTimeout,
+		StopGracePeriod:     defaultStopGracePeriod,
+		StartPort:           8100,
+		LogLevel:            "info",
+		MetricsMaxInMemory:  1000,
+		VRAMReclaimTimeout:  defaultVRAMReclaimTimeout,
+		ModelLimits:         ModelLimitsConfig{Warn: defaultModelWarnLimit, Max: defaultModelMaxLimit},
 	}
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
@@ -221,10 +654,72 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 		config.HealthCheckTimeout = 15
 	}
 
+	if config.ProcessStartTimeout < 1 {
+		config.ProcessStartTimeout = defaultProcessStartTimeout
+	}
+
+	if config.StopGracePeriod < 1 {
+		config.StopGracePeriod = defaultStopGracePeriod
+	}
+
+	if config.HealthCheckInterval < 0 {
+		return Config{}, fmt.Errorf("healthCheckInterval must not be negative")
+	}
+
+	if config.SwapCooldown < 0 {
+		return Config{}, fmt.Errorf("swapCooldown must not be negative")
+	}
+
+	switch config.SlotMemoryCheck {
+	case "":
+		config.SlotMemoryCheck = SlotMemoryCheckWarn
+	case SlotMemoryCheckWarn, SlotMemoryCheckReduce, SlotMemoryCheckOff:
+	default:
+		return Config{}, fmt.Errorf("invalid slotMemoryCheck '%s', must be %s, %s or %s", config.SlotMemoryCheck, SlotMemoryCheckWarn, SlotMemoryCheckReduce, SlotMemoryCheckOff)
+	}
+
+	if config.LogHistory.MaxBytes < 0 || config.LogHistory.MaxLines < 0 {
+		return Config{}, fmt.Errorf("logHistory limits must not be negative")
+	}
+
+	if config.ModelLimits.Warn < 0 || config.ModelLimits.Max < 0 {
+		return Config{}, fmt.Errorf("modelLimits must not be negative")
+	}
+
+	if config.ModelLogs.MaxSizeMB < 0 || config.ModelLogs.MaxFiles < 0 {
+		return Config{}, fmt.Errorf("modelLogs.maxSizeMB and modelLogs.maxFiles must not be negative")
+	}
+
+	if config.MultiPartConcurrency < 0 {
+		return Config{}, fmt.Errorf("multiPartConcurrency must not be negative")
+	}
+
+	if config.ActivityStats.FlushInterval < 0 || config.ActivityStats.FlushRequests < 0 {
+		return Config{}, fmt.Errorf("activityStats.flushInterval and activityStats.flushRequests must not be negative")
+	}
+
 	if config.StartPort < 1 {
 		return Config{}, fmt.Errorf("startPort must be greater than 1")
 	}
 
+	if config.Niceness < minNiceness || config.Niceness > maxNiceness {
+		return Config{}, fmt.Errorf("niceness must be between %d and %d", minNiceness, maxNiceness)
+	}
+
+	switch config.StaleDownloads {
+	case "":
+		config.StaleDownloads = StaleDownloadsResume
+	case StaleDownloadsResume, StaleDownloadsFail:
+	default:
+		return Config{}, fmt.Errorf("invalid staleDownloads '%s', must be %s or %s", config.StaleDownloads, StaleDownloadsResume, StaleDownloadsFail)
+	}
+
+	for _, webhook := range config.Webhooks {
+		if err := webhook.validate(); err != nil {
+			return Config{}, err
+		}
+	}
+
 	// Populate the aliases map
 	config.Aliases = make(map[string]string)
 	for modelName, modelConfig := range config.Models {
@@ -236,6 +731,14 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 		}
 	}
 
+	if config.AutoAliases {
+		addAutoAliases(&config)
+	}
+
+	if err := buildAliasPatterns(&config); err != nil {
+		return Config{}, err
+	}
+
 	/* check macro constraint rules:
 
 	- name must fit the regex ^[a-zA-Z0-9_-]+$
@@ -268,6 +771,12 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 	}
 	sort.Strings(modelIds) // This guarantees stable iteration order
 
+	sharedGroupOf, err := sharedProcessGroups(config)
+	if err != nil {
+		return Config{}, err
+	}
+	sharedPorts := make(map[string]string) // group ID to the port its members share
+
 	nextPort := config.StartPort
 	for _, modelId := range modelIds {
 		modelConfig := config.Models[modelId]
@@ -284,6 +793,83 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 			modelConfig.Proxy = strings.ReplaceAll(modelConfig.Proxy, macroSlug, macroValue)
 			modelConfig.CheckEndpoint = strings.ReplaceAll(modelConfig.CheckEndpoint, macroSlug, macroValue)
 			modelConfig.Filters.StripParams = strings.ReplaceAll(modelConfig.Filters.StripParams, macroSlug, macroValue)
+			modelConfig.ChatTemplateFile = strings.ReplaceAll(modelConfig.ChatTemplateFile, macroSlug, macroValue)
+			for i := range modelConfig.LoRAAdapters {
+				modelConfig.LoRAAdapters[i].Path = strings.ReplaceAll(modelConfig.LoRAAdapters[i].Path, macroSlug, macroValue)
+			}
+		}
+
+		if modelConfig.IsRemote() {
+			if strings.TrimSpace(modelConfig.Proxy) == "" || strings.Contains(modelConfig.Proxy, "${PORT}") {
+				return Config{}, fmt.Errorf("model %s: needs a cmd, or a proxy URL of a remote server", modelId)
+			}
+			if modelConfig.ChatTemplateFile != "" || len(modelConfig.LoRAAdapters) > 0 {
+				return Config{}, fmt.Errorf("model %s: chatTemplateFile and loraAdapters need a cmd, they can't be applied to a remote server", modelId)
+			}
+		}
+
+		if modelConfig.ChatTemplateFile != "" {
+			cmd, err := withChatTemplateFile(modelConfig.Cmd, modelConfig.ChatTemplateFile)
+			if err != nil {
+				return Config{}, fmt.Errorf("model %s: %v", modelId, err)
+			}
+			modelConfig.Cmd = cmd
+		}
+
+		if len(modelConfig.LoRAAdapters) > 0 {
+			cmd, err := withLoRAAdapters(modelConfig.Cmd, modelConfig.LoRAAdapters)
+			if err != nil {
+				return Config{}, fmt.Errorf("model %s: %v", modelId, err)
+			}
+			modelConfig.Cmd = cmd
+		}
+
+		switch modelConfig.ForceSystemPromptMode {
+		case SystemPromptModePrepend, SystemPromptModeOverride:
+		default:
+			return Config{}, fmt.Errorf("model %s: invalid forceSystemPromptMode '%s', must be %s or %s", modelId, modelConfig.ForceSystemPromptMode, SystemPromptModePrepend, SystemPromptModeOverride)
+		}
+
+		if modelConfig.KeepWarm.ExtraTTL < 0 || modelConfig.KeepWarm.Window < 0 || modelConfig.KeepWarm.BusyRequests < 0 {
+			return Config{}, fmt.Errorf("model %s: keepWarm values must not be negative", modelId)
+		}
+
+		if modelConfig.ProcessStartTimeout < 0 {
+			return Config{}, fmt.Errorf("model %s: processStartTimeout must not be negative", modelId)
+		}
+		if modelConfig.ProcessStartTimeout == 0 {
+			modelConfig.ProcessStartTimeout = config.ProcessStartTimeout
+		}
+
+		if modelConfig.StopGracePeriod < 0 {
+			return Config{}, fmt.Errorf("model %s: stopGracePeriod must not be negative", modelId)
+		}
+		if modelConfig.StopGracePeriod == 0 {
+			modelConfig.StopGracePeriod = config.StopGracePeriod
+		}
+
+		if modelConfig.HealthCheckInterval < 0 {
+			return Config{}, fmt.Errorf("model %s: healthCheckInterval must not be negative", modelId)
+		}
+		if modelConfig.HealthCheckInterval == 0 {
+			modelConfig.HealthCheckInterval = config.HealthCheckInterval
+		}
+
+		if modelConfig.Niceness == nil {
+			niceness := config.Niceness
+			modelConfig.Niceness = &niceness
+		}
+		if *modelConfig.Niceness < minNiceness || *modelConfig.Niceness > maxNiceness {
+			return Config{}, fmt.Errorf("model %s: niceness must be between %d and %d", modelId, minNiceness, maxNiceness)
+		}
+
+		if modelConfig.Retry.MaxAttempts < 0 || modelConfig.Retry.Backoff < 0 {
+			return Config{}, fmt.Errorf("model %s: retry values must not be negative", modelId)
+		}
+		for _, code := range modelConfig.Retry.StatusCodes {
+			if code < 500 || code > 599 {
+				return Config{}, fmt.Errorf("model %s: retry status code %d is not a 5xx status", modelId, code)
+			}
 		}
 
 		// enforce ${PORT} used in both cmd and proxy
@@ -291,13 +877,28 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 			return Config{}, fmt.Errorf("model %s: proxy uses ${PORT} but cmd does not - ${PORT} is only available when used in cmd", modelId)
 		}
 
+		if !modelConfig.IsRemote() {
+			config.Warnings = append(config.Warnings, cmdWarnings(modelId, modelConfig.Cmd)...)
+		}
+
+		groupID, shared := sharedGroupOf[modelId]
+		if shared && (modelConfig.IsRemote() || !strings.Contains(modelConfig.Cmd, "${PORT}")) {
+			return Config{}, fmt.Errorf("model %s: members of sharedProcess group %s need a cmd that uses ${PORT}", modelId, groupID)
+		}
+
 		// only iterate over models that use ${PORT} to keep port numbers from increasing unnecessarily
 		if strings.Contains(modelConfig.Cmd, "${PORT}") || strings.Contains(modelConfig.Proxy, "${PORT}") || strings.Contains(modelConfig.CmdStop, "${PORT}") {
-			nextPortStr := strconv.Itoa(nextPort)
+			nextPortStr, reused := sharedPorts[groupID]
+			if !shared || !reused {
+				nextPortStr = strconv.Itoa(nextPort)
+				nextPort++
+				if shared {
+					sharedPorts[groupID] = nextPortStr
+				}
+			}
 			modelConfig.Cmd = strings.ReplaceAll(modelConfig.Cmd, "${PORT}", nextPortStr)
 			modelConfig.CmdStop = strings.ReplaceAll(modelConfig.CmdStop, "${PORT}", nextPortStr)
 			modelConfig.Proxy = strings.ReplaceAll(modelConfig.Proxy, "${PORT}", nextPortStr)
-			nextPort++
 		}
 
 		if strings.Contains(modelConfig.Cmd, "${MODEL_ID}") || strings.Contains(modelConfig.CmdStop, "${MODEL_ID}") {
@@ -350,6 +951,20 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 		}
 	}
 
+	// fallbackModels may use aliases, they are resolved to model IDs
+	for modelID, modelConfig := range config.Models {
+		for i, fallback := range modelConfig.FallbackModels {
+			realName, found := config.RealModelName(fallback)
+			if !found {
+				return Config{}, fmt.Errorf("model %s: fallback model %s is not defined", modelID, fallback)
+			}
+			if realName == modelID {
+				return Config{}, fmt.Errorf("model %s: a model can't be its own fallback", modelID)
+			}
+			modelConfig.FallbackModels[i] = realName
+		}
+	}
+
 	// clean up hooks preload
 	if len(config.Hooks.OnStartup.Preload) > 0 {
 		var toPreload []string
@@ -362,10 +977,18 @@ func LoadConfigFromReader(r io.Reader) (Config, error) {
 				toPreload = append(toPreload, real)
 			}
 		}
+		sort.SliceStable(toPreload, func(i, j int) bool {
+			return config.Models[toPreload[i]].PreloadPriority > config.Models[toPreload[j]].PreloadPriority
+		})
 
+		var preloadWarnings []string
+		toPreload, preloadWarnings = resolvePreloadConflicts(toPreload, config)
+		config.Warnings = append(config.Warnings, preloadWarnings...)
 		config.Hooks.OnStartup.Preload = toPreload
 	}
 
+	config.Warnings = append(config.Warnings, modelLimitWarnings(config)...)
+
 	return config, nil
 }
 
@@ -414,6 +1037,39 @@ func AddDefaultGroupToConfig(config Config) Config {
 	return config
 }
 
+// flags that give llama-server the model to load
+var cmdModelFlags = map[string]bool{"-m": true, "--model": true, "-hf": true, "--hf-repo": true}
+
+// cmdWarnings checks a model's cmd, after macro expansion, for the common mistakes that
+// otherwise only show up when the model is started. Unknown flags are left alone.
+func cmdWarnings(modelId, cmd string) []string {
+	args, err := SanitizeCommand(cmd)
+	if err != nil {
+		return []string{fmt.Sprintf("model %s: %v", modelId, err)}
+	}
+
+	var warnings []string
+	hasModel := false
+	for i, arg := range args {
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !cmdModelFlags[flag] {
+			continue
+		}
+		if (hasValue && value != "") || (!hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-")) {
+			hasModel = true
+			break
+		}
+	}
+	if !hasModel {
+		warnings = append(warnings, fmt.Sprintf("model %s: cmd has no --model/-m argument", modelId))
+	}
+
+	if !strings.Contains(cmd, "${PORT}") {
+		warnings = append(warnings, fmt.Sprintf("model %s: cmd does not use ${PORT}, the proxy URL may not match the port the process listens on", modelId))
+	}
+	return warnings
+}
+
 func SanitizeCommand(cmdStr string) ([]string, error) {
 	var cleanedLines []string
 	for _, line := range strings.Split(cmdStr, "\n") {
@@ -461,3 +1117,71 @@ func StripComments(cmdStr string) string {
 	}
 	return strings.Join(cleanedLines, "\n")
 }
+
+var (
+	autoAliasQuantToken = regexp.MustCompile(`^(i?q[0-9].*|f16|f32|bf16|fp16|fp32|gguf)$`)
+	autoAliasQuantPart  = regexp.MustCompile(`^([0-9]+|k|m|s|l|xs|xxs|nl)$`)
+	autoAliasSizeToken  = regexp.MustCompile(`^([0-9]+x)?[0-9]+(\.[0-9]+)?[bm]$`)
+	autoAliasVersion    = regexp.MustCompile(`^v[0-9]+$`)
+	autoAliasSeparators = regexp.MustCompile(`[\s_.()]+`)
+)
+
+// autoAliasFor derives a short alias from a model name by dropping the
+// quantization and parameter size parts, e.g. "Llama3-8B-Q4_K_M" -> "llama3".
+// An empty string is returned when nothing useful remains.
+func autoAliasFor(name string) string {
+	slug := strings.ToLower(strings.TrimSpace(name))
+	slug = autoAliasSeparators.ReplaceAllString(slug, "-")
+
+	tokens := strings.Split(slug, "-")
+	kept := make([]string, 0, len(tokens))
+	inQuant := false
+	for i, token := range tokens {
+		// q4-k-m and q8-0 are split over several tokens
+		if inQuant && autoAliasQuantPart.MatchString(token) {
+			continue
+		}
+		inQuant = autoAliasQuantToken.MatchString(token)
+		if token == "" || inQuant || autoAliasSizeToken.MatchString(token) {
+			continue
+		}
+		// drop the -vN suffix the config generator appends to duplicate IDs
+		if i == len(tokens)-1 && autoAliasVersion.MatchString(token) {
+			continue
+		}
+		kept = append(kept, token)
+	}
+
+	return strings.Join(kept, "-")
+}
+
+// addAutoAliases adds derived short aliases to config.Aliases. A derived alias
+// is only used when it does not clash with a model ID or an explicit alias and
+// exactly one model maps to it, so ambiguous names are never routed.
+func addAutoAliases(config *Config) {
+	candidates := make(map[string][]string)
+	for modelID, modelConfig := range config.Models {
+		seen := make(map[string]bool)
+		for _, source := range []string{modelID, modelConfig.Name} {
+			alias := autoAliasFor(source)
+			if alias == "" || alias == modelID || seen[alias] {
+				continue
+			}
+			seen[alias] = true
+			candidates[alias] = append(candidates[alias], modelID)
+		}
+	}
+
+	for alias, modelIDs := range candidates {
+		if len(modelIDs) != 1 {
+			continue
+		}
+		if _, found := config.Models[alias]; found {
+			continue
+		}
+		if _, found := config.Aliases[alias]; found {
+			continue
+		}
+		config.Aliases[alias] = modelIDs[0]
+	}
+}
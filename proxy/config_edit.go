@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prave/FrogLLM/event"
+	"gopkg.in/yaml.v3"
+)
+
+// configEditError is a failed edit of the config file with the HTTP status it
+// is answered with
+type configEditError struct {
+	status int
+	err    error
+}
+
+func (e *configEditError) Error() string { return e.err.Error() }
+
+// errConfigUnchanged is returned by an edit of updateConfigFile that leaves the
+// config as it is, the file is then not written
+var errConfigUnchanged = errors.New("config unchanged")
+
+// configEditStatus returns the HTTP status for an error of updateConfigFile,
+// errors returned by the edit itself are bad requests
+func configEditStatus(err error) int {
+	var editErr *configEditError
+	if errors.As(err, &editErr) {
+		return editErr.status
+	}
+	return http.StatusBadRequest
+}
+
+// modelNodeInYAML returns the mapping of a model in the config YAML
+func modelNodeInYAML(node *yaml.Node, modelID string) (*yaml.Node, error) {
+	if node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
+		return nil, fmt.Errorf("invalid YAML document structure")
+	}
+
+	rootNode := node.Content[0]
+	if rootNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("root node is not a mapping")
+	}
+
+	for i := 0; i+1 < len(rootNode.Content); i += 2 {
+		models := rootNode.Content[i+1]
+		if rootNode.Content[i].Value != "models" || models.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(models.Content); j += 2 {
+			if models.Content[j].Value == modelID && models.Content[j+1].Kind == yaml.MappingNode {
+				return models.Content[j+1], nil
+			}
+		}
+		return nil, fmt.Errorf("model %s not found", modelID)
+	}
+	return nil, fmt.Errorf("models section not found")
+}
+
+// setMappingField sets a field of a YAML mapping, adding it if it is not present
+func setMappingField(mapping *yaml.Node, field string, value *yaml.Node) {
+	for k := 0; k+1 < len(mapping.Content); k += 2 {
+		if mapping.Content[k].Value == field {
+			mapping.Content[k+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field},
+		value,
+	)
+}
+
+// setModelFieldInYAML sets a field of a model in the config YAML, adding the
+// field if it is not present so comments and ordering are preserved
+func setModelFieldInYAML(node *yaml.Node, modelID, field string, value *yaml.Node) error {
+	model, err := modelNodeInYAML(node, modelID)
+	if err != nil {
+		return err
+	}
+	setMappingField(model, field, value)
+	return nil
+}
+
+// cmdNode returns the YAML string of a cmd, a literal block when it has several lines
+func cmdNode(cmd string) *yaml.Node {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: cmd}
+	if strings.Contains(cmd, "\n") {
+		value.Style = yaml.LiteralStyle
+	}
+	return value
+}
+
+// updateConfigFile edits the config file and starts a config reload. edit gets
+// the parsed YAML so comments and ordering are kept. The file is restored when
+// the result is not a valid config. Edits are serialized, concurrent ones would
+// otherwise overwrite each other's changes.
+func (pm *ProxyManager) updateConfigFile(edit func(doc *yaml.Node) error) error {
+	pm.configWriteMu.Lock()
+	defer pm.configWriteMu.Unlock()
+
+	configPath := pm.currentConfigPath()
+	originalBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to read config file: %v", err)}
+	}
+	var yamlNode yaml.Node
+	if err := yaml.Unmarshal(originalBytes, &yamlNode); err != nil {
+		return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to parse YAML: %v", err)}
+	}
+
+	if err := edit(&yamlNode); err == errConfigUnchanged {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	updatedBytes, err := yaml.Marshal(&yamlNode)
+	if err != nil {
+		return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to marshal updated YAML: %v", err)}
+	}
+	if err := os.WriteFile(configPath, updatedBytes, 0644); err != nil {
+		return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to write config file: %v", err)}
+	}
+	if _, err := LoadConfig(configPath); err != nil {
+		if restoreErr := os.WriteFile(configPath, originalBytes, 0644); restoreErr != nil {
+			pm.proxyLogger.Errorf("Failed to restore config file: %v", restoreErr)
+		}
+		return &configEditError{http.StatusBadRequest, fmt.Errorf("Updated configuration is invalid: %v", err)}
+	}
+
+	event.Emit(ConfigFileChangedEvent{ReloadingState: ReloadingStateStart})
+	return nil
+}
+
+// updateModelFieldsInConfig is updateConfigFile for the fields of one model,
+// edit gets the model's mapping, see setMappingField
+func (pm *ProxyManager) updateModelFieldsInConfig(modelID string, edit func(model *yaml.Node) error) error {
+	return pm.updateConfigFile(func(doc *yaml.Node) error {
+		model, err := modelNodeInYAML(doc, modelID)
+		if err != nil {
+			return err
+		}
+		return edit(model)
+	})
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// effectiveModelConfig is a model's config as it runs, with macros, ${PORT},
+// ${MODEL_ID}, chatTemplateFile and inherited global settings resolved
+type effectiveModelConfig struct {
+	Cmd                 string   `json:"cmd"`
+	Args                []string `json:"args"` // cmd as passed to exec, after comments and line continuations are removed
+	ArgsError           string   `json:"argsError,omitempty"`
+	CmdStop             string   `json:"cmdStop,omitempty"`
+	Proxy               string   `json:"proxy"`
+	CheckEndpoint       string   `json:"checkEndpoint"`
+	Env                 []string `json:"env"`
+	Aliases             []string `json:"aliases"`
+	Group               string   `json:"group"`
+	TTL                 int      `json:"ttl"`
+	ConcurrencyLimit    int      `json:"concurrencyLimit"`
+	HealthCheckTimeout  int      `json:"healthCheckTimeout"`
+	ProcessStartTimeout int      `json:"processStartTimeout"`
+	StopGracePeriod     int      `json:"stopGracePeriod"`
+	HealthCheckInterval int      `json:"healthCheckInterval"`
+	Niceness            int      `json:"niceness"`
+	UseModelName        string   `json:"useModelName,omitempty"`
+	Unlisted            bool     `json:"unlisted"`
+}
+
+// apiGetEffectiveConfig handles GET /api/config/effective, what each model actually
+// runs as opposed to the raw YAML returned by GET /api/config
+func (pm *ProxyManager) apiGetEffectiveConfig(c *gin.Context) {
+	groupOf := make(map[string]string)
+	for groupID, group := range pm.config.Groups {
+		for _, member := range group.Members {
+			groupOf[member] = groupID
+		}
+	}
+
+	aliasesOf := make(map[string][]string)
+	for alias, modelID := range pm.config.Aliases {
+		aliasesOf[modelID] = append(aliasesOf[modelID], alias)
+	}
+
+	models := make(map[string]effectiveModelConfig, len(pm.config.Models))
+	for modelID, modelConfig := range pm.config.Models {
+		aliases := aliasesOf[modelID]
+		if aliases == nil {
+			aliases = []string{}
+		}
+		sort.Strings(aliases)
+
+		env := modelConfig.Env
+		if env == nil {
+			env = []string{}
+		}
+
+		effective := effectiveModelConfig{
+			Cmd:                 modelConfig.Cmd,
+			CmdStop:             modelConfig.CmdStop,
+			Proxy:               modelConfig.Proxy,
+			CheckEndpoint:       modelConfig.CheckEndpoint,
+			Env:                 env,
+			Aliases:             aliases,
+			Group:               groupOf[modelID],
+			TTL:                 modelConfig.UnloadAfter,
+			ConcurrencyLimit:    modelConfig.ConcurrencyLimit,
+			HealthCheckTimeout:  pm.config.HealthCheckTimeout,
+			ProcessStartTimeout: modelConfig.ProcessStartTimeout,
+			StopGracePeriod:     modelConfig.StopGracePeriod,
+			HealthCheckInterval: modelConfig.HealthCheckInterval,
+			Niceness:            modelConfig.niceness(),
+			UseModelName:        modelConfig.UseModelName,
+			Unlisted:            modelConfig.Unlisted,
+		}
+		// the defaults NewProcess applies
+		if effective.ConcurrencyLimit <= 0 {
+			effective.ConcurrencyLimit = 10
+		}
+		if effective.ProcessStartTimeout <= 0 {
+			effective.ProcessStartTimeout = defaultProcessStartTimeout
+		}
+		if effective.StopGracePeriod <= 0 {
+			effective.StopGracePeriod = defaultStopGracePeriod
+		}
+		if args, err := modelConfig.SanitizedCommand(); err != nil {
+			effective.ArgsError = err.Error()
+		} else {
+			effective.Args = args
+		}
+		models[modelID] = effective
+	}
+
+	warnings := pm.config.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"configPath": pm.currentConfigPath(),
+		"models":     models,
+		"warnings":   warnings,
+	})
+}
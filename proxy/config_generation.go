@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// beginGeneration claims the ProgressManager for a config generation started by an
+// API request. While another generation runs it answers 409 with the running job
+// and returns false.
+func (pm *ProxyManager) beginGeneration(c *gin.Context, source string) (string, bool) {
+	jobID, err := autosetup.GetProgressManager().BeginGeneration(source)
+	var inProgress *autosetup.GenerationInProgressError
+	if errors.As(err, &inProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "generation already in progress",
+			"jobId":     inProgress.JobID,
+			"source":    inProgress.Source,
+			"startedAt": inProgress.StartedAt,
+		})
+		return "", false
+	}
+	return jobID, true
+}
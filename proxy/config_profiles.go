@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/event"
+)
+
+// Config profiles are whole alternative config files, e.g. with larger
+// contexts for development. They are not the removed profiles config key.
+
+// profile names are used in file names, e.g. config.dev.yaml
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// DefaultProfile is the profile name of the base config file
+const DefaultProfile = "default"
+
+// ProfileConfigPath returns the config file of a profile. Profiles are stored
+// next to the base config, e.g. config.yaml has config.dev.yaml for "dev".
+// An empty or default profile is the base config.
+func ProfileConfigPath(baseConfigPath, profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return baseConfigPath
+	}
+	ext := filepath.Ext(baseConfigPath)
+	return strings.TrimSuffix(baseConfigPath, ext) + "." + profile + ext
+}
+
+// ValidateProfile checks that profile is a valid name with an existing config file
+func ValidateProfile(baseConfigPath, profile string) error {
+	if profile == "" || profile == DefaultProfile {
+		return nil
+	}
+	if !profileNameRegex.MatchString(profile) {
+		return fmt.Errorf("invalid config profile name %s", profile)
+	}
+	profilePath := ProfileConfigPath(baseConfigPath, profile)
+	if _, err := os.Stat(profilePath); err != nil {
+		return fmt.Errorf("config profile %s not found: %s", profile, profilePath)
+	}
+	return nil
+}
+
+// ListProfiles returns the default profile and all profiles with a config
+// file next to the base config
+func ListProfiles(baseConfigPath string) []string {
+	ext := filepath.Ext(baseConfigPath)
+	prefix := strings.TrimSuffix(filepath.Base(baseConfigPath), ext) + "."
+
+	profiles := []string{DefaultProfile}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(baseConfigPath), "*"+ext))
+	for _, match := range matches {
+		name := filepath.Base(match)
+		rest := strings.TrimPrefix(name, prefix)
+		if rest == name || !strings.HasSuffix(rest, ext) {
+			continue
+		}
+		profile := strings.TrimSuffix(rest, ext)
+		if profileNameRegex.MatchString(profile) && profile != DefaultProfile {
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Strings(profiles[1:])
+	return profiles
+}
+
+// SetConfigProfile sets the base config path and the active profile, the
+// config is read from ProfileConfigPath(baseConfigPath, profile)
+func (pm *ProxyManager) SetConfigProfile(baseConfigPath, profile string) {
+	if profile == DefaultProfile {
+		profile = ""
+	}
+	pm.baseConfigPath = baseConfigPath
+	pm.activeProfile = profile
+	pm.configPath = ProfileConfigPath(baseConfigPath, profile)
+}
+
+// currentConfigPath returns the config file of the active profile
+func (pm *ProxyManager) currentConfigPath() string {
+	pm.Lock()
+	defer pm.Unlock()
+	return pm.configPath
+}
+
+// ActiveProfile returns the name of the active config profile
+func (pm *ProxyManager) ActiveProfile() string {
+	pm.Lock()
+	defer pm.Unlock()
+	if pm.activeProfile == "" {
+		return DefaultProfile
+	}
+	return pm.activeProfile
+}
+
+// activateProfile makes profile the active config profile once its config
+// loads. ConfigProfileActivatedEvent then has the server reload with it like
+// with an edited config file, see frogllm.go.
+func (pm *ProxyManager) activateProfile(profile string) (Config, error) {
+	pm.Lock()
+	baseConfigPath := pm.baseConfigPath
+	pm.Unlock()
+
+	if err := ValidateProfile(baseConfigPath, profile); err != nil {
+		return Config{}, err
+	}
+	newConfig, err := LoadConfig(ProfileConfigPath(baseConfigPath, profile))
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to load config profile %s: %v", profile, err)
+	}
+
+	pm.Lock()
+	pm.SetConfigProfile(baseConfigPath, profile)
+	configPath := pm.configPath
+	pm.Unlock()
+
+	pm.proxyLogger.Infof("Activated config profile %s (%s), reloading", profile, configPath)
+	event.Emit(ConfigProfileActivatedEvent{Profile: profile})
+	return newConfig, nil
+}
+
+// apiGetConfigProfiles handles GET /api/config/profiles
+func (pm *ProxyManager) apiGetConfigProfiles(c *gin.Context) {
+	pm.Lock()
+	baseConfigPath := pm.baseConfigPath
+	pm.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"profiles": ListProfiles(baseConfigPath),
+		"active":   pm.ActiveProfile(),
+	})
+}
+
+// apiActivateConfigProfile handles POST /api/config/profile/:name/activate
+func (pm *ProxyManager) apiActivateConfigProfile(c *gin.Context) {
+	newConfig, err := pm.activateProfile(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active": pm.ActiveProfile(),
+		"models": len(newConfig.Models),
+	})
+}
@@ -1,9 +1,16 @@
 package proxy
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prave/FrogLLM/autosetup"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,7 +105,7 @@ func TestConfig_FindConfig(t *testing.T) {
 			},
 		},
 		HealthCheckTimeout: 10,
-		aliases: map[string]string{
+		Aliases: map[string]string{
 			"m1":        "model1",
 			"model-one": "model1",
 			"m2":        "model2",
@@ -486,3 +493,746 @@ models:
 	assert.NoError(t, err)
 	assert.Equal(t, "/path/to/server -p 9000 -hf author/model:F16", strings.Join(sanitizedCmd3, " "))
 }
+
+func TestConfig_ForceSystemPromptMode(t *testing.T) {
+	content := `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    forceSystemPrompt: "be nice"
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, SystemPromptModePrepend, config.Models["model1"].ForceSystemPromptMode)
+
+	content = `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    forceSystemPrompt: "be nice"
+    forceSystemPromptMode: replace
+`
+	_, err = LoadConfigFromReader(strings.NewReader(content))
+	assert.ErrorContains(t, err, "invalid forceSystemPromptMode")
+}
+
+func TestConfig_AutoAliases(t *testing.T) {
+	content := `
+autoAliases: true
+models:
+  llama3-8b-q4-k-m:
+    cmd: path/to/cmd --port ${PORT}
+  mistral-7b:
+    cmd: path/to/cmd --port ${PORT}
+    name: "Mistral 7B Instruct"
+  qwen-7b-q4-k-m:
+    cmd: path/to/cmd --port ${PORT}
+  qwen-14b-q8-0:
+    cmd: path/to/cmd --port ${PORT}
+  phi3:
+    cmd: path/to/cmd --port ${PORT}
+  phi3-mini-q4-k-m:
+    cmd: path/to/cmd --port ${PORT}
+    aliases:
+      - phi3-mini
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	realName, found := config.RealModelName("llama3")
+	assert.True(t, found)
+	assert.Equal(t, "llama3-8b-q4-k-m", realName)
+
+	realName, found = config.RealModelName("mistral-instruct")
+	assert.True(t, found)
+	assert.Equal(t, "mistral-7b", realName)
+
+	realName, found = config.RealModelName("mistral")
+	assert.True(t, found)
+	assert.Equal(t, "mistral-7b", realName)
+
+	// ambiguous aliases are not assigned to either model
+	_, found = config.RealModelName("qwen")
+	assert.False(t, found)
+
+	// model IDs and explicit aliases win over derived ones
+	realName, _ = config.RealModelName("phi3")
+	assert.Equal(t, "phi3", realName)
+	realName, _ = config.RealModelName("phi3-mini")
+	assert.Equal(t, "phi3-mini-q4-k-m", realName)
+}
+
+func TestConfig_AutoAliasesDisabledByDefault(t *testing.T) {
+	content := `
+models:
+  llama3-8b-q4-k-m:
+    cmd: path/to/cmd --port ${PORT}
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	assert.NoError(t, err)
+	_, found := config.RealModelName("llama3")
+	assert.False(t, found)
+}
+
+func TestConfig_KeepWarm(t *testing.T) {
+	content := `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    ttl: 60
+    keepWarm:
+      extraTTL: 300
+      busyRequests: 5
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	keepWarm := config.Models["model1"].KeepWarm
+	assert.True(t, keepWarm.Enabled())
+	assert.Equal(t, 600*time.Second, keepWarm.WindowDuration())
+	assert.Equal(t, 60*time.Second, keepWarm.EffectiveTTL(60*time.Second, 0))
+	assert.Equal(t, 360*time.Second, keepWarm.EffectiveTTL(60*time.Second, 5))
+
+	content = `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    keepWarm:
+      extraTTL: -1
+`
+	_, err = LoadConfigFromReader(strings.NewReader(content))
+	assert.ErrorContains(t, err, "keepWarm values must not be negative")
+}
+
+func TestConfig_Retry(t *testing.T) {
+	content := `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    retry:
+      maxAttempts: 3
+      backoff: 200
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	retry := config.Models["model1"].Retry
+	assert.True(t, retry.Enabled())
+	assert.True(t, retry.ShouldRetry(1, 503))
+	assert.True(t, retry.ShouldRetry(2, 503))
+	assert.False(t, retry.ShouldRetry(3, 503))
+	assert.False(t, retry.ShouldRetry(1, 500))
+	assert.Equal(t, 200*time.Millisecond, retry.BackoffDuration(1))
+	assert.Equal(t, 400*time.Millisecond, retry.BackoffDuration(2))
+
+	content = `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    retry:
+      maxAttempts: 2
+      statusCodes: [429]
+`
+	_, err = LoadConfigFromReader(strings.NewReader(content))
+	assert.ErrorContains(t, err, "retry status code 429 is not a 5xx status")
+}
+
+func TestConfig_ProcessStartTimeout(t *testing.T) {
+	content := `
+processStartTimeout: 20
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+  model2:
+    cmd: path/to/cmd --port ${PORT}
+    processStartTimeout: 5
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 20, config.ProcessStartTimeout)
+	assert.Equal(t, 20, config.Models["model1"].ProcessStartTimeout)
+	assert.Equal(t, 5, config.Models["model2"].ProcessStartTimeout)
+
+	config, err = LoadConfigFromReader(strings.NewReader("models: {}"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, defaultProcessStartTimeout, config.ProcessStartTimeout)
+	}
+
+	content = `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    processStartTimeout: -1
+`
+	_, err = LoadConfigFromReader(strings.NewReader(content))
+	assert.ErrorContains(t, err, "processStartTimeout must not be negative")
+}
+
+func TestConfig_StopGracePeriod(t *testing.T) {
+	content := `
+stopGracePeriod: 30
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+  model2:
+    cmd: path/to/cmd --port ${PORT}
+    stopGracePeriod: 2
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 30, config.Models["model1"].StopGracePeriod)
+	assert.Equal(t, 2, config.Models["model2"].StopGracePeriod)
+
+	config, err = LoadConfigFromReader(strings.NewReader("models: {}"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, defaultStopGracePeriod, config.StopGracePeriod)
+	}
+}
+
+func TestConfig_CmdWarnings(t *testing.T) {
+	content := `
+macros:
+  server: "llama-server --port ${PORT}"
+models:
+  good:
+    cmd: ${server} --model /models/good.gguf
+  hf:
+    cmd: llama-server -hf ggml-org/gemma-3-1b-it-GGUF --port ${PORT}
+  missing-model:
+    cmd: ${server} --ctx-size 4096
+  dangling-model:
+    cmd: ${server} -m --ctx-size 4096
+  missing-port:
+    cmd: llama-server -m /models/fixed.gguf --port 9000
+    proxy: http://localhost:9001
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"model dangling-model: cmd has no --model/-m argument",
+		"model missing-model: cmd has no --model/-m argument",
+		"model missing-port: cmd does not use ${PORT}, the proxy URL may not match the port the process listens on",
+	}, config.Warnings)
+}
+
+func TestConfig_AliasPatterns(t *testing.T) {
+	content := `
+models:
+  llama3-8b:
+    cmd: path/to/cmd --port ${PORT}
+    aliases:
+      - llama3-exact
+    aliasPatterns:
+      - "llama3-*"
+  llama3-8b-q4:
+    cmd: path/to/cmd --port ${PORT}
+    aliasPatterns:
+      - "llama3-*:q4_k_m"
+  llama3-hf:
+    cmd: path/to/cmd --port ${PORT}
+    aliasPatterns:
+      - "meta-llama/*"
+  catch-all:
+    cmd: path/to/cmd --port ${PORT}
+    aliasPatterns:
+      - "*"
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// model IDs and exact aliases win over patterns
+	realName, found := config.RealModelName("llama3-8b-q4")
+	assert.True(t, found)
+	assert.Equal(t, "llama3-8b-q4", realName)
+	realName, _ = config.RealModelName("llama3-exact")
+	assert.Equal(t, "llama3-8b", realName)
+
+	// the most specific pattern wins
+	realName, _ = config.RealModelName("llama3-instruct:q4_k_m")
+	assert.Equal(t, "llama3-8b-q4", realName)
+	realName, _ = config.RealModelName("llama3-instruct")
+	assert.Equal(t, "llama3-8b", realName)
+	realName, _ = config.RealModelName("gpt-4o")
+	assert.Equal(t, "catch-all", realName)
+
+	// * matches across / in owner/name model names
+	realName, _ = config.RealModelName("meta-llama/Llama-3.1-8B-Instruct")
+	assert.Equal(t, "llama3-hf", realName)
+	realName, _ = config.RealModelName("meta-llama/Llama-3.1-8B-Instruct/q4")
+	assert.Equal(t, "llama3-hf", realName)
+	realName, _ = config.RealModelName("openai/gpt-4o")
+	assert.Equal(t, "catch-all", realName)
+
+	// precedence does not depend on map iteration order
+	for i := 0; i < 10; i++ {
+		config, _ := LoadConfigFromReader(strings.NewReader(content))
+		var order []string
+		for _, pattern := range config.AliasPatterns {
+			order = append(order, pattern.Pattern+"="+pattern.ModelID)
+		}
+		assert.Equal(t, []string{
+			"llama3-*:q4_k_m=llama3-8b-q4",
+			"meta-llama/*=llama3-hf",
+			"llama3-*=llama3-8b",
+			"*=catch-all",
+		}, order)
+	}
+
+	content = `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+    aliasPatterns:
+      - "llama3-["
+`
+	_, err = LoadConfigFromReader(strings.NewReader(content))
+	assert.ErrorContains(t, err, "invalid alias pattern")
+}
+
+func TestConfig_LoadOrCreateConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "nested", "config.yaml")
+
+	config, created, err := LoadOrCreateConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, created)
+	assert.Empty(t, config.Models)
+	assert.Equal(t, 8100, config.StartPort)
+	assert.Equal(t, DefaultMacros(), config.Macros)
+
+	// FrogLLM starts with the default config
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/v1/models", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"object":"list","data":[]}`, w.Body.String())
+
+	// an existing config is left alone
+	content := `
+models:
+  model1:
+    cmd: path/to/cmd --port ${PORT}
+`
+	assert.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+	config, created, err = LoadOrCreateConfig(configPath)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Contains(t, config.Models, "model1")
+}
+
+func TestConfig_ChatTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "chat template.jinja")
+	assert.NoError(t, os.WriteFile(templatePath, []byte("{{ messages }}"), 0644))
+
+	content := fmt.Sprintf(`
+macros:
+  templates: %s
+models:
+  model1:
+    cmd: |
+      path/to/server --port ${PORT}
+      --jinja
+    chatTemplateFile: "${templates}/chat template.jinja"
+`, dir)
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	modelConfig := config.Models["model1"]
+	assert.Equal(t, templatePath, modelConfig.ChatTemplateFile)
+	args, err := modelConfig.SanitizedCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"path/to/server", "--port", "8100", "--jinja", "--chat-template-file", templatePath}, args)
+
+	// the file has to exist
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server
+    chatTemplateFile: /does/not/exist.jinja
+`))
+	assert.ErrorContains(t, err, "chatTemplateFile /does/not/exist.jinja")
+
+	// and the cmd must not set its own template
+	_, err = LoadConfigFromReader(strings.NewReader(fmt.Sprintf(`
+models:
+  model1:
+    cmd: path/to/server --chat-template chatml
+    chatTemplateFile: %q
+`, templatePath)))
+	assert.ErrorContains(t, err, "remove it to use chatTemplateFile")
+}
+
+func TestConfig_LoRAAdapters(t *testing.T) {
+	dir := t.TempDir()
+	sqlPath := filepath.Join(dir, "sql-lora.gguf")
+	stylePath := filepath.Join(dir, "style-lora.gguf")
+	for _, path := range []string{sqlPath, stylePath} {
+		assert.NoError(t, os.WriteFile(path, []byte("GGUF"), 0644))
+	}
+
+	content := fmt.Sprintf(`
+macros:
+  adapters: %s
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+    loraAdapters:
+      - path: ${adapters}/sql-lora.gguf
+        scale: 0.5
+      - path: ${adapters}/style-lora.gguf
+      - path: /does/not/exist.gguf
+        disabled: true
+`, dir)
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	modelConfig := config.Models["model1"]
+	assert.Equal(t, []string{sqlPath, stylePath}, modelConfig.enabledLoRAPaths())
+	args, err := modelConfig.SanitizedCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"path/to/server", "--port", "8100", "--lora-scaled", sqlPath, "0.5", "--lora", stylePath}, args)
+
+	// enabled adapters have to exist
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server
+    loraAdapters:
+      - path: /does/not/exist.gguf
+`))
+	assert.ErrorContains(t, err, "loraAdapters /does/not/exist.gguf")
+}
+
+func TestConfig_RemoteModels(t *testing.T) {
+	config, err := LoadConfigFromReader(strings.NewReader(`
+models:
+  remote:
+    proxy: http://192.168.1.20:8080
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	modelConfig := config.Models["remote"]
+	assert.True(t, modelConfig.IsRemote())
+	assert.Equal(t, "http://192.168.1.20:8080", modelConfig.Proxy)
+	assert.Empty(t, config.Warnings)
+
+	// without a cmd the proxy must point at a running server
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  remote:
+    checkEndpoint: /health
+`))
+	assert.ErrorContains(t, err, "needs a cmd, or a proxy URL of a remote server")
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  remote:
+    proxy: http://192.168.1.20:8080
+    chatTemplateFile: /templates/chatml.jinja
+`))
+	assert.ErrorContains(t, err, "can't be applied to a remote server")
+}
+
+func TestConfig_SharedProcessGroupSharesPort(t *testing.T) {
+	config, err := LoadConfigFromReader(strings.NewReader(`
+startPort: 9000
+models:
+  small-a:
+    cmd: llama-server --port ${PORT} -m a.gguf
+  small-b:
+    cmd: llama-server --port ${PORT} -m b.gguf
+  solo:
+    cmd: llama-server --port ${PORT} -m solo.gguf
+groups:
+  small:
+    sharedProcess: true
+    members: [small-a, small-b]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, config.Groups["small"].SharedProcess)
+	assert.Equal(t, "http://localhost:9000", config.Models["small-a"].Proxy)
+	assert.Equal(t, "http://localhost:9000", config.Models["small-b"].Proxy)
+	assert.Equal(t, "llama-server --port 9000 -m b.gguf", config.Models["small-b"].Cmd)
+	assert.Equal(t, "http://localhost:9001", config.Models["solo"].Proxy)
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  small-a:
+    cmd: llama-server --port ${PORT} -m a.gguf
+groups:
+  small:
+    swap: false
+    sharedProcess: true
+    members: [small-a]
+`))
+	assert.ErrorContains(t, err, "sharedProcess needs swap")
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  small-a:
+    cmd: llama-server --port 8080 -m a.gguf
+    proxy: http://localhost:8080
+groups:
+  small:
+    sharedProcess: true
+    members: [small-a]
+`))
+	assert.ErrorContains(t, err, "need a cmd that uses ${PORT}")
+}
+
+func TestConfig_PreloadConflicts(t *testing.T) {
+	content := `
+healthCheckTimeout: 15
+groups:
+  chats:
+    members: [chat-a, chat-b, chat-c]
+  coding:
+    members: [coder]
+  shared:
+    swap: false
+    exclusive: false
+    members: [embed, rerank]
+  pinned:
+    exclusive: false
+    persistent: true
+    members: [whisper]
+models:
+  chat-a:
+    cmd: path/to/cmd --port ${PORT}
+  chat-b:
+    cmd: path/to/cmd --port ${PORT}
+    preloadPreferred: true
+  chat-c:
+    cmd: path/to/cmd --port ${PORT}
+  coder:
+    cmd: path/to/cmd --port ${PORT}
+  embed:
+    cmd: path/to/cmd --port ${PORT}
+  rerank:
+    cmd: path/to/cmd --port ${PORT}
+  whisper:
+    cmd: path/to/cmd --port ${PORT}
+hooks:
+  on_startup:
+    preload: [embed, chat-a, whisper, coder, chat-b, rerank, chat-c]
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// chats and coding are exclusive groups and stop each other, chat-b is the
+	// preferred chat model. The exclusive chat group loads before the shared
+	// models it would stop, the persistent group is never stopped.
+	assert.Equal(t, []string{"chat-b", "embed", "whisper", "rerank"}, config.Hooks.OnStartup.Preload)
+	var warnings []string
+	for _, warning := range config.Warnings {
+		if strings.HasPrefix(warning, "preload:") {
+			warnings = append(warnings, warning)
+		}
+	}
+	assert.Equal(t, []string{
+		"preload: loading chat-a before embed, exclusive group chats of chat-a stops embed",
+		"preload: skipping coder, exclusive group coding of coder stops chat-a; set preloadPreferred on the model to keep it warm instead",
+		"preload: skipping chat-a, chat-a and chat-b are in swap group chats and chat-b has preloadPreferred",
+		"preload: loading chat-b before embed, exclusive group chats of chat-b stops embed",
+		"preload: skipping chat-c, chat-c and chat-b are in swap group chats; set preloadPreferred on the model to keep it warm instead",
+	}, warnings)
+}
+
+func TestConfig_Niceness(t *testing.T) {
+	config, err := LoadConfigFromReader(strings.NewReader(`
+niceness: 5
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+  model2:
+    cmd: path/to/server --port ${PORT}
+    niceness: 19
+  model3:
+    cmd: path/to/server --port ${PORT}
+    niceness: 0
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 5, *config.Models["model1"].Niceness)
+	assert.Equal(t, 19, *config.Models["model2"].Niceness)
+	assert.Equal(t, 0, *config.Models["model3"].Niceness)
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+    niceness: 20
+`))
+	assert.ErrorContains(t, err, "model model1: niceness must be between -20 and 19")
+
+	_, err = LoadConfigFromReader(strings.NewReader("niceness: -21\n"))
+	assert.ErrorContains(t, err, "niceness must be between -20 and 19")
+}
+
+func TestConfig_FallbackModels(t *testing.T) {
+	config, err := LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+    fallbackModels: [backup]
+  model2:
+    cmd: path/to/server --port ${PORT}
+    aliases: [backup]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"model2"}, config.Models["model1"].FallbackModels)
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+    fallbackModels: [model3]
+`))
+	assert.ErrorContains(t, err, "model model1: fallback model model3 is not defined")
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+    aliases: [self]
+    fallbackModels: [self]
+`))
+	assert.ErrorContains(t, err, "model model1: a model can't be its own fallback")
+}
+
+func TestConfig_PreloadPriorityOrdersPreload(t *testing.T) {
+	content := `
+healthCheckTimeout: 15
+groups:
+  together:
+    swap: false
+    exclusive: false
+    members: [small, chat, embed, rerank, last]
+models:
+  small:
+    cmd: path/to/cmd --port ${PORT}
+  chat:
+    cmd: path/to/cmd --port ${PORT}
+    preloadPriority: 10
+  embed:
+    cmd: path/to/cmd --port ${PORT}
+    aliases: [embeddings]
+    preloadPriority: 5
+  rerank:
+    cmd: path/to/cmd --port ${PORT}
+    preloadPriority: 5
+  last:
+    cmd: path/to/cmd --port ${PORT}
+    preloadPriority: -1
+hooks:
+  on_startup:
+    parallel: true
+    preload: [last, small, rerank, embeddings, chat]
+`
+	config, err := LoadConfigFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// equal priorities keep their order in the list
+	assert.Equal(t, []string{"chat", "rerank", "embed", "small", "last"}, config.Hooks.OnStartup.Preload)
+	assert.True(t, config.Hooks.OnStartup.Parallel)
+}
+
+func TestConfig_StaleDownloads(t *testing.T) {
+	config, err := LoadConfigFromReader(strings.NewReader("healthCheckTimeout: 15\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, StaleDownloadsResume, config.StaleDownloads)
+
+	config, err = LoadConfigFromReader(strings.NewReader("staleDownloads: fail\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, StaleDownloadsFail, config.StaleDownloads)
+	}
+
+	_, err = LoadConfigFromReader(strings.NewReader("staleDownloads: retry\n"))
+	assert.ErrorContains(t, err, "invalid staleDownloads 'retry', must be resume or fail")
+}
+
+func TestConfig_WebhookValidation(t *testing.T) {
+	_, err := LoadConfigFromReader(strings.NewReader(`
+webhooks:
+  - url: ftp://example.com/hook
+`))
+	assert.ErrorContains(t, err, "http or https")
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+webhooks:
+  - url: https://example.com/hook
+    events: [model.exploded]
+`))
+	assert.ErrorContains(t, err, "unknown event")
+
+	config, err := LoadConfigFromReader(strings.NewReader(`
+webhooks:
+  - url: https://hooks.slack.com/services/T000/B000/XXX
+    events: [download.completed, download.failed]
+`))
+	if assert.NoError(t, err) {
+		assert.Len(t, config.Webhooks, 1)
+		assert.True(t, config.Webhooks[0].subscribed(WebhookDownloadFailed))
+		assert.False(t, config.Webhooks[0].subscribed(WebhookBinaryUpdated))
+	}
+}
+
+func TestConfig_BinaryMismatchWarnings(t *testing.T) {
+	binary := &autosetup.BinaryMetadata{Type: "cpu", Version: "b5000"}
+
+	server := managedServerPath()
+	content := fmt.Sprintf(`
+models:
+  offloaded:
+    cmd: %s --port ${PORT} -m a.gguf -ngl 999
+  cpu-only:
+    cmd: %s --port ${PORT} -m b.gguf -ngl 0
+  path-binary:
+    cmd: llama-server --port ${PORT} -m c.gguf --n-gpu-layers=99
+`, server, server)
+
+	mismatches := func() []string {
+		config, err := LoadConfigFromReader(strings.NewReader(content))
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		return binaryMismatchWarnings(binary, config)
+	}
+
+	found := mismatches()
+	if assert.Len(t, found, 1) {
+		assert.Contains(t, found[0], "model offloaded")
+		assert.Contains(t, found[0], "-ngl 999")
+		assert.Contains(t, found[0], "/api/binary/optimize")
+	}
+
+	binary.Type = "cuda"
+	assert.Empty(t, mismatches())
+}
@@ -160,40 +160,58 @@ groups:
 		},
 		Models: map[string]ModelConfig{
 			"model1": {
-				Cmd:           "path/to/cmd --arg1 one",
-				CmdStop:       "taskkill /f /t /pid ${PID}",
-				Proxy:         "http://localhost:8080",
-				Aliases:       []string{"m1", "model-one"},
-				Env:           []string{"VAR1=value1", "VAR2=value2"},
-				CheckEndpoint: "/health",
+				Cmd:                   "path/to/cmd --arg1 one",
+				CmdStop:               "taskkill /f /t /pid ${PID}",
+				Proxy:                 "http://localhost:8080",
+				Aliases:               []string{"m1", "model-one"},
+				Env:                   []string{"VAR1=value1", "VAR2=value2"},
+				CheckEndpoint:         "/health",
+				ForceSystemPromptMode: SystemPromptModePrepend,
+				ProcessStartTimeout:   10,
+				StopGracePeriod:       10,
 			},
 			"model2": {
-				Cmd:           "path/to/server --arg1 one",
-				CmdStop:       "taskkill /f /t /pid ${PID}",
-				Proxy:         "http://localhost:8081",
-				Aliases:       []string{"m2"},
-				Env:           []string{},
-				CheckEndpoint: "/",
+				Cmd:                   "path/to/server --arg1 one",
+				CmdStop:               "taskkill /f /t /pid ${PID}",
+				Proxy:                 "http://localhost:8081",
+				Aliases:               []string{"m2"},
+				Env:                   []string{},
+				CheckEndpoint:         "/",
+				ForceSystemPromptMode: SystemPromptModePrepend,
+				ProcessStartTimeout:   10,
+				StopGracePeriod:       10,
 			},
 			"model3": {
-				Cmd:           "path/to/cmd --arg1 one",
-				CmdStop:       "taskkill /f /t /pid ${PID}",
-				Proxy:         "http://localhost:8081",
-				Aliases:       []string{"mthree"},
-				Env:           []string{},
-				CheckEndpoint: "/",
+				Cmd:                   "path/to/cmd --arg1 one",
+				CmdStop:               "taskkill /f /t /pid ${PID}",
+				Proxy:                 "http://localhost:8081",
+				Aliases:               []string{"mthree"},
+				Env:                   []string{},
+				CheckEndpoint:         "/",
+				ForceSystemPromptMode: SystemPromptModePrepend,
+				ProcessStartTimeout:   10,
+				StopGracePeriod:       10,
 			},
 			"model4": {
-				Cmd:           "path/to/cmd --arg1 one",
-				CmdStop:       "taskkill /f /t /pid ${PID}",
-				Proxy:         "http://localhost:8082",
-				CheckEndpoint: "/",
-				Aliases:       []string{},
-				Env:           []string{},
+				Cmd:                   "path/to/cmd --arg1 one",
+				CmdStop:               "taskkill /f /t /pid ${PID}",
+				Proxy:                 "http://localhost:8082",
+				CheckEndpoint:         "/",
+				Aliases:               []string{},
+				Env:                   []string{},
+				ForceSystemPromptMode: SystemPromptModePrepend,
+				ProcessStartTimeout:   10,
+				StopGracePeriod:       10,
 			},
 		},
-		HealthCheckTimeout: 15,
-		MetricsMaxInMemory: 1000,
+		HealthCheckTimeout:  15,
+		ProcessStartTimeout: 10,
+		StopGracePeriod:     10,
+		MetricsMaxInMemory:  1000,
+		VRAMReclaimTimeout:  10,
+		ModelLimits:         ModelLimitsConfig{Warn: 500, Max: 2000},
+		StaleDownloads:      StaleDownloadsResume,
+		SlotMemoryCheck:     SlotMemoryCheckWarn,
 		Profiles: map[string][]string{
 			"test": {"model1", "model2"},
 		},
@@ -222,6 +240,12 @@ groups:
 			},
 		},
 	}
+	for _, modelId := range []string{"model1", "model2", "model3", "model4"} {
+		expected.Warnings = append(expected.Warnings,
+			"model "+modelId+": cmd has no --model/-m argument",
+			"model "+modelId+": cmd does not use ${PORT}, the proxy URL may not match the port the process listens on",
+		)
+	}
 
 	assert.Equal(t, expected, config)
 
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextUsageBuckets are the upper bounds, in tokens, of the context usage
+// histogram in ActivityStats. Requests larger than the last bound go in an
+// extra overflow bucket.
+var contextUsageBuckets = []int64{512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072, 262144}
+
+const (
+	// a context size is only suggested once a model has seen this many requests
+	contextUsageMinRequests = 10
+	// the suggested context leaves this much room above the 99th percentile
+	contextUsageHeadroom = 1.25
+	// the smallest context size suggested
+	contextUsageMinSuggestion = 4096
+)
+
+// contextUsageBucket returns the histogram bucket of a request of tokens
+func contextUsageBucket(tokens int64) int {
+	for i, bound := range contextUsageBuckets {
+		if tokens <= bound {
+			return i
+		}
+	}
+	return len(contextUsageBuckets)
+}
+
+// recordContextUsage adds one request of prompt+completion tokens to the histogram
+func (s *ActivityStats) recordContextUsage(tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	if len(s.ContextUsage) != len(contextUsageBuckets)+1 {
+		usage := make([]int64, len(contextUsageBuckets)+1)
+		copy(usage, s.ContextUsage)
+		s.ContextUsage = usage
+	}
+	s.ContextUsage[contextUsageBucket(tokens)]++
+	if tokens > s.MaxContextTokens {
+		s.MaxContextTokens = tokens
+	}
+}
+
+// contextUsagePercentile returns the upper bound of the bucket holding the p-th
+// percentile of the recorded requests, so the real value is at most that. Requests
+// in the overflow bucket are bounded by the largest request seen.
+func (s *ActivityStats) contextUsagePercentile(p float64) int64 {
+	var total int64
+	for _, count := range s.ContextUsage {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(total)))
+	var seen int64
+	for i, count := range s.ContextUsage {
+		seen += count
+		if seen >= rank {
+			if i < len(contextUsageBuckets) && contextUsageBuckets[i] < s.MaxContextTokens {
+				return contextUsageBuckets[i]
+			}
+			return s.MaxContextTokens
+		}
+	}
+	return s.MaxContextTokens
+}
+
+// ContextUsageReport compares the context a model's requests used to its --ctx-size
+type ContextUsageReport struct {
+	ModelID           string  `json:"modelId"`
+	Requests          int64   `json:"requests"`
+	ContextSize       int     `json:"contextSize"`
+	Parallel          int     `json:"parallel"`
+	SlotContextSize   int     `json:"slotContextSize"`
+	P50Tokens         int64   `json:"p50Tokens"`
+	P95Tokens         int64   `json:"p95Tokens"`
+	P99Tokens         int64   `json:"p99Tokens"`
+	MaxTokens         int64   `json:"maxTokens"`
+	Utilization       float64 `json:"utilization"`
+	SuggestedCtxSize  int     `json:"suggestedCtxSize,omitempty"`
+	Suggestion        string  `json:"suggestion,omitempty"`
+	InsufficientUsage bool    `json:"insufficientUsage,omitempty"`
+}
+
+// buildContextUsageReport reports how much of the per slot context, --ctx-size
+// split over --parallel slots, the requests of a model used. A smaller --ctx-size
+// is suggested when the 99th percentile with headroom fits in less than half of it.
+func buildContextUsageReport(stats *ActivityStats, contextSize, parallel int) ContextUsageReport {
+	slotContext := contextSize / parallel
+	report := ContextUsageReport{
+		ModelID:         stats.ModelID,
+		ContextSize:     contextSize,
+		Parallel:        parallel,
+		SlotContextSize: slotContext,
+		P50Tokens:       stats.contextUsagePercentile(50),
+		P95Tokens:       stats.contextUsagePercentile(95),
+		P99Tokens:       stats.contextUsagePercentile(99),
+		MaxTokens:       stats.MaxContextTokens,
+	}
+	for _, count := range stats.ContextUsage {
+		report.Requests += count
+	}
+	if slotContext > 0 {
+		report.Utilization = math.Round(float64(report.P95Tokens)/float64(slotContext)*1000) / 1000
+	}
+	if report.Requests < contextUsageMinRequests {
+		report.InsufficientUsage = true
+		return report
+	}
+
+	suggestedSlot := contextUsageMinSuggestion
+	for float64(suggestedSlot) < float64(report.P99Tokens)*contextUsageHeadroom {
+		suggestedSlot *= 2
+	}
+	if suggestedSlot*2 <= slotContext {
+		report.SuggestedCtxSize = suggestedSlot * parallel
+		report.Suggestion = fmt.Sprintf("99%% of requests used at most %d tokens of the %d token slot context, lower --ctx-size to %d to free VRAM for the KV cache",
+			report.P99Tokens, slotContext, report.SuggestedCtxSize)
+	}
+	return report
+}
+
+// apiGetContextUsage handles GET /api/activity/context-usage, the context usage
+// report of every configured model with recorded requests
+func (pm *ProxyManager) apiGetContextUsage(c *gin.Context) {
+	if pm.metricsMonitor == nil || pm.metricsMonitor.ActivityStats == nil {
+		c.JSON(http.StatusOK, gin.H{"models": []ContextUsageReport{}})
+		return
+	}
+
+	pm.Lock()
+	models := make(map[string]ModelConfig, len(pm.config.Models))
+	for modelID, modelConfig := range pm.config.Models {
+		models[modelID] = modelConfig
+	}
+	pm.Unlock()
+
+	reports := []ContextUsageReport{}
+	for modelID, stats := range pm.metricsMonitor.ActivityStats.GetStats() {
+		modelConfig, found := models[modelID]
+		if !found || len(stats.ContextUsage) == 0 {
+			continue
+		}
+		args, _ := modelConfig.SanitizedCommand()
+		contextSize := cmdIntFlag(args, "--ctx-size", "-c")
+		if contextSize <= 0 {
+			contextSize = defaultServerContextSize
+		}
+		parallel := cmdIntFlag(args, "--parallel", "-np")
+		if parallel <= 0 {
+			parallel = defaultServerParallel
+		}
+		reports = append(reports, buildContextUsageReport(stats, contextSize, parallel))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ModelID < reports[j].ModelID })
+
+	c.JSON(http.StatusOK, gin.H{"models": reports})
+}
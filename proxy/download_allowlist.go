@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errAutoDownloadNotAllowed is returned by autoDownloadModel for repos the
+// autoDownloadAllowlist of the settings doesn't match
+var errAutoDownloadNotAllowed = errors.New("auto-download not allowed")
+
+// validateAutoDownloadAllowlist requires each pattern to be a valid glob, as
+// matched by compileAliasPattern
+func (s *SystemSettings) validateAutoDownloadAllowlist() error {
+	for i, pattern := range s.AutoDownloadAllowlist {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("autoDownloadAllowlist[%d] is empty", i)
+		}
+		if _, err := compileAliasPattern(strings.ToLower(pattern)); err != nil {
+			return fmt.Errorf("autoDownloadAllowlist[%d] %q: %v", i, pattern, err)
+		}
+	}
+	return nil
+}
+
+// autoDownloadAllowed reports if requests may download repo, an org/name
+// HuggingFace repo. Patterns match case insensitively and a * also matches /,
+// so "bartowski/*" allows every repo of bartowski. Without an allowlist every
+// repo is allowed.
+func (s *SystemSettings) autoDownloadAllowed(repo string) bool {
+	if len(s.AutoDownloadAllowlist) == 0 {
+		return true
+	}
+	repo = strings.ToLower(repo)
+	for _, pattern := range s.AutoDownloadAllowlist {
+		matcher, err := compileAliasPattern(strings.ToLower(strings.TrimSpace(pattern)))
+		if err == nil && matcher.MatchString(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAutoDownloadAllowed returns an error wrapping errAutoDownloadNotAllowed
+// when the repo of modelID, a repo or repo:file model ID, may not be downloaded
+func (pm *ProxyManager) checkAutoDownloadAllowed(modelID string) error {
+	repo, _, _ := strings.Cut(modelID, ":")
+	settings := pm.getSystemSettings()
+	if settings == nil || settings.autoDownloadAllowed(repo) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not match the autoDownloadAllowlist in the settings", errAutoDownloadNotAllowed, repo)
+}
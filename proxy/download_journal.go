@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// the unfinished downloads are kept in this file in the download directory, or
+// FilePaths.DownloadJournal, so downloads interrupted by a crash or restart can
+// be found on the next start
+const downloadJournalFile = "downloads_in_progress.json"
+
+// staleDownloadsError is the error of an interrupted download marked failed on startup
+const staleDownloadsError = "Download interrupted by a restart"
+
+func (dm *DownloadManager) journalPath() string {
+	if path := CurrentFilePaths().DownloadJournal; path != "" {
+		return path
+	}
+	return filepath.Join(dm.downloadDir, downloadJournalFile)
+}
+
+// unfinished reports if a download still has to complete
+func (status DownloadStatus) unfinished() bool {
+	return status == StatusPending || status == StatusDownloading || status == StatusPaused
+}
+
+// saveJournal writes the unfinished downloads to the journal and removes it
+// once there are none. HF API keys are not written, resumed downloads of gated
+// repos fail until they are started again.
+func (dm *DownloadManager) saveJournal() {
+	dm.journalMux.Lock()
+	defer dm.journalMux.Unlock()
+
+	dm.downloadsMux.RLock()
+	var unfinished []DownloadInfo
+	for _, info := range dm.downloads {
+		if info.Status.unfinished() {
+			unfinished = append(unfinished, *info)
+		}
+	}
+	dm.downloadsMux.RUnlock()
+
+	if len(unfinished) == 0 {
+		if err := os.Remove(dm.journalPath()); err != nil && !os.IsNotExist(err) {
+			dm.logger.Warnf("Failed to remove download journal: %v", err)
+		}
+		return
+	}
+
+	data, err := json.MarshalIndent(unfinished, "", "  ")
+	if err != nil {
+		dm.logger.Warnf("Failed to encode download journal: %v", err)
+		return
+	}
+	tmpPath := dm.journalPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		dm.logger.Warnf("Failed to write download journal: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, dm.journalPath()); err != nil {
+		dm.logger.Warnf("Failed to write download journal: %v", err)
+	}
+}
+
+// ReconcileStaleDownloads picks up the downloads the journal holds from before
+// the last shutdown. Paused downloads are restored paused. Downloads that were
+// running are resumed from their partial file, or with resume false marked
+// failed and their partial file removed. It returns the IDs of the stale downloads.
+func (dm *DownloadManager) ReconcileStaleDownloads(resume bool) []string {
+	data, err := os.ReadFile(dm.journalPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			dm.logger.Warnf("Failed to read download journal: %v", err)
+		}
+		return nil
+	}
+	var journal []DownloadInfo
+	if err := json.Unmarshal(data, &journal); err != nil {
+		dm.logger.Warnf("Ignoring unreadable download journal: %v", err)
+		return nil
+	}
+
+	var stale []string
+	var toResume []*DownloadInfo
+	dm.downloadsMux.Lock()
+	for i := range journal {
+		info := &journal[i]
+		if _, exists := dm.downloads[info.ID]; exists || !info.Status.unfinished() {
+			continue
+		}
+		info.Speed, info.ETA = 0, 0
+		// sets are not restored, their parts complete on their own
+		info.SetID, info.PartIndex, info.PartCount = "", 0, 0
+		if stat, err := os.Stat(info.FilePath); err == nil {
+			info.DownloadedBytes = stat.Size()
+		} else {
+			info.DownloadedBytes = 0
+		}
+		dm.downloads[info.ID] = info
+
+		switch {
+		case info.Status == StatusPaused:
+			dm.logger.Infof("Restored paused download: %s", info.ID)
+		case resume:
+			info.Status = StatusPending
+			toResume = append(toResume, info)
+			dm.logger.Infof("Resuming download interrupted by a restart: %s (%d bytes on disk)", info.ID, info.DownloadedBytes)
+		default:
+			info.Status = StatusFailed
+			info.Error = staleDownloadsError
+			if err := os.Remove(info.FilePath); err != nil && !os.IsNotExist(err) {
+				dm.logger.Warnf("Failed to remove partial file %s: %v", info.FilePath, err)
+			}
+			dm.logger.Warnf("Marked download interrupted by a restart as failed: %s", info.ID)
+		}
+		stale = append(stale, info.ID)
+	}
+	dm.downloadsMux.Unlock()
+
+	for _, info := range toResume {
+		ctx, cancel := context.WithCancel(context.Background())
+		dm.workersMux.Lock()
+		dm.activeWorkers[info.ID] = cancel
+		dm.workersMux.Unlock()
+		go dm.downloadWorker(ctx, info)
+	}
+	dm.saveJournal()
+	return stale
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prave/FrogLLM/autosetup"
 	"github.com/prave/FrogLLM/event"
 )
 
@@ -23,6 +24,7 @@ const (
 	StatusDownloading DownloadStatus = "downloading"
 	StatusPaused      DownloadStatus = "paused"
 	StatusCompleted   DownloadStatus = "completed"
+	StatusVerified    DownloadStatus = "verified" // a part of a multi-part set waiting for the other parts
 	StatusFailed      DownloadStatus = "failed"
 	StatusCancelled   DownloadStatus = "cancelled"
 )
@@ -44,19 +46,37 @@ type DownloadInfo struct {
 	Error           string         `json:"error,omitempty"`
 	RetryCount      int            `json:"retryCount"`
 	HFApiKey        string         `json:"-"` // Don't serialize API key
+	AutoLoad        bool           `json:"autoLoad,omitempty"`
+	SetID           string         `json:"setId,omitempty"` // multi-part set of the part, see download_sets.go
+	PartIndex       int            `json:"partIndex,omitempty"`
+	PartCount       int            `json:"partCount,omitempty"`
 }
 
-// DownloadManager handles concurrent downloads with resume capability
+// DownloadOptions are optional settings of a download
+type DownloadOptions struct {
+	// load the downloaded model as soon as the download completes
+	AutoLoad bool
+}
+
+// DownloadManager handles concurrent downloads with resume capability.
+// All DownloadInfo fields that change after StartDownload are guarded by
+// downloadsMux; callers only ever receive copies.
 type DownloadManager struct {
 	downloads     map[string]*DownloadInfo
 	downloadsMux  sync.RWMutex
 	activeWorkers map[string]context.CancelFunc
 	workersMux    sync.RWMutex
+	sets          map[string]*downloadSet // multi-part sets by ID, guarded by downloadsMux
+	partLimit     int                     // parts of a set downloading at once, see download_sets.go
+	journalMux    sync.Mutex              // serializes writes of the download journal
+	mirrors       []string                // HF mirror base URLs, see download_mirrors.go
+	bandwidth     bandwidthLimiter        // shared by all downloads, see download_throttle.go
 	downloadDir   string
 	logger        *LogMonitor
 }
 
-// DownloadProgressEvent is fired when download progress changes
+// DownloadProgressEvent is fired when download progress changes. Info is a
+// snapshot and is safe to read from event handlers.
 type DownloadProgressEvent struct {
 	DownloadID string
 	Info       *DownloadInfo
@@ -74,6 +94,8 @@ func NewDownloadManager(downloadDir string, logger *LogMonitor) *DownloadManager
 	dm := &DownloadManager{
 		downloads:     make(map[string]*DownloadInfo),
 		activeWorkers: make(map[string]context.CancelFunc),
+		sets:          make(map[string]*downloadSet),
+		partLimit:     defaultPartConcurrency,
 		downloadDir:   downloadDir,
 		logger:        logger,
 	}
@@ -97,9 +119,24 @@ func (dm *DownloadManager) startPeriodicCleanup() {
 
 // StartDownload initiates a new download
 func (dm *DownloadManager) StartDownload(modelID, filename, url, hfApiKey, destinationPath string) (string, error) {
+	return dm.StartDownloadWithOptions(modelID, filename, url, hfApiKey, destinationPath, DownloadOptions{})
+}
+
+// StartDownloadWithOptions initiates a new download with optional settings
+func (dm *DownloadManager) StartDownloadWithOptions(modelID, filename, url, hfApiKey, destinationPath string, options DownloadOptions) (string, error) {
+	downloadInfo, err := dm.registerDownload(modelID, filename, url, hfApiKey, destinationPath, options)
+	if err != nil {
+		return "", err
+	}
+	dm.startWorker(downloadInfo)
+	return downloadInfo.ID, nil
+}
+
+// registerDownload adds a pending download without starting it
+func (dm *DownloadManager) registerDownload(modelID, filename, url, hfApiKey, destinationPath string, options DownloadOptions) (*DownloadInfo, error) {
 	// Validate inputs
 	if filename == "" || filename == "undefined" {
-		return "", fmt.Errorf("invalid filename: %s", filename)
+		return nil, fmt.Errorf("invalid filename: %s", filename)
 	}
 
 	downloadID := fmt.Sprintf("%s-%s-%d", modelID, filename, time.Now().Unix())
@@ -125,14 +162,17 @@ func (dm *DownloadManager) StartDownload(modelID, filename, url, hfApiKey, desti
 		}
 	}
 
-	// Ensure download directory exists (including any subdirectories)
-	if err := os.MkdirAll(downloadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create download directory: %v", err)
-	}
-
 	// Clean filename for filesystem
 	cleanFilename := dm.sanitizeFilename(filename)
 	filePath := filepath.Join(downloadDir, cleanFilename)
+	if err := autosetup.CheckPathLength(filePath); err != nil {
+		return nil, err
+	}
+
+	// Ensure download directory exists (including any subdirectories)
+	if err := os.MkdirAll(downloadDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %v", err)
+	}
 
 	downloadInfo := &DownloadInfo{
 		ID:        downloadID,
@@ -144,26 +184,37 @@ func (dm *DownloadManager) StartDownload(modelID, filename, url, hfApiKey, desti
 		StartTime: time.Now(),
 		FilePath:  filePath,
 		HFApiKey:  hfApiKey,
+		AutoLoad:  options.AutoLoad,
 	}
 
 	dm.downloadsMux.Lock()
 	dm.downloads[downloadID] = downloadInfo
 	dm.downloadsMux.Unlock()
+	dm.saveJournal()
+	return downloadInfo, nil
+}
 
-	// Start download worker in separate goroutine
+// startWorker starts the download worker of a registered download
+func (dm *DownloadManager) startWorker(info *DownloadInfo) {
 	ctx, cancel := context.WithCancel(context.Background())
 	dm.workersMux.Lock()
-	dm.activeWorkers[downloadID] = cancel
+	dm.activeWorkers[info.ID] = cancel
 	dm.workersMux.Unlock()
 
-	go dm.downloadWorker(ctx, downloadInfo)
+	go dm.downloadWorker(ctx, info)
 
-	dm.logger.Infof("Started download: %s -> %s", url, filePath)
-	return downloadID, nil
+	dm.logger.Infof("Started download: %s -> %s", info.URL, info.FilePath)
 }
 
 // StartMultiPartDownload initiates multiple downloads for a multi-part model
 func (dm *DownloadManager) StartMultiPartDownload(modelID, quantization string, filePaths []string, hfApiKey, destinationPath string) ([]string, error) {
+	return dm.StartMultiPartDownloadWithOptions(modelID, quantization, filePaths, hfApiKey, destinationPath, DownloadOptions{})
+}
+
+// StartMultiPartDownloadWithOptions initiates multiple downloads for a multi-part model
+// with optional settings, which apply to every part. The parts form a set, see
+// download_sets.go, that completes once every part has been verified.
+func (dm *DownloadManager) StartMultiPartDownloadWithOptions(modelID, quantization string, filePaths []string, hfApiKey, destinationPath string, options DownloadOptions) ([]string, error) {
 	if len(filePaths) == 0 {
 		return nil, fmt.Errorf("no files provided for multi-part download")
 	}
@@ -177,7 +228,7 @@ func (dm *DownloadManager) StartMultiPartDownload(modelID, quantization string,
 	// Create model-specific directory
 	modelDir := filepath.Join(downloadDir, strings.ReplaceAll(modelID, "/", "_"))
 
-	downloadIDs := make([]string, 0, len(filePaths))
+	setParts := make([]*DownloadInfo, 0, len(filePaths))
 	quantDirs := make(map[string]bool) // Track created directories
 
 	// Start download for each part
@@ -193,44 +244,44 @@ func (dm *DownloadManager) StartMultiPartDownload(modelID, quantization string,
 			// Has subdirectory structure
 			subDir := strings.Join(parts[:len(parts)-1], string(os.PathSeparator))
 			targetDir = filepath.Join(modelDir, subDir)
-
-			// Create directory if we haven't already
-			if !quantDirs[targetDir] {
-				if err := os.MkdirAll(targetDir, 0755); err != nil {
-					return nil, fmt.Errorf("failed to create directory %s: %v", targetDir, err)
-				}
-				quantDirs[targetDir] = true
-				dm.logger.Infof("Created directory: %s", targetDir)
-			}
 		} else {
 			// No subdirectory, use model directory directly
 			targetDir = modelDir
-			if !quantDirs[targetDir] {
-				if err := os.MkdirAll(targetDir, 0755); err != nil {
-					return nil, fmt.Errorf("failed to create directory %s: %v", targetDir, err)
-				}
-				quantDirs[targetDir] = true
+		}
+		if err := autosetup.CheckPathLength(filepath.Join(targetDir, dm.sanitizeFilename(filename))); err != nil {
+			return nil, err
+		}
+
+		// Create directory if we haven't already
+		if !quantDirs[targetDir] {
+			if err := os.MkdirAll(targetDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory %s: %v", targetDir, err)
+			}
+			quantDirs[targetDir] = true
+			if targetDir != modelDir {
+				dm.logger.Infof("Created directory: %s", targetDir)
 			}
 		}
 
 		// Construct HuggingFace URL
-		url := fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s", modelID, filePath)
+		url := fmt.Sprintf("%s/%s/resolve/main/%s", huggingFaceBase, modelID, filePath)
 
 		// Use the specific target directory for this file
-		downloadID, err := dm.StartDownload(modelID, filename, url, hfApiKey, targetDir)
+		info, err := dm.registerDownload(modelID, filename, url, hfApiKey, targetDir, options)
 		if err != nil {
 			dm.logger.Errorf("Failed to start download for %s: %v", filename, err)
 			// Continue with other files even if one fails
 			continue
 		}
 
-		downloadIDs = append(downloadIDs, downloadID)
+		setParts = append(setParts, info)
 	}
 
-	if len(downloadIDs) == 0 {
+	if len(setParts) == 0 {
 		return nil, fmt.Errorf("failed to start any downloads")
 	}
 
+	downloadIDs := dm.startSet(modelID, quantization, setParts)
 	dm.logger.Infof("Started multi-part download: %d files for %s/%s", len(downloadIDs), modelID, quantization)
 	return downloadIDs, nil
 }
@@ -238,34 +289,53 @@ func (dm *DownloadManager) StartMultiPartDownload(modelID, quantization string,
 // downloadWorker handles the actual download process with robust retry mechanism
 func (dm *DownloadManager) downloadWorker(ctx context.Context, info *DownloadInfo) {
 	defer func() {
-		dm.workersMux.Lock()
-		delete(dm.activeWorkers, info.ID)
-		dm.workersMux.Unlock()
+		// a cancelled context means Pause/Cancel already removed this worker,
+		// and a resumed download may have registered a new one under the same ID
+		if ctx.Err() == nil {
+			dm.workersMux.Lock()
+			delete(dm.activeWorkers, info.ID)
+			dm.workersMux.Unlock()
+		}
+		// the last part of a set to stop completes it or retries its failed parts
+		if info.SetID != "" {
+			dm.partFinished(info.SetID)
+		}
 	}()
 
+	// parts of a set take turns, see download_sets.go
+	release, ok := dm.acquirePartSlot(ctx, info)
+	if !ok {
+		return
+	}
+	defer release()
+
 	maxRetries := 50 // Allow many retries for large downloads
 	baseDelay := time.Second * 2
 
 	for retryCount := 0; retryCount <= maxRetries; retryCount++ {
-		// Update retry count in download info
+		// Update retry count and status in download info, a paused download
+		// keeps its status and the worker stops
 		dm.downloadsMux.Lock()
 		info.RetryCount = retryCount
+		paused := info.Status == StatusPaused
+		if !paused {
+			info.Status = StatusDownloading
+		}
 		dm.downloadsMux.Unlock()
-
-		// Update status to downloading
-		dm.updateStatus(info.ID, StatusDownloading)
+		if paused {
+			return
+		}
 
 		// Send initial progress event
-		event.Emit(DownloadProgressEvent{
-			DownloadID: info.ID,
-			Info:       info,
-		})
+		dm.emitProgress(info)
 
 		// Check if file already exists (resume support)
 		existingSize := int64(0)
 		if stat, err := os.Stat(info.FilePath); err == nil {
 			existingSize = stat.Size()
+			dm.downloadsMux.Lock()
 			info.DownloadedBytes = existingSize
+			dm.downloadsMux.Unlock()
 			if retryCount > 0 {
 				dm.logger.Infof("Retry %d: Resuming download from byte %d", retryCount, existingSize)
 			} else {
@@ -288,7 +358,9 @@ func (dm *DownloadManager) downloadWorker(ctx context.Context, info *DownloadInf
 		// Check if we should continue retrying
 		select {
 		case <-ctx.Done():
-			dm.updateError(info.ID, "Download cancelled")
+			if dm.statusOf(info.ID) != StatusPaused {
+				dm.updateError(info.ID, "Download cancelled")
+			}
 			return
 		default:
 		}
@@ -311,7 +383,9 @@ func (dm *DownloadManager) downloadWorker(ctx context.Context, info *DownloadInf
 		case <-time.After(delay):
 			continue
 		case <-ctx.Done():
-			dm.updateError(info.ID, "Download cancelled during retry wait")
+			if dm.statusOf(info.ID) != StatusPaused {
+				dm.updateError(info.ID, "Download cancelled during retry wait")
+			}
 			return
 		}
 	}
@@ -321,8 +395,12 @@ func (dm *DownloadManager) downloadWorker(ctx context.Context, info *DownloadInf
 // Returns (success, shouldRetry)
 func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadInfo, existingSize int64, retryCount int) (bool, bool) {
 
-	// Create HTTP request with resume support
-	req, err := http.NewRequestWithContext(ctx, "GET", info.URL, nil)
+	// Create HTTP request with resume support, retries go through the mirrors in turn
+	url, hasNext := dm.attemptURL(info.URL, retryCount)
+	if retryCount > 0 && url != info.URL {
+		dm.logger.Infof("Retry %d: Downloading from mirror %s", retryCount, url)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		if retryCount > 0 {
 			dm.logger.Errorf("Retry %d failed to create request: %v", retryCount, err)
@@ -341,9 +419,8 @@ func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadIn
 	}
 
 	// Make the request with NO timeout - let it run as long as needed
-	client := &http.Client{
-		// Remove timeout completely - downloads can take hours for large models
-	}
+	// Remove timeout completely - downloads can take hours for large models
+	client := autosetup.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		if retryCount > 0 {
@@ -355,7 +432,7 @@ func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadIn
 
 	// Check response status
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		if resp.StatusCode == 404 {
+		if resp.StatusCode == 404 && !hasNext {
 			dm.updateError(info.ID, "File not found on server")
 			return false, false // Don't retry 404 errors
 		}
@@ -366,16 +443,16 @@ func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadIn
 	if existingSize > 0 && resp.StatusCode != http.StatusPartialContent {
 		dm.logger.Warnf("Server doesn't support resume, starting from beginning")
 		existingSize = 0
+		dm.downloadsMux.Lock()
 		info.DownloadedBytes = 0
+		dm.downloadsMux.Unlock()
 	}
 
 	// Get total file size
 	if resp.ContentLength > 0 {
-		if existingSize > 0 {
-			info.TotalBytes = existingSize + resp.ContentLength
-		} else {
-			info.TotalBytes = resp.ContentLength
-		}
+		dm.downloadsMux.Lock()
+		info.TotalBytes = existingSize + resp.ContentLength
+		dm.downloadsMux.Unlock()
 	}
 
 	// Open file for writing (create or append)
@@ -392,7 +469,8 @@ func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadIn
 	defer file.Close()
 
 	// Download with progress tracking
-	success := dm.downloadWithProgress(ctx, info, resp.Body, file)
+	body := &throttledReader{ctx: ctx, reader: resp.Body, limiter: &dm.bandwidth}
+	success := dm.downloadWithProgress(ctx, info, body, file)
 	return success, true // Always allow retry if download fails
 }
 
@@ -401,12 +479,20 @@ func (dm *DownloadManager) attemptDownload(ctx context.Context, info *DownloadIn
 func (dm *DownloadManager) downloadWithProgress(ctx context.Context, info *DownloadInfo, reader io.Reader, writer io.Writer) bool {
 	buffer := make([]byte, 64*1024) // 64KB buffer for optimal performance
 	lastUpdate := time.Now()
+
+	dm.downloadsMux.RLock()
 	lastBytes := info.DownloadedBytes
+	dm.downloadsMux.RUnlock()
 
 	for {
 		select {
 		case <-ctx.Done():
-			dm.updateStatus(info.ID, StatusCancelled)
+			// a paused download keeps its paused status
+			dm.downloadsMux.Lock()
+			if info.Status != StatusPaused {
+				info.Status = StatusCancelled
+			}
+			dm.downloadsMux.Unlock()
 			return false
 		default:
 			n, err := reader.Read(buffer)
@@ -418,11 +504,13 @@ func (dm *DownloadManager) downloadWithProgress(ctx context.Context, info *Downl
 				}
 
 				// Update progress
+				dm.downloadsMux.Lock()
 				info.DownloadedBytes += int64(n)
 
 				// Calculate speed and ETA every second
 				now := time.Now()
-				if now.Sub(lastUpdate) >= time.Second {
+				emit := now.Sub(lastUpdate) >= time.Second
+				if emit {
 					elapsed := now.Sub(lastUpdate).Seconds()
 					if elapsed > 0 {
 						bytesThisSecond := info.DownloadedBytes - lastBytes
@@ -446,31 +534,21 @@ func (dm *DownloadManager) downloadWithProgress(ctx context.Context, info *Downl
 						info.ETA = 0
 					}
 
-					// Fire progress event
-					event.Emit(DownloadProgressEvent{
-						DownloadID: info.ID,
-						Info:       info,
-					})
-
 					lastUpdate = now
 					lastBytes = info.DownloadedBytes
 				}
+				dm.downloadsMux.Unlock()
+
+				// Fire progress event
+				if emit {
+					dm.emitProgress(info)
+				}
 			}
 
 			if err != nil {
 				if err == io.EOF {
-					// Download completed successfully
-					info.Progress = 100
-					dm.updateStatus(info.ID, StatusCompleted)
-					dm.logger.Infof("Download completed: %s", info.FilePath)
-
-					// Send final progress event
-					event.Emit(DownloadProgressEvent{
-						DownloadID: info.ID,
-						Info:       info,
-					})
-
-					return true
+					// Download completed successfully, parts of a set are verified first
+					return dm.finishDownload(info)
 				} else {
 					dm.logger.Errorf("Read error during download: %v", err)
 					return false
@@ -480,13 +558,27 @@ func (dm *DownloadManager) downloadWithProgress(ctx context.Context, info *Downl
 	}
 }
 
+// emitProgress fires a DownloadProgressEvent with a snapshot of info
+func (dm *DownloadManager) emitProgress(info *DownloadInfo) {
+	dm.downloadsMux.RLock()
+	snapshot := *info
+	dm.downloadsMux.RUnlock()
+
+	event.Emit(DownloadProgressEvent{
+		DownloadID: snapshot.ID,
+		Info:       &snapshot,
+	})
+}
+
 // PauseDownload pauses an active download
 func (dm *DownloadManager) PauseDownload(downloadID string) error {
 	dm.workersMux.Lock()
 	if cancel, exists := dm.activeWorkers[downloadID]; exists {
+		// mark it paused before cancelling, otherwise the worker sees the
+		// cancellation first and records the download as failed
+		dm.updateStatus(downloadID, StatusPaused)
 		cancel()
 		delete(dm.activeWorkers, downloadID)
-		dm.updateStatus(downloadID, StatusPaused)
 		dm.logger.Infof("Paused download: %s", downloadID)
 	}
 	dm.workersMux.Unlock()
@@ -495,17 +587,20 @@ func (dm *DownloadManager) PauseDownload(downloadID string) error {
 
 // ResumeDownload resumes a paused download
 func (dm *DownloadManager) ResumeDownload(downloadID string) error {
-	dm.downloadsMux.RLock()
+	dm.downloadsMux.Lock()
 	info, exists := dm.downloads[downloadID]
-	dm.downloadsMux.RUnlock()
-
 	if !exists {
+		dm.downloadsMux.Unlock()
 		return fmt.Errorf("download not found: %s", downloadID)
 	}
 
 	if info.Status != StatusPaused {
+		dm.downloadsMux.Unlock()
 		return fmt.Errorf("download is not paused: %s", downloadID)
 	}
+	// flip the status while still holding the lock so concurrent resumes only start one worker
+	info.Status = StatusPending
+	dm.downloadsMux.Unlock()
 
 	// Start new worker for resumed download
 	ctx, cancel := context.WithCancel(context.Background())
@@ -529,26 +624,28 @@ func (dm *DownloadManager) CancelDownload(downloadID string) error {
 	}
 	dm.workersMux.Unlock()
 
-	// Remove partial file
-	dm.downloadsMux.RLock()
+	// Remove from downloads map
+	dm.downloadsMux.Lock()
 	info, exists := dm.downloads[downloadID]
-	dm.downloadsMux.RUnlock()
+	var status DownloadStatus
+	if exists {
+		status = info.Status
+		delete(dm.downloads, downloadID)
+	}
+	dm.downloadsMux.Unlock()
 
-	if exists && info.Status != StatusCompleted {
+	// Remove partial file
+	if exists && status != StatusCompleted {
 		os.Remove(info.FilePath)
 		dm.logger.Infof("Removed partial file: %s", info.FilePath)
 	}
-
-	// Remove from downloads map
-	dm.downloadsMux.Lock()
-	delete(dm.downloads, downloadID)
-	dm.downloadsMux.Unlock()
+	dm.saveJournal()
 
 	dm.logger.Infof("Cancelled download: %s", downloadID)
 	return nil
 }
 
-// GetDownloads returns all download information
+// GetDownloads returns a snapshot of all download information
 func (dm *DownloadManager) GetDownloads() map[string]*DownloadInfo {
 	dm.downloadsMux.RLock()
 	defer dm.downloadsMux.RUnlock()
@@ -582,22 +679,39 @@ func (dm *DownloadManager) updateStatus(downloadID string, status DownloadStatus
 	dm.downloadsMux.Lock()
 	if info, exists := dm.downloads[downloadID]; exists {
 		info.Status = status
-		if status == StatusCompleted {
+		if status == StatusCompleted || status == StatusVerified {
 			info.Progress = 100
 		}
 	}
 	dm.downloadsMux.Unlock()
+	dm.saveJournal()
+}
+
+// statusOf returns the current status of a download, or "" if unknown
+func (dm *DownloadManager) statusOf(downloadID string) DownloadStatus {
+	dm.downloadsMux.RLock()
+	defer dm.downloadsMux.RUnlock()
+
+	if info, exists := dm.downloads[downloadID]; exists {
+		return info.Status
+	}
+	return ""
 }
 
 // updateError updates the error status of a download
 func (dm *DownloadManager) updateError(downloadID string, errorMsg string) {
 	dm.downloadsMux.Lock()
-	if info, exists := dm.downloads[downloadID]; exists {
+	info, exists := dm.downloads[downloadID]
+	if exists {
 		info.Status = StatusFailed
 		info.Error = errorMsg
 	}
 	dm.downloadsMux.Unlock()
+	dm.saveJournal()
 	dm.logger.Errorf("Download error [%s]: %s", downloadID, errorMsg)
+	if exists {
+		dm.emitProgress(info)
+	}
 }
 
 // sanitizeFilename removes invalid characters from filename
@@ -611,12 +725,9 @@ func (dm *DownloadManager) sanitizeFilename(filename string) string {
 	return clean
 }
 
-// GetDownloadStatus returns the current status of a download
+// GetDownloadStatus returns a snapshot of the current status of a download
 func (dm *DownloadManager) GetDownloadStatus(downloadID string) *DownloadInfo {
-	dm.downloadsMux.RLock()
-	defer dm.downloadsMux.RUnlock()
-
-	if info, exists := dm.downloads[downloadID]; exists {
+	if info, exists := dm.GetDownload(downloadID); exists {
 		return info
 	}
 	return nil
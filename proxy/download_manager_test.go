@@ -0,0 +1,419 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prave/FrogLLM/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDownloadServer(t *testing.T) *httptest.Server {
+	chunk := make([]byte, 16*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(chunk)*8))
+		for i := 0; i < 8; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadManager_ConcurrentAccess(t *testing.T) {
+	server := newTestDownloadServer(t)
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+
+	var wg sync.WaitGroup
+	ids := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := dm.StartDownload(fmt.Sprintf("model%d", i), "model.gguf", server.URL+"/model.gguf", "", fmt.Sprintf("%s/%d", dm.downloadDir, i))
+			if assert.NoError(t, err) {
+				ids <- id
+			}
+		}(i)
+	}
+
+	// query while downloads are starting and progressing
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for id, info := range dm.GetDownloads() {
+					_ = info.Progress
+					_ = info.Status
+					if status := dm.GetDownloadStatus(id); status != nil {
+						_ = status.DownloadedBytes
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(ids)
+
+	cancelled := 0
+	for id := range ids {
+		if cancelled < 5 {
+			assert.NoError(t, dm.CancelDownload(id))
+			cancelled++
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		for _, info := range dm.GetDownloads() {
+			if info.Status != StatusCompleted {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(stop)
+	readers.Wait()
+
+	assert.Len(t, dm.GetDownloads(), 5)
+}
+
+func TestDownloadManager_GetDownloadsReturnsSnapshot(t *testing.T) {
+	server := newTestDownloadServer(t)
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+
+	id, err := dm.StartDownload("model", "model.gguf", server.URL+"/model.gguf", "", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	downloads := dm.GetDownloads()
+	downloads[id].Status = StatusFailed
+	delete(downloads, id)
+
+	info, found := dm.GetDownload(id)
+	assert.True(t, found)
+	assert.NotEqual(t, StatusFailed, info.Status)
+
+	assert.Eventually(t, func() bool {
+		info, _ := dm.GetDownload(id)
+		return info.Status == StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDownloadManager_PauseKeepsPausedStatus(t *testing.T) {
+	started := make(chan struct{})
+	closed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(1024*1024))
+		w.Write(make([]byte, 16*1024))
+		w.(http.Flusher).Flush()
+		close(started)
+
+		// stall mid transfer until the client goes away
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer server.Close()
+
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+	id, err := dm.StartDownload("model", "model.gguf", server.URL+"/model.gguf", "", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not start")
+	}
+	assert.NoError(t, dm.PauseDownload(id))
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download was not interrupted")
+	}
+
+	// the worker has exited or is exiting and must not mark it failed or cancelled
+	assert.Never(t, func() bool {
+		info, _ := dm.GetDownload(id)
+		return info.Status != StatusPaused
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestDownloadManager_ReconcileStaleDownloads(t *testing.T) {
+	content := make([]byte, 64*1024)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	var rangeHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// a download that was running when FrogLLM crashed, with part of the file on disk
+	writeStaleDownload := func(t *testing.T, dir string) DownloadInfo {
+		stale := DownloadInfo{
+			ID:         "owner/model-model.gguf-1",
+			ModelID:    "owner/model",
+			Filename:   "model.gguf",
+			URL:        server.URL + "/model.gguf",
+			Status:     StatusDownloading,
+			TotalBytes: int64(len(content)),
+			FilePath:   filepath.Join(dir, "model.gguf"),
+		}
+		assert.NoError(t, os.WriteFile(stale.FilePath, content[:16*1024], 0644))
+		data, err := json.Marshal([]DownloadInfo{stale})
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, downloadJournalFile), data, 0644))
+		return stale
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		dir := t.TempDir()
+		stale := writeStaleDownload(t, dir)
+
+		dm := NewDownloadManager(dir, NewLogMonitorWriter(io.Discard))
+		assert.Equal(t, []string{stale.ID}, dm.ReconcileStaleDownloads(true))
+
+		assert.Eventually(t, func() bool {
+			info, found := dm.GetDownload(stale.ID)
+			return found && info.Status == StatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "bytes=16384-", rangeHeader)
+		data, err := os.ReadFile(stale.FilePath)
+		assert.NoError(t, err)
+		assert.Equal(t, content, data)
+		assert.NoFileExists(t, filepath.Join(dir, downloadJournalFile))
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		dir := t.TempDir()
+		stale := writeStaleDownload(t, dir)
+
+		dm := NewDownloadManager(dir, NewLogMonitorWriter(io.Discard))
+		assert.Equal(t, []string{stale.ID}, dm.ReconcileStaleDownloads(false))
+
+		info, found := dm.GetDownload(stale.ID)
+		if assert.True(t, found) {
+			assert.Equal(t, StatusFailed, info.Status)
+			assert.Equal(t, staleDownloadsError, info.Error)
+		}
+		assert.NoFileExists(t, stale.FilePath)
+		assert.NoFileExists(t, filepath.Join(dir, downloadJournalFile))
+	})
+
+	t.Run("paused downloads stay paused", func(t *testing.T) {
+		dir := t.TempDir()
+		dm := NewDownloadManager(dir, NewLogMonitorWriter(io.Discard))
+		dm.downloads["paused"] = &DownloadInfo{ID: "paused", Status: StatusPaused, FilePath: filepath.Join(dir, "paused.gguf")}
+		dm.saveJournal()
+		assert.FileExists(t, filepath.Join(dir, downloadJournalFile))
+
+		restarted := NewDownloadManager(dir, NewLogMonitorWriter(io.Discard))
+		assert.Equal(t, []string{"paused"}, restarted.ReconcileStaleDownloads(true))
+		info, found := restarted.GetDownload("paused")
+		if assert.True(t, found) {
+			assert.Equal(t, StatusPaused, info.Status)
+		}
+	})
+}
+
+func TestDownloadManager_MirrorFailover(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	defer primary.Close()
+	var mirrorPath string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorPath = r.URL.Path
+		w.Write([]byte("gguf"))
+	}))
+	defer mirror.Close()
+
+	original := huggingFaceBase
+	huggingFaceBase = primary.URL
+	defer func() { huggingFaceBase = original }()
+
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+	dm.SetMirrors([]string{" " + mirror.URL + "/ "})
+
+	url, hasNext := dm.attemptURL(primary.URL+"/org/repo/resolve/main/model.gguf", 2)
+	assert.Equal(t, primary.URL+"/org/repo/resolve/main/model.gguf", url)
+	assert.False(t, hasNext)
+	url, _ = dm.attemptURL("https://example.com/model.gguf", 1)
+	assert.Equal(t, "https://example.com/model.gguf", url, "only HF URLs have mirrors")
+
+	// the 404 on the primary isn't final while the mirror is left to try
+	id, err := dm.StartDownload("org/repo", "model.gguf", primary.URL+"/org/repo/resolve/main/model.gguf", "", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool {
+		info, _ := dm.GetDownload(id)
+		return info.Status == StatusCompleted || info.Status == StatusFailed
+	}, 10*time.Second, 20*time.Millisecond)
+
+	info, _ := dm.GetDownload(id)
+	assert.Equal(t, StatusCompleted, info.Status, info.Error)
+	assert.Equal(t, "/org/repo/resolve/main/model.gguf", mirrorPath)
+	data, _ := os.ReadFile(info.FilePath)
+	assert.Equal(t, "gguf", string(data))
+}
+
+func TestDownloadManager_BandwidthLimit(t *testing.T) {
+	const fileSize = 1024 * 1024
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(fileSize))
+		w.Write(make([]byte, fileSize))
+	}))
+	defer server.Close()
+
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+	dm.SetBandwidthLimit(4)
+
+	// two downloads at once share the 4MB/s
+	start := time.Now()
+	var ids []string
+	for _, name := range []string{"a.gguf", "b.gguf"} {
+		id, err := dm.StartDownload("org/repo", name, server.URL+"/"+name, "", "")
+		if !assert.NoError(t, err) {
+			return
+		}
+		ids = append(ids, id)
+	}
+	finished := make(map[string]time.Duration)
+	assert.Eventually(t, func() bool {
+		for _, id := range ids {
+			info, _ := dm.GetDownload(id)
+			if _, done := finished[id]; !done && (info.Status == StatusCompleted || info.Status == StatusFailed) {
+				finished[id] = time.Since(start)
+			}
+		}
+		return len(finished) == len(ids)
+	}, 10*time.Second, 5*time.Millisecond)
+
+	var last time.Duration
+	for _, id := range ids {
+		info, _ := dm.GetDownload(id)
+		assert.Equal(t, StatusCompleted, info.Status, info.Error)
+		assert.Equal(t, int64(fileSize), info.DownloadedBytes)
+		last = max(last, finished[id])
+	}
+	rate := float64(2*fileSize) / last.Seconds() / (1024 * 1024)
+	assert.LessOrEqual(t, rate, 4.0*1.05, "combined rate %.2fMB/s over the cap", rate)
+
+	// neither download gets the bandwidth to itself, they finish together
+	diff := finished[ids[0]] - finished[ids[1]]
+	if diff < 0 {
+		diff = -diff
+	}
+	assert.Less(t, diff, 150*time.Millisecond, "downloads finished %v apart", diff)
+}
+
+func TestDownloadManager_MultiPartSet(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxActive int
+	failedOnce := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if strings.HasSuffix(r.URL.Path, "-00002-of-00003.gguf") && !failedOnce {
+			failedOnce = true
+			mu.Unlock()
+			http.NotFound(w, r)
+			return
+		}
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		data := []byte(path.Base(r.URL.Path))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		time.Sleep(50 * time.Millisecond)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	original := huggingFaceBase
+	huggingFaceBase = server.URL
+	defer func() { huggingFaceBase = original }()
+
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+	dm.SetPartConcurrency(2)
+
+	var events []DownloadInfo
+	defer event.On(func(e DownloadProgressEvent) {
+		mu.Lock()
+		events = append(events, *e.Info)
+		mu.Unlock()
+	})()
+
+	files := []string{"Q4_K_M/model-00001-of-00003.gguf", "Q4_K_M/model-00002-of-00003.gguf", "Q4_K_M/model-00003-of-00003.gguf"}
+	ids, err := dm.StartMultiPartDownload("org/repo", "Q4_K_M", files, "", "")
+	if !assert.NoError(t, err) || !assert.Len(t, ids, 3) {
+		return
+	}
+	assert.Eventually(t, func() bool {
+		for _, id := range ids {
+			if info, _ := dm.GetDownload(id); info.Status != StatusCompleted {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 20*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, failedOnce)
+	assert.Equal(t, 2, maxActive, "parts downloading at once")
+
+	// the failed part was downloaded again, and no part completed before all were verified
+	verified := map[string]bool{}
+	for _, e := range events {
+		switch e.Status {
+		case StatusVerified:
+			verified[e.ID] = true
+		case StatusCompleted:
+			assert.Len(t, verified, 3, "%s completed before all parts were verified", e.Filename)
+		}
+	}
+	for i, id := range ids {
+		info, _ := dm.GetDownload(id)
+		assert.Equal(t, i, info.PartIndex)
+		assert.Equal(t, 3, info.PartCount)
+		assert.Empty(t, info.Error)
+		data, _ := os.ReadFile(info.FilePath)
+		assert.Equal(t, path.Base(files[i]), string(data))
+	}
+}
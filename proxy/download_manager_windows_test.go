@@ -0,0 +1,26 @@
+//go:build windows
+
+package proxy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadManager_OverlongWindowsPath(t *testing.T) {
+	dm := NewDownloadManager(t.TempDir(), NewLogMonitorWriter(io.Discard))
+	deepDir := filepath.Join(t.TempDir(), strings.Repeat("deeply-nested-folder", 8))
+
+	_, err := dm.StartDownload("org/model", strings.Repeat("x", 60)+".gguf", "http://127.0.0.1:1/model.gguf", "", deepDir)
+	assert.ErrorContains(t, err, "Choose a shorter download directory")
+	_, statErr := os.Stat(deepDir)
+	assert.True(t, os.IsNotExist(statErr), "nothing is created for a rejected download")
+
+	_, err = dm.StartMultiPartDownload("org/model", "Q4_K_M", []string{"Q4_K_M/" + strings.Repeat("x", 60) + "-00001-of-00002.gguf"}, "", deepDir)
+	assert.ErrorContains(t, err, "Choose a shorter download directory")
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// huggingFaceBase is the base of the HF download URLs that mirrors can serve,
+// replaceable for testing
+var huggingFaceBase = "https://huggingface.co"
+
+// SetMirrors sets the base URLs of the HF mirrors, e.g. https://hf-mirror.com,
+// that serve the same <repo>/resolve/<revision>/<file> layout as huggingface.co.
+// Call it before starting downloads. HF API keys are sent to mirrors too, so
+// only configure mirrors that are trusted with them.
+func (dm *DownloadManager) SetMirrors(mirrors []string) {
+	dm.mirrors = nil
+	for _, mirror := range mirrors {
+		if mirror = strings.TrimRight(strings.TrimSpace(mirror), "/"); mirror != "" {
+			dm.mirrors = append(dm.mirrors, mirror)
+		}
+	}
+}
+
+// attemptURL returns the URL of a download attempt. Attempts go through the URL
+// and then each mirror in turn, starting over after the last. hasNext reports if
+// a later attempt still tries a URL this one didn't, so a 404 isn't final yet.
+func (dm *DownloadManager) attemptURL(url string, retryCount int) (attempt string, hasNext bool) {
+	path, found := strings.CutPrefix(url, huggingFaceBase+"/")
+	if !found || len(dm.mirrors) == 0 {
+		return url, false
+	}
+	candidates := len(dm.mirrors) + 1
+	index := retryCount % candidates
+	hasNext = retryCount < candidates-1
+	if index == 0 {
+		return url, hasNext
+	}
+	return dm.mirrors[index-1] + "/" + path, hasNext
+}
@@ -0,0 +1,347 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// setFilePath records that the file of a download was moved
+func (dm *DownloadManager) setFilePath(downloadID, filePath string) {
+	dm.downloadsMux.Lock()
+	if info, exists := dm.downloads[downloadID]; exists {
+		info.FilePath = filePath
+	}
+	dm.downloadsMux.Unlock()
+	dm.saveJournal()
+}
+
+// movedFile is a file moved by POST /api/models/downloads/:id/move
+type movedFile struct {
+	DownloadID string `json:"downloadId"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// pathReplacements returns the ways the config may refer to a moved file, the
+// absolute path and for a relative download path that one too, each with the
+// new absolute path
+func pathReplacements(moves []movedFile, relativePaths map[string]string) [][2]string {
+	var replacements [][2]string
+	for _, move := range moves {
+		replacements = append(replacements, [2]string{move.From, move.To})
+		if relative := relativePaths[move.DownloadID]; relative != "" && !filepath.IsAbs(relative) {
+			relative = filepath.Clean(relative)
+			replacements = append(replacements,
+				[2]string{"." + string(filepath.Separator) + relative, move.To},
+				[2]string{relative, move.To})
+		}
+	}
+	return replacements
+}
+
+// modelFileFlagPattern matches a flag of modelFileFlags and the start of its value
+var modelFileFlagPattern = func() *regexp.Regexp {
+	flags := make([]string, 0, len(modelFileFlags))
+	for flag := range modelFileFlags {
+		flags = append(flags, regexp.QuoteMeta(flag))
+	}
+	// longer flags first so --lora-scaled is not matched as --lora
+	sort.Slice(flags, func(i, j int) bool { return len(flags[i]) > len(flags[j]) })
+	return regexp.MustCompile(`(?:^|\s)(?:` + strings.Join(flags, "|") + `)(?:=|\s+)["']?`)
+}()
+
+// replacePath replaces from with to in a config value. The value has to be the
+// path itself, like a loraAdapters path, or pass it whole to one of
+// modelFileFlags, so other paths that merely contain it are kept.
+func replacePath(value, from, to string) string {
+	if value == from {
+		return to
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, match := range modelFileFlagPattern.FindAllStringIndex(value, -1) {
+		start, end := match[1], match[1]+len(from)
+		if start < last || !strings.HasPrefix(value[start:], from) {
+			continue
+		}
+		if end < len(value) && !strings.ContainsRune(" \t\r\n\"'", rune(value[end])) {
+			continue
+		}
+		result.WriteString(value[last:start])
+		result.WriteString(to)
+		last = end
+	}
+	result.WriteString(value[last:])
+	return result.String()
+}
+
+// replaceModelPathsInYAML replaces paths in the string values of the models in
+// the config YAML, their cmd, loraAdapters and so on. It returns the IDs of the
+// models it changed.
+func replaceModelPathsInYAML(node *yaml.Node, replacements [][2]string) []string {
+	if node.Kind != yaml.DocumentNode || len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var replace func(n *yaml.Node) bool
+	replace = func(n *yaml.Node) bool {
+		if n.Kind == yaml.ScalarNode {
+			value := n.Value
+			for _, r := range replacements {
+				value = replacePath(value, r[0], r[1])
+			}
+			changed := value != n.Value
+			n.Value = value
+			return changed
+		}
+		changed := false
+		for _, child := range n.Content {
+			if replace(child) {
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	var changedModels []string
+	rootNode := node.Content[0]
+	for i := 0; i+1 < len(rootNode.Content); i += 2 {
+		models := rootNode.Content[i+1]
+		if rootNode.Content[i].Value != "models" || models.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(models.Content); j += 2 {
+			if replace(models.Content[j+1]) {
+				changedModels = append(changedModels, models.Content[j].Value)
+			}
+		}
+	}
+	sort.Strings(changedModels)
+	return changedModels
+}
+
+// blockModelStarts keeps a stopped model from starting until the returned
+// function is called. It reports false when the model is running or starting.
+func (pm *ProxyManager) blockModelStarts(modelID string) (func(), bool) {
+	processGroup := pm.findGroupByModelName(modelID)
+	if processGroup == nil {
+		return func() {}, true
+	}
+	processGroup.Lock()
+	process, found := processGroup.processes[modelID]
+	processGroup.Unlock()
+	if !found {
+		return func() {}, true
+	}
+	if !process.blockStarts() {
+		return nil, false
+	}
+	return process.unblockStarts, true
+}
+
+// apiMoveDownload handles POST /api/models/downloads/:id/move. It moves the
+// file of a completed download, all parts of a multi-part one, to another
+// tracked folder and points the models that use it to the new path. Files are
+// renamed when the folders are on the same file system and copied otherwise.
+func (pm *ProxyManager) apiMoveDownload(c *gin.Context) {
+	var req struct {
+		Destination string `json:"destination"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Destination) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "destination is required"})
+		return
+	}
+
+	download, exists := pm.downloadManager.GetDownload(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "download not found"})
+		return
+	}
+	downloads := []*DownloadInfo{download}
+	if download.SetID != "" {
+		downloads = nil
+		for _, info := range pm.downloadManager.GetDownloads() {
+			if info.SetID == download.SetID {
+				downloads = append(downloads, info)
+			}
+		}
+		sort.Slice(downloads, func(i, j int) bool { return downloads[i].PartIndex < downloads[j].PartIndex })
+	}
+	for _, info := range downloads {
+		if info.Status != StatusCompleted {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is %s, only completed downloads can be moved", info.Filename, info.Status)})
+			return
+		}
+	}
+
+	// the destination must be a tracked folder
+	destination, err := filepath.Abs(strings.TrimSpace(req.Destination))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination: " + err.Error()})
+		return
+	}
+	db, err := pm.loadModelFolderDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load folder database: " + err.Error()})
+		return
+	}
+	tracked := false
+	for _, folder := range db.Folders {
+		if abs, err := filepath.Abs(folder.Path); err == nil && folder.Enabled && abs == destination {
+			tracked = true
+			break
+		}
+	}
+	if !tracked {
+		c.JSON(http.StatusBadRequest, gin.H{"error": destination + " is not a tracked model folder, add it with POST /api/config/folders first"})
+		return
+	}
+	if stat, err := os.Stat(destination); err != nil || !stat.IsDir() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": destination + " is not a folder"})
+		return
+	}
+
+	var moves []movedFile
+	relativePaths := map[string]string{}
+	for _, info := range downloads {
+		from, err := filepath.Abs(info.FilePath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := os.Stat(from); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s no longer exists", from)})
+			return
+		}
+		to := filepath.Join(destination, filepath.Base(from))
+		if to == from {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is already in %s", info.Filename, destination)})
+			return
+		}
+		if _, err := os.Stat(to); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": to + " already exists"})
+			return
+		}
+		moves = append(moves, movedFile{DownloadID: info.ID, From: from, To: to})
+		relativePaths[info.ID] = info.FilePath
+	}
+
+	// models using the files must be stopped, and the config must point them
+	// to the new paths
+	pm.Lock()
+	var usedBy []string
+	for modelID, modelConfig := range pm.config.Models {
+		referenced := make(map[string]bool)
+		for _, file := range modelFilesFromCmd(modelConfig) {
+			referenced[file] = true
+		}
+		for _, move := range moves {
+			if isReferencedModelFile(move.From, referenced) {
+				usedBy = append(usedBy, modelID)
+				break
+			}
+		}
+	}
+	pm.Unlock()
+	sort.Strings(usedBy)
+
+	// the models stay stopped until the files are moved and the config updated
+	for _, modelID := range usedBy {
+		unblock, stopped := pm.blockModelStarts(modelID)
+		if !stopped {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("model %s uses the file and is running, unload it first", modelID)})
+			return
+		}
+		defer unblock()
+	}
+
+	moveBack := func(moved []movedFile) {
+		for _, move := range moved {
+			if err := moveFile(move.To, move.From); err != nil {
+				pm.proxyLogger.Errorf("Failed to move %s back to %s: %v", move.To, move.From, err)
+			}
+		}
+	}
+	filesMoved := false
+	updatedModels := []string{}
+	err = pm.updateConfigFile(func(doc *yaml.Node) error {
+		if len(usedBy) > 0 {
+			updatedModels = replaceModelPathsInYAML(doc, pathReplacements(moves, relativePaths))
+			updatedBytes, err := yaml.Marshal(doc)
+			if err != nil {
+				return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to marshal updated YAML: %v", err)}
+			}
+
+			// a model that refers to the file some other way, e.g. through a macro,
+			// would lose it
+			updatedConfig, err := LoadConfigFromReader(bytes.NewReader(updatedBytes))
+			if err != nil {
+				return fmt.Errorf("Updated configuration is invalid: %v", err)
+			}
+			for _, modelID := range usedBy {
+				for _, file := range modelFilesFromCmd(updatedConfig.Models[modelID]) {
+					for _, move := range moves {
+						if file == move.From {
+							return &configEditError{http.StatusConflict, fmt.Errorf("model %s refers to %s in a way that can't be updated, edit the config by hand", modelID, move.From)}
+						}
+					}
+				}
+			}
+		}
+
+		for i, move := range moves {
+			if err := moveFile(move.From, move.To); err != nil {
+				moveBack(moves[:i])
+				return &configEditError{http.StatusInternalServerError, fmt.Errorf("failed to move %s: %v", move.From, err)}
+			}
+		}
+		filesMoved = true
+		if len(updatedModels) == 0 {
+			return errConfigUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		if filesMoved {
+			moveBack(moves)
+		}
+		c.JSON(configEditStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, move := range moves {
+		pm.downloadManager.setFilePath(move.DownloadID, move.To)
+		pm.proxyLogger.Infof("Moved download %s from %s to %s", move.DownloadID, move.From, move.To)
+	}
+
+	// the model counts of both folders changed
+	folders := map[string]bool{destination: true}
+	for _, move := range moves {
+		folders[filepath.Dir(move.From)] = true
+	}
+	for i := range db.Folders {
+		if abs, err := filepath.Abs(db.Folders[i].Path); err == nil && folders[abs] {
+			db.Folders[i].ModelCount = pm.countModelsInFolder(abs)
+		}
+	}
+	if err := pm.saveModelFolderDatabase(db); err != nil {
+		pm.proxyLogger.Warnf("Failed to update the folder database after moving a download: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":            download.ID,
+		"destination":   destination,
+		"files":         moves,
+		"updatedModels": updatedModels,
+	})
+}
@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultPartConcurrency is how many parts of a multi-part download are fetched
+// at once unless multiPartConcurrency is set
+const defaultPartConcurrency = 3
+
+// maxSetRetryRounds is how often the failed parts of a set are downloaded again
+// once the other parts are done
+const maxSetRetryRounds = 2
+
+// downloadSet holds the parts of a multi-part download. A part that finishes is
+// verified and waits, the parts are only marked completed together once every
+// part is verified, so nothing picks up a model with parts still missing.
+type downloadSet struct {
+	id      string
+	partIDs []string
+	slots   chan struct{} // one per part downloading
+	retries int           // retry rounds of failed parts so far
+	done    bool
+}
+
+// SetPartConcurrency sets how many parts of a multi-part download are fetched at
+// once, 0 restores the default. Sets that already started keep their limit.
+func (dm *DownloadManager) SetPartConcurrency(parts int) {
+	if parts <= 0 {
+		parts = defaultPartConcurrency
+	}
+	dm.downloadsMux.Lock()
+	dm.partLimit = parts
+	dm.downloadsMux.Unlock()
+}
+
+// startSet makes registered downloads the parts of a set and starts them,
+// returning their IDs in order
+func (dm *DownloadManager) startSet(modelID, quantization string, parts []*DownloadInfo) []string {
+	set := &downloadSet{id: fmt.Sprintf("%s-%s-%d", modelID, quantization, time.Now().UnixNano())}
+	dm.downloadsMux.Lock()
+	set.slots = make(chan struct{}, dm.partLimit)
+	for i, info := range parts {
+		info.SetID, info.PartIndex, info.PartCount = set.id, i, len(parts)
+		set.partIDs = append(set.partIDs, info.ID)
+	}
+	dm.sets[set.id] = set
+	dm.downloadsMux.Unlock()
+	dm.saveJournal()
+
+	for _, info := range parts {
+		dm.startWorker(info)
+	}
+	return set.partIDs
+}
+
+// acquirePartSlot waits until a part of a set may download, parts stay pending
+// meanwhile. Downloads that are not part of a running set don't wait. ok is
+// false when ctx is done first.
+func (dm *DownloadManager) acquirePartSlot(ctx context.Context, info *DownloadInfo) (release func(), ok bool) {
+	dm.downloadsMux.RLock()
+	set := dm.sets[info.SetID]
+	dm.downloadsMux.RUnlock()
+	if set == nil {
+		return func() {}, true
+	}
+	select {
+	case set.slots <- struct{}{}:
+		return func() { <-set.slots }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// finishDownload marks a download that read all its bytes completed. A part of
+// a set is verified instead and its set completes in partFinished. A part that
+// fails verification is removed and false returned, so it is downloaded again.
+func (dm *DownloadManager) finishDownload(info *DownloadInfo) bool {
+	if info.SetID == "" {
+		dm.updateStatus(info.ID, StatusCompleted)
+		dm.logger.Infof("Download completed: %s", info.FilePath)
+		dm.emitProgress(info)
+		return true
+	}
+
+	if err := dm.verifyPart(info); err != nil {
+		dm.logger.Warnf("Part %s failed verification, downloading it again: %v", info.Filename, err)
+		os.Remove(info.FilePath)
+		dm.downloadsMux.Lock()
+		info.DownloadedBytes = 0
+		dm.downloadsMux.Unlock()
+		return false
+	}
+	dm.updateStatus(info.ID, StatusVerified)
+	dm.logger.Infof("Download part %d/%d verified: %s", info.PartIndex+1, info.PartCount, info.FilePath)
+	dm.emitProgress(info)
+	return true
+}
+
+// verifyPart checks the file of a part has the size the server announced
+func (dm *DownloadManager) verifyPart(info *DownloadInfo) error {
+	stat, err := os.Stat(info.FilePath)
+	if err != nil {
+		return err
+	}
+	dm.downloadsMux.RLock()
+	totalBytes := info.TotalBytes
+	dm.downloadsMux.RUnlock()
+	if stat.Size() == 0 {
+		return fmt.Errorf("file is empty")
+	}
+	if totalBytes > 0 && stat.Size() != totalBytes {
+		return fmt.Errorf("file has %d of %d bytes", stat.Size(), totalBytes)
+	}
+	return nil
+}
+
+// partFinished is called when the worker of a part of a set stops. Once no part
+// is left to download, the set completes when every part is verified. Otherwise
+// only its failed parts are downloaded again, up to maxSetRetryRounds times.
+func (dm *DownloadManager) partFinished(setID string) {
+	dm.downloadsMux.Lock()
+	set := dm.sets[setID]
+	if set == nil || set.done {
+		dm.downloadsMux.Unlock()
+		return
+	}
+	var parts, failed []*DownloadInfo
+	for _, id := range set.partIDs {
+		info, exists := dm.downloads[id]
+		if !exists {
+			// a cancelled part cancels the set
+			set.done = true
+			delete(dm.sets, setID)
+			dm.downloadsMux.Unlock()
+			dm.logger.Warnf("Multi-part download %s stopped, part %s was cancelled", setID, id)
+			return
+		}
+		switch info.Status {
+		case StatusVerified:
+		case StatusFailed:
+			failed = append(failed, info)
+		default:
+			// downloading, waiting for a slot or paused
+			dm.downloadsMux.Unlock()
+			return
+		}
+		parts = append(parts, info)
+	}
+
+	if len(failed) == 0 {
+		for _, info := range parts {
+			info.Status = StatusCompleted
+		}
+		set.done = true
+		delete(dm.sets, setID)
+		dm.downloadsMux.Unlock()
+		dm.saveJournal()
+		dm.logger.Infof("Multi-part download completed: all %d parts of %s verified", len(parts), parts[0].ModelID)
+		for _, info := range parts {
+			dm.emitProgress(info)
+		}
+		return
+	}
+
+	if set.retries >= maxSetRetryRounds {
+		set.done = true
+		delete(dm.sets, setID)
+		dm.downloadsMux.Unlock()
+		dm.logger.Errorf("Multi-part download of %s failed, %d of %d parts failed after %d retries", parts[0].ModelID, len(failed), len(parts), maxSetRetryRounds)
+		return
+	}
+	set.retries++
+	round := set.retries
+	for _, info := range failed {
+		info.Status, info.Error, info.RetryCount = StatusPending, "", 0
+	}
+	dm.downloadsMux.Unlock()
+	dm.saveJournal()
+
+	dm.logger.Warnf("Retrying %d failed parts of the multi-part download of %s (%d/%d)", len(failed), parts[0].ModelID, round, maxSetRetryRounds)
+	for _, info := range failed {
+		dm.startWorker(info)
+	}
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter caps the combined rate of all downloads. Readers reserve the
+// bytes they read and wait until the reservation is due, reservations are
+// handed out in order so concurrent downloads get an equal share.
+type bandwidthLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec float64 // 0 is unlimited
+	next        time.Time
+}
+
+// setRate changes the cap, bytes per second, 0 removes it
+func (l *bandwidthLimiter) setRate(bytesPerSec float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	l.bytesPerSec = bytesPerSec
+	l.next = time.Time{}
+}
+
+// chunkSize is how much a reader may read at once, a tenth of a second of
+// bandwidth so slow caps still advance smoothly
+func (l *bandwidthLimiter) chunkSize(max int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.bytesPerSec == 0 {
+		return max
+	}
+	chunk := int(l.bytesPerSec / 10)
+	if chunk < 1024 {
+		chunk = 1024
+	}
+	if chunk > max {
+		chunk = max
+	}
+	return chunk
+}
+
+// wait reserves n bytes and blocks until they are within the cap or ctx is done
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.bytesPerSec == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.bytesPerSec * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader reads through a bandwidthLimiter
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if chunk := r.limiter.chunkSize(len(p)); chunk < len(p) {
+		p = p[:chunk]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// SetBandwidthLimit caps the combined speed of all downloads in MB/s, 0 removes
+// the cap. Running downloads pick up the new limit right away.
+func (dm *DownloadManager) SetBandwidthLimit(mbPerSec float64) {
+	dm.bandwidth.setRate(mbPerSec * 1024 * 1024)
+}
@@ -1,5 +1,7 @@
 package proxy
 
+import "time"
+
 // package level registry of the different event types
 
 const ProcessStateChangeEventID = 0x01
@@ -10,6 +12,13 @@ const TokenMetricsEventID = 0x05
 const ModelPreloadedEventID = 0x06
 const DownloadProgressEventID = 0x07
 const ConfigGenerationProgressEventID = 0x08
+const GenerationProgressEventID = 0x09
+const ModelLoadFailedEventID = 0x0A
+const ProcessHealthChangedEventID = 0x0B
+const BinaryUpdatedEventID = 0x0C
+const SwapDeferredEventID = 0x0D
+const GPUThrottlingEventID = 0x0E
+const ConfigProfileActivatedEventID = 0x0F
 
 type ProcessStateChangeEvent struct {
 	ProcessName string
@@ -61,6 +70,27 @@ func (e ModelPreloadedEvent) Type() uint32 {
 	return ModelPreloadedEventID
 }
 
+// ModelLoadFailedEvent is fired after a failed load recorded its reason
+type ModelLoadFailedEvent struct {
+	ModelName string
+	Reason    string
+	Code      LoadErrorCode
+}
+
+func (e ModelLoadFailedEvent) Type() uint32 {
+	return ModelLoadFailedEventID
+}
+
+// ProcessHealthChangedEvent is fired when a ready process starts or stops failing its health checks
+type ProcessHealthChangedEvent struct {
+	ProcessName string
+	Healthy     bool
+}
+
+func (e ProcessHealthChangedEvent) Type() uint32 {
+	return ProcessHealthChangedEventID
+}
+
 // ConfigGenerationProgressEvent is fired when config generation progress changes
 type ConfigGenerationProgressEvent struct {
 	Stage              string  `json:"stage"`
@@ -73,3 +103,47 @@ type ConfigGenerationProgressEvent struct {
 func (e ConfigGenerationProgressEvent) Type() uint32 {
 	return ConfigGenerationProgressEventID
 }
+
+// BinaryUpdatedEvent is fired after the llama-server binary was replaced
+type BinaryUpdatedEvent struct {
+	Version    string
+	BinaryType string
+}
+
+func (e BinaryUpdatedEvent) Type() uint32 {
+	return BinaryUpdatedEventID
+}
+
+// SwapDeferredEvent is fired when loading a model waits for a resident model to
+// finish its swapCooldown
+type SwapDeferredEvent struct {
+	ModelName     string
+	ResidentModel string
+	Wait          time.Duration
+}
+
+func (e SwapDeferredEvent) Type() uint32 {
+	return SwapDeferredEventID
+}
+
+// GPUThrottlingEvent is fired when a GPU starts throttling while models generate
+type GPUThrottlingEvent struct {
+	GPU    int
+	Name   string
+	Reason string
+	Models []string // the models generating when it started
+}
+
+func (e GPUThrottlingEvent) Type() uint32 {
+	return GPUThrottlingEventID
+}
+
+// ConfigProfileActivatedEvent is fired when another config profile is activated,
+// the server reloads with its config file
+type ConfigProfileActivatedEvent struct {
+	Profile string
+}
+
+func (e ConfigProfileActivatedEvent) Type() uint32 {
+	return ConfigProfileActivatedEventID
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FilePaths are the files FrogLLM keeps its state in. Relative paths are
+// relative to the working directory, so instances sharing a directory need
+// their own names.
+type FilePaths struct {
+	Config         string // models and groups
+	Settings       string // system settings saved from the UI
+	FolderDatabase string // tracked model folders
+
+	// empty keeps them in their directories, activity_stats.json next to the
+	// config file and downloads_in_progress.json in the download directory
+	ActivityStats   string
+	DownloadJournal string
+}
+
+// DefaultFilePaths are used unless a flag or environment variable names a file
+var DefaultFilePaths = FilePaths{
+	Config:         "config.yaml",
+	Settings:       "settings.json",
+	FolderDatabase: "model_folders.json",
+}
+
+// environment variables naming the files, flags take precedence
+const (
+	ConfigFileEnv         = "FROGLLM_CONFIG"
+	SettingsFileEnv       = "FROGLLM_SETTINGS"
+	FolderDatabaseFileEnv = "FROGLLM_FOLDER_DB"
+	ActivityStatsFileEnv  = "FROGLLM_ACTIVITY_STATS"
+	DownloadJournalEnv    = "FROGLLM_DOWNLOAD_JOURNAL"
+)
+
+var (
+	filePathsMutex sync.RWMutex
+	filePaths      = DefaultFilePaths
+)
+
+// ResolveFilePaths fills the fields flags leaves empty from the environment,
+// then from DefaultFilePaths
+func ResolveFilePaths(flags FilePaths) FilePaths {
+	resolve := func(flag, env, fallback string) string {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			return flag
+		}
+		if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return FilePaths{
+		Config:         resolve(flags.Config, ConfigFileEnv, DefaultFilePaths.Config),
+		Settings:       resolve(flags.Settings, SettingsFileEnv, DefaultFilePaths.Settings),
+		FolderDatabase: resolve(flags.FolderDatabase, FolderDatabaseFileEnv, DefaultFilePaths.FolderDatabase),
+
+		ActivityStats:   resolve(flags.ActivityStats, ActivityStatsFileEnv, DefaultFilePaths.ActivityStats),
+		DownloadJournal: resolve(flags.DownloadJournal, DownloadJournalEnv, DefaultFilePaths.DownloadJournal),
+	}
+}
+
+// SetFilePaths sets the files read and written from now on, empty fields use
+// DefaultFilePaths. Call it before New, a ProxyManager starts with the config
+// file set here.
+func SetFilePaths(paths FilePaths) {
+	if paths.Config == "" {
+		paths.Config = DefaultFilePaths.Config
+	}
+	if paths.Settings == "" {
+		paths.Settings = DefaultFilePaths.Settings
+	}
+	if paths.FolderDatabase == "" {
+		paths.FolderDatabase = DefaultFilePaths.FolderDatabase
+	}
+	filePathsMutex.Lock()
+	filePaths = paths
+	filePathsMutex.Unlock()
+}
+
+// CurrentFilePaths returns the files set by SetFilePaths
+func CurrentFilePaths() FilePaths {
+	filePathsMutex.RLock()
+	defer filePathsMutex.RUnlock()
+	return filePaths
+}
+
+// activityStatsPath returns the activity stats file of a ProxyManager using
+// configPath
+func activityStatsPath(configPath string) string {
+	if path := CurrentFilePaths().ActivityStats; path != "" {
+		return path
+	}
+	if configPath == "" {
+		return "activity_stats.json"
+	}
+	return filepath.Join(filepath.Dir(configPath), "activity_stats.json")
+}
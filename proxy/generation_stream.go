@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/event"
+	"github.com/tidwall/gjson"
+)
+
+// generationStreamInterval is how often an in-flight streaming generation reports its progress
+const generationStreamInterval = 250 * time.Millisecond
+
+var lastGenerationID atomic.Int64
+
+// GenerationProgressEvent reports the progress of a streaming generation, the
+// last event of a generation has Done set
+type GenerationProgressEvent struct {
+	GenerationID    int64   `json:"generationId"`
+	Model           string  `json:"model"`
+	TokensGenerated int     `json:"tokensGenerated"`
+	TokensPerSecond float64 `json:"tokensPerSecond"`
+	ElapsedMs       int64   `json:"elapsedMs"`
+	Done            bool    `json:"done"`
+}
+
+func (e GenerationProgressEvent) Type() uint32 {
+	return GenerationProgressEventID
+}
+
+// generationTracker counts the tokens of a streaming response as it is written.
+// llama-server sends one token per SSE chunk.
+type generationTracker struct {
+	id         int64
+	model      string
+	startTime  time.Time
+	firstToken time.Time
+	lastEmit   time.Time
+	tokens     int
+	scanned    int // bytes of the response already scanned for complete lines
+}
+
+func newGenerationTracker(model string) *generationTracker {
+	now := time.Now()
+	return &generationTracker{
+		id:        lastGenerationID.Add(1),
+		model:     model,
+		startTime: now,
+		lastEmit:  now,
+	}
+}
+
+// observe counts the tokens in the complete lines of body not scanned yet and
+// reports progress at most every generationStreamInterval
+func (t *generationTracker) observe(body []byte) {
+	for {
+		end := bytes.IndexByte(body[t.scanned:], '\n')
+		if end < 0 {
+			break
+		}
+		line := bytes.TrimSpace(body[t.scanned : t.scanned+end])
+		t.scanned += end + 1
+		if isTokenChunk(line) {
+			if t.tokens == 0 {
+				t.firstToken = time.Now()
+			}
+			t.tokens++
+		}
+	}
+
+	if time.Since(t.lastEmit) >= generationStreamInterval {
+		t.emit(false)
+	}
+}
+
+func (t *generationTracker) emit(done bool) {
+	now := time.Now()
+	t.lastEmit = now
+
+	progress := GenerationProgressEvent{
+		GenerationID:    t.id,
+		Model:           t.model,
+		TokensGenerated: t.tokens,
+		ElapsedMs:       now.Sub(t.startTime).Milliseconds(),
+		Done:            done,
+	}
+	// the rate is measured from the first token so prompt processing does not lower it
+	if elapsed := now.Sub(t.firstToken).Seconds(); t.tokens > 0 && elapsed > 0 {
+		progress.TokensPerSecond = float64(t.tokens) / elapsed
+	}
+	event.Emit(progress)
+}
+
+// isTokenChunk reports if an SSE line carries generated text, from the OpenAI
+// compatible endpoints or llama-server's own /completion
+func isTokenChunk(line []byte) bool {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return false
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || !gjson.ValidBytes(data) {
+		return false
+	}
+	chunk := gjson.ParseBytes(data)
+	for _, path := range []string{"choices.0.delta.content", "choices.0.delta.reasoning_content", "choices.0.text", "content"} {
+		if chunk.Get(path).String() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// apiGenerationStream handles GET /api/models/:id/generation-stream. It waits for
+// a streaming generation of the model and sends its progress as server-sent
+// events, closing the stream when that generation ends.
+func (pm *ProxyManager) apiGenerationStream(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Content-Type-Options", "nosniff")
+	// prevent nginx from buffering SSE
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	updates := make(chan GenerationProgressEvent, 25)
+	defer event.On(func(e GenerationProgressEvent) {
+		if e.Model != modelID {
+			return
+		}
+		select {
+		case updates <- e:
+		case <-ctx.Done():
+		}
+	})()
+
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// follow the first generation seen, later ones need a new stream
+	var following int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pm.shutdownCtx.Done():
+			return
+		case progress := <-updates:
+			if following == 0 {
+				following = progress.GenerationID
+			} else if progress.GenerationID != following {
+				continue
+			}
+			c.SSEvent("generation", progress)
+			c.Writer.Flush()
+			if progress.Done {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// gpuUnhealthyPolls is how many polls in a row have to find an issue before the
+// GPU counts as wedged, so a single transient error doesn't pause loading
+const gpuUnhealthyPolls = 2
+
+// gpuHealthMonitor keeps the result of the latest GPU health polls
+type gpuHealthMonitor struct {
+	sync.Mutex
+
+	// runs nvidia-smi, replaceable for testing
+	query func() ([]autosetup.GPUHealthIssue, error)
+
+	issues         []autosetup.GPUHealthIssue
+	queryError     string
+	failedPolls    int
+	checkedAt      time.Time
+	unhealthySince time.Time
+}
+
+func newGPUHealthMonitor() *gpuHealthMonitor {
+	return &gpuHealthMonitor{query: autosetup.QueryNvidiaSMIHealth}
+}
+
+// poll queries the GPUs once and reports if that changed whether they are healthy
+func (m *gpuHealthMonitor) poll() (becameUnhealthy, recovered bool) {
+	issues, err := m.query()
+
+	m.Lock()
+	defer m.Unlock()
+	wasUnhealthy := m.failedPolls >= gpuUnhealthyPolls
+	m.checkedAt = time.Now()
+	m.queryError = ""
+	if err != nil {
+		// no nvidia-smi or no NVIDIA GPU, there is nothing to watch
+		m.queryError = err.Error()
+	}
+	m.issues = issues
+	if len(issues) > 0 {
+		m.failedPolls++
+	} else {
+		m.failedPolls = 0
+	}
+
+	unhealthy := m.failedPolls >= gpuUnhealthyPolls
+	if unhealthy && !wasUnhealthy {
+		m.unhealthySince = m.checkedAt
+	} else if !unhealthy {
+		m.unhealthySince = time.Time{}
+	}
+	return unhealthy && !wasUnhealthy, wasUnhealthy && !unhealthy
+}
+
+// unhealthy returns the issues of a GPU that has been failing for gpuUnhealthyPolls polls
+func (m *gpuHealthMonitor) unhealthy() ([]autosetup.GPUHealthIssue, bool) {
+	m.Lock()
+	defer m.Unlock()
+	if m.failedPolls < gpuUnhealthyPolls {
+		return nil, false
+	}
+	return m.issues, true
+}
+
+func describeGPUIssues(issues []autosetup.GPUHealthIssue) string {
+	problems := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		gpu := issue.GPU
+		if issue.Name != "" {
+			gpu = issue.Name + " (" + issue.GPU + ")"
+		}
+		if gpu == "" {
+			problems = append(problems, issue.Problem)
+		} else {
+			problems = append(problems, gpu+": "+issue.Problem)
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// watchGPUHealth polls the GPUs until shutdown, warning when one becomes unhealthy
+func (pm *ProxyManager) watchGPUHealth(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pm.checkGPUHealth()
+		select {
+		case <-pm.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pm *ProxyManager) checkGPUHealth() {
+	becameUnhealthy, recovered := pm.gpuHealth.poll()
+	if becameUnhealthy {
+		issues, _ := pm.gpuHealth.unhealthy()
+		if pm.config.GPUHealth.PauseLoading {
+			pm.proxyLogger.Errorf("GPU unhealthy, model loading is paused until it recovers: %s", describeGPUIssues(issues))
+		} else {
+			pm.proxyLogger.Errorf("GPU unhealthy, model loads are likely to fail until it is reset: %s", describeGPUIssues(issues))
+		}
+	}
+	if recovered {
+		pm.proxyLogger.Infof("GPU health recovered")
+	}
+}
+
+// gpuBlocksLoad refuses to start a model that is not running yet while the GPU is
+// unhealthy and gpuHealth.pauseLoading is set, instead of crashing its process
+func (pm *ProxyManager) gpuBlocksLoad(processGroup *ProcessGroup, modelID string) error {
+	if !pm.config.GPUHealth.PauseLoading {
+		return nil
+	}
+	issues, unhealthy := pm.gpuHealth.unhealthy()
+	if !unhealthy {
+		return nil
+	}
+	processGroup.Lock()
+	process := processGroup.processes[modelID]
+	processGroup.Unlock()
+	if process != nil && process.CurrentState() == StateReady {
+		return nil
+	}
+	return &LoadError{
+		Code:       LoadErrorGPUUnhealthy,
+		Reason:     "model loading is paused, the GPU is unhealthy: " + describeGPUIssues(issues),
+		Suggestion: "reset the GPU (nvidia-smi -r) or reboot, loading resumes once it is healthy",
+	}
+}
+
+// apiGetGPUHealth handles GET /api/system/gpu-health
+func (pm *ProxyManager) apiGetGPUHealth(c *gin.Context) {
+	m := pm.gpuHealth
+	m.Lock()
+	issues := m.issues
+	if issues == nil {
+		issues = []autosetup.GPUHealthIssue{}
+	}
+	unhealthy := m.failedPolls >= gpuUnhealthyPolls
+	response := gin.H{
+		"enabled":       pm.config.GPUHealth.Enabled,
+		"healthy":       !unhealthy,
+		"issues":        issues,
+		"loadingPaused": unhealthy && pm.config.GPUHealth.PauseLoading,
+	}
+	if !m.checkedAt.IsZero() {
+		response["checkedAt"] = m.checkedAt
+	}
+	if !m.unhealthySince.IsZero() {
+		response["unhealthySince"] = m.unhealthySince
+	}
+	if m.queryError != "" {
+		response["error"] = m.queryError
+	}
+	m.Unlock()
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+	"github.com/prave/FrogLLM/event"
+)
+
+// gpuLoadedUtilization is the utilization from which a GPU counts as under load,
+// an idle GPU lowers its clocks below base without throttling
+const gpuLoadedUtilization = 50
+
+// gpuThrottleReason returns why a GPU is throttled, empty when it is not. A GPU
+// under load throttles when the driver reports a thermal slowdown or its SM
+// clock is below its base clock.
+func gpuThrottleReason(sample autosetup.GPUThermalSample) string {
+	if sample.Utilization < gpuLoadedUtilization {
+		return ""
+	}
+	if sample.ThermalSlowdown {
+		return fmt.Sprintf("thermal slowdown at %d°C, SM clock %d MHz", sample.Temperature, sample.SMClock)
+	}
+	if sample.SMClock > 0 && sample.BaseClock > 0 && sample.SMClock < sample.BaseClock {
+		return fmt.Sprintf("SM clock %d MHz below base %d MHz at %d°C", sample.SMClock, sample.BaseClock, sample.Temperature)
+	}
+	return ""
+}
+
+// gpuThrottle is a GPU that is throttling
+type gpuThrottle struct {
+	GPU    int    `json:"gpu"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// gpuThermalMonitor keeps the latest GPU thermal samples and which GPUs throttle
+type gpuThermalMonitor struct {
+	sync.Mutex
+
+	// runs nvidia-smi, replaceable for testing
+	query func() ([]autosetup.GPUThermalSample, error)
+
+	samples    []autosetup.GPUThermalSample
+	throttled  map[int]gpuThrottle // by GPU index
+	queryError string
+	checkedAt  time.Time
+}
+
+func newGPUThermalMonitor() *gpuThermalMonitor {
+	return &gpuThermalMonitor{query: autosetup.QueryNvidiaSMIThermal, throttled: make(map[int]gpuThrottle)}
+}
+
+// poll samples the GPUs once, returning the GPUs that started throttling since
+// the last poll and if any GPU is throttling
+func (m *gpuThermalMonitor) poll() (started []gpuThrottle, throttling bool) {
+	samples, err := m.query()
+
+	m.Lock()
+	defer m.Unlock()
+	m.checkedAt = time.Now()
+	m.queryError = ""
+	if err != nil {
+		// no nvidia-smi or no NVIDIA GPU, there is nothing to watch
+		m.queryError = err.Error()
+	}
+	m.samples = samples
+
+	throttled := make(map[int]gpuThrottle)
+	for _, sample := range samples {
+		reason := gpuThrottleReason(sample)
+		if reason == "" {
+			continue
+		}
+		throttle := gpuThrottle{GPU: sample.Index, Name: sample.Name, Reason: reason}
+		throttled[sample.Index] = throttle
+		if _, already := m.throttled[sample.Index]; !already {
+			started = append(started, throttle)
+		}
+	}
+	m.throttled = throttled
+	return started, len(throttled) > 0
+}
+
+// idle forgets the throttling GPUs while nothing generates, so throttling during
+// the next generation counts as a new event
+func (m *gpuThermalMonitor) idle() {
+	m.Lock()
+	defer m.Unlock()
+	m.throttled = make(map[int]gpuThrottle)
+}
+
+// generatingModels returns the ready models with requests in flight
+func (pm *ProxyManager) generatingModels() []string {
+	pm.Lock()
+	defer pm.Unlock()
+	var models []string
+	for _, processGroup := range pm.processGroups {
+		processGroup.Lock()
+		for modelID, process := range processGroup.processes {
+			if process.CurrentState() == StateReady && process.inFlightCount.Load() > 0 {
+				models = append(models, modelID)
+			}
+		}
+		processGroup.Unlock()
+	}
+	sort.Strings(models)
+	return models
+}
+
+// watchGPUThermal samples the GPUs every interval while models generate, until shutdown
+func (pm *ProxyManager) watchGPUThermal(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pm.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		models := pm.generatingModels()
+		if len(models) == 0 {
+			pm.gpuThermal.idle()
+			continue
+		}
+		pm.checkGPUThermal(models, interval)
+	}
+}
+
+// checkGPUThermal samples the GPUs while models generate. Throttling is added to
+// the activity stats of the models, and a GPU that starts throttling is warned
+// about with a GPUThrottlingEvent.
+func (pm *ProxyManager) checkGPUThermal(models []string, interval time.Duration) {
+	started, throttling := pm.gpuThermal.poll()
+	if !throttling {
+		return
+	}
+	for _, throttle := range started {
+		pm.proxyLogger.Warnf("GPU %d (%s) is throttling while generating %v, generation is slower: %s", throttle.GPU, throttle.Name, models, throttle.Reason)
+		event.Emit(GPUThrottlingEvent{GPU: throttle.GPU, Name: throttle.Name, Reason: throttle.Reason, Models: models})
+	}
+	if pm.metricsMonitor != nil && pm.metricsMonitor.ActivityStats != nil {
+		pm.metricsMonitor.ActivityStats.RecordThrottling(models, len(started) > 0, interval)
+	}
+}
+
+// apiGetGPUThermal handles GET /api/system/gpu-thermal
+func (pm *ProxyManager) apiGetGPUThermal(c *gin.Context) {
+	m := pm.gpuThermal
+	m.Lock()
+	samples := m.samples
+	if samples == nil {
+		samples = []autosetup.GPUThermalSample{}
+	}
+	throttled := make([]gpuThrottle, 0, len(m.throttled))
+	for _, throttle := range m.throttled {
+		throttled = append(throttled, throttle)
+	}
+	sort.Slice(throttled, func(i, j int) bool { return throttled[i].GPU < throttled[j].GPU })
+	response := gin.H{
+		"enabled":   pm.config.GPUThermal.Enabled,
+		"samples":   samples,
+		"throttled": throttled,
+	}
+	if !m.checkedAt.IsZero() {
+		response["checkedAt"] = m.checkedAt
+	}
+	if m.queryError != "" {
+		response["error"] = m.queryError
+	}
+	m.Unlock()
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,10 +1,15 @@
 package proxy
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"sync"
 	"testing"
 
@@ -40,7 +45,21 @@ func TestMain(m *testing.M) {
 		testLogger.SetLogLevel(LevelWarn)
 	}
 
-	m.Run()
+	// keep the files a ProxyManager writes out of the package directory
+	stateDir, err := os.MkdirTemp("", "frogllm-proxy-test")
+	if err != nil {
+		fmt.Printf("failed to create the test state directory: %v\n", err)
+		os.Exit(1)
+	}
+	SetFilePaths(FilePaths{
+		Settings:       filepath.Join(stateDir, "settings.json"),
+		FolderDatabase: filepath.Join(stateDir, "model_folders.json"),
+		ActivityStats:  filepath.Join(stateDir, "activity_stats.json"),
+	})
+
+	code := m.Run()
+	os.RemoveAll(stateDir)
+	os.Exit(code)
 }
 
 // Helper function to get the binary path
@@ -83,3 +102,122 @@ proxy: "http://127.0.0.1:%d"
 
 	return cfg
 }
+
+// writeTestGGUF writes a minimal GGUF file containing only metadata. Values
+// must be string or uint32. Keys are written in sorted order after general.architecture.
+func writeTestGGUF(t *testing.T, path string, metadata map[string]interface{}) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if key != "general.architecture" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if _, found := metadata["general.architecture"]; found {
+		keys = append([]string{"general.architecture"}, keys...)
+	}
+
+	write(uint32(0x46554747)) // GGUF magic
+	write(uint32(3))          // version
+	write(uint64(0))          // tensor count
+	write(uint64(len(keys)))
+
+	for _, key := range keys {
+		writeString(key)
+		switch value := metadata[key].(type) {
+		case string:
+			write(uint32(8))
+			writeString(value)
+		case uint32:
+			write(uint32(4))
+			write(value)
+		default:
+			t.Fatalf("unsupported GGUF test value for %s: %T", key, value)
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestProxyManager creates a ProxyManager whose event subscriptions end with
+// the test, so download events emitted by later tests don't reach it
+func newTestProxyManager(t *testing.T, config Config) *ProxyManager {
+	t.Helper()
+	pm := New(config)
+	t.Cleanup(pm.downloadSubCancel)
+	t.Cleanup(pm.webhookSubCancel)
+	return pm
+}
+
+// useTempFilePaths points the config, settings and folder database files and
+// the installed llama-server at a new temp dir until the test ends, and returns
+// the dir
+func useTempFilePaths(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	paths, binaryDir := CurrentFilePaths(), managedBinaryDir
+	t.Cleanup(func() {
+		SetFilePaths(paths)
+		managedBinaryDir = binaryDir
+	})
+
+	tempPaths := paths
+	tempPaths.Config = filepath.Join(dir, "config.yaml")
+	tempPaths.Settings = filepath.Join(dir, "settings.json")
+	tempPaths.FolderDatabase = filepath.Join(dir, "model_folders.json")
+	SetFilePaths(tempPaths)
+	managedBinaryDir = filepath.Join(dir, "binaries", "llama-server")
+	return dir
+}
+
+// fakeUpstreamCmd keeps running like llama-server while the requests go to a
+// fake upstream, flags after it are ignored
+const fakeUpstreamCmd = `sh -c "exec sleep 60"`
+
+// newFakeUpstream starts an in process stand-in for llama-server, closed when
+// the test ends. A nil handler answers every request with an empty 200.
+func newFakeUpstream(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	if handler == nil {
+		handler = func(w http.ResponseWriter, r *http.Request) {}
+	}
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// fakeUpstreamModel is a model served by a newFakeUpstream
+func fakeUpstreamModel(upstream *httptest.Server) ModelConfig {
+	return ModelConfig{Cmd: fakeUpstreamCmd, Proxy: upstream.URL, CheckEndpoint: "/health"}
+}
+
+// writeFakeLlamaServer writes an executable that keeps running like llama-server,
+// for tests that look up the binary or run a model cmd with its flags
+func writeFakeLlamaServer(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoadErrorCode classifies why a model could not be loaded
+type LoadErrorCode string
+
+const (
+	LoadErrorOutOfMemory          LoadErrorCode = "out_of_memory"
+	LoadErrorModelNotFound        LoadErrorCode = "model_not_found"
+	LoadErrorUnsupportedArch      LoadErrorCode = "unsupported_architecture"
+	LoadErrorPortInUse            LoadErrorCode = "port_in_use"
+	LoadErrorInvalidFlag          LoadErrorCode = "invalid_flag"
+	LoadErrorModelLoadFailed      LoadErrorCode = "model_load_failed"
+	LoadErrorBinaryNotFound       LoadErrorCode = "binary_not_found"
+	LoadErrorTimeout              LoadErrorCode = "timeout"
+	LoadErrorCrashed              LoadErrorCode = "crashed"
+	LoadErrorInsufficientMemory   LoadErrorCode = "insufficient_memory"
+	LoadErrorGPUUnhealthy         LoadErrorCode = "gpu_unhealthy"
+	LoadErrorArchiveRestoreFailed LoadErrorCode = "archive_restore_failed"
+	LoadErrorUnknown              LoadErrorCode = "unknown"
+)
+
+// LoadError is a classified model load failure
+type LoadError struct {
+	Code       LoadErrorCode `json:"code"`
+	Reason     string        `json:"reason"`     // e.g. "out of memory: <upstream log line>"
+	Suggestion string        `json:"suggestion"` // what the user can do about it
+	ExitCode   int           `json:"exitCode,omitempty"`
+	Err        error         `json:"-"`
+}
+
+func (e *LoadError) Error() string {
+	return e.Reason
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// loadFailurePatterns match llama-server output explaining a failed load, in order of precedence
+var loadFailurePatterns = []struct {
+	code       LoadErrorCode
+	reason     string
+	pattern    *regexp.Regexp
+	suggestion string
+}{
+	{LoadErrorOutOfMemory, "out of memory",
+		regexp.MustCompile(`(?i)out of memory|cudaMalloc failed|failed to allocate|unable to allocate|ErrorOutOfDeviceMemory`),
+		"lower -ngl or --ctx-size, use a smaller quantization or unload other models"},
+	{LoadErrorPortInUse, "port in use",
+		regexp.MustCompile(`(?i)address already in use|couldn't bind|could not bind|failed to bind`),
+		"another process holds the port, stop it or change startPort"},
+	{LoadErrorInvalidFlag, "invalid flag",
+		regexp.MustCompile(`(?i)invalid argument|unknown argument|error while handling argument|unrecognized option`),
+		"remove or fix the flag in the model's cmd, it may not be supported by this llama-server version"},
+	{LoadErrorUnsupportedArch, "unsupported model",
+		regexp.MustCompile(`(?i)unknown model architecture|unsupported model`),
+		"update llama-server, this build does not support the model's architecture"},
+	{LoadErrorModelNotFound, "model file not found",
+		regexp.MustCompile(`(?i)no such file or directory|failed to open|unable to open`),
+		"check the --model path, the file may have been moved or deleted"},
+	{LoadErrorModelLoadFailed, "model failed to load",
+		regexp.MustCompile(`(?i)failed to load model|error loading model|invalid magic`),
+		"the model file may be incomplete or corrupt, download it again"},
+}
+
+// classifyLoadFailure explains a failed start, preferring the upstream output line
+// that names the cause over the error of the health check and the exit code
+func classifyLoadFailure(err error, output string) *LoadError {
+	loadErr := &LoadError{Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		loadErr.ExitCode = exitErr.ExitCode()
+	}
+
+	lines := strings.Split(output, "\n")
+	for _, failure := range loadFailurePatterns {
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); failure.pattern.MatchString(line) {
+				if len(line) > 200 {
+					line = line[:200] + "..."
+				}
+				loadErr.Code, loadErr.Suggestion = failure.code, failure.suggestion
+				loadErr.Reason = failure.reason + ": " + line
+				return loadErr
+			}
+		}
+	}
+
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "executable file not found") || strings.Contains(message, "no such file or directory") ||
+		loadErr.ExitCode == 126 || loadErr.ExitCode == 127:
+		loadErr.Code, loadErr.Reason = LoadErrorBinaryNotFound, "binary not found: "+message
+		loadErr.Suggestion = "check the binary path in the cmd or reinstall llama-server"
+	case strings.Contains(message, "timed out") || strings.Contains(message, "did not open"):
+		loadErr.Code, loadErr.Reason = LoadErrorTimeout, "timed out: "+message
+		loadErr.Suggestion = "raise healthCheckTimeout for large models, or check the upstream log for why it hangs"
+	case exitErr != nil && killedBySignal(exitErr, syscall.SIGKILL):
+		loadErr.Code, loadErr.Reason = LoadErrorOutOfMemory, "killed: "+message
+		loadErr.Suggestion = "the system likely ran out of RAM, lower --ctx-size or use a smaller quantization"
+	case strings.Contains(message, "exited"):
+		loadErr.Code, loadErr.Reason = LoadErrorCrashed, "crashed: "+message
+		loadErr.Suggestion = "check the upstream log for the cause"
+	default:
+		loadErr.Code, loadErr.Reason = LoadErrorUnknown, message
+		loadErr.Suggestion = "check the upstream log for the cause"
+	}
+	return loadErr
+}
+
+func killedBySignal(exitErr *exec.ExitError, signal syscall.Signal) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == signal
+}
+
+// writeLoadError reports a failed start to the client, as JSON when it accepts it
+func writeLoadError(w http.ResponseWriter, r *http.Request, err error) {
+	message := fmt.Sprintf("unable to start process: %s", err)
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		http.Error(w, message, http.StatusBadGateway)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":      message,
+			"code":       string(loadErr.Code),
+			"suggestion": loadErr.Suggestion,
+		})
+		return
+	}
+	http.Error(w, fmt.Sprintf("%s\ncode: %s, suggestion: %s", message, loadErr.Code, loadErr.Suggestion), http.StatusBadGateway)
+}
+
+// sendSwapError reports a failed swapProcessGroup, with the code and suggestion of a *LoadError
+func (pm *ProxyManager) sendSwapError(c *gin.Context, err error) {
+	message := fmt.Sprintf("error swapping process group: %s", err.Error())
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) && strings.Contains(c.GetHeader("Accept"), "application/json") {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "code": loadErr.Code, "suggestion": loadErr.Suggestion})
+		return
+	}
+	pm.sendErrorResponse(c, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prave/FrogLLM/event"
+)
+
+// outputTailSize is how much of the latest upstream output is kept to explain a failed load
+const outputTailSize = 16 * 1024
+
+// outputTail keeps the last outputTailSize bytes written to it
+type outputTail struct {
+	sync.Mutex
+	buf []byte
+}
+
+func (t *outputTail) Write(b []byte) {
+	t.Lock()
+	defer t.Unlock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > outputTailSize {
+		t.buf = append([]byte(nil), t.buf[len(t.buf)-outputTailSize:]...)
+	}
+}
+
+func (t *outputTail) Reset() {
+	t.Lock()
+	t.buf = nil
+	t.Unlock()
+}
+
+func (t *outputTail) String() string {
+	t.Lock()
+	defer t.Unlock()
+	return string(t.buf)
+}
+
+func (p *Process) loadStarted() {
+	p.loadMutex.Lock()
+	defer p.loadMutex.Unlock()
+	p.loadStartedAt = time.Now()
+	p.loadError = nil
+	if p.outputTail != nil {
+		p.outputTail.Reset()
+	}
+}
+
+// loadFinished records why a load failed and returns the failure as a *LoadError,
+// a stop during the load is not a failure
+func (p *Process) loadFinished(err error) error {
+	p.loadMutex.Lock()
+	defer p.loadMutex.Unlock()
+	p.loadStartedAt = time.Time{}
+	if err == nil || errors.Is(err, ErrStartInterrupted) {
+		return err
+	}
+	output := ""
+	if p.outputTail != nil {
+		output = p.outputTail.String()
+	}
+	p.loadError = classifyLoadFailure(err, output)
+	p.proxyLogger.Warnf("<%s> Load failed (%s), %s", p.ID, p.loadError.Code, p.loadError.Reason)
+	event.Emit(ModelLoadFailedEvent{ModelName: p.ID, Reason: p.loadError.Reason, Code: p.loadError.Code})
+	return p.loadError
+}
+
+// loadStatus returns why the last load failed and how long the current load has been running
+func (p *Process) loadStatus() (loadError *LoadError, loading time.Duration) {
+	p.loadMutex.Lock()
+	defer p.loadMutex.Unlock()
+	if !p.loadStartedAt.IsZero() {
+		loading = time.Since(p.loadStartedAt)
+	}
+	return p.loadError, loading
+}
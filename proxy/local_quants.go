@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// matchesQuantization reports if a quantization label matches a requested
+// quantization, which may leave out the size suffix, e.g. q5_k matches Q5_K_M
+func matchesQuantization(label, requested string) bool {
+	requested = strings.ToUpper(requested)
+	return label == requested || strings.HasPrefix(label, requested+"_")
+}
+
+// repoDownloadDir returns the folder auto downloads of a HuggingFace repo are stored in
+func (pm *ProxyManager) repoDownloadDir(repo string) string {
+	baseDownloadDir := pm.config.DownloadDir
+	if baseDownloadDir == "" {
+		baseDownloadDir = "./downloads"
+	}
+	return filepath.Join(baseDownloadDir, strings.ReplaceAll(repo, "/", "_"))
+}
+
+// unfinishedDownloads returns the absolute paths of files the download manager
+// has not finished writing
+func (pm *ProxyManager) unfinishedDownloads() map[string]bool {
+	unfinished := make(map[string]bool)
+	for _, download := range pm.downloadManager.GetDownloads() {
+		if download.Status == StatusCompleted {
+			continue
+		}
+		if abs, err := filepath.Abs(download.FilePath); err == nil {
+			unfinished[abs] = true
+		}
+	}
+	return unfinished
+}
+
+// findBestLocalQuant returns the best complete GGUF model already downloaded
+// to downloadDir, limited to quantization when it is not empty. Vision
+// projectors, unfinished downloads and split models missing shards are skipped.
+func (pm *ProxyManager) findBestLocalQuant(downloadDir, quantization string) (string, bool) {
+	if _, err := os.Stat(downloadDir); err != nil {
+		return "", false
+	}
+	unfinished := pm.unfinishedDownloads()
+
+	preference := pm.quantPreference()
+	best := ""
+	bestRank := 0
+	filepath.Walk(downloadDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".gguf") {
+			return nil
+		}
+		label := quantizationLabel(info.Name())
+		if label == "mmproj" || info.Size() == 0 {
+			return nil
+		}
+		if quantization != "" && !matchesQuantization(label, quantization) {
+			return nil
+		}
+
+		// llama-server loads split models from the first shard
+		shards := []string{path}
+		if match := splitShardPattern.FindStringSubmatch(path); match != nil {
+			var count int
+			fmt.Sscanf(match[2], "%d", &count)
+			shards = shards[:0]
+			for i := 1; i <= count; i++ {
+				shards = append(shards, fmt.Sprintf("%s-%05d-of-%s.gguf", match[1], i, match[2]))
+			}
+			if shards[0] != path {
+				return nil
+			}
+		}
+		for _, shard := range shards {
+			abs, _ := filepath.Abs(shard)
+			if _, err := os.Stat(shard); err != nil || unfinished[abs] {
+				return nil
+			}
+		}
+
+		rank := preference.rank(label)
+		if best == "" || rank < bestRank {
+			best, bestRank = path, rank
+		}
+		return nil
+	})
+
+	return best, best != ""
+}
@@ -1,7 +1,7 @@
 package proxy
 
 import (
-	"container/ring"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -20,11 +20,21 @@ const (
 	LevelError
 )
 
+// defaultLogHistoryBytes is how much log history is kept for new UI connections
+// when logHistory.maxBytes is not set
+const defaultLogHistoryBytes = 1024 * 1024
+
 type LogMonitor struct {
 	eventbus *event.Dispatcher
 	mu       sync.RWMutex
-	buffer   *ring.Ring
-	bufferMu sync.RWMutex
+
+	// recent writes served by GetHistory, capped by historyMaxBytes and historyMaxLines
+	history         [][]byte
+	historyBytes    int
+	historyLines    int
+	historyMaxBytes int
+	historyMaxLines int // 0 is no line limit
+	bufferMu        sync.RWMutex
 
 	// typically this can be os.Stdout
 	stdout io.Writer
@@ -40,11 +50,71 @@ func NewLogMonitor() *LogMonitor {
 
 func NewLogMonitorWriter(stdout io.Writer) *LogMonitor {
 	return &LogMonitor{
-		eventbus: event.NewDispatcherConfig(1000),
-		buffer:   ring.New(10 * 1024), // keep 10KB of buffered logs
-		stdout:   stdout,
-		level:    LevelInfo,
-		prefix:   "",
+		eventbus:        event.NewDispatcherConfig(1000),
+		historyMaxBytes: defaultLogHistoryBytes,
+		stdout:          stdout,
+		level:           LevelInfo,
+		prefix:          "",
+	}
+}
+
+// SetHistoryLimit caps the history by bytes and lines, oldest output is dropped
+// first. maxBytes 0 uses the default of 1MB, maxLines 0 is no line limit.
+func (w *LogMonitor) SetHistoryLimit(maxBytes, maxLines int) {
+	if maxBytes <= 0 {
+		maxBytes = defaultLogHistoryBytes
+	}
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+	w.historyMaxBytes = maxBytes
+	w.historyMaxLines = maxLines
+	w.trimHistory()
+}
+
+// trimHistory drops the oldest output until the history is within its limits,
+// cutting at a line break where it can. Must be called with bufferMu held.
+func (w *LogMonitor) trimHistory() {
+	for len(w.history) > 0 {
+		excessBytes := w.historyBytes - w.historyMaxBytes
+		excessLines := 0
+		if w.historyMaxLines > 0 {
+			excessLines = w.historyLines - w.historyMaxLines
+		}
+		if excessBytes <= 0 && excessLines <= 0 {
+			return
+		}
+
+		first := w.history[0]
+		cut := 0
+		if excessBytes > 0 {
+			cut = min(excessBytes, len(first))
+			// drop the rest of a partially dropped line, unless it is all that is left
+			if cut < len(first) && first[cut-1] != '\n' {
+				if i := bytes.IndexByte(first[cut:], '\n'); i >= 0 {
+					cut += i + 1
+				} else if len(w.history) > 1 {
+					cut = len(first)
+				}
+			}
+		}
+		for i := 0; i < len(first) && excessLines > 0; i++ {
+			if first[i] == '\n' {
+				excessLines--
+				cut = max(cut, i+1)
+			}
+		}
+		if excessLines > 0 {
+			cut = len(first)
+		}
+
+		w.historyBytes -= cut
+		w.historyLines -= bytes.Count(first[:cut], []byte{'\n'})
+		if cut == len(first) {
+			w.history[0] = nil
+			w.history = w.history[1:]
+		} else {
+			w.history[0] = first[cut:]
+		}
 	}
 }
 
@@ -61,8 +131,10 @@ func (w *LogMonitor) Write(p []byte) (n int, err error) {
 	w.bufferMu.Lock()
 	bufferCopy := make([]byte, len(p))
 	copy(bufferCopy, p)
-	w.buffer.Value = bufferCopy
-	w.buffer = w.buffer.Next()
+	w.history = append(w.history, bufferCopy)
+	w.historyBytes += len(bufferCopy)
+	w.historyLines += bytes.Count(bufferCopy, []byte{'\n'})
+	w.trimHistory()
 	w.bufferMu.Unlock()
 
 	w.broadcast(bufferCopy)
@@ -74,13 +146,9 @@ func (w *LogMonitor) GetHistory() []byte {
 	defer w.bufferMu.RUnlock()
 
 	var history []byte
-	w.buffer.Do(func(p any) {
-		if p != nil {
-			if content, ok := p.([]byte); ok {
-				history = append(history, content...)
-			}
-		}
-	})
+	for _, content := range w.history {
+		history = append(history, content...)
+	}
 	return history
 }
 
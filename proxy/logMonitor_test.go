@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -84,3 +86,43 @@ func TestWrite_ImmutableBuffer(t *testing.T) {
 		t.Errorf("Expected history to be %q, got %q", expected, history)
 	}
 }
+
+func TestLogMonitor_HistoryIsCapped(t *testing.T) {
+	lm := NewLogMonitorWriter(io.Discard)
+	lm.SetHistoryLimit(64, 0)
+	for i := 0; i < 1000; i++ {
+		lm.Write([]byte(fmt.Sprintf("line %03d\n", i)))
+	}
+	history := string(lm.GetHistory())
+	if len(history) > 64 {
+		t.Errorf("expected at most 64 bytes of history, got %d: %q", len(history), history)
+	}
+	if !strings.HasSuffix(history, "line 999\n") || !strings.HasPrefix(history, "line ") {
+		t.Errorf("expected the most recent whole lines, got %q", history)
+	}
+
+	// the tail of a single write larger than the limit is kept
+	lm.Write(bytes.Repeat([]byte("x"), 100))
+	if history := lm.GetHistory(); len(history) != 64 {
+		t.Errorf("expected 64 bytes of history, got %d", len(history))
+	}
+
+	// a line limit cuts inside a write with several lines
+	lm = NewLogMonitorWriter(io.Discard)
+	lm.SetHistoryLimit(0, 3)
+	lm.Write([]byte("a\nb\n"))
+	lm.Write([]byte("c\nd\ne\n"))
+	if history := string(lm.GetHistory()); history != "c\nd\ne\n" {
+		t.Errorf("expected the last 3 lines, got %q", history)
+	}
+	lm.Write([]byte("f\n"))
+	if history := string(lm.GetHistory()); history != "d\ne\nf\n" {
+		t.Errorf("expected the last 3 lines, got %q", history)
+	}
+
+	// lowering the limit trims the existing history
+	lm.SetHistoryLimit(0, 1)
+	if history := string(lm.GetHistory()); history != "f\n" {
+		t.Errorf("expected the last line, got %q", history)
+	}
+}
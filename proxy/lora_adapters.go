@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+	"gopkg.in/yaml.v3"
+)
+
+// LoRAAdapterConfig is a LoRA adapter applied to a model when it loads
+type LoRAAdapterConfig struct {
+	Path string `yaml:"path" json:"path"`
+
+	// strength of the adapter, 0 applies it at full strength like 1
+	Scale float64 `yaml:"scale,omitempty" json:"scale,omitempty"`
+
+	// a disabled adapter stays in the config but is not applied
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// loraAdapterArg returns the llama-server flag that applies an adapter
+func loraAdapterArg(adapter LoRAAdapterConfig) string {
+	path := adapter.Path
+	if strings.ContainsAny(path, " \t'\"") {
+		path = `"` + strings.ReplaceAll(path, `"`, `\"`) + `"`
+	}
+	if adapter.Scale == 0 || adapter.Scale == 1 {
+		return "--lora " + path
+	}
+	return "--lora-scaled " + path + " " + strconv.FormatFloat(adapter.Scale, 'f', -1, 64)
+}
+
+// withLoRAAdapters adds the flags of the enabled adapters to a cmd after checking
+// their files
+func withLoRAAdapters(cmd string, adapters []LoRAAdapterConfig) (string, error) {
+	var args []string
+	for _, adapter := range adapters {
+		if adapter.Disabled {
+			continue
+		}
+		if adapter.Scale < 0 {
+			return "", fmt.Errorf("loraAdapters %s: scale must not be negative", adapter.Path)
+		}
+		info, err := os.Stat(adapter.Path)
+		if err != nil {
+			return "", fmt.Errorf("loraAdapters %s: %v", adapter.Path, err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("loraAdapters %s is a directory", adapter.Path)
+		}
+		args = append(args, loraAdapterArg(adapter))
+	}
+	if len(args) == 0 {
+		return cmd, nil
+	}
+	return strings.TrimRight(cmd, "\n") + "\n" + strings.Join(args, "\n"), nil
+}
+
+// enabledLoRAPaths returns the files of the adapters applied to the model
+func (m ModelConfig) enabledLoRAPaths() []string {
+	var paths []string
+	for _, adapter := range m.LoRAAdapters {
+		if !adapter.Disabled {
+			paths = append(paths, adapter.Path)
+		}
+	}
+	return paths
+}
+
+// apiSetModelLoRA handles POST /api/models/:id/lora. It enables or disables a
+// LoRA adapter of the model, adding it to loraAdapters the first time, and
+// reloads the config so the model restarts with the adapters.
+func (pm *ProxyManager) apiSetModelLoRA(c *gin.Context) {
+	var req struct {
+		Path    string   `json:"path"`
+		Enabled *bool    `json:"enabled"`
+		Scale   *float64 `json:"scale"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	path := strings.TrimSpace(req.Path)
+	if path == "" || req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path and enabled are required"})
+		return
+	}
+	if req.Scale != nil && *req.Scale < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scale must not be negative"})
+		return
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	var adapters []LoRAAdapterConfig
+	err := pm.updateModelFieldsInConfig(modelID, func(model *yaml.Node) error {
+		var raw struct {
+			LoRAAdapters []LoRAAdapterConfig `yaml:"loraAdapters"`
+		}
+		if err := model.Decode(&raw); err != nil {
+			return err
+		}
+
+		adapters = raw.LoRAAdapters
+		index := -1
+		for i, adapter := range adapters {
+			if adapter.Path == path {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			if !*req.Enabled {
+				return &configEditError{http.StatusNotFound, fmt.Errorf("LoRA adapter not attached to %s: %s", modelID, path)}
+			}
+			if !autosetup.IsLoRAAdapter(path) {
+				return fmt.Errorf("Not a LoRA adapter GGUF: %s", path)
+			}
+			adapters = append(adapters, LoRAAdapterConfig{Path: path})
+			index = len(adapters) - 1
+		}
+		adapters[index].Disabled = !*req.Enabled
+		if req.Scale != nil {
+			adapters[index].Scale = *req.Scale
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(adapters); err != nil {
+			return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to encode LoRA adapters: %v", err)}
+		}
+		setMappingField(model, "loraAdapters", value)
+		return nil
+	})
+	if err != nil {
+		c.JSON(configEditStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	pm.proxyLogger.Infof("Set model %s LoRA adapter %s enabled=%t", modelID, path, *req.Enabled)
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":        modelID,
+		"loraAdapters": adapters,
+	})
+}
@@ -61,6 +61,8 @@ func MetricsMiddleware(pm *ProxyManager) gin.HandlerFunc {
 					writer.metricsRecorder.realModelName = resolvedName
 				}
 
+				writer.finishGeneration()
+
 				// Process the response metrics
 				if strings.Contains(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
 					writer.metricsRecorder.processStreamingResponse(writer.body)
@@ -84,6 +86,7 @@ func MetricsMiddleware(pm *ProxyManager) gin.HandlerFunc {
 		}
 		c.Writer = writer
 		c.Next()
+		writer.finishGeneration()
 
 		// check for streaming response
 		if strings.Contains(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
@@ -95,10 +98,22 @@ func MetricsMiddleware(pm *ProxyManager) gin.HandlerFunc {
 }
 
 func (rec *MetricsRecorder) parseAndRecordMetrics(jsonData gjson.Result) bool {
+	metric, ok := parseTokenMetrics(jsonData, rec.startTime)
+	if !ok {
+		return false
+	}
+	metric.Model = rec.realModelName
+	rec.metricsMonitor.addMetrics(metric)
+	return true
+}
+
+// parseTokenMetrics reads the token counts and speeds of a response from its
+// usage and llama-server's timings, false when it has neither
+func parseTokenMetrics(jsonData gjson.Result, startTime time.Time) (TokenMetrics, bool) {
 	usage := jsonData.Get("usage")
 	timings := jsonData.Get("timings")
 	if !usage.Exists() && !timings.Exists() {
-		return false
+		return TokenMetrics{}, false
 	}
 
 	// default values
@@ -109,7 +124,7 @@ func (rec *MetricsRecorder) parseAndRecordMetrics(jsonData gjson.Result) bool {
 	// timings data
 	tokensPerSecond := -1.0
 	promptPerSecond := -1.0
-	durationMs := int(time.Since(rec.startTime).Milliseconds())
+	durationMs := int(time.Since(startTime).Milliseconds())
 
 	if usage.Exists() {
 		outputTokens = int(jsonData.Get("usage.completion_tokens").Int())
@@ -129,21 +144,27 @@ func (rec *MetricsRecorder) parseAndRecordMetrics(jsonData gjson.Result) bool {
 		}
 	}
 
-	rec.metricsMonitor.addMetrics(TokenMetrics{
+	return TokenMetrics{
 		Timestamp:       time.Now(),
-		Model:           rec.realModelName,
 		CachedTokens:    cachedTokens,
 		InputTokens:     inputTokens,
 		OutputTokens:    outputTokens,
 		PromptPerSecond: promptPerSecond,
 		TokensPerSecond: tokensPerSecond,
 		DurationMs:      durationMs,
-	})
-
-	return true
+	}, true
 }
 
 func (rec *MetricsRecorder) processStreamingResponse(body []byte) {
+	if metric, ok := streamTokenMetrics(body, rec.startTime); ok {
+		metric.Model = rec.realModelName
+		rec.metricsMonitor.addMetrics(metric)
+	}
+}
+
+// streamTokenMetrics reads the token metrics of a streamed response from the
+// last event that has usage or timings
+func streamTokenMetrics(body []byte, startTime time.Time) (TokenMetrics, bool) {
 	// Iterate **backwards** through the lines looking for the data payload with
 	// usage data
 	lines := bytes.Split(body, []byte("\n"))
@@ -171,11 +192,12 @@ func (rec *MetricsRecorder) processStreamingResponse(body []byte) {
 		}
 
 		if gjson.ValidBytes(data) {
-			if rec.parseAndRecordMetrics(gjson.ParseBytes(data)) {
-				return // short circuit if a metric was recorded
+			if metric, ok := parseTokenMetrics(gjson.ParseBytes(data), startTime); ok {
+				return metric, true
 			}
 		}
 	}
+	return TokenMetrics{}, false
 }
 
 func (rec *MetricsRecorder) processNonStreamingResponse(body []byte) {
@@ -194,6 +216,7 @@ type MetricsResponseWriter struct {
 	gin.ResponseWriter
 	body            []byte
 	metricsRecorder *MetricsRecorder
+	generation      *generationTracker // set once the response is a stream
 }
 
 func (w *MetricsResponseWriter) Write(b []byte) (int, error) {
@@ -202,9 +225,24 @@ func (w *MetricsResponseWriter) Write(b []byte) (int, error) {
 		return n, err
 	}
 	w.body = append(w.body, b...)
+
+	if w.generation == nil && strings.Contains(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.generation = newGenerationTracker(w.metricsRecorder.realModelName)
+	}
+	if w.generation != nil {
+		w.generation.observe(w.body)
+	}
 	return n, nil
 }
 
+// finishGeneration reports the end of a streaming generation to generation-stream clients
+func (w *MetricsResponseWriter) finishGeneration() {
+	if w.generation != nil {
+		w.generation.observe(w.body)
+		w.generation.emit(true)
+	}
+}
+
 func (w *MetricsResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
@@ -2,7 +2,7 @@ package proxy
 
 import (
 	"encoding/json"
-	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -31,7 +31,9 @@ func (e TokenMetricsEvent) Type() uint32 {
 	return TokenMetricsEventID // defined in events.go
 }
 
-// MetricsMonitor parses llama-server output for token statistics
+// MetricsMonitor parses llama-server output for token statistics. mu only
+// guards the metrics kept in memory, events and activity stats are recorded
+// without holding it so concurrent requests don't wait on each other.
 type MetricsMonitor struct {
 	mu            sync.RWMutex
 	metrics       []TokenMetrics
@@ -41,39 +43,41 @@ type MetricsMonitor struct {
 }
 
 func NewMetricsMonitor(config *Config, configPath string) *MetricsMonitor {
-	maxMetrics := config.MetricsMaxInMemory
-	if maxMetrics <= 0 {
-		maxMetrics = 1000 // Default fallback
-	}
-
-	// Use a consistent path for activity stats - in the same directory as the config
-	// or in the current working directory if not specified
-	statsPath := "activity_stats.json"
-	if configPath != "" {
-		// Place activity stats in the same directory as the config file
-		dir := filepath.Dir(configPath)
-		statsPath = filepath.Join(dir, "activity_stats.json")
-	}
 
 	mp := &MetricsMonitor{
-		maxMetrics:    maxMetrics,
-		ActivityStats: NewActivityStatsManager(statsPath),
+		ActivityStats: NewActivityStatsManager(activityStatsPath(configPath)),
 	}
+	mp.setMaxMetrics(config.MetricsMaxInMemory)
 
 	return mp
 }
 
-// addMetrics adds a new metric to the collection and publishes an event
-func (mp *MetricsMonitor) addMetrics(metric TokenMetrics) {
+// setMaxMetrics changes how many metrics are kept in memory, e.g. when a
+// config profile with a different metricsMaxInMemory is activated
+func (mp *MetricsMonitor) setMaxMetrics(maxMetrics int) {
+	if maxMetrics <= 0 {
+		maxMetrics = 1000 // Default fallback
+	}
+
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
+	mp.maxMetrics = maxMetrics
+	if len(mp.metrics) > maxMetrics {
+		mp.metrics = mp.metrics[len(mp.metrics)-maxMetrics:]
+	}
+}
 
+// addMetrics adds a new metric to the collection and publishes an event
+func (mp *MetricsMonitor) addMetrics(metric TokenMetrics) {
+	mp.mu.Lock()
 	metric.ID = mp.nextID
 	mp.nextID++
 	mp.metrics = append(mp.metrics, metric)
 	if len(mp.metrics) > mp.maxMetrics {
 		mp.metrics = mp.metrics[len(mp.metrics)-mp.maxMetrics:]
 	}
+	mp.mu.Unlock()
+
 	event.Emit(TokenMetricsEvent{Metrics: metric})
 
 	// Record to persistent activity stats
@@ -99,7 +103,40 @@ func (mp *MetricsMonitor) GetMetrics() []TokenMetrics {
 
 // GetMetricsJSON returns metrics as JSON
 func (mp *MetricsMonitor) GetMetricsJSON() ([]byte, error) {
-	mp.mu.RLock()
-	defer mp.mu.RUnlock()
-	return json.Marshal(mp.metrics)
+	return json.Marshal(mp.GetMetrics())
+}
+
+// metricsBatchInterval is the aggregation window of the event stream: metrics
+// recorded within it are sent together as one message, so a burst of requests
+// sends at most one metrics message per window to each client
+var metricsBatchInterval = 250 * time.Millisecond
+
+// metricsBatchLimit caps the metrics a batch holds, the oldest are dropped
+// when a client falls further behind
+const metricsBatchLimit = 1000
+
+// metricsBatch collects the metrics recorded since a client was last sent them
+type metricsBatch struct {
+	mu      sync.Mutex
+	pending []TokenMetrics
+}
+
+func (b *metricsBatch) add(metric TokenMetrics) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, metric)
+	if len(b.pending) > metricsBatchLimit {
+		b.pending = b.pending[len(b.pending)-metricsBatchLimit:]
+	}
+}
+
+// take returns the pending metrics ordered by ID and empties the batch
+func (b *metricsBatch) take() []TokenMetrics {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	// metrics recorded concurrently can arrive out of order
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return pending
 }
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"fmt"
+)
+
+// ensureMinFreeVRAM keeps minFreeVRAMGB of VRAM free for the desktop and other
+// programs. A load that would leave less, going by the size of the model's GGUF
+// files, first unloads the non-persistent models of other groups and is refused
+// when that is not enough. The check is skipped when VRAM can't be measured or
+// the model is already loaded.
+func (pm *ProxyManager) ensureMinFreeVRAM(group *ProcessGroup, modelName string) error {
+	minFreeGB := pm.config.MinFreeVRAMGB
+	if minFreeGB <= 0 {
+		return nil
+	}
+	if group.processState(modelName) == StateReady {
+		return nil
+	}
+
+	freeGB, _, err := pm.measureVRAM()
+	if err != nil {
+		pm.proxyLogger.Debugf("Not checking minFreeVRAMGB, VRAM can't be measured: %v", err)
+		return nil
+	}
+	modelGB := float64(modelFileSize(pm.config.Models[modelName])) / (1024 * 1024 * 1024)
+	if freeGB-modelGB >= minFreeGB {
+		return nil
+	}
+
+	pm.proxyLogger.Infof("Loading %s (%.1fGB) would leave %.1fGB of VRAM free, below minFreeVRAMGB %.1fGB, unloading models...",
+		modelName, modelGB, freeGB-modelGB, minFreeGB)
+
+	unloadedCount := 0
+	for groupId, otherGroup := range pm.processGroups {
+		if groupId == group.id || otherGroup.persistent || pm.runningModelsGB([]*ProcessGroup{otherGroup}) == 0 {
+			continue
+		}
+		pm.stopGroupsForLoad([]*ProcessGroup{otherGroup}, StopImmediately)
+		unloadedCount++
+
+		if freeGB, _, err = pm.measureVRAM(); err == nil && freeGB-modelGB >= minFreeGB {
+			pm.proxyLogger.Infof("Unloaded %d models to keep %.1fGB of VRAM free", unloadedCount, minFreeGB)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("loading %s (%.1fGB) would leave %.1fGB of VRAM free after unloading %d models, minFreeVRAMGB is %.1fGB",
+		modelName, modelGB, freeGB-modelGB, unloadedCount, minFreeGB)
+}
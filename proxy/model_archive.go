@@ -0,0 +1,334 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchivedModel records where the files of an archived model were moved to. The
+// model keeps its config entry and is restored when it is next loaded.
+type ArchivedModel struct {
+	ModelID    string            `json:"modelId"`
+	Files      map[string]string `json:"files"` // original path -> path in the archive
+	SizeBytes  int64             `json:"sizeBytes"`
+	ArchivedAt time.Time         `json:"archivedAt"`
+}
+
+// modelArchiveDatabase is stored in the archive folder next to the files it tracks
+type modelArchiveDatabase struct {
+	Models  map[string]ArchivedModel `json:"models"`
+	Version string                   `json:"version"`
+}
+
+func (pm *ProxyManager) archiveDir() string {
+	if pm.config.ArchiveDir == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(pm.config.ArchiveDir); err == nil {
+		return abs
+	}
+	return pm.config.ArchiveDir
+}
+
+func (pm *ProxyManager) getModelArchiveDatabasePath() string {
+	return filepath.Join(pm.archiveDir(), "archived_models.json")
+}
+
+// loadModelArchiveDatabase reads the archive database, empty when no model was archived yet
+func (pm *ProxyManager) loadModelArchiveDatabase() (*modelArchiveDatabase, error) {
+	db := &modelArchiveDatabase{Models: map[string]ArchivedModel{}, Version: "1.0"}
+	if pm.archiveDir() == "" {
+		return db, nil
+	}
+
+	data, err := os.ReadFile(pm.getModelArchiveDatabasePath())
+	if os.IsNotExist(err) {
+		return db, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, err
+	}
+	if db.Models == nil {
+		db.Models = map[string]ArchivedModel{}
+	}
+	return db, nil
+}
+
+func (pm *ProxyManager) saveModelArchiveDatabase(db *modelArchiveDatabase) error {
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pm.getModelArchiveDatabasePath(), data, 0644)
+}
+
+// archivedModels returns the archived models by ID, logging a database that can't be read
+func (pm *ProxyManager) archivedModels() map[string]ArchivedModel {
+	db, err := pm.loadModelArchiveDatabase()
+	if err != nil {
+		pm.proxyLogger.Warnf("Failed to load model archive database: %v", err)
+		return map[string]ArchivedModel{}
+	}
+	return db.Models
+}
+
+// archiveModelFiles returns the --model file of a model and, for a split model,
+// the other shards next to it
+func (pm *ProxyManager) archiveModelFiles(modelConfig ModelConfig) ([]string, error) {
+	modelPath := pm.extractModelPathFromCmd(modelConfig.Cmd)
+	if modelPath == "" {
+		return nil, fmt.Errorf("cmd has no --model/-m argument")
+	}
+	modelPath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, fmt.Errorf("model file %s not found", modelPath)
+	}
+
+	files := []string{modelPath}
+	if match := splitShardPattern.FindStringSubmatch(modelPath); match != nil {
+		shards, _ := filepath.Glob(fmt.Sprintf("%s-*-of-%s.gguf", match[1], match[2]))
+		sort.Strings(shards)
+		files = shards
+	}
+	return files, nil
+}
+
+// moveFile renames src to dst, copying when they are on different file systems.
+// The copy is written next to dst first so a partial file is never picked up.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".partial"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// modelStopped reports if no process of the model is running or starting
+func (pm *ProxyManager) modelStopped(modelID string) bool {
+	processGroup := pm.findGroupByModelName(modelID)
+	if processGroup == nil {
+		return true
+	}
+	processGroup.Lock()
+	defer processGroup.Unlock()
+	process, found := processGroup.processes[modelID]
+	if !found {
+		return true
+	}
+	state := process.CurrentState()
+	return state == StateStopped || state == StateShutdown
+}
+
+// archiveModel moves the files of a stopped model into the archive folder
+func (pm *ProxyManager) archiveModel(modelID string) (ArchivedModel, error) {
+	pm.archiveMu.Lock()
+	defer pm.archiveMu.Unlock()
+
+	if pm.archiveDir() == "" {
+		return ArchivedModel{}, fmt.Errorf("archiveDir is not configured")
+	}
+	db, err := pm.loadModelArchiveDatabase()
+	if err != nil {
+		return ArchivedModel{}, err
+	}
+	if _, found := db.Models[modelID]; found {
+		return ArchivedModel{}, fmt.Errorf("model %s is already archived", modelID)
+	}
+	if !pm.modelStopped(modelID) {
+		return ArchivedModel{}, fmt.Errorf("model %s is running, unload it first", modelID)
+	}
+
+	files, err := pm.archiveModelFiles(pm.config.Models[modelID])
+	if err != nil {
+		return ArchivedModel{}, err
+	}
+
+	// files shared with another model would go missing for that one too
+	for otherID, otherConfig := range pm.config.Models {
+		if _, archived := db.Models[otherID]; otherID == modelID || archived {
+			continue
+		}
+		referenced := make(map[string]bool)
+		for _, file := range modelFilesFromCmd(otherConfig) {
+			referenced[file] = true
+		}
+		for _, file := range files {
+			if isReferencedModelFile(file, referenced) {
+				return ArchivedModel{}, fmt.Errorf("%s is also used by model %s", file, otherID)
+			}
+		}
+	}
+
+	archived := ArchivedModel{ModelID: modelID, Files: map[string]string{}, ArchivedAt: time.Now()}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			archived.SizeBytes += info.Size()
+		}
+		archivePath := filepath.Join(pm.archiveDir(), modelID, filepath.Base(file))
+		if err := moveFile(file, archivePath); err != nil {
+			// put back what was moved so the model still loads
+			for original, moved := range archived.Files {
+				if restoreErr := moveFile(moved, original); restoreErr != nil {
+					pm.proxyLogger.Errorf("Failed to move %s back to %s: %v", moved, original, restoreErr)
+				}
+			}
+			return ArchivedModel{}, fmt.Errorf("failed to archive %s: %v", file, err)
+		}
+		archived.Files[file] = archivePath
+	}
+
+	db.Models[modelID] = archived
+	if err := pm.saveModelArchiveDatabase(db); err != nil {
+		return ArchivedModel{}, fmt.Errorf("files moved to %s but the archive database was not saved: %v", pm.archiveDir(), err)
+	}
+	pm.proxyLogger.Infof("Archived model %s to %s", modelID, filepath.Join(pm.archiveDir(), modelID))
+	return archived, nil
+}
+
+// restoreArchivedModel moves the files of an archived model back to where its cmd
+// expects them. It does nothing for models that are not archived.
+func (pm *ProxyManager) restoreArchivedModel(modelID string) error {
+	if pm.archiveDir() == "" {
+		return nil
+	}
+	pm.archiveMu.Lock()
+	defer pm.archiveMu.Unlock()
+
+	db, err := pm.loadModelArchiveDatabase()
+	if err != nil {
+		return err
+	}
+	archived, found := db.Models[modelID]
+	if !found {
+		return nil
+	}
+
+	pm.proxyLogger.Infof("Restoring archived model %s", modelID)
+	start := time.Now()
+	for original, archivePath := range archived.Files {
+		if _, err := os.Stat(original); err == nil {
+			// restored before, e.g. copied back by hand
+			continue
+		}
+		if err := moveFile(archivePath, original); err != nil {
+			return fmt.Errorf("failed to restore %s from %s: %v", original, archivePath, err)
+		}
+	}
+	os.Remove(filepath.Join(pm.archiveDir(), modelID))
+
+	delete(db.Models, modelID)
+	if err := pm.saveModelArchiveDatabase(db); err != nil {
+		return err
+	}
+	pm.proxyLogger.Infof("Restored archived model %s in %v", modelID, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// restoreBeforeLoad restores an archived model as part of swapProcessGroup
+func (pm *ProxyManager) restoreBeforeLoad(modelID string) error {
+	if err := pm.restoreArchivedModel(modelID); err != nil {
+		return &LoadError{
+			Code:       LoadErrorArchiveRestoreFailed,
+			Reason:     fmt.Sprintf("restore from archive failed: %v", err),
+			Suggestion: "check that archiveDir is reachable and the disk has room for the model",
+			Err:        err,
+		}
+	}
+	return nil
+}
+
+// apiArchiveModel handles POST /api/models/:id/archive
+func (pm *ProxyManager) apiArchiveModel(c *gin.Context) {
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	if !pm.modelStopped(modelID) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("model %s is running, unload it first", modelID)})
+		return
+	}
+
+	archived, err := pm.archiveModel(modelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, archived)
+}
+
+// apiRestoreModel handles POST /api/models/:id/restore, restoring without loading the model
+func (pm *ProxyManager) apiRestoreModel(c *gin.Context) {
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+	if _, archived := pm.archivedModels()[modelID]; !archived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("model %s is not archived", modelID)})
+		return
+	}
+
+	if err := pm.restoreArchivedModel(modelID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "ok", "model": modelID})
+}
+
+// apiGetArchivedModels handles GET /api/models/archived
+func (pm *ProxyManager) apiGetArchivedModels(c *gin.Context) {
+	db, err := pm.loadModelArchiveDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model archive database: " + err.Error()})
+		return
+	}
+
+	models := make([]ArchivedModel, 0, len(db.Models))
+	for _, archived := range db.Models {
+		models = append(models, archived)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ModelID < models[j].ModelID })
+	c.JSON(http.StatusOK, gin.H{"archiveDir": pm.archiveDir(), "models": models})
+}
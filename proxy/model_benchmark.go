@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+const (
+	defaultBenchmarkPromptTokens = 512
+	defaultBenchmarkMaxTokens    = 128
+	maxBenchmarkPromptTokens     = 32768
+	maxBenchmarkMaxTokens        = 4096
+)
+
+// benchmarkWords make up the benchmark prompt, common words that are a single
+// token each with most tokenizers
+var benchmarkWords = strings.Fields("the quick brown fox jumps over the lazy dog and then runs into the green forest")
+
+// ModelBenchmark is the result of a standardized generation run against a model.
+// The binary version and cmd are kept so runs before and after an update or a
+// config change can be compared.
+type ModelBenchmark struct {
+	ModelID                   string    `json:"modelId"`
+	PromptTokens              int       `json:"promptTokens"`
+	GeneratedTokens           int       `json:"generatedTokens"`
+	PromptTokensPerSecond     float64   `json:"promptTokensPerSecond"`
+	GenerationTokensPerSecond float64   `json:"generationTokensPerSecond"`
+	TTFTMs                    int       `json:"ttftMs"`
+	TotalMs                   int       `json:"totalMs"`
+	BinaryVersion             string    `json:"binaryVersion,omitempty"`
+	Cmd                       string    `json:"cmd"`
+	RanAt                     time.Time `json:"ranAt"`
+}
+
+// modelBenchmarkDatabase keeps the last benchmark of each model
+type modelBenchmarkDatabase struct {
+	Models  map[string]ModelBenchmark `json:"models"`
+	Version string                    `json:"version"`
+}
+
+func (pm *ProxyManager) getModelBenchmarkDatabasePath() string {
+	return "model_benchmarks.json"
+}
+
+// loadModelBenchmarkDatabase reads the benchmark database, empty when no model was benchmarked yet
+func (pm *ProxyManager) loadModelBenchmarkDatabase() (*modelBenchmarkDatabase, error) {
+	db := &modelBenchmarkDatabase{Models: map[string]ModelBenchmark{}, Version: "1.0"}
+	data, err := os.ReadFile(pm.getModelBenchmarkDatabasePath())
+	if os.IsNotExist(err) {
+		return db, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, err
+	}
+	if db.Models == nil {
+		db.Models = map[string]ModelBenchmark{}
+	}
+	return db, nil
+}
+
+func (pm *ProxyManager) saveModelBenchmarkDatabase(db *modelBenchmarkDatabase) error {
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pm.getModelBenchmarkDatabasePath(), data, 0644)
+}
+
+// benchmarkPrompt is roughly promptTokens tokens long
+func benchmarkPrompt(promptTokens int) string {
+	words := make([]string, promptTokens)
+	for i := range words {
+		words[i] = benchmarkWords[i%len(benchmarkWords)]
+	}
+	return strings.Join(words, " ")
+}
+
+// benchmarkResponseWriter collects a streamed response and notes when its first
+// event arrived, which is the time to first token
+type benchmarkResponseWriter struct {
+	header     http.Header
+	status     int
+	body       bytes.Buffer
+	firstEvent time.Time
+}
+
+func (w *benchmarkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchmarkResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *benchmarkResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.firstEvent.IsZero() && bytes.Contains(b, []byte("data:")) {
+		w.firstEvent = time.Now()
+	}
+	return w.body.Write(b)
+}
+
+func (w *benchmarkResponseWriter) Flush() {}
+
+// runBenchmarkRequest sends a streamed completion request to a model of group
+func runBenchmarkRequest(group *ProcessGroup, modelID string, payload gin.H) (*benchmarkResponseWriter, time.Time, error) {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, "/v1/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = int64(len(body))
+
+	w := &benchmarkResponseWriter{header: make(http.Header)}
+	start := time.Now()
+	if err := group.ProxyRequest(modelID, w, req); err != nil {
+		return nil, start, err
+	}
+	if w.status != http.StatusOK {
+		return nil, start, fmt.Errorf("upstream returned %d: %s", w.status, strings.TrimSpace(w.body.String()))
+	}
+	return w, start, nil
+}
+
+// benchmarkModel loads a model if needed and runs the standardized generation
+// against it. Speeds come from llama-server's timings, like the metrics of
+// regular requests, and from the wall clock when the upstream has none.
+func (pm *ProxyManager) benchmarkModel(modelID string, promptTokens, maxTokens int) (ModelBenchmark, error) {
+	group, _, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		return ModelBenchmark{}, err
+	}
+
+	// load the model first so loading isn't counted
+	loadReq, _ := http.NewRequest("GET", "/", nil)
+	if err := group.ProxyRequest(modelID, &DiscardWriter{}, loadReq); err != nil {
+		return ModelBenchmark{}, err
+	}
+
+	w, start, err := runBenchmarkRequest(group, modelID, gin.H{
+		"model":          modelID,
+		"prompt":         benchmarkPrompt(promptTokens),
+		"max_tokens":     maxTokens,
+		"stream":         true,
+		"stream_options": gin.H{"include_usage": true},
+		"temperature":    0,
+		"cache_prompt":   false,
+		"ignore_eos":     true,
+	})
+	if err != nil {
+		return ModelBenchmark{}, err
+	}
+	total := time.Since(start)
+	ttft := total
+	if !w.firstEvent.IsZero() {
+		ttft = w.firstEvent.Sub(start)
+	}
+
+	metric, ok := streamTokenMetrics(w.body.Bytes(), start)
+	if !ok {
+		return ModelBenchmark{}, fmt.Errorf("response has no usage or timings")
+	}
+	metric.Model = modelID
+	if pm.metricsMonitor != nil {
+		pm.metricsMonitor.addMetrics(metric)
+	}
+
+	promptPerSecond := metric.PromptPerSecond
+	if promptPerSecond < 0 && ttft > 0 {
+		promptPerSecond = float64(metric.InputTokens) / ttft.Seconds()
+	}
+	generationPerSecond := metric.TokensPerSecond
+	if generationPerSecond < 0 && total > ttft {
+		generationPerSecond = float64(metric.OutputTokens) / (total - ttft).Seconds()
+	}
+
+	pm.Lock()
+	cmd := pm.config.Models[modelID].Cmd
+	pm.Unlock()
+	result := ModelBenchmark{
+		ModelID:                   modelID,
+		PromptTokens:              metric.InputTokens,
+		GeneratedTokens:           metric.OutputTokens,
+		PromptTokensPerSecond:     max(promptPerSecond, 0),
+		GenerationTokensPerSecond: max(generationPerSecond, 0),
+		TTFTMs:                    int(ttft.Milliseconds()),
+		TotalMs:                   int(total.Milliseconds()),
+		Cmd:                       cmd,
+		RanAt:                     time.Now(),
+	}
+	if metadata, err := autosetup.LoadBinaryMetadata(filepath.Join("binaries", "llama-server")); err == nil {
+		result.BinaryVersion = metadata.Version
+	}
+	return result, nil
+}
+
+// apiBenchmarkModel handles POST /api/models/:id/benchmark. It runs a generation
+// with promptTokens of prompt and maxTokens of output, returns prompt processing
+// and generation speed and time to first token, and keeps the result so the next
+// run can be compared with it.
+func (pm *ProxyManager) apiBenchmarkModel(c *gin.Context) {
+	var req struct {
+		PromptTokens int `json:"promptTokens"`
+		MaxTokens    int `json:"maxTokens"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+			return
+		}
+	}
+	if req.PromptTokens == 0 {
+		req.PromptTokens = defaultBenchmarkPromptTokens
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultBenchmarkMaxTokens
+	}
+	if req.PromptTokens < 1 || req.PromptTokens > maxBenchmarkPromptTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("promptTokens must be between 1 and %d", maxBenchmarkPromptTokens)})
+		return
+	}
+	if req.MaxTokens < 1 || req.MaxTokens > maxBenchmarkMaxTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("maxTokens must be between 1 and %d", maxBenchmarkMaxTokens)})
+		return
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	pm.proxyLogger.Infof("Benchmarking %s with %d prompt and %d generated tokens", modelID, req.PromptTokens, req.MaxTokens)
+	result, err := pm.benchmarkModel(modelID, req.PromptTokens, req.MaxTokens)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "benchmark failed: " + err.Error()})
+		return
+	}
+
+	response := gin.H{"benchmark": result}
+	db, err := pm.loadModelBenchmarkDatabase()
+	if err != nil {
+		pm.proxyLogger.Warnf("Failed to load model benchmark database: %v", err)
+	} else {
+		if previous, ok := db.Models[modelID]; ok {
+			response["previous"] = previous
+		}
+		db.Models[modelID] = result
+		if err := pm.saveModelBenchmarkDatabase(db); err != nil {
+			pm.proxyLogger.Warnf("Failed to save model benchmark database: %v", err)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// apiGetModelBenchmark handles GET /api/models/:id/benchmark, the last benchmark of a model
+func (pm *ProxyManager) apiGetModelBenchmark(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	db, err := pm.loadModelBenchmarkDatabase()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load benchmarks: " + err.Error()})
+		return
+	}
+	result, ok := db.Models[modelID]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model has not been benchmarked: " + modelID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"benchmark": result})
+}
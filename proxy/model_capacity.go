@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// llama-server's defaults when a cmd does not set --ctx-size or --parallel
+const (
+	defaultServerContextSize = 4096
+	defaultServerParallel    = 1
+)
+
+// cmdIntFlag returns the value of the first of names set in args, as --name value
+// or --name=value, and 0 when none is set or the value is not a number
+func cmdIntFlag(args []string, names ...string) int {
+	for i, arg := range args {
+		for _, name := range names {
+			value := ""
+			if arg == name && i+1 < len(args) {
+				value = args[i+1]
+			} else if strings.HasPrefix(arg, name+"=") {
+				value = strings.TrimPrefix(arg, name+"=")
+			} else {
+				continue
+			}
+			n, _ := strconv.Atoi(value)
+			return n
+		}
+	}
+	return 0
+}
+
+// apiGetModelCapacity handles GET /api/models/:id/capacity. llama-server splits
+// --ctx-size evenly between its --parallel slots, so the number of sequences that
+// fit is the VRAM left after the weights and overhead divided by the KV cache of
+// one slot. Available VRAM is detected unless given via ?vram=<GB>.
+func (pm *ProxyManager) apiGetModelCapacity(c *gin.Context) {
+	pm.Lock()
+	realModelName, found := pm.config.RealModelName(c.Param("id"))
+	var modelConfig ModelConfig
+	if found {
+		modelConfig, _, _ = pm.config.FindConfig(realModelName)
+	}
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model %s not found", c.Param("id"))})
+		return
+	}
+
+	modelPath := pm.resolveModelPath(realModelName)
+	if modelPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("could not find a model file for %s", realModelName)})
+		return
+	}
+
+	estimator := autosetup.NewMemoryEstimator()
+	memInfo, err := estimator.GetModelMemoryInfo(modelPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read model memory info: %v", err)})
+		return
+	}
+	estimator.AddLoRAAdapters(memInfo, modelConfig.enabledLoRAPaths())
+	metadata, err := autosetup.ReadGGUFMetadata(modelPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read model metadata: %v", err)})
+		return
+	}
+
+	availableVRAM, vramSource, ok := pm.requestedVRAM(c, estimator)
+	if !ok {
+		return
+	}
+
+	args, _ := modelConfig.SanitizedCommand()
+	contextSize := cmdIntFlag(args, "--ctx-size", "-c")
+	if contextSize <= 0 {
+		contextSize = defaultServerContextSize
+	}
+	parallel := cmdIntFlag(args, "--parallel", "-np")
+	if parallel <= 0 {
+		parallel = defaultServerParallel
+	}
+	perSequenceContext := contextSize / parallel
+
+	configured := estimator.CalculateMemoryForContext(memInfo, contextSize, metadata.BlockCount)
+	perSequence := estimator.CalculateMemoryForContext(memInfo, perSequenceContext, metadata.BlockCount)
+
+	// every slot full at once, see slot_memory.go
+	worstCase := memInfo.ModelSizeGB + memInfo.LoRASizeGB + estimator.OverheadGB + perSequence.KVCacheGB*float64(parallel)
+
+	kvBudget := availableVRAM - memInfo.ModelSizeGB - memInfo.LoRASizeGB - estimator.OverheadGB
+	maxConcurrent := 0
+	if kvBudget > 0 && perSequence.KVCacheGB > 0 {
+		maxConcurrent = int(math.Floor(kvBudget / perSequence.KVCacheGB))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":                  realModelName,
+		"contextSize":            contextSize,
+		"parallel":               parallel,
+		"perSequenceContext":     perSequenceContext,
+		"kvCachePerSequenceGB":   perSequence.KVCacheGB,
+		"kvCacheGB":              configured.KVCacheGB,
+		"totalVRAMGB":            configured.TotalMemoryGB,
+		"fitsAvailable":          configured.TotalMemoryGB <= availableVRAM,
+		"worstCaseVRAMGB":        worstCase,
+		"slotsFitAvailable":      worstCase <= availableVRAM,
+		"modelSizeGB":            memInfo.ModelSizeGB,
+		"loraSizeGB":             memInfo.LoRASizeGB,
+		"overheadGB":             estimator.OverheadGB,
+		"availableVRAMGB":        availableVRAM,
+		"vramSource":             vramSource,
+		"kvBudgetGB":             math.Max(kvBudget, 0),
+		"maxConcurrentSequences": maxConcurrent,
+	})
+}
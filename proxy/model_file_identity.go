@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// resolvedModelPath returns the absolute path of a model file with symlinks
+// resolved, or just the absolute path when the file can't be resolved
+func resolvedModelPath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolved
+	}
+	return absPath
+}
+
+// sameModelFile reports if two paths name the same file, through symlinks or
+// hardlinks. Paths that don't exist are compared by their absolute path.
+func sameModelFile(a, b string) bool {
+	resolvedA, resolvedB := resolvedModelPath(a), resolvedModelPath(b)
+	if resolvedA == resolvedB {
+		return true
+	}
+	infoA, errA := os.Stat(resolvedA)
+	infoB, errB := os.Stat(resolvedB)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// modelsBySameFile groups model IDs by the file their path names, sorted by model
+// ID within each group. Models reaching one file through different symlinks or
+// hardlinks end up in the same group.
+func modelsBySameFile(modelPaths map[string]string) [][]string {
+	modelIDs := make([]string, 0, len(modelPaths))
+	for modelID := range modelPaths {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	var groups [][]string
+	var groupFiles []string
+	for _, modelID := range modelIDs {
+		path := modelPaths[modelID]
+		found := false
+		for i, file := range groupFiles {
+			if sameModelFile(file, path) {
+				groups[i] = append(groups[i], modelID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []string{modelID})
+			groupFiles = append(groupFiles, path)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// members returns the group's model IDs, sorted, including processes added after the group was created
+func (pg *ProcessGroup) members() []string {
+	seen := make(map[string]bool)
+	if groupConfig, exists := pg.config.Groups[pg.id]; exists {
+		for _, member := range groupConfig.Members {
+			seen[member] = true
+		}
+	}
+	pg.Lock()
+	for modelID := range pg.processes {
+		seen[modelID] = true
+	}
+	pg.Unlock()
+
+	members := make([]string, 0, len(seen))
+	for modelID := range seen {
+		members = append(members, modelID)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// apiGetModelGroup handles GET /api/models/:id/group. It returns the model's group,
+// the group's swap/exclusive/persistent policy, its other members and the models
+// loading this one unloads, so clients can explain why one model replaces another.
+func (pm *ProxyManager) apiGetModelGroup(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	var group *ProcessGroup
+	var otherGroups []*ProcessGroup
+	if found {
+		group = pm.findGroupByModelName(modelID)
+		for _, otherGroup := range pm.processGroups {
+			if otherGroup != group {
+				otherGroups = append(otherGroups, otherGroup)
+			}
+		}
+	}
+	pm.Unlock()
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model %s is not in a group", modelID)})
+		return
+	}
+
+	siblings := []string{}
+	for _, member := range group.members() {
+		if member != modelID {
+			siblings = append(siblings, member)
+		}
+	}
+
+	// swapping unloads the other members, an exclusive group also unloads
+	// every group that is not persistent
+	unloads := []string{}
+	if group.swap {
+		unloads = append(unloads, siblings...)
+	}
+	if group.exclusive {
+		for _, otherGroup := range otherGroups {
+			if otherGroup.persistent {
+				continue
+			}
+			unloads = append(unloads, otherGroup.members()...)
+		}
+	}
+	sort.Strings(unloads)
+
+	// seconds the model stays loaded before a swap may unload it, see swap_cooldown.go
+	cooldownRemaining := 0.0
+	group.Lock()
+	if process := group.processes[modelID]; process != nil {
+		cooldownRemaining = process.cooldownRemaining(swapCooldownDuration(group.config)).Seconds()
+	}
+	group.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":             modelID,
+		"group":             group.id,
+		"swap":              group.swap,
+		"exclusive":         group.exclusive,
+		"persistent":        group.persistent,
+		"sharedProcess":     group.sharedProcess,
+		"siblings":          siblings,
+		"unloads":           unloads,
+		"swapCooldown":      group.config.SwapCooldown,
+		"cooldownRemaining": cooldownRemaining,
+	})
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// default model count limits, configs beyond a few hundred models make every
+// config load, reload and model listing noticeably slower
+const (
+	defaultModelWarnLimit = 500
+	defaultModelMaxLimit  = 2000
+)
+
+// ModelLimitsConfig caps how many models a config holds
+type ModelLimitsConfig struct {
+	Warn int `yaml:"warn"` // warn at load above this many models, 0 never warns
+	Max  int `yaml:"max"`  // adding or generating models beyond this fails, 0 is no limit
+}
+
+// modelLimitAdvice is what a user at the limit can do about it
+const modelLimitAdvice = "prune models that are no longer used (GET /api/models/orphans helps find unused files), " +
+	"split the config into profiles, or raise modelLimits.max"
+
+// modelLimitWarnings warns about a config that holds more models than the limits
+func modelLimitWarnings(config Config) []string {
+	count := len(config.Models)
+	limits := config.ModelLimits
+	if limits.Max > 0 && count > limits.Max {
+		return []string{fmt.Sprintf("config has %d models, more than modelLimits.max of %d, no models can be added until some are removed: %s",
+			count, limits.Max, modelLimitAdvice)}
+	}
+	if limits.Warn > 0 && count > limits.Warn {
+		return []string{fmt.Sprintf("config has %d models, more than modelLimits.warn of %d, large configs slow down loading and reloading: %s",
+			count, limits.Warn, modelLimitAdvice)}
+	}
+	return nil
+}
+
+// errModelLimit is returned when adding models would go beyond modelLimits.max
+var errModelLimit = errors.New("model limit reached")
+
+// checkModelLimit returns an error when adding added models to a config with
+// count models goes beyond the hard limit
+func checkModelLimit(limits ModelLimitsConfig, count, added int) error {
+	if limits.Max > 0 && count+added > limits.Max {
+		return fmt.Errorf("%w: config would have %d models, more than modelLimits.max of %d: %s", errModelLimit, count+added, limits.Max, modelLimitAdvice)
+	}
+	return nil
+}
+
+// modelLimitStatus is the model count against the limits for the health report
+func (pm *ProxyManager) modelLimitStatus() map[string]int {
+	pm.Lock()
+	defer pm.Unlock()
+	return map[string]int{
+		"count": len(pm.config.Models),
+		"warn":  pm.config.ModelLimits.Warn,
+		"max":   pm.config.ModelLimits.Max,
+	}
+}
@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelLogsConfig tees the stdout and stderr of each model's upstream process to
+// a log file per model, kept on disk for debugging after the in memory history
+// has moved on
+type ModelLogsConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Dir       string `yaml:"dir"`       // default "logs"
+	MaxSizeMB int    `yaml:"maxSizeMB"` // size a log file is rotated at, default 10
+	MaxFiles  int    `yaml:"maxFiles"`  // rotated files kept per model, default 3
+}
+
+func (m ModelLogsConfig) dir() string {
+	if m.Dir == "" {
+		return "logs"
+	}
+	return m.Dir
+}
+
+func (m ModelLogsConfig) maxBytes() int64 {
+	if m.MaxSizeMB <= 0 {
+		return 10 * 1024 * 1024
+	}
+	return int64(m.MaxSizeMB) * 1024 * 1024
+}
+
+func (m ModelLogsConfig) maxFiles() int {
+	if m.MaxFiles <= 0 {
+		return 3
+	}
+	return m.MaxFiles
+}
+
+// modelLogFileName keeps model IDs like org/model:q4 from becoming directories
+// or invalid file names
+var modelLogFileName = strings.NewReplacer("/", "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_")
+
+// path is the log file of a model, rotated files have .1, .2, ... appended
+func (m ModelLogsConfig) path(modelID string) string {
+	path := filepath.Join(m.dir(), modelLogFileName.Replace(modelID)+".log")
+	if absPath, err := filepath.Abs(path); err == nil {
+		return absPath
+	}
+	return path
+}
+
+// newModelLogFile returns the log file of a model, nil when model logs are off
+func newModelLogFile(config ModelLogsConfig, modelID string) *modelLogFile {
+	if !config.Enabled {
+		return nil
+	}
+	return &modelLogFile{
+		path:     config.path(modelID),
+		maxBytes: config.maxBytes(),
+		maxFiles: config.maxFiles(),
+	}
+}
+
+// modelLogFile is an append only log file rotated by size. It is opened on the
+// first write and closed when the process exits. Writes never fail, a broken
+// log file must not break the pipe of the upstream process.
+type modelLogFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	err      error // last open error, served by the logs endpoint
+}
+
+func (l *modelLogFile) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil && l.size > 0 && l.size+int64(len(b)) > l.maxBytes {
+		l.rotate()
+	}
+	if l.file == nil && !l.open() {
+		return len(b), nil
+	}
+	n, _ := l.file.Write(b)
+	l.size += int64(n)
+	return len(b), nil
+}
+
+// open must be called with mu held
+func (l *modelLogFile) open() bool {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		l.err = err
+		return false
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		l.err = err
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		l.err = err
+		return false
+	}
+	l.file, l.size, l.err = file, info.Size(), nil
+	return true
+}
+
+// rotate shifts model.log to model.log.1, model.log.1 to model.log.2 and so on,
+// dropping the oldest. Must be called with mu held.
+func (l *modelLogFile) rotate() {
+	l.file.Close()
+	l.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	os.Rename(l.path, l.path+".1")
+}
+
+// start marks the start of a run of the process, so runs can be told apart
+func (l *modelLogFile) start(modelID string, args []string) {
+	fmt.Fprintf(l, "\n=== %s starting %s: %s ===\n", time.Now().Format(time.RFC3339), modelID, strings.Join(args, " "))
+}
+
+// Close closes the file, the next write opens it again
+func (l *modelLogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// openError is the error the file last failed to open with, nil when it is fine
+func (l *modelLogFile) openError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// rotated lists the rotated files of the log that exist, newest first
+func (l *modelLogFile) rotated() []string {
+	files := []string{}
+	for i := 1; i <= l.maxFiles; i++ {
+		path := fmt.Sprintf("%s.%d", l.path, i)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// apiGetModelLogs handles GET /api/models/:id/logs, the recent output of a model's
+// upstream process and where its log file is when modelLogs is enabled
+func (pm *ProxyManager) apiGetModelLogs(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	logsConfig := pm.config.ModelLogs
+	var process *Process
+	if found {
+		if processGroup := pm.findGroupByModelName(modelID); processGroup != nil {
+			process = processGroup.processes[modelID]
+		}
+	}
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	response := gin.H{
+		"modelId": modelID,
+		"enabled": logsConfig.Enabled,
+		"output":  "",
+	}
+	if process != nil && process.outputTail != nil {
+		response["output"] = process.outputTail.String()
+	}
+	if process != nil && process.logFile != nil {
+		response["path"] = process.logFile.path
+		response["rotated"] = process.logFile.rotated()
+		if err := process.logFile.openError(); err != nil {
+			response["error"] = err.Error()
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -1,8 +1,10 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"sort"
 	"sync"
 	"time"
@@ -108,12 +110,20 @@ func (pm *ProxyManager) apiV1LoadModel(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "model_id is required"})
 		return
 	}
+	if !pm.modelIDAllowed(c, req.ModelID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key is not permitted to access model %s", req.ModelID)})
+		return
+	}
 
 	// Check if model needs to be downloaded first
 	modelPath := pm.resolveModelPath(req.ModelID)
 	if modelPath == "" && req.AutoUnload {
 		// Try to download the model
 		if err := pm.autoDownloadModel(c, req.ModelID); err != nil {
+			if errors.Is(err, errAutoDownloadNotAllowed) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": fmt.Sprintf("Model %s not found locally and download failed: %v", req.ModelID, err),
 			})
@@ -137,6 +147,9 @@ func (pm *ProxyManager) apiV1LoadModel(c *gin.Context) {
 				if modelToUnload == req.ModelID {
 					continue // Don't unload the model we're trying to load
 				}
+				if !pm.unloadAllowed(c, modelToUnload) {
+					continue // a scoped key only unloads its own models
+				}
 
 				modelVRAM := pm.getModelVRAMUsage(modelToUnload)
 				if err := pm.unloadSpecificModel(modelToUnload); err == nil {
@@ -181,6 +194,10 @@ func (pm *ProxyManager) apiV1UnloadModel(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "model_id is required"})
 		return
 	}
+	if !pm.modelIDAllowed(c, req.ModelID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key is not permitted to access model %s", req.ModelID)})
+		return
+	}
 
 	if err := pm.unloadSpecificModel(req.ModelID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -247,10 +264,21 @@ func (pm *ProxyManager) apiV1GetLoadedModels(c *gin.Context) {
 // Helper methods that need to be implemented in ProxyManager
 
 func (pm *ProxyManager) resolveModelPath(modelID string) string {
-	// Check if model exists locally
-	// This should check config and filesystem
+	// Check if model exists locally by looking at the --model argument of its cmd
 	// Return empty string if not found
-	return ""
+	modelConfig, _, found := pm.config.FindConfig(modelID)
+	if !found {
+		return ""
+	}
+
+	modelPath := pm.extractModelPathFromCmd(modelConfig.Cmd)
+	if modelPath == "" {
+		return ""
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return ""
+	}
+	return modelPath
 }
 
 func (pm *ProxyManager) estimateModelVRAM(modelID string) float64 {
@@ -278,6 +306,29 @@ func (pm *ProxyManager) loadSpecificModel(modelID string) error {
 	return fmt.Errorf("not implemented")
 }
 
+// unloadAllowed reports if the request's API key may use every model that
+// unloadSpecificModel stops for modelID
+func (pm *ProxyManager) unloadAllowed(c *gin.Context, modelID string) bool {
+	if apiKeyScope(c) == nil {
+		return true
+	}
+	pm.Lock()
+	defer pm.Unlock()
+
+	processGroup, exists := pm.processGroups[modelID]
+	if !exists {
+		return true
+	}
+	processGroup.Lock()
+	defer processGroup.Unlock()
+	for member := range processGroup.processes {
+		if !pm.allowedModel(c, member) {
+			return false
+		}
+	}
+	return true
+}
+
 func (pm *ProxyManager) unloadSpecificModel(modelID string) error {
 	// Unload a specific model
 	// This should integrate with existing model unloading logic
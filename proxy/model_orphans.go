@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrphanModel is a GGUF file in a download or tracked folder that no configured model uses
+type OrphanModel struct {
+	Path      string    `json:"path"`
+	Folder    string    `json:"folder"`
+	SizeBytes int64     `json:"sizeBytes"`
+	ModTime   time.Time `json:"modTime"`
+}
+
+// cmd flags whose value is a file used by the model
+var modelFileFlags = map[string]bool{
+	"-m":               true,
+	"--model":          true,
+	"-mm":              true,
+	"--mmproj":         true,
+	"-md":              true,
+	"--model-draft":    true,
+	"--lora":           true,
+	"--lora-scaled":    true,
+	"--control-vector": true,
+}
+
+// matches the first shard of a split GGUF, e.g. model-00001-of-00003.gguf
+var splitShardPattern = regexp.MustCompile(`^(.+)-\d{5}-of-(\d{5})\.gguf$`)
+
+// modelFilesFromCmd returns the absolute paths of the files referenced by a model's cmd
+func modelFilesFromCmd(modelConfig ModelConfig) []string {
+	args, err := modelConfig.SanitizedCommand()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for i, arg := range args {
+		var value string
+		if modelFileFlags[arg] && i+1 < len(args) {
+			value = args[i+1]
+		} else if flag, v, found := strings.Cut(arg, "="); found && modelFileFlags[flag] {
+			value = v
+		}
+		if value == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(value); err == nil {
+			files = append(files, abs)
+		}
+	}
+	return files
+}
+
+// isReferencedModelFile reports if path is in referenced, or is a shard of a
+// referenced split model since llama-server loads all shards from the first one
+func isReferencedModelFile(path string, referenced map[string]bool) bool {
+	if referenced[path] {
+		return true
+	}
+
+	match := splitShardPattern.FindStringSubmatch(path)
+	if match == nil {
+		return false
+	}
+	firstShard := fmt.Sprintf("%s-00001-of-%s.gguf", match[1], match[2])
+	return referenced[firstShard]
+}
+
+// orphanFolders returns the download folder and all enabled tracked folders
+// of the folder database at foldersPath, the only places orphaned models are
+// looked for and deleted from. The value reports if sub folders are included.
+func (pm *ProxyManager) orphanFolders(foldersPath string) map[string]bool {
+	// downloads are stored in per repo sub folders
+	folders := map[string]bool{pm.downloadFolder(): true}
+
+	db, err := readModelFolderDatabase(foldersPath)
+	if err != nil {
+		pm.proxyLogger.Warnf("Failed to load model folder database: %v", err)
+		return folders
+	}
+	for _, folder := range db.Folders {
+		if !folder.Enabled {
+			continue
+		}
+		if abs, err := filepath.Abs(folder.Path); err == nil {
+			// non recursive folders are not tracked below the top level
+			folders[abs] = folders[abs] || folder.Recursive
+		}
+	}
+	return folders
+}
+
+// findOrphanModels lists the GGUF files in the orphan folders not referenced by
+// any model. Models are read from the config file at configPath as well as the
+// running config, the file may have models that are not loaded yet.
+func (pm *ProxyManager) findOrphanModels(configPath, foldersPath string) ([]OrphanModel, error) {
+	referenced := make(map[string]bool)
+	addReferenced := func(models map[string]ModelConfig) {
+		for _, modelConfig := range models {
+			for _, file := range modelFilesFromCmd(modelConfig) {
+				// a cmd may reach the file through a symlink
+				referenced[file] = true
+				referenced[resolvedModelPath(file)] = true
+			}
+		}
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %v", configPath, err)
+		}
+		addReferenced(config.Models)
+	}
+	pm.Lock()
+	addReferenced(pm.config.Models)
+	pm.Unlock()
+
+	seen := make(map[string]bool)
+	orphans := []OrphanModel{}
+	for folder, recursive := range pm.orphanFolders(foldersPath) {
+		if _, err := os.Stat(folder); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Continue walking even if there's an error
+			}
+			if info.IsDir() {
+				if path != folder && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(strings.ToLower(info.Name()), ".gguf") || seen[path] {
+				return nil
+			}
+			seen[path] = true
+
+			if isReferencedModelFile(path, referenced) || isReferencedModelFile(resolvedModelPath(path), referenced) {
+				return nil
+			}
+			orphans = append(orphans, OrphanModel{
+				Path:      path,
+				Folder:    folder,
+				SizeBytes: info.Size(),
+				ModTime:   info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Path < orphans[j].Path
+	})
+	return orphans, nil
+}
+
+// downloadFolder returns the absolute path of the download folder
+func (pm *ProxyManager) downloadFolder() string {
+	downloadDir := pm.config.DownloadDir
+	if downloadDir == "" {
+		downloadDir = "./downloads"
+	}
+	if abs, err := filepath.Abs(downloadDir); err == nil {
+		return abs
+	}
+	return downloadDir
+}
+
+// runningModelFiles returns the files used by processes that are not stopped
+func (pm *ProxyManager) runningModelFiles() map[string]bool {
+	pm.Lock()
+	defer pm.Unlock()
+
+	files := make(map[string]bool)
+	for _, processGroup := range pm.processGroups {
+		processGroup.Lock()
+		for _, process := range processGroup.processes {
+			if state := process.CurrentState(); state == StateStopped || state == StateShutdown {
+				continue
+			}
+			for _, file := range modelFilesFromCmd(process.config) {
+				files[file] = true
+			}
+		}
+		processGroup.Unlock()
+	}
+	return files
+}
+
+// apiGetOrphanModels handles GET /api/models/orphans
+func (pm *ProxyManager) apiGetOrphanModels(c *gin.Context) {
+	orphans, err := pm.findOrphanModels(pm.configPath, pm.getModelFolderDatabasePath())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to scan for orphaned models: %v", err)})
+		return
+	}
+
+	var totalBytes int64
+	for _, orphan := range orphans {
+		totalBytes += orphan.SizeBytes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orphans":    orphans,
+		"count":      len(orphans),
+		"totalBytes": totalBytes,
+		"totalGB":    float64(totalBytes) / (1024 * 1024 * 1024),
+	})
+}
+
+// apiDeleteOrphanModels handles POST /api/models/orphans/delete. Only files
+// that are currently orphaned are deleted, anything else is reported as skipped.
+func (pm *ProxyManager) apiDeleteOrphanModels(c *gin.Context) {
+	var req struct {
+		Paths   []string `json:"paths"`
+		Confirm bool     `json:"confirm"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if len(req.Paths) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "paths is required"})
+		return
+	}
+	if !req.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm must be true to delete model files"})
+		return
+	}
+
+	orphans, err := pm.findOrphanModels(pm.configPath, pm.getModelFolderDatabasePath())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to scan for orphaned models: %v", err)})
+		return
+	}
+	orphanSizes := make(map[string]int64, len(orphans))
+	for _, orphan := range orphans {
+		orphanSizes[orphan.Path] = orphan.SizeBytes
+	}
+	running := pm.runningModelFiles()
+
+	deleted := []string{}
+	skipped := []gin.H{}
+	var freedBytes int64
+	for _, path := range req.Paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			skipped = append(skipped, gin.H{"path": path, "reason": "invalid path"})
+			continue
+		}
+
+		size, isOrphan := orphanSizes[absPath]
+		switch {
+		case !isOrphan:
+			skipped = append(skipped, gin.H{"path": path, "reason": "not an orphaned model in a tracked folder"})
+			continue
+		case isReferencedModelFile(absPath, running):
+			skipped = append(skipped, gin.H{"path": path, "reason": "model file is loaded"})
+			continue
+		}
+
+		if err := os.Remove(absPath); err != nil {
+			skipped = append(skipped, gin.H{"path": path, "reason": err.Error()})
+			continue
+		}
+		pm.proxyLogger.Infof("Deleted orphaned model file: %s", absPath)
+		deleted = append(deleted, absPath)
+		freedBytes += size
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted":    deleted,
+		"skipped":    skipped,
+		"freedBytes": freedBytes,
+	})
+}
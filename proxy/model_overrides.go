@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateModelOverrides requires each custom override to have flags and to match
+// by architecture or name, so one entry can't add flags to every model
+func (s *SystemSettings) validateModelOverrides() error {
+	for i, override := range s.ModelOverrides {
+		if strings.TrimSpace(override.Architecture) == "" && strings.TrimSpace(override.NameContains) == "" {
+			return fmt.Errorf("modelOverrides[%d] needs an architecture or nameContains", i)
+		}
+		if len(override.Args) == 0 {
+			return fmt.Errorf("modelOverrides[%d] needs args", i)
+		}
+		for _, arg := range override.Args {
+			if strings.TrimSpace(arg) == "" || strings.Contains(arg, "\n") {
+				return fmt.Errorf("modelOverrides[%d] has an empty or multi-line arg", i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+	"gopkg.in/yaml.v3"
+)
+
+// cmdFlagChange is a flag that re-analysis added, removed or changed in a cmd.
+// Flags without a value have an empty Before or After.
+type cmdFlagChange struct {
+	Flag   string `json:"flag"`
+	Change string `json:"change"` // added, removed or changed
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// cmdFlags returns the flags of a cmd with their values, in order. Arguments
+// before the first flag, the binary or a macro, are left out.
+func cmdFlags(cmd string) ([]string, map[string]string) {
+	args, err := SanitizeCommand(cmd)
+	if err != nil {
+		return nil, nil
+	}
+	var order []string
+	values := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		if !hasValue && i+1 < len(args) && !isCmdFlag(args[i+1]) {
+			value = args[i+1]
+			i++
+		}
+		if _, seen := values[flag]; !seen {
+			order = append(order, flag)
+		}
+		values[flag] = value
+	}
+	return order, values
+}
+
+// isCmdFlag reports if an argument is a flag rather than a value like -1
+func isCmdFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+// diffCmdFlags lists the flags that differ between two cmds
+func diffCmdFlags(before, after string) []cmdFlagChange {
+	beforeOrder, beforeValues := cmdFlags(before)
+	afterOrder, afterValues := cmdFlags(after)
+
+	changes := []cmdFlagChange{}
+	for _, flag := range beforeOrder {
+		afterValue, kept := afterValues[flag]
+		switch {
+		case !kept:
+			changes = append(changes, cmdFlagChange{Flag: flag, Change: "removed", Before: beforeValues[flag]})
+		case afterValue != beforeValues[flag]:
+			changes = append(changes, cmdFlagChange{Flag: flag, Change: "changed", Before: beforeValues[flag], After: afterValue})
+		}
+	}
+	for _, flag := range afterOrder {
+		if _, existed := beforeValues[flag]; !existed {
+			changes = append(changes, cmdFlagChange{Flag: flag, Change: "added", After: afterValues[flag]})
+		}
+	}
+	return changes
+}
+
+// setMissingMacrosInYAML adds the macros a generated cmd refers to that the
+// config YAML doesn't define yet. Existing macros are left as they are.
+func setMissingMacrosInYAML(node *yaml.Node, macros map[string]interface{}) {
+	if len(macros) == 0 || node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
+		return
+	}
+	rootNode := node.Content[0]
+	if rootNode.Kind != yaml.MappingNode {
+		return
+	}
+
+	var macrosNode *yaml.Node
+	for i := 0; i < len(rootNode.Content); i += 2 {
+		if rootNode.Content[i].Value == "macros" && rootNode.Content[i+1].Kind == yaml.MappingNode {
+			macrosNode = rootNode.Content[i+1]
+			break
+		}
+	}
+	if macrosNode == nil {
+		macrosNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		rootNode.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "macros"},
+			macrosNode,
+		}, rootNode.Content...)
+	}
+
+	existing := make(map[string]bool)
+	for i := 0; i < len(macrosNode.Content); i += 2 {
+		existing[macrosNode.Content[i].Value] = true
+	}
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		macrosNode.Content = append(macrosNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(macros[name])},
+		)
+	}
+}
+
+// apiReanalyzeModel handles POST /api/models/:id/reanalyze. It re-reads the
+// model's GGUF, generates its cmd with the same SMART settings as auto-setup
+// and writes only that cmd into the config, keeping the rest of the entry and
+// the file's layout. The config is backed up first.
+func (pm *ProxyManager) apiReanalyzeModel(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	modelConfig, _, _ := pm.config.FindConfig(modelID)
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	modelPath := pm.extractModelPathFromCmd(modelConfig.Cmd)
+	if modelPath == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Model " + modelID + " has no --model in its cmd"})
+		return
+	}
+	absModelPath, err := filepath.Abs(modelPath)
+	if err != nil || !pm.fileExists(absModelPath) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model file not found: " + modelPath})
+		return
+	}
+
+	options := autosetup.SetupOptions{
+		EnableJinja:      true,
+		ThroughputFirst:  true,
+		MinContext:       16384,
+		PreferredContext: 32768,
+	}
+	models, err := autosetup.DetectModelsWithOptions(filepath.Dir(absModelPath), options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to analyze model: %v", err)})
+		return
+	}
+	var targetModel *autosetup.ModelInfo
+	for i, model := range models {
+		if path, err := filepath.Abs(model.Path); err == nil && path == absModelPath {
+			targetModel = &models[i]
+			break
+		}
+	}
+	if targetModel == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Not a valid GGUF model file: " + absModelPath})
+		return
+	}
+
+	generated, macros, err := pm.generateSmartModelConfig(*targetModel, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	generatedConfig, _ := generated["config"].(map[string]interface{})
+	newCmd, _ := generatedConfig["cmd"].(string)
+	if newCmd == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "generated model config has no cmd"})
+		return
+	}
+
+	var oldCmd, backupPath string
+	var changes []cmdFlagChange
+	err = pm.updateConfigFile(func(doc *yaml.Node) error {
+		model, err := modelNodeInYAML(doc, modelID)
+		if err != nil {
+			return err
+		}
+		var raw struct {
+			Cmd string `yaml:"cmd"`
+		}
+		if err := model.Decode(&raw); err != nil {
+			return err
+		}
+		oldCmd = raw.Cmd
+
+		changes = diffCmdFlags(oldCmd, newCmd)
+		if len(changes) == 0 {
+			return errConfigUnchanged
+		}
+
+		backupPath = pm.currentConfigPath() + ".backup." + strconv.FormatInt(time.Now().Unix(), 10)
+		if err := pm.backupConfigFile(backupPath); err != nil {
+			return &configEditError{http.StatusInternalServerError, fmt.Errorf("Failed to backup config: %v", err)}
+		}
+
+		setMappingField(model, "cmd", cmdNode(newCmd))
+		setMissingMacrosInYAML(doc, macros)
+		return nil
+	})
+	if err != nil {
+		c.JSON(configEditStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"model":   modelID,
+			"changed": false,
+			"changes": changes,
+			"before":  oldCmd,
+			"after":   oldCmd,
+		})
+		return
+	}
+
+	pm.proxyLogger.Infof("Re-analyzed %s, %d cmd flags changed", modelID, len(changes))
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":   modelID,
+		"changed": true,
+		"changes": changes,
+		"before":  oldCmd,
+		"after":   newCmd,
+		"backup":  backupPath,
+	})
+}
@@ -3,13 +3,16 @@ package proxy
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
 )
 
 // ModelSearchResult represents a searchable model with all necessary info
@@ -97,7 +100,7 @@ func (pm *ProxyManager) apiV1SearchModels(c *gin.Context) {
 		req.Header.Set("Authorization", "Bearer "+hfToken)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := autosetup.NewHTTPClient(10 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -173,30 +176,23 @@ func (pm *ProxyManager) apiV1SearchModels(c *gin.Context) {
 	})
 }
 
-// extractQuantization extracts quantization from filename
+// extractQuantization extracts quantization from filename, "Unknown" when there is none
 func extractQuantization(filename string) string {
-	filename = strings.ToUpper(filename)
-
-	// Common quantization patterns
-	quantizations := []string{
-		"Q2_K", "Q3_K_S", "Q3_K_M", "Q3_K_L",
-		"Q4_0", "Q4_1", "Q4_K_S", "Q4_K_M",
-		"Q5_0", "Q5_1", "Q5_K_S", "Q5_K_M",
-		"Q6_K", "Q8_0", "F16", "F32",
-		"IQ1_S", "IQ2_XXS", "IQ2_XS", "IQ2_S",
-		"IQ3_XXS", "IQ3_XS", "IQ3_S", "IQ3_M",
-		"IQ4_XS", "IQ4_NL",
-	}
-
-	for _, q := range quantizations {
-		if strings.Contains(filename, q) {
-			return q
-		}
+	if quant := autosetup.DetectQuantization(filename); quant != "" {
+		return quant
 	}
-
 	return "Unknown"
 }
 
+// quantizationTag returns the lowercased quantization of a filename for use in
+// suggested model IDs, e.g. "q4_k_m", or "unknown" when there is none
+func quantizationTag(filename string) string {
+	if quant := autosetup.DetectQuantization(filename); quant != "" {
+		return strings.ToLower(quant)
+	}
+	return "unknown"
+}
+
 // formatModelName creates a readable name from repo and filename
 func formatModelName(repo, filename string) string {
 	// Remove extension
@@ -233,4 +229,140 @@ func (pm *ProxyManager) getSystemSettings() *SystemSettings {
 	// For now, returning a placeholder
 	settings, _ := pm.loadSystemSettings()
 	return settings
-}
\ No newline at end of file
+}
+
+// quantizationLabel returns the quantization of a GGUF filename as found by
+// extractQuantization, "mmproj" for vision projectors and "unknown" when there is none
+func quantizationLabel(filename string) string {
+	base := filepath.Base(filename)
+	if strings.Contains(strings.ToLower(base), "mmproj") {
+		return "mmproj"
+	}
+	if quant := extractQuantization(base); quant != "Unknown" {
+		return quant
+	}
+	return "unknown"
+}
+
+// apiGetHFRepoSize handles GET /api/models/hf-size?repo=owner/name and
+// returns the total download size of each quantization, summing all shards
+func (pm *ProxyManager) apiGetHFRepoSize(c *gin.Context) {
+	repo := strings.TrimSpace(c.Query("repo"))
+	if repo == "" || strings.Count(repo, "/") != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter 'repo' must be in owner/name format"})
+		return
+	}
+
+	// Get HF token from header or stored settings for gated repos
+	hfToken := c.GetHeader("HF-Token")
+	if hfToken == "" {
+		hfToken = c.GetHeader("X-HF-Token")
+	}
+	if hfToken == "" {
+		if settings := pm.getSystemSettings(); settings != nil {
+			hfToken = settings.HuggingFaceApiKey
+		}
+	}
+
+	result, err := pm.searchHuggingFaceModel(repo, hfToken, 0)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	type quantSize struct {
+		Quantization string  `json:"quantization"`
+		SizeBytes    int64   `json:"sizeBytes"`
+		SizeGB       float64 `json:"sizeGB"`
+		Files        int     `json:"files"`
+		IsSplit      bool    `json:"isSplit"`
+	}
+
+	byQuant := make(map[string]*quantSize)
+	sizesKnown := true
+	for _, file := range result.GGUFFiles {
+		label := quantizationLabel(file.Filename)
+		quant, ok := byQuant[label]
+		if !ok {
+			quant = &quantSize{Quantization: label}
+			byQuant[label] = quant
+		}
+		quant.SizeBytes += file.Size
+		quant.Files++
+		quant.IsSplit = quant.IsSplit || file.IsSplit
+		if file.Size == 0 {
+			sizesKnown = false
+		}
+	}
+
+	quants := make([]quantSize, 0, len(byQuant))
+	for _, quant := range byQuant {
+		quant.SizeGB = math.Round(float64(quant.SizeBytes)/(1024*1024*1024)*100) / 100
+		quants = append(quants, *quant)
+	}
+	sort.Slice(quants, func(i, j int) bool {
+		if quants[i].SizeBytes != quants[j].SizeBytes {
+			return quants[i].SizeBytes < quants[j].SizeBytes
+		}
+		return quants[i].Quantization < quants[j].Quantization
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"repo":       repo,
+		"quants":     quants,
+		"totalBytes": result.TotalSize,
+		"sizesKnown": sizesKnown,
+	})
+}
+
+// apiGetHFMetadata handles GET /api/models/hf-metadata?repo=owner/name&file=x.gguf.
+// It reads the GGUF header of a file with ranged requests, so architecture,
+// context and quantization can be checked before downloading the whole model.
+func (pm *ProxyManager) apiGetHFMetadata(c *gin.Context) {
+	repo := strings.TrimSpace(c.Query("repo"))
+	if repo == "" || strings.Count(repo, "/") != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter 'repo' must be in owner/name format"})
+		return
+	}
+	file := strings.TrimSpace(c.Query("file"))
+	if !strings.HasSuffix(strings.ToLower(file), ".gguf") || strings.Contains(file, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter 'file' must be a .gguf file of the repo"})
+		return
+	}
+
+	// Get HF token from header or stored settings for gated repos
+	hfToken := c.GetHeader("HF-Token")
+	if hfToken == "" {
+		hfToken = c.GetHeader("X-HF-Token")
+	}
+	if hfToken == "" {
+		if settings := pm.getSystemSettings(); settings != nil {
+			hfToken = settings.HuggingFaceApiKey
+		}
+	}
+
+	metadata, err := pm.readRemoteGGUFMetadata(repo, file, hfToken)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to read GGUF metadata of %s/%s: %v", repo, file, err)})
+		return
+	}
+
+	// old files have no general.file_type, the filename usually has it
+	quantization, quantizationSource := metadata.Quantization, "metadata"
+	if quantization == "" {
+		quantization, quantizationSource = quantizationLabel(file), "filename"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"repo":               repo,
+		"file":               file,
+		"architecture":       metadata.Architecture,
+		"name":               metadata.ModelName,
+		"contextLength":      metadata.ContextLength,
+		"blockCount":         metadata.BlockCount,
+		"headCountKV":        metadata.HeadCountKV,
+		"slidingWindow":      metadata.SlidingWindow,
+		"quantization":       quantization,
+		"quantizationSource": quantizationSource,
+	})
+}
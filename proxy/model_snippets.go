@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sample inputs of the snippets, ?prompt= replaces them
+const (
+	snippetChatPrompt      = "Write a haiku about frogs."
+	snippetEmbeddingInput  = "Frogs are amphibians."
+	snippetRerankQuery     = "Which animal croaks?"
+	snippetAPIKeyVariable  = "FROGLLM_API_KEY"
+	snippetNoAPIKeyDefault = "none" // the OpenAI SDKs need a key even when none is checked
+)
+
+// snippetRerankDocuments are the documents a reranker snippet orders by the query
+var snippetRerankDocuments = []string{"Frogs croak at night.", "Cats purr when content.", "Owls hoot in the dark."}
+
+// modelSnippetKind returns the kind of requests a model serves, chat, embedding
+// or reranker, and the endpoint its snippets call
+func modelSnippetKind(modelConfig ModelConfig) (kind, endpoint string) {
+	switch modelTypeFromCmd(modelConfig) {
+	case "embedding":
+		return "embedding", "/v1/embeddings"
+	case "reranker":
+		return "reranker", "/v1/rerank"
+	}
+	return "chat", "/v1/chat/completions"
+}
+
+// requestBaseURL returns the URL clients reached FrogLLM at, e.g.
+// http://192.168.1.10:5800, honoring a reverse proxy's X-Forwarded-Proto
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// modelSnippets returns curl, Python and JavaScript code calling modelID at
+// baseURL. With requireKey the key is read from FROGLLM_API_KEY, it is never
+// part of a snippet.
+func modelSnippets(kind, endpoint, baseURL, modelID, prompt string, requireKey bool) map[string]string {
+	model, input := strconv.Quote(modelID), strconv.Quote(prompt)
+	documents, _ := json.Marshal(snippetRerankDocuments)
+
+	// structs keep the model first in the request body
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	var body interface{}
+	switch kind {
+	case "embedding":
+		body = struct {
+			Model string `json:"model"`
+			Input string `json:"input"`
+		}{modelID, prompt}
+	case "reranker":
+		body = struct {
+			Model     string   `json:"model"`
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}{modelID, prompt, snippetRerankDocuments}
+	default:
+		body = struct {
+			Model    string    `json:"model"`
+			Messages []message `json:"messages"`
+		}{modelID, []message{{"user", prompt}}}
+	}
+	bodyJSON, _ := json.MarshalIndent(body, "", "  ")
+
+	curlAuth, pythonKey, jsKey := "", strconv.Quote(snippetNoAPIKeyDefault), strconv.Quote(snippetNoAPIKeyDefault)
+	pythonImports := ""
+	if requireKey {
+		curlAuth = fmt.Sprintf("  -H \"Authorization: Bearer $%s\" \\\n", snippetAPIKeyVariable)
+		pythonKey = fmt.Sprintf("os.environ[%q]", snippetAPIKeyVariable)
+		pythonImports = "import os\n"
+		jsKey = "process.env." + snippetAPIKeyVariable
+	}
+
+	curl := fmt.Sprintf("curl %s \\\n  -H \"Content-Type: application/json\" \\\n%s  -d %s",
+		baseURL+endpoint, curlAuth, shellQuote(string(bodyJSON)))
+
+	var python, javascript string
+	switch kind {
+	case "embedding":
+		python = fmt.Sprintf("%sfrom openai import OpenAI\n\nclient = OpenAI(base_url=%q, api_key=%s)\n\nresponse = client.embeddings.create(model=%s, input=%s)\nprint(len(response.data[0].embedding))\n",
+			pythonImports, baseURL+"/v1", pythonKey, model, input)
+		javascript = fmt.Sprintf("import OpenAI from \"openai\";\n\nconst client = new OpenAI({ baseURL: %q, apiKey: %s });\n\nconst response = await client.embeddings.create({ model: %s, input: %s });\nconsole.log(response.data[0].embedding.length);\n",
+			baseURL+"/v1", jsKey, model, input)
+	case "reranker":
+		// the OpenAI SDKs have no rerank call
+		pythonHeaders := ""
+		jsHeaders := `{ "Content-Type": "application/json" }`
+		if requireKey {
+			pythonHeaders = fmt.Sprintf("\n    headers={\"Authorization\": \"Bearer \" + %s},", pythonKey)
+			jsHeaders = fmt.Sprintf("{ \"Content-Type\": \"application/json\", Authorization: `Bearer ${%s}` }", jsKey)
+		}
+		python = fmt.Sprintf("%simport requests\n\nresponse = requests.post(\n    %q,\n    json={\"model\": %s, \"query\": %s, \"documents\": %s},%s\n)\nprint(response.json())\n",
+			pythonImports, baseURL+endpoint, model, input, documents, pythonHeaders)
+		javascript = fmt.Sprintf("const response = await fetch(%q, {\n  method: \"POST\",\n  headers: %s,\n  body: JSON.stringify({ model: %s, query: %s, documents: %s }),\n});\nconsole.log(await response.json());\n",
+			baseURL+endpoint, jsHeaders, model, input, documents)
+	default:
+		python = fmt.Sprintf("%sfrom openai import OpenAI\n\nclient = OpenAI(base_url=%q, api_key=%s)\n\nresponse = client.chat.completions.create(\n    model=%s,\n    messages=[{\"role\": \"user\", \"content\": %s}],\n)\nprint(response.choices[0].message.content)\n",
+			pythonImports, baseURL+"/v1", pythonKey, model, input)
+		javascript = fmt.Sprintf("import OpenAI from \"openai\";\n\nconst client = new OpenAI({ baseURL: %q, apiKey: %s });\n\nconst response = await client.chat.completions.create({\n  model: %s,\n  messages: [{ role: \"user\", content: %s }],\n});\nconsole.log(response.choices[0].message.content);\n",
+			baseURL+"/v1", jsKey, model, input)
+	}
+
+	return map[string]string{"curl": curl, "python": python, "javascript": javascript}
+}
+
+// apiGetModelSnippets handles GET /api/models/:id/snippets. It returns
+// ready to run curl, Python and JavaScript code calling the model through this
+// proxy, with the endpoint for its kind: chat, embedding or reranker.
+func (pm *ProxyManager) apiGetModelSnippets(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	modelConfig := pm.config.Models[modelID]
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	kind, endpoint := modelSnippetKind(modelConfig)
+	prompt := strings.TrimSpace(c.Query("prompt"))
+	if prompt == "" {
+		switch kind {
+		case "embedding":
+			prompt = snippetEmbeddingInput
+		case "reranker":
+			prompt = snippetRerankQuery
+		default:
+			prompt = snippetChatPrompt
+		}
+	}
+	requireKey := false
+	if settings, _ := pm.loadSystemSettings(); settings != nil {
+		requireKey = settings.RequireAPIKey
+	}
+	baseURL := requestBaseURL(c.Request)
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":      modelID,
+		"type":       kind,
+		"endpoint":   endpoint,
+		"baseUrl":    baseURL,
+		"prompt":     prompt,
+		"requireKey": requireKey,
+		"snippets":   modelSnippets(kind, endpoint, baseURL, modelID, prompt, requireKey),
+	})
+}
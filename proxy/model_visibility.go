@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// apiSetModelVisibility handles POST /api/models/:id/visibility. Unlisted
+// models are hidden from /v1/models but can still be requested by name.
+func (pm *ProxyManager) apiSetModelVisibility(c *gin.Context) {
+	var req struct {
+		Unlisted *bool `json:"unlisted"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.Unlisted == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unlisted is required"})
+		return
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(*req.Unlisted)}
+	err := pm.updateModelFieldsInConfig(modelID, func(model *yaml.Node) error {
+		setMappingField(model, "unlisted", value)
+		return nil
+	})
+	if err != nil {
+		c.JSON(configEditStatus(err), gin.H{"error": "Failed to update model visibility: " + err.Error()})
+		return
+	}
+
+	// apply to the running config right away, the reload picks it up from the
+	// file. The Models map is shared with readers of the old config so it is
+	// copied and the config swapped.
+	pm.Lock()
+	newConfig := pm.config
+	newConfig.Models = make(map[string]ModelConfig, len(pm.config.Models))
+	for id, modelConfig := range pm.config.Models {
+		newConfig.Models[id] = modelConfig
+	}
+	modelConfig := newConfig.Models[modelID]
+	modelConfig.Unlisted = *req.Unlisted
+	newConfig.Models[modelID] = modelConfig
+	pm.config = newConfig
+	pm.Unlock()
+
+	pm.proxyLogger.Infof("Set model %s unlisted=%t", modelID, *req.Unlisted)
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":    modelID,
+		"unlisted": *req.Unlisted,
+	})
+}
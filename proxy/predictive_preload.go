@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"sort"
+	"time"
+)
+
+// PredictivePreloadConfig keeps the most used models warm. At startup and every
+// interval the topN models with the most requests in the activity stats are
+// loaded, as far as they fit in memory and their groups allow.
+type PredictivePreloadConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	TopN     int  `yaml:"topN"`     // default 2
+	Interval int  `yaml:"interval"` // seconds between checks, default 600
+}
+
+func (p PredictivePreloadConfig) topN() int {
+	if p.TopN <= 0 {
+		return 2
+	}
+	return p.TopN
+}
+
+func (p PredictivePreloadConfig) interval() time.Duration {
+	if p.Interval <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(p.Interval) * time.Second
+}
+
+// selectPredictivePreloads returns the models to preload, most used first. Models
+// are taken by request count until topN of them are warm, counting the running
+// ones. A model is passed over when it is remote, its files don't fit in the
+// budget left, or loading it would stop a running or selected model, or be
+// stopped by one, under the group rules.
+func selectPredictivePreloads(stats map[string]*ActivityStats, config Config, running map[string]bool, sizeOf func(modelID string) uint64, budget uint64, topN int) []string {
+	var ranked []*ActivityStats
+	for modelID, modelStats := range stats {
+		modelConfig, found := config.Models[modelID]
+		if !found || modelConfig.IsRemote() || modelStats.RequestCount == 0 {
+			continue
+		}
+		ranked = append(ranked, modelStats)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].RequestCount != ranked[j].RequestCount {
+			return ranked[i].RequestCount > ranked[j].RequestCount
+		}
+		return ranked[i].ModelID < ranked[j].ModelID
+	})
+
+	groupOf := make(map[string]string)
+	for groupID, group := range config.Groups {
+		for _, member := range group.Members {
+			groupOf[member] = groupID
+		}
+	}
+	warm := make([]string, 0, len(running))
+	for modelID := range running {
+		warm = append(warm, modelID)
+	}
+
+	var selected []string
+	used := uint64(0)
+	for _, modelStats := range ranked {
+		if len(warm) >= topN {
+			break
+		}
+		modelID := modelStats.ModelID
+		if running[modelID] {
+			continue
+		}
+		size := sizeOf(modelID)
+		if used+size > budget {
+			continue
+		}
+		conflicts := false
+		for _, other := range warm {
+			if preloadStops(config, groupOf, modelID, other) || preloadStops(config, groupOf, other, modelID) {
+				conflicts = true
+				break
+			}
+		}
+		if conflicts {
+			continue
+		}
+		selected = append(selected, modelID)
+		warm = append(warm, modelID)
+		used += size
+	}
+	return selected
+}
+
+// runningModels returns the models whose process is not stopped
+func (pm *ProxyManager) runningModels() map[string]bool {
+	pm.Lock()
+	defer pm.Unlock()
+	running := make(map[string]bool)
+	for _, processGroup := range pm.processGroups {
+		processGroup.Lock()
+		for modelID, process := range processGroup.processes {
+			if state := process.CurrentState(); state != StateStopped && state != StateShutdown {
+				running[modelID] = true
+			}
+		}
+		processGroup.Unlock()
+	}
+	return running
+}
+
+// predictivePreload loads the most used models that aren't running, see
+// selectPredictivePreloads
+func (pm *ProxyManager) predictivePreload() {
+	if pm.metricsMonitor == nil || pm.metricsMonitor.ActivityStats == nil {
+		return
+	}
+	budget := pm.preloadMemoryBudget()
+	sizeOf := func(modelID string) uint64 { return modelFileSize(pm.config.Models[modelID]) }
+	selected := selectPredictivePreloads(pm.metricsMonitor.ActivityStats.GetStats(), pm.config, pm.runningModels(),
+		sizeOf, budget, pm.config.PredictivePreload.topN())
+	for _, modelID := range selected {
+		pm.proxyLogger.Infof("Predictive preload: loading %s, one of the %d most used models", modelID, pm.config.PredictivePreload.topN())
+		pm.preloadModel(modelID)
+	}
+}
+
+// watchPredictivePreload preloads the most used models now and every interval, until shutdown
+func (pm *ProxyManager) watchPredictivePreload(interval time.Duration) {
+	pm.predictivePreload()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pm.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			pm.predictivePreload()
+		}
+	}
+}
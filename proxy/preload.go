@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/prave/FrogLLM/event"
+)
+
+// preloadModels loads the models of hooks.on_startup.preload in order, LoadConfig
+// sorted it by preloadPriority. With parallel set, batches of models are loaded
+// at the same time, see preloadBatches.
+func (pm *ProxyManager) preloadModels(preload []string, parallel bool) {
+	batches := make([][]string, 0, len(preload))
+	if parallel {
+		sizeOf := func(modelID string) uint64 { return modelFileSize(pm.config.Models[modelID]) }
+		batches = preloadBatches(preload, pm.config, sizeOf, pm.preloadMemoryBudget())
+	} else {
+		for _, modelID := range preload {
+			batches = append(batches, []string{modelID})
+		}
+	}
+
+	for _, batch := range batches {
+		done := make(chan struct{}, len(batch))
+		for _, modelID := range batch {
+			go func(modelID string) {
+				pm.preloadModel(modelID)
+				done <- struct{}{}
+			}(modelID)
+		}
+		for range batch {
+			<-done
+		}
+	}
+}
+
+func (pm *ProxyManager) preloadModel(modelID string) {
+	pm.proxyLogger.Infof("Preloading model: %s", modelID)
+	processGroup, _, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		event.Emit(ModelPreloadedEvent{ModelName: modelID, Success: false})
+		pm.proxyLogger.Errorf("Failed to preload model %s: %v", modelID, err)
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	processGroup.ProxyRequest(modelID, &DiscardWriter{}, req)
+	event.Emit(ModelPreloadedEvent{ModelName: modelID, Success: true})
+}
+
+// preloadMemoryBudget is the memory the preloaded models may use together, the
+// available memory above minFreeMemoryPercent. 0 when it can't be read.
+func (pm *ProxyManager) preloadMemoryBudget() uint64 {
+	memInfo, err := pm.getMemoryInfo()
+	if err != nil {
+		pm.proxyLogger.Warnf("Could not get memory info, preloading one model at a time: %v", err)
+		return 0
+	}
+	minFreePercent := pm.config.MinFreeMemoryPercent
+	if minFreePercent == 0 {
+		minFreePercent = 10.0
+	}
+	reserved := uint64(float64(memInfo.Total) * (minFreePercent / 100.0))
+	if memInfo.Available <= reserved {
+		return 0
+	}
+	return memInfo.Available - reserved
+}
+
+// modelFileSize is the size of the files in a model's cmd, the memory its weights need
+func modelFileSize(modelConfig ModelConfig) uint64 {
+	var size uint64
+	for _, file := range modelFilesFromCmd(modelConfig) {
+		if info, err := os.Stat(file); err == nil {
+			size += uint64(info.Size())
+		}
+	}
+	return size
+}
+
+// preloadBatches splits the preload order into batches that are loaded one after
+// another. A batch holds models of the same priority whose files fit in budget
+// together. Models of exclusive groups, which stop the others, and of swap groups
+// with another model in the batch are loaded on their own.
+func preloadBatches(order []string, config Config, sizeOf func(modelID string) uint64, budget uint64) [][]string {
+	groupOf := make(map[string]string)
+	for groupID, group := range config.Groups {
+		for _, member := range group.Members {
+			groupOf[member] = groupID
+		}
+	}
+	canShare := func(batch []string, modelID string) bool {
+		group := config.Groups[groupOf[modelID]]
+		if group.Exclusive {
+			return false
+		}
+		for _, other := range batch {
+			otherGroup := config.Groups[groupOf[other]]
+			if otherGroup.Exclusive || (groupOf[other] == groupOf[modelID] && group.Swap) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var batches [][]string
+	var batchSize uint64
+	for _, modelID := range order {
+		size := sizeOf(modelID)
+		if n := len(batches); n > 0 {
+			batch := batches[n-1]
+			samePriority := config.Models[batch[0]].PreloadPriority == config.Models[modelID].PreloadPriority
+			if samePriority && batchSize+size <= budget && canShare(batch, modelID) {
+				batches[n-1] = append(batch, modelID)
+				batchSize += size
+				continue
+			}
+		}
+		batches = append(batches, []string{modelID})
+		batchSize = size
+	}
+	return batches
+}
+
+// preloadStops reports if loading model a stops model b: a member of a swap
+// group stops the other members, a model of an exclusive group stops the models
+// of every other group that isn't persistent
+func preloadStops(config Config, groupOf map[string]string, a, b string) bool {
+	groupA, groupB := groupOf[a], groupOf[b]
+	if groupA == groupB {
+		return config.Groups[groupA].Swap
+	}
+	return config.Groups[groupA].Exclusive && !config.Groups[groupB].Persistent
+}
+
+// resolvePreloadConflicts drops the preloads that would be stopped by, or stop,
+// models loaded earlier, since at most one of them ends up warm. A model that
+// only stops models loaded before it is moved ahead of them instead. A
+// preloadPreferred model wins over the models it conflicts with, otherwise the
+// first in the order is kept. Each skipped or moved model gives a warning.
+func resolvePreloadConflicts(order []string, config Config) (kept []string, warnings []string) {
+	groupOf := make(map[string]string)
+	for groupID, group := range config.Groups {
+		for _, member := range group.Members {
+			groupOf[member] = groupID
+		}
+	}
+	conflict := func(a, b string) bool {
+		return preloadStops(config, groupOf, a, b) || preloadStops(config, groupOf, b, a)
+	}
+	// models that stop each other can't both stay warm in any order
+	exclusive := func(a, b string) bool {
+		return preloadStops(config, groupOf, a, b) && preloadStops(config, groupOf, b, a)
+	}
+	reason := func(a, b string) string {
+		if groupOf[a] == groupOf[b] {
+			return fmt.Sprintf("%s and %s are in swap group %s", a, b, groupOf[a])
+		}
+		if preloadStops(config, groupOf, a, b) {
+			return fmt.Sprintf("exclusive group %s of %s stops %s", groupOf[a], a, b)
+		}
+		return fmt.Sprintf("exclusive group %s of %s stops %s", groupOf[b], b, a)
+	}
+
+	// position returns the latest position modelID can load at without stopping
+	// a model loaded before it or being stopped by one loaded after it, -1 if none
+	position := func(modelID string) int {
+		for pos := len(kept); pos >= 0; pos-- {
+			valid := true
+			for _, before := range kept[:pos] {
+				if preloadStops(config, groupOf, modelID, before) {
+					valid = false
+					break
+				}
+			}
+			for _, after := range kept[pos:] {
+				if valid && preloadStops(config, groupOf, after, modelID) {
+					valid = false
+				}
+			}
+			if valid {
+				return pos
+			}
+		}
+		return -1
+	}
+
+	for _, modelID := range order {
+		pos := position(modelID)
+		if pos < 0 && config.Models[modelID].PreloadPreferred {
+			var conflicting []string
+			for _, other := range kept {
+				if exclusive(modelID, other) {
+					conflicting = append(conflicting, other)
+				}
+			}
+			if !slices.ContainsFunc(conflicting, func(other string) bool { return config.Models[other].PreloadPreferred }) {
+				for _, other := range conflicting {
+					warnings = append(warnings, fmt.Sprintf("preload: skipping %s, %s and %s has preloadPreferred", other, reason(other, modelID), modelID))
+				}
+				kept = slices.DeleteFunc(kept, func(other string) bool { return slices.Contains(conflicting, other) })
+				pos = position(modelID)
+			}
+		}
+		if pos < 0 {
+			for _, other := range kept {
+				if conflict(modelID, other) {
+					warnings = append(warnings, fmt.Sprintf("preload: skipping %s, %s; set preloadPreferred on the model to keep it warm instead", modelID, reason(modelID, other)))
+					break
+				}
+			}
+			continue
+		}
+		if pos < len(kept) {
+			warnings = append(warnings, fmt.Sprintf("preload: loading %s before %s, %s", modelID, kept[pos], reason(modelID, kept[pos])))
+		}
+		kept = slices.Insert(kept, pos, modelID)
+	}
+	return kept, warnings
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -22,6 +24,7 @@ import (
 
 	"github.com/prave/FrogLLM/autosetup"
 	"github.com/prave/FrogLLM/event"
+	"github.com/tidwall/gjson"
 )
 
 type ProcessState string
@@ -36,6 +39,12 @@ const (
 	StateShutdown ProcessState = ProcessState("shutdown")
 )
 
+// seconds a process has to write output or open its port, see processStartTimeout
+const defaultProcessStartTimeout = 10
+
+// seconds a process has to exit after a graceful stop before it is killed, see stopGracePeriod
+const defaultStopGracePeriod = 10
+
 type StopStrategy int
 
 const (
@@ -54,6 +63,20 @@ type Process struct {
 	// closed when command exits
 	cmdWaitChan chan struct{}
 
+	// error returned by cmd.Wait(), only read after cmdWaitChan is closed
+	cmdExitErr error
+
+	// set once the upstream process writes to stdout or stderr
+	outputSeen atomic.Bool
+
+	// set by stopCommand before the command is cancelled, so start() can tell a
+	// requested stop from the command exiting on its own
+	stopRequested atomic.Bool
+
+	// set while a ready process fails its periodic health checks, see process_health.go
+	unhealthy           atomic.Bool
+	healthWatchInterval time.Duration
+
 	processLogger *LogMonitor
 	proxyLogger   *LogMonitor
 
@@ -62,10 +85,22 @@ type Process struct {
 
 	lastRequestHandled time.Time
 
+	// request timestamps within the keepWarm window
+	recentRequests      []time.Time
+	recentRequestsMutex sync.Mutex
+
 	stateMutex sync.RWMutex
 	state      ProcessState
 
+	// set by blockStarts, guarded by stateMutex
+	startsBlocked bool
+
 	inFlightRequests sync.WaitGroup
+	inFlightCount    atomic.Int32
+
+	// set when an upstream stream broke, the process is stopped once its other
+	// requests are done and the next request starts a fresh one
+	needsRestart atomic.Bool
 
 	// used to block on multiple start() calls
 	waitStarting sync.WaitGroup
@@ -73,11 +108,28 @@ type Process struct {
 	// for managing concurrency limits
 	concurrencyLimitSemaphore chan struct{}
 
-	// used for testing to override the default value
+	// how long a stopping process has to exit before it is killed, see stopGracePeriod
 	gracefulStopTimeout time.Duration
 
 	// track the number of failed starts
 	failedStartCount int
+
+	// last lines of upstream output and the outcome of the last load, see load_status.go
+	outputTail    *outputTail
+	loadMutex     sync.Mutex
+	loadStartedAt time.Time
+	loadError     *LoadError
+
+	// upstream output is also written here when modelLogs is enabled, see model_logs.go
+	logFile *modelLogFile
+
+	// unix nanoseconds of when the process last became ready, see swap_cooldown.go
+	readyAt atomic.Int64
+
+	// cmd of the next launch only and of the running launch, see try_params.go
+	launchMutex   sync.Mutex
+	nextLaunchCmd string
+	launchedCmd   string
 }
 
 func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, processLogger *LogMonitor, proxyLogger *LogMonitor) *Process {
@@ -86,6 +138,11 @@ func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, processLo
 		concurrentLimit = config.ConcurrencyLimit
 	}
 
+	stopGracePeriod := defaultStopGracePeriod
+	if config.StopGracePeriod > 0 {
+		stopGracePeriod = config.StopGracePeriod
+	}
+
 	return &Process{
 		ID:                      ID,
 		config:                  config,
@@ -100,10 +157,11 @@ func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, processLo
 		// concurrency limit
 		concurrencyLimitSemaphore: make(chan struct{}, concurrentLimit),
 
-		// To be removed when migration over exec.CommandContext is complete
-		// stop timeout
-		gracefulStopTimeout: 10 * time.Second,
+		// time to exit after the graceful stop before the process is killed
+		gracefulStopTimeout: time.Duration(stopGracePeriod) * time.Second,
+		healthWatchInterval: time.Duration(config.HealthCheckInterval) * time.Second,
 		cmdWaitChan:         make(chan struct{}),
+		outputTail:          &outputTail{},
 	}
 }
 
@@ -116,8 +174,12 @@ func (p *Process) LogMonitor() *LogMonitor {
 var (
 	ErrExpectedStateMismatch  = errors.New("expected state mismatch")
 	ErrInvalidStateTransition = errors.New("invalid state transition")
+	ErrStartsBlocked          = errors.New("starts are blocked while the model files change")
 )
 
+// ErrStartInterrupted is returned by start() when the process is stopped before it is ready
+var ErrStartInterrupted = errors.New("health check interrupted due to shutdown")
+
 // swapState performs a compare and swap of the state atomically. It returns the current state
 // and an error if the swap failed.
 func (p *Process) swapState(expectedState, newState ProcessState) (ProcessState, error) {
@@ -129,6 +191,10 @@ func (p *Process) swapState(expectedState, newState ProcessState) (ProcessState,
 		return p.state, ErrExpectedStateMismatch
 	}
 
+	if newState == StateStarting && p.startsBlocked {
+		return p.state, ErrStartsBlocked
+	}
+
 	if !isValidTransition(p.state, newState) {
 		p.proxyLogger.Warnf("<%s> swapState() Invalid state transition from %s to %s", p.ID, p.state, newState)
 		return p.state, ErrInvalidStateTransition
@@ -140,6 +206,24 @@ func (p *Process) swapState(expectedState, newState ProcessState) (ProcessState,
 	return p.state, nil
 }
 
+// blockStarts keeps a stopped process from starting until unblockStarts, while
+// its files are changed. It reports false when the process is not stopped.
+func (p *Process) blockStarts() bool {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+	if p.state != StateStopped && p.state != StateShutdown {
+		return false
+	}
+	p.startsBlocked = true
+	return true
+}
+
+func (p *Process) unblockStarts() {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+	p.startsBlocked = false
+}
+
 // Helper function to encapsulate transition rules
 func isValidTransition(from, to ProcessState) bool {
 	switch from {
@@ -166,15 +250,20 @@ func (p *Process) CurrentState() ProcessState {
 // start starts the upstream command, checks the health endpoint, and sets the state to Ready
 // it is a private method because starting is automatic but stopping can be called
 // at any time.
-func (p *Process) start() error {
+func (p *Process) start() (err error) {
 
 	if p.config.Proxy == "" {
 		return fmt.Errorf("can not start(), upstream proxy missing")
 	}
 
-	args, err := p.config.SanitizedCommand()
-	if err != nil {
-		return fmt.Errorf("unable to get sanitized command: %v", err)
+	launchConfig := p.config
+	launchConfig.Cmd = p.launchCmd()
+
+	var args []string
+	if !p.config.IsRemote() {
+		if args, err = launchConfig.SanitizedCommand(); err != nil {
+			return fmt.Errorf("unable to get sanitized command: %v", err)
+		}
 	}
 
 	if curState, err := p.swapState(StateStopped, StateStarting); err != nil {
@@ -198,15 +287,41 @@ func (p *Process) start() error {
 
 	p.waitStarting.Add(1)
 	defer p.waitStarting.Done()
+	p.needsRestart.Store(false)
+	p.launchStarted(launchConfig.Cmd)
+	p.loadStarted()
+	defer func() { err = p.loadFinished(err) }()
 	cmdContext, ctxCancelUpstream := context.WithCancel(context.Background())
 
+	p.outputSeen.Store(false)
+	p.stopRequested.Store(false)
+	output := &outputWatcher{w: p.processLogger, seen: &p.outputSeen, tail: p.outputTail}
+
+	if p.config.IsRemote() {
+		// a remote server is not managed, it is only health checked and
+		// stopping it only stops routing to it, see ModelConfig.IsRemote
+		p.cancelUpstream = ctxCancelUpstream
+		p.cmdWaitChan = make(chan struct{})
+		go func() {
+			<-cmdContext.Done()
+			p.markStopped()
+		}()
+		p.proxyLogger.Debugf("<%s> Using remote server %s", p.ID, p.config.Proxy)
+		goto checkHealth
+	}
+
+	if p.logFile != nil {
+		p.logFile.start(p.ID, args)
+		output.w = io.MultiWriter(p.processLogger, p.logFile)
+	}
+
 	p.cmd = exec.CommandContext(cmdContext, args[0], args[1:]...)
-	p.cmd.Stdout = p.processLogger
-	p.cmd.Stderr = p.processLogger
+	p.cmd.Stdout = output
+	p.cmd.Stderr = output
 	p.cmd.Env = append(p.cmd.Environ(), p.config.Env...)
-	// Go 1.20+ features commented out for compatibility
-	// p.cmd.Cancel = p.cmdStopUpstreamProcess
-	// p.cmd.WaitDelay = p.gracefulStopTimeout
+	p.cmd.Cancel = p.cmdStopUpstreamProcess
+	p.cmd.WaitDelay = p.gracefulStopTimeout
+	configurePriority(p.cmd, p.config.niceness())
 	p.cancelUpstream = ctxCancelUpstream
 	p.cmdWaitChan = make(chan struct{})
 
@@ -232,12 +347,12 @@ func (p *Process) start() error {
 				newArgs, argErr := p.config.SanitizedCommand()
 				if argErr == nil {
 					p.cmd = exec.CommandContext(cmdContext, newArgs[0], newArgs[1:]...)
-					p.cmd.Stdout = p.processLogger
-					p.cmd.Stderr = p.processLogger
+					p.cmd.Stdout = output
+					p.cmd.Stderr = output
 					p.cmd.Env = append(p.cmd.Environ(), p.config.Env...)
-					// Go 1.20+ features commented out for compatibility
-					// p.cmd.Cancel = p.cmdStopUpstreamProcess
-					// p.cmd.WaitDelay = p.gracefulStopTimeout
+					p.cmd.Cancel = p.cmdStopUpstreamProcess
+					p.cmd.WaitDelay = p.gracefulStopTimeout
+					configurePriority(p.cmd, p.config.niceness())
 
 					p.proxyLogger.Debugf("<%s> Retrying start command after binary download: %s", p.ID, strings.Join(newArgs, " "))
 					if retryErr := p.cmd.Start(); retryErr == nil {
@@ -261,12 +376,12 @@ func (p *Process) start() error {
 						newArgs, argErr := p.config.SanitizedCommand()
 						if argErr == nil {
 							p.cmd = exec.CommandContext(cmdContext, newArgs[0], newArgs[1:]...)
-							p.cmd.Stdout = p.processLogger
-							p.cmd.Stderr = p.processLogger
+							p.cmd.Stdout = output
+							p.cmd.Stderr = output
 							p.cmd.Env = append(p.cmd.Environ(), p.config.Env...)
-							// Go 1.20+ features commented out for compatibility
-							// p.cmd.Cancel = p.cmdStopUpstreamProcess
-							// p.cmd.WaitDelay = p.gracefulStopTimeout
+							p.cmd.Cancel = p.cmdStopUpstreamProcess
+							p.cmd.WaitDelay = p.gracefulStopTimeout
+							configurePriority(p.cmd, p.config.niceness())
 							if retryErr := p.cmd.Start(); retryErr == nil {
 								p.proxyLogger.Infof("<%s> Successfully started after reconfigure", p.ID)
 								goto startupSuccess
@@ -289,6 +404,10 @@ func (p *Process) start() error {
 
 startupSuccess:
 
+	if err := applyPriority(p.cmd, p.config.niceness()); err != nil {
+		p.proxyLogger.Warnf("<%s> Failed to set niceness %d: %v", p.ID, p.config.niceness(), err)
+	}
+
 	// Capture the exit error for later signalling
 	go p.waitForCmd()
 
@@ -300,8 +419,12 @@ startupSuccess:
 	// only in the third case will the process be considered Ready to accept
 	<-time.After(250 * time.Millisecond) // give process a bit of time to start
 
+checkHealth:
+
 	checkStartTime := time.Now()
 	maxDuration := time.Second * time.Duration(p.healthCheckTimeout)
+	spawnDuration := time.Second * time.Duration(p.processStartTimeout())
+	portOpen := false
 	checkEndpoint := strings.TrimSpace(p.config.CheckEndpoint)
 
 	// a "none" means don't check for health ... I could have picked a better word :facepalm:
@@ -316,10 +439,10 @@ startupSuccess:
 		for {
 			currentState := p.CurrentState()
 			if currentState != StateStarting {
-				if currentState == StateStopped {
-					return fmt.Errorf("upstream command exited prematurely but successfully")
+				if currentState == StateStopped && !p.stopRequested.Load() {
+					return p.prematureExitError()
 				}
-				return errors.New("health check interrupted due to shutdown")
+				return ErrStartInterrupted
 			}
 
 			if time.Since(checkStartTime) > maxDuration {
@@ -335,10 +458,21 @@ startupSuccess:
 					ttl := time.Until(checkStartTime.Add(maxDuration))
 					p.proxyLogger.Debugf("<%s> Connection refused on %s, giving up in %.0fs (normal during startup)", p.ID, healthURL, ttl.Seconds())
 				} else {
+					portOpen = true
 					p.proxyLogger.Debugf("<%s> Health check error on %s, %v (normal during startup)", p.ID, healthURL, err)
 				}
 			}
-			<-time.After(p.healthCheckLoopInterval)
+
+			// fail fast when the process never got going, model loading gets the full health check timeout
+			if !portOpen && !p.outputSeen.Load() && !p.config.IsRemote() && time.Since(checkStartTime) > spawnDuration {
+				p.stopCommand()
+				return fmt.Errorf("upstream process wrote no output and did not open %s within %vs, check the cmd binary and its arguments", proxyTo, spawnDuration.Seconds())
+			}
+
+			select {
+			case <-time.After(p.healthCheckLoopInterval):
+			case <-p.cmdWaitChan:
+			}
 		}
 	}
 
@@ -346,8 +480,6 @@ startupSuccess:
 		// start a goroutine to check every second if
 		// the process should be stopped
 		go func() {
-			maxDuration := time.Duration(p.config.UnloadAfter) * time.Second
-
 			for range time.Tick(time.Second) {
 				if p.CurrentState() != StateReady {
 					return
@@ -356,8 +488,9 @@ startupSuccess:
 				// wait for all inflight requests to complete and ticker
 				p.inFlightRequests.Wait()
 
+				maxDuration := p.effectiveUnloadAfter(time.Now())
 				if time.Since(p.lastRequestHandled) > maxDuration {
-					p.proxyLogger.Infof("<%s> Unloading model, TTL of %ds reached", p.ID, p.config.UnloadAfter)
+					p.proxyLogger.Infof("<%s> Unloading model, TTL of %.0fs reached (configured %ds)", p.ID, maxDuration.Seconds(), p.config.UnloadAfter)
 					p.Stop()
 					return
 				}
@@ -365,18 +498,92 @@ startupSuccess:
 		}()
 	}
 
+	p.unhealthy.Store(false)
 	if curState, err := p.swapState(StateStarting, StateReady); err != nil {
 		return fmt.Errorf("failed to set Process state to ready: current state: %v, error: %v", curState, err)
 	} else {
 		p.failedStartCount = 0
+		p.readyAt.Store(time.Now().UnixNano())
+		go p.watchHealth(p.cmdWaitChan)
 		return nil
 	}
 }
 
-// attemptOneShotRegenerate regenerates config.yaml from tracked folders using saved settings.
+// processStartTimeout returns the seconds the process has to write output or open its port
+func (p *Process) processStartTimeout() int {
+	if p.config.ProcessStartTimeout > 0 {
+		return p.config.ProcessStartTimeout
+	}
+	return defaultProcessStartTimeout
+}
+
+// prematureExitError describes an upstream process that exited before it was ready
+func (p *Process) prematureExitError() error {
+	<-p.cmdWaitChan
+	if p.cmdExitErr != nil {
+		return fmt.Errorf("upstream command exited during startup: %w", p.cmdExitErr)
+	}
+	return fmt.Errorf("upstream command exited prematurely but successfully")
+}
+
+// outputWatcher passes process output through to w and records that there was some
+type outputWatcher struct {
+	w    io.Writer
+	seen *atomic.Bool
+	tail *outputTail
+}
+
+func (o *outputWatcher) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		o.seen.Store(true)
+		if o.tail != nil {
+			o.tail.Write(b)
+		}
+	}
+	return o.w.Write(b)
+}
+
+// recordRequest remembers when a request was handled for the keepWarm policy
+func (p *Process) recordRequest(at time.Time) {
+	if !p.config.KeepWarm.Enabled() {
+		return
+	}
+
+	p.recentRequestsMutex.Lock()
+	defer p.recentRequestsMutex.Unlock()
+	p.recentRequests = append(p.pruneRecentRequests(at), at)
+}
+
+// pruneRecentRequests drops timestamps outside of the keepWarm window, the caller must hold recentRequestsMutex
+func (p *Process) pruneRecentRequests(now time.Time) []time.Time {
+	cutoff := now.Add(-p.config.KeepWarm.WindowDuration())
+	i := 0
+	for i < len(p.recentRequests) && p.recentRequests[i].Before(cutoff) {
+		i++
+	}
+	return p.recentRequests[i:]
+}
+
+// effectiveUnloadAfter returns the ttl extended by the keepWarm policy
+func (p *Process) effectiveUnloadAfter(now time.Time) time.Duration {
+	ttl := time.Duration(p.config.UnloadAfter) * time.Second
+	if !p.config.KeepWarm.Enabled() {
+		return ttl
+	}
+
+	p.recentRequestsMutex.Lock()
+	p.recentRequests = p.pruneRecentRequests(now)
+	count := len(p.recentRequests)
+	p.recentRequestsMutex.Unlock()
+
+	return p.config.KeepWarm.EffectiveTTL(ttl, count)
+}
+
+// attemptOneShotRegenerate regenerates the config from tracked folders using saved settings.
 func (p *Process) attemptOneShotRegenerate() error {
+	paths := CurrentFilePaths()
 	// Load folder DB
-	dbPath := "model_folders.json"
+	dbPath := paths.FolderDatabase
 	data, err := os.ReadFile(dbPath)
 	if err != nil {
 		return fmt.Errorf("no folder DB: %v", err)
@@ -402,7 +609,7 @@ func (p *Process) attemptOneShotRegenerate() error {
 
 	// Load settings if present
 	opts := autosetup.SetupOptions{EnableJinja: true, ThroughputFirst: true, MinContext: 16384, PreferredContext: 32768}
-	if sdata, err := os.ReadFile("settings.json"); err == nil {
+	if sdata, err := os.ReadFile(paths.Settings); err == nil {
 		var s struct {
 			Backend          string  `json:"backend"`
 			VRAMGB           float64 `json:"vramGB"`
@@ -446,7 +653,7 @@ func (p *Process) attemptOneShotRegenerate() error {
 	if err != nil {
 		return err
 	}
-	gen := autosetup.NewConfigGenerator(folders[0], bin.Path, "config.yaml", opts)
+	gen := autosetup.NewConfigGenerator(folders[0], bin.Path, paths.Config, opts)
 	gen.SetSystemInfo(&system)
 	gen.SetAvailableVRAM(system.TotalVRAMGB)
 	if err := gen.GenerateConfig(allModels); err != nil {
@@ -469,7 +676,7 @@ func (p *Process) Stop() {
 }
 
 // StopImmediately will transition the process to the stopping state and stop the process with a SIGTERM.
-// If the process does not stop within the specified timeout, it will be forcefully stopped with a SIGKILL.
+// If the process does not stop within the stop grace period, it will be forcefully stopped with a SIGKILL.
 func (p *Process) StopImmediately() {
 	if !isValidTransition(p.CurrentState(), StateStopping) {
 		return
@@ -495,11 +702,11 @@ func (p *Process) Shutdown() {
 
 	p.stopCommand()
 	// just force it to this state since there is no recovery from shutdown
-	p.state = StateShutdown
+	p.forceState(StateShutdown)
 }
 
-// stopCommand will send a SIGTERM to the process and wait for it to exit.
-// If it does not exit within 5 seconds, it will send a SIGKILL.
+// stopCommand stops the process gracefully, see cmdStopUpstreamProcess, and waits for it
+// to exit. If it does not exit within the stop grace period it is killed.
 func (p *Process) stopCommand() {
 	stopStartTime := time.Now()
 	defer func() {
@@ -511,6 +718,7 @@ func (p *Process) stopCommand() {
 		return
 	}
 
+	p.stopRequested.Store(true)
 	p.cancelUpstream()
 	<-p.cmdWaitChan
 }
@@ -559,6 +767,10 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Process can not ProxyRequest, state is %s", currentState), http.StatusServiceUnavailable)
 		return
 	}
+	if currentState == StateReady && !p.Healthy() {
+		http.Error(w, fmt.Sprintf("Process %s is unhealthy, its health check is failing", p.ID), http.StatusServiceUnavailable)
+		return
+	}
 
 	select {
 	case p.concurrencyLimitSemaphore <- struct{}{}:
@@ -569,17 +781,23 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p.inFlightRequests.Add(1)
+	p.inFlightCount.Add(1)
 	defer func() {
 		p.lastRequestHandled = time.Now()
+		p.recordRequest(p.lastRequestHandled)
+		idle := p.inFlightCount.Add(-1) == 0
 		p.inFlightRequests.Done()
+		if idle && p.needsRestart.CompareAndSwap(true, false) {
+			p.proxyLogger.Infof("<%s> Stopping the process after its upstream stream broke", p.ID)
+			go p.Stop()
+		}
 	}()
 
 	// start the process on demand
 	if p.CurrentState() != StateReady {
 		beginStartTime := time.Now()
 		if err := p.start(); err != nil {
-			errstr := fmt.Sprintf("unable to start process: %s", err)
-			http.Error(w, errstr, http.StatusBadGateway)
+			writeLoadError(w, r, err)
 			return
 		}
 		startDuration = time.Since(beginStartTime)
@@ -587,22 +805,67 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 
 	proxyTo := p.config.Proxy
 	client := &http.Client{}
-	req, err := http.NewRequestWithContext(r.Context(), r.Method, proxyTo+r.URL.String(), r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	req.Header = r.Header.Clone()
 
-	contentLength, err := strconv.ParseInt(req.Header.Get("content-length"), 10, 64)
-	if err == nil {
-		req.ContentLength = contentLength
+	// buffer the body so the request can be replayed by the retry policy
+	retry := p.config.Retry
+	var reqBody []byte
+	if retry.Enabled() && isIdempotentRequest(r) {
+		var err error
+		if reqBody, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// streaming requests are never retried
+		if gjson.GetBytes(reqBody, "stream").Bool() {
+			retry = RetryConfig{}
+		}
+	} else {
+		retry = RetryConfig{}
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
-		return
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		var body io.Reader = r.Body
+		if reqBody != nil {
+			body = bytes.NewReader(reqBody)
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, proxyTo+r.URL.String(), body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Header = r.Header.Clone()
+
+		contentLength, err := strconv.ParseInt(req.Header.Get("content-length"), 10, 64)
+		if err == nil {
+			req.ContentLength = contentLength
+		}
+
+		resp, err = client.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		// nothing has been written to the client yet so it is still safe to retry
+		if !retry.ShouldRetry(attempt, resp.StatusCode) {
+			break
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		backoff := retry.BackoffDuration(attempt)
+		p.proxyLogger.Infof("<%s>%s upstream returned %d for %s, retrying in %v (attempt %d of %d)",
+			p.ID, requestTag(r.Context()), resp.StatusCode, r.URL.Path, backoff, attempt+1, retry.MaxAttempts)
+
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			http.Error(w, "request cancelled while waiting to retry", http.StatusServiceUnavailable)
+			return
+		}
 	}
 	defer resp.Body.Close()
 	for k, vv := range resp.Header {
@@ -611,7 +874,8 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	// prevent nginx from buffering streaming responses (e.g., SSE)
-	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream") {
+	isStreaming := strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream")
+	if isStreaming {
 		w.Header().Set("X-Accel-Buffering", "no")
 	}
 	w.WriteHeader(resp.StatusCode)
@@ -632,19 +896,67 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if err != nil {
+			if r.Context().Err() != nil {
+				// the client went away, not the upstream
+				return
+			}
+			if isStreaming {
+				// the status was sent long ago, tell the client in the stream. The
+				// process is restarted once the other requests to it are done.
+				p.proxyLogger.Errorf("<%s>%s upstream stream for %s ended early, restarting the process once idle: %v", p.ID, requestTag(r.Context()), r.URL.Path, err)
+				writeStreamError(w, fmt.Sprintf("upstream %s stopped responding mid-stream: %v", p.ID, err))
+				p.needsRestart.Store(true)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusBadGateway)
 			return
 		}
 	}
 
 	totalTime := time.Since(requestBeginTime)
-	p.proxyLogger.Debugf("<%s> request %s - start: %v, total: %v",
-		p.ID, r.RequestURI, startDuration, totalTime)
+	p.proxyLogger.Debugf("<%s>%s request %s - start: %v, total: %v",
+		p.ID, requestTag(r.Context()), r.RequestURI, startDuration, totalTime)
+}
+
+// writeStreamError ends a streaming response the upstream broke off with an OpenAI
+// compatible error chunk followed by [DONE], so clients can tell a crash from a
+// normal completion
+func writeStreamError(w http.ResponseWriter, message string) {
+	data, _ := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    "server_error",
+			"code":    http.StatusBadGateway,
+		},
+	})
+	// the blank lines end any event the upstream left unfinished
+	fmt.Fprintf(w, "\n\ndata: %s\n\ndata: [DONE]\n\n", data)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// isIdempotentRequest reports if a request can be safely replayed upstream.
+// Inference requests have no side effects so POSTs are replayable, except for
+// llama-server's slot save/restore/erase and props endpoints.
+func isIdempotentRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return !strings.Contains(r.URL.Path, "/slots") && !strings.HasSuffix(r.URL.Path, "/props")
+	default:
+		return false
+	}
 }
 
 // waitForCmd waits for the command to exit and handles exit conditions depending on current state
 func (p *Process) waitForCmd() {
 	exitErr := p.cmd.Wait()
+	p.cmdExitErr = exitErr
+	if p.logFile != nil {
+		p.logFile.Close()
+	}
 	p.proxyLogger.Debugf("<%s> cmd.Wait() returned error: %v", p.ID, exitErr)
 
 	if exitErr != nil {
@@ -665,20 +977,34 @@ func (p *Process) waitForCmd() {
 		}
 	}
 
+	p.markStopped()
+}
+
+// markStopped moves the process to StateStopped once its command exited, or a
+// remote server is no longer routed to, and releases the waiters on cmdWaitChan
+func (p *Process) markStopped() {
 	currentState := p.CurrentState()
 	switch currentState {
 	case StateStopping:
 		if curState, err := p.swapState(StateStopping, StateStopped); err != nil {
 			p.proxyLogger.Errorf("<%s> Process exited but could not swap to StateStopped. curState=%s, err: %v", p.ID, curState, err)
-			p.state = StateStopped
+			p.forceState(StateStopped)
 		}
 	default:
 		p.proxyLogger.Infof("<%s> process exited but not StateStopping, current state: %s", p.ID, currentState)
-		p.state = StateStopped // force it to be in this state
+		p.forceState(StateStopped)
 	}
 	close(p.cmdWaitChan)
 }
 
+// forceState sets the state without checking the transition, for a process
+// that is gone whatever state it was in
+func (p *Process) forceState(newState ProcessState) {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+	p.state = newState
+}
+
 // cmdStopUpstreamProcess attemps to stop the upstream process gracefully
 func (p *Process) cmdStopUpstreamProcess() error {
 	p.processLogger.Debugf("<%s> cmdStopUpstreamProcess() initiating graceful stop of upstream process", p.ID)
@@ -708,6 +1034,13 @@ func (p *Process) cmdStopUpstreamProcess() error {
 			p.proxyLogger.Errorf("<%s> Failed to exec stop command: %v", p.ID, err)
 			return err
 		}
+	} else if runtime.GOOS == "windows" {
+		// there is no SIGTERM on Windows, ask the process tree to close without /f
+		stopCmd := exec.Command("taskkill", "/t", "/pid", strconv.Itoa(p.cmd.Process.Pid))
+		if err := stopCmd.Run(); err != nil {
+			p.proxyLogger.Errorf("<%s> Failed to exec taskkill: %v", p.ID, err)
+			return err
+		}
 	} else {
 		if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
 			p.proxyLogger.Errorf("<%s> Failed to send SIGTERM to process: %v", p.ID, err)
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/event"
+)
+
+// failedHealthChecks is how many health checks in a row have to fail before a
+// ready process counts as unhealthy, so one slow response doesn't take it out
+const failedHealthChecks = 2
+
+// watchHealth polls the health endpoint of a ready process every healthCheckInterval
+// until it exits, see healthCheckInterval in config.go
+func (p *Process) watchHealth(exited <-chan struct{}) {
+	checkEndpoint := strings.TrimSpace(p.config.CheckEndpoint)
+	if p.healthWatchInterval <= 0 || checkEndpoint == "none" {
+		return
+	}
+	healthURL, err := url.JoinPath(p.config.Proxy, checkEndpoint)
+	if err != nil {
+		return
+	}
+
+	// exited closes when this run of the process ends, a restart starts a new watcher
+	ticker := time.NewTicker(p.healthWatchInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+		if p.CurrentState() != StateReady {
+			return
+		}
+		if err := p.checkHealthEndpoint(healthURL); err != nil {
+			failures++
+			p.proxyLogger.Debugf("<%s> Health check failed (%d in a row): %v", p.ID, failures, err)
+			if failures == failedHealthChecks {
+				p.setHealthy(false)
+			}
+			continue
+		}
+		failures = 0
+		p.setHealthy(true)
+	}
+}
+
+// setHealthy records the health of a ready process, emitting ProcessHealthChangedEvent on a change
+func (p *Process) setHealthy(healthy bool) {
+	if p.unhealthy.Swap(!healthy) == !healthy {
+		return
+	}
+	if healthy {
+		p.proxyLogger.Infof("<%s> Health check passing again, routing requests to it", p.ID)
+	} else {
+		p.proxyLogger.Warnf("<%s> Health check failing, not routing requests to it until it recovers", p.ID)
+	}
+	event.Emit(ProcessHealthChangedEvent{ProcessName: p.ID, Healthy: healthy})
+}
+
+// Healthy is false while a ready process fails its periodic health checks
+func (p *Process) Healthy() bool {
+	return !p.unhealthy.Load()
+}
+
+// healthyInstance returns the model to route a request for modelID to, the model
+// itself unless its process is ready but unhealthy, then the first of its
+// fallbackModels that is not and that the request's API key may use. With no
+// healthy one it returns modelID, whose process then rejects the request.
+func (pm *ProxyManager) healthyInstance(c *gin.Context, modelID string) string {
+	candidates := append([]string{modelID}, pm.config.Models[modelID].FallbackModels...)
+	for _, candidate := range candidates {
+		if candidate != modelID && !pm.allowedModel(c, candidate) {
+			continue
+		}
+		process := pm.modelProcess(candidate)
+		if process == nil || process.CurrentState() != StateReady || process.Healthy() {
+			return candidate
+		}
+	}
+	return modelID
+}
@@ -0,0 +1,15 @@
+package proxy
+
+// niceness range of setpriority(2), also used on Windows to pick a priority class
+const (
+	minNiceness = -20
+	maxNiceness = 19
+)
+
+// niceness of the model's process, 0 when it is not set
+func (m ModelConfig) niceness() int {
+	if m.Niceness == nil {
+		return 0
+	}
+	return *m.Niceness
+}
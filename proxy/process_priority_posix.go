@@ -0,0 +1,21 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configurePriority is a no-op, niceness is set with setpriority once the process runs
+func configurePriority(cmd *exec.Cmd, niceness int) {}
+
+// applyPriority sets the niceness of the started process. On Linux that is its
+// main thread only, threads and children it creates afterwards inherit it but
+// ones started before keep theirs. Negative values need root or CAP_SYS_NICE.
+func applyPriority(cmd *exec.Cmd, niceness int) error {
+	if niceness == 0 || cmd.Process == nil {
+		return nil
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, cmd.Process.Pid, niceness)
+}
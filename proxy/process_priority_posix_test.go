@@ -0,0 +1,28 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcess_Niceness(t *testing.T) {
+	config := fakeUpstreamModel(newFakeUpstream(t, nil))
+	config.Niceness = intPtr(10)
+	process := NewProcess("nice-process", 15, config, debugLogger, debugLogger)
+	defer process.StopImmediately()
+	if !assert.NoError(t, process.start()) {
+		return
+	}
+
+	out, err := exec.Command("ps", "-o", "nice=", "-p", strconv.Itoa(process.cmd.Process.Pid)).Output()
+	if err != nil {
+		t.Skipf("ps is not available: %v", err)
+	}
+	assert.Equal(t, "10", strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package proxy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Windows process priority classes, see CreateProcess
+const (
+	idlePriorityClass        = 0x00000040
+	belowNormalPriorityClass = 0x00004000
+	aboveNormalPriorityClass = 0x00008000
+	highPriorityClass        = 0x00000080
+)
+
+// priorityClass maps a niceness to the nearest Windows priority class, 0 when normal
+func priorityClass(niceness int) uint32 {
+	switch {
+	case niceness >= 15:
+		return idlePriorityClass
+	case niceness > 0:
+		return belowNormalPriorityClass
+	case niceness <= -10:
+		return highPriorityClass
+	case niceness < 0:
+		return aboveNormalPriorityClass
+	}
+	return 0
+}
+
+// configurePriority creates the process in the priority class closest to niceness.
+// Windows has no niceness, only these few classes.
+func configurePriority(cmd *exec.Cmd, niceness int) {
+	class := priorityClass(niceness)
+	if class == 0 {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= class
+}
+
+// applyPriority is a no-op, the priority class is set when the process is created
+func applyPriority(cmd *exec.Cmd, niceness int) error {
+	return nil
+}
@@ -2,15 +2,21 @@ package proxy
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prave/FrogLLM/event"
 	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
 )
 
 var (
@@ -334,10 +340,87 @@ func TestProcess_ExitInterruptsHealthCheck(t *testing.T) {
 	process := NewProcess("sleepy", checkHealthTimeout, config, debugLogger, debugLogger)
 	process.healthCheckLoopInterval = time.Second // make it faster
 	err := process.start()
-	assert.Equal(t, "upstream command exited prematurely but successfully", err.Error())
+	assert.Equal(t, "crashed: upstream command exited prematurely but successfully", err.Error())
 	assert.Equal(t, process.CurrentState(), StateStopped)
 }
 
+func TestProcess_ExitDuringStartFailsFast(t *testing.T) {
+	config := ModelConfig{
+		Cmd:           `sh -c "exit 3"`,
+		Proxy:         "http://127.0.0.1:9914",
+		CheckEndpoint: "/health",
+	}
+
+	process := NewProcess("exits", 30, config, debugLogger, debugLogger)
+	startTime := time.Now()
+	err := process.start()
+	assert.ErrorContains(t, err, "upstream command exited during startup: exit status 3")
+	assert.Less(t, time.Since(startTime), 5*time.Second, "should not wait for the health check loop")
+	assert.Equal(t, StateStopped, process.CurrentState())
+}
+
+func TestProcess_RemoteShutdown(t *testing.T) {
+	remote := newFakeUpstream(t, nil)
+
+	process := NewProcess("remote", 30, ModelConfig{Proxy: remote.URL, CheckEndpoint: "/health"}, debugLogger, debugLogger)
+	assert.NoError(t, process.start())
+	assert.Equal(t, StateReady, process.CurrentState())
+
+	// the remote goroutine forces StateStopped, then Shutdown forces StateShutdown
+	process.Shutdown()
+	assert.Equal(t, StateShutdown, process.CurrentState())
+	assert.Error(t, process.start(), "a shut down process is not started again")
+	assert.Equal(t, StateShutdown, process.CurrentState())
+}
+
+func TestProcess_BlockStarts(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer remote.Close()
+
+	process := NewProcess("remote", 30, ModelConfig{Proxy: remote.URL, CheckEndpoint: "/health"}, debugLogger, debugLogger)
+	assert.True(t, process.blockStarts())
+	assert.ErrorContains(t, process.start(), ErrStartsBlocked.Error())
+	assert.Equal(t, StateStopped, process.CurrentState())
+
+	process.unblockStarts()
+	assert.NoError(t, process.start())
+	assert.False(t, process.blockStarts(), "a ready process is not blocked")
+	process.Shutdown()
+}
+
+func TestProcess_ProcessStartTimeout(t *testing.T) {
+	// never writes output or opens its port
+	config := ModelConfig{
+		Cmd:                 fakeUpstreamCmd,
+		Proxy:               "http://127.0.0.1:9915",
+		CheckEndpoint:       "/health",
+		ProcessStartTimeout: 1,
+	}
+
+	process := NewProcess("silent", 30, config, debugLogger, debugLogger)
+	process.healthCheckLoopInterval = 100 * time.Millisecond
+	startTime := time.Now()
+	err := process.start()
+	assert.ErrorContains(t, err, "wrote no output and did not open http://127.0.0.1:9915 within 1s")
+	assert.Less(t, time.Since(startTime), 10*time.Second, "should fail before the health check timeout")
+	assert.Eventually(t, func() bool { return process.CurrentState() == StateStopped }, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestProcess_ProcessStartTimeoutIgnoredWithOutput(t *testing.T) {
+	// writes output but is slow to open its port, like a model that is loading
+	config := ModelConfig{
+		Cmd:                 `sh -c "echo loading; exec sleep 60"`,
+		Proxy:               "http://127.0.0.1:9916",
+		CheckEndpoint:       "/health",
+		ProcessStartTimeout: 1,
+	}
+
+	process := NewProcess("loading", 3, config, debugLogger, debugLogger)
+	process.healthCheckLoopInterval = 100 * time.Millisecond
+	err := process.start()
+	assert.ErrorContains(t, err, "health check timed out after 3s")
+}
+
 func TestProcess_ConcurrencyLimit(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping long concurrency limit test")
@@ -449,6 +532,52 @@ func TestProcess_ForceStopWithKill(t *testing.T) {
 	<-waitChan
 }
 
+func TestProcess_StopGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping SIGTERM test on Windows")
+	}
+
+	t.Run("process ignoring SIGTERM is killed after the grace period", func(t *testing.T) {
+		config := ModelConfig{
+			Cmd:             `sh -c "trap '' TERM; echo started; exec sleep 60"`,
+			Proxy:           "http://127.0.0.1:9917",
+			CheckEndpoint:   "none",
+			StopGracePeriod: 1,
+		}
+		process := NewProcess("ignores-sigterm", 5, config, debugLogger, debugLogger)
+		assert.Equal(t, time.Second, process.gracefulStopTimeout)
+		if !assert.NoError(t, process.start()) {
+			return
+		}
+
+		stopStartTime := time.Now()
+		process.StopImmediately()
+		assert.GreaterOrEqual(t, time.Since(stopStartTime), time.Second)
+		assert.Less(t, time.Since(stopStartTime), 5*time.Second)
+		assert.Equal(t, StateStopped, process.CurrentState())
+		assert.Equal(t, "signal: killed", process.cmd.ProcessState.String())
+	})
+
+	t.Run("process handling SIGTERM stops without waiting", func(t *testing.T) {
+		config := ModelConfig{
+			Cmd:             fakeUpstreamCmd,
+			Proxy:           "http://127.0.0.1:9918",
+			CheckEndpoint:   "none",
+			StopGracePeriod: 10,
+		}
+		process := NewProcess("handles-sigterm", 5, config, debugLogger, debugLogger)
+		if !assert.NoError(t, process.start()) {
+			return
+		}
+
+		stopStartTime := time.Now()
+		process.StopImmediately()
+		assert.Less(t, time.Since(stopStartTime), 5*time.Second)
+		assert.Equal(t, StateStopped, process.CurrentState())
+		assert.Equal(t, "signal: terminated", process.cmd.ProcessState.String())
+	})
+}
+
 func TestProcess_StopCmd(t *testing.T) {
 	config := getTestSimpleResponderConfig("test_stop_cmd")
 
@@ -491,3 +620,348 @@ func TestProcess_EnvironmentSetCorrectly(t *testing.T) {
 	assert.Equal(t, len(process1.cmd.Environ())+2, len(process2.cmd.Environ()), "process2 should have 2 more environment variables than process1")
 
 }
+
+func TestProcess_KeepWarmAdaptiveTTL(t *testing.T) {
+	config := getTestSimpleResponderConfig("keepwarm")
+	config.UnloadAfter = 60
+	config.KeepWarm = KeepWarmConfig{
+		ExtraTTL:     300,
+		Window:       600,
+		BusyRequests: 10,
+	}
+
+	now := time.Now()
+
+	t.Run("idle model keeps configured ttl", func(t *testing.T) {
+		process := NewProcess("idle", 5, config, debugLogger, debugLogger)
+		assert.Equal(t, 60*time.Second, process.effectiveUnloadAfter(now))
+	})
+
+	t.Run("busy model gets full extra ttl", func(t *testing.T) {
+		process := NewProcess("busy", 5, config, debugLogger, debugLogger)
+		// 20 requests over the last 2 minutes
+		for i := 0; i < 20; i++ {
+			process.recordRequest(now.Add(-time.Duration(120-i*6) * time.Second))
+		}
+		assert.Equal(t, 360*time.Second, process.effectiveUnloadAfter(now))
+	})
+
+	t.Run("light traffic gets a proportional share", func(t *testing.T) {
+		process := NewProcess("light", 5, config, debugLogger, debugLogger)
+		for i := 0; i < 5; i++ {
+			process.recordRequest(now.Add(-time.Duration(i) * time.Minute))
+		}
+		assert.Equal(t, 210*time.Second, process.effectiveUnloadAfter(now))
+	})
+
+	t.Run("old traffic falls out of the window", func(t *testing.T) {
+		process := NewProcess("burst", 5, config, debugLogger, debugLogger)
+		// a burst 15 minutes ago, outside of the 10 minute window
+		for i := 0; i < 20; i++ {
+			process.recordRequest(now.Add(-15 * time.Minute))
+		}
+		process.recordRequest(now.Add(-time.Minute))
+		assert.Equal(t, 90*time.Second, process.effectiveUnloadAfter(now))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		config := getTestSimpleResponderConfig("keepwarm")
+		config.UnloadAfter = 60
+		process := NewProcess("disabled", 5, config, debugLogger, debugLogger)
+		for i := 0; i < 20; i++ {
+			process.recordRequest(now)
+		}
+		assert.Equal(t, 60*time.Second, process.effectiveUnloadAfter(now))
+	})
+}
+
+func TestProcess_RetryPolicy(t *testing.T) {
+	newReadyProcess := func(upstreamURL string) *Process {
+		config := getTestSimpleResponderConfig("retry")
+		config.Proxy = upstreamURL
+		config.Retry = RetryConfig{MaxAttempts: 3, Backoff: 1}
+		process := NewProcess("retry", 5, config, debugLogger, debugLogger)
+		// the upstream is an httptest server, no need to start a process
+		process.state = StateReady
+		return process
+	}
+
+	t.Run("retries on 503", func(t *testing.T) {
+		var attempts int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				http.Error(w, "no slot available", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(body)
+		}))
+		defer upstream.Close()
+
+		process := newReadyProcess(upstream.URL)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"retry"}`))
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"model":"retry"}`, w.Body.String())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var attempts int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			http.Error(w, "no slot available", http.StatusServiceUnavailable)
+		}))
+		defer upstream.Close()
+
+		process := newReadyProcess(upstream.URL)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("does not retry other status codes", func(t *testing.T) {
+		var attempts int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}))
+		defer upstream.Close()
+
+		process := newReadyProcess(upstream.URL)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("no retry after stream started", func(t *testing.T) {
+		var attempts int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				http.Error(w, "no slot available", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"choices\":[]}\n\n"))
+		}))
+		defer upstream.Close()
+
+		process := newReadyProcess(upstream.URL)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"stream":true}`))
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+}
+
+func TestProcess_UpstreamDiesMidStream(t *testing.T) {
+	slowStarted, releaseSlow := make(chan struct{}), make(chan struct{})
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.Write([]byte("ok"))
+			return
+		}
+		if r.URL.Path == "/slow" {
+			close(slowStarted)
+			<-releaseSlow
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"con")
+		w.(http.Flusher).Flush()
+		// drop the connection without ending the chunked response, like a crash
+		panic(http.ErrAbortHandler)
+	})
+
+	config := fakeUpstreamModel(upstream)
+	process := NewProcess("dies-mid-stream", 5, config, debugLogger, debugLogger)
+	defer process.Stop()
+
+	// another request is in flight when the stream breaks
+	slow := httptest.NewRecorder()
+	slowDone := make(chan struct{})
+	go func() {
+		process.ProxyRequest(slow, httptest.NewRequest("GET", "/slow", nil))
+		close(slowDone)
+	}()
+	<-slowStarted
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"stream":true}`))
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"), body)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"), body)
+
+	var errorChunk gjson.Result
+	for _, line := range strings.Split(body, "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok && gjson.Get(data, "error").Exists() {
+			errorChunk = gjson.Parse(data)
+		}
+	}
+	assert.Equal(t, "server_error", errorChunk.Get("error.type").String(), body)
+	assert.Equal(t, int64(http.StatusBadGateway), errorChunk.Get("error.code").Int())
+	assert.Contains(t, errorChunk.Get("error.message").String(), "mid-stream")
+
+	// the other request finishes, then the process is stopped so the next
+	// request starts a fresh one
+	assert.Equal(t, StateReady, process.CurrentState())
+	close(releaseSlow)
+	<-slowDone
+	assert.Equal(t, http.StatusOK, slow.Code)
+	assert.Equal(t, "ok", slow.Body.String())
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestProcess_LoadFailReason(t *testing.T) {
+	config := ModelConfig{
+		Cmd:           `sh -c "echo 'ggml_backend_cuda_buffer_type_alloc_buffer: allocating 9000 MiB on device 0: cudaMalloc failed: out of memory' >&2; echo 'llama_model_load: error loading model' >&2; exit 1"`,
+		Proxy:         "http://127.0.0.1:1",
+		CheckEndpoint: "/health",
+	}
+	process := NewProcess("oom-model", 5, config, debugLogger, debugLogger)
+
+	failed := make(chan ModelLoadFailedEvent, 1)
+	defer event.On(func(e ModelLoadFailedEvent) {
+		failed <- e
+	})()
+
+	err := process.start()
+	var startErr *LoadError
+	if assert.ErrorAs(t, err, &startErr) {
+		assert.Equal(t, LoadErrorOutOfMemory, startErr.Code)
+		assert.Equal(t, 1, startErr.ExitCode)
+	}
+	loadError, loading := process.loadStatus()
+	assert.Equal(t, time.Duration(0), loading)
+	if !assert.NotNil(t, loadError) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(loadError.Reason, "out of memory: "), loadError.Reason)
+	assert.Contains(t, loadError.Reason, "cudaMalloc failed")
+	assert.NotEmpty(t, loadError.Suggestion)
+
+	select {
+	case e := <-failed:
+		assert.Equal(t, "oom-model", e.ModelName)
+		assert.Equal(t, loadError.Reason, e.Reason)
+		assert.Equal(t, LoadErrorOutOfMemory, e.Code)
+	case <-time.After(time.Second):
+		t.Fatal("expected a ModelLoadFailedEvent")
+	}
+}
+
+func TestClassifyLoadFailure(t *testing.T) {
+	exited := fmt.Errorf("upstream command exited during startup: exit status 1")
+	tests := []struct {
+		name   string
+		err    error
+		output string
+		code   LoadErrorCode
+		reason string
+	}{
+		{"cuda oom", exited, "ggml_backend_cuda_buffer_type_alloc_buffer: allocating 20480.00 MiB on device 0: cudaMalloc failed: out of memory\nllama_model_load: error loading model\n",
+			LoadErrorOutOfMemory, "out of memory: ggml_backend_cuda_buffer_type_alloc_buffer: allocating 20480.00 MiB on device 0: cudaMalloc failed: out of memory"},
+		{"vulkan oom", exited, "ggml_vulkan: Device memory allocation of size 4294967296 failed.\nvk::Device::allocateMemory: ErrorOutOfDeviceMemory\n",
+			LoadErrorOutOfMemory, "out of memory: vk::Device::allocateMemory: ErrorOutOfDeviceMemory"},
+		{"missing file", exited, "main: loading model\ngguf_init_from_file: failed to open GGUF file 'model.gguf' (No such file or directory)\n",
+			LoadErrorModelNotFound, "model file not found: gguf_init_from_file: failed to open GGUF file 'model.gguf' (No such file or directory)"},
+		{"unsupported arch", exited, "llama_model_load: error loading model: unknown model architecture: 'foo'\n",
+			LoadErrorUnsupportedArch, "unsupported model: llama_model_load: error loading model: unknown model architecture: 'foo'"},
+		{"port bind", exited, "main: couldn't bind HTTP server socket, hostname: 127.0.0.1, port: 8100\n",
+			LoadErrorPortInUse, "port in use: main: couldn't bind HTTP server socket, hostname: 127.0.0.1, port: 8100"},
+		{"flag error", exited, "error: invalid argument: --flash-attn-typo\n",
+			LoadErrorInvalidFlag, "invalid flag: error: invalid argument: --flash-attn-typo"},
+		{"corrupt file", exited, "gguf_init_from_file_impl: invalid magic characters: 'ABCD', expected 'GGUF'\n",
+			LoadErrorModelLoadFailed, "model failed to load: gguf_init_from_file_impl: invalid magic characters: 'ABCD', expected 'GGUF'"},
+		{"crash", exited, "segfault\n", LoadErrorCrashed, "crashed: " + exited.Error()},
+		{"timeout", fmt.Errorf("health check timed out after 5s"), "", LoadErrorTimeout, "timed out: health check timed out after 5s"},
+		{"unknown", fmt.Errorf("something else"), "", LoadErrorUnknown, "something else"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadErr := classifyLoadFailure(tt.err, tt.output)
+			assert.Equal(t, tt.code, loadErr.Code)
+			assert.Equal(t, tt.reason, loadErr.Reason)
+			assert.NotEmpty(t, loadErr.Suggestion)
+			assert.ErrorIs(t, loadErr, tt.err)
+		})
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// a shell that can't find the binary exits with 127
+	exitErr := exec.Command("sh", "-c", "exit 127").Run()
+	loadErr := classifyLoadFailure(fmt.Errorf("upstream command exited during startup: %w", exitErr), "sh: 1: llama-server: not found\n")
+	assert.Equal(t, LoadErrorBinaryNotFound, loadErr.Code)
+	assert.Equal(t, 127, loadErr.ExitCode)
+}
+
+func TestProcess_HealthGatedRouting(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	config := fakeUpstreamModel(upstream)
+	process := NewProcess("watched", 15, config, debugLogger, debugLogger)
+	process.healthWatchInterval = 20 * time.Millisecond
+	defer process.Stop()
+
+	changes := make(chan bool, 4)
+	defer event.On(func(e ProcessHealthChangedEvent) {
+		if e.ProcessName == "watched" {
+			changes <- e.Healthy
+		}
+	})()
+
+	request := func() int {
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, httptest.NewRequest("GET", "/v1/models", nil))
+		return w.Code
+	}
+	expectChange := func(want bool) {
+		t.Helper()
+		select {
+		case got := <-changes:
+			assert.Equal(t, want, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected a ProcessHealthChangedEvent with healthy=%v", want)
+		}
+	}
+
+	assert.Equal(t, http.StatusOK, request())
+	assert.True(t, process.Healthy())
+
+	healthy.Store(false)
+	expectChange(false)
+	assert.False(t, process.Healthy())
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.Equal(t, http.StatusServiceUnavailable, request())
+
+	healthy.Store(true)
+	expectChange(true)
+	assert.Equal(t, http.StatusOK, request())
+}
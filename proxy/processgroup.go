@@ -15,6 +15,9 @@ type ProcessGroup struct {
 	exclusive  bool
 	persistent bool
 
+	// members share one port, see shared_process.go
+	sharedProcess bool
+
 	proxyLogger    *LogMonitor
 	upstreamLogger *LogMonitor
 
@@ -35,6 +38,7 @@ func NewProcessGroup(id string, config Config, proxyLogger *LogMonitor, upstream
 		swap:           groupConfig.Swap,
 		exclusive:      groupConfig.Exclusive,
 		persistent:     groupConfig.Persistent,
+		sharedProcess:  groupConfig.SharedProcess,
 		proxyLogger:    proxyLogger,
 		upstreamLogger: upstreamLogger,
 		processes:      make(map[string]*Process),
@@ -44,6 +48,7 @@ func NewProcessGroup(id string, config Config, proxyLogger *LogMonitor, upstream
 	for _, modelID := range groupConfig.Members {
 		modelConfig, modelID, _ := pg.config.FindConfig(modelID)
 		process := NewProcess(modelID, pg.config.HealthCheckTimeout, modelConfig, pg.upstreamLogger, pg.proxyLogger)
+		process.logFile = newModelLogFile(pg.config.ModelLogs, modelID)
 		pg.processes[modelID] = process
 	}
 
@@ -63,11 +68,26 @@ func (pg *ProcessGroup) ProxyRequest(modelID string, writer http.ResponseWriter,
 	}
 
 	if pg.swap {
+		cooldown := swapCooldownDuration(pg.config)
 		pg.Lock()
+		// let the running model finish its cooldown first, see swap_cooldown.go
+		for pg.lastUsedProcess != modelID && cooldown > 0 {
+			residentModel, wait := pg.residentInCooldown(cooldown, modelID)
+			if wait <= 0 {
+				break
+			}
+			pg.Unlock()
+			if err := deferSwap(request.Context(), pg.proxyLogger, modelID, residentModel, wait); err != nil {
+				return err
+			}
+			pg.Lock()
+		}
 		if pg.lastUsedProcess != modelID {
 
 			// is there something already running?
-			if pg.lastUsedProcess != "" && pg.processes[pg.lastUsedProcess] != nil {
+			if pg.sharedProcess {
+				pg.releaseSharedPort(modelID)
+			} else if pg.lastUsedProcess != "" && pg.processes[pg.lastUsedProcess] != nil {
 				pg.processes[pg.lastUsedProcess].Stop()
 			}
 
@@ -105,6 +125,16 @@ func (pg *ProcessGroup) HasMember(modelName string) bool {
 	return hasProcess
 }
 
+// processState returns the state of a model's process, stopped when it has none
+func (pg *ProcessGroup) processState(modelID string) ProcessState {
+	pg.Lock()
+	defer pg.Unlock()
+	if process, found := pg.processes[modelID]; found {
+		return process.CurrentState()
+	}
+	return StateStopped
+}
+
 func (pg *ProcessGroup) StopProcesses(strategy StopStrategy) {
 	pg.Lock()
 	defer pg.Unlock()
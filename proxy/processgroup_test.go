@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -112,3 +113,49 @@ func TestProcessGroup_ProxyRequestSwapIsFalse(t *testing.T) {
 		assert.Equal(t, StateReady, process.CurrentState())
 	}
 }
+
+func TestProcessGroup_SharedProcessSwitchesMembers(t *testing.T) {
+	upstream := newFakeUpstream(t, nil)
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": fakeUpstreamModel(upstream),
+			"model2": fakeUpstreamModel(upstream),
+			"model3": fakeUpstreamModel(upstream),
+		},
+		Groups: map[string]GroupConfig{
+			"G1": {Swap: true, SharedProcess: true, Members: []string{"model1", "model2", "model3"}},
+		},
+	})
+	pg := NewProcessGroup("G1", config, testLogger, testLogger)
+	defer pg.StopProcesses(StopImmediately)
+
+	for _, modelID := range []string{"model1", "model2", "model1", "model3"} {
+		w := httptest.NewRecorder()
+		assert.NoError(t, pg.ProxyRequest(modelID, w, httptest.NewRequest("GET", "/test", nil)))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// only the requested member runs on the shared port
+		for memberID, process := range pg.processes {
+			if memberID == modelID {
+				assert.Equal(t, StateReady, process.CurrentState(), memberID)
+			} else {
+				assert.Equal(t, StateStopped, process.CurrentState(), memberID)
+			}
+		}
+	}
+
+	// a member started outside the group, still starting when another is
+	// requested, is waited for and stopped before the switch
+	starting := make(chan error)
+	go func() { starting <- pg.processes["model2"].start() }()
+	assert.Eventually(t, func() bool { return pg.processes["model2"].CurrentState() != StateStopped }, time.Second, 5*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	assert.NoError(t, pg.ProxyRequest("model1", w, httptest.NewRequest("GET", "/test", nil)))
+	assert.NoError(t, <-starting)
+	assert.Equal(t, StateStopped, pg.processes["model2"].CurrentState())
+	assert.Equal(t, StateStopped, pg.processes["model3"].CurrentState())
+	assert.Equal(t, StateReady, pg.processes["model1"].CurrentState())
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -35,6 +36,10 @@ type ProxyManager struct {
 	configPath string // Path to the config file
 	ginEngine  *gin.Engine
 
+	// config profiles, see config_profiles.go
+	baseConfigPath string
+	activeProfile  string
+
 	// logging
 	proxyLogger    *LogMonitor
 	upstreamLogger *LogMonitor
@@ -52,9 +57,43 @@ type ProxyManager struct {
 
 	// subscription canceller for download progress
 	downloadSubCancel context.CancelFunc
+	webhookSubCancel  context.CancelFunc
 
 	// debounce timer for auto reconfigure after downloads
 	autoReconfigTimer *time.Timer
+
+	// starts a model once to check it reaches health, replaceable for testing
+	modelVerifier func(modelID string, modelConfig ModelConfig) error
+
+	// GGUF embedding_length by model path
+	embeddingDimCache sync.Map
+
+	// HuggingFace API base URL, replaceable for testing
+	huggingFaceURL string
+
+	// settings.json in memory, see settings_reload.go
+	systemSettings settingsCache
+
+	// enriched results of recent model searches, see search_cache.go
+	searchCache *searchCache
+
+	// auto-downloads requests for unconfigured models wait on, see auto_download_queue.go
+	autoDownloads autoDownloadQueue
+
+	// latest GPU error states, polled when gpuHealth is enabled
+	gpuHealth *gpuHealthMonitor
+
+	// latest GPU temperatures and clocks, sampled while models generate when gpuThermal is enabled
+	gpuThermal *gpuThermalMonitor
+
+	// measures free and total VRAM in GB after unloads, replaceable for testing
+	measureVRAM func() (free, total float64, err error)
+
+	// serializes moving model files to and from archiveDir
+	archiveMu sync.Mutex
+
+	// serializes edits of the config file, see updateConfigFile
+	configWriteMu sync.Mutex
 }
 
 func New(config Config) *ProxyManager {
@@ -62,6 +101,9 @@ func New(config Config) *ProxyManager {
 	stdoutLogger := NewLogMonitorWriter(os.Stdout)
 	upstreamLogger := NewLogMonitorWriter(stdoutLogger)
 	proxyLogger := NewLogMonitorWriter(stdoutLogger)
+	for _, logger := range []*LogMonitor{stdoutLogger, upstreamLogger, proxyLogger} {
+		logger.SetHistoryLimit(config.LogHistory.MaxBytes, config.LogHistory.MaxLines)
+	}
 
 	if config.LogRequests {
 		proxyLogger.Warn("LogRequests configuration is deprecated. Use logLevel instead.")
@@ -93,16 +135,19 @@ func New(config Config) *ProxyManager {
 		downloadDir = "./downloads"
 	}
 
+	configPath := CurrentFilePaths().Config
 	pm := &ProxyManager{
 		config:     config,
-		configPath: "config.yaml", // Default path, can be overridden
+		configPath: configPath, // can be overridden with SetConfigPath
 		ginEngine:  gin.New(),
 
+		baseConfigPath: configPath,
+
 		proxyLogger:    proxyLogger,
 		muxLogger:      stdoutLogger,
 		upstreamLogger: upstreamLogger,
 
-		metricsMonitor:  NewMetricsMonitor(&config, "config.yaml"),
+		metricsMonitor:  NewMetricsMonitor(&config, configPath),
 		downloadManager: NewDownloadManager(downloadDir, proxyLogger),
 
 		processGroups: make(map[string]*ProcessGroup),
@@ -110,6 +155,12 @@ func New(config Config) *ProxyManager {
 		shutdownCtx:    shutdownCtx,
 		shutdownCancel: shutdownCancel,
 	}
+	pm.modelVerifier = pm.verifyModelStarts
+	pm.huggingFaceURL = "https://huggingface.co"
+	pm.searchCache = newSearchCache(searchCacheTTL)
+	pm.gpuHealth = newGPUHealthMonitor()
+	pm.gpuThermal = newGPUThermalMonitor()
+	pm.measureVRAM = autosetup.MeasureVRAM
 
 	// create the process groups
 	for groupID := range config.Groups {
@@ -119,39 +170,49 @@ func New(config Config) *ProxyManager {
 
 	pm.setupGinEngine()
 
+	if config.GPUHealth.Enabled {
+		go pm.watchGPUHealth(config.GPUHealth.IntervalDuration())
+	}
+	if config.GPUThermal.Enabled {
+		go pm.watchGPUThermal(config.GPUThermal.IntervalDuration())
+	}
+
+	pm.metricsMonitor.ActivityStats.startFlushing(shutdownCtx, config.ActivityStats)
+
 	// No automatic config modifications on startup - keep it clean and predictable
 
 	// Subscribe to download completion to add folder to DB and auto-regenerate config
 	pm.downloadSubCancel = event.On(func(e DownloadProgressEvent) {
 		if e.Info != nil && e.Info.Status == StatusCompleted {
-			go pm.handleDownloadCompleted(e.Info.FilePath)
+			go pm.handleDownloadCompleted(*e.Info)
 		}
 	})
 
-	// run any startup hooks
-	if len(config.Hooks.OnStartup.Preload) > 0 {
+	pm.webhookSubCancel = pm.subscribeWebhooks()
+
+	if err := autosetup.SetHTTPProxy(config.HTTPProxy); err != nil {
+		proxyLogger.Warnf("Ignoring httpProxy: %v", err)
+		autosetup.SetHTTPProxy("")
+	}
+	autosetup.SetBinaryMirrors(config.BinaryMirrors)
+	pm.downloadManager.SetMirrors(config.HFMirrors)
+	pm.downloadManager.SetBandwidthLimit(config.MaxDownloadMBps)
+	pm.downloadManager.SetPartConcurrency(config.MultiPartConcurrency)
+
+	// pick up downloads a crash or restart interrupted, after subscribing so
+	// resumed downloads are handled like any other when they complete
+	pm.downloadManager.ReconcileStaleDownloads(config.StaleDownloads != StaleDownloadsFail)
+
+	// run any startup hooks, then keep the most used models warm
+	startupPreload := len(config.Hooks.OnStartup.Preload) > 0
+	if startupPreload || config.PredictivePreload.Enabled {
 		// do it in the background, don't block startup -- not sure if good idea yet
 		go func() {
-			discardWriter := &DiscardWriter{}
-			for _, realModelName := range config.Hooks.OnStartup.Preload {
-				proxyLogger.Infof("Preloading model: %s", realModelName)
-				processGroup, _, err := pm.swapProcessGroup(realModelName)
-
-				if err != nil {
-					event.Emit(ModelPreloadedEvent{
-						ModelName: realModelName,
-						Success:   false,
-					})
-					proxyLogger.Errorf("Failed to preload model %s: %v", realModelName, err)
-					continue
-				} else {
-					req, _ := http.NewRequest("GET", "/", nil)
-					processGroup.ProxyRequest(realModelName, discardWriter, req)
-					event.Emit(ModelPreloadedEvent{
-						ModelName: realModelName,
-						Success:   true,
-					})
-				}
+			if startupPreload {
+				pm.preloadModels(config.Hooks.OnStartup.Preload, config.Hooks.OnStartup.Parallel)
+			}
+			if config.PredictivePreload.Enabled {
+				pm.watchPredictivePreload(config.PredictivePreload.interval())
 			}
 		}()
 	}
@@ -161,7 +222,7 @@ func New(config Config) *ProxyManager {
 
 // SetConfigPath sets the path to the configuration file
 func (pm *ProxyManager) SetConfigPath(path string) {
-	pm.configPath = path
+	pm.SetConfigProfile(path, "")
 }
 
 // quotePath properly quotes file paths that contain spaces or special characters
@@ -175,8 +236,18 @@ func (pm *ProxyManager) quotePath(path string) string {
 	return path
 }
 
-// handleDownloadCompleted ensures the downloaded file's folder is tracked, then regenerates config
-func (pm *ProxyManager) handleDownloadCompleted(downloadedFilePath string) {
+// handleDownloadCompleted ensures the downloaded file's folder is tracked and loads
+// the model when the download was started with autoLoad
+func (pm *ProxyManager) handleDownloadCompleted(info DownloadInfo) {
+	pm.trackDownloadFolder(info.FilePath)
+	// the parts of a set complete together, the last one loads the model
+	if info.AutoLoad && (info.PartCount == 0 || info.PartIndex == info.PartCount-1) {
+		pm.autoLoadDownloadedModel(info)
+	}
+}
+
+// trackDownloadFolder adds the folder of a downloaded file to the model folder database
+func (pm *ProxyManager) trackDownloadFolder(downloadedFilePath string) {
 	pm.Lock()
 	defer pm.Unlock()
 	// Derive folder from file path
@@ -202,6 +273,76 @@ func (pm *ProxyManager) handleDownloadCompleted(downloadedFilePath string) {
 	pm.proxyLogger.Debug("Skipping auto-regeneration after download to preserve model IDs")
 }
 
+// autoLoadDownloadedModel loads the configured model that uses the downloaded files.
+// Multi-part downloads load when their last part completes. The model is loaded
+// through swapProcessGroup, so memory limits and group rules apply like for a request.
+func (pm *ProxyManager) autoLoadDownloadedModel(info DownloadInfo) {
+	files := map[string]bool{}
+	if absPath, err := filepath.Abs(info.FilePath); err == nil {
+		files[absPath] = true
+	}
+	if pm.downloadManager != nil {
+		for _, other := range pm.downloadManager.GetDownloads() {
+			if other.ID == info.ID || other.ModelID != info.ModelID || !other.AutoLoad {
+				continue
+			}
+			if other.Status != StatusCompleted {
+				pm.proxyLogger.Debugf("Auto-load of %s waits for %s (%s)", info.ModelID, other.Filename, other.Status)
+				return
+			}
+			if absPath, err := filepath.Abs(other.FilePath); err == nil {
+				files[absPath] = true
+			}
+		}
+	}
+
+	modelID := pm.configuredModelForFiles(files)
+	if modelID == "" {
+		// downloads from the UI are not in the config yet
+		if _, err := pm.reloadConfigForNewModel(info.ModelID, false); err != nil {
+			pm.proxyLogger.Warnf("Auto-load of %s: failed to add it to the config: %v", info.ModelID, err)
+		}
+		modelID = pm.configuredModelForFiles(files)
+	}
+	if modelID == "" {
+		pm.proxyLogger.Warnf("Auto-load of %s skipped, no configured model uses %s", info.ModelID, info.FilePath)
+		return
+	}
+
+	pm.proxyLogger.Infof("Auto-loading downloaded model: %s", modelID)
+	processGroup, realModelName, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		pm.proxyLogger.Errorf("Failed to auto-load model %s: %v", modelID, err)
+		return
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	processGroup.ProxyRequest(realModelName, &DiscardWriter{}, req)
+}
+
+// configuredModelForFiles returns the ID of the first configured model, by ID, whose
+// --model argument is one of files, which are absolute paths
+func (pm *ProxyManager) configuredModelForFiles(files map[string]bool) string {
+	pm.Lock()
+	defer pm.Unlock()
+
+	modelIDs := make([]string, 0, len(pm.config.Models))
+	for modelID := range pm.config.Models {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	for _, modelID := range modelIDs {
+		modelPath := pm.extractModelPathFromCmd(pm.config.Models[modelID].Cmd)
+		if modelPath == "" {
+			continue
+		}
+		if absPath, err := filepath.Abs(modelPath); err == nil && files[absPath] {
+			return modelID
+		}
+	}
+	return ""
+}
+
 // generateConfigFromDBLocked performs full regenerate using saved settings.
 // Caller must hold pm.Lock().
 func (pm *ProxyManager) generateConfigFromDBLocked() {
@@ -211,6 +352,7 @@ func (pm *ProxyManager) generateConfigFromDBLocked() {
 		ThroughputFirst:  true,
 		MinContext:       16384,
 		PreferredContext: 32768,
+		MaxModels:        pm.config.ModelLimits.Max,
 	}
 	if s, err := pm.loadSystemSettings(); err == nil && s != nil {
 		options.EnableJinja = s.EnableJinja
@@ -227,7 +369,17 @@ func (pm *ProxyManager) generateConfigFromDBLocked() {
 		if s.Backend != "" {
 			options.ForceBackend = s.Backend
 		}
+		options.ModelOverrides = s.ModelOverrides
+		options.CmdTemplates = s.CmdTemplates
+	}
+
+	// waiting here would hold pm.Lock() while an API generation may need it
+	jobID, err := autosetup.GetProgressManager().BeginGeneration("auto-reconfigure")
+	if err != nil {
+		pm.proxyLogger.Warnf("Auto-reconfigure skipped: %v", err)
+		return
 	}
+	defer autosetup.GetProgressManager().EndGeneration(jobID)
 
 	db, err := pm.loadModelFolderDatabase()
 	if err != nil {
@@ -260,15 +412,14 @@ func (pm *ProxyManager) generateConfigFromDBLocked() {
 		return
 	}
 
-	system := autosetup.DetectSystem()
-	_ = autosetup.EnhanceSystemInfo(&system)
+	system, _ := pm.detectSystem()
 	binariesDir := filepath.Join(".", "binaries")
 	binary, err := autosetup.DownloadBinary(binariesDir, system, options.ForceBackend)
 	if err != nil {
 		pm.proxyLogger.Warnf("Auto-reconfigure failed to ensure binary: %v", err)
 		return
 	}
-	generator := autosetup.NewConfigGenerator(folderPaths[0], binary.Path, "config.yaml", options)
+	generator := autosetup.NewConfigGenerator(folderPaths[0], binary.Path, pm.configPath, options)
 	generator.SetSystemInfo(&system)
 	generator.SetAvailableVRAM(system.TotalVRAMGB)
 	if err := generator.GenerateConfig(allModels); err != nil {
@@ -280,6 +431,9 @@ func (pm *ProxyManager) generateConfigFromDBLocked() {
 }
 
 func (pm *ProxyManager) setupGinEngine() {
+	// correlation ID for the log lines of each request, see request_id.go
+	pm.ginEngine.Use(pm.assignRequestID)
+
 	pm.ginEngine.Use(func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
@@ -288,6 +442,7 @@ func (pm *ProxyManager) setupGinEngine() {
 		clientIP := c.ClientIP()
 		method := c.Request.Method
 		path := c.Request.URL.Path
+		tag := requestTag(c.Request.Context())
 
 		// Process request
 		c.Next()
@@ -298,7 +453,7 @@ func (pm *ProxyManager) setupGinEngine() {
 		statusCode := c.Writer.Status()
 		bodySize := c.Writer.Size()
 
-		pm.proxyLogger.Infof("Request %s \"%s %s %s\" %d %d \"%s\" %v",
+		pm.proxyLogger.Infof("Request %s \"%s %s %s\" %d %d \"%s\" %v%s",
 			clientIP,
 			method,
 			path,
@@ -307,9 +462,13 @@ func (pm *ProxyManager) setupGinEngine() {
 			bodySize,
 			c.Request.UserAgent(),
 			duration,
+			tag,
 		)
 	})
 
+	// a panicking handler returns a 500, see recovery.go
+	pm.ginEngine.Use(pm.recoverPanics)
+
 	// see: issue: #81, #77 and #42 for CORS issues
 	// respond with permissive OPTIONS for any endpoint
 	pm.ginEngine.Use(func(c *gin.Context) {
@@ -364,10 +523,11 @@ func (pm *ProxyManager) setupGinEngine() {
 	pm.ginEngine.POST("/v1/audio/transcriptions", auth, pm.proxyOAIPostFormHandler)
 
 	pm.ginEngine.GET("/v1/models", auth, pm.listModelsHandler)
-	pm.ginEngine.GET("/v1/models/search", auth, pm.apiV1SearchModels)  // NEW: Unified model search
-	pm.ginEngine.POST("/v1/models/load", auth, pm.apiV1LoadModel)      // NEW: Load model with auto-unload
-	pm.ginEngine.POST("/v1/models/unload", auth, pm.apiV1UnloadModel)  // NEW: Unload specific model
-	pm.ginEngine.GET("/v1/models/loaded", auth, pm.apiV1GetLoadedModels) // NEW: Get loaded models
+	pm.ginEngine.POST("/v1/models/load", auth, pm.apiV1LoadModel)     // NEW: Load model with auto-unload
+	pm.ginEngine.POST("/v1/models/unload", auth, pm.apiV1UnloadModel) // NEW: Unload specific model
+	// model IDs may contain slashes, e.g. org/repo, see modelDetailHandler for
+	// /v1/models/search and /v1/models/loaded
+	pm.ginEngine.GET("/v1/models/*model", auth, pm.modelDetailHandler)
 
 	// Info endpoint to show model-to-port mappings
 	pm.ginEngine.GET("/info", auth, pm.infoHandler)
@@ -395,6 +555,11 @@ func (pm *ProxyManager) setupGinEngine() {
 	pm.ginEngine.GET("/unload", pm.unloadAllModelsHandler)
 	pm.ginEngine.GET("/running", pm.listRunningProcessesHandler)
 	pm.ginEngine.GET("/health", func(c *gin.Context) {
+		// plain OK for load balancers, details for clients asking for JSON
+		if strings.Contains(c.GetHeader("Accept"), "application/json") {
+			c.JSON(http.StatusOK, gin.H{"status": "OK", "models": pm.modelLimitStatus()})
+			return
+		}
 		c.String(http.StatusOK, "OK")
 	})
 
@@ -463,21 +628,19 @@ func (pm *ProxyManager) requireAPIKey() gin.HandlerFunc {
 		}
 
 		if settings, _ := pm.loadSystemSettings(); settings != nil && settings.RequireAPIKey {
-			key := c.GetHeader("Authorization")
-			if key == "" {
-				key = c.GetHeader("X-API-Key")
-			}
-			if key == "" {
-				// Allow API key via query param for EventSource and limited clients
-				key = c.Query("api_key")
-			}
-			if strings.HasPrefix(strings.ToLower(key), "bearer ") {
-				key = strings.TrimSpace(key[7:])
-			}
-			if strings.TrimSpace(key) == "" || strings.TrimSpace(settings.APIKey) == "" || key != settings.APIKey {
+			scope, ok := settings.authenticateAPIKey(apiKeyFromRequest(c))
+			if !ok {
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required or invalid"})
 				return
 			}
+			if scope != nil {
+				// keyring keys are for inference only, management needs the global key
+				if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is not permitted to access this endpoint"})
+					return
+				}
+				c.Set(apiKeyScopeContextKey, scope)
+			}
 		}
 		c.Next()
 	}
@@ -534,6 +697,10 @@ func (pm *ProxyManager) Shutdown() {
 		pm.downloadSubCancel()
 		pm.downloadSubCancel = nil
 	}
+	if pm.webhookSubCancel != nil {
+		pm.webhookSubCancel()
+		pm.webhookSubCancel = nil
+	}
 	pm.shutdownCancel()
 }
 
@@ -557,18 +724,51 @@ func (pm *ProxyManager) swapProcessGroup(requestedModel string) (*ProcessGroup,
 		return nil, realModelName, fmt.Errorf("could not find process group for model %s", requestedModel)
 	}
 
+	// a remote model runs on another machine, loading it needs neither the local
+	// GPU nor memory, and it does not evict the local models of other groups
+	if modelConfig := pm.config.Models[realModelName]; modelConfig.IsRemote() {
+		return processGroup, realModelName, nil
+	}
+
+	if err := pm.gpuBlocksLoad(processGroup, realModelName); err != nil {
+		return nil, realModelName, err
+	}
+
+	if err := pm.restoreBeforeLoad(realModelName); err != nil {
+		return nil, realModelName, err
+	}
+
 	// Check memory before loading a new model
 	if err := pm.ensureMemoryAvailable(processGroup, realModelName); err != nil {
-		return nil, realModelName, fmt.Errorf("memory check failed: %v", err)
+		return nil, realModelName, &LoadError{
+			Code:       LoadErrorInsufficientMemory,
+			Reason:     fmt.Sprintf("memory check failed: %v", err),
+			Suggestion: "unload other models or lower minFreeMemoryPercent",
+			Err:        err,
+		}
+	}
+	if err := pm.ensureMinFreeVRAM(processGroup, realModelName); err != nil {
+		return nil, realModelName, &LoadError{
+			Code:       LoadErrorInsufficientMemory,
+			Reason:     fmt.Sprintf("VRAM check failed: %v", err),
+			Suggestion: "unload other models, use a smaller quantization or lower minFreeVRAMGB",
+			Err:        err,
+		}
 	}
+	pm.checkSlotMemory(processGroup, realModelName)
 
 	if processGroup.exclusive {
 		pm.proxyLogger.Debugf("Exclusive mode for group %s, stopping other process groups", processGroup.id)
+		var otherGroups []*ProcessGroup
 		for groupId, otherGroup := range pm.processGroups {
 			if groupId != processGroup.id && !otherGroup.persistent {
-				otherGroup.StopProcesses(StopWaitForInflightRequest)
+				otherGroups = append(otherGroups, otherGroup)
 			}
 		}
+		if err := pm.waitForExclusiveCooldown(otherGroups, realModelName); err != nil {
+			return nil, realModelName, err
+		}
+		pm.stopGroupsForLoad(otherGroups, StopWaitForInflightRequest)
 	}
 
 	return processGroup, realModelName, nil
@@ -579,76 +779,11 @@ func (pm *ProxyManager) listModelsHandler(c *gin.Context) {
 	createdTime := time.Now().Unix()
 
 	for id, modelConfig := range pm.config.Models {
-		if modelConfig.Unlisted {
+		if modelConfig.Unlisted || !pm.allowedModel(c, id) {
 			continue
 		}
 
-		record := gin.H{
-			"id":       id,
-			"object":   "model",
-			"created":  createdTime,
-			"owned_by": "FrogLLM",
-		}
-
-		if name := strings.TrimSpace(modelConfig.Name); name != "" {
-			record["name"] = name
-		}
-		if desc := strings.TrimSpace(modelConfig.Description); desc != "" {
-			record["description"] = desc
-		}
-
-		// Extract model path from Cmd if available
-		modelPath := ""
-		if modelConfig.Cmd != "" {
-			// Extract model path from llama-server command
-			cmdParts := strings.Fields(modelConfig.Cmd)
-			for i, part := range cmdParts {
-				if part == "-m" && i+1 < len(cmdParts) {
-					modelPath = cmdParts[i+1]
-					break
-				}
-			}
-		}
-
-		// Add enhanced model information
-		if modelPath != "" {
-			// Check if model file exists and get size
-			if fileInfo, err := os.Stat(modelPath); err == nil {
-				sizeGB := float64(fileInfo.Size()) / (1024 * 1024 * 1024)
-				record["size_gb"] = fmt.Sprintf("%.2f", sizeGB)
-				record["size_bytes"] = fileInfo.Size()
-				record["file_exists"] = true
-			} else {
-				record["file_exists"] = false
-			}
-			record["model_path"] = modelPath
-		}
-
-		// Add quantization info if available in the path/name
-		if strings.Contains(modelPath, "Q4_K_M") || strings.Contains(id, "Q4_K_M") {
-			record["quantization"] = "Q4_K_M"
-		} else if strings.Contains(modelPath, "Q8_0") || strings.Contains(id, "Q8_0") {
-			record["quantization"] = "Q8_0"
-		} else if strings.Contains(modelPath, "Q5_K_M") || strings.Contains(id, "Q5_K_M") {
-			record["quantization"] = "Q5_K_M"
-		} else if strings.Contains(modelPath, "f16") || strings.Contains(id, "f16") {
-			record["quantization"] = "F16"
-		}
-
-		// Check if model is currently loaded
-		pm.Lock()
-		_, isLoaded := pm.processGroups[id] // Check if process group exists for this model
-		pm.Unlock()
-
-		if isLoaded {
-			record["status"] = "loaded"
-			record["loading"] = false
-		} else {
-			record["status"] = "unloaded"
-			record["loading"] = false
-		}
-
-		data = append(data, record)
+		data = append(data, pm.buildModelRecord(id, modelConfig, createdTime))
 	}
 
 	// Sort by the "id" key
@@ -670,27 +805,181 @@ func (pm *ProxyManager) listModelsHandler(c *gin.Context) {
 	})
 }
 
+// modelDetailHandler returns the /v1/models record for a single model or alias
+func (pm *ProxyManager) modelDetailHandler(c *gin.Context) {
+	// a catch-all route can't have static siblings, the fixed endpoints come
+	// first so a model can't shadow them
+	modelID := strings.TrimPrefix(c.Param("model"), "/")
+	switch modelID {
+	case "search":
+		pm.apiV1SearchModels(c)
+		return
+	case "loaded":
+		pm.apiV1GetLoadedModels(c)
+		return
+	}
+
+	modelConfig, realModelName, found := pm.config.FindConfig(modelID)
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, fmt.Sprintf("model %s not found", modelID))
+		return
+	}
+	if !pm.requireModelAccess(c, realModelName) {
+		return
+	}
+
+	if origin := c.GetHeader("Origin"); origin != "" {
+		c.Header("Access-Control-Allow-Origin", origin)
+	}
+
+	record := pm.buildModelRecord(realModelName, modelConfig, time.Now().Unix())
+	if len(modelConfig.Aliases) > 0 {
+		record["aliases"] = modelConfig.Aliases
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// buildModelRecord builds the OpenAI compatible model object with FrogLLM extensions
+func (pm *ProxyManager) buildModelRecord(id string, modelConfig ModelConfig, createdTime int64) gin.H {
+	record := gin.H{
+		"id":       id,
+		"object":   "model",
+		"created":  createdTime,
+		"owned_by": "FrogLLM",
+	}
+
+	if name := strings.TrimSpace(modelConfig.Name); name != "" {
+		record["name"] = name
+	}
+	if desc := strings.TrimSpace(modelConfig.Description); desc != "" {
+		record["description"] = desc
+	}
+
+	// Extract model path from Cmd if available
+	modelPath := pm.extractModelPathFromCmd(modelConfig.Cmd)
+
+	// Add enhanced model information
+	if modelPath != "" {
+		// Check if model file exists and get size
+		if fileInfo, err := os.Stat(modelPath); err == nil {
+			sizeGB := float64(fileInfo.Size()) / (1024 * 1024 * 1024)
+			record["size_gb"] = fmt.Sprintf("%.2f", sizeGB)
+			record["size_bytes"] = fileInfo.Size()
+			record["file_exists"] = true
+		} else {
+			record["file_exists"] = false
+		}
+		record["model_path"] = modelPath
+	}
+
+	// Add quantization info if available in the path/name
+	if strings.Contains(modelPath, "Q4_K_M") || strings.Contains(id, "Q4_K_M") {
+		record["quantization"] = "Q4_K_M"
+	} else if strings.Contains(modelPath, "Q8_0") || strings.Contains(id, "Q8_0") {
+		record["quantization"] = "Q8_0"
+	} else if strings.Contains(modelPath, "Q5_K_M") || strings.Contains(id, "Q5_K_M") {
+		record["quantization"] = "Q5_K_M"
+	} else if strings.Contains(modelPath, "f16") || strings.Contains(id, "f16") {
+		record["quantization"] = "F16"
+	}
+
+	// Embedding and reranker models report their output shape
+	switch modelType := modelTypeFromCmd(modelConfig); modelType {
+	case "embedding", "reranker":
+		record["type"] = modelType
+		if dimensions := pm.embeddingDimensions(id); dimensions > 0 {
+			record["dimensions"] = dimensions
+		}
+		if modelType == "reranker" {
+			// llama-server returns raw relevance logits, unbounded and higher is more relevant
+			record["score_range"] = gin.H{"type": "logit", "normalized": false}
+		}
+	}
+
+	// the model is loaded when its process is ready, every configured model has
+	// a process in its group whether it runs or not
+	state := pm.modelProcessState(id)
+	record["state"] = string(state)
+	switch state {
+	case StateReady:
+		record["status"] = "loaded"
+	case StateStarting:
+		record["status"] = "loading"
+	default:
+		record["status"] = "unloaded"
+	}
+	record["loading"] = state == StateStarting
+
+	return record
+}
+
+// modelProcessState returns the state of a model's process, stopped when it has none
+func (pm *ProxyManager) modelProcessState(modelID string) ProcessState {
+	pm.Lock()
+	defer pm.Unlock()
+	processGroup := pm.findGroupByModelName(modelID)
+	if processGroup == nil {
+		return StateStopped
+	}
+	return processGroup.processState(modelID)
+}
+
+// modelTypeFromCmd returns "embedding", "reranker" or "" based on the llama-server flags in cmd
+func modelTypeFromCmd(modelConfig ModelConfig) string {
+	args, err := modelConfig.SanitizedCommand()
+	if err != nil {
+		return ""
+	}
+
+	modelType := ""
+	for i, arg := range args {
+		switch arg {
+		case "--reranking", "--rerank":
+			return "reranker"
+		case "--pooling":
+			if i+1 < len(args) && args[i+1] == "rank" {
+				return "reranker"
+			}
+		case "--embedding", "--embeddings":
+			modelType = "embedding"
+		}
+	}
+	return modelType
+}
+
+// embeddingDimensions returns the embedding_length of the model's GGUF, cached per file
+func (pm *ProxyManager) embeddingDimensions(modelID string) int {
+	modelPath := pm.resolveModelPath(modelID)
+	if modelPath == "" {
+		return 0
+	}
+
+	if cached, ok := pm.embeddingDimCache.Load(modelPath); ok {
+		return cached.(int)
+	}
+
+	dimensions, err := autosetup.ReadEmbeddingLength(modelPath)
+	if err != nil {
+		pm.proxyLogger.Debugf("<%s> unable to read embedding dimensions: %v", modelID, err)
+		dimensions = 0
+	}
+	pm.embeddingDimCache.Store(modelPath, dimensions)
+	return dimensions
+}
+
 func (pm *ProxyManager) proxyToUpstream(c *gin.Context) {
 	upstreamPath := c.Param("upstreamPath")
 
 	// If API key is required, enforce it
 	if settings, _ := pm.loadSystemSettings(); settings != nil && settings.RequireAPIKey {
-		key := c.GetHeader("Authorization")
-		// Accept either Bearer <key> or raw key in X-API-Key
-		if key == "" {
-			key = c.GetHeader("X-API-Key")
-		}
-		if key == "" {
-			// Allow API key via query param for EventSource and limited clients
-			key = c.Query("api_key")
-		}
-		if strings.HasPrefix(strings.ToLower(key), "bearer ") {
-			key = strings.TrimSpace(key[7:])
-		}
-		if strings.TrimSpace(key) == "" || strings.TrimSpace(settings.APIKey) == "" || key != settings.APIKey {
+		scope, ok := settings.authenticateAPIKey(apiKeyFromRequest(c))
+		if !ok {
 			pm.sendErrorResponse(c, http.StatusUnauthorized, "API key required or invalid")
 			return
 		}
+		if scope != nil {
+			c.Set(apiKeyScopeContextKey, scope)
+		}
 	}
 
 	// split the upstream path by / and search for the model name
@@ -726,10 +1015,13 @@ func (pm *ProxyManager) proxyToUpstream(c *gin.Context) {
 		pm.sendErrorResponse(c, http.StatusBadRequest, "model id required in path")
 		return
 	}
+	if !pm.requireModelAccess(c, modelName) {
+		return
+	}
 
 	processGroup, realModelName, err := pm.swapProcessGroup(modelName)
 	if err != nil {
-		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error swapping process group: %s", err.Error()))
+		pm.sendSwapError(c, err)
 		return
 	}
 
@@ -738,6 +1030,7 @@ func (pm *ProxyManager) proxyToUpstream(c *gin.Context) {
 	processGroup.ProxyRequest(realModelName, c.Writer, c.Request)
 }
 func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
+	tag := requestTag(c.Request.Context())
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		pm.sendErrorResponse(c, http.StatusBadRequest, "could not ready request body")
@@ -751,6 +1044,14 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 	}
 
 	realModelName, found := pm.config.RealModelName(requestedModel)
+	if found && !pm.requireModelAccess(c, realModelName) {
+		return
+	}
+	if !found && apiKeyScope(c) != nil {
+		// keyring keys can not trigger downloads of new models
+		pm.sendErrorResponse(c, http.StatusForbidden, fmt.Sprintf("API key is not permitted to access model %s", requestedModel))
+		return
+	}
 	if !found {
 		// Check if this might be a HuggingFace model that we can download
 		// Support both formats: "repo/model" and "repo:filename"
@@ -762,17 +1063,21 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 			if len(parts) == 2 && strings.Contains(parts[0], "/") {
 				// This is a valid repo:filename format
 				modelToDownload = requestedModel
-				pm.proxyLogger.Infof("Model %s not found locally (repo:filename format), attempting auto-download...", requestedModel)
+				pm.proxyLogger.Infof("Model %s not found locally (repo:filename format), attempting auto-download...%s", requestedModel, tag)
 			}
 		} else if strings.Contains(requestedModel, "/") {
 			// Format: "repo/model" (traditional HuggingFace format)
 			modelToDownload = requestedModel
-			pm.proxyLogger.Infof("Model %s not found locally (repo format), attempting auto-download...", requestedModel)
+			pm.proxyLogger.Infof("Model %s not found locally (repo format), attempting auto-download...%s", requestedModel, tag)
 		}
 
 		if modelToDownload != "" {
 			// Trigger download and wait for it to complete
 			if err := pm.autoDownloadModel(c, modelToDownload); err != nil {
+				if errors.Is(err, errAutoDownloadNotAllowed) {
+					pm.sendErrorResponse(c, http.StatusForbidden, fmt.Sprintf("Model %s is not available: %s", requestedModel, err.Error()))
+					return
+				}
 				pm.sendErrorResponse(c, http.StatusServiceUnavailable, fmt.Sprintf("Failed to download model %s: %s", modelToDownload, err.Error()))
 				return
 			}
@@ -796,40 +1101,46 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 				realModelName, found = pm.config.RealModelName(modelToDownload)
 				if !found {
 					// Debug: log what's in the config
-					pm.proxyLogger.Errorf("Model lookup failed. Requested: %s, Downloaded: %s", requestedModel, modelToDownload)
-					pm.proxyLogger.Errorf("Available models in config:")
+					pm.proxyLogger.Errorf("Model lookup failed. Requested: %s, Downloaded: %s%s", requestedModel, modelToDownload, tag)
+					pm.proxyLogger.Errorf("Available models in config:%s", tag)
 					for modelID := range pm.config.Models {
-						pm.proxyLogger.Errorf("  - %s (aliases: %v)", modelID, pm.config.Models[modelID].Aliases)
+						pm.proxyLogger.Errorf("  - %s (aliases: %v)%s", modelID, pm.config.Models[modelID].Aliases, tag)
 					}
-					pm.proxyLogger.Errorf("Aliases map contains:")
+					pm.proxyLogger.Errorf("Aliases map contains:%s", tag)
 					for alias, model := range pm.config.Aliases {
-						pm.proxyLogger.Errorf("  - %s -> %s", alias, model)
+						pm.proxyLogger.Errorf("  - %s -> %s%s", alias, model, tag)
 					}
 					pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("Model %s downloaded but still not found in config", requestedModel))
 					return
 				}
 			}
-			pm.proxyLogger.Infof("Model %s resolved to real name: %s", requestedModel, realModelName)
+			pm.proxyLogger.Infof("Model %s resolved to real name: %s%s", requestedModel, realModelName, tag)
 		} else {
 			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("could not find real modelID for %s", requestedModel))
 			return
 		}
 	}
 
+	// an unhealthy model hands its requests to a healthy fallback
+	if instance := pm.healthyInstance(c, realModelName); instance != realModelName {
+		pm.proxyLogger.Infof("<%s>%s is unhealthy, routing the request to %s", realModelName, tag, instance)
+		requestedModel, realModelName = instance, instance
+	}
+
 	processGroup, usedModelName, err := pm.swapProcessGroup(requestedModel)
 	if err != nil {
 		// If the swap fails, it might be because we need to use the real name
-		pm.proxyLogger.Warnf("Swap failed with requested model %s, trying with real name %s", requestedModel, realModelName)
+		pm.proxyLogger.Warnf("Swap failed with requested model %s, trying with real name %s%s", requestedModel, realModelName, tag)
 		processGroup, usedModelName, err = pm.swapProcessGroup(realModelName)
 		if err != nil {
-			pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error swapping process group: %s", err.Error()))
+			pm.sendSwapError(c, err)
 			return
 		}
 	}
 
 	// Use the model name that was actually found in the process group
 	modelNameForProxy := usedModelName
-	pm.proxyLogger.Debugf("Using model name for proxy: %s", modelNameForProxy)
+	pm.proxyLogger.Debugf("Using model name for proxy: %s%s", modelNameForProxy, tag)
 
 	// Track model usage for LRU eviction
 	modelTracker.UpdateModelUsage(realModelName)
@@ -847,10 +1158,10 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 	// issue #174 strip parameters from the JSON body
 	stripParams, err := pm.config.Models[realModelName].Filters.SanitizedStripParams()
 	if err != nil { // just log it and continue
-		pm.proxyLogger.Errorf("Error sanitizing strip params string: %s, %s", pm.config.Models[realModelName].Filters.StripParams, err.Error())
+		pm.proxyLogger.Errorf("Error sanitizing strip params string: %s, %s%s", pm.config.Models[realModelName].Filters.StripParams, err.Error(), tag)
 	} else {
 		for _, param := range stripParams {
-			pm.proxyLogger.Debugf("<%s> stripping param: %s", realModelName, param)
+			pm.proxyLogger.Debugf("<%s>%s stripping param: %s", realModelName, tag, param)
 			bodyBytes, err = sjson.DeleteBytes(bodyBytes, param)
 			if err != nil {
 				pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error deleting parameter %s from request", param))
@@ -859,6 +1170,15 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 		}
 	}
 
+	// enforce the model's system prompt on chat requests
+	if forcedPrompt := pm.config.Models[realModelName].ForceSystemPrompt; forcedPrompt != "" {
+		bodyBytes, err = applyForcedSystemPrompt(bodyBytes, forcedPrompt, pm.config.Models[realModelName].ForceSystemPromptMode)
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error applying system prompt: %s", err.Error()))
+			return
+		}
+	}
+
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	// dechunk it as we already have all the body bytes see issue #11
@@ -866,14 +1186,53 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 	c.Request.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
 	c.Request.ContentLength = int64(len(bodyBytes))
 
-	if err := processGroup.ProxyRequest(modelNameForProxy, c.Writer, c.Request); err != nil {
+	// non-streaming chat and completion responses always get usage, see usage_normalize.go
+	var writer http.ResponseWriter = c.Writer
+	var usageWriter *usageResponseWriter
+	if usageNormalizedPaths[c.Request.URL.Path] && !gjson.GetBytes(bodyBytes, "stream").Bool() {
+		usageWriter = &usageResponseWriter{ResponseWriter: c.Writer}
+		writer = usageWriter
+	}
+
+	err = processGroup.ProxyRequest(modelNameForProxy, writer, c.Request)
+	if usageWriter != nil {
+		usageWriter.finish(bodyBytes, upstreamTokenCounter(pm.config.Models[realModelName].Proxy))
+	}
+	if err != nil {
 		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error proxying request: %s", err.Error()))
-		pm.proxyLogger.Errorf("Error Proxying Request for processGroup %s and model %s", processGroup.id, modelNameForProxy)
+		pm.proxyLogger.Errorf("Error Proxying Request for processGroup %s and model %s%s", processGroup.id, modelNameForProxy, tag)
 		return
 	}
 }
 
+// applyForcedSystemPrompt injects a system message into the messages array of a
+// chat request. In prepend mode the forced prompt is added before any client
+// messages; in override mode client supplied system messages are dropped.
+// Bodies without a messages array are returned unchanged.
+func applyForcedSystemPrompt(body []byte, prompt string, mode string) ([]byte, error) {
+	messages := gjson.GetBytes(body, "messages")
+	if !messages.Exists() || !messages.IsArray() {
+		return body, nil
+	}
+
+	systemMessage, err := json.Marshal(map[string]string{"role": "system", "content": prompt})
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := []string{string(systemMessage)}
+	for _, message := range messages.Array() {
+		if mode == SystemPromptModeOverride && message.Get("role").String() == "system" {
+			continue
+		}
+		rewritten = append(rewritten, message.Raw)
+	}
+
+	return sjson.SetRawBytes(body, "messages", []byte("["+strings.Join(rewritten, ",")+"]"))
+}
+
 func (pm *ProxyManager) proxyOAIPostFormHandler(c *gin.Context) {
+	tag := requestTag(c.Request.Context())
 	// Parse multipart form
 	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB max memory, larger files go to tmp disk
 		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("error parsing multipart form: %s", err.Error()))
@@ -886,10 +1245,13 @@ func (pm *ProxyManager) proxyOAIPostFormHandler(c *gin.Context) {
 		pm.sendErrorResponse(c, http.StatusBadRequest, "missing or invalid 'model' parameter in form data")
 		return
 	}
+	if realModelName, found := pm.config.RealModelName(requestedModel); found && !pm.requireModelAccess(c, realModelName) {
+		return
+	}
 
 	processGroup, realModelName, err := pm.swapProcessGroup(requestedModel)
 	if err != nil {
-		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error swapping process group: %s", err.Error()))
+		pm.sendSwapError(c, err)
 		return
 	}
 
@@ -978,7 +1340,7 @@ func (pm *ProxyManager) proxyOAIPostFormHandler(c *gin.Context) {
 	// Use the modified request for proxying
 	if err := processGroup.ProxyRequest(realModelName, c.Writer, modifiedReq); err != nil {
 		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error proxying request: %s", err.Error()))
-		pm.proxyLogger.Errorf("Error Proxying Request for processGroup %s and model %s", processGroup.id, realModelName)
+		pm.proxyLogger.Errorf("Error Proxying Request for processGroup %s and model %s%s", processGroup.id, realModelName, tag)
 		return
 	}
 }
@@ -1026,6 +1388,11 @@ func (pm *ProxyManager) infoHandler(c *gin.Context) {
 	modelInfo := make([]gin.H, 0)
 
 	for modelID, modelConfig := range pm.config.Models {
+		// scoped API keys only see their own models
+		if !pm.allowedModel(c, modelID) {
+			continue
+		}
+
 		// Extract port from proxy URL if available
 		port := ""
 		if modelConfig.Proxy != "" {
@@ -1038,23 +1405,41 @@ func (pm *ProxyManager) infoHandler(c *gin.Context) {
 			}
 		}
 
-		// Check if model is currently running
+		// Check if model is currently running, loading or failed to load
 		isRunning := false
+		isLoading := false
+		isHealthy := false
+		var loadError *LoadError
+		var loading time.Duration
 		processGroup := pm.findGroupByModelName(modelID)
 		if processGroup != nil {
 			if process, exists := processGroup.processes[modelID]; exists {
 				isRunning = process.CurrentState() == StateReady
+				isLoading = process.CurrentState() == StateStarting
+				isHealthy = isRunning && process.Healthy()
+				loadError, loading = process.loadStatus()
 			}
 		}
 
-		modelInfo = append(modelInfo, gin.H{
+		info := gin.H{
 			"model":       modelID,
 			"port":        port,
 			"proxy":       modelConfig.Proxy,
 			"running":     isRunning,
+			"loading":     isLoading,
+			"healthy":     isHealthy,
 			"name":        modelConfig.Name,
 			"description": modelConfig.Description,
-		})
+		}
+		if loadError != nil {
+			info["failReason"] = loadError.Reason
+			info["failCode"] = loadError.Code
+			info["failSuggestion"] = loadError.Suggestion
+		}
+		if isLoading {
+			info["loadingElapsedMs"] = loading.Milliseconds()
+		}
+		modelInfo = append(modelInfo, info)
 	}
 
 	// Sort by model ID for consistent output
@@ -1101,10 +1486,10 @@ type HuggingFaceSearchResult struct {
 // searchHuggingFaceModel searches for a specific model and returns GGUF file information
 func (pm *ProxyManager) searchHuggingFaceModel(modelID, hfApiKey string, limit int) (*HuggingFaceSearchResult, error) {
 	// Create HTTP client
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := autosetup.NewHTTPClient(30 * time.Second)
 
-	// Build HuggingFace API URL to get model details
-	modelURL := fmt.Sprintf("https://huggingface.co/api/models/%s", modelID)
+	// Build HuggingFace API URL to get model details, blobs=true includes file sizes
+	modelURL := fmt.Sprintf("%s/api/models/%s?blobs=true", pm.huggingFaceURL, modelID)
 
 	// Create request
 	req, err := http.NewRequest("GET", modelURL, nil)
@@ -1167,23 +1552,7 @@ func (pm *ProxyManager) searchHuggingFaceModel(modelID, hfApiKey string, limit i
 					}
 
 					// Extract quantization from filename
-					quantization := "unknown"
-					if matched, _ := regexp.MatchString(`[Qq]\d+_[KkMmSsLlXxSs]`, filename); matched {
-						re := regexp.MustCompile(`[Qq]\d+_[KkMmSsLlXxSs]`)
-						if match := re.FindString(filename); match != "" {
-							quantization = strings.ToLower(match)
-						}
-					} else if matched, _ := regexp.MatchString(`[Qq]\d+`, filename); matched {
-						re := regexp.MustCompile(`[Qq]\d+`)
-						if match := re.FindString(filename); match != "" {
-							quantization = strings.ToLower(match)
-						}
-					} else if matched, _ := regexp.MatchString(`IQ\d+_[KkMmSsLlXxSs]+`, filename); matched {
-						re := regexp.MustCompile(`IQ\d+_[KkMmSsLlXxSs]+`)
-						if match := re.FindString(filename); match != "" {
-							quantization = strings.ToLower(match)
-						}
-					}
+					quantization := quantizationTag(filename)
 
 					// Generate suggested model ID for this quantization
 					suggestedModelID := modelID
@@ -1214,7 +1583,20 @@ func (pm *ProxyManager) searchHuggingFaceModel(modelID, hfApiKey string, limit i
 }
 
 // autoDownloadModel attempts to download a model from HuggingFace
-func (pm *ProxyManager) autoDownloadModel(c *gin.Context, modelID string) error {
+func (pm *ProxyManager) autoDownloadModel(c *gin.Context, modelID string) (err error) {
+	tag := requestTag(c.Request.Context())
+	if err := pm.checkAutoDownloadAllowed(modelID); err != nil {
+		pm.proxyLogger.Warnf("Refusing to auto-download %s: %v%s", modelID, err, tag)
+		return err
+	}
+
+	entry := pm.autoDownloads.add(modelID)
+	defer func() {
+		if pm.autoDownloads.finish(entry) {
+			err = fmt.Errorf("%w: %s", errAutoDownloadCancelled, modelID)
+		}
+	}()
+
 	// Extract HF API key from request headers if available
 	hfApiKey := c.GetHeader("HF-Token")
 	if hfApiKey == "" {
@@ -1237,37 +1619,45 @@ func (pm *ProxyManager) autoDownloadModel(c *gin.Context, modelID string) error
 			// Check if it's a filename or quantization
 			if strings.HasSuffix(strings.ToLower(parts[1]), ".gguf") {
 				targetFile = parts[1]
-				pm.proxyLogger.Infof("Auto-downloading specific file: %s from repo: %s", targetFile, baseModelID)
+				pm.proxyLogger.Infof("Auto-downloading specific file: %s from repo: %s%s", targetFile, baseModelID, tag)
 			} else {
 				targetQuantization = strings.ToLower(parts[1])
-				pm.proxyLogger.Infof("Auto-downloading first file with quantization: %s from repo: %s", targetQuantization, baseModelID)
+				pm.proxyLogger.Infof("Auto-downloading first file with quantization: %s from repo: %s%s", targetQuantization, baseModelID, tag)
 			}
 		}
 	} else {
-		pm.proxyLogger.Infof("Auto-downloading first available GGUF file from repo: %s", baseModelID)
+		pm.proxyLogger.Infof("Auto-downloading first available GGUF file from repo: %s%s", baseModelID, tag)
+	}
+
+	downloadDir := pm.repoDownloadDir(baseModelID)
+
+	// Use a suitable quantization that is already downloaded instead of fetching again
+	if targetFile == "" {
+		if localFile, found := pm.findBestLocalQuant(downloadDir, targetQuantization); found {
+			pm.proxyLogger.Infof("Found already downloaded %s for %s, skipping download%s", localFile, modelID, tag)
+			return nil
+		}
 	}
 
 	// Use the enhanced search API to find available GGUF files
 	searchResults, err := pm.searchHuggingFaceModel(baseModelID, hfApiKey, 50)
 	if err != nil {
-		pm.proxyLogger.Errorf("Failed to search for model %s: %v", baseModelID, err)
+		pm.proxyLogger.Errorf("Failed to search for model %s: %v%s", baseModelID, err, tag)
 		// Fallback to old method
 		return pm.autoDownloadModelFallback(c, baseModelID, hfApiKey)
 	}
 
 	if len(searchResults.GGUFFiles) == 0 {
-		pm.proxyLogger.Warnf("No GGUF files found via API for model %s, trying fallback method", baseModelID)
+		pm.proxyLogger.Warnf("No GGUF files found via API for model %s, trying fallback method%s", baseModelID, tag)
 		return pm.autoDownloadModelFallback(c, baseModelID, hfApiKey)
 	}
 
-	pm.proxyLogger.Infof("Found %d GGUF files for model %s", len(searchResults.GGUFFiles), baseModelID)
+	pm.proxyLogger.Infof("Found %d GGUF files for model %s%s", len(searchResults.GGUFFiles), baseModelID, tag)
 
-	// Use the configured download directory
-	baseDownloadDir := pm.config.DownloadDir
-	if baseDownloadDir == "" {
-		baseDownloadDir = "./downloads"
+	// the search may take a while, don't start downloads for a cancelled request
+	if pm.autoDownloads.isCancelled(entry) {
+		return errAutoDownloadCancelled
 	}
-	downloadDir := filepath.Join(baseDownloadDir, strings.ReplaceAll(baseModelID, "/", "_"))
 
 	// If a specific file is requested, download only that file
 	if targetFile != "" {
@@ -1518,34 +1908,18 @@ func (pm *ProxyManager) downloadSpecificQuantization(searchResults *HuggingFaceS
 	return nil
 }
 
-// downloadAllGGUFFiles downloads GGUF files when no specific file or quantization is specified
-// For split models, downloads ALL parts; for non-split models, downloads only the first file
+// downloadAllGGUFFiles downloads GGUF files when no specific file or quantization is specified.
+// It picks the most preferred quantization, see quant_preference.go. For split
+// models, downloads ALL parts; for non-split models, downloads only that file
 func (pm *ProxyManager) downloadAllGGUFFiles(searchResults *HuggingFaceSearchResult, hfApiKey, downloadDir, baseModelID string) error {
-	if len(searchResults.GGUFFiles) == 0 {
+	filesToDownload := pm.quantPreference().preferredFiles(searchResults.GGUFFiles)
+	if len(filesToDownload) == 0 {
 		return fmt.Errorf("no GGUF files found for model %s", baseModelID)
 	}
 
-	// Check if the first file is a split model
-	firstFile := searchResults.GGUFFiles[0]
+	firstFile := filesToDownload[0]
 
 	if firstFile.IsSplit {
-		// This is a split model, we need to download ALL parts with the same base name
-		var baseModelName string
-		var filesToDownload []HuggingFaceFile
-
-		// Extract base name without split part number
-		re := regexp.MustCompile(`(.*?)(-\d{5}-of-\d{5}|\.gguf\.part\d+of\d+|-part-\d{5}-of-\d{5}|\.split-\d{5}-of-\d{5})`)
-		if matches := re.FindStringSubmatch(firstFile.Filename); len(matches) > 1 {
-			baseModelName = matches[1]
-		}
-
-		// Find all parts with the same base name
-		for _, file := range searchResults.GGUFFiles {
-			if file.IsSplit && strings.HasPrefix(file.Filename, baseModelName) {
-				filesToDownload = append(filesToDownload, file)
-			}
-		}
-
 		pm.proxyLogger.Infof("Downloading %d split parts for model %s", len(filesToDownload), baseModelID)
 
 		// Create download directory
@@ -1587,7 +1961,7 @@ func (pm *ProxyManager) downloadAllGGUFFiles(searchResults *HuggingFaceSearchRes
 		return nil
 	}
 
-	// Not a split model, download only the first file
+	// Not a split model, download only the preferred file
 	// Check if file already exists
 	targetPath := filepath.Join(downloadDir, firstFile.Filename)
 	if _, err := os.Stat(targetPath); err == nil {
@@ -1600,7 +1974,7 @@ func (pm *ProxyManager) downloadAllGGUFFiles(searchResults *HuggingFaceSearchRes
 		return fmt.Errorf("failed to create download directory: %v", err)
 	}
 
-	pm.proxyLogger.Infof("Downloading first available GGUF file: %s (%.2f GB)", firstFile.Filename, float64(firstFile.Size)/(1024*1024*1024))
+	pm.proxyLogger.Infof("Downloading preferred GGUF file: %s (%.2f GB)", firstFile.Filename, float64(firstFile.Size)/(1024*1024*1024))
 
 	// Build download URL if not provided
 	downloadURL := firstFile.DownloadURL
@@ -1680,20 +2054,10 @@ func (pm *ProxyManager) waitForMultipleDownloads(downloadIDs []string, timeout t
 
 // autoDownloadModelFallback provides fallback behavior for when search API fails
 func (pm *ProxyManager) autoDownloadModelFallback(c *gin.Context, modelID, hfApiKey string) error {
-	// Use the original logic as fallback
-	commonQuantizations := []string{
-		"Q4_K_M", "Q5_K_M", "Q6_K", "Q8_0",
-	}
-
-	// Use the configured download directory
-	baseDownloadDir := pm.config.DownloadDir
-	if baseDownloadDir == "" {
-		baseDownloadDir = "./downloads"
-	}
-	downloadDir := filepath.Join(baseDownloadDir, strings.ReplaceAll(modelID, "/", "_"))
+	downloadDir := pm.repoDownloadDir(modelID)
 
-	// Try to download the first available quantization
-	for _, quant := range commonQuantizations {
+	// Try to download the quantizations in order of preference
+	for _, quant := range pm.quantPreference() {
 		filename := fmt.Sprintf("%s.gguf", quant)
 		url := fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s/%s", modelID, quant, filename)
 
@@ -1724,6 +2088,10 @@ func (pm *ProxyManager) waitForDownload(downloadID string, timeout time.Duration
 			if status == nil {
 				return fmt.Errorf("download %s not found", downloadID)
 			}
+			if pm.autoDownloads.repoCancelled(status.ModelID) {
+				pm.downloadManager.CancelDownload(downloadID)
+				return errAutoDownloadCancelled
+			}
 
 			switch status.Status {
 			case StatusCompleted:
@@ -1796,23 +2164,11 @@ func (pm *ProxyManager) reloadConfigForNewModel(modelID string, deferSave bool)
 	var absModelPath string
 
 	// Build the model path using configured download directory
-	baseDownloadDir := pm.config.DownloadDir
-	if baseDownloadDir == "" {
-		baseDownloadDir = "./downloads"
-	}
-	downloadDir := filepath.Join(baseDownloadDir, strings.ReplaceAll(baseModelID, "/", "_"))
+	downloadDir := pm.repoDownloadDir(baseModelID)
 
-	// If we don't have a specific file, find what was downloaded
+	// If we don't have a specific file, use the best quantization that was downloaded
 	if targetFile == "" {
-		// Find the first GGUF file in the directory (including subdirectories)
-		// First try root directory
-		files, err := filepath.Glob(filepath.Join(downloadDir, "*.gguf"))
-		if err != nil || len(files) == 0 {
-			// Try subdirectories (common for quantization-specific downloads)
-			files, err = filepath.Glob(filepath.Join(downloadDir, "*", "*.gguf"))
-		}
-		if err == nil && len(files) > 0 {
-			modelPath := files[0]
+		if modelPath, found := pm.findBestLocalQuant(downloadDir, targetQuantization); found {
 			// Get the relative path from downloadDir for proper construction later
 			relPath, _ := filepath.Rel(downloadDir, modelPath)
 			targetFile = relPath
@@ -2155,6 +2511,9 @@ func (pm *ProxyManager) reloadConfigForNewModel(modelID string, deferSave bool)
 			pm.proxyLogger.Debugf("Added alias mapping: %s -> %s", alias, modelName)
 		}
 	}
+	if err := buildAliasPatterns(&newConfig); err != nil {
+		pm.proxyLogger.Warnf("Failed to rebuild alias patterns: %v", err)
+	}
 
 	// CRITICAL: Update in-memory config and process groups atomically
 	// This ensures the model is available immediately for the current request
@@ -2179,6 +2538,7 @@ func (pm *ProxyManager) reloadConfigForNewModel(modelID string, deferSave bool)
 					// Add the new member to the existing group
 					if modelConfig, ok := newConfig.Models[memberName]; ok {
 						process := NewProcess(memberName, newConfig.HealthCheckTimeout, modelConfig, pm.upstreamLogger, pm.proxyLogger)
+						process.logFile = newModelLogFile(newConfig.ModelLogs, memberName)
 						existingGroup.processes[memberName] = process
 						pm.proxyLogger.Infof("Added process for model %s to existing group %s", memberName, groupName)
 					} else {
@@ -2252,7 +2612,7 @@ func (pm *ProxyManager) ensureMemoryAvailable(group *ProcessGroup, modelName str
 	unloadedCount := 0
 	for groupId, otherGroup := range pm.processGroups {
 		if groupId != group.id && !otherGroup.persistent {
-			otherGroup.StopProcesses(StopImmediately)
+			pm.stopGroupsForLoad([]*ProcessGroup{otherGroup}, StopImmediately)
 			unloadedCount++
 
 			// Check memory again after unloading
@@ -1,11 +1,14 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -26,46 +29,59 @@ import (
 )
 
 type Model struct {
-	Id          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	State       string `json:"state"`
-	Unlisted    bool   `json:"unlisted"`
-	ProxyURL    string `json:"proxyUrl"`
+	Id             string        `json:"id"`
+	Name           string        `json:"name"`
+	Description    string        `json:"description"`
+	State          string        `json:"state"`
+	Unlisted       bool          `json:"unlisted"`
+	ProxyURL       string        `json:"proxyUrl"`
+	FailReason     string        `json:"failReason,omitempty"` // why the last load failed, e.g. out of memory
+	FailCode       LoadErrorCode `json:"failCode,omitempty"`   // classification of the last failure, see load_errors.go
+	FailSuggestion string        `json:"failSuggestion,omitempty"`
+	Unhealthy      bool          `json:"unhealthy,omitempty"`        // ready but failing its periodic health checks
+	Archived       bool          `json:"archived,omitempty"`         // files are in archiveDir, restored on the next load
+	LoadingMs      int64         `json:"loadingElapsedMs,omitempty"` // time spent loading so far while starting
 }
 
 // SystemSettings persist user-chosen settings for autosetup/regeneration
 type SystemSettings struct {
-	GPUType          string  `json:"gpuType"` // nvidia|amd|intel|apple|none
-	Backend          string  `json:"backend"` // cuda|rocm|vulkan|metal|mlx|cpu
-	VRAMGB           float64 `json:"vramGB"`
-	RAMGB            float64 `json:"ramGB"`
-	PreferredContext int     `json:"preferredContext"`
-	ThroughputFirst  bool    `json:"throughputFirst"`
-	EnableJinja      bool    `json:"enableJinja"`
-	RequireAPIKey    bool    `json:"requireApiKey"`
-	APIKey           string  `json:"apiKey,omitempty"`
-	HuggingFaceApiKey string `json:"huggingFaceApiKey,omitempty"`
-}
-
+	GPUType                string                    `json:"gpuType"` // nvidia|amd|intel|apple|none
+	Backend                string                    `json:"backend"` // cuda|rocm|vulkan|metal|mlx|cpu
+	VRAMGB                 float64                   `json:"vramGB"`
+	RAMGB                  float64                   `json:"ramGB"`
+	PreferredContext       int                       `json:"preferredContext"`
+	ThroughputFirst        bool                      `json:"throughputFirst"`
+	EnableJinja            bool                      `json:"enableJinja"`
+	RequireAPIKey          bool                      `json:"requireApiKey"`
+	APIKey                 string                    `json:"apiKey,omitempty"`
+	APIKeys                []ScopedAPIKey            `json:"apiKeys,omitempty"` // keys limited to some models, see api_keys.go
+	HuggingFaceApiKey      string                    `json:"huggingFaceApiKey,omitempty"`
+	GPUVRAMOverrides       map[string]float64        `json:"gpuVramOverrides,omitempty"`       // GPU index or name to VRAM in GB, see vram_overrides.go
+	ModelOverrides         []autosetup.ModelOverride `json:"modelOverrides,omitempty"`         // extra flags for matching models when generating the config
+	CmdTemplates           map[string]string         `json:"cmdTemplates,omitempty"`           // GGUF architecture to llama-server flags, see autosetup.DefaultCmdTemplates
+	AutoDownloadAllowlist  []string                  `json:"autoDownloadAllowlist,omitempty"`  // repo patterns requests may auto-download, see download_allowlist.go
+	QuantizationPreference []string                  `json:"quantizationPreference,omitempty"` // quantizations most preferred first, see quant_preference.go
+}
+
+// getSystemSettingsPath returns the settings file, see file_paths.go
 func (pm *ProxyManager) getSystemSettingsPath() string {
-	return "settings.json"
+	return CurrentFilePaths().Settings
 }
 
+// loadSystemSettings returns the saved settings with the environment overrides
+// applied, see settings_effective.go. nil when there are neither. The settings
+// are shared, callers must not change them.
 func (pm *ProxyManager) loadSystemSettings() (*SystemSettings, error) {
-	path := pm.getSystemSettingsPath()
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, nil
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var s SystemSettings
-	if err := json.Unmarshal(data, &s); err != nil {
-		return nil, err
-	}
-	return &s, nil
+	_, effective, err := pm.cachedSystemSettings()
+	return effective, err
+}
+
+// loadSavedSystemSettings returns settings.json as saved, nil when there is none.
+// It is read from disk on first use and kept in memory, see settings_reload.go.
+// The settings are shared, callers must not change them.
+func (pm *ProxyManager) loadSavedSystemSettings() (*SystemSettings, error) {
+	saved, _, err := pm.cachedSystemSettings()
+	return saved, err
 }
 
 func (pm *ProxyManager) saveSystemSettings(s *SystemSettings) error {
@@ -73,23 +89,32 @@ func (pm *ProxyManager) saveSystemSettings(s *SystemSettings) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(pm.getSystemSettingsPath(), data, 0644)
+	if err := os.WriteFile(pm.getSystemSettingsPath(), data, 0644); err != nil {
+		return err
+	}
+	pm.cacheSystemSettings(s)
+	return nil
 }
 
 func addApiHandlers(pm *ProxyManager) {
 	// Add API endpoints for React to consume
-	apiGroup := pm.ginEngine.Group("/api", pm.requireAPIKey())
+	apiGroup := pm.ginEngine.Group("/api", pm.requireAPIKey(), pm.rejectInReadOnlyMode())
 	{
 		apiGroup.POST("/models/unload", pm.apiUnloadAllModels)
 		apiGroup.POST("/models/unload/:model", pm.apiUnloadModel)
 		apiGroup.POST("/models/load/:model", pm.apiLoadModel) // NEW: Load specific model with auto-download if needed
 		apiGroup.GET("/events", pm.apiSendEvents)
 		apiGroup.GET("/metrics", pm.apiGetMetrics)
-		apiGroup.GET("/activity/stats", pm.apiGetActivityStats)  // NEW: Get persistent activity statistics
+		apiGroup.GET("/activity/stats", pm.apiGetActivityStats)          // NEW: Get persistent activity statistics
+		apiGroup.POST("/activity/stats/reset", pm.apiResetActivityStats) // NEW: Clear activity statistics, all or ?model=
+		apiGroup.GET("/activity/export", pm.apiExportActivityStats)
+		apiGroup.GET("/activity/context-usage", pm.apiGetContextUsage) // NEW: Context usage vs configured --ctx-size
 
 		// Model downloader endpoints
 		apiGroup.GET("/system/specs", pm.apiGetSystemSpecs)
 		apiGroup.GET("/system/detection", pm.apiGetSystemDetection) // NEW: Comprehensive system detection for setup
+		apiGroup.GET("/system/gpu-health", pm.apiGetGPUHealth)      // NEW: GPU error states from nvidia-smi
+		apiGroup.GET("/system/gpu-thermal", pm.apiGetGPUThermal)    // NEW: GPU temperatures, clocks and throttling
 		apiGroup.GET("/settings/hf-api-key", pm.apiGetHFApiKey)
 		apiGroup.POST("/settings/hf-api-key", pm.apiSetHFApiKey)
 		apiGroup.POST("/models/download", pm.apiDownloadModel)
@@ -98,17 +123,50 @@ func addApiHandlers(pm *ProxyManager) {
 		apiGroup.GET("/models/downloads/:id", pm.apiGetDownloadStatus)
 		apiGroup.POST("/models/downloads/:id/pause", pm.apiPauseDownload)
 		apiGroup.POST("/models/downloads/:id/resume", pm.apiResumeDownload)
+		apiGroup.POST("/models/downloads/:id/move", pm.apiMoveDownload)
 		apiGroup.GET("/models/download-destinations", pm.apiGetDownloadDestinations) // NEW: Get available download destinations
-		apiGroup.GET("/models/search", pm.apiSearchModels) // NEW: Search HuggingFace models with stats
+		apiGroup.GET("/models/auto-download/queue", pm.apiGetAutoDownloadQueue)      // NEW: Auto-downloads inference requests wait on
+		apiGroup.DELETE("/models/auto-download/queue", pm.apiClearAutoDownloadQueue) // NEW: Cancel pending auto-downloads, all or ?model=
+		apiGroup.GET("/models/search", pm.apiSearchModels)                           // NEW: Search HuggingFace models with stats
+		apiGroup.GET("/models/hf-size", pm.apiGetHFRepoSize)                         // NEW: Per quantization download size of a HuggingFace repo
+		apiGroup.GET("/models/hf-metadata", pm.apiGetHFMetadata)                     // NEW: GGUF metadata of a HuggingFace file from its header only
+		apiGroup.POST("/models/download-best-fit", pm.apiDownloadBestFit)            // NEW: Download the best quantization that fits the VRAM
+		apiGroup.GET("/models/:id/kv-cache-info", pm.apiGetKVCacheInfo)              // NEW: KV cache memory at various context sizes
+		apiGroup.GET("/models/:id/capacity", pm.apiGetModelCapacity)                 // NEW: Concurrent sequences that fit in VRAM
+		apiGroup.POST("/models/:id/calibrate", pm.apiCalibrateModel)                 // NEW: Benchmark and save the fastest batch sizes
+		apiGroup.POST("/models/:id/benchmark", pm.apiBenchmarkModel)                 // NEW: Prompt and generation speed and TTFT of a model
+		apiGroup.GET("/models/:id/benchmark", pm.apiGetModelBenchmark)               // NEW: Last benchmark of a model
+		apiGroup.GET("/models/:id/logs", pm.apiGetModelLogs)                         // NEW: Recent upstream output and log file of a model
+		apiGroup.POST("/models/:id/reanalyze", pm.apiReanalyzeModel)                 // NEW: Re-read the GGUF and regenerate the model's cmd
+		apiGroup.POST("/models/validate-cmd", pm.apiValidateCmd)                     // NEW: Check and normalize a pasted cmd
+		apiGroup.GET("/models/orphans", pm.apiGetOrphanModels)                       // NEW: GGUF files not used by any configured model
+		apiGroup.POST("/models/orphans/delete", pm.apiDeleteOrphanModels)            // NEW: Delete selected orphaned GGUF files
+		apiGroup.POST("/models/:id/visibility", pm.apiSetModelVisibility)            // NEW: Mark a model as listed or unlisted
+		apiGroup.GET("/models/archived", pm.apiGetArchivedModels)                    // NEW: Models whose files are in archiveDir
+		apiGroup.POST("/models/:id/archive", pm.apiArchiveModel)                     // NEW: Move a stopped model's files to archiveDir
+		apiGroup.POST("/models/:id/restore", pm.apiRestoreModel)                     // NEW: Move an archived model's files back
+		apiGroup.POST("/models/:id/chat-template", pm.apiSetModelChatTemplate)       // NEW: Set or clear a model's chatTemplateFile
+		apiGroup.POST("/models/:id/lora", pm.apiSetModelLoRA)                        // NEW: Enable or disable a LoRA adapter of a model
+		apiGroup.GET("/models/:id/group", pm.apiGetModelGroup)                       // NEW: Group, swap policy and siblings of a model
+		apiGroup.GET("/models/:id/snippets", pm.apiGetModelSnippets)                 // NEW: curl, Python and JS code calling a model
+		apiGroup.GET("/models/:id/generation-stream", pm.apiGenerationStream)        // NEW: Live token stats of a streaming generation
+		apiGroup.POST("/models/:id/try-params", pm.apiTryParams)                     // NEW: Restart a model with other KV cache types without saving them
+		apiGroup.GET("/models/:id/try-params", pm.apiGetTryParams)                   // NEW: KV cache types a model runs with and its config sets
 
 		// System settings persistence
 		apiGroup.GET("/settings/system", pm.apiGetSystemSettings)
 		apiGroup.POST("/settings/system", pm.apiSetSystemSettings)
+		apiGroup.POST("/settings/reload", pm.apiReloadSystemSettings) // NEW: Re-read settings.json changed on disk
+		apiGroup.GET("/settings/recommended", pm.apiGetRecommendedSettings)
+		apiGroup.GET("/settings/effective", pm.apiGetEffectiveSettings) // NEW: Settings in use after env overrides and detection
 
 		// Configuration management endpoints
 		apiGroup.GET("/config", pm.apiGetConfig)
+		apiGroup.GET("/config/effective", pm.apiGetEffectiveConfig) // NEW: Config with macros and defaults resolved
 		apiGroup.POST("/config", pm.apiUpdateConfig)
-		apiGroup.POST("/config/model/:id", pm.apiUpdateModelParams) // NEW: Selective model parameter update
+		apiGroup.POST("/config/model/:id", pm.apiUpdateModelParams)                  // NEW: Selective model parameter update
+		apiGroup.GET("/config/profiles", pm.apiGetConfigProfiles)                    // NEW: List config profiles
+		apiGroup.POST("/config/profile/:name/activate", pm.apiActivateConfigProfile) // NEW: Switch to a config profile
 		apiGroup.POST("/config/scan-folder", pm.apiScanModelFolder)
 		apiGroup.POST("/config/add-model", pm.apiAddModel)
 		apiGroup.POST("/config/append-model", pm.apiAppendModelToConfig) // NEW: Append model to existing config
@@ -133,6 +191,7 @@ func addApiHandlers(pm *ProxyManager) {
 		apiGroup.GET("/binary/status", pm.apiGetBinaryStatus)          // Get current binary information
 		apiGroup.POST("/binary/update", pm.apiUpdateBinary)            // Update binary to latest version
 		apiGroup.POST("/binary/update/force", pm.apiForceUpdateBinary) // Force update binary (even if same version)
+		apiGroup.POST("/binary/optimize", pm.apiOptimizeBinary)        // NEW: Replace the binary with the best build for the hardware
 	}
 }
 
@@ -192,6 +251,12 @@ func (pm *ProxyManager) apiLoadModel(c *gin.Context) {
 		return
 	}
 
+	// an archived model's file is missing until it is restored
+	if err := pm.restoreBeforeLoad(modelName); err != nil {
+		pm.sendSwapError(c, err)
+		return
+	}
+
 	// Extract model path from Cmd if available
 	modelPath := ""
 	if modelConfig.Cmd != "" {
@@ -209,23 +274,21 @@ func (pm *ProxyManager) apiLoadModel(c *gin.Context) {
 	if modelPath != "" {
 		if _, err := os.Stat(modelPath); os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, gin.H{
-				"error": fmt.Sprintf("model file not found: %s", modelPath),
-				"model": modelName,
-				"path":  modelPath,
+				"error":      fmt.Sprintf("model file not found: %s", modelPath),
+				"model":      modelName,
+				"path":       modelPath,
 				"suggestion": "Use the model downloader to download this model first"})
 			return
 		}
 	}
 
 	// Check if model is already loaded
-	pm.Lock()
-	_, isLoaded := pm.processGroups[modelName]
-	pm.Unlock()
+	isLoaded := pm.modelProcessState(modelName) == StateReady
 
 	if isLoaded {
 		c.JSON(http.StatusOK, gin.H{
-			"msg": "model already loaded",
-			"model": modelName,
+			"msg":    "model already loaded",
+			"model":  modelName,
 			"status": "loaded"})
 		return
 	}
@@ -239,8 +302,8 @@ func (pm *ProxyManager) apiLoadModel(c *gin.Context) {
 	}()
 
 	c.JSON(http.StatusOK, gin.H{
-		"msg": "model loading initiated",
-		"model": modelName,
+		"msg":    "model loading initiated",
+		"model":  modelName,
 		"status": "loading"})
 }
 
@@ -253,12 +316,16 @@ func (pm *ProxyManager) getModelStatus() []Model {
 		modelIDs = append(modelIDs, modelID)
 	}
 	sort.Strings(modelIDs)
+	archived := pm.archivedModels()
 
 	// Iterate over sorted keys
 	for _, modelID := range modelIDs {
 		// Get process state
 		processGroup := pm.findGroupByModelName(modelID)
 		state := "unknown"
+		var loadError *LoadError
+		var loading time.Duration
+		unhealthy := false
 		if processGroup != nil {
 			process := processGroup.processes[modelID]
 			if process != nil {
@@ -278,15 +345,26 @@ func (pm *ProxyManager) getModelStatus() []Model {
 					stateStr = "unknown"
 				}
 				state = stateStr
+				loadError, loading = process.loadStatus()
+				unhealthy = stateStr == "ready" && !process.Healthy()
 			}
 		}
+		if loadError == nil {
+			loadError = &LoadError{}
+		}
 		models = append(models, Model{
-			Id:          modelID,
-			Name:        pm.config.Models[modelID].Name,
-			Description: pm.config.Models[modelID].Description,
-			State:       state,
-			Unlisted:    pm.config.Models[modelID].Unlisted,
-			ProxyURL:    pm.config.Models[modelID].Proxy,
+			Id:             modelID,
+			Name:           pm.config.Models[modelID].Name,
+			Description:    pm.config.Models[modelID].Description,
+			State:          state,
+			Unlisted:       pm.config.Models[modelID].Unlisted,
+			ProxyURL:       pm.config.Models[modelID].Proxy,
+			FailReason:     loadError.Reason,
+			FailCode:       loadError.Code,
+			FailSuggestion: loadError.Suggestion,
+			Unhealthy:      unhealthy,
+			Archived:       archived[modelID].ModelID != "",
+			LoadingMs:      loading.Milliseconds(),
 		})
 	}
 
@@ -367,6 +445,12 @@ func (pm *ProxyManager) apiSendEvents(c *gin.Context) {
 	defer event.On(func(e ConfigFileChangedEvent) {
 		sendModels()
 	})()
+	defer event.On(func(e ModelLoadFailedEvent) {
+		sendModels()
+	})()
+	defer event.On(func(e ProcessHealthChangedEvent) {
+		sendModels()
+	})()
 
 	/**
 	 * Send Log data
@@ -379,11 +463,26 @@ func (pm *ProxyManager) apiSendEvents(c *gin.Context) {
 	})()
 
 	/**
-	 * Send Metrics data
+	 * Send Metrics data, batched per metricsBatchInterval
 	 */
+	var batch metricsBatch
 	defer event.On(func(e TokenMetricsEvent) {
-		sendMetrics([]TokenMetrics{e.Metrics})
+		batch.add(e.Metrics)
 	})()
+	go func() {
+		ticker := time.NewTicker(metricsBatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if metrics := batch.take(); len(metrics) > 0 {
+					sendMetrics(metrics)
+				}
+			}
+		}
+	}()
 
 	/**
 	 * Send Download progress data
@@ -457,11 +556,8 @@ func (pm *ProxyManager) apiGetMetrics(c *gin.Context) {
 // API handlers for ModelDownloader functionality
 
 func (pm *ProxyManager) apiGetSystemSpecs(c *gin.Context) {
-	// Use real system detection from autosetup package
-	system := autosetup.DetectSystem()
-
-	// Enhance with detailed system information
-	err := autosetup.EnhanceSystemInfo(&system)
+	// Use real system detection from autosetup package, with the user's VRAM overrides
+	system, err := pm.detectSystem()
 	if err != nil {
 		// Log error but continue with basic info
 		pm.proxyLogger.Errorf("Failed to enhance system info: %v", err)
@@ -484,13 +580,17 @@ func (pm *ProxyManager) apiGetSystemSpecs(c *gin.Context) {
 		availableRAM = int64(realtimeInfo.AvailableRAMGB * 1024 * 1024 * 1024)
 		availableVRAM = int64(realtimeInfo.AvailableVRAMGB * 1024 * 1024 * 1024)
 		totalRAM = int64(realtimeInfo.TotalRAMGB * 1024 * 1024 * 1024)
-		totalVRAM = int64(realtimeInfo.TotalVRAMGB * 1024 * 1024 * 1024)
+		if !system.HasVRAMOverrides() {
+			totalVRAM = int64(realtimeInfo.TotalVRAMGB * 1024 * 1024 * 1024)
+		} else if availableVRAM > totalVRAM {
+			availableVRAM = totalVRAM
+		}
 	}
 
 	// Get primary GPU name
 	gpuName := "CPU Only"
-	if len(system.VRAMDetails) > 0 {
-		gpuName = system.VRAMDetails[0].Name
+	if gpu, ok := system.PrimaryGPU(); ok {
+		gpuName = gpu.Name
 	}
 
 	// Get actual available disk space
@@ -510,11 +610,8 @@ func (pm *ProxyManager) apiGetSystemSpecs(c *gin.Context) {
 
 // apiGetSystemDetection provides comprehensive system detection for setup UI auto-population
 func (pm *ProxyManager) apiGetSystemDetection(c *gin.Context) {
-	// Perform comprehensive system detection
-	system := autosetup.DetectSystem()
-
-	// Enhance with detailed system information
-	err := autosetup.EnhanceSystemInfo(&system)
+	// Perform comprehensive system detection, with the user's VRAM overrides
+	system, err := pm.detectSystem()
 	if err != nil {
 		pm.proxyLogger.Errorf("Failed to enhance system info: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect system information"})
@@ -527,42 +624,9 @@ func (pm *ProxyManager) apiGetSystemDetection(c *gin.Context) {
 		pm.proxyLogger.Warnf("Failed to get realtime hardware info: %v", err)
 	}
 
-	// Determine optimal backend priority
-	backends := []string{}
-	primaryBackend := "cpu"
-
-	if system.HasCUDA {
-		backends = append(backends, "cuda")
-		primaryBackend = "cuda"
-	}
-	if system.HasROCm {
-		backends = append(backends, "rocm")
-		if primaryBackend == "cpu" {
-			primaryBackend = "rocm"
-		}
-	}
-	if system.HasVulkan {
-		backends = append(backends, "vulkan")
-		if primaryBackend == "cpu" {
-			primaryBackend = "vulkan"
-		}
-	}
-	if system.HasMLX {
-		backends = append(backends, "mlx")
-		if primaryBackend == "cpu" {
-			primaryBackend = "mlx"
-		}
-	}
-	if system.HasMetal {
-		backends = append(backends, "metal")
-		if primaryBackend == "cpu" {
-			primaryBackend = "metal"
-		}
-	}
-	if system.HasIntel {
-		backends = append(backends, "intel")
-	}
-	backends = append(backends, "cpu") // Always available
+	rec := recommendForSystem(system, realtimeInfo)
+	backends := rec.Backends
+	primaryBackend := rec.PrimaryBackend
 
 	// Determine GPU type for UI dropdown
 	gpuType := "CPU Only"
@@ -588,84 +652,62 @@ func (pm *ProxyManager) apiGetSystemDetection(c *gin.Context) {
 		gpuTypes = append(gpuTypes, "Intel GPU")
 	}
 
-	// Calculate memory values
-	totalRAMGB := system.TotalRAMGB
-	totalVRAMGB := system.TotalVRAMGB
-	availableRAMGB := totalRAMGB * 0.75 // Conservative estimate
-
-	// Use realtime info if available
-	if realtimeInfo != nil {
-		availableRAMGB = realtimeInfo.AvailableRAMGB
-		if realtimeInfo.TotalRAMGB > 0 {
-			totalRAMGB = realtimeInfo.TotalRAMGB
-		}
-		if realtimeInfo.TotalVRAMGB > 0 {
-			totalVRAMGB = realtimeInfo.TotalVRAMGB
-		}
-	}
-
-	// Skip building detailed GPU information - we only need primary GPU for the response
-
-	// Determine recommended context size based on available memory
-	suggestedContextSize := 32768
-	maxRecommendedContextSize := 131072
-	if totalVRAMGB >= 24 {
-		suggestedContextSize = 131072 // 128K
-		maxRecommendedContextSize = 131072
-	} else if totalVRAMGB >= 16 {
-		suggestedContextSize = 65536 // 64K
-		maxRecommendedContextSize = 131072
-	} else if totalVRAMGB >= 8 {
-		suggestedContextSize = 32768 // 32K
-		maxRecommendedContextSize = 65536
-	} else {
-		suggestedContextSize = 16384 // 16K
-		maxRecommendedContextSize = 32768
-	}
-
-	// Performance priority recommendation
-	throughputFirst := totalVRAMGB >= 8
+	totalRAMGB := rec.TotalRAMGB
+	totalVRAMGB := rec.TotalVRAMGB
+	availableRAMGB := rec.AvailableRAMGB
+	suggestedContextSize := rec.SuggestedContextSize
+	maxRecommendedContextSize := rec.MaxRecommendedContextSize
+	throughputFirst := rec.ThroughputFirst
 
 	// Build primary GPU info
 	var primaryGPU interface{} = nil
-	gpuBrand := "unknown"
-	if len(system.VRAMDetails) > 0 {
-		gpu := system.VRAMDetails[0]
-		if system.HasCUDA {
-			gpuBrand = "nvidia"
-		} else if system.HasROCm {
-			gpuBrand = "amd"
-		} else if system.HasIntel {
-			gpuBrand = "intel"
-		} else if system.HasMetal || system.HasMLX {
-			gpuBrand = "apple"
+	if gpu, ok := system.PrimaryGPU(); ok {
+		primaryGPU = gpuDetectionInfo(gpu, gin.H{"brand": rec.GPUBrand})
+	}
+
+	// All detected GPUs, including an integrated GPU not used next to a discrete one
+	gpus := make([]gin.H, 0, len(system.VRAMDetails))
+	for i, gpu := range system.VRAMDetails {
+		gpus = append(gpus, gpuDetectionInfo(gpu, gin.H{"index": i, "type": gpu.Type}))
+	}
+
+	// GPUs without a working driver or backend are not in VRAMDetails, list
+	// them so the UI can point to the driver to install
+	gpuStatus := "detected"
+	physicalGPUs := []autosetup.PhysicalGPU{}
+	var driverHints []string
+	if len(system.VRAMDetails) == 0 {
+		gpuStatus = "none"
+		if found := autosetup.EnumeratePhysicalGPUs(); len(found) > 0 {
+			gpuStatus = "detected but no backend"
+			physicalGPUs = found
+			for _, gpu := range found {
+				driverHints = append(driverHints, fmt.Sprintf("%s found but no backend can use it: %s", gpu.Name, gpu.DriverHint))
+			}
 		}
+	}
 
-		primaryGPU = gin.H{
-			"name":   gpu.Name,
-			"brand":  gpuBrand,
-			"vramGB": math.Round(gpu.VRAMGB*10) / 10, // Round to 1 decimal place
-		}
+	notes := []string{
+		fmt.Sprintf("Detected %s with %.1fGB VRAM", primaryBackend, math.Round(totalVRAMGB*10)/10),
+		fmt.Sprintf("Recommended context size: %d tokens", suggestedContextSize),
+		fmt.Sprintf("Performance priority: %s", func() string {
+			if throughputFirst {
+				return "Speed (Higher throughput)"
+			}
+			return "Quality (Larger context)"
+		}()),
 	}
+	notes = append(notes, driverHints...)
 
 	// Build recommendations
 	recommendations := gin.H{
 		"primaryBackend":          primaryBackend,
 		"fallbackBackend":         "cpu",
 		"suggestedContextSize":    suggestedContextSize,
-		"suggestedVRAMAllocation": int(totalVRAMGB * 0.8),
-		"suggestedRAMAllocation":  int(totalRAMGB * 0.5),
+		"suggestedVRAMAllocation": rec.suggestedVRAMAllocation(),
+		"suggestedRAMAllocation":  rec.suggestedRAMAllocation(),
 		"throughputFirst":         throughputFirst,
-		"notes": []string{
-			fmt.Sprintf("Detected %s with %.1fGB VRAM", primaryBackend, math.Round(totalVRAMGB*10)/10),
-			fmt.Sprintf("Recommended context size: %d tokens", suggestedContextSize),
-			fmt.Sprintf("Performance priority: %s", func() string {
-				if throughputFirst {
-					return "Speed (Higher throughput)"
-				}
-				return "Quality (Larger context)"
-			}()),
-		},
+		"notes":                   notes,
 	}
 
 	detection := gin.H{
@@ -681,8 +723,11 @@ func (pm *ProxyManager) apiGetSystemDetection(c *gin.Context) {
 		"platform":                  system.OS,
 		"arch":                      system.Architecture,
 		"gpuDetected":               len(system.VRAMDetails) > 0,
+		"gpuStatus":                 gpuStatus,
+		"physicalGPUs":              physicalGPUs,
 		"gpuTypes":                  gpuTypes,
 		"primaryGPU":                primaryGPU,
+		"gpus":                      gpus,
 		"totalRAMGB":                math.Round(totalRAMGB*10) / 10,     // Round to 1 decimal place
 		"availableRAMGB":            math.Round(availableRAMGB*10) / 10, // Round to 1 decimal place
 		"recommendedBackends":       backends,
@@ -711,6 +756,8 @@ func (pm *ProxyManager) apiGetSetupProgress(c *gin.Context) {
 		"completed":        state.Completed,
 		"started_at":       state.StartedAt,
 		"updated_at":       state.UpdatedAt,
+		"job_id":           state.JobID,
+		"job_source":       state.JobSource,
 	})
 }
 
@@ -743,6 +790,7 @@ func (pm *ProxyManager) apiDownloadModel(c *gin.Context) {
 		Files           []string `json:"files,omitempty"`           // Optional: multiple files for multi-part downloads
 		IsMultiPart     bool     `json:"isMultiPart,omitempty"`     // Flag for multi-part downloads
 		Quantization    string   `json:"quantization,omitempty"`    // Quantization type for display
+		AutoLoad        bool     `json:"autoLoad,omitempty"`        // Load the model once the download completes
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -752,7 +800,7 @@ func (pm *ProxyManager) apiDownloadModel(c *gin.Context) {
 
 	// Handle multi-part downloads
 	if req.IsMultiPart && len(req.Files) > 0 {
-		downloadIDs, err := pm.downloadManager.StartMultiPartDownload(req.ModelId, req.Quantization, req.Files, req.HfApiKey, req.DestinationPath)
+		downloadIDs, err := pm.downloadManager.StartMultiPartDownloadWithOptions(req.ModelId, req.Quantization, req.Files, req.HfApiKey, req.DestinationPath, DownloadOptions{AutoLoad: req.AutoLoad})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -764,6 +812,7 @@ func (pm *ProxyManager) apiDownloadModel(c *gin.Context) {
 			"modelId":      req.ModelId,
 			"quantization": req.Quantization,
 			"partCount":    len(req.Files),
+			"autoLoad":     req.AutoLoad,
 		})
 		return
 	}
@@ -774,7 +823,7 @@ func (pm *ProxyManager) apiDownloadModel(c *gin.Context) {
 		return
 	}
 
-	downloadID, err := pm.downloadManager.StartDownload(req.ModelId, req.Filename, req.URL, req.HfApiKey, req.DestinationPath)
+	downloadID, err := pm.downloadManager.StartDownloadWithOptions(req.ModelId, req.Filename, req.URL, req.HfApiKey, req.DestinationPath, DownloadOptions{AutoLoad: req.AutoLoad})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -785,6 +834,7 @@ func (pm *ProxyManager) apiDownloadModel(c *gin.Context) {
 		"status":     "download started",
 		"modelId":    req.ModelId,
 		"filename":   req.Filename,
+		"autoLoad":   req.AutoLoad,
 	})
 }
 
@@ -824,7 +874,7 @@ func (pm *ProxyManager) apiGetDownloads(c *gin.Context) {
 
 // apiGetSystemSettings returns saved system settings
 func (pm *ProxyManager) apiGetSystemSettings(c *gin.Context) {
-	s, err := pm.loadSystemSettings()
+	s, err := pm.loadSavedSystemSettings()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to load settings: %v", err)})
 		return
@@ -836,6 +886,11 @@ func (pm *ProxyManager) apiGetSystemSettings(c *gin.Context) {
 	// Redact API key from response
 	redacted := *s
 	redacted.APIKey = ""
+	redacted.APIKeys = make([]ScopedAPIKey, len(s.APIKeys))
+	for i, entry := range s.APIKeys {
+		entry.Key = ""
+		redacted.APIKeys[i] = entry
+	}
 	c.JSON(http.StatusOK, gin.H{"settings": redacted})
 }
 
@@ -848,9 +903,7 @@ func (pm *ProxyManager) apiSetSystemSettings(c *gin.Context) {
 	}
 
 	// Platform-aware mapping: on macOS/arm, map unsupported to metal
-	if runtime.GOOS == "darwin" && (req.Backend == "cuda" || req.Backend == "rocm" || req.Backend == "vulkan") {
-		req.Backend = "metal"
-	}
+	req.Backend = platformBackend(req.Backend)
 
 	// Basic validation
 	if req.VRAMGB < 0 || req.RAMGB < 0 {
@@ -863,10 +916,40 @@ func (pm *ProxyManager) apiSetSystemSettings(c *gin.Context) {
 	}
 
 	// Preserve existing API key if require is true and new key is empty; also auto-populate hardware defaults when zeros
-	if existing, _ := pm.loadSystemSettings(); existing != nil {
+	if existing, _ := pm.loadSavedSystemSettings(); existing != nil {
 		if req.RequireAPIKey && strings.TrimSpace(req.APIKey) == "" && strings.TrimSpace(existing.APIKey) != "" {
 			req.APIKey = existing.APIKey
 		}
+		// keyring keys are redacted when read, keep the saved key of entries sent back without one
+		if req.APIKeys == nil {
+			req.APIKeys = append([]ScopedAPIKey(nil), existing.APIKeys...)
+		}
+		if req.GPUVRAMOverrides == nil {
+			req.GPUVRAMOverrides = existing.GPUVRAMOverrides
+		}
+		if req.ModelOverrides == nil {
+			req.ModelOverrides = existing.ModelOverrides
+		}
+		if req.CmdTemplates == nil {
+			req.CmdTemplates = existing.CmdTemplates
+		}
+		if req.AutoDownloadAllowlist == nil {
+			req.AutoDownloadAllowlist = existing.AutoDownloadAllowlist
+		}
+		if req.QuantizationPreference == nil {
+			req.QuantizationPreference = existing.QuantizationPreference
+		}
+		for i := range req.APIKeys {
+			if strings.TrimSpace(req.APIKeys[i].Key) != "" {
+				continue
+			}
+			for _, saved := range existing.APIKeys {
+				if saved.Name == req.APIKeys[i].Name {
+					req.APIKeys[i].Key = saved.Key
+					break
+				}
+			}
+		}
 		// If values are zero, carry forward existing (so we don't wipe)
 		if req.VRAMGB == 0 {
 			req.VRAMGB = existing.VRAMGB
@@ -885,11 +968,16 @@ func (pm *ProxyManager) apiSetSystemSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "requireApiKey=true needs a non-empty apiKey"})
 		return
 	}
+	if err := req.validateFields(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// If still zeros (first-time save), auto-populate from detection
 	if req.VRAMGB == 0 || req.RAMGB == 0 || req.PreferredContext == 0 || req.Backend == "" {
 		system := autosetup.DetectSystem()
 		_ = autosetup.EnhanceSystemInfo(&system)
+		autosetup.ApplyVRAMOverrides(&system, req.GPUVRAMOverrides)
 		if req.VRAMGB == 0 {
 			req.VRAMGB = system.TotalVRAMGB
 		}
@@ -900,18 +988,7 @@ func (pm *ProxyManager) apiSetSystemSettings(c *gin.Context) {
 			req.PreferredContext = 32768
 		}
 		if req.Backend == "" {
-			// crude mapping
-			if runtime.GOOS == "darwin" || system.HasMetal {
-				req.Backend = "metal"
-			} else if system.HasCUDA {
-				req.Backend = "cuda"
-			} else if system.HasROCm {
-				req.Backend = "rocm"
-			} else if system.HasVulkan {
-				req.Backend = "vulkan"
-			} else {
-				req.Backend = "cpu"
-			}
+			req.Backend = detectedBackend(system)
 		}
 	}
 
@@ -988,8 +1065,20 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 		hfToken = c.GetHeader("X-HF-Token")
 	}
 
+	// repeated searches within the TTL are served from the cache, refresh=true skips it
+	preference := pm.quantPreference()
+	cacheKey := searchCacheKey(query, limit, includeGated, hfToken, preference)
+	if c.Query("refresh") != "true" {
+		if response, found := pm.searchCache.get(cacheKey); found {
+			response["query"] = query
+			response["cached"] = true
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+
 	// Create HTTP client for HuggingFace API
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := autosetup.NewHTTPClient(30 * time.Second)
 
 	// Build search URL with enhanced query
 	enhancedQuery := query
@@ -1008,7 +1097,7 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 		searchParams.Add("cardData", "true")
 	}
 
-	searchURL := "https://huggingface.co/api/models?" + searchParams.Encode()
+	searchURL := pm.huggingFaceURL + "/api/models?" + searchParams.Encode()
 
 	// Create request
 	req, err := http.NewRequest("GET", searchURL, nil)
@@ -1091,23 +1180,7 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 						}
 
 						// Extract quantization from filename for suggested model ID
-						quantization := "unknown"
-						if matched, _ := regexp.MatchString(`[Qq]\d+_[KkMmSsLlXxSs]`, filename); matched {
-							re := regexp.MustCompile(`[Qq]\d+_[KkMmSsLlXxSs]`)
-							if match := re.FindString(filename); match != "" {
-								quantization = strings.ToLower(match)
-							}
-						} else if matched, _ := regexp.MatchString(`[Qq]\d+`, filename); matched {
-							re := regexp.MustCompile(`[Qq]\d+`)
-							if match := re.FindString(filename); match != "" {
-								quantization = strings.ToLower(match)
-							}
-						} else if matched, _ := regexp.MatchString(`IQ\d+_[KkMmSsLlXxSs]+`, filename); matched {
-							re := regexp.MustCompile(`IQ\d+_[KkMmSsLlXxSs]+`)
-							if match := re.FindString(filename); match != "" {
-								quantization = strings.ToLower(match)
-							}
-						}
+						quantization := quantizationTag(filename)
 
 						// Generate suggested model ID for this quantization
 						suggestedModelID := modelID
@@ -1116,12 +1189,12 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 						}
 
 						fileInfo := map[string]interface{}{
-							"filename":           filename,
-							"size":              size,
-							"isSplit":           isSplit,
-							"baseName":          baseName,
-							"quantization":      quantization,
-							"suggestedModelID":  suggestedModelID,
+							"filename":         filename,
+							"size":             size,
+							"isSplit":          isSplit,
+							"baseName":         baseName,
+							"quantization":     quantization,
+							"suggestedModelID": suggestedModelID,
 						}
 
 						if isSplit {
@@ -1157,17 +1230,7 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 				}
 
 				// Extract quantization for split models
-				if matched, _ := regexp.MatchString(`[Qq]\d+_[KkMmSsLlXxSs]`, baseName); matched {
-					re := regexp.MustCompile(`[Qq]\d+_[KkMmSsLlXxSs]`)
-					if match := re.FindString(baseName); match != "" {
-						quantization = strings.ToLower(match)
-					}
-				} else if matched, _ := regexp.MatchString(`[Qq]\d+`, baseName); matched {
-					re := regexp.MustCompile(`[Qq]\d+`)
-					if match := re.FindString(baseName); match != "" {
-						quantization = strings.ToLower(match)
-					}
-				}
+				quantization = quantizationTag(baseName)
 
 				// Generate suggested model ID for split model
 				suggestedModelID := modelID
@@ -1176,7 +1239,7 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 				}
 
 				ggufFiles = append(ggufFiles, map[string]interface{}{
-					"filename":          displayName + " (Split Model)",
+					"filename":         displayName + " (Split Model)",
 					"size":             groupSize,
 					"isSplit":          true,
 					"partCount":        len(parts),
@@ -1187,6 +1250,13 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 				totalSize += groupSize
 			}
 
+			// most preferred quantization first, the one auto-download would pick
+			preference.sortSearchFiles(ggufFiles)
+			if len(ggufFiles) > 0 {
+				enhanced["recommendedQuantization"] = ggufFiles[0]["quantization"]
+				enhanced["recommendedModelID"] = ggufFiles[0]["suggestedModelID"]
+			}
+
 			enhanced["ggufFiles"] = ggufFiles
 			enhanced["ggufCount"] = len(ggufFiles)
 			enhanced["totalSize"] = totalSize
@@ -1204,13 +1274,15 @@ func (pm *ProxyManager) apiSearchModels(c *gin.Context) {
 	}
 
 	// Return enhanced results with stats
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"models":      enhancedResults,
 		"totalCount":  len(enhancedResults),
 		"query":       query,
 		"enhanced":    true,
 		"gatedSearch": includeGated && hfToken != "",
-	})
+	}
+	pm.searchCache.put(cacheKey, response)
+	c.JSON(http.StatusOK, response)
 }
 
 // getAvailableDiskSpace detects available disk space in bytes
@@ -1286,7 +1358,7 @@ func (pm *ProxyManager) getUnixDiskSpace() int64 {
 
 func (pm *ProxyManager) apiGetConfig(c *gin.Context) {
 	// Read the current config file
-	configData, err := os.ReadFile("config.yaml")
+	configData, err := os.ReadFile(pm.currentConfigPath())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read config file"})
 		return
@@ -1318,20 +1390,21 @@ func (pm *ProxyManager) apiUpdateConfig(c *gin.Context) {
 	}
 
 	// Backup current config
-	backupPath := "config.yaml.backup." + strconv.FormatInt(time.Now().Unix(), 10)
+	configPath := pm.currentConfigPath()
+	backupPath := configPath + ".backup." + strconv.FormatInt(time.Now().Unix(), 10)
 	if err := pm.backupConfigFile(backupPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to backup config"})
 		return
 	}
 
 	// Write new config
-	if err := os.WriteFile("config.yaml", []byte(req.Yaml), 0644); err != nil {
+	if err := os.WriteFile(configPath, []byte(req.Yaml), 0644); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write config file"})
 		return
 	}
 
 	// Validate the new config
-	if _, err := LoadConfig("config.yaml"); err != nil {
+	if _, err := LoadConfig(configPath); err != nil {
 		// Restore backup if validation fails
 		if backupErr := pm.restoreConfigFile(backupPath); backupErr != nil {
 			pm.proxyLogger.Errorf("Failed to restore config backup: %v", backupErr)
@@ -1407,6 +1480,8 @@ func (pm *ProxyManager) apiScanModelFolder(c *gin.Context) {
 	}
 
 	var allModels []autosetup.ModelInfo
+	archivedModels := []autosetup.ArchivedModelInfo{}
+	loraAdapters := []gin.H{}
 	var scanSummary []gin.H
 
 	// Scan each folder
@@ -1423,11 +1498,30 @@ func (pm *ProxyManager) apiScanModelFolder(c *gin.Context) {
 			continue
 		}
 
+		// GGUFs inside archives are reported only, they need extracting before use
+		archived, err := autosetup.DetectArchivedModels(folderPath)
+		if err != nil {
+			pm.proxyLogger.Warnf("Failed to scan archives in %s: %v", folderPath, err)
+		}
+		archivedModels = append(archivedModels, archived...)
+
+		// LoRA adapters are left out of the models, they can only be attached to one with --lora
+		adapters, err := autosetup.DetectLoRAAdapters(folderPath)
+		if err != nil {
+			pm.proxyLogger.Warnf("Failed to scan LoRA adapters in %s: %v", folderPath, err)
+		}
+		for _, adapter := range adapters {
+			loraAdapters = append(loraAdapters, gin.H{"name": adapter.Name, "path": adapter.Path})
+		}
+
+		autosetup.TagVisionModels(models, autosetup.FindMMProjMatches(models, folderPath))
 		allModels = append(allModels, models...)
 		scanSummary = append(scanSummary, gin.H{
-			"folder": folderPath,
-			"status": "success",
-			"models": len(models),
+			"folder":         folderPath,
+			"status":         "success",
+			"models":         len(models),
+			"archivedModels": len(archived),
+			"loraAdapters":   len(adapters),
 		})
 	}
 
@@ -1474,11 +1568,14 @@ func (pm *ProxyManager) apiScanModelFolder(c *gin.Context) {
 			"contextLength": model.ContextLength,
 			"numLayers":     model.NumLayers,
 			"isMoE":         model.IsMoE,
+			"tags":          model.Tags,
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"models":         apiModels,
+		"archivedModels": archivedModels,
+		"loraAdapters":   loraAdapters,
 		"scanSummary":    scanSummary,
 		"totalModels":    len(allModels),
 		"foldersScanned": len(foldersToScan),
@@ -1487,14 +1584,17 @@ func (pm *ProxyManager) apiScanModelFolder(c *gin.Context) {
 
 // Database management functions
 func (pm *ProxyManager) getModelFolderDatabasePath() string {
-	return "model_folders.json"
+	return CurrentFilePaths().FolderDatabase
 }
 
 func (pm *ProxyManager) loadModelFolderDatabase() (*ModelFolderDatabase, error) {
-	dbPath := pm.getModelFolderDatabasePath()
+	return readModelFolderDatabase(pm.getModelFolderDatabasePath())
+}
 
+// readModelFolderDatabase reads the folder database at dbPath
+func readModelFolderDatabase(dbPath string) (*ModelFolderDatabase, error) {
 	// Create empty database if file doesn't exist
-	if !pm.fileExists(dbPath) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		return &ModelFolderDatabase{
 			Folders: []ModelFolderEntry{},
 			Version: "1.0",
@@ -1615,7 +1715,7 @@ func (pm *ProxyManager) apiAddModel(c *gin.Context) {
 	}
 
 	// Generate SMART configuration using the same logic as command-line
-	modelConfig, err := pm.generateSmartModelConfig(*targetModel, options)
+	modelConfig, _, err := pm.generateSmartModelConfig(*targetModel, options)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1634,6 +1734,7 @@ func (pm *ProxyManager) apiAddModel(c *gin.Context) {
 			"contextLength": targetModel.ContextLength,
 			"numLayers":     targetModel.NumLayers,
 			"isMoE":         targetModel.IsMoE,
+			"tags":          targetModel.Tags,
 		},
 	})
 }
@@ -1718,14 +1819,14 @@ func (pm *ProxyManager) apiAppendModelToConfig(c *gin.Context) {
 	}
 
 	// Load existing config
-	configPath := "config.yaml"
+	configPath := pm.currentConfigPath()
 	if !pm.fileExists(configPath) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "config.yaml not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": configPath + " not found"})
 		return
 	}
 
 	// Generate SMART configuration for the new model
-	modelConfig, err := pm.generateSmartModelConfig(*targetModel, options)
+	modelConfig, macros, err := pm.generateSmartModelConfig(*targetModel, options)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1744,8 +1845,34 @@ func (pm *ProxyManager) apiAppendModelToConfig(c *gin.Context) {
 		return
 	}
 
-	// Append to existing config
-	err = pm.appendModelToConfig(configPath, modelID, modelConfig)
+	// Optionally make sure llama-server can actually load the model before committing it
+	var verification gin.H
+	if c.Query("verify") == "true" {
+		verification = pm.verifyGeneratedModel(modelID, modelConfig, macros)
+		if verification["verified"] != true {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":        fmt.Sprintf("Model %s failed verification and was not added to config", modelID),
+				"modelId":      modelID,
+				"verification": verification,
+			})
+			return
+		}
+	}
+
+	// Append to existing config, with the macros its cmd refers to
+	generatedConfig, ok := modelConfig["config"].(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "generated model config is invalid"})
+		return
+	}
+	err = pm.appendModelToConfig(configPath, modelID, generatedConfig)
+	if err == nil {
+		err = appendMissingMacros(configPath, macros)
+	}
+	if errors.Is(err, errModelLimit) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to append model to config: %v", err)})
 		return
@@ -1758,7 +1885,17 @@ func (pm *ProxyManager) apiAppendModelToConfig(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	// Optionally load the new model right away and warm it up
+	var warm gin.H
+	if c.Query("warm") == "true" {
+		if err := pm.applyAppendedModel(configPath, modelID); err != nil {
+			warm = gin.H{"ready": false, "error": err.Error()}
+		} else {
+			warm = pm.warmModel(modelID, targetModel.IsEmbedding)
+		}
+	}
+
+	response := gin.H{
 		"status":  "Model successfully appended to config.yaml",
 		"modelId": modelID,
 		"modelInfo": gin.H{
@@ -1768,17 +1905,104 @@ func (pm *ProxyManager) apiAppendModelToConfig(c *gin.Context) {
 			"isInstruct":    targetModel.IsInstruct,
 			"isEmbedding":   targetModel.IsEmbedding,
 			"contextLength": targetModel.ContextLength,
+			"tags":          targetModel.Tags,
 		},
 		"requiresRestart": true,
 		"restartMessage":  "New model has been added to configuration. Would you like to restart the server to apply changes?",
-	})
+	}
+	if verification != nil {
+		response["verification"] = verification
+	}
+	if warm != nil {
+		response["warm"] = warm
+		if warm["ready"] == true {
+			response["requiresRestart"] = false
+			response["restartMessage"] = "New model has been added to configuration and is loaded."
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// verifyGeneratedModel starts the model described by a generateSmartModelConfig
+// result on a free port, waits for it to become healthy and stops it again
+func (pm *ProxyManager) verifyGeneratedModel(modelID string, generated gin.H, macros map[string]interface{}) gin.H {
+	startTime := time.Now()
+	result := gin.H{"verified": false}
+
+	modelConfig, err := buildVerificationModelConfig(modelID, generated, macros)
+	if err == nil {
+		err = pm.modelVerifier(modelID, modelConfig)
+	}
+
+	result["durationMs"] = time.Since(startTime).Milliseconds()
+	if err != nil {
+		pm.proxyLogger.Warnf("<%s> Model verification failed: %v", modelID, err)
+		result["error"] = err.Error()
+		return result
+	}
+
+	pm.proxyLogger.Infof("<%s> Model verification passed in %v", modelID, time.Since(startTime))
+	result["verified"] = true
+	return result
+}
+
+// buildVerificationModelConfig expands the generated macros and ${PORT} into a
+// ModelConfig that can be started on its own
+func buildVerificationModelConfig(modelID string, generated gin.H, macros map[string]interface{}) (ModelConfig, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return ModelConfig{}, fmt.Errorf("failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	verifyConfig := map[string]interface{}{
+		"startPort": port,
+		"models":    map[string]interface{}{modelID: generated["config"]},
+	}
+	if macros != nil {
+		verifyConfig["macros"] = macros
+	}
+
+	data, err := yaml.Marshal(verifyConfig)
+	if err != nil {
+		return ModelConfig{}, fmt.Errorf("failed to build verification config: %v", err)
+	}
+
+	config, err := LoadConfigFromReader(bytes.NewReader(data))
+	if err != nil {
+		return ModelConfig{}, fmt.Errorf("generated config is invalid: %v", err)
+	}
+
+	return config.Models[modelID], nil
+}
+
+// verifyModelStarts is the default modelVerifier
+func (pm *ProxyManager) verifyModelStarts(modelID string, modelConfig ModelConfig) error {
+	// like a load through its group, the members sharing the model's process
+	// are stopped while it runs
+	pm.Lock()
+	processGroup := pm.findGroupByModelName(modelID)
+	pm.Unlock()
+	if processGroup != nil && processGroup.sharedProcess {
+		processGroup.Lock()
+		defer processGroup.Unlock()
+		processGroup.releaseSharedPort(modelID)
+	}
+
+	modelConfig.UnloadAfter = 0
+	process := NewProcess(modelID+"-verify", pm.config.HealthCheckTimeout, modelConfig, pm.upstreamLogger, pm.proxyLogger)
+	defer process.StopImmediately()
+
+	return process.start()
 }
 
 // apiValidateModelsOnDisk validates that all models in config.yaml exist on disk and removes missing ones
 func (pm *ProxyManager) apiValidateModelsOnDisk(c *gin.Context) {
-	configPath := "config.yaml"
+	configPath := pm.currentConfigPath()
 	if !pm.fileExists(configPath) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "config.yaml not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": configPath + " not found"})
 		return
 	}
 
@@ -1852,6 +2076,11 @@ func (pm *ProxyManager) apiValidateConfig(c *gin.Context) {
 		return
 	}
 
+	warnings := config.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"valid":       true,
 		"modelCount":  len(config.Models),
@@ -1859,13 +2088,14 @@ func (pm *ProxyManager) apiValidateConfig(c *gin.Context) {
 		"macroCount":  len(config.Macros),
 		"startPort":   config.StartPort,
 		"downloadDir": config.DownloadDir,
+		"warnings":    warnings,
 	})
 }
 
 // Helper functions
 
 func (pm *ProxyManager) backupConfigFile(backupPath string) error {
-	sourceFile, err := os.Open("config.yaml")
+	sourceFile, err := os.Open(pm.currentConfigPath())
 	if err != nil {
 		return err
 	}
@@ -1882,7 +2112,7 @@ func (pm *ProxyManager) backupConfigFile(backupPath string) error {
 }
 
 func (pm *ProxyManager) restoreConfigFile(backupPath string) error {
-	return os.Rename(backupPath, "config.yaml")
+	return os.Rename(backupPath, pm.currentConfigPath())
 }
 
 func (pm *ProxyManager) scanFolderForGGUF(folderPath string, recursive bool) ([]gin.H, error) {
@@ -1975,8 +2205,7 @@ func (pm *ProxyManager) generateModelConfig(modelID, name, description, filePath
 
 	if auto {
 		// Use autosetup to generate optimal configuration
-		system := autosetup.DetectSystem()
-		err := autosetup.EnhanceSystemInfo(&system)
+		system, err := pm.detectSystem()
 		if err != nil {
 			pm.proxyLogger.Warnf("Failed to enhance system info for auto config: %v", err)
 		}
@@ -1992,27 +2221,25 @@ func (pm *ProxyManager) generateModelConfig(modelID, name, description, filePath
 	return config, nil
 }
 
-// generateSmartModelConfig generates a configuration using the SAME logic as command-line autosetup
-func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, options autosetup.SetupOptions) (gin.H, error) {
+// generateSmartModelConfig generates a configuration using the SAME logic as command-line autosetup.
+// The macros the model cmd refers to are returned separately, they are only
+// needed to start the model outside of the existing config.
+func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, options autosetup.SetupOptions) (gin.H, map[string]interface{}, error) {
 	// Detect system like command-line does
-	system := autosetup.DetectSystem()
-	err := autosetup.EnhanceSystemInfo(&system)
+	system, err := pm.detectSystem()
 	if err != nil {
 		pm.proxyLogger.Warnf("Failed to enhance system info: %v", err)
 	}
 
 	// Use existing binary or download (like command-line uses)
-	binaryPath := filepath.Join("binaries", "llama-server", "build", "bin", "llama-server")
-	if runtime.GOOS == "windows" {
-		binaryPath += ".exe"
-	}
+	binaryPath := managedServerPath()
 	binaryType := "cuda" // Default assumption
 
 	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
 		// Try to download if not exists (same as command-line)
 		binary, err := autosetup.DownloadBinary("binaries", system, options.ForceBackend)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find or download binary: %v", err)
+			return nil, nil, fmt.Errorf("failed to find or download binary: %v", err)
 		}
 		binaryPath = binary.Path
 		binaryType = binary.Type
@@ -2029,13 +2256,13 @@ func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, opti
 	tempModels := []autosetup.ModelInfo{model}
 	err = generator.GenerateConfig(tempModels)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate smart config: %v", err)
+		return nil, nil, fmt.Errorf("failed to generate smart config: %v", err)
 	}
 
 	// Read the generated config to extract the model configuration
 	configData, err := os.ReadFile(tempConfigPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read generated config: %v", err)
+		return nil, nil, fmt.Errorf("failed to read generated config: %v", err)
 	}
 
 	// Clean up temp file
@@ -2045,13 +2272,13 @@ func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, opti
 	var yamlConfig map[string]interface{}
 	err = yaml.Unmarshal(configData, &yamlConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse generated config: %v", err)
+		return nil, nil, fmt.Errorf("failed to parse generated config: %v", err)
 	}
 
 	// Extract the model configuration from the YAML
 	models, ok := yamlConfig["models"].(map[string]interface{})
 	if !ok || len(models) == 0 {
-		return nil, fmt.Errorf("no models found in generated config")
+		return nil, nil, fmt.Errorf("no models found in generated config")
 	}
 
 	// Get the first (and only) model configuration
@@ -2061,6 +2288,8 @@ func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, opti
 		break
 	}
 
+	macros, _ := yamlConfig["macros"].(map[string]interface{})
+
 	return gin.H{
 		"config": modelConfig,
 		"source": "SMART autosetup (same as command-line)",
@@ -2070,7 +2299,7 @@ func (pm *ProxyManager) generateSmartModelConfig(model autosetup.ModelInfo, opti
 			"backend": binaryType,
 			"binary":  binaryPath,
 		},
-	}, nil
+	}, macros, nil
 }
 
 // apiGenerateAllModels generates complete configuration using SAME logic as command-line
@@ -2098,9 +2327,13 @@ func (pm *ProxyManager) apiGenerateAllModels(c *gin.Context) {
 		return
 	}
 
-	// Reset progress at the start
+	// only one generation writes config.yaml at a time, this also resets the progress
+	jobID, ok := pm.beginGeneration(c, "generate-all")
+	if !ok {
+		return
+	}
 	progressMgr := autosetup.GetProgressManager()
-	progressMgr.Reset()
+	defer progressMgr.EndGeneration(jobID)
 
 	// Use SAME options as command-line, but with user-selected overrides
 	options := autosetup.SetupOptions{
@@ -2111,6 +2344,8 @@ func (pm *ProxyManager) apiGenerateAllModels(c *gin.Context) {
 		ForceBackend:     req.Options.ForceBackend, // Use user-selected backend
 		ForceVRAM:        req.Options.ForceVRAM,    // Use user-selected VRAM
 		ForceRAM:         req.Options.ForceRAM,     // Use user-selected RAM
+		MaxModels:        pm.config.ModelLimits.Max,
+		ConfigPath:       pm.currentConfigPath(),
 	}
 
 	if options.MinContext == 0 {
@@ -2129,9 +2364,9 @@ func (pm *ProxyManager) apiGenerateAllModels(c *gin.Context) {
 	}
 
 	// Read the generated config.yaml file
-	configData, err := os.ReadFile("config.yaml")
+	configData, err := os.ReadFile(options.ConfigPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read generated config.yaml"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read generated " + options.ConfigPath})
 		return
 	}
 
@@ -2224,6 +2459,94 @@ func (pm *ProxyManager) optimizeConfigForModel(config gin.H, fileSize int64, sys
 	return config
 }
 
+// kvCacheContextSizes are the context sizes reported by apiGetKVCacheInfo
+var kvCacheContextSizes = []int{4096, 8192, 16384, 32768, 65536, 131072}
+
+// apiGetKVCacheInfo returns the KV cache and total VRAM needed by a model for a
+// range of context sizes. Available VRAM is detected unless given via ?vram=<GB>
+func (pm *ProxyManager) apiGetKVCacheInfo(c *gin.Context) {
+	modelID := c.Param("id")
+
+	realModelName, found := pm.config.RealModelName(modelID)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model %s not found", modelID)})
+		return
+	}
+
+	modelPath := pm.resolveModelPath(realModelName)
+	if modelPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("could not find a model file for %s", realModelName)})
+		return
+	}
+
+	estimator := autosetup.NewMemoryEstimator()
+	memInfo, err := estimator.GetModelMemoryInfo(modelPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read model memory info: %v", err)})
+		return
+	}
+	estimator.AddLoRAAdapters(memInfo, pm.config.Models[realModelName].enabledLoRAPaths())
+
+	metadata, err := autosetup.ReadGGUFMetadata(modelPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read model metadata: %v", err)})
+		return
+	}
+
+	availableVRAM, vramSource, ok := pm.requestedVRAM(c, estimator)
+	if !ok {
+		return
+	}
+
+	contextSizes := []gin.H{}
+	for _, ctx := range kvCacheContextSizes {
+		if metadata.ContextLength > 0 && uint32(ctx) > metadata.ContextLength {
+			continue
+		}
+
+		result := estimator.CalculateMemoryForContext(memInfo, ctx, metadata.BlockCount)
+		contextSizes = append(contextSizes, gin.H{
+			"contextSize":   ctx,
+			"kvCacheGB":     result.KVCacheGB,
+			"totalVRAMGB":   result.TotalMemoryGB,
+			"fitsAvailable": result.TotalMemoryGB <= availableVRAM,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":            realModelName,
+		"modelPath":        modelPath,
+		"modelSizeGB":      memInfo.ModelSizeGB,
+		"loraSizeGB":       memInfo.LoRASizeGB,
+		"maxContextLength": metadata.ContextLength,
+		"blockCount":       metadata.BlockCount,
+		"overheadGB":       estimator.OverheadGB,
+		"availableVRAMGB":  availableVRAM,
+		"vramSource":       vramSource,
+		"contextSizes":     contextSizes,
+	})
+}
+
+// requestedVRAM returns the VRAM to size a model against in GB and where it came
+// from, ?vram=<GB> or detected. It sends a 400 and returns false for a bad ?vram.
+func (pm *ProxyManager) requestedVRAM(c *gin.Context, estimator *autosetup.MemoryEstimator) (float64, string, bool) {
+	if vramParam := c.Query("vram"); vramParam != "" {
+		availableVRAM, err := strconv.ParseFloat(vramParam, 64)
+		if err != nil || availableVRAM < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vram must be a positive number of GB"})
+			return 0, "", false
+		}
+		return availableVRAM, "query", true
+	}
+
+	availableVRAM, err := estimator.GetAvailableVRAM()
+	if err != nil {
+		pm.proxyLogger.Debugf("VRAM detection failed: %v", err)
+		return 0, "unavailable", true
+	}
+	return availableVRAM, "detected", true
+}
+
 // apiUpdateModelParams performs selective updates to model parameters in YAML without destroying structure
 func (pm *ProxyManager) apiUpdateModelParams(c *gin.Context) {
 	modelID := c.Param("id")
@@ -2241,14 +2564,15 @@ func (pm *ProxyManager) apiUpdateModelParams(c *gin.Context) {
 	}
 
 	// Backup current config
-	backupPath := "config.yaml.backup." + strconv.FormatInt(time.Now().Unix(), 10)
+	configPath := pm.currentConfigPath()
+	backupPath := configPath + ".backup." + strconv.FormatInt(time.Now().Unix(), 10)
 	if err := pm.backupConfigFile(backupPath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to backup config: " + err.Error()})
 		return
 	}
 
 	// Read current YAML file
-	configBytes, err := os.ReadFile("config.yaml")
+	configBytes, err := os.ReadFile(configPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read config file: " + err.Error()})
 		return
@@ -2289,7 +2613,7 @@ func (pm *ProxyManager) apiUpdateModelParams(c *gin.Context) {
 		return
 	}
 
-	if err := os.WriteFile("config.yaml", updatedBytes, 0644); err != nil {
+	if err := os.WriteFile(configPath, updatedBytes, 0644); err != nil {
 		// Restore backup if write fails
 		if backupErr := pm.restoreConfigFile(backupPath); backupErr != nil {
 			pm.proxyLogger.Errorf("Failed to restore config backup: %v", backupErr)
@@ -2299,7 +2623,7 @@ func (pm *ProxyManager) apiUpdateModelParams(c *gin.Context) {
 	}
 
 	// Validate the updated config
-	if _, err := LoadConfig("config.yaml"); err != nil {
+	if _, err := LoadConfig(configPath); err != nil {
 		// Restore backup if validation fails
 		if backupErr := pm.restoreConfigFile(backupPath); backupErr != nil {
 			pm.proxyLogger.Errorf("Failed to restore config backup: %v", backupErr)
@@ -2466,13 +2790,9 @@ func (pm *ProxyManager) findModelByFilePath(filePath string) string {
 				line = strings.TrimSpace(line)
 				if strings.HasPrefix(line, "--model ") {
 					existingPath := strings.TrimSpace(strings.TrimPrefix(line, "--model "))
-					// Compare absolute paths
-					if absExistingPath, err := filepath.Abs(existingPath); err == nil {
-						if absFilePath, err := filepath.Abs(filePath); err == nil {
-							if absExistingPath == absFilePath {
-								return modelID
-							}
-						}
+					// a symlink or hardlink to the file is the same model
+					if sameModelFile(existingPath, filePath) {
+						return modelID
 					}
 				}
 			}
@@ -2501,7 +2821,7 @@ func (pm *ProxyManager) cleanupDuplicateModels(configPath string) (int, error) {
 	}
 
 	// Track file paths and find duplicates
-	filePathToModels := make(map[string][]string)
+	modelPaths := make(map[string]string)
 
 	for modelID, modelConfigInterface := range models {
 		modelConfig, ok := modelConfigInterface.(map[string]interface{})
@@ -2519,10 +2839,7 @@ func (pm *ProxyManager) cleanupDuplicateModels(configPath string) (int, error) {
 		for _, line := range lines {
 			line = strings.TrimSpace(line)
 			if strings.HasPrefix(line, "--model ") {
-				modelPath := strings.TrimSpace(strings.TrimPrefix(line, "--model "))
-				if absPath, err := filepath.Abs(modelPath); err == nil {
-					filePathToModels[absPath] = append(filePathToModels[absPath], modelID)
-				}
+				modelPaths[modelID] = strings.TrimSpace(strings.TrimPrefix(line, "--model "))
 				break
 			}
 		}
@@ -2531,7 +2848,7 @@ func (pm *ProxyManager) cleanupDuplicateModels(configPath string) (int, error) {
 	// Find and remove duplicates (keep the first one, remove others)
 	var removedModels []string
 
-	for _, modelIDs := range filePathToModels {
+	for _, modelIDs := range modelsBySameFile(modelPaths) {
 		if len(modelIDs) > 1 {
 			// Keep the first model, remove the rest
 			for i := 1; i < len(modelIDs); i++ {
@@ -2643,6 +2960,13 @@ func (pm *ProxyManager) appendModelToConfig(configPath, modelID string, modelCon
 		}
 	}
 
+	// a new entry must stay within the model limit, updates are fine
+	if _, exists := models[modelID]; !exists {
+		if err := checkModelLimit(pm.config.ModelLimits, len(models), 1); err != nil {
+			return err
+		}
+	}
+
 	// Ensure model config has TTL (Time To Live) - default 300 seconds
 	if _, hasTTL := modelConfig["ttl"]; !hasTTL {
 		modelConfig["ttl"] = 300
@@ -2858,13 +3182,19 @@ func (pm *ProxyManager) validateAndCleanupConfig(configPath string) ([]string, e
 	return removedModels, nil
 }
 
-// extractModelPathFromCmd extracts the model path from --model parameter in cmd string
+// extractModelPathFromCmd extracts the model path from the --model, -m or
+// --model= argument in cmd string, whether or not the file exists
 func (pm *ProxyManager) extractModelPathFromCmd(cmd string) string {
-	lines := strings.Split(cmd, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "--model ") {
-			return strings.TrimSpace(strings.TrimPrefix(line, "--model "))
+	args, err := SanitizeCommand(cmd)
+	if err != nil {
+		return ""
+	}
+
+	for i, arg := range args {
+		if (arg == "--model" || arg == "-m") && i+1 < len(args) {
+			return args[i+1]
+		} else if strings.HasPrefix(arg, "--model=") {
+			return strings.TrimPrefix(arg, "--model=")
 		}
 	}
 	return ""
@@ -2907,16 +3237,7 @@ func (pm *ProxyManager) addModelToConfig(configPath, modelID string, modelConfig
 		config["startPort"] = 8100
 	}
 	if config["macros"] == nil {
-		// Build cross-platform binary path
-		binaryPath := filepath.Join("binaries", "llama-server", "build", "bin", "llama-server")
-		if runtime.GOOS == "windows" {
-			binaryPath += ".exe"
-		}
-
-		config["macros"] = map[string]interface{}{
-			"llama-embed-base":  fmt.Sprintf("%s --host 127.0.0.1 --port ${PORT} --embedding", binaryPath),
-			"llama-server-base": fmt.Sprintf("%s --host 127.0.0.1 --port ${PORT} --metrics --flash-attn auto --no-warmup --dry-penalty-last-n 0 --batch-size 2048 --ubatch-size 512", binaryPath),
-		}
+		config["macros"] = DefaultMacros()
 	}
 
 	// Ensure models section exists
@@ -2998,7 +3319,8 @@ func (pm *ProxyManager) apiRestartServer(c *gin.Context) {
 		"status":  "restarting",
 	})
 
-	// Perform restart in background
+	// Perform restart in background, reloading the active profile's config
+	configPath := pm.currentConfigPath()
 	go func() {
 		time.Sleep(100 * time.Millisecond)
 		pm.proxyLogger.Info("Initiating soft restart...")
@@ -3015,7 +3337,7 @@ func (pm *ProxyManager) apiRestartServer(c *gin.Context) {
 
 		// Reload configuration
 		pm.proxyLogger.Info("Reloading configuration...")
-		newConfig, err := LoadConfig("config.yaml")
+		newConfig, err := LoadConfig(configPath)
 		if err != nil {
 			pm.proxyLogger.Errorf("Failed to reload config: %v", err)
 			return
@@ -3283,9 +3605,9 @@ func (pm *ProxyManager) countModelsInFolder(folderPath string) int {
 }
 
 func (pm *ProxyManager) apiCleanupDuplicateModels(c *gin.Context) {
-	configPath := "config.yaml"
+	configPath := pm.currentConfigPath()
 	if !pm.fileExists(configPath) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "config.yaml not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": configPath + " not found"})
 		return
 	}
 
@@ -3310,7 +3632,7 @@ func (pm *ProxyManager) apiCleanupDuplicateModels(c *gin.Context) {
 	}
 
 	// Track file paths and find duplicates
-	filePathToModels := make(map[string][]string)
+	modelPaths := make(map[string]string)
 
 	for modelID, modelConfigInterface := range models {
 		modelConfig, ok := modelConfigInterface.(map[string]interface{})
@@ -3328,10 +3650,7 @@ func (pm *ProxyManager) apiCleanupDuplicateModels(c *gin.Context) {
 		for _, line := range lines {
 			line = strings.TrimSpace(line)
 			if strings.HasPrefix(line, "--model ") {
-				modelPath := strings.TrimSpace(strings.TrimPrefix(line, "--model "))
-				if absPath, err := filepath.Abs(modelPath); err == nil {
-					filePathToModels[absPath] = append(filePathToModels[absPath], modelID)
-				}
+				modelPaths[modelID] = strings.TrimSpace(strings.TrimPrefix(line, "--model "))
 				break
 			}
 		}
@@ -3341,7 +3660,7 @@ func (pm *ProxyManager) apiCleanupDuplicateModels(c *gin.Context) {
 	var removedModels []string
 	var keptModels []string
 
-	for _, modelIDs := range filePathToModels {
+	for _, modelIDs := range modelsBySameFile(modelPaths) {
 		if len(modelIDs) > 1 {
 			// Keep the first model, remove the rest
 			keptModels = append(keptModels, modelIDs[0])
@@ -3424,6 +3743,12 @@ func (pm *ProxyManager) apiRegenerateConfigFromDatabase(c *gin.Context) {
 		}
 	}
 
+	jobID, ok := pm.beginGeneration(c, "regenerate-from-database")
+	if !ok {
+		return
+	}
+	defer autosetup.GetProgressManager().EndGeneration(jobID)
+
 	// Load folder database
 	db, err := pm.loadModelFolderDatabase()
 	if err != nil {
@@ -3517,6 +3842,9 @@ func (pm *ProxyManager) apiRegenerateConfigFromDatabase(c *gin.Context) {
 	// This ensures identical behavior between UI and CLI
 
 	// Use multi-folder autosetup for proper handling of all tracked folders
+	// the config of the active profile is regenerated
+	configPath := pm.currentConfigPath()
+	req.Options.ConfigPath = configPath
 	err = autosetup.AutoSetupMultiFoldersWithOptions(folderPaths, req.Options)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("AutoSetup failed (same as CLI): %v", err)})
@@ -3532,8 +3860,8 @@ func (pm *ProxyManager) apiRegenerateConfigFromDatabase(c *gin.Context) {
 		PercentageComplete: 100,
 	})
 
-	// Read the generated config (AutoSetupWithOptions always writes to config.yaml)
-	configData, err := os.ReadFile("config.yaml")
+	// Read the generated config
+	configData, err := os.ReadFile(configPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read generated config.yaml"})
 		return
@@ -3557,7 +3885,7 @@ func (pm *ProxyManager) apiRegenerateConfigFromDatabase(c *gin.Context) {
 			group.StopProcesses(StopWaitForInflightRequest)
 		}
 		// Reload config
-		if newConfig, err := LoadConfig("config.yaml"); err == nil {
+		if newConfig, err := LoadConfig(configPath); err == nil {
 			pm.config = newConfig
 			// Recreate process groups
 			pm.processGroups = make(map[string]*ProcessGroup)
@@ -3625,12 +3953,20 @@ func (pm *ProxyManager) apiGetBinaryStatus(c *gin.Context) {
 		latestVersion = "unknown"
 	}
 
-	// Get optimal binary type for system
-	_, optimalType, err := autosetup.GetOptimalBinaryURL(system, "", latestVersion)
+	// Get optimal binary type for system, what POST /api/binary/optimize installs
+	optimalType, err := autosetup.OptimalBinaryType(system, latestVersion)
 	if err != nil {
 		optimalType = "unknown"
 	}
 
+	// models asking for GPU offload from a CPU build, see binary_mismatch.go
+	pm.Lock()
+	mismatches := binaryMismatchWarnings(metadata, pm.config)
+	pm.Unlock()
+	if mismatches == nil {
+		mismatches = []string{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"exists":          true,
 		"path":            serverPath,
@@ -3642,6 +3978,7 @@ func (pm *ProxyManager) apiGetBinaryStatus(c *gin.Context) {
 		"isOptimal":       metadata.Type == optimalType,
 		"isUpToDate":      metadata.Version == latestVersion,
 		"updateAvailable": metadata.Version != latestVersion,
+		"gpuMismatches":   mismatches,
 	})
 }
 
@@ -3695,6 +4032,7 @@ func (pm *ProxyManager) apiUpdateBinary(c *gin.Context) {
 	}
 
 	pm.proxyLogger.Infof("Successfully updated binary to version %s (%s)", binary.Version, binary.Type)
+	event.Emit(BinaryUpdatedEvent{Version: binary.Version, BinaryType: binary.Type})
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "updated",
@@ -3712,7 +4050,6 @@ func (pm *ProxyManager) apiForceUpdateBinary(c *gin.Context) {
 	pm.apiUpdateBinary(c)
 }
 
-
 // apiGetActivityStats returns persistent activity statistics
 func (pm *ProxyManager) apiGetActivityStats(c *gin.Context) {
 	// Get model-specific stats if requested
@@ -3720,7 +4057,7 @@ func (pm *ProxyManager) apiGetActivityStats(c *gin.Context) {
 
 	if pm.metricsMonitor == nil || pm.metricsMonitor.ActivityStats == nil {
 		c.JSON(http.StatusOK, gin.H{
-			"stats": make(map[string]interface{}),
+			"stats":  make(map[string]interface{}),
 			"global": nil,
 		})
 		return
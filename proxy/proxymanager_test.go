@@ -3,18 +3,28 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
 	"github.com/prave/FrogLLM/event"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
@@ -816,6 +826,81 @@ func TestProxyManager_MiddlewareWritesMetrics_Streaming(t *testing.T) {
 	assert.Greater(t, lastMetric.DurationMs, 0, "duration should be greater than 0")
 }
 
+func TestMetricsMonitor_ConcurrentRecording(t *testing.T) {
+	monitor := NewMetricsMonitor(&Config{MetricsMaxInMemory: 500}, "")
+	monitor.ActivityStats = NewActivityStatsManager(filepath.Join(t.TempDir(), "activity_stats.json"))
+
+	var batch metricsBatch
+	defer event.On(func(e TokenMetricsEvent) {
+		batch.add(e.Metrics)
+	})()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				monitor.addMetrics(TokenMetrics{Model: "model1", InputTokens: 1, OutputTokens: 1})
+				monitor.GetMetrics()
+			}
+		}()
+	}
+	wg.Wait()
+
+	metrics := monitor.GetMetrics()
+	assert.Len(t, metrics, 500)
+	assert.Equal(t, 999, metrics[len(metrics)-1].ID)
+	stats, found := monitor.ActivityStats.GetModelStats("model1")
+	if assert.True(t, found) {
+		assert.Equal(t, int64(1000), stats.RequestCount)
+	}
+
+	// a batch holds the latest metricsBatchLimit metrics, ordered by ID
+	eventually := assert.Eventually(t, func() bool {
+		batch.mu.Lock()
+		defer batch.mu.Unlock()
+		return len(batch.pending) == metricsBatchLimit
+	}, time.Second, 10*time.Millisecond)
+	if eventually {
+		taken := batch.take()
+		for i := 1; i < len(taken); i++ {
+			assert.Less(t, taken[i-1].ID, taken[i].ID)
+		}
+		assert.Empty(t, batch.take())
+	}
+}
+
+/*
+go test -race -run=^$ -bench=BenchmarkMetricsMonitor_Concurrent ./proxy
+records metrics from parallel requests while others read them, like the UI
+and the event stream do
+*/
+func BenchmarkMetricsMonitor_Concurrent(b *testing.B) {
+	monitor := NewMetricsMonitor(&Config{MetricsMaxInMemory: 1000}, "")
+	monitor.ActivityStats = NewActivityStatsManager(filepath.Join(b.TempDir(), "activity_stats.json"))
+	var batch metricsBatch
+	defer event.On(func(e TokenMetricsEvent) {
+		batch.add(e.Metrics)
+	})()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			monitor.addMetrics(TokenMetrics{Model: "model1", InputTokens: 10, OutputTokens: 20, DurationMs: 5})
+			switch i % 10 {
+			case 0:
+				monitor.GetMetricsJSON()
+			case 5:
+				batch.take()
+			}
+			i++
+		}
+	})
+}
+
 func TestProxyManager_HealthEndpoint(t *testing.T) {
 	config := AddDefaultGroupToConfig(Config{
 		HealthCheckTimeout: 15,
@@ -1030,3 +1115,4157 @@ func TestProxyManager_InfoEndpoint(t *testing.T) {
 	assert.Equal(t, "Test Model 1", model1["name"])
 	assert.Equal(t, "A test model", model1["description"])
 }
+
+func TestProxyManager_ForceSystemPrompt_Prepend(t *testing.T) {
+	reqBody := `{"model":"model1","messages":[{"role":"system","content":"client prompt"},{"role":"user","content":"hello"}]}`
+	result, err := applyForcedSystemPrompt([]byte(reqBody), "forced prompt", SystemPromptModePrepend)
+	assert.NoError(t, err)
+
+	messages := gjson.GetBytes(result, "messages").Array()
+	if assert.Len(t, messages, 3) {
+		assert.Equal(t, "system", messages[0].Get("role").String())
+		assert.Equal(t, "forced prompt", messages[0].Get("content").String())
+		assert.Equal(t, "client prompt", messages[1].Get("content").String())
+		assert.Equal(t, "hello", messages[2].Get("content").String())
+	}
+	assert.Equal(t, "model1", gjson.GetBytes(result, "model").String())
+}
+
+func TestProxyManager_ForceSystemPrompt_Override(t *testing.T) {
+	reqBody := `{"model":"model1","messages":[{"role":"system","content":"client prompt"},{"role":"user","content":"hello"}]}`
+	result, err := applyForcedSystemPrompt([]byte(reqBody), "forced prompt", SystemPromptModeOverride)
+	assert.NoError(t, err)
+
+	messages := gjson.GetBytes(result, "messages").Array()
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "system", messages[0].Get("role").String())
+		assert.Equal(t, "forced prompt", messages[0].Get("content").String())
+		assert.Equal(t, "user", messages[1].Get("role").String())
+	}
+}
+
+func TestProxyManager_ForceSystemPrompt_NoMessages(t *testing.T) {
+	reqBody := `{"model":"model1","prompt":"hello"}`
+	result, err := applyForcedSystemPrompt([]byte(reqBody), "forced prompt", SystemPromptModeOverride)
+	assert.NoError(t, err)
+	assert.Equal(t, reqBody, string(result))
+}
+
+func TestProxyManager_KVCacheInfo(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "test-model.gguf")
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"general.name":                  "Test Model",
+		"llama.block_count":             uint32(32),
+		"llama.context_length":          uint32(32768),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Cmd = fmt.Sprintf("%s --model %s", modelConfig.Cmd, modelPath)
+	modelConfig.Aliases = []string{"m1"}
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": modelConfig,
+		},
+		Aliases: map[string]string{"m1": "model1"},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	req := httptest.NewRequest("GET", "/api/models/m1/kv-cache-info?vram=4", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+
+	body := w.Body.Bytes()
+	assert.Equal(t, "model1", gjson.GetBytes(body, "model").String())
+	assert.Equal(t, "query", gjson.GetBytes(body, "vramSource").String())
+
+	// context sizes above the model's 32768 maximum are skipped
+	sizes := gjson.GetBytes(body, "contextSizes").Array()
+	if assert.Len(t, sizes, 4) {
+		// 4096 tokens * 32 layers * 8 kv heads * (128+128) * 2 bytes = 0.5 GiB
+		assert.Equal(t, int64(4096), sizes[0].Get("contextSize").Int())
+		assert.InDelta(t, 0.5, sizes[0].Get("kvCacheGB").Float(), 0.0001)
+		assert.True(t, sizes[0].Get("fitsAvailable").Bool())
+
+		assert.Equal(t, int64(32768), sizes[3].Get("contextSize").Int())
+		assert.InDelta(t, 4.0, sizes[3].Get("kvCacheGB").Float(), 0.0001)
+		assert.False(t, sizes[3].Get("fitsAvailable").Bool())
+	}
+
+	req = httptest.NewRequest("GET", "/api/models/unknown/kv-cache-info", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestProxyManager_ModelCapacity(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "test-model.gguf")
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"general.name":                  "Test Model",
+		"llama.block_count":             uint32(32),
+		"llama.context_length":          uint32(32768),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Cmd = fmt.Sprintf("%s --model %s --ctx-size 16384 -np 4", modelConfig.Cmd, modelPath)
+	defaultsConfig := getTestSimpleResponderConfig("model2")
+	defaultsConfig.Cmd = fmt.Sprintf("%s --model %s", defaultsConfig.Cmd, modelPath)
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": modelConfig,
+			"model2": defaultsConfig,
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	w := get("/api/models/model1/capacity?vram=6.1")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		body := w.Body.Bytes()
+		assert.Equal(t, int64(16384), gjson.GetBytes(body, "contextSize").Int())
+		assert.Equal(t, int64(4), gjson.GetBytes(body, "parallel").Int())
+		assert.Equal(t, int64(4096), gjson.GetBytes(body, "perSequenceContext").Int())
+		// 4096 tokens * 32 layers * 8 kv heads * (128+128) * 2 bytes = 0.5 GiB per slot
+		assert.InDelta(t, 0.5, gjson.GetBytes(body, "kvCachePerSequenceGB").Float(), 0.0001)
+		assert.InDelta(t, 2.0, gjson.GetBytes(body, "kvCacheGB").Float(), 0.0001)
+		assert.True(t, gjson.GetBytes(body, "fitsAvailable").Bool())
+		// 6.1GB - 2GB overhead - a tiny model leaves room for 8 slots of 0.5GB
+		assert.Equal(t, int64(8), gjson.GetBytes(body, "maxConcurrentSequences").Int())
+	}
+
+	// llama-server defaults without --ctx-size and --parallel
+	w = get("/api/models/model2/capacity?vram=3")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		body := w.Body.Bytes()
+		assert.Equal(t, int64(4096), gjson.GetBytes(body, "contextSize").Int())
+		assert.Equal(t, int64(1), gjson.GetBytes(body, "parallel").Int())
+		assert.Equal(t, int64(1), gjson.GetBytes(body, "maxConcurrentSequences").Int())
+	}
+
+	// not even the weights fit
+	w = get("/api/models/model1/capacity?vram=1")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.Equal(t, int64(0), gjson.GetBytes(w.Body.Bytes(), "maxConcurrentSequences").Int())
+		assert.False(t, gjson.GetBytes(w.Body.Bytes(), "fitsAvailable").Bool())
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/models/model1/capacity?vram=lots").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/models/unknown/capacity").Code)
+}
+
+func TestProxyManager_SlotMemoryCheck(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "test-model.gguf")
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"llama.block_count":             uint32(32),
+		"llama.context_length":          uint32(32768),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Cmd = fmt.Sprintf("%s --model %s --ctx-size 16384 -np 4", modelConfig.Cmd, modelPath)
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		SlotMemoryCheck:    SlotMemoryCheckReduce,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	freeGB := 3.0
+	proxy.measureVRAM = func() (float64, float64, error) { return freeGB, 24, nil }
+	group := proxy.findGroupByModelName("model1")
+	process := proxy.modelProcess("model1")
+
+	// 4 slots of 4096 tokens need 0.5GB of KV cache each, with 2GB overhead
+	// only one fits in 3GB and keeps its context
+	proxy.checkSlotMemory(group, "model1")
+	cmd := process.launchCmd()
+	assert.Contains(t, cmd, "--ctx-size 4096")
+	assert.Contains(t, cmd, "-np 1")
+	assert.NotContains(t, modelConfig.Cmd, "-np 1")
+
+	// a single slot that does not fit gets a smaller context
+	process.setNextLaunchCmd("")
+	freeGB = 2.3
+	proxy.checkSlotMemory(group, "model1")
+	cmd = process.launchCmd()
+	assert.Contains(t, cmd, "--ctx-size 2304")
+	assert.Contains(t, cmd, "-np 1")
+
+	// nothing to do when all slots fit
+	process.setNextLaunchCmd("")
+	freeGB = 8
+	proxy.checkSlotMemory(group, "model1")
+	assert.Equal(t, process.config.Cmd, process.launchCmd())
+
+	// warn only logs
+	process.setNextLaunchCmd("")
+	freeGB = 3
+	proxy.config.SlotMemoryCheck = SlotMemoryCheckWarn
+	proxy.checkSlotMemory(group, "model1")
+	assert.Equal(t, process.config.Cmd, process.launchCmd())
+
+	// the capacity endpoint reports the worst case
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1/capacity?vram=3", nil))
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.InDelta(t, 4.0, gjson.GetBytes(w.Body.Bytes(), "worstCaseVRAMGB").Float(), 0.01)
+		assert.False(t, gjson.GetBytes(w.Body.Bytes(), "slotsFitAvailable").Bool())
+	}
+
+	_, err := LoadConfigFromReader(strings.NewReader("slotMemoryCheck: shrink\n"))
+	assert.ErrorContains(t, err, "invalid slotMemoryCheck")
+}
+
+func TestProxyManager_VerifyGeneratedModel(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	generated := gin.H{
+		"config": map[string]interface{}{
+			"cmd":   "${llama-server-base}\n--model /models/test.gguf\n",
+			"proxy": "http://127.0.0.1:${PORT}",
+			"ttl":   300,
+		},
+	}
+	macros := map[string]interface{}{
+		"llama-server-base": "/path/to/llama-server --port ${PORT}",
+	}
+
+	t.Run("successful verification", func(t *testing.T) {
+		var verifiedConfig ModelConfig
+		proxy.modelVerifier = func(modelID string, modelConfig ModelConfig) error {
+			verifiedConfig = modelConfig
+			return nil
+		}
+
+		result := proxy.verifyGeneratedModel("test-model", generated, macros)
+		assert.Equal(t, true, result["verified"])
+		assert.Nil(t, result["error"])
+
+		// macros and ${PORT} are expanded so the model can run standalone
+		assert.Contains(t, verifiedConfig.Cmd, "/path/to/llama-server --port ")
+		assert.NotContains(t, verifiedConfig.Cmd, "${")
+		assert.NotContains(t, verifiedConfig.Proxy, "${PORT}")
+	})
+
+	t.Run("failed verification", func(t *testing.T) {
+		proxy.modelVerifier = func(modelID string, modelConfig ModelConfig) error {
+			return fmt.Errorf("health check timed out after 15s")
+		}
+
+		result := proxy.verifyGeneratedModel("test-model", generated, macros)
+		assert.Equal(t, false, result["verified"])
+		assert.Equal(t, "health check timed out after 15s", result["error"])
+	})
+}
+
+func TestProxyManager_AppendModelVerifyFailure(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	binaryPath := managedServerPath()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(binaryPath), 0755))
+	assert.NoError(t, os.WriteFile(binaryPath, []byte("#!/bin/sh\n"), 0755))
+
+	modelPath := filepath.Join(dir, "models", "broken-model-Q4_K_M.gguf")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(modelPath), 0755))
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(4096),
+		"llama.block_count":    uint32(2),
+	})
+
+	originalConfig := "models:\n  existing:\n    cmd: llama-server --model /models/existing.gguf\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(originalConfig), 0644))
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	verified := false
+	proxy.modelVerifier = func(modelID string, modelConfig ModelConfig) error {
+		verified = true
+		return fmt.Errorf("health check timed out after 15s")
+	}
+
+	body := fmt.Sprintf(`{"filePath": %q}`, modelPath)
+	req := httptest.NewRequest("POST", "/api/config/append-model?verify=true", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	assert.True(t, verified)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Equal(t, false, gjson.Get(w.Body.String(), "verification.verified").Value())
+	assert.Equal(t, "health check timed out after 15s", gjson.Get(w.Body.String(), "verification.error").String())
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, originalConfig, string(data))
+}
+
+func TestProxyManager_AppendModelWithWarm(t *testing.T) {
+	// the appended model's ${PORT} is the config's startPort, where the upstream listens
+	var warmupPath string
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			warmupPath = r.URL.Path
+		}
+		w.Write([]byte(`{}`))
+	})
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(upstream.URL, "http://"))
+	assert.NoError(t, err)
+
+	dir := useTempFilePaths(t)
+
+	writeFakeLlamaServer(t, managedServerPath())
+
+	modelPath := filepath.Join(dir, "models", "tiny-model-Q4_K_M.gguf")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(modelPath), 0755))
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(4096),
+		"llama.block_count":    uint32(2),
+	})
+
+	originalConfig := fmt.Sprintf("startPort: %s\nmodels:\n  existing:\n    cmd: %s\n    proxy: %s\n", port, fakeUpstreamCmd, upstream.URL)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(originalConfig), 0644))
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	body := fmt.Sprintf(`{"filePath": %q}`, modelPath)
+	req := httptest.NewRequest("POST", "/api/config/append-model?warm=true", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "tiny-model", gjson.Get(w.Body.String(), "modelId").String())
+	assert.True(t, gjson.Get(w.Body.String(), "warm.ready").Bool(), w.Body.String())
+	assert.Equal(t, string(StateReady), gjson.Get(w.Body.String(), "warm.state").String())
+	assert.False(t, gjson.Get(w.Body.String(), "requiresRestart").Bool())
+	assert.Equal(t, "/v1/chat/completions", warmupPath)
+
+	// the model serves requests without a restart
+	req = httptest.NewRequest("GET", "/running", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "tiny-model")
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "llama-server-base")
+}
+
+func TestProxyManager_ReanalyzeModel(t *testing.T) {
+	dir := useTempFilePaths(t)
+	configPath := filepath.Join(dir, "config.yaml")
+
+	writeFakeLlamaServer(t, managedServerPath())
+
+	// re-quantized in place, the file is now a 4K model the config still runs at 128K
+	modelPath := filepath.Join(dir, "models", "tiny-model-Q4_K_M.gguf")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(modelPath), 0755))
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"llama.context_length": uint32(4096),
+		"llama.block_count":    uint32(2),
+	})
+	originalConfig := fmt.Sprintf(`models:
+  # tuned by hand
+  tiny:
+    cmd: %s --port ${PORT} --model %s --ctx-size 131072
+    aliases:
+      - tiny-alias
+    ttl: 300
+`, fakeUpstreamCmd, modelPath)
+	assert.NoError(t, os.WriteFile(configPath, []byte(originalConfig), 0644))
+
+	config, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	req := httptest.NewRequest("POST", "/api/models/tiny-alias/reanalyze", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	body := w.Body.String()
+	assert.Equal(t, "tiny", gjson.Get(body, "model").String())
+	assert.True(t, gjson.Get(body, "changed").Bool())
+	ctxChange := gjson.Get(body, `changes.#(flag=="--ctx-size")`)
+	assert.Equal(t, "changed", ctxChange.Get("change").String(), body)
+	assert.Equal(t, "131072", ctxChange.Get("before").String())
+	newContext := ctxChange.Get("after").Int()
+	assert.True(t, newContext > 0 && newContext < 131072, body)
+	assert.FileExists(t, gjson.Get(body, "backup").String())
+
+	// only the cmd is replaced, the rest of the entry and the comment are kept
+	data, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "# tuned by hand")
+	assert.Contains(t, string(data), "tiny-alias")
+	assert.Contains(t, string(data), "ttl: 300")
+	assert.Contains(t, string(data), "llama-server-base:")
+	updated, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.Contains(t, updated.Models["tiny"].Cmd, fmt.Sprintf("--ctx-size %d", newContext))
+
+	// nothing changed on disk since, a second run has nothing to update
+	config, err = LoadConfig(configPath)
+	assert.NoError(t, err)
+	proxy.Lock()
+	proxy.config = config
+	proxy.Unlock()
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/tiny/reanalyze", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, gjson.Get(w.Body.String(), "changed").Bool(), w.Body.String())
+}
+
+func TestProxyManager_EmbeddingDimensions(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "embed-model.gguf")
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture":  "bert",
+		"bert.embedding_length": uint32(768),
+	})
+
+	embedConfig := getTestSimpleResponderConfig("embed")
+	embedConfig.Cmd = fmt.Sprintf("%s --model %s --embedding", embedConfig.Cmd, modelPath)
+
+	rerankConfig := getTestSimpleResponderConfig("rerank")
+	rerankConfig.Cmd = fmt.Sprintf("%s --model %s --reranking", rerankConfig.Cmd, modelPath)
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"embed":    embedConfig,
+			"rerank":   rerankConfig,
+			"model1":   getTestSimpleResponderConfig("model1"),
+			"org/chat": getTestSimpleResponderConfig("org/chat"),
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	for _, model := range gjson.GetBytes(w.Body.Bytes(), "data").Array() {
+		switch model.Get("id").String() {
+		case "embed":
+			assert.Equal(t, "embedding", model.Get("type").String())
+			assert.Equal(t, int64(768), model.Get("dimensions").Int())
+			assert.Equal(t, modelPath, model.Get("model_path").String())
+			assert.True(t, model.Get("file_exists").Bool())
+		case "rerank":
+			assert.Equal(t, "reranker", model.Get("type").String())
+			assert.Equal(t, "logit", model.Get("score_range.type").String())
+			assert.False(t, model.Get("score_range.normalized").Bool())
+			assert.False(t, model.Get("score_range.min").Exists())
+		case "model1":
+			assert.False(t, model.Get("dimensions").Exists())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/v1/models/embed", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		assert.Equal(t, int64(768), gjson.GetBytes(w.Body.Bytes(), "dimensions").Int())
+	}
+
+	req = httptest.NewRequest("GET", "/v1/models/unknown", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// IDs with a slash work, and the fixed endpoints next to the route still do
+	req = httptest.NewRequest("GET", "/v1/models/org/chat", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		assert.Equal(t, "org/chat", gjson.GetBytes(w.Body.Bytes(), "id").String())
+	}
+
+	req = httptest.NewRequest("GET", "/v1/models/loaded", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, gjson.GetBytes(w.Body.Bytes(), "id").Exists())
+}
+
+func TestProxyManager_OrphanModels(t *testing.T) {
+	downloadDir := t.TempDir()
+	repoDir := filepath.Join(downloadDir, "owner_repo")
+	assert.NoError(t, os.MkdirAll(repoDir, 0755))
+
+	usedPath := filepath.Join(repoDir, "used-Q4_K_M.gguf")
+	orphanPath := filepath.Join(repoDir, "unused-Q8_0.gguf")
+	shardPaths := []string{
+		filepath.Join(repoDir, "split-00001-of-00002.gguf"),
+		filepath.Join(repoDir, "split-00002-of-00002.gguf"),
+	}
+	for _, path := range append([]string{usedPath, orphanPath}, shardPaths...) {
+		assert.NoError(t, os.WriteFile(path, []byte("GGUF"), 0644))
+	}
+	outsidePath := filepath.Join(t.TempDir(), "outside.gguf")
+	assert.NoError(t, os.WriteFile(outsidePath, []byte("GGUF"), 0644))
+
+	usedConfig := getTestSimpleResponderConfig("used")
+	usedConfig.Cmd = fmt.Sprintf("%s --model %s", usedConfig.Cmd, usedPath)
+	splitConfig := getTestSimpleResponderConfig("split")
+	splitConfig.Cmd = fmt.Sprintf("%s -m %s", splitConfig.Cmd, shardPaths[0])
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		DownloadDir:        downloadDir,
+		Models: map[string]ModelConfig{
+			"used":  usedConfig,
+			"split": splitConfig,
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	req := httptest.NewRequest("GET", "/api/models/orphans", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+
+	// referenced files and all shards of a referenced split model are not orphans
+	orphans := gjson.GetBytes(w.Body.Bytes(), "orphans").Array()
+	if assert.Len(t, orphans, 1) {
+		assert.Equal(t, orphanPath, orphans[0].Get("path").String())
+		assert.Equal(t, int64(4), orphans[0].Get("sizeBytes").Int())
+	}
+
+	t.Run("files referenced by the config file and tracked folders", func(t *testing.T) {
+		trackedDir := t.TempDir()
+		loraPath := filepath.Join(trackedDir, "adapter.gguf")
+		pendingPath := filepath.Join(trackedDir, "pending-Q4_K_M.gguf")
+		trackedOrphanPath := filepath.Join(trackedDir, "tracked-orphan.gguf")
+		for _, path := range []string{loraPath, pendingPath, trackedOrphanPath} {
+			assert.NoError(t, os.WriteFile(path, []byte("GGUF"), 0644))
+		}
+
+		// the config file has a model that is not loaded yet and a lora adapter
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configYAML := fmt.Sprintf(`models:
+  used:
+    cmd: llama-server --port ${PORT} --model %s --lora-scaled %s 0.5
+  pending:
+    cmd: llama-server --port ${PORT} -m %s
+`, usedPath, loraPath, pendingPath)
+		assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+		foldersPath := filepath.Join(t.TempDir(), "model_folders.json")
+		data, _ := json.Marshal(ModelFolderDatabase{
+			Folders: []ModelFolderEntry{{Path: trackedDir, Enabled: true}},
+		})
+		assert.NoError(t, os.WriteFile(foldersPath, data, 0644))
+
+		orphans, err := proxy.findOrphanModels(configPath, foldersPath)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var paths []string
+		for _, orphan := range orphans {
+			paths = append(paths, orphan.Path)
+		}
+		assert.ElementsMatch(t, []string{orphanPath, trackedOrphanPath}, paths)
+	})
+
+	t.Run("delete requires confirmation", func(t *testing.T) {
+		body := fmt.Sprintf(`{"paths":[%q]}`, orphanPath)
+		req := httptest.NewRequest("POST", "/api/models/orphans/delete", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.FileExists(t, orphanPath)
+	})
+
+	t.Run("only orphans in tracked folders are deleted", func(t *testing.T) {
+		body := fmt.Sprintf(`{"paths":[%q,%q,%q],"confirm":true}`, orphanPath, usedPath, outsidePath)
+		req := httptest.NewRequest("POST", "/api/models/orphans/delete", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			return
+		}
+
+		assert.Equal(t, orphanPath, gjson.GetBytes(w.Body.Bytes(), "deleted.0").String())
+		assert.Len(t, gjson.GetBytes(w.Body.Bytes(), "skipped").Array(), 2)
+		assert.NoFileExists(t, orphanPath)
+		assert.FileExists(t, usedPath)
+		assert.FileExists(t, outsidePath)
+	})
+}
+
+func TestProxyManager_HFRepoSize(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	var authHeader string
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		if r.URL.Path != "/api/models/owner/model-GGUF" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "true", r.URL.Query().Get("blobs"))
+		json.NewEncoder(w).Encode(gin.H{
+			"id": "owner/model-GGUF",
+			"siblings": []gin.H{
+				{"rfilename": "README.md", "size": 1000},
+				{"rfilename": "model-Q4_K_M.gguf", "size": 4 * gb},
+				{"rfilename": "model-IQ2_XXS.gguf", "size": gb},
+				{"rfilename": "Q8_0/model-Q8_0-00001-of-00002.gguf", "size": 5 * gb},
+				{"rfilename": "Q8_0/model-Q8_0-00002-of-00002.gguf", "size": 3 * gb},
+				{"rfilename": "mmproj-model-f16.gguf", "size": gb / 2},
+			},
+		})
+	}))
+	defer hf.Close()
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+	})
+	proxy := newTestProxyManager(t, config)
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	req := httptest.NewRequest("GET", "/api/models/hf-size?repo=owner/model-GGUF", nil)
+	req.Header.Set("HF-Token", "hf_test")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, "Bearer hf_test", authHeader)
+
+	body := w.Body.Bytes()
+	assert.Equal(t, int64(13*gb+gb/2), gjson.GetBytes(body, "totalBytes").Int())
+	assert.True(t, gjson.GetBytes(body, "sizesKnown").Bool())
+
+	// sorted by size, shards of a split quantization are summed
+	quants := gjson.GetBytes(body, "quants").Array()
+	if assert.Len(t, quants, 4) {
+		assert.Equal(t, "mmproj", quants[0].Get("quantization").String())
+		assert.Equal(t, "IQ2_XXS", quants[1].Get("quantization").String())
+
+		assert.Equal(t, "Q4_K_M", quants[2].Get("quantization").String())
+		assert.Equal(t, 4.0, quants[2].Get("sizeGB").Float())
+		assert.Equal(t, int64(1), quants[2].Get("files").Int())
+		assert.False(t, quants[2].Get("isSplit").Bool())
+
+		assert.Equal(t, "Q8_0", quants[3].Get("quantization").String())
+		assert.Equal(t, int64(8*gb), quants[3].Get("sizeBytes").Int())
+		assert.Equal(t, int64(2), quants[3].Get("files").Int())
+		assert.True(t, quants[3].Get("isSplit").Bool())
+	}
+
+	req = httptest.NewRequest("GET", "/api/models/hf-size?repo=not-a-repo", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("GET", "/api/models/hf-size?repo=owner/missing", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestProxyManager_ModelVisibility(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `healthCheckTimeout: 15
+logLevel: error
+models:
+  # kept as is by the visibility update
+  visible:
+    cmd: echo visible
+    proxy: http://127.0.0.1:12345
+  hidden:
+    cmd: echo hidden
+    proxy: http://127.0.0.1:12346
+    aliases:
+      - hidden-alias
+`
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	listModels := func() []string {
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var ids []string
+		for _, m := range gjson.Get(w.Body.String(), "data.#.id").Array() {
+			ids = append(ids, m.String())
+		}
+		return ids
+	}
+	setVisibility := func(model, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/models/"+model+"/visibility", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, []string{"hidden", "visible"}, listModels())
+
+	// aliases resolve to the real model
+	w := setVisibility("hidden-alias", `{"unlisted": true}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, "hidden", gjson.Get(w.Body.String(), "model").String())
+	assert.Equal(t, []string{"visible"}, listModels())
+
+	// the unlisted model is still routable
+	modelConfig, realName, found := proxy.config.FindConfig("hidden")
+	assert.True(t, found)
+	assert.Equal(t, "hidden", realName)
+	assert.True(t, modelConfig.Unlisted)
+
+	// the config the proxy was created with is not modified in place
+	assert.False(t, config.Models["hidden"].Unlisted)
+
+	// the file is updated without losing its comments
+	saved, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(saved), "# kept as is by the visibility update")
+	reloaded, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.True(t, reloaded.Models["hidden"].Unlisted)
+	assert.False(t, reloaded.Models["visible"].Unlisted)
+
+	w = setVisibility("hidden", `{"unlisted": false}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"hidden", "visible"}, listModels())
+
+	assert.Equal(t, http.StatusNotFound, setVisibility("unknown", `{"unlisted": true}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setVisibility("visible", `{}`).Code)
+}
+
+func TestProxyManager_ConcurrentConfigEditsKeepEachOther(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := "healthCheckTimeout: 15\nlogLevel: error\nmodels:\n"
+	var modelIDs []string
+	for i := 0; i < 8; i++ {
+		modelID := fmt.Sprintf("model%d", i)
+		modelIDs = append(modelIDs, modelID)
+		configYAML += fmt.Sprintf("  %s:\n    cmd: echo %s\n    proxy: http://127.0.0.1:%d\n", modelID, modelID, 12345+i)
+	}
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	var wg sync.WaitGroup
+	for _, modelID := range modelIDs {
+		wg.Add(1)
+		go func(modelID string) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/api/models/"+modelID+"/visibility", strings.NewReader(`{"unlisted": true}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}(modelID)
+	}
+	wg.Wait()
+
+	updated, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, modelID := range modelIDs {
+		assert.True(t, updated.Models[modelID].Unlisted, modelID)
+	}
+}
+
+func TestProxyManager_ScopedAPIKeys(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	settings := SystemSettings{
+		RequireAPIKey: true,
+		APIKey:        "global-key",
+		APIKeys: []ScopedAPIKey{
+			{Name: "chat-app", Key: "chat-key", Models: []string{"chat-alias"}},
+			{Name: "embedding-app", Key: "embed-key", Groups: []string{"embeddings"}},
+		},
+	}
+	data, err := json.Marshal(settings)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+
+	// the chat model is served by an in process upstream, the cmd only has to keep running
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"chat"}`))
+	})
+	chatConfig := fakeUpstreamModel(upstream)
+	chatConfig.Aliases = []string{"chat-alias"}
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		DownloadDir:        t.TempDir(),
+		Aliases:            map[string]string{"chat-alias": "chat"},
+		Models: map[string]ModelConfig{
+			"chat":   chatConfig,
+			"embed":  getTestSimpleResponderConfig("embed"),
+			"secret": getTestSimpleResponderConfig("secret"),
+		},
+		Groups: map[string]GroupConfig{
+			"embeddings": {Swap: true, Exclusive: false, Members: []string{"embed"}},
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	request := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+	listModels := func(key string) []string {
+		w := request("GET", "/v1/models", key, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var ids []string
+		for _, id := range gjson.Get(w.Body.String(), "data.#.id").Array() {
+			ids = append(ids, id.String())
+		}
+		return ids
+	}
+
+	// single key mode keeps full access
+	assert.Equal(t, []string{"chat", "embed", "secret"}, listModels("global-key"))
+	assert.Equal(t, http.StatusOK, request("GET", "/v1/models/secret", "global-key", "").Code)
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/orphans", "global-key", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/v1/models", "wrong-key", "").Code)
+
+	// scoped keys only see and use their models
+	assert.Equal(t, []string{"chat"}, listModels("chat-key"))
+	assert.Equal(t, []string{"embed"}, listModels("embed-key"))
+	assert.Equal(t, http.StatusOK, request("GET", "/v1/models/chat", "chat-key", "").Code)
+	w := request("POST", "/v1/chat/completions", "chat-key", `{"model":"chat-alias"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "chat")
+	w = request("GET", "/info", "chat-key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []interface{}{"chat"}, gjson.Get(w.Body.String(), "models.#.model").Value())
+	assert.Equal(t, http.StatusForbidden, request("GET", "/v1/models/secret", "chat-key", "").Code)
+	assert.Equal(t, http.StatusForbidden, request("POST", "/v1/chat/completions", "chat-key", `{"model":"secret"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request("POST", "/v1/embeddings", "embed-key", `{"model":"chat-alias"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request("POST", "/v1/models/load", "chat-key", `{"model_id":"embed"}`).Code)
+
+	// auto-unload only stops what a scoped key may use itself
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(apiKeyScopeContextKey, &ScopedAPIKey{Groups: []string{"embeddings"}})
+	assert.True(t, proxy.unloadAllowed(c, "embeddings"))
+	assert.False(t, proxy.unloadAllowed(c, DEFAULT_GROUP_ID))
+	c.Set(apiKeyScopeContextKey, &ScopedAPIKey{Models: []string{"chat"}})
+	assert.False(t, proxy.unloadAllowed(c, DEFAULT_GROUP_ID))
+	c.Set(apiKeyScopeContextKey, &ScopedAPIKey{Models: []string{"chat", "secret"}})
+	assert.True(t, proxy.unloadAllowed(c, DEFAULT_GROUP_ID))
+	assert.Equal(t, http.StatusForbidden, request("GET", "/upstream/secret/health", "chat-key", "").Code)
+
+	// unknown models are not downloaded for scoped keys
+	assert.Equal(t, http.StatusForbidden, request("POST", "/v1/chat/completions", "chat-key", `{"model":"owner/new-model"}`).Code)
+
+	// management endpoints need the global key
+	assert.Equal(t, http.StatusForbidden, request("GET", "/api/models/orphans", "chat-key", "").Code)
+}
+
+func TestProxyManager_ConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	writeConfig := func(path string, models ...string) {
+		content := "logLevel: error\nmodels:\n"
+		for i, model := range models {
+			content += fmt.Sprintf("  %s:\n    cmd: echo %s\n    proxy: http://127.0.0.1:%d\n", model, model, 12000+i)
+		}
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	writeConfig(configPath, "base-model")
+	writeConfig(ProfileConfigPath(configPath, "dev"), "dev-large", "dev-small")
+	writeConfig(ProfileConfigPath(configPath, "prod"), "prod-model")
+
+	assert.Equal(t, filepath.Join(dir, "config.dev.yaml"), ProfileConfigPath(configPath, "dev"))
+	assert.Equal(t, []string{"default", "dev", "prod"}, ListProfiles(configPath))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer func() { proxy.StopProcesses(StopWaitForInflightRequest) }()
+	proxy.SetConfigPath(configPath)
+
+	// frogllm.go reloads with a new ProxyManager for the activated profile
+	activated := make(chan string, 4)
+	defer event.On(func(e ConfigProfileActivatedEvent) {
+		activated <- e.Profile
+	})()
+	reload := func(profile string) {
+		t.Helper()
+		select {
+		case got := <-activated:
+			assert.Equal(t, profile, got)
+		case <-time.After(time.Second):
+			t.Fatalf("profile %s was not activated", profile)
+		}
+		config, err := LoadConfig(proxy.currentConfigPath())
+		if !assert.NoError(t, err) {
+			return
+		}
+		next := newTestProxyManager(t, config)
+		next.SetConfigProfile(configPath, proxy.ActiveProfile())
+		proxy.StopProcesses(StopWaitForInflightRequest)
+		proxy = next
+	}
+
+	listModels := func() []string {
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var ids []string
+		for _, id := range gjson.Get(w.Body.String(), "data.#.id").Array() {
+			ids = append(ids, id.String())
+		}
+		return ids
+	}
+	activate := func(profile string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/config/profile/"+profile+"/activate", nil)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "default", proxy.ActiveProfile())
+	assert.Equal(t, []string{"base-model"}, listModels())
+
+	w := activate("dev")
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, "dev", gjson.Get(w.Body.String(), "active").String())
+	assert.Equal(t, int64(2), gjson.Get(w.Body.String(), "models").Int())
+	reload("dev")
+	assert.Equal(t, []string{"dev-large", "dev-small"}, listModels())
+	assert.Equal(t, ProfileConfigPath(configPath, "dev"), proxy.configPath)
+
+	assert.Equal(t, http.StatusOK, activate("prod").Code)
+	reload("prod")
+	assert.Equal(t, []string{"prod-model"}, listModels())
+
+	req := httptest.NewRequest("GET", "/api/config/profiles", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, "prod", gjson.Get(w.Body.String(), "active").String())
+	assert.Equal(t, []string{"default", "dev", "prod"}, []string{
+		gjson.Get(w.Body.String(), "profiles.0").String(),
+		gjson.Get(w.Body.String(), "profiles.1").String(),
+		gjson.Get(w.Body.String(), "profiles.2").String(),
+	})
+
+	// the config API and a restart use the active profile's config file
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/config", nil))
+	assert.Contains(t, gjson.Get(w.Body.String(), "yaml").String(), "prod-model")
+
+	writeConfig(ProfileConfigPath(configPath, "prod"), "prod-model", "prod-extra")
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/server/restart", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Eventually(t, func() bool {
+		return len(listModels()) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, []string{"prod-extra", "prod-model"}, listModels())
+	assert.Equal(t, "prod", proxy.ActiveProfile())
+
+	// unknown, invalid or broken profiles keep the active one
+	assert.NoError(t, os.WriteFile(ProfileConfigPath(configPath, "broken"), []byte("models: ["), 0644))
+	assert.Equal(t, http.StatusBadRequest, activate("missing").Code)
+	assert.Equal(t, http.StatusBadRequest, activate("..").Code)
+	assert.Equal(t, http.StatusBadRequest, activate("broken").Code)
+	assert.Equal(t, "prod", proxy.ActiveProfile())
+	assert.Empty(t, activated)
+
+	assert.Equal(t, http.StatusOK, activate("default").Code)
+	reload("default")
+	assert.Equal(t, []string{"base-model"}, listModels())
+	assert.Equal(t, configPath, proxy.configPath)
+}
+
+func TestProxyManager_AutoDownloadUsesLocalQuant(t *testing.T) {
+	downloadDir := t.TempDir()
+	repoDir := filepath.Join(downloadDir, "owner_repo")
+	assert.NoError(t, os.MkdirAll(filepath.Join(repoDir, "Q8_0"), 0755))
+	for _, name := range []string{
+		"Q8_0/model-Q8_0.gguf",
+		"model-Q4_K_M.gguf",
+		"mmproj-model-F16.gguf",
+		// split model missing its second shard
+		"model-Q6_K-00001-of-00002.gguf",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(repoDir, name), []byte("GGUF"), 0644))
+	}
+
+	huggingFace := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected HuggingFace request: %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer huggingFace.Close()
+
+	proxy := newTestProxyManager(t, Config{LogLevel: "error", DownloadDir: downloadDir})
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.huggingFaceURL = huggingFace.URL
+
+	best, found := proxy.findBestLocalQuant(repoDir, "")
+	assert.True(t, found)
+	assert.Equal(t, filepath.Join(repoDir, "model-Q4_K_M.gguf"), best)
+
+	best, found = proxy.findBestLocalQuant(repoDir, "q8_0")
+	assert.True(t, found)
+	assert.Equal(t, filepath.Join(repoDir, "Q8_0", "model-Q8_0.gguf"), best)
+
+	// the incomplete split model is not suitable
+	_, found = proxy.findBestLocalQuant(repoDir, "q6_k")
+	assert.False(t, found)
+	assert.NoError(t, os.WriteFile(filepath.Join(repoDir, "model-Q6_K-00002-of-00002.gguf"), []byte("GGUF"), 0644))
+	best, found = proxy.findBestLocalQuant(repoDir, "q6_k")
+	assert.True(t, found)
+	assert.Equal(t, filepath.Join(repoDir, "model-Q6_K-00001-of-00002.gguf"), best)
+
+	// the local quants are used without searching or downloading
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	assert.NoError(t, proxy.autoDownloadModel(c, "owner/repo"))
+	assert.NoError(t, proxy.autoDownloadModel(c, "owner/repo:q8_0"))
+	assert.Empty(t, proxy.downloadManager.GetDownloads())
+
+	// the models added for the requests run the same local quants
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := fmt.Sprintf("logLevel: error\ndownloadDir: %s\nmodels: {}\n", downloadDir)
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+	proxy.SetConfigPath(configPath)
+
+	_, err := proxy.reloadConfigForNewModel("owner/repo", true)
+	assert.NoError(t, err)
+	modelConfig, realName, found := proxy.config.FindConfig("owner/repo")
+	if assert.True(t, found) {
+		assert.Equal(t, "owner-repo", realName)
+		assert.Equal(t, filepath.Join(repoDir, "model-Q4_K_M.gguf"), proxy.extractModelPathFromCmd(modelConfig.Cmd))
+	}
+
+	_, err = proxy.reloadConfigForNewModel("owner/repo:q8_0", true)
+	assert.NoError(t, err)
+	modelConfig, realName, found = proxy.config.FindConfig("owner/repo:q8_0")
+	if assert.True(t, found) {
+		assert.Equal(t, "owner-repo-q8_0", realName)
+		assert.Equal(t, filepath.Join(repoDir, "Q8_0", "model-Q8_0.gguf"), proxy.extractModelPathFromCmd(modelConfig.Cmd))
+	}
+}
+
+func TestProxyManager_ActivityExport(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	firstUsed := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	lastUsed := time.Date(2025, 1, 2, 13, 30, 0, 0, time.UTC)
+	stats := NewActivityStatsManager(filepath.Join(t.TempDir(), "activity_stats.json"))
+	stats.stats["model2"] = &ActivityStats{ModelID: "model2", RequestCount: 1, PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	stats.stats["model1"] = &ActivityStats{
+		ModelID:          "model1",
+		RequestCount:     3,
+		PromptTokens:     300,
+		CompletionTokens: 200,
+		TotalTokens:      500,
+		TotalDurationMs:  4000,
+		FirstUsed:        firstUsed,
+		LastUsed:         lastUsed,
+	}
+	proxy.metricsMonitor.ActivityStats = stats
+
+	req := httptest.NewRequest("GET", "/api/activity/export?format=csv", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, `attachment; filename="activity-stats.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, [][]string{
+		{"model_id", "request_count", "prompt_tokens", "completion_tokens", "total_tokens", "tokens_per_second", "first_used", "last_used", "vram_gb"},
+		{"model1", "3", "300", "200", "500", "50.00", "2025-01-02T09:00:00Z", "2025-01-02T13:30:00Z", ""},
+		{"model2", "1", "10", "5", "15", "0.00", "", "", ""},
+	}, records)
+
+	req = httptest.NewRequest("GET", "/api/activity/export?format=json", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		assert.Equal(t, `attachment; filename="activity-stats.json"`, w.Header().Get("Content-Disposition"))
+		rows := gjson.ParseBytes(w.Body.Bytes()).Array()
+		if assert.Len(t, rows, 2) {
+			assert.Equal(t, "model1", rows[0].Get("model_id").String())
+			assert.Equal(t, 50.0, rows[0].Get("tokens_per_second").Float())
+			assert.Equal(t, "2025-01-02T13:30:00Z", rows[0].Get("last_used").String())
+			assert.False(t, rows[1].Get("last_used").Exists())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/activity/export?format=xml", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestProxyManager_ContextUsageReport(t *testing.T) {
+	oversized := getTestSimpleResponderConfig("oversized")
+	oversized.Cmd += " --ctx-size 65536 --parallel 2"
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"oversized": oversized,
+			"busy":      getTestSimpleResponderConfig("busy"),
+			"new":       getTestSimpleResponderConfig("new"),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	stats := NewActivityStatsManager(filepath.Join(t.TempDir(), "activity_stats.json"))
+	proxy.metricsMonitor.ActivityStats = stats
+
+	// 100 requests of at most 3000 tokens in 32768 token slots
+	for i := 0; i < 100; i++ {
+		stats.RecordActivity("oversized", 1500+i*10, 500, 100)
+	}
+	// the default 4096 context is used in full
+	for i := 0; i < 20; i++ {
+		stats.RecordActivity("busy", 3500, 500, 100)
+	}
+	stats.RecordActivity("new", 100, 20, 100)
+
+	req := httptest.NewRequest("GET", "/api/activity/context-usage", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	body := w.Body.String()
+	assert.Equal(t, []interface{}{"busy", "new", "oversized"}, gjson.Get(body, "models.#.modelId").Value())
+
+	busy := gjson.Get(body, "models.0")
+	assert.Equal(t, int64(4096), busy.Get("slotContextSize").Int())
+	assert.Equal(t, int64(4000), busy.Get("maxTokens").Int())
+	assert.Equal(t, 0.977, busy.Get("utilization").Float())
+	assert.False(t, busy.Get("suggestedCtxSize").Exists())
+
+	assert.True(t, gjson.Get(body, "models.1.insufficientUsage").Bool())
+
+	oversizedReport := gjson.Get(body, "models.2")
+	assert.Equal(t, int64(100), oversizedReport.Get("requests").Int())
+	assert.Equal(t, int64(32768), oversizedReport.Get("slotContextSize").Int())
+	assert.Equal(t, int64(2990), oversizedReport.Get("maxTokens").Int())
+	assert.Equal(t, int64(2990), oversizedReport.Get("p99Tokens").Int())
+	// 2990 tokens with headroom fit in a 4096 token slot, for each of the 2 slots
+	assert.Equal(t, int64(8192), oversizedReport.Get("suggestedCtxSize").Int())
+	assert.Contains(t, oversizedReport.Get("suggestion").String(), "lower --ctx-size to 8192")
+
+	// the histogram is persisted with the stats
+	assert.NoError(t, stats.SaveToFile())
+	reloaded := NewActivityStatsManager(stats.filePath)
+	modelStats, found := reloaded.GetModelStats("oversized")
+	if assert.True(t, found) {
+		assert.Equal(t, int64(5), modelStats.ContextUsage[contextUsageBucket(2000)])
+		assert.Equal(t, int64(95), modelStats.ContextUsage[contextUsageBucket(2990)])
+		assert.Equal(t, int64(2990), modelStats.MaxContextTokens)
+	}
+}
+
+func TestActivityStatsManager_Flush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity_stats.json")
+	// a new manager only sees what was flushed, like one started after a crash
+	savedRequests := func() int64 {
+		return NewActivityStatsManager(path).GetGlobalStats().RequestCount
+	}
+
+	// an early flush after flushRequests requests
+	ctx, cancel := context.WithCancel(context.Background())
+	stats := NewActivityStatsManager(path)
+	stats.startFlushing(ctx, ActivityStatsConfig{FlushInterval: 3600, FlushRequests: 3})
+	stats.RecordActivity("model1", 10, 5, 100)
+	stats.RecordActivity("model1", 10, 5, 100)
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, savedRequests())
+	stats.RecordActivity("model1", 10, 5, 100)
+	assert.Eventually(t, func() bool { return savedRequests() == 3 }, time.Second, 10*time.Millisecond)
+	cancel()
+
+	// a periodic flush of the requests below flushRequests, then a crash without
+	// the save on shutdown
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stats = NewActivityStatsManager(path)
+	stats.setFlushConfig(ActivityStatsConfig{FlushRequests: 100})
+	stats.flushInterval.Store(int64(50 * time.Millisecond))
+	go stats.runFlusher(ctx)
+	stats.RecordActivity("model2", 100, 20, 100)
+	assert.Eventually(t, func() bool { return savedRequests() == 4 }, time.Second, 10*time.Millisecond)
+	cancel()
+
+	reloaded := NewActivityStatsManager(path)
+	modelStats, found := reloaded.GetModelStats("model2")
+	if assert.True(t, found) {
+		assert.Equal(t, int64(1), modelStats.RequestCount)
+		assert.Equal(t, int64(120), modelStats.TotalTokens)
+	}
+	modelStats, found = reloaded.GetModelStats("model1")
+	if assert.True(t, found) {
+		assert.Equal(t, int64(3), modelStats.RequestCount)
+	}
+}
+
+func TestProxyManager_ResetActivityStats(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	data, err := json.Marshal(SystemSettings{RequireAPIKey: true, APIKey: "secret"})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models:             map[string]ModelConfig{},
+	}))
+	stats := NewActivityStatsManager(filepath.Join(t.TempDir(), "activity_stats.json"))
+	proxy.metricsMonitor.ActivityStats = stats
+	stats.RecordActivity("model1", 100, 50, 1000)
+	stats.RecordActivity("model1", 100, 50, 1000)
+	stats.RecordActivity("model2", 10, 5, 100)
+	assert.NoError(t, stats.SaveToFile())
+
+	reset := func(query, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/activity/stats/reset"+query, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, reset("", `{"confirm":true}`, "").Code)
+	assert.Equal(t, http.StatusBadRequest, reset("", `{}`, "secret").Code)
+	assert.Equal(t, http.StatusNotFound, reset("?model=model3", `{"confirm":true}`, "secret").Code)
+	assert.Equal(t, int64(3), stats.GetGlobalStats().RequestCount)
+
+	// a model's counts are taken out of the global stats, in memory and on disk
+	w := reset("?model=model1", `{"confirm":true}`, "secret")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	for _, manager := range []*ActivityStatsManager{stats, NewActivityStatsManager(stats.filePath)} {
+		_, found := manager.GetModelStats("model1")
+		assert.False(t, found)
+		_, found = manager.GetModelStats("model2")
+		assert.True(t, found)
+		global := manager.GetGlobalStats()
+		assert.Equal(t, int64(1), global.RequestCount)
+		assert.Equal(t, int64(15), global.TotalTokens)
+	}
+
+	w = reset("", `{"confirm":true}`, "secret")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	for _, manager := range []*ActivityStatsManager{stats, NewActivityStatsManager(stats.filePath)} {
+		assert.Len(t, manager.GetStats(), 1) // only _global_
+		assert.Zero(t, manager.GetGlobalStats().RequestCount)
+	}
+}
+
+func TestProxyManager_DownloadAutoLoad(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	modelConfig := func(modelPath string) ModelConfig {
+		modelConfig := fakeUpstreamModel(upstream)
+		modelConfig.Cmd += " --model " + modelPath
+		return modelConfig
+	}
+	autoPath := filepath.Join(dir, "models", "auto-Q4_K_M.gguf")
+	manualPath := filepath.Join(dir, "models", "manual-Q4_K_M.gguf")
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"auto":   modelConfig(autoPath),
+			"manual": modelConfig(manualPath),
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+	processes := proxy.processGroups[DEFAULT_GROUP_ID].processes
+
+	proxy.handleDownloadCompleted(DownloadInfo{ID: "manual-1", ModelID: "owner/manual", Status: StatusCompleted, FilePath: manualPath})
+	assert.Equal(t, StateStopped, processes["manual"].CurrentState(), "downloads without autoLoad are not loaded")
+
+	proxy.handleDownloadCompleted(DownloadInfo{ID: "auto-1", ModelID: "owner/auto", Status: StatusCompleted, FilePath: autoPath, AutoLoad: true})
+	assert.Equal(t, StateReady, processes["auto"].CurrentState())
+	assert.Equal(t, StateStopped, processes["manual"].CurrentState())
+}
+
+func TestProxyManager_MoveDownload(t *testing.T) {
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "downloads")
+	destinationDir := filepath.Join(dir, "models")
+	assert.NoError(t, os.MkdirAll(destinationDir, 0755))
+
+	paths := CurrentFilePaths()
+	defer SetFilePaths(paths)
+	paths.FolderDatabase = filepath.Join(dir, "model_folders.json")
+	SetFilePaths(paths)
+	data, _ := json.Marshal(ModelFolderDatabase{
+		Folders: []ModelFolderEntry{{Path: destinationDir, Enabled: true}},
+	})
+	assert.NoError(t, os.WriteFile(CurrentFilePaths().FolderDatabase, data, 0644))
+
+	modelPath := filepath.Join(sourceDir, "frog-Q4_K_M.gguf")
+	configPath := filepath.Join(dir, "config.yaml")
+	configYAML := fmt.Sprintf(`healthCheckTimeout: 15
+logLevel: error
+models:
+  frog:
+    cmd: llama-server --port ${PORT} --model %s
+    proxy: http://127.0.0.1:12345
+  other:
+    cmd: llama-server --port ${PORT} --model %s
+    proxy: http://127.0.0.1:12346
+  similar:
+    cmd: llama-server --port ${PORT} --model %s --mmproj %s
+    proxy: http://127.0.0.1:12347
+`, modelPath, filepath.Join(sourceDir, "other.gguf"), modelPath+".bak", filepath.Join(dir, "copy", modelPath))
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	info, err := proxy.downloadManager.registerDownload("frog", "frog-Q4_K_M.gguf", "https://example.com/frog-Q4_K_M.gguf", "", sourceDir, DownloadOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, os.WriteFile(modelPath, []byte("GGUF"), 0644))
+
+	move := func(id, destination string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"destination":%q}`, destination)
+		req := httptest.NewRequest("POST", "/api/models/downloads/"+id+"/move", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, move("missing", destinationDir).Code)
+	assert.Equal(t, http.StatusConflict, move(info.ID, destinationDir).Code, "pending downloads can't be moved")
+
+	proxy.downloadManager.updateStatus(info.ID, StatusCompleted)
+	assert.Equal(t, http.StatusBadRequest, move(info.ID, t.TempDir()).Code, "the destination must be tracked")
+	assert.FileExists(t, modelPath)
+
+	w := move(info.ID, destinationDir)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	movedPath := filepath.Join(destinationDir, "frog-Q4_K_M.gguf")
+	assert.Equal(t, movedPath, gjson.Get(w.Body.String(), "files.0.to").String())
+	assert.Equal(t, []interface{}{"frog"}, gjson.Get(w.Body.String(), "updatedModels").Value())
+	assert.NoFileExists(t, modelPath)
+	assert.FileExists(t, movedPath)
+
+	// the config points the model to the new path, other models are kept even
+	// when their paths contain the moved one
+	updated, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{movedPath}, modelFilesFromCmd(updated.Models["frog"]))
+	assert.Equal(t, config.Models["other"].Cmd, updated.Models["other"].Cmd)
+	assert.Equal(t, config.Models["similar"].Cmd, updated.Models["similar"].Cmd)
+
+	download, _ := proxy.downloadManager.GetDownload(info.ID)
+	assert.Equal(t, movedPath, download.FilePath)
+
+	db, err := proxy.loadModelFolderDatabase()
+	if assert.NoError(t, err) && assert.Len(t, db.Folders, 1) {
+		assert.Equal(t, 1, db.Folders[0].ModelCount)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, move(info.ID, destinationDir).Code, "already in the destination")
+}
+
+func TestProxyManager_ReplacePath(t *testing.T) {
+	from, to := "/models/frog.gguf", "/archive/frog.gguf"
+	tests := map[string]string{
+		"/models/frog.gguf":                              "/archive/frog.gguf",
+		"llama-server --model /models/frog.gguf":         "llama-server --model /archive/frog.gguf",
+		"llama-server -m=/models/frog.gguf --port 1":     "llama-server -m=/archive/frog.gguf --port 1",
+		"llama-server\n--mmproj \"/models/frog.gguf\"\n": "llama-server\n--mmproj \"/archive/frog.gguf\"\n",
+		"llama-server --model /models/frog.gguf.bak":     "llama-server --model /models/frog.gguf.bak",
+		"llama-server --model /old/models/frog.gguf":     "llama-server --model /old/models/frog.gguf",
+		"llama-server --alias /models/frog.gguf":         "llama-server --alias /models/frog.gguf",
+		"/models/frog.gguf.bak":                          "/models/frog.gguf.bak",
+	}
+	for value, expected := range tests {
+		assert.Equal(t, expected, replacePath(value, from, to), value)
+	}
+}
+
+func TestProxyManager_RecommendedSettings(t *testing.T) {
+	t.Run("nvidia system", func(t *testing.T) {
+		system := autosetup.SystemInfo{
+			OS:          "linux",
+			HasCUDA:     true,
+			HasVulkan:   true,
+			TotalRAMGB:  64,
+			TotalVRAMGB: 24,
+			VRAMDetails: []autosetup.GPUInfo{{Name: "RTX 4090", VRAMGB: 24, Type: "CUDA", Kind: autosetup.GPUKindDiscrete}},
+		}
+		rec := recommendForSystem(system, nil)
+		assert.Equal(t, []string{"cuda", "vulkan", "cpu"}, rec.Backends)
+		assert.Equal(t, SystemSettings{
+			GPUType:          "nvidia",
+			Backend:          "cuda",
+			VRAMGB:           19,
+			RAMGB:            32,
+			PreferredContext: 131072,
+			ThroughputFirst:  true,
+			EnableJinja:      true,
+		}, rec.systemSettings())
+	})
+
+	t.Run("cpu only system", func(t *testing.T) {
+		rec := recommendForSystem(autosetup.SystemInfo{OS: "linux", TotalRAMGB: 16}, &autosetup.RealtimeHardwareInfo{TotalRAMGB: 15, AvailableRAMGB: 10})
+		assert.Equal(t, 10.0, rec.AvailableRAMGB)
+		assert.Equal(t, SystemSettings{
+			GPUType:          "none",
+			Backend:          "cpu",
+			RAMGB:            7,
+			PreferredContext: 16384,
+			EnableJinja:      true,
+		}, rec.systemSettings())
+	})
+
+	t.Run("endpoint agrees with system detection", func(t *testing.T) {
+		proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/settings/recommended", nil))
+		detection := httptest.NewRecorder()
+		proxy.ServeHTTP(detection, httptest.NewRequest("GET", "/api/system/detection", nil))
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) || !assert.Equal(t, http.StatusOK, detection.Code) {
+			return
+		}
+
+		settings := gjson.GetBytes(w.Body.Bytes(), "settings")
+		recommendations := gjson.GetBytes(detection.Body.Bytes(), "recommendations")
+		assert.Equal(t, recommendations.Get("primaryBackend").String(), settings.Get("backend").String())
+		assert.Equal(t, recommendations.Get("suggestedContextSize").Int(), settings.Get("preferredContext").Int())
+		assert.Equal(t, recommendations.Get("suggestedVRAMAllocation").Float(), settings.Get("vramGB").Float())
+		assert.Equal(t, recommendations.Get("suggestedRAMAllocation").Float(), settings.Get("ramGB").Float())
+		assert.Equal(t, recommendations.Get("throughputFirst").Bool(), settings.Get("throughputFirst").Bool())
+	})
+}
+
+func TestProxyManager_CustomFilePaths(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	// a flag wins over the environment, which wins over the default
+	t.Setenv(ConfigFileEnv, "env.yaml")
+	t.Setenv(SettingsFileEnv, "b.settings.json")
+	t.Setenv(FolderDatabaseFileEnv, "")
+	t.Setenv(ActivityStatsFileEnv, "b.stats.json")
+	t.Setenv(DownloadJournalEnv, "")
+	paths := ResolveFilePaths(FilePaths{Config: "b.yaml", DownloadJournal: "b.downloads.json"})
+	assert.Equal(t, FilePaths{
+		Config:          "b.yaml",
+		Settings:        "b.settings.json",
+		FolderDatabase:  "model_folders.json",
+		ActivityStats:   "b.stats.json",
+		DownloadJournal: "b.downloads.json",
+	}, paths)
+
+	paths.FolderDatabase = "b.folders.json"
+	defer SetFilePaths(CurrentFilePaths())
+	SetFilePaths(paths)
+
+	assert.NoError(t, os.WriteFile("b.yaml", []byte("models: {}\n"), 0644))
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve("GET", "/api/config/effective", "")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.Equal(t, "b.yaml", gjson.Get(w.Body.String(), "configPath").String())
+	}
+
+	w = serve("POST", "/api/settings/system", `{"backend":"cuda","vramGB":16,"ramGB":32,"preferredContext":8192}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.FileExists(t, "b.settings.json")
+	assert.NoFileExists(t, "settings.json")
+	w = serve("GET", "/api/settings/system", "")
+	assert.Equal(t, int64(8192), gjson.Get(w.Body.String(), "settings.preferredContext").Int(), w.Body.String())
+
+	modelsDir := t.TempDir()
+	w = serve("POST", "/api/config/folders", fmt.Sprintf(`{"folderPaths":[%q]}`, modelsDir))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.FileExists(t, "b.folders.json")
+	assert.NoFileExists(t, "model_folders.json")
+	w = serve("GET", "/api/config/folders", "")
+	assert.Contains(t, w.Body.String(), modelsDir)
+
+	assert.Equal(t, "b.stats.json", proxy.metricsMonitor.ActivityStats.filePath)
+	assert.Equal(t, "b.downloads.json", proxy.downloadManager.journalPath())
+}
+
+func TestProxyManager_GPUVRAMOverrides(t *testing.T) {
+	useTempFilePaths(t)
+
+	system := autosetup.SystemInfo{
+		OS:          "linux",
+		HasCUDA:     true,
+		TotalRAMGB:  64,
+		VRAMDetails: []autosetup.GPUInfo{{Name: "NVIDIA A16", VRAMGB: 2, Type: "CUDA", Kind: autosetup.GPUKindDiscrete}},
+	}
+	autosetup.ApplyVRAMOverrides(&system, map[string]float64{"0": 16})
+
+	// the override wins over the VRAM reported by realtime detection
+	rec := recommendForSystem(system, &autosetup.RealtimeHardwareInfo{TotalRAMGB: 64, TotalVRAMGB: 2, AvailableRAMGB: 40})
+	assert.Equal(t, 16.0, rec.TotalVRAMGB)
+	assert.Equal(t, 12.0, rec.systemSettings().VRAMGB)
+
+	info := gpuDetectionInfo(system.VRAMDetails[0], gin.H{"index": 0})
+	assert.Equal(t, 16.0, info["vramGB"])
+	assert.Equal(t, 2.0, info["detectedVRAMGB"])
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/system", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"backend":"cuda","vramGB":16,"ramGB":32,"preferredContext":8192,"gpuVramOverrides":{"0":-1}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = post(`{"backend":"cuda","vramGB":16,"ramGB":32,"preferredContext":8192,"gpuVramOverrides":{"0":16}}`)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	// saving without overrides keeps the saved ones
+	w = post(`{"backend":"cuda","vramGB":16,"ramGB":32,"preferredContext":8192}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	settings, err := proxy.loadSystemSettings()
+	if assert.NoError(t, err) && assert.NotNil(t, settings) {
+		assert.Equal(t, map[string]float64{"0": 16}, settings.GPUVRAMOverrides)
+	}
+}
+
+func TestProxyManager_EffectiveSettings(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	data, err := json.Marshal(SystemSettings{
+		Backend:          "cuda",
+		VRAMGB:           8,
+		RAMGB:            32,
+		PreferredContext: 8192,
+		EnableJinja:      true,
+		APIKey:           "secret",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+
+	t.Setenv("FROGLLM_BACKEND", "CPU")
+	t.Setenv("FROGLLM_VRAM_GB", "24")
+	t.Setenv("FROGLLM_PREFERRED_CONTEXT", "lots")
+	t.Setenv("HF_TOKEN", "hf_env_token")
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	get := func(path string) gjson.Result {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return gjson.Parse(w.Body.String())
+	}
+
+	effective := get("/api/settings/effective")
+	assert.True(t, effective.Get("savedExists").Bool())
+	assert.Equal(t, "cpu", effective.Get("settings.backend").String())
+	assert.Equal(t, 24.0, effective.Get("settings.vramGB").Float())
+	assert.Equal(t, 32.0, effective.Get("settings.ramGB").Float())
+	assert.Equal(t, int64(8192), effective.Get("settings.preferredContext").Int())
+	assert.False(t, effective.Get("settings.throughputFirst").Bool())
+	assert.False(t, effective.Get("settings.apiKey").Exists())
+	assert.False(t, effective.Get("settings.huggingFaceApiKey").Exists())
+
+	assert.Equal(t, "env", effective.Get("sources.backend").String())
+	assert.Equal(t, "env", effective.Get("sources.vramGB").String())
+	assert.Equal(t, "saved", effective.Get("sources.ramGB").String())
+	assert.Equal(t, "saved", effective.Get("sources.preferredContext").String())
+	assert.Equal(t, "env", effective.Get("sources.huggingFaceApiKey").String())
+	assert.Equal(t, "backend", effective.Get("envOverrides.FROGLLM_BACKEND").String())
+	assert.False(t, effective.Get("envOverrides.FROGLLM_PREFERRED_CONTEXT").Exists())
+	assert.Contains(t, effective.Get("warnings.0").String(), "FROGLLM_PREFERRED_CONTEXT")
+
+	// the saved settings are unchanged, the overrides are what is used
+	saved := get("/api/settings/system")
+	assert.Equal(t, "cuda", saved.Get("settings.backend").String())
+	assert.Equal(t, 8.0, saved.Get("settings.vramGB").Float())
+	settings, err := proxy.loadSystemSettings()
+	if assert.NoError(t, err) && assert.NotNil(t, settings) {
+		assert.Equal(t, "cpu", settings.Backend)
+		assert.Equal(t, "hf_env_token", settings.HuggingFaceApiKey)
+	}
+
+	// the effective settings are worked out once, requests share them
+	again, err := proxy.loadSystemSettings()
+	assert.NoError(t, err)
+	assert.Same(t, settings, again)
+
+	// without settings.json the env overrides still apply and the rest is detected or defaulted
+	assert.NoError(t, os.Remove(filepath.Join(dir, "settings.json")))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/reload", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	effective = get("/api/settings/effective")
+	assert.False(t, effective.Get("savedExists").Bool())
+	assert.Equal(t, "cpu", effective.Get("settings.backend").String())
+	assert.Equal(t, "detected", effective.Get("sources.ramGB").String())
+	assert.Equal(t, "default", effective.Get("sources.preferredContext").String())
+	assert.Equal(t, int64(32768), effective.Get("settings.preferredContext").Int())
+	assert.True(t, effective.Get("settings.enableJinja").Bool())
+}
+
+func TestProxyManager_ReloadSystemSettings(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	writeSettings := func(settings SystemSettings) {
+		data, err := json.Marshal(settings)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+	}
+	writeSettings(SystemSettings{Backend: "cuda"})
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/downloads", "").Code)
+
+	// a change on disk is not read by requests until it is reloaded
+	writeSettings(SystemSettings{Backend: "vulkan", RequireAPIKey: true, APIKey: "secret"})
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/downloads", "").Code)
+	w := request("GET", "/api/settings/system", "")
+	assert.Equal(t, "cuda", gjson.Get(w.Body.String(), "settings.backend").String())
+
+	w = request("POST", "/api/settings/reload", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `["apiKey","backend","requireApiKey"]`, gjson.Get(w.Body.String(), "changed").Raw)
+
+	// the API key requirement applies from the next request
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/api/models/downloads", "").Code)
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/downloads", "secret").Code)
+	w = request("GET", "/api/settings/system", "")
+	assert.Equal(t, "vulkan", gjson.Get(w.Body.String(), "settings.backend").String())
+
+	// invalid settings are refused and the ones in memory stay
+	writeSettings(SystemSettings{Backend: "cpu", QuantizationPreference: []string{"Q9_Z"}})
+	w = request("POST", "/api/settings/reload", "secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "quantizationPreference[0]")
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/api/models/downloads", "").Code)
+
+	// saving through the API updates the settings in memory
+	writeSettings(SystemSettings{Backend: "vulkan", RequireAPIKey: true, APIKey: "secret"})
+	req := httptest.NewRequest("POST", "/api/settings/system", strings.NewReader(`{"backend":"cpu","vramGB":8,"ramGB":16,"preferredContext":4096}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = request("GET", "/api/settings/system", "")
+	assert.Equal(t, "cpu", gjson.Get(w.Body.String(), "settings.backend").String())
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/downloads", "").Code)
+}
+
+func TestProxyManager_ModelSnippets(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"chat":   {Cmd: "llama-server --port ${PORT} -m chat.gguf"},
+			"embed":  {Cmd: "llama-server --port ${PORT} -m embed.gguf --embedding --pooling mean"},
+			"rerank": {Cmd: "llama-server --port ${PORT} -m rerank.gguf --reranking"},
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	snippets := func(model string) gjson.Result {
+		req := httptest.NewRequest("GET", "/api/models/"+model+"/snippets", nil)
+		req.Host = "frog.local:5800"
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return gjson.Parse(w.Body.String())
+	}
+
+	// an embedding model gets embeddings snippets
+	result := snippets("embed")
+	assert.Equal(t, "embedding", result.Get("type").String())
+	assert.Equal(t, "/v1/embeddings", result.Get("endpoint").String())
+	assert.Equal(t, "http://frog.local:5800", result.Get("baseUrl").String())
+	assert.Contains(t, result.Get("snippets.curl").String(), "curl http://frog.local:5800/v1/embeddings")
+	assert.Contains(t, result.Get("snippets.curl").String(), `"input": "Frogs are amphibians."`)
+	assert.Contains(t, result.Get("snippets.python").String(), `client.embeddings.create(model="embed"`)
+	assert.Contains(t, result.Get("snippets.python").String(), `base_url="http://frog.local:5800/v1"`)
+	assert.Contains(t, result.Get("snippets.javascript").String(), `client.embeddings.create({ model: "embed"`)
+	assert.NotContains(t, result.Get("snippets.curl").String(), "Authorization")
+
+	result = snippets("chat")
+	assert.Equal(t, "chat", result.Get("type").String())
+	assert.Contains(t, result.Get("snippets.curl").String(), "/v1/chat/completions")
+	assert.Contains(t, result.Get("snippets.python").String(), "client.chat.completions.create(")
+
+	result = snippets("rerank")
+	assert.Equal(t, "reranker", result.Get("type").String())
+	assert.Contains(t, result.Get("snippets.curl").String(), "/v1/rerank")
+	assert.Contains(t, result.Get("snippets.python").String(), `"documents": ["Frogs croak at night."`)
+
+	// the sample prompt can be replaced, quotes are escaped for each language
+	req := httptest.NewRequest("GET", "/api/models/chat/snippets?prompt="+url.QueryEscape(`it's "fine"`), nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Contains(t, gjson.Get(w.Body.String(), "snippets.curl").String(), `it'\''s \"fine\"`)
+	assert.Contains(t, gjson.Get(w.Body.String(), "snippets.python").String(), `"it's \"fine\""`)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/missing/snippets", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestProxyManager_ModelGroup(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+			"model3": getTestSimpleResponderConfig("model3"),
+			"model4": getTestSimpleResponderConfig("model4"),
+			"model5": getTestSimpleResponderConfig("model5"),
+		},
+		LogLevel: "error",
+		Groups: map[string]GroupConfig{
+			"chat":    {Swap: true, Exclusive: true, Members: []string{"model1", "model2", "model3"}},
+			"embed":   {Swap: false, Exclusive: false, Members: []string{"model4"}},
+			"forever": {Swap: true, Exclusive: false, Persistent: true, Members: []string{"model5"}},
+		},
+	})
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	get := func(model string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/"+model+"/group", nil))
+		return w
+	}
+
+	w := get("model2")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		body := w.Body.Bytes()
+		assert.Equal(t, "chat", gjson.GetBytes(body, "group").String())
+		assert.True(t, gjson.GetBytes(body, "swap").Bool())
+		assert.True(t, gjson.GetBytes(body, "exclusive").Bool())
+		assert.False(t, gjson.GetBytes(body, "persistent").Bool())
+		assert.Equal(t, `["model1","model3"]`, gjson.GetBytes(body, "siblings").Raw)
+		// the persistent group is never unloaded
+		assert.Equal(t, `["model1","model3","model4"]`, gjson.GetBytes(body, "unloads").Raw)
+	}
+
+	w = get("model4")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.Equal(t, "embed", gjson.GetBytes(w.Body.Bytes(), "group").String())
+		assert.Equal(t, `[]`, gjson.GetBytes(w.Body.Bytes(), "siblings").Raw)
+		assert.Equal(t, `[]`, gjson.GetBytes(w.Body.Bytes(), "unloads").Raw)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("missing").Code)
+}
+
+func TestProxyManager_GenerationStream(t *testing.T) {
+	const tokens = 10
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < tokens; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"t%d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{}}],\"usage\":{\"completion_tokens\":10,\"prompt_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": fakeUpstreamModel(upstream),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	stream := httptest.NewRecorder()
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		proxy.ServeHTTP(stream, httptest.NewRequest("GET", "/api/models/model1/generation-stream", nil))
+	}()
+	time.Sleep(100 * time.Millisecond) // let the stream subscribe
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","stream":true}`))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	select {
+	case <-streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("generation stream did not close when the generation ended")
+	}
+
+	assert.Equal(t, "text/event-stream", stream.Header().Get("Content-Type"))
+	var progress []gjson.Result
+	for _, line := range strings.Split(stream.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			progress = append(progress, gjson.Parse(data))
+		}
+	}
+	if !assert.GreaterOrEqual(t, len(progress), 2, stream.Body.String()) {
+		return
+	}
+
+	// periodic updates while generating, then a final one
+	first, last := progress[0], progress[len(progress)-1]
+	assert.False(t, first.Get("done").Bool())
+	assert.Less(t, first.Get("tokensGenerated").Int(), int64(tokens))
+	assert.True(t, last.Get("done").Bool())
+	assert.Equal(t, int64(tokens), last.Get("tokensGenerated").Int())
+	assert.Greater(t, last.Get("tokensPerSecond").Float(), 0.0)
+	assert.Equal(t, "model1", last.Get("model").String())
+	assert.Equal(t, first.Get("generationId").Int(), last.Get("generationId").Int())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/missing/generation-stream", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCalibrateBatchSizes(t *testing.T) {
+	// mocked prompt speeds, 2048/1024 does not fit
+	timings := map[batchCandidate]float64{
+		{BatchSize: 512, UBatchSize: 512}:   1800,
+		{BatchSize: 1024, UBatchSize: 512}:  2400,
+		{BatchSize: 2048, UBatchSize: 512}:  2100,
+		{BatchSize: 2048, UBatchSize: 1024}: 0,
+	}
+	results, best := calibrateBatchSizes(defaultBatchCandidates, func(candidate batchCandidate) (float64, error) {
+		if timings[candidate] == 0 {
+			return 0, fmt.Errorf("model did not start: out of memory")
+		}
+		return timings[candidate], nil
+	})
+
+	assert.Len(t, results, 4)
+	if assert.NotNil(t, best) {
+		assert.Equal(t, batchCandidate{BatchSize: 1024, UBatchSize: 512}, best.batchCandidate)
+		assert.Equal(t, 2400.0, best.TokensPerSecond)
+	}
+	assert.Contains(t, results[3].Error, "out of memory")
+
+	_, best = calibrateBatchSizes(defaultBatchCandidates, func(batchCandidate) (float64, error) {
+		return 0, fmt.Errorf("failed")
+	})
+	assert.Nil(t, best)
+}
+
+func TestSetCmdFlag(t *testing.T) {
+	assert.Equal(t, "llama-server -m model.gguf --batch-size 1024",
+		setCmdFlag("llama-server -m model.gguf", 1024, "--batch-size", "-b"))
+	assert.Equal(t, "llama-server --batch-size 1024 -m model.gguf",
+		setCmdFlag("llama-server -b 512 -m model.gguf", 1024, "--batch-size", "-b"))
+	assert.Equal(t, "llama-server --batch-size 1024",
+		setCmdFlag("llama-server --batch-size=512", 1024, "--batch-size", "-b"))
+	// -b does not match inside -bs
+	assert.Equal(t, "llama-server -bs 4 --batch-size 256",
+		setCmdFlag("llama-server -bs 4", 256, "--batch-size", "-b"))
+	assert.Equal(t, "llama-server\n  -m model.gguf\n  --ubatch-size 512\n",
+		setCmdFlag("llama-server\n  -m model.gguf\n", 512, "--ubatch-size", "-ub"))
+}
+
+func TestProxyManager_CalibrateModel(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `healthCheckTimeout: 15
+logLevel: error
+models:
+  model1:
+    cmd: |
+      llama-server
+        --port ${PORT}
+        -b 256
+`
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	// without calibrate only the candidates are listed
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/model1/calibrate", nil))
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.False(t, gjson.Get(w.Body.String(), "calibrate").Bool())
+		assert.Equal(t, int64(len(defaultBatchCandidates)), gjson.Get(w.Body.String(), "candidates.#").Int())
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/models/model1/calibrate", strings.NewReader(`{"candidates": [{"batchSize": 256, "ubatchSize": 512}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/missing/calibrate", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the winner replaces -b and is appended as a new line of the raw cmd
+	assert.NoError(t, proxy.persistBatchSizes("model1", batchCandidate{BatchSize: 2048, UBatchSize: 512}))
+	saved, err := LoadConfig(configPath)
+	if assert.NoError(t, err) {
+		assert.Contains(t, saved.Models["model1"].Cmd, "--batch-size 2048")
+		assert.Contains(t, saved.Models["model1"].Cmd, "--ubatch-size 512")
+		assert.NotContains(t, saved.Models["model1"].Cmd, "-b 256")
+	}
+	data, _ := os.ReadFile(configPath)
+	assert.Contains(t, string(data), "--port ${PORT}")
+}
+
+func TestProxyManager_CalibrateSharedProcessModel(t *testing.T) {
+	var completions atomic.Int32
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/completion" {
+			completions.Add(1)
+			w.Write([]byte(`{"timings": {"prompt_per_second": 1000}}`))
+		}
+	})
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := strings.NewReplacer("${upstream}", upstream.URL, "${cmd}", fakeUpstreamCmd).Replace(`healthCheckTimeout: 15
+logLevel: error
+models:
+  model1:
+    cmd: ${cmd} --port ${PORT}
+    proxy: ${upstream}
+  model2:
+    cmd: ${cmd} --port ${PORT}
+    proxy: ${upstream}
+groups:
+  shared:
+    swap: true
+    sharedProcess: true
+    members: [model1, model2]
+`)
+	assert.NoError(t, os.WriteFile(configPath, []byte(configYAML), 0644))
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+	proxy.SetConfigPath(configPath)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model": "model2"}`)))
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, StateReady, proxy.modelProcessState("model2"))
+
+	// the benchmark loads model1 through the group, which frees the shared port first
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/models/model1/calibrate", strings.NewReader(`{"calibrate": true, "candidates": [{"batchSize": 1024, "ubatchSize": 512}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	proxy.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.Equal(t, 1000.0, gjson.Get(w.Body.String(), "best.tokensPerSecond").Float())
+	assert.Equal(t, int32(1), completions.Load())
+	assert.Equal(t, StateStopped, proxy.modelProcessState("model2"))
+	assert.Equal(t, StateStopped, proxy.modelProcessState("model1"), "the benchmark process is stopped")
+
+	saved, err := LoadConfig(configPath)
+	if assert.NoError(t, err) {
+		assert.Contains(t, saved.Models["model1"].Cmd, "--batch-size 1024")
+	}
+}
+
+func TestProxyManager_ModelFailReason(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"broken": {
+				Cmd:           `sh -c "echo 'gguf_init_from_file: failed to open GGUF file missing.gguf' >&2; exit 1"`,
+				Proxy:         "http://127.0.0.1:1",
+				CheckEndpoint: "/health",
+			},
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model": "broken"}`))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/info", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	info := gjson.Get(w.Body.String(), `models.#(model=="broken")`)
+	assert.False(t, info.Get("running").Bool())
+	assert.False(t, info.Get("loading").Bool())
+	assert.Equal(t, "model file not found: gguf_init_from_file: failed to open GGUF file missing.gguf", info.Get("failReason").String())
+	assert.Equal(t, string(LoadErrorModelNotFound), info.Get("failCode").String())
+	assert.NotEmpty(t, info.Get("failSuggestion").String())
+
+	proxy.Lock()
+	models := proxy.getModelStatus()
+	proxy.Unlock()
+	if assert.Len(t, models, 1) {
+		assert.Equal(t, "stopped", models[0].State)
+		assert.Equal(t, info.Get("failReason").String(), models[0].FailReason)
+		assert.Equal(t, LoadErrorModelNotFound, models[0].FailCode)
+	}
+}
+
+func TestProxyManager_SetModelChatTemplate(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	templatePath := filepath.Join(dir, "template.jinja")
+	assert.NoError(t, os.WriteFile(templatePath, []byte("{{ messages }}"), 0644))
+	assert.NoError(t, os.WriteFile(configPath, []byte(`healthCheckTimeout: 15
+logLevel: error
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	setTemplate := func(model, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/models/"+model+"/chat-template", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	w := setTemplate("model1", fmt.Sprintf(`{"chatTemplateFile": %q}`, templatePath))
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		saved, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.Equal(t, templatePath, saved.Models["model1"].ChatTemplateFile)
+		assert.Contains(t, saved.Models["model1"].Cmd, "--chat-template-file "+templatePath)
+	}
+
+	w = setTemplate("model1", `{"chatTemplateFile": "/does/not/exist.jinja"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusBadRequest, setTemplate("model1", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, setTemplate("missing", fmt.Sprintf(`{"chatTemplateFile": %q}`, templatePath)).Code)
+
+	// an empty path removes the template again
+	w = setTemplate("model1", `{"chatTemplateFile": ""}`)
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		saved, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.NotContains(t, saved.Models["model1"].Cmd, "--chat-template-file")
+	}
+}
+
+func TestProxyManager_SetModelLoRA(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	adapterPath := filepath.Join(dir, "sql-lora.gguf")
+	writeTestGGUF(t, adapterPath, map[string]interface{}{
+		"general.architecture": "llama",
+		"general.type":         "adapter",
+		"adapter.type":         "lora",
+	})
+	modelPath := filepath.Join(dir, "base.gguf")
+	writeTestGGUF(t, modelPath, map[string]interface{}{
+		"general.architecture": "llama",
+	})
+	assert.NoError(t, os.WriteFile(configPath, []byte(`healthCheckTimeout: 15
+logLevel: error
+models:
+  model1:
+    cmd: path/to/server --port ${PORT}
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	setLoRA := func(model, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/models/"+model+"/lora", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	w := setLoRA("model1", fmt.Sprintf(`{"path": %q, "enabled": true, "scale": 0.75}`, adapterPath))
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		saved, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.Equal(t, []LoRAAdapterConfig{{Path: adapterPath, Scale: 0.75}}, saved.Models["model1"].LoRAAdapters)
+		assert.Contains(t, saved.Models["model1"].Cmd, "--lora-scaled "+adapterPath+" 0.75")
+	}
+
+	// disabling keeps the adapter in the config without applying it
+	w = setLoRA("model1", fmt.Sprintf(`{"path": %q, "enabled": false}`, adapterPath))
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		saved, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.Equal(t, []LoRAAdapterConfig{{Path: adapterPath, Scale: 0.75, Disabled: true}}, saved.Models["model1"].LoRAAdapters)
+		assert.NotContains(t, saved.Models["model1"].Cmd, "--lora")
+	}
+
+	assert.Equal(t, http.StatusBadRequest, setLoRA("model1", fmt.Sprintf(`{"path": %q, "enabled": true}`, modelPath)).Code)
+	assert.Equal(t, http.StatusBadRequest, setLoRA("model1", fmt.Sprintf(`{"path": %q}`, adapterPath)).Code)
+	assert.Equal(t, http.StatusBadRequest, setLoRA("model1", fmt.Sprintf(`{"path": %q, "enabled": true, "scale": -1}`, adapterPath)).Code)
+	assert.Equal(t, http.StatusNotFound, setLoRA("model1", `{"path": "/other-lora.gguf", "enabled": false}`).Code)
+	assert.Equal(t, http.StatusNotFound, setLoRA("missing", fmt.Sprintf(`{"path": %q, "enabled": true}`, adapterPath)).Code)
+}
+
+func TestProxyManager_GPUHealthPausesLoading(t *testing.T) {
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"model1"}`))
+	})
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		GPUHealth:          GPUHealthConfig{PauseLoading: true},
+		Models: map[string]ModelConfig{
+			"model1": fakeUpstreamModel(upstream),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	var issues []autosetup.GPUHealthIssue
+	proxy.gpuHealth.query = func() ([]autosetup.GPUHealthIssue, error) {
+		return issues, nil
+	}
+	gpuHealth := func() gjson.Result {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/system/gpu-health", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		return gjson.Parse(w.Body.String())
+	}
+	chat := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model": "model1"}`)))
+		return w
+	}
+
+	issues = []autosetup.GPUHealthIssue{{GPU: "00000000:01:00.0", Name: "NVIDIA RTX 4090", Problem: "reset required"}}
+	proxy.checkGPUHealth()
+	// a single failed poll is not enough to pause loading
+	assert.True(t, gpuHealth().Get("healthy").Bool())
+
+	proxy.checkGPUHealth()
+	health := gpuHealth()
+	assert.False(t, health.Get("healthy").Bool())
+	assert.True(t, health.Get("loadingPaused").Bool())
+	assert.Equal(t, "reset required", health.Get("issues.0.problem").String())
+
+	w := chat()
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "model loading is paused, the GPU is unhealthy: NVIDIA RTX 4090 (00000000:01:00.0): reset required")
+
+	issues = nil
+	proxy.checkGPUHealth()
+	assert.False(t, gpuHealth().Get("loadingPaused").Bool())
+	assert.Equal(t, http.StatusOK, chat().Code)
+}
+
+func TestProxyManager_VRAMReclaimAfterUnload(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"ok"}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	server := filepath.Join(dir, "server.sh")
+	writeFakeLlamaServer(t, server)
+	modelPath := filepath.Join(dir, "model1.gguf")
+	assert.NoError(t, os.WriteFile(modelPath, make([]byte, 1024*1024), 0644))
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		VRAMReclaimTimeout: 5,
+		Models: map[string]ModelConfig{
+			"model1": {Cmd: server + " --model " + modelPath, Proxy: upstream.URL, CheckEndpoint: "/health"},
+			"model2": {Cmd: server, Proxy: upstream.URL, CheckEndpoint: "/health"},
+		},
+		Groups: map[string]GroupConfig{
+			"G1": {Swap: true, Exclusive: true, Members: []string{"model1"}},
+			"G2": {Swap: true, Exclusive: true, Members: []string{"model2"}},
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	defer func(interval time.Duration) { vramReclaimPollInterval = interval }(vramReclaimPollInterval)
+	vramReclaimPollInterval = 10 * time.Millisecond
+
+	// the driver keeps the VRAM for a few polls after the process exits
+	var mu sync.Mutex
+	measurements := 0
+	proxy.measureVRAM = func() (float64, float64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		measurements++
+		if measurements < 4 {
+			return 10, 24, nil
+		}
+		return 12, 24, nil
+	}
+
+	chat := func(model string) int {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model": "`+model+`"}`)))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, chat("model1"))
+	mu.Lock()
+	assert.Equal(t, 0, measurements, "nothing was unloaded")
+	mu.Unlock()
+
+	assert.Equal(t, http.StatusOK, chat("model2"))
+	mu.Lock()
+	assert.Equal(t, 4, measurements, "load waits until the VRAM is free")
+	mu.Unlock()
+	assert.Equal(t, StateStopped, proxy.processGroups["G1"].processes["model1"].CurrentState())
+
+	// VRAM that never comes back is logged and the load goes ahead
+	proxy.measureVRAM = func() (float64, float64, error) {
+		return 10, 24, nil
+	}
+	assert.False(t, proxy.waitForVRAMReclaim(10, 1, 50*time.Millisecond))
+	assert.True(t, proxy.waitForVRAMReclaim(9, 1, 50*time.Millisecond))
+}
+
+func TestProxyManager_MinFreeVRAM(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"ok"}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	server := filepath.Join(dir, "server.sh")
+	writeFakeLlamaServer(t, server)
+	smallPath := filepath.Join(dir, "small.gguf")
+	assert.NoError(t, os.WriteFile(smallPath, make([]byte, 1024*1024), 0644))
+	// a sparse 4GB file
+	largePath := filepath.Join(dir, "large.gguf")
+	large, err := os.Create(largePath)
+	assert.NoError(t, err)
+	assert.NoError(t, large.Truncate(4*1024*1024*1024))
+	large.Close()
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		MinFreeVRAMGB:      2,
+		Models: map[string]ModelConfig{
+			"small": {Cmd: server + " --model " + smallPath, Proxy: upstream.URL, CheckEndpoint: "/health"},
+			"large": {Cmd: server + " --model " + largePath, Proxy: upstream.URL, CheckEndpoint: "/health"},
+		},
+		Groups: map[string]GroupConfig{
+			"G1": {Swap: true, Members: []string{"small"}},
+			"G2": {Swap: true, Members: []string{"large"}},
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	// the small model holds 2GB of the 7GB free without it
+	proxy.measureVRAM = func() (float64, float64, error) {
+		if proxy.processGroups["G1"].processState("small") == StateReady {
+			return 5, 8, nil
+		}
+		return 7, 8, nil
+	}
+
+	chat := func(model string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model": "`+model+`"}`))
+		req.Header.Set("Accept", "application/json")
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	// 5GB free less the 4GB model is below the minimum, unloading the small model makes room
+	assert.Equal(t, http.StatusOK, chat("small").Code)
+	assert.Equal(t, http.StatusOK, chat("large").Code)
+	assert.Equal(t, StateStopped, proxy.processGroups["G1"].processState("small"))
+	assert.Equal(t, StateReady, proxy.processGroups["G2"].processState("large"))
+
+	// with 4GB to keep free the load is refused even after unloading
+	proxy.StopProcesses(StopImmediately)
+	proxy.config.MinFreeVRAMGB = 4
+	assert.Equal(t, http.StatusOK, chat("small").Code)
+	w := chat("large")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(LoadErrorInsufficientMemory), gjson.Get(w.Body.String(), "code").String(), w.Body.String())
+	assert.Contains(t, w.Body.String(), "minFreeVRAMGB")
+	assert.Equal(t, StateStopped, proxy.processGroups["G2"].processState("large"))
+
+	// the minimum doesn't apply to a model that is already loaded
+	proxy.config.MinFreeVRAMGB = 2
+	assert.Equal(t, http.StatusOK, chat("large").Code)
+	proxy.config.MinFreeVRAMGB = 100
+	assert.Equal(t, http.StatusOK, chat("large").Code)
+}
+
+func TestProxyManager_NormalizesUsage(t *testing.T) {
+	var response string
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tokenize" {
+			// one token per word
+			body, _ := io.ReadAll(r.Body)
+			words := strings.Fields(gjson.GetBytes(body, "content").String())
+			tokens := make([]int, len(words))
+			json.NewEncoder(w).Encode(map[string]any{"tokens": tokens})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		w.Write([]byte(response))
+	})
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": fakeUpstreamModel(upstream),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	chat := func(body string) gjson.Result {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+		return gjson.Parse(w.Body.String())
+	}
+	request := `{"model": "model1", "messages": [{"role": "user", "content": "how are you"}]}`
+
+	// an upstream without usage or timings, the tokens are counted with /tokenize
+	response = `{"choices": [{"message": {"role": "assistant", "content": "fine thanks"}}]}`
+	result := chat(request)
+	assert.Equal(t, int64(3), result.Get("usage.prompt_tokens").Int())
+	assert.Equal(t, int64(2), result.Get("usage.completion_tokens").Int())
+	assert.Equal(t, int64(5), result.Get("usage.total_tokens").Int())
+	assert.Equal(t, "fine thanks", result.Get("choices.0.message.content").String())
+
+	// other field names and timings are used when they are there
+	response = `{"choices": [{"message": {"content": "fine"}}], "usage": {"input_tokens": 12}, "timings": {"prompt_n": 40, "predicted_n": 7}}`
+	result = chat(request)
+	assert.Equal(t, int64(12), result.Get("usage.prompt_tokens").Int())
+	assert.Equal(t, int64(7), result.Get("usage.completion_tokens").Int())
+	assert.Equal(t, int64(19), result.Get("usage.total_tokens").Int())
+	assert.Equal(t, int64(12), result.Get("usage.input_tokens").Int())
+
+	// complete usage is left alone
+	response = `{"choices": [{"message": {"content": "fine"}}], "usage": {"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 4}}`
+	result = chat(request)
+	assert.Equal(t, int64(4), result.Get("usage.total_tokens").Int())
+}
+
+func TestProxyManager_Webhooks(t *testing.T) {
+	defer func(backoff time.Duration) { webhookRetryBackoff = backoff }(webhookRetryBackoff)
+	webhookRetryBackoff = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	var attempts int
+	received := make(chan gjson.Result, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		// the first delivery fails and is retried
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		received <- gjson.ParseBytes(body)
+	}))
+	defer receiver.Close()
+
+	// retries: 0 is a single attempt
+	var failedAttempts atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failedAttempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": {Cmd: fakeUpstreamCmd, CheckEndpoint: "none"},
+		},
+		Webhooks: []WebhookConfig{{
+			URL:     receiver.URL,
+			Events:  []string{WebhookModelLoadFailed},
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+			Timeout: intPtr(5),
+			Retries: intPtr(2),
+		}, {
+			URL:     failing.URL,
+			Events:  []string{WebhookModelLoadFailed},
+			Retries: intPtr(0),
+		}},
+	})
+	newTestProxyManager(t, config)
+
+	// not subscribed to
+	event.Emit(ProcessHealthChangedEvent{ProcessName: "model1", Healthy: false})
+	event.Emit(ModelLoadFailedEvent{ModelName: "model1", Reason: "out of memory", Code: "oom"})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, WebhookModelLoadFailed, payload.Get("event").String())
+		assert.Equal(t, "model1", payload.Get("data.model").String())
+		assert.Equal(t, "oom", payload.Get("data.code").String())
+		assert.Contains(t, payload.Get("text").String(), "out of memory")
+		assert.True(t, payload.Get("timestamp").Exists())
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	select {
+	case payload := <-received:
+		t.Fatalf("unexpected webhook %s", payload.Get("event").String())
+	case <-time.After(200 * time.Millisecond):
+	}
+	mu.Lock()
+	assert.Equal(t, 2, attempts)
+	mu.Unlock()
+	assert.Equal(t, int32(1), failedAttempts.Load())
+}
+
+func TestProxyManager_BenchmarkModel(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	var requests []gjson.Result
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/completions" {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, gjson.ParseBytes(body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\": [{\"text\": \"the\"}]}\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, "data: {\"choices\": [{\"text\": \" end\"}], \"usage\": {\"prompt_tokens\": 64, \"completion_tokens\": 16}, "+
+			"\"timings\": {\"prompt_n\": 64, \"prompt_ms\": 50, \"prompt_per_second\": 1280, \"predicted_n\": 16, \"predicted_ms\": 400, \"predicted_per_second\": 40}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": fakeUpstreamModel(upstream),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1/benchmark", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/model1/benchmark", strings.NewReader(`{"maxTokens": 0, "promptTokens": -1}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	benchmark := func() gjson.Result {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/model1/benchmark", strings.NewReader(`{"promptTokens": 64, "maxTokens": 16}`)))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return gjson.Parse(w.Body.String())
+	}
+
+	result := benchmark()
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, 64, len(strings.Fields(requests[0].Get("prompt").String())))
+		assert.Equal(t, int64(16), requests[0].Get("max_tokens").Int())
+		assert.True(t, requests[0].Get("stream").Bool())
+	}
+	assert.Equal(t, int64(64), result.Get("benchmark.promptTokens").Int())
+	assert.Equal(t, int64(16), result.Get("benchmark.generatedTokens").Int())
+	assert.Equal(t, 1280.0, result.Get("benchmark.promptTokensPerSecond").Float())
+	assert.Equal(t, 40.0, result.Get("benchmark.generationTokensPerSecond").Float())
+	assert.Less(t, result.Get("benchmark.ttftMs").Int(), result.Get("benchmark.totalMs").Int())
+	assert.Equal(t, fakeUpstreamCmd, result.Get("benchmark.cmd").String())
+	assert.False(t, result.Get("previous").Exists())
+
+	// the benchmark shows up in the metrics like a regular request
+	metrics := proxy.metricsMonitor.GetMetrics()
+	if assert.NotEmpty(t, metrics) {
+		assert.Equal(t, "model1", metrics[len(metrics)-1].Model)
+		assert.Equal(t, 40.0, metrics[len(metrics)-1].TokensPerSecond)
+	}
+
+	// the last run is kept and returned for comparison
+	result = benchmark()
+	assert.Equal(t, 40.0, result.Get("previous.generationTokensPerSecond").Float())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1/benchmark", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(16), gjson.Get(w.Body.String(), "benchmark.generatedTokens").Int())
+}
+
+func TestProxyManager_ModelLimit(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`modelLimits:
+  warn: 1
+  max: 2
+models:
+  model1:
+    cmd: llama-server --port ${PORT} -m one.gguf
+  model2:
+    cmd: llama-server --port ${PORT} -m two.gguf
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ModelLimitsConfig{Warn: 1, Max: 2}, config.ModelLimits)
+	found := false
+	for _, warning := range config.Warnings {
+		found = found || strings.Contains(warning, "more than modelLimits.warn of 1")
+	}
+	assert.True(t, found, config.Warnings)
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	// a third model is beyond the hard limit
+	err = proxy.appendModelToConfig(configPath, "model3", map[string]interface{}{"cmd": "llama-server --port ${PORT} -m three.gguf"})
+	assert.ErrorIs(t, err, errModelLimit)
+	assert.ErrorContains(t, err, "modelLimits.max of 2")
+	data, _ := os.ReadFile(configPath)
+	assert.NotContains(t, string(data), "three.gguf")
+
+	// updating an existing model is not adding one
+	assert.NoError(t, proxy.appendModelToConfig(configPath, "model2", map[string]interface{}{"cmd": "llama-server --port ${PORT} -m two-v2.gguf"}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Accept", "application/json")
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"count":2,"max":2,"warn":1}`, gjson.Get(w.Body.String(), "models").Raw)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, "OK", w.Body.String())
+}
+
+func TestProxyManager_EffectiveConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`healthCheckTimeout: 15
+logLevel: error
+startPort: 9200
+niceness: 5
+macros:
+  server: path/to/server --port ${PORT}
+models:
+  model1:
+    cmd: |
+      # base flags
+      ${server}
+      --model ${MODEL_ID}.gguf
+    aliases: [m1]
+    env: ["CUDA_VISIBLE_DEVICES=0"]
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	proxy.SetConfigPath(configPath)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/config", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, gjson.Get(w.Body.String(), "yaml").String(), "${server}")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/config/effective", nil))
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	model := gjson.Get(w.Body.String(), "models.model1")
+	assert.NotContains(t, model.Get("cmd").String(), "${")
+	assert.Equal(t, `["path/to/server","--port","9200","--model","model1.gguf"]`, model.Get("args").Raw)
+	assert.Equal(t, "http://localhost:9200", model.Get("proxy").String())
+	assert.Equal(t, `["CUDA_VISIBLE_DEVICES=0"]`, model.Get("env").Raw)
+	assert.Equal(t, `["m1"]`, model.Get("aliases").Raw)
+	assert.Equal(t, DEFAULT_GROUP_ID, model.Get("group").String())
+	assert.Equal(t, int64(5), model.Get("niceness").Int())
+	assert.Equal(t, int64(15), model.Get("healthCheckTimeout").Int())
+	assert.Equal(t, int64(10), model.Get("concurrencyLimit").Int())
+	assert.Equal(t, configPath, gjson.Get(w.Body.String(), "configPath").String())
+}
+
+func TestProxyManager_ValidateCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the server binary")
+	}
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "llama-server")
+	assert.NoError(t, os.WriteFile(binary, []byte(`#!/bin/sh
+cat <<'HELP'
+----- common params -----
+
+-h,    --help, --usage                  print usage and exit
+-c,    --ctx-size N                     size of the prompt context (default: 4096)
+-ngl,  --gpu-layers, --n-gpu-layers N   number of layers to store in VRAM
+-m,    --model FNAME                    model path
+--port PORT                             port to listen (default: 8080)
+HELP
+`), 0755))
+	modelPath := filepath.Join(dir, "model.gguf")
+	assert.NoError(t, os.WriteFile(modelPath, []byte("GGUF"), 0644))
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Macros:             map[string]string{"server": binary + " --port ${PORT}"},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	validate := func(cmd string) (int, gjson.Result) {
+		body, _ := json.Marshal(map[string]string{"cmd": cmd})
+		req := httptest.NewRequest("POST", "/api/models/validate-cmd", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w.Code, gjson.Parse(w.Body.String())
+	}
+
+	code, result := validate("${server} \\\n  -m " + modelPath + " -c 8192 -ngl -1 --bogus-flag")
+	if assert.Equal(t, http.StatusOK, code, result.Raw) {
+		assert.True(t, result.Get("valid").Bool(), result.Raw)
+		assert.True(t, result.Get("binaryFound").Bool())
+		assert.True(t, result.Get("modelFound").Bool())
+		assert.True(t, result.Get("hasPort").Bool())
+		assert.True(t, result.Get("flagsChecked").Bool())
+		assert.Equal(t, `["--port","-m","-c","-ngl"]`, result.Get("recognizedFlags").Raw)
+		assert.Equal(t, `["--bogus-flag"]`, result.Get("unknownFlags").Raw)
+		assert.Equal(t, binary+"\n--port ${PORT}\n-m "+modelPath+"\n-c 8192\n-ngl -1\n--bogus-flag", result.Get("normalized").String())
+	}
+
+	missing := filepath.Join(dir, "missing.gguf")
+	code, result = validate("${server} --model " + missing)
+	if assert.Equal(t, http.StatusOK, code, result.Raw) {
+		assert.False(t, result.Get("valid").Bool())
+		assert.False(t, result.Get("modelFound").Bool())
+		assert.Equal(t, missing, result.Get("modelPath").String())
+		assert.Equal(t, "model file "+missing+" not found", result.Get("errors.0").String())
+	}
+
+	code, result = validate(binary + " -m " + modelPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, result.Get("hasPort").Bool())
+	assert.False(t, result.Get("valid").Bool())
+
+	code, _ = validate("${unknown} -m " + modelPath)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestProxyManager_ArchiveThenRestoreOnLoad(t *testing.T) {
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"model1"}`))
+	})
+
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "models", "model1.gguf")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(modelPath), 0755))
+	assert.NoError(t, os.WriteFile(modelPath, []byte("GGUF weights"), 0644))
+
+	modelConfig := fakeUpstreamModel(upstream)
+	modelConfig.Cmd += " -m " + modelPath
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		ArchiveDir:         filepath.Join(dir, "archive"),
+		Models: map[string]ModelConfig{
+			"model1": modelConfig,
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := request("POST", "/api/models/model1/archive", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	archivePath := filepath.Join(dir, "archive", "model1", "model1.gguf")
+	assert.Equal(t, archivePath, gjson.Get(w.Body.String(), "files").Get(gjson.Escape(modelPath)).String())
+	assert.NoFileExists(t, modelPath)
+	assert.FileExists(t, archivePath)
+	assert.True(t, proxy.getModelStatus()[0].Archived)
+
+	w = request("GET", "/api/models/archived", "")
+	assert.Equal(t, "model1", gjson.Get(w.Body.String(), "models.0.modelId").String())
+	assert.Equal(t, int64(len("GGUF weights")), gjson.Get(w.Body.String(), "models.0.sizeBytes").Int())
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/api/models/model1/archive", "").Code)
+
+	// a request restores the file before the model is started
+	w = request("POST", "/v1/chat/completions", `{"model": "model1"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	data, err := os.ReadFile(modelPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "GGUF weights", string(data))
+	assert.NoFileExists(t, archivePath)
+	assert.False(t, proxy.getModelStatus()[0].Archived)
+
+	// a running model's files can't be archived
+	assert.Equal(t, http.StatusConflict, request("POST", "/api/models/model1/archive", "").Code)
+}
+
+func TestProxyManager_ConcurrentGenerationIsRejected(t *testing.T) {
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	defer proxy.StopProcesses(StopImmediately)
+
+	progressMgr := autosetup.GetProgressManager()
+	jobID, err := progressMgr.BeginGeneration("test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer progressMgr.EndGeneration(jobID)
+
+	for path, body := range map[string]string{
+		"/api/config/generate-all":       `{"folderPath": "/models"}`,
+		"/api/config/regenerate-from-db": `{}`,
+	} {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusConflict, w.Code, path)
+		assert.Equal(t, "generation already in progress", gjson.Get(w.Body.String(), "error").String(), path)
+		assert.Equal(t, jobID, gjson.Get(w.Body.String(), "jobId").String(), path)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/setup/progress", nil))
+	assert.Equal(t, jobID, gjson.Get(w.Body.String(), "job_id").String())
+}
+
+func TestProxyManager_HFMetadata(t *testing.T) {
+	ggufPath := filepath.Join(t.TempDir(), "model-Q4_K_M.gguf")
+	writeTestGGUF(t, ggufPath, map[string]interface{}{
+		"general.architecture": "qwen3",
+		"qwen3.context_length": uint32(40960),
+		"qwen3.block_count":    uint32(36),
+	})
+
+	var rangeRequested string
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/owner/model-GGUF/resolve/main/model-Q4_K_M.gguf" {
+			http.NotFound(w, r)
+			return
+		}
+		rangeRequested = r.Header.Get("Range")
+		http.ServeFile(w, r, ggufPath)
+	}))
+	defer hf.Close()
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/hf-metadata?repo=owner/model-GGUF&file=model-Q4_K_M.gguf", nil))
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	assert.NotEmpty(t, rangeRequested)
+	body := gjson.Parse(w.Body.String())
+	assert.Equal(t, "qwen3", body.Get("architecture").String())
+	assert.Equal(t, int64(40960), body.Get("contextLength").Int())
+	assert.Equal(t, int64(36), body.Get("blockCount").Int())
+	// the test file has no general.file_type
+	assert.Equal(t, "Q4_K_M", body.Get("quantization").String())
+	assert.Equal(t, "filename", body.Get("quantizationSource").String())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/hf-metadata?repo=owner/model-GGUF&file=missing.gguf", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/hf-metadata?repo=owner/model-GGUF&file=README.md", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestProxyManager_DownloadBestFitPicksQuantPerVRAM(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	// 32 layers of 8 KV heads with 128 wide keys and values: 4GB of f16 KV cache at 32K context
+	ggufPath := filepath.Join(t.TempDir(), "model-Q3_K_M.gguf")
+	writeTestGGUF(t, ggufPath, map[string]interface{}{
+		"general.architecture":          "llama",
+		"llama.block_count":             uint32(32),
+		"llama.attention.head_count_kv": uint32(8),
+		"llama.attention.key_length":    uint32(128),
+		"llama.attention.value_length":  uint32(128),
+	})
+
+	var metadataRequested string
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/models/owner/model-GGUF":
+			json.NewEncoder(w).Encode(gin.H{
+				"id": "owner/model-GGUF",
+				"siblings": []gin.H{
+					{"rfilename": "model-Q4_K_M.gguf", "size": 4 * gb},
+					{"rfilename": "Q8_0/model-Q8_0-00002-of-00002.gguf", "size": 3 * gb},
+					{"rfilename": "Q8_0/model-Q8_0-00001-of-00002.gguf", "size": 5 * gb},
+					{"rfilename": "model-Q3_K_M.gguf", "size": 3 * gb},
+					{"rfilename": "model-Q6_K.gguf", "size": 6 * gb},
+					{"rfilename": "mmproj-model-f16.gguf", "size": gb / 2},
+				},
+			})
+		case "/owner/model-GGUF/resolve/main/model-Q3_K_M.gguf":
+			metadataRequested = r.Header.Get("Range")
+			http.ServeFile(w, r, ggufPath)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer hf.Close()
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	bestFit := func(vramGB float64) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"repo": "owner/model-GGUF", "contextSize": 32768, "vramGB": %g, "dryRun": true}`, vramGB)
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/download-best-fit", strings.NewReader(body)))
+		return w
+	}
+
+	// weights + 4GB KV cache + 2GB overhead
+	for _, tier := range []struct {
+		vramGB float64
+		quant  string
+		files  []string
+	}{
+		{10, "Q4_K_M", []string{"model-Q4_K_M.gguf"}},
+		{12.5, "Q6_K", []string{"model-Q6_K.gguf"}},
+		{24, "Q8_0", []string{"Q8_0/model-Q8_0-00001-of-00002.gguf", "Q8_0/model-Q8_0-00002-of-00002.gguf"}},
+	} {
+		w := bestFit(tier.vramGB)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			continue
+		}
+		body := w.Body.String()
+		assert.Equal(t, tier.quant, gjson.Get(body, "quantization").String(), "%g GB", tier.vramGB)
+		var files []string
+		for _, file := range gjson.Get(body, "files").Array() {
+			files = append(files, file.String())
+		}
+		assert.Equal(t, tier.files, files)
+		assert.Equal(t, 4.0, gjson.Get(body, "chosen.kvCacheGB").Float())
+		assert.NotEmpty(t, gjson.Get(body, "reasoning").Array())
+		assert.False(t, gjson.Get(body, "downloadIds").Exists())
+	}
+	assert.Equal(t, "bytes=0-1048575", metadataRequested)
+
+	w := bestFit(8)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "Q3_K_M", gjson.Get(w.Body.String(), "candidates.0.quantization").String())
+	assert.Equal(t, 9.0, gjson.Get(w.Body.String(), "candidates.0.totalGB").Float())
+}
+
+func TestProxyManager_PreloadInPriorityOrder(t *testing.T) {
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	configStr := strings.NewReplacer("${upstream}", upstream.URL, "${cmd}", fakeUpstreamCmd).Replace(`
+healthCheckTimeout: 15
+logLevel: error
+groups:
+  together:
+    swap: false
+    members: [chat, embed, rerank]
+models:
+  chat:
+    cmd: ${cmd}
+    proxy: ${upstream}
+    preloadPriority: 10
+  embed:
+    cmd: ${cmd}
+    proxy: ${upstream}
+  rerank:
+    cmd: ${cmd}
+    proxy: ${upstream}
+    preloadPriority: 5
+hooks:
+  on_startup:
+    preload: [embed, rerank, chat]
+`)
+	config, err := LoadConfigFromReader(strings.NewReader(configStr))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	preloaded := make(chan ModelPreloadedEvent, 3)
+	unsub := event.On(func(e ModelPreloadedEvent) {
+		preloaded <- e
+	})
+	defer unsub()
+
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-preloaded:
+			assert.True(t, e.Success, e.ModelName)
+			order = append(order, e.ModelName)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for models to preload")
+		}
+	}
+	assert.Equal(t, []string{"chat", "rerank", "embed"}, order)
+}
+
+func TestProxyManager_PreloadBatches(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	config := Config{
+		Models: map[string]ModelConfig{
+			"chat":   {PreloadPriority: 10},
+			"embed":  {PreloadPriority: 10},
+			"rerank": {PreloadPriority: 10},
+			"vision": {PreloadPriority: 10},
+			"code":   {PreloadPriority: 10},
+			"big":    {PreloadPriority: 10},
+			"spare":  {},
+		},
+		Groups: map[string]GroupConfig{
+			"together": {Swap: false, Members: []string{"chat", "embed", "big", "spare"}},
+			"swapped":  {Swap: true, Members: []string{"rerank", "vision"}},
+			"solo":     {Swap: true, Exclusive: true, Members: []string{"code"}},
+		},
+	}
+	sizes := map[string]uint64{"chat": 8 * gb, "embed": gb, "rerank": gb, "vision": 2 * gb, "code": 4 * gb, "big": 20 * gb, "spare": gb}
+	sizeOf := func(modelID string) uint64 { return sizes[modelID] }
+
+	batches := preloadBatches([]string{"chat", "embed", "rerank", "vision", "code", "big", "spare"}, config, sizeOf, 16*gb)
+	assert.Equal(t, [][]string{
+		{"chat", "embed", "rerank"}, // vision shares the swap group of rerank
+		{"vision"},                  // code's group is exclusive
+		{"code"},
+		{"big"}, // over the budget together with anything, loaded on its own
+		{"spare"},
+	}, batches)
+
+	assert.Equal(t, [][]string{{"chat"}, {"embed"}}, preloadBatches([]string{"chat", "embed"}, config, sizeOf, 0))
+}
+
+func TestProxyManager_SymlinkedModelIsSameFile(t *testing.T) {
+	dir := t.TempDir()
+	realPath := filepath.Join(dir, "models", "model-Q4_K_M.gguf")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(realPath), 0755))
+	assert.NoError(t, os.WriteFile(realPath, []byte("GGUF"), 0644))
+	otherPath := filepath.Join(dir, "models", "other-Q4_K_M.gguf")
+	assert.NoError(t, os.WriteFile(otherPath, []byte("GGUF other"), 0644))
+	linkPath := filepath.Join(dir, "link-Q4_K_M.gguf")
+	if err := os.Symlink(realPath, linkPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	hardlinkPath := filepath.Join(dir, "hardlink-Q4_K_M.gguf")
+	assert.NoError(t, os.Link(realPath, hardlinkPath))
+
+	configPath := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf(`healthCheckTimeout: 15
+models:
+  model-a:
+    cmd: |
+      llama-server --port ${PORT}
+      --model %s
+  model-b:
+    cmd: |
+      llama-server --port ${PORT}
+      --model %s
+  model-c:
+    cmd: |
+      llama-server --port ${PORT}
+      --model %s
+  other:
+    cmd: |
+      llama-server --port ${PORT}
+      --model %s
+groups:
+  all:
+    members: [model-a, model-b, model-c, other]
+`, realPath, linkPath, hardlinkPath, otherPath)), 0644))
+
+	config, err := LoadConfig(configPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	// the symlink and the hardlink reach the file of model-a
+	assert.True(t, sameModelFile(linkPath, realPath))
+	assert.True(t, sameModelFile(hardlinkPath, realPath))
+	assert.False(t, sameModelFile(otherPath, realPath))
+	assert.Equal(t, "other", proxy.findModelByFilePath(otherPath))
+	delete(proxy.config.Models, "model-b")
+	delete(proxy.config.Models, "model-c")
+	assert.Equal(t, "model-a", proxy.findModelByFilePath(linkPath))
+
+	removed, err := proxy.cleanupDuplicateModels(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	config, err = LoadConfig(configPath)
+	if assert.NoError(t, err) {
+		assert.Contains(t, config.Models, "model-a")
+		assert.Contains(t, config.Models, "other")
+		assert.NotContains(t, config.Models, "model-b")
+		assert.NotContains(t, config.Models, "model-c")
+	}
+}
+
+func TestProxyManager_ReadOnlyMode(t *testing.T) {
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"responseMessage":"chat"}`))
+	})
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		ReadOnlyMode:       true,
+		Models: map[string]ModelConfig{
+			"chat": fakeUpstreamModel(upstream),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	blocked := []struct{ method, path string }{
+		{"POST", "/api/config"},
+		{"POST", "/api/config/model/chat"},
+		{"POST", "/api/config/append-model"},
+		{"DELETE", "/api/config/models/chat"},
+		{"POST", "/api/config/folders"},
+		{"DELETE", "/api/config/folders"},
+		{"POST", "/api/config/regenerate-from-db"},
+		{"POST", "/api/models/download"},
+		{"POST", "/api/models/download/cancel"},
+		{"POST", "/api/models/orphans/delete"},
+		{"POST", "/api/settings/system"},
+		{"POST", "/api/server/restart"},
+		{"POST", "/api/server/restart/hard"},
+		{"POST", "/api/binary/update"},
+	}
+	for _, endpoint := range blocked {
+		w := request(endpoint.method, endpoint.path, `{}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", endpoint.method, endpoint.path)
+		assert.Contains(t, w.Body.String(), "read-only mode")
+	}
+
+	// reading and checking still work
+	assert.Equal(t, http.StatusOK, request("GET", "/api/config/profiles", "").Code)
+	assert.Equal(t, http.StatusOK, request("GET", "/api/models/downloads", "").Code)
+	assert.NotEqual(t, http.StatusForbidden, request("POST", "/api/models/validate-cmd", `{"cmd": "llama-server --model /m.gguf"}`).Code)
+
+	// inference still works
+	w := request("POST", "/v1/chat/completions", `{"model": "chat", "messages": [{"role": "user", "content": "hi"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "chat", gjson.Get(w.Body.String(), "responseMessage").String())
+}
+
+func TestProxyManager_ModelLogs(t *testing.T) {
+	// the cmd writes a line for the log before it keeps running
+	modelConfig := fakeUpstreamModel(newFakeUpstream(t, nil))
+	modelConfig.Cmd = `sh -c "echo loading model1; exec sleep 60"`
+
+	logDir := filepath.Join(t.TempDir(), "logs")
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		ModelLogs:          ModelLogsConfig{Enabled: true, Dir: logDir},
+		Models: map[string]ModelConfig{
+			"model1:q4": modelConfig,
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/upstream/model1:q4/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	logFile := filepath.Join(logDir, "model1_q4.log")
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(logFile)
+		return err == nil && strings.Contains(string(data), "loading model1\n")
+	}, 5*time.Second, 50*time.Millisecond)
+	data, _ := os.ReadFile(logFile)
+	assert.Contains(t, string(data), "starting model1:q4: sh -c")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1:q4/logs", nil))
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		result := gjson.Parse(w.Body.String())
+		assert.True(t, result.Get("enabled").Bool())
+		assert.Equal(t, logFile, result.Get("path").String())
+		assert.Contains(t, result.Get("output").String(), "loading model1")
+	}
+}
+
+func TestModelLogFile_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	logFile := newModelLogFile(ModelLogsConfig{Enabled: true, Dir: dir, MaxFiles: 2}, "model1")
+	logFile.maxBytes = 10
+	defer logFile.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		n, err := logFile.Write([]byte(line))
+		assert.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read("model1.log"))
+	assert.Equal(t, "third\n", read("model1.log.1"))
+	assert.Equal(t, "second\n", read("model1.log.2"))
+	assert.NoFileExists(t, filepath.Join(dir, "model1.log.3"))
+	assert.Len(t, logFile.rotated(), 2)
+
+	assert.Nil(t, newModelLogFile(ModelLogsConfig{}, "model1"))
+}
+
+func TestProxyManager_OptimizeBinary(t *testing.T) {
+	defer func(load func() (*autosetup.BinaryMetadata, error)) { loadInstalledBinary = load }(loadInstalledBinary)
+	defer func(detect func() (autosetup.SystemInfo, error)) { detectBinarySystem = detect }(detectBinarySystem)
+	defer func(latest func() (string, error)) { latestBinaryVersion = latest }(latestBinaryVersion)
+	defer func(optimal func(autosetup.SystemInfo, string) (string, error)) { optimalBinaryType = optimal }(optimalBinaryType)
+	defer func(replace func(string, autosetup.SystemInfo, string) (*autosetup.BinaryInfo, error)) {
+		replaceBinary = replace
+	}(replaceBinary)
+
+	installed := &autosetup.BinaryMetadata{Type: "cpu", Version: "b6000"}
+	loadInstalledBinary = func() (*autosetup.BinaryMetadata, error) { return installed, nil }
+	cudaSystem := autosetup.SystemInfo{OS: "linux", Architecture: "amd64", HasCUDA: true}
+	detectBinarySystem = func() (autosetup.SystemInfo, error) { return cudaSystem, nil }
+	latestBinaryVersion = func() (string, error) { return "b6527", nil }
+	optimalBinaryType = func(system autosetup.SystemInfo, version string) (string, error) {
+		assert.Equal(t, cudaSystem, system)
+		assert.Equal(t, "b6527", version)
+		return "cuda", nil
+	}
+	var replaced []string
+	replaceBinary = func(downloadDir string, system autosetup.SystemInfo, backend string) (*autosetup.BinaryInfo, error) {
+		replaced = append(replaced, backend)
+		if backend == "vulkan" {
+			return nil, fmt.Errorf("download failed")
+		}
+		installed = &autosetup.BinaryMetadata{Type: backend, Version: "b6527"}
+		return &autosetup.BinaryInfo{Type: backend, Version: "b6527", Path: "binaries/llama-server/llama-server"}, nil
+	}
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	defer proxy.StopProcesses(StopImmediately)
+
+	optimize := func() (int, gjson.Result) {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/binary/optimize", nil))
+		return w.Code, gjson.Parse(w.Body.String())
+	}
+
+	code, result := optimize()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "optimized", result.Get("status").String())
+	assert.Equal(t, "cpu", result.Get("previousType").String())
+	assert.Equal(t, "cuda", result.Get("type").String())
+	assert.Equal(t, []string{"cuda"}, replaced)
+
+	// the cuda build is in place now, nothing to do
+	code, result = optimize()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "optimal", result.Get("status").String())
+	assert.Equal(t, []string{"cuda"}, replaced)
+
+	// a failed replacement reports the build that stays installed
+	optimalBinaryType = func(autosetup.SystemInfo, string) (string, error) { return "vulkan", nil }
+	code, result = optimize()
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "cuda", result.Get("previousType").String())
+	assert.Equal(t, []string{"cuda", "vulkan"}, replaced)
+}
+
+func TestProxyManager_RecoversFromPanics(t *testing.T) {
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	defer proxy.StopProcesses(StopImmediately)
+
+	proxy.ginEngine.GET("/test/panic", func(c *gin.Context) {
+		var models map[string]ModelConfig
+		models["model1"] = ModelConfig{}
+	})
+	proxy.ginEngine.GET("/test/panic-after-write", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/test/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error": "internal server error"}`, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "goroutine")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/test/panic-after-write", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+
+	// the server keeps serving
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Panics(t, func() {
+		proxy.ginEngine.GET("/test/abort", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/abort", nil))
+	})
+}
+
+func TestProxyManager_SwapCooldown(t *testing.T) {
+	upstream := newFakeUpstream(t, nil)
+
+	// alternating requests for two models of a swap group for a while, returns
+	// how often a model was started
+	alternate := func(swapCooldown int) int {
+		config := AddDefaultGroupToConfig(Config{
+			HealthCheckTimeout: 15,
+			LogLevel:           "error",
+			SwapCooldown:       swapCooldown,
+			Models: map[string]ModelConfig{
+				"model1": fakeUpstreamModel(upstream),
+				"model2": fakeUpstreamModel(upstream),
+			},
+		})
+		proxy := newTestProxyManager(t, config)
+		defer proxy.StopProcesses(StopImmediately)
+
+		var starts atomic.Int32
+		defer event.On(func(e ProcessStateChangeEvent) {
+			if e.NewState == StateStarting {
+				starts.Add(1)
+			}
+		})()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+		defer cancel()
+		var wg sync.WaitGroup
+		for _, model := range []string{"model1", "model2"} {
+			wg.Add(1)
+			go func(model string) {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					req := httptest.NewRequest("GET", "/upstream/"+model+"/health", nil).WithContext(ctx)
+					proxy.ServeHTTP(httptest.NewRecorder(), req)
+					time.Sleep(10 * time.Millisecond)
+				}
+			}(model)
+		}
+		wg.Wait()
+		return int(starts.Load())
+	}
+
+	var deferred atomic.Int32
+	defer event.On(func(e SwapDeferredEvent) {
+		deferred.Add(1)
+	})()
+
+	withoutCooldown := alternate(0)
+	assert.Zero(t, deferred.Load())
+
+	withCooldown := alternate(1)
+	assert.LessOrEqual(t, withCooldown, 2)
+	assert.Less(t, withCooldown, withoutCooldown)
+	assert.NotZero(t, deferred.Load())
+}
+
+func TestProxyManager_RequestID(t *testing.T) {
+	var upstreamIDs []string
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			upstreamIDs = append(upstreamIDs, r.Header.Get("X-Request-ID"))
+		}
+	})
+
+	model1 := fakeUpstreamModel(upstream)
+	model1.Filters.StripParams = "temperature"
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "debug",
+		Models: map[string]ModelConfig{
+			"model1": model1,
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	// an incoming ID is kept
+	req := httptest.NewRequest("GET", "/upstream/model1/props", nil)
+	req.Header.Set("X-Request-ID", "trace-4f2a9c")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "trace-4f2a9c", w.Header().Get("X-Request-ID"))
+	assert.Contains(t, string(proxy.proxyLogger.GetHistory()), "[trace-4f2a9c]")
+
+	// one is generated when there is none or it is unusable
+	for _, incoming := range []string{"", "has spaces\nand a newline", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest("GET", "/upstream/model1/props", nil)
+		if incoming != "" {
+			req.Header.Set("X-Request-ID", incoming)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		id := w.Header().Get("X-Request-ID")
+		assert.Len(t, id, 16)
+		assert.NotEqual(t, incoming, id)
+		assert.Contains(t, string(proxy.proxyLogger.GetHistory()), "["+id+"]")
+	}
+
+	// the logs of the request handling are tagged too
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","temperature":0.2}`))
+	req.Header.Set("X-Request-ID", "trace-strip")
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, string(proxy.proxyLogger.GetHistory()), "<model1> [trace-strip] stripping param: temperature")
+
+	// the upstream got the same IDs the client did
+	if assert.Len(t, upstreamIDs, 5) {
+		assert.Equal(t, "trace-4f2a9c", upstreamIDs[0])
+		assert.NotEqual(t, upstreamIDs[1], upstreamIDs[2])
+	}
+
+	// API endpoints return one too
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Len(t, w.Header().Get("X-Request-ID"), 16)
+}
+
+func TestProxyManager_RemoteModel(t *testing.T) {
+	var served atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			served.Add(1)
+		}
+	}))
+	defer remote.Close()
+	upstream := newFakeUpstream(t, nil)
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"local":  fakeUpstreamModel(upstream),
+			"remote": {Proxy: remote.URL, CheckEndpoint: "/health"},
+		},
+		Groups: map[string]GroupConfig{
+			"remotes": {Swap: true, Exclusive: true, Members: []string{"remote"}},
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	for _, model := range []string{"local", "remote"} {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/upstream/"+model+"/v1/models", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int32(1), served.Load())
+
+	// no process was spawned for the remote model, and loading it left the local
+	// model of the other group running
+	remoteProcess := proxy.findGroupByModelName("remote").processes["remote"]
+	assert.Equal(t, StateReady, remoteProcess.CurrentState())
+	assert.Nil(t, remoteProcess.cmd)
+	assert.Equal(t, StateReady, proxy.findGroupByModelName("local").processes["local"].CurrentState())
+
+	remoteProcess.Stop()
+	assert.Equal(t, StateStopped, remoteProcess.CurrentState())
+}
+
+func TestProxyManager_AutoDownloadAllowlist(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	data, err := json.Marshal(SystemSettings{
+		AutoDownloadAllowlist: []string{"bartowski/*", "unsloth/Qwen3-*-GGUF"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models:             map[string]ModelConfig{},
+	}))
+
+	// allowed repos, with or without a file or quantization
+	for _, modelID := range []string{"bartowski/Llama-3.2-3B-Instruct-GGUF", "BARTOWSKI/gemma-2-9b-it-GGUF:q4_k_m", "unsloth/Qwen3-8B-GGUF:Qwen3-8B-Q4_K_M.gguf"} {
+		assert.NoError(t, proxy.checkAutoDownloadAllowed(modelID), modelID)
+	}
+
+	// blocked repos are refused before anything is downloaded
+	for _, modelID := range []string{"someone/Llama-3.2-3B-Instruct-GGUF", "unsloth/Llama-3.2-3B-GGUF"} {
+		assert.ErrorIs(t, proxy.checkAutoDownloadAllowed(modelID), errAutoDownloadNotAllowed, modelID)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"someone/evil-GGUF:q4_k_m"}`)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "someone/evil-GGUF does not match the autoDownloadAllowlist")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/models/load", bytes.NewBufferString(`{"model_id":"someone/evil-GGUF","auto_unload":true}`)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// saving settings without an allowlist keeps it, an invalid pattern is refused
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/system", bytes.NewBufferString(`{"backend":"cpu","vramGB":8,"ramGB":16,"preferredContext":4096}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	settings, err := proxy.loadSavedSystemSettings()
+	if assert.NoError(t, err) && assert.NotNil(t, settings) {
+		assert.Equal(t, []string{"bartowski/*", "unsloth/Qwen3-*-GGUF"}, settings.AutoDownloadAllowlist)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/system", bytes.NewBufferString(`{"autoDownloadAllowlist":["bartowski/[abc"]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "autoDownloadAllowlist[0]")
+}
+
+func TestProxyManager_TryParamsIsEphemeral(t *testing.T) {
+	upstream := newFakeUpstream(t, nil)
+	modelConfig := fakeUpstreamModel(upstream)
+	modelConfig.Cmd += "\n--cache-type-k f16\n--cache-type-v f16"
+
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": modelConfig,
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/model1/try-params", strings.NewReader(`{"cacheType": "q9_0"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/model1/try-params", strings.NewReader(`{"cacheTypeK": "q8_0", "cacheTypeV": "q4_0"}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "q8_0", gjson.Get(w.Body.String(), "cacheTypeK").String())
+	assert.Equal(t, "q4_0", gjson.Get(w.Body.String(), "cacheTypeV").String())
+	assert.False(t, gjson.Get(w.Body.String(), "persisted").Bool())
+
+	process := proxy.findGroupByModelName("model1").processes["model1"]
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.Contains(t, strings.Join(process.cmd.Args, " "), "--cache-type-k q8_0 --cache-type-v q4_0")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1/try-params", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, gjson.Get(w.Body.String(), "overridden").Bool())
+	assert.Equal(t, "f16", gjson.Get(w.Body.String(), "config.cacheTypeK").String())
+
+	// the next normal load uses the config again
+	process.Stop()
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/upstream/model1/v1/models", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, strings.Join(process.cmd.Args, " "), "--cache-type-k f16 --cache-type-v f16")
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/model1/try-params", nil))
+	assert.False(t, gjson.Get(w.Body.String(), "overridden").Bool())
+	assert.Equal(t, "f16", gjson.Get(w.Body.String(), "cacheTypeK").String())
+}
+
+func TestGPUThrottleReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   autosetup.GPUThermalSample
+		expected string
+	}{
+		{"boosting under load", autosetup.GPUThermalSample{Utilization: 98, SMClock: 2520, BaseClock: 2235, Temperature: 70}, ""},
+		{"below base under load", autosetup.GPUThermalSample{Utilization: 97, SMClock: 1095, BaseClock: 1410, Temperature: 88}, "SM clock 1095 MHz below base 1410 MHz at 88°C"},
+		{"thermal slowdown", autosetup.GPUThermalSample{Utilization: 100, SMClock: 1800, BaseClock: 1410, Temperature: 92, ThermalSlowdown: true}, "thermal slowdown at 92°C, SM clock 1800 MHz"},
+		{"idle clocks down", autosetup.GPUThermalSample{Utilization: 3, SMClock: 210, BaseClock: 1410, Temperature: 40}, ""},
+		{"unknown base clock", autosetup.GPUThermalSample{Utilization: 90, SMClock: 1200, Temperature: 80}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, gpuThrottleReason(tt.sample))
+		})
+	}
+}
+
+func TestProxyManager_GPUThrottlingDuringGeneration(t *testing.T) {
+	config := AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+	proxy := newTestProxyManager(t, config)
+	proxy.metricsMonitor.ActivityStats = NewActivityStatsManager(filepath.Join(t.TempDir(), "activity_stats.json"))
+
+	var samples []autosetup.GPUThermalSample
+	proxy.gpuThermal.query = func() ([]autosetup.GPUThermalSample, error) {
+		return samples, nil
+	}
+	events := make(chan GPUThrottlingEvent, 10)
+	defer event.On(func(e GPUThrottlingEvent) {
+		events <- e
+	})()
+	nextEvent := func() GPUThrottlingEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no GPUThrottlingEvent")
+			return GPUThrottlingEvent{}
+		}
+	}
+
+	cool := autosetup.GPUThermalSample{Index: 0, Name: "NVIDIA RTX 4090", Utilization: 98, SMClock: 2520, BaseClock: 2235, Temperature: 70}
+	hot := autosetup.GPUThermalSample{Index: 0, Name: "NVIDIA RTX 4090", Utilization: 98, SMClock: 1800, BaseClock: 2235, Temperature: 89}
+
+	samples = []autosetup.GPUThermalSample{cool}
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+
+	// throttling for two samples is one event and 10s throttled
+	samples = []autosetup.GPUThermalSample{hot}
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+
+	// after recovering, throttling again is a new event
+	samples = []autosetup.GPUThermalSample{cool}
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+	samples = []autosetup.GPUThermalSample{hot}
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+
+	first := nextEvent()
+	assert.Equal(t, "NVIDIA RTX 4090", first.Name)
+	assert.Equal(t, []string{"model1"}, first.Models)
+	assert.Equal(t, "SM clock 1800 MHz below base 2235 MHz at 89°C", first.Reason)
+	nextEvent()
+
+	stats, found := proxy.metricsMonitor.ActivityStats.GetModelStats("model1")
+	assert.True(t, found)
+	assert.Equal(t, int64(2), stats.ThrottleEvents)
+	assert.Equal(t, int64(15000), stats.ThrottledMs)
+	assert.Equal(t, int64(2), proxy.metricsMonitor.ActivityStats.GetGlobalStats().ThrottleEvents)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/system/gpu-thermal", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1800), gjson.Get(w.Body.String(), "samples.0.smClock").Int())
+	assert.Equal(t, "NVIDIA RTX 4090", gjson.Get(w.Body.String(), "throttled.0.name").String())
+
+	// nothing generating, the next throttling is a new event
+	proxy.gpuThermal.idle()
+	proxy.checkGPUThermal([]string{"model1"}, 5*time.Second)
+	nextEvent()
+	assert.Empty(t, events)
+}
+
+func TestProxyManager_QuantizationPreference(t *testing.T) {
+	dir := useTempFilePaths(t)
+
+	data, err := json.Marshal(SystemSettings{QuantizationPreference: []string{"Q8_0", "Q4_K_M"}})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644))
+
+	const gb = 1024 * 1024 * 1024
+	siblings := []gin.H{
+		{"rfilename": "mmproj-model-f16.gguf", "size": gb / 2},
+		{"rfilename": "model-Q4_K_M.gguf", "size": 4 * gb},
+		{"rfilename": "model-Q6_K.gguf", "size": 6 * gb},
+		{"rfilename": "Q8_0/model-Q8_0-00002-of-00002.gguf", "size": 3 * gb},
+		{"rfilename": "Q8_0/model-Q8_0-00001-of-00002.gguf", "size": 5 * gb},
+	}
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/models":
+			json.NewEncoder(w).Encode([]gin.H{{"id": "owner/model-GGUF", "siblings": siblings}})
+		case "/api/models/owner/model-GGUF":
+			json.NewEncoder(w).Encode(gin.H{"id": "owner/model-GGUF", "siblings": siblings})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer hf.Close()
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	// search ranks the preferred quantization first
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/search?q=model", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	result := gjson.Get(w.Body.String(), "models.0")
+	assert.Equal(t, "q8_0", result.Get("recommendedQuantization").String())
+	assert.Equal(t, "q8_0", result.Get("ggufFiles.0.quantization").String())
+	assert.Equal(t, "q4_k_m", result.Get("ggufFiles.1.quantization").String())
+	assert.Equal(t, "f16", result.Get("ggufFiles.3.quantization").String(), "vision projectors rank last")
+
+	// auto-download picks the same quantization, every shard of it
+	searchResults, err := proxy.searchHuggingFaceModel("owner/model-GGUF", "", 50)
+	if assert.NoError(t, err) {
+		var files []string
+		for _, file := range proxy.quantPreference().preferredFiles(searchResults.GGUFFiles) {
+			files = append(files, file.Filename)
+		}
+		assert.Equal(t, []string{"Q8_0/model-Q8_0-00001-of-00002.gguf", "Q8_0/model-Q8_0-00002-of-00002.gguf"}, files)
+	}
+
+	// best fit picks the most preferred quantization that fits over the largest
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/models/download-best-fit", strings.NewReader(`{"repo": "owner/model-GGUF", "contextSize": 4096, "vramGB": 9, "dryRun": true}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Q4_K_M", gjson.Get(w.Body.String(), "quantization").String())
+
+	// saving settings without a preference keeps it, an unknown quantization is refused
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/system", bytes.NewBufferString(`{"backend":"cpu","vramGB":8,"ramGB":16,"preferredContext":4096}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	settings, err := proxy.loadSavedSystemSettings()
+	if assert.NoError(t, err) && assert.NotNil(t, settings) {
+		assert.Equal(t, []string{"Q8_0", "Q4_K_M"}, settings.QuantizationPreference)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "/api/settings/system", bytes.NewBufferString(`{"quantizationPreference":["Q4_K_M","best"]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "quantizationPreference[1]")
+}
+
+func TestProxyManager_PredictivePreloadSelectsMostUsed(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	config := Config{
+		Models: map[string]ModelConfig{
+			"chat":   {Cmd: "llama-server"},
+			"code":   {Cmd: "llama-server"},
+			"embed":  {Cmd: "llama-server"},
+			"rerank": {Cmd: "llama-server"},
+			"vision": {Cmd: "llama-server"},
+			"big":    {Cmd: "llama-server"},
+			"remote": {Proxy: "http://other-host:8080"},
+		},
+		Groups: map[string]GroupConfig{
+			"together": {Swap: false, Members: []string{"chat", "embed", "big", "remote"}},
+			"swapped":  {Swap: true, Members: []string{"rerank", "vision"}},
+			"solo":     {Swap: true, Exclusive: true, Members: []string{"code"}},
+		},
+	}
+	sizes := map[string]uint64{"chat": 8 * gb, "code": 4 * gb, "embed": gb, "rerank": gb, "vision": 2 * gb, "big": 20 * gb}
+	sizeOf := func(modelID string) uint64 { return sizes[modelID] }
+	stats := map[string]*ActivityStats{
+		"remote":  {ModelID: "remote", RequestCount: 900},
+		"big":     {ModelID: "big", RequestCount: 500},
+		"chat":    {ModelID: "chat", RequestCount: 400},
+		"code":    {ModelID: "code", RequestCount: 300},
+		"rerank":  {ModelID: "rerank", RequestCount: 200},
+		"vision":  {ModelID: "vision", RequestCount: 100},
+		"embed":   {ModelID: "embed", RequestCount: 50},
+		"removed": {ModelID: "removed", RequestCount: 1000},
+	}
+
+	// remote and removed models aren't loaded, big doesn't fit, code's group is
+	// exclusive and stops chat
+	assert.Equal(t, []string{"chat", "rerank"}, selectPredictivePreloads(stats, config, nil, sizeOf, 16*gb, 2))
+	// vision shares the swap group of rerank
+	assert.Equal(t, []string{"chat", "rerank", "embed"}, selectPredictivePreloads(stats, config, nil, sizeOf, 16*gb, 3))
+
+	// running models count towards topN and are never stopped
+	assert.Equal(t, []string{"chat"}, selectPredictivePreloads(stats, config, map[string]bool{"vision": true}, sizeOf, 16*gb, 2))
+	assert.Empty(t, selectPredictivePreloads(stats, config, map[string]bool{"code": true}, sizeOf, 16*gb, 2))
+
+	assert.Empty(t, selectPredictivePreloads(stats, config, nil, sizeOf, 0, 2))
+}
+
+func TestProxyManager_SearchCache(t *testing.T) {
+	var searches atomic.Int32
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searches.Add(1)
+		json.NewEncoder(w).Encode([]gin.H{{"id": "owner/" + r.URL.Query().Get("search"), "siblings": []gin.H{{"rfilename": "model-Q4_K_M.gguf", "size": 1024}}}})
+	}))
+	defer hf.Close()
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	search := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	w := search("/api/models/search?q=qwen")
+	assert.False(t, gjson.Get(w.Body.String(), "cached").Bool())
+	assert.Equal(t, int32(1), searches.Load())
+
+	// the same normalized query within the TTL is not searched again
+	w = search("/api/models/search?q=%20Qwen%20")
+	assert.True(t, gjson.Get(w.Body.String(), "cached").Bool())
+	assert.Equal(t, "owner/qwen GGUF", gjson.Get(w.Body.String(), "models.0.id").String())
+	assert.Equal(t, int32(1), searches.Load())
+
+	// other parameters are another search
+	search("/api/models/search?q=qwen&limit=5")
+	assert.Equal(t, int32(2), searches.Load())
+
+	// refresh bypasses the cache
+	w = search("/api/models/search?q=qwen&refresh=true")
+	assert.False(t, gjson.Get(w.Body.String(), "cached").Bool())
+	assert.Equal(t, int32(3), searches.Load())
+
+	// expired searches go to HuggingFace again
+	proxy.searchCache.ttl = 0
+	search("/api/models/search?q=qwen")
+	assert.Equal(t, int32(4), searches.Load())
+}
+
+func TestProxyManager_HTTPProxy(t *testing.T) {
+	var proxiedHosts []string
+	var mu sync.Mutex
+	outboundProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		mu.Unlock()
+		json.NewEncoder(w).Encode([]gin.H{{"id": "owner/qwen-GGUF", "siblings": []gin.H{{"rfilename": "model-Q4_K_M.gguf", "size": 1024}}}})
+	}))
+	defer outboundProxy.Close()
+	t.Cleanup(func() { autosetup.SetHTTPProxy("") })
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		HTTPProxy:          outboundProxy.URL,
+	}))
+	proxy.huggingFaceURL = "http://huggingface.test"
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/search?q=qwen", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "owner/qwen-GGUF", gjson.Get(w.Body.String(), "models.0.id").String())
+	mu.Lock()
+	assert.Equal(t, []string{"huggingface.test"}, proxiedHosts, "the search goes through httpProxy")
+	mu.Unlock()
+}
+
+func TestProxyManager_AutoDownloadQueue(t *testing.T) {
+	useTempFilePaths(t)
+
+	// the repo search waits until released, the request stays queued meanwhile
+	searched := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searched <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(gin.H{"id": "owner/model-GGUF", "siblings": []gin.H{{"rfilename": "model-Q4_K_M.gguf", "size": 1024}}})
+	}))
+	defer hf.Close()
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer files.Close()
+
+	proxy := newTestProxyManager(t, AddDefaultGroupToConfig(Config{HealthCheckTimeout: 15, LogLevel: "error"}))
+	proxy.huggingFaceURL = hf.URL
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+
+	chatDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"owner/model-GGUF:q4_k_m"}`)))
+		chatDone <- w
+	}()
+	<-searched
+	downloadID, err := proxy.downloadManager.StartDownload("owner/model-GGUF", "model-Q4_K_M.gguf", files.URL+"/model-Q4_K_M.gguf", "", t.TempDir())
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/auto-download/queue", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), gjson.Get(w.Body.String(), "count").Int(), w.Body.String())
+	entry := gjson.Get(w.Body.String(), "queue.0")
+	assert.Equal(t, "owner/model-GGUF:q4_k_m", entry.Get("model").String())
+	assert.Equal(t, "owner/model-GGUF", entry.Get("repo").String())
+	assert.Equal(t, int64(1), entry.Get("requests").Int())
+	assert.Equal(t, downloadID, entry.Get("downloads.0.id").String())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/models/auto-download/queue?model=owner/other-GGUF", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// cancelling fails the waiting request and cancels the repo's downloads
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/models/auto-download/queue", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["owner/model-GGUF:q4_k_m"]`, gjson.Get(w.Body.String(), "cancelled").Raw)
+	assert.Equal(t, `["`+downloadID+`"]`, gjson.Get(w.Body.String(), "cancelledDownloads").Raw)
+	_, found := proxy.downloadManager.GetDownload(downloadID)
+	assert.False(t, found)
+
+	close(release)
+	select {
+	case w = <-chatDone:
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "auto-download cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request still waits for the cancelled auto-download")
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/api/models/auto-download/queue", nil))
+	assert.Equal(t, int64(0), gjson.Get(w.Body.String(), "count").Int(), w.Body.String())
+}
+
+func TestProxyManager_ListModelsReportsProcessState(t *testing.T) {
+	upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	config, err := LoadConfigFromReader(strings.NewReader(strings.NewReplacer("${upstream}", upstream.URL, "${cmd}", fakeUpstreamCmd).Replace(`
+healthCheckTimeout: 15
+logLevel: error
+models:
+  model1:
+    cmd: ${cmd}
+    proxy: ${upstream}
+  model2:
+    cmd: ${cmd}
+    proxy: ${upstream}
+groups:
+  together:
+    swap: false
+    members: [model1, model2]
+`)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := newTestProxyManager(t, config)
+	defer proxy.StopProcesses(StopImmediately)
+
+	listed := func() map[string]gjson.Result {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/v1/models", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		records := make(map[string]gjson.Result)
+		for _, record := range gjson.Get(w.Body.String(), "data").Array() {
+			records[record.Get("id").String()] = record
+		}
+		return records
+	}
+
+	// both models have a process in their group, neither is running
+	assert.NotNil(t, proxy.findGroupByModelName("model1"))
+	records := listed()
+	assert.Equal(t, "unloaded", records["model1"].Get("status").String())
+	assert.Equal(t, "stopped", records["model1"].Get("state").String())
+
+	processGroup, _, err := proxy.swapProcessGroup("model1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	processGroup.ProxyRequest("model1", httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	records = listed()
+	assert.Equal(t, "loaded", records["model1"].Get("status").String())
+	assert.Equal(t, "ready", records["model1"].Get("state").String())
+	assert.False(t, records["model1"].Get("loading").Bool())
+	assert.Equal(t, "unloaded", records["model2"].Get("status").String())
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/v1/models/model2", nil))
+	assert.Equal(t, "stopped", gjson.Get(w.Body.String(), "state").String())
+}
+
+func TestProxyManager_RoutesAroundUnhealthyModel(t *testing.T) {
+	var primaryHealthy, backupHealthy atomic.Bool
+	primaryHealthy.Store(true)
+	backupHealthy.Store(true)
+	newInstance := func(name string, healthy *atomic.Bool) ModelConfig {
+		upstream := newFakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" && !healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(name))
+		})
+		return fakeUpstreamModel(upstream)
+	}
+	primary := newInstance("primary", &primaryHealthy)
+	primary.FallbackModels = []string{"backup"}
+
+	proxy := New(AddDefaultGroupToConfig(Config{
+		HealthCheckTimeout: 15,
+		LogLevel:           "error",
+		Models: map[string]ModelConfig{
+			"primary": primary,
+			"backup":  newInstance("backup", &backupHealthy),
+		},
+		Groups: map[string]GroupConfig{
+			"instances": {Swap: false, Members: []string{"primary", "backup"}},
+		},
+	}))
+	defer proxy.StopProcesses(StopWaitForInflightRequest)
+	for _, modelID := range []string{"primary", "backup"} {
+		proxy.modelProcess(modelID).healthWatchInterval = 20 * time.Millisecond
+	}
+
+	changes := make(chan ProcessHealthChangedEvent, 8)
+	defer event.On(func(e ProcessHealthChangedEvent) {
+		changes <- e
+	})()
+	expectChange := func(processName string, healthy bool) {
+		t.Helper()
+		select {
+		case e := <-changes:
+			assert.Equal(t, ProcessHealthChangedEvent{ProcessName: processName, Healthy: healthy}, e)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %s to become healthy=%v", processName, healthy)
+		}
+	}
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"primary"}`)))
+		return w
+	}
+
+	// both run, the backup only serves while the primary is unhealthy
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"backup"}`)))
+	assert.Equal(t, "primary", request().Body.String())
+
+	primaryHealthy.Store(false)
+	expectChange("primary", false)
+	assert.Equal(t, "backup", request().Body.String())
+	assert.Equal(t, StateReady, proxy.modelProcessState("primary"))
+
+	// a scoped key is not routed to a fallback it may not use
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(apiKeyScopeContextKey, &ScopedAPIKey{Models: []string{"primary"}})
+	assert.Equal(t, "primary", proxy.healthyInstance(c, "primary"))
+	c.Set(apiKeyScopeContextKey, &ScopedAPIKey{Models: []string{"primary", "backup"}})
+	assert.Equal(t, "backup", proxy.healthyInstance(c, "primary"))
+
+	// with no healthy instance the request fails
+	backupHealthy.Store(false)
+	expectChange("backup", false)
+	assert.Equal(t, http.StatusServiceUnavailable, request().Code)
+
+	primaryHealthy.Store(true)
+	expectChange("primary", true)
+	assert.Equal(t, "primary", request().Body.String())
+}
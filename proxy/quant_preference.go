@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// splitPartPattern matches the part number of a split model's filename, group 1
+// is the name the parts share
+var splitPartPattern = regexp.MustCompile(`(.*?)(-\d{5}-of-\d{5}|\.gguf\.part\d+of\d+|-part-\d{5}-of-\d{5}|\.split-\d{5}-of-\d{5})`)
+
+// defaultQuantPreference orders quantizations from most to least preferred when
+// the settings have no quantizationPreference, a balance of quality and size
+var defaultQuantPreference = quantPreference{
+	"Q4_K_M", "Q5_K_M", "Q6_K", "Q8_0",
+	"Q5_K_S", "Q4_K_S", "IQ4_XS", "IQ4_NL", "Q5_0", "Q4_0", "Q4_1",
+	"Q3_K_L", "Q3_K_M", "IQ3_M", "Q3_K_S", "Q2_K",
+	"BF16", "F16", "F32",
+}
+
+// quantPreference is an ordered list of quantizations, most preferred first. It
+// ranks the files search recommends, auto-download picks and already
+// downloaded files are reused in.
+type quantPreference []string
+
+// rank orders quantization labels by the preference, unknown ones last. An
+// entry may leave out the size suffix, e.g. Q5_K ranks Q5_K_M and Q5_K_S.
+func (p quantPreference) rank(label string) int {
+	label = strings.ToUpper(label)
+	for i, quant := range p {
+		if matchesQuantization(label, quant) {
+			return i
+		}
+	}
+	return len(p)
+}
+
+// preferredFiles returns the GGUF files of the most preferred quantization of a
+// repo, all shards of a split model or a single file. Files keep their order
+// between quantizations of the same rank. nil when there are only vision
+// projectors.
+func (p quantPreference) preferredFiles(files []HuggingFaceFile) []HuggingFaceFile {
+	var best *HuggingFaceFile
+	bestRank := 0
+	for i := range files {
+		label := quantizationLabel(files[i].Filename)
+		if label == "mmproj" {
+			continue
+		}
+		if rank := p.rank(label); best == nil || rank < bestRank {
+			best, bestRank = &files[i], rank
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	if !best.IsSplit {
+		return []HuggingFaceFile{*best}
+	}
+
+	// every shard of the same split model
+	baseName := best.Filename
+	if match := splitPartPattern.FindStringSubmatch(best.Filename); match != nil {
+		baseName = match[1]
+	}
+	var shards []HuggingFaceFile
+	for _, file := range files {
+		if file.IsSplit && strings.HasPrefix(file.Filename, baseName) {
+			shards = append(shards, file)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Filename < shards[j].Filename })
+	return shards
+}
+
+// sortSearchFiles orders the GGUF files of a search result by preference,
+// vision projectors last
+func (p quantPreference) sortSearchFiles(files []map[string]interface{}) {
+	rank := func(file map[string]interface{}) int {
+		if filename, _ := file["filename"].(string); quantizationLabel(filename) == "mmproj" {
+			return len(p) + 1
+		}
+		quantization, _ := file["quantization"].(string)
+		return p.rank(quantization)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return rank(files[i]) < rank(files[j]) })
+}
+
+// validateQuantizationPreference requires each entry to be a known
+// quantization, listed once
+func (s *SystemSettings) validateQuantizationPreference() error {
+	seen := make(map[string]bool)
+	for i, quant := range s.QuantizationPreference {
+		quant = strings.ToUpper(strings.TrimSpace(quant))
+		if quant == "" || extractQuantization(quant) == "Unknown" {
+			return fmt.Errorf("quantizationPreference[%d] %q is not a quantization", i, s.QuantizationPreference[i])
+		}
+		if seen[quant] {
+			return fmt.Errorf("quantizationPreference[%d] %q is listed twice", i, s.QuantizationPreference[i])
+		}
+		seen[quant] = true
+		s.QuantizationPreference[i] = quant
+	}
+	return nil
+}
+
+// configuredQuantPreference returns the quantizationPreference of the settings,
+// nil when none is set
+func (pm *ProxyManager) configuredQuantPreference() quantPreference {
+	if settings := pm.getSystemSettings(); settings != nil && len(settings.QuantizationPreference) > 0 {
+		return quantPreference(settings.QuantizationPreference)
+	}
+	return nil
+}
+
+// quantPreference returns the quantizationPreference of the settings, or
+// defaultQuantPreference when none is set
+func (pm *ProxyManager) quantPreference() quantPreference {
+	if preference := pm.configuredQuantPreference(); preference != nil {
+		return preference
+	}
+	return defaultQuantPreference
+}
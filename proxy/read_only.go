@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyAllowedRoutes are the non-GET /api routes that change nothing and
+// keep working in read-only mode
+var readOnlyAllowedRoutes = map[string]bool{
+	"POST /api/models/validate-cmd": true,
+}
+
+// rejectInReadOnlyMode answers 403 to every /api request that could change the
+// config, model files, downloads or the running server when readOnlyMode is set.
+// GET endpoints and inference outside of /api are not affected.
+func (pm *ProxyManager) rejectInReadOnlyMode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pm.Lock()
+		readOnly := pm.config.ReadOnlyMode
+		pm.Unlock()
+
+		method := c.Request.Method
+		if !readOnly || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions ||
+			readOnlyAllowedRoutes[method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the API is in read-only mode, readOnlyMode is set in the config"})
+	}
+}
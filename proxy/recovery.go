@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoverPanics turns a panic in a handler into a 500 JSON error instead of a
+// dropped connection. The panic and its stack trace are logged with the request
+// they happened in, clients only get a generic error.
+func (pm *ProxyManager) recoverPanics(c *gin.Context) {
+	// capture these because /upstream/:model rewrites them in c.Next()
+	method := c.Request.Method
+	path := c.Request.URL.Path
+	tag := requestTag(c.Request.Context())
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		// httputil.ReverseProxy aborts a response to a client that went away with
+		// http.ErrAbortHandler, which net/http handles quietly
+		if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			panic(recovered)
+		}
+
+		pm.proxyLogger.Errorf("Panic handling %s \"%s %s\"%s: %v\n%s", c.ClientIP(), method, path, tag, recovered, debug.Stack())
+		if c.Writer.Written() {
+			// the status and part of the body are already sent, all that is left is to stop
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}()
+	c.Next()
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader carries the correlation ID of a request. It is honored on
+// incoming requests, forwarded to the upstream and returned in the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps incoming IDs so a client can't stuff the logs
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// validRequestID accepts the IDs of common proxies and tracing tools, UUIDs,
+// hex and base64 strings, and nothing that could break a log line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// assignRequestID gives every request a correlation ID, the incoming
+// X-Request-ID when it has a valid one, so its log lines can be told apart from
+// those of concurrent requests
+func (pm *ProxyManager) assignRequestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	c.Request.Header.Set(requestIDHeader, id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDContextKey{}, id))
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+// requestID returns the correlation ID of the request ctx belongs to, "" outside of one
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// requestTag is the correlation ID as it is appended to log lines, " [id]", or
+// "" outside of a request
+func requestTag(ctx context.Context) string {
+	if id := requestID(ctx); id != "" {
+		return " [" + id + "]"
+	}
+	return ""
+}
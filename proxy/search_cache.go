@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchCacheTTL is how long apiSearchModels serves a search from the cache.
+// Users refine a search as they type, repeating the same queries within seconds.
+const searchCacheTTL = 2 * time.Minute
+
+// searchCacheMaxEntries bounds the cache, the oldest search is dropped first
+const searchCacheMaxEntries = 256
+
+type searchCacheEntry struct {
+	response gin.H
+	cachedAt time.Time
+}
+
+// searchCache keeps the enriched responses of recent HuggingFace searches
+type searchCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]searchCacheEntry
+}
+
+func newSearchCache(ttl time.Duration) *searchCache {
+	return &searchCache{ttl: ttl, entries: make(map[string]searchCacheEntry)}
+}
+
+// searchCacheKey identifies a search by its normalized query and the parameters
+// that change its results. The HF token is hashed, gated and private repos
+// differ per token. The quantization preference orders the files.
+func searchCacheKey(query, limit string, includeGated bool, hfToken string, preference quantPreference) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	token := ""
+	if hfToken != "" {
+		sum := sha256.Sum256([]byte(hfToken))
+		token = hex.EncodeToString(sum[:8])
+	}
+	gated := "false"
+	if includeGated {
+		gated = "true"
+	}
+	return strings.Join([]string{query, strings.TrimSpace(limit), gated, token, strings.Join(preference, ",")}, "\x00")
+}
+
+// get returns a copy of the cached response of a search within the TTL
+func (c *searchCache) get(key string) (gin.H, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, found := c.entries[key]
+	if !found || time.Since(entry.cachedAt) > c.ttl {
+		return nil, false
+	}
+	response := make(gin.H, len(entry.response))
+	for k, v := range entry.response {
+		response[k] = v
+	}
+	return response, true
+}
+
+// put caches the response of a search, dropping expired searches and the
+// oldest one when the cache is full
+func (c *searchCache) put(key string, response gin.H) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.Sub(entry.cachedAt) > c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	if _, found := c.entries[key]; !found && len(c.entries) >= searchCacheMaxEntries {
+		oldest := ""
+		for k, entry := range c.entries {
+			if oldest == "" || entry.cachedAt.Before(c.entries[oldest].cachedAt) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = searchCacheEntry{response: response, cachedAt: now}
+}
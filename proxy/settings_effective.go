@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// settingsEnvOverride is an environment variable that takes precedence over a
+// field of the saved settings.json, e.g. to pin the backend in a container
+type settingsEnvOverride struct {
+	env   string
+	field string // JSON name of the SystemSettings field
+	apply func(s *SystemSettings, value string) error
+}
+
+var settingsEnvOverrides = []settingsEnvOverride{
+	{"FROGLLM_BACKEND", "backend", func(s *SystemSettings, value string) error {
+		s.Backend = platformBackend(strings.ToLower(value))
+		return nil
+	}},
+	{"FROGLLM_VRAM_GB", "vramGB", func(s *SystemSettings, value string) error {
+		return parseSettingsGB(value, &s.VRAMGB)
+	}},
+	{"FROGLLM_RAM_GB", "ramGB", func(s *SystemSettings, value string) error {
+		return parseSettingsGB(value, &s.RAMGB)
+	}},
+	{"FROGLLM_PREFERRED_CONTEXT", "preferredContext", func(s *SystemSettings, value string) error {
+		context, err := strconv.Atoi(value)
+		if err != nil || context <= 0 {
+			return fmt.Errorf("must be a positive number of tokens")
+		}
+		s.PreferredContext = context
+		return nil
+	}},
+	{"FROGLLM_THROUGHPUT_FIRST", "throughputFirst", func(s *SystemSettings, value string) error {
+		return parseSettingsBool(value, &s.ThroughputFirst)
+	}},
+	{"FROGLLM_ENABLE_JINJA", "enableJinja", func(s *SystemSettings, value string) error {
+		return parseSettingsBool(value, &s.EnableJinja)
+	}},
+	{"HF_TOKEN", "huggingFaceApiKey", func(s *SystemSettings, value string) error {
+		s.HuggingFaceApiKey = value
+		return nil
+	}},
+}
+
+func parseSettingsGB(value string, target *float64) error {
+	gb, err := strconv.ParseFloat(value, 64)
+	if err != nil || gb <= 0 {
+		return fmt.Errorf("must be a positive number of GB")
+	}
+	*target = gb
+	return nil
+}
+
+func parseSettingsBool(value string, target *bool) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("must be true or false")
+	}
+	*target = b
+	return nil
+}
+
+// hasSettingsEnvOverrides reports if any settings environment variable is set
+func hasSettingsEnvOverrides() bool {
+	for _, override := range settingsEnvOverrides {
+		if strings.TrimSpace(os.Getenv(override.env)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applySettingsEnvOverrides sets the fields of s that have an environment
+// variable set. It returns the overridden fields by variable and a warning for
+// each value that couldn't be used, which leaves its field as it was.
+func applySettingsEnvOverrides(s *SystemSettings) (applied map[string]string, warnings []string) {
+	applied = make(map[string]string)
+	for _, override := range settingsEnvOverrides {
+		value := strings.TrimSpace(os.Getenv(override.env))
+		if value == "" {
+			continue
+		}
+		if err := override.apply(s, value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignoring %s=%q: %v", override.env, value, err))
+			continue
+		}
+		applied[override.env] = override.field
+	}
+	return applied, warnings
+}
+
+// platformBackend maps backends the platform can't run to one it can, on macOS
+// that is metal
+func platformBackend(backend string) string {
+	if runtime.GOOS == "darwin" && (backend == "cuda" || backend == "rocm" || backend == "vulkan") {
+		return "metal"
+	}
+	return backend
+}
+
+// detectedBackend picks the backend for a detected system
+func detectedBackend(system autosetup.SystemInfo) string {
+	// crude mapping
+	if runtime.GOOS == "darwin" || system.HasMetal {
+		return "metal"
+	} else if system.HasCUDA {
+		return "cuda"
+	} else if system.HasROCm {
+		return "rocm"
+	} else if system.HasIntel && system.IntelIsPrimaryGPU() {
+		return "intel"
+	} else if system.HasVulkan {
+		return "vulkan"
+	}
+	return "cpu"
+}
+
+// apiGetEffectiveSettings handles GET /api/settings/effective. It returns the
+// settings FrogLLM actually uses: settings.json with the environment overrides
+// applied and the fields it leaves unset filled from detection or the defaults
+// config generation falls back to. sources tells where each value came from:
+// saved, env, detected or default.
+func (pm *ProxyManager) apiGetEffectiveSettings(c *gin.Context) {
+	saved, err := pm.loadSavedSystemSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to load settings: %v", err)})
+		return
+	}
+
+	sources := make(map[string]string)
+	var effective SystemSettings
+	if saved != nil {
+		effective = *saved
+		for _, field := range []string{"backend", "vramGB", "ramGB", "preferredContext", "throughputFirst", "enableJinja", "huggingFaceApiKey"} {
+			sources[field] = "saved"
+		}
+	} else {
+		// what config generation uses without settings.json
+		effective.ThroughputFirst = true
+		effective.EnableJinja = true
+		sources["throughputFirst"] = "default"
+		sources["enableJinja"] = "default"
+	}
+
+	applied, warnings := applySettingsEnvOverrides(&effective)
+	for _, field := range applied {
+		sources[field] = "env"
+	}
+
+	if effective.VRAMGB == 0 || effective.RAMGB == 0 || effective.Backend == "" {
+		system, _ := pm.detectSystem()
+		if effective.VRAMGB == 0 {
+			effective.VRAMGB = system.TotalVRAMGB
+			sources["vramGB"] = "detected"
+		}
+		if effective.RAMGB == 0 {
+			effective.RAMGB = system.TotalRAMGB
+			sources["ramGB"] = "detected"
+		}
+		if effective.Backend == "" {
+			effective.Backend = detectedBackend(system)
+			sources["backend"] = "detected"
+		}
+	}
+	if effective.PreferredContext == 0 {
+		effective.PreferredContext = 32768
+		sources["preferredContext"] = "default"
+	}
+	if effective.HuggingFaceApiKey == "" {
+		delete(sources, "huggingFaceApiKey")
+	}
+
+	// keys are never returned, sources shows whether one is set
+	effective.APIKey = ""
+	effective.HuggingFaceApiKey = ""
+	redactedKeys := make([]ScopedAPIKey, len(effective.APIKeys))
+	for i, entry := range effective.APIKeys {
+		entry.Key = ""
+		redactedKeys[i] = entry
+	}
+	effective.APIKeys = redactedKeys
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"settings":     effective,
+		"sources":      sources,
+		"envOverrides": applied,
+		"savedExists":  saved != nil,
+		"warnings":     warnings,
+	})
+}
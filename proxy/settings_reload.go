@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gin-gonic/gin"
+)
+
+// settingsReloadDelay is how long the settings watcher waits for more changes
+// to settings.json before reloading it, editors often write a file in steps
+const settingsReloadDelay = 500 * time.Millisecond
+
+// settingsCache keeps settings.json in memory, requests don't read it from
+// disk. It is read on first use and again by reloadSystemSettings, the
+// effective settings are worked out at the same time. Both are shared by all
+// requests and never changed, a reload replaces them.
+type settingsCache struct {
+	sync.RWMutex
+	loaded    bool
+	saved     *SystemSettings // nil when there is no settings.json
+	effective *SystemSettings // saved with the environment overrides, nil when there are neither
+}
+
+// clone returns a deep copy, callers may change the settings they get
+func (s *SystemSettings) clone() *SystemSettings {
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var copied SystemSettings
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil
+	}
+	return &copied
+}
+
+// validateFields checks the fields validated when the settings are saved,
+// normalizing them
+func (s *SystemSettings) validateFields() error {
+	for _, validate := range []func() error{
+		s.validateAPIKeys,
+		s.validateGPUVRAMOverrides,
+		s.validateModelOverrides,
+		s.validateCmdTemplates,
+		s.validateAutoDownloadAllowlist,
+		s.validateQuantizationPreference,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSystemSettingsFile reads settings.json from disk, nil when there is none
+func (pm *ProxyManager) readSystemSettingsFile() (*SystemSettings, error) {
+	data, err := os.ReadFile(pm.getSystemSettingsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s SystemSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// cacheSystemSettings replaces the settings in memory with a copy of s and
+// applies the environment overrides to it once
+func (pm *ProxyManager) cacheSystemSettings(s *SystemSettings) {
+	saved := s.clone()
+	effective := saved
+	if hasSettingsEnvOverrides() {
+		effective = saved.clone()
+		if effective == nil {
+			effective = &SystemSettings{}
+		}
+		_, warnings := applySettingsEnvOverrides(effective)
+		for _, warning := range warnings {
+			pm.proxyLogger.Warnf("Settings: %s", warning)
+		}
+	}
+
+	pm.systemSettings.Lock()
+	pm.systemSettings.loaded = true
+	pm.systemSettings.saved = saved
+	pm.systemSettings.effective = effective
+	pm.systemSettings.Unlock()
+}
+
+// cachedSystemSettings returns the saved and effective settings, reading
+// settings.json on first use. Callers must not change them.
+func (pm *ProxyManager) cachedSystemSettings() (saved, effective *SystemSettings, err error) {
+	pm.systemSettings.RLock()
+	loaded, saved, effective := pm.systemSettings.loaded, pm.systemSettings.saved, pm.systemSettings.effective
+	pm.systemSettings.RUnlock()
+	if loaded {
+		return saved, effective, nil
+	}
+
+	s, err := pm.readSystemSettingsFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	pm.cacheSystemSettings(s)
+	pm.systemSettings.RLock()
+	defer pm.systemSettings.RUnlock()
+	return pm.systemSettings.saved, pm.systemSettings.effective, nil
+}
+
+// reloadSystemSettings reads settings.json again and replaces the settings in
+// memory, so the next requests check API keys, pick quantizations and so on by
+// the new ones. Settings that fail validation are refused and the ones in
+// memory stay. It returns the JSON names of the fields that changed.
+func (pm *ProxyManager) reloadSystemSettings() ([]string, error) {
+	s, err := pm.readSystemSettingsFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", pm.getSystemSettingsPath(), err)
+	}
+	if s != nil {
+		if s.RequireAPIKey && strings.TrimSpace(s.APIKey) == "" {
+			return nil, fmt.Errorf("requireApiKey=true needs a non-empty apiKey")
+		}
+		if err := s.validateFields(); err != nil {
+			return nil, err
+		}
+	}
+
+	pm.systemSettings.RLock()
+	previous := pm.systemSettings.saved
+	pm.systemSettings.RUnlock()
+	changed := changedSettingsFields(previous, s)
+	pm.cacheSystemSettings(s)
+	return changed, nil
+}
+
+// changedSettingsFields returns the JSON names of the fields that differ
+// between two settings, sorted
+func changedSettingsFields(before, after *SystemSettings) []string {
+	fields := func(s *SystemSettings) map[string]json.RawMessage {
+		fields := make(map[string]json.RawMessage)
+		if s != nil {
+			data, _ := json.Marshal(s)
+			json.Unmarshal(data, &fields)
+		}
+		return fields
+	}
+	beforeFields, afterFields := fields(before), fields(after)
+	changed := []string{}
+	for name, value := range afterFields {
+		if !bytes.Equal(value, beforeFields[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range beforeFields {
+		if _, found := afterFields[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// apiReloadSystemSettings handles POST /api/settings/reload, applying a
+// settings.json that was changed on disk without restarting
+func (pm *ProxyManager) apiReloadSystemSettings(c *gin.Context) {
+	changed, err := pm.reloadSystemSettings()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("settings not reloaded: %v", err)})
+		return
+	}
+	pm.proxyLogger.Infof("Reloaded %s, changed: %v", pm.getSystemSettingsPath(), changed)
+	saved, _ := pm.loadSavedSystemSettings()
+	c.JSON(http.StatusOK, gin.H{"status": "reloaded", "changed": changed, "savedExists": saved != nil})
+}
+
+// WatchSystemSettings reloads settings.json when it changes on disk, until shutdown
+func (pm *ProxyManager) WatchSystemSettings() {
+	path, err := filepath.Abs(pm.getSystemSettingsPath())
+	if err != nil {
+		pm.proxyLogger.Warnf("Not watching settings for changes: %v", err)
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		pm.proxyLogger.Warnf("Not watching settings for changes: %v", err)
+		return
+	}
+	// editors replace the file, watch the folder it is in
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		pm.proxyLogger.Warnf("Not watching settings for changes: %v", err)
+		return
+	}
+
+	reload := func() {
+		changed, err := pm.reloadSystemSettings()
+		if err != nil {
+			pm.proxyLogger.Warnf("Settings changed on disk but were not reloaded: %v", err)
+			return
+		}
+		if len(changed) > 0 {
+			pm.proxyLogger.Infof("Settings changed on disk, reloaded: %v", changed)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case <-pm.shutdownCtx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case changeEvent, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if changeEvent.Name != path || !(changeEvent.Has(fsnotify.Write) || changeEvent.Has(fsnotify.Create) || changeEvent.Has(fsnotify.Remove) || changeEvent.Has(fsnotify.Rename)) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(settingsReloadDelay, reload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				pm.proxyLogger.Warnf("Settings watcher error: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// sharedProcessGroups maps the members of sharedProcess groups to their group.
+// The members of such a group share one port, only one of them runs at a time,
+// so the group must swap.
+func sharedProcessGroups(config Config) (map[string]string, error) {
+	groupIDs := make([]string, 0, len(config.Groups))
+	for groupID := range config.Groups {
+		groupIDs = append(groupIDs, groupID)
+	}
+	sort.Strings(groupIDs)
+
+	sharedGroupOf := make(map[string]string)
+	for _, groupID := range groupIDs {
+		group := config.Groups[groupID]
+		if !group.SharedProcess {
+			continue
+		}
+		if !group.Swap {
+			return nil, fmt.Errorf("group %s: sharedProcess needs swap, its members share one port", groupID)
+		}
+		for _, member := range group.Members {
+			sharedGroupOf[member] = groupID
+		}
+	}
+	return sharedGroupOf, nil
+}
+
+// releaseSharedPort stops the members of a sharedProcess group other than
+// modelID and waits for them to exit, so modelID can listen on the port they
+// share. A member that is still starting is waited for first, Stop skips it
+// otherwise. pg must be locked.
+func (pg *ProcessGroup) releaseSharedPort(modelID string) {
+	for memberID, process := range pg.processes {
+		if memberID == modelID {
+			continue
+		}
+		process.waitForStart()
+		process.Stop()
+	}
+}
+
+// waitForStart waits until a process that is starting is ready or failed.
+// Unlike waitStarting.Wait it can run while start is called concurrently.
+func (p *Process) waitForStart() {
+	for p.CurrentState() == StateStarting {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// values of slotMemoryCheck
+const (
+	SlotMemoryCheckWarn   = "warn"
+	SlotMemoryCheckReduce = "reduce"
+	SlotMemoryCheckOff    = "off"
+)
+
+// minSlotContext is the smallest context slotMemoryCheck: reduce gives a slot
+const minSlotContext = 512
+
+var (
+	ctxSizePattern  = regexp.MustCompile(`(^|\s)(--ctx-size|-c)(=|\s+)(\S+)`)
+	parallelPattern = regexp.MustCompile(`(^|\s)(--parallel|-np)(=|\s+)(\S+)`)
+)
+
+// slotMemory is the VRAM a model needs once all of its --parallel slots hold a
+// full context, which a burst of concurrent requests gets to after a load that
+// went fine
+type slotMemory struct {
+	ContextSize int
+	Parallel    int
+	SlotContext int
+	SlotKVGB    float64
+	KVCacheGB   float64 // SlotKVGB * Parallel
+	WeightsGB   float64 // model and LoRA adapters
+	OverheadGB  float64
+	WorstCaseGB float64
+}
+
+// estimateSlotMemory works out the worst-case VRAM of a model launched with cmd
+func estimateSlotMemory(modelPath, cmd string, loraPaths []string) (*slotMemory, error) {
+	estimator := autosetup.NewMemoryEstimator()
+	memInfo, err := estimator.GetModelMemoryInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model memory info: %v", err)
+	}
+	estimator.AddLoRAAdapters(memInfo, loraPaths)
+	metadata, err := autosetup.ReadGGUFMetadata(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model metadata: %v", err)
+	}
+
+	args, err := SanitizeCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	contextSize := cmdIntFlag(args, "--ctx-size", "-c")
+	if contextSize <= 0 {
+		contextSize = defaultServerContextSize
+	}
+	parallel := cmdIntFlag(args, "--parallel", "-np")
+	if parallel <= 0 {
+		parallel = defaultServerParallel
+	}
+
+	m := &slotMemory{
+		ContextSize: contextSize,
+		Parallel:    parallel,
+		SlotContext: contextSize / parallel,
+		WeightsGB:   memInfo.ModelSizeGB + memInfo.LoRASizeGB,
+		OverheadGB:  estimator.OverheadGB,
+	}
+	m.SlotKVGB = estimator.CalculateMemoryForContext(memInfo, m.SlotContext, metadata.BlockCount).KVCacheGB
+	m.KVCacheGB = m.SlotKVGB * float64(parallel)
+	m.WorstCaseGB = m.WeightsGB + m.OverheadGB + m.KVCacheGB
+	return m, nil
+}
+
+// fit returns the slots and context that fit in availableGB. Slots keep their
+// context and are dropped first, a single slot that still does not fit gets a
+// smaller context. ok is false when not even minSlotContext fits.
+func (m *slotMemory) fit(availableGB float64) (parallel, contextSize int, ok bool) {
+	kvBudget := availableGB - m.WeightsGB - m.OverheadGB
+	if m.SlotKVGB <= 0 || kvBudget <= 0 {
+		return 0, 0, false
+	}
+	if parallel = int(math.Floor(kvBudget / m.SlotKVGB)); parallel >= 1 {
+		parallel = min(parallel, m.Parallel)
+		return parallel, m.SlotContext * parallel, true
+	}
+	// round down to a multiple of 256 tokens
+	contextSize = int(kvBudget/m.SlotKVGB*float64(m.SlotContext)) / 256 * 256
+	if contextSize < minSlotContext {
+		return 0, 0, false
+	}
+	return 1, contextSize, true
+}
+
+// withIntFlag sets a flag of a cmd, replacing its value or adding it
+func withIntFlag(cmd string, pattern *regexp.Regexp, flag string, value int) string {
+	if pattern.MatchString(cmd) {
+		return pattern.ReplaceAllString(cmd, "${1}${2}${3}"+strconv.Itoa(value))
+	}
+	return strings.TrimRight(cmd, "\n") + "\n" + flag + " " + strconv.Itoa(value)
+}
+
+// checkSlotMemory runs before a load. llama-server allocates the KV cache of
+// every slot, but a model that fits idle can still run out of VRAM once a burst
+// of requests fills all of them. When the worst case does not fit the free VRAM
+// the load is logged, or with slotMemoryCheck: reduce started with fewer slots,
+// and a smaller context once one slot is left. The reduction only applies to
+// this launch, like try-params. Loads are never refused, the estimate does not
+// know about layers offloaded to the CPU.
+func (pm *ProxyManager) checkSlotMemory(group *ProcessGroup, modelName string) {
+	mode := pm.config.SlotMemoryCheck
+	if mode == SlotMemoryCheckOff || group.processState(modelName) == StateReady {
+		return
+	}
+	group.Lock()
+	process := group.processes[modelName]
+	group.Unlock()
+	if process == nil {
+		return
+	}
+	modelPath := pm.resolveModelPath(modelName)
+	if modelPath == "" {
+		return
+	}
+
+	cmd := process.launchCmd()
+	m, err := estimateSlotMemory(modelPath, cmd, pm.config.Models[modelName].enabledLoRAPaths())
+	if err != nil {
+		pm.proxyLogger.Debugf("Not checking the slot memory of %s: %v", modelName, err)
+		return
+	}
+	freeGB, _, err := pm.measureVRAM()
+	if err != nil {
+		pm.proxyLogger.Debugf("Not checking the slot memory of %s, VRAM can't be measured: %v", modelName, err)
+		return
+	}
+	if m.WorstCaseGB <= freeGB {
+		return
+	}
+
+	parallel, contextSize, ok := m.fit(freeGB)
+	if mode != SlotMemoryCheckReduce || !ok {
+		pm.proxyLogger.Warnf("%s needs %.1fGB of VRAM once its %d slots of %d tokens are full but %.1fGB is free, concurrent requests may run out of memory",
+			modelName, m.WorstCaseGB, m.Parallel, m.SlotContext, freeGB)
+		return
+	}
+
+	cmd = withIntFlag(withIntFlag(cmd, ctxSizePattern, "--ctx-size", contextSize), parallelPattern, "--parallel", parallel)
+	process.setNextLaunchCmd(cmd)
+	pm.proxyLogger.Warnf("%s needs %.1fGB of VRAM once its %d slots of %d tokens are full but %.1fGB is free, loading it with --parallel %d --ctx-size %d",
+		modelName, m.WorstCaseGB, m.Parallel, m.SlotContext, freeGB, parallel, contextSize)
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/prave/FrogLLM/event"
+)
+
+// swapCooldownDuration is how long a loaded model is kept before a swap may
+// unload it. Alternating requests for models that swap each other out would
+// otherwise reload a model for nearly every request, with the cooldown the
+// requests for the other model wait and are then served together.
+func swapCooldownDuration(config Config) time.Duration {
+	return time.Duration(config.SwapCooldown) * time.Second
+}
+
+// cooldownRemaining is how much longer a ready process stays in its cooldown
+func (p *Process) cooldownRemaining(cooldown time.Duration) time.Duration {
+	if cooldown <= 0 || p.CurrentState() != StateReady {
+		return 0
+	}
+	readyAt := p.readyAt.Load()
+	if readyAt == 0 {
+		return 0
+	}
+	return max(cooldown-time.Since(time.Unix(0, readyAt)), 0)
+}
+
+// residentInCooldown returns the process of the group with the longest cooldown
+// left, other than exceptModelID. Must be called with the group locked.
+func (pg *ProcessGroup) residentInCooldown(cooldown time.Duration, exceptModelID string) (string, time.Duration) {
+	residentModel, longest := "", time.Duration(0)
+	for modelID, process := range pg.processes {
+		if modelID == exceptModelID || process == nil {
+			continue
+		}
+		if remaining := process.cooldownRemaining(cooldown); remaining > longest {
+			residentModel, longest = modelID, remaining
+		}
+	}
+	return residentModel, longest
+}
+
+// deferSwap waits out the cooldown of the resident model before modelID may
+// replace it, logging and firing a SwapDeferredEvent so the wait is visible
+func deferSwap(ctx context.Context, logger *LogMonitor, modelID, residentModel string, wait time.Duration) error {
+	logger.Infof("<%s>%s Swap deferred for %.1fs, %s is in its swap cooldown", modelID, requestTag(ctx), wait.Seconds(), residentModel)
+	event.Emit(SwapDeferredEvent{ModelName: modelID, ResidentModel: residentModel, Wait: wait})
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitForExclusiveCooldown waits until no model in groups is in its cooldown,
+// before an exclusive group unloads them to load modelID
+func (pm *ProxyManager) waitForExclusiveCooldown(groups []*ProcessGroup, modelID string) error {
+	cooldown := swapCooldownDuration(pm.config)
+	if cooldown <= 0 {
+		return nil
+	}
+	for {
+		residentModel, longest := "", time.Duration(0)
+		for _, group := range groups {
+			group.Lock()
+			groupResident, remaining := group.residentInCooldown(cooldown, "")
+			group.Unlock()
+			if remaining > longest {
+				residentModel, longest = groupResident, remaining
+			}
+		}
+		if longest <= 0 {
+			return nil
+		}
+		if err := deferSwap(pm.shutdownCtx, pm.proxyLogger, modelID, residentModel, longest); err != nil {
+			return err
+		}
+	}
+}
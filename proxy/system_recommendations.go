@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+// systemRecommendation is what FrogLLM recommends for a detected system, shared by
+// apiGetSystemDetection and apiGetRecommendedSettings so both give the same advice
+type systemRecommendation struct {
+	Backends                  []string // usable backends, best first, always ends with cpu
+	PrimaryBackend            string
+	GPUBrand                  string // nvidia|amd|intel|apple|unknown
+	TotalRAMGB                float64
+	TotalVRAMGB               float64
+	AvailableRAMGB            float64
+	SuggestedContextSize      int
+	MaxRecommendedContextSize int
+	ThroughputFirst           bool
+}
+
+// recommendForSystem builds the recommendation for a system, realtimeInfo may be nil
+func recommendForSystem(system autosetup.SystemInfo, realtimeInfo *autosetup.RealtimeHardwareInfo) systemRecommendation {
+	r := systemRecommendation{PrimaryBackend: "cpu", GPUBrand: "unknown"}
+
+	// Determine optimal backend priority
+	if system.HasCUDA {
+		r.Backends = append(r.Backends, "cuda")
+		r.PrimaryBackend = "cuda"
+	}
+	if system.HasROCm {
+		r.Backends = append(r.Backends, "rocm")
+		if r.PrimaryBackend == "cpu" {
+			r.PrimaryBackend = "rocm"
+		}
+	}
+	if system.HasVulkan {
+		r.Backends = append(r.Backends, "vulkan")
+		if r.PrimaryBackend == "cpu" {
+			r.PrimaryBackend = "vulkan"
+		}
+	}
+	if system.HasMLX {
+		r.Backends = append(r.Backends, "mlx")
+		if r.PrimaryBackend == "cpu" {
+			r.PrimaryBackend = "mlx"
+		}
+	}
+	if system.HasMetal {
+		r.Backends = append(r.Backends, "metal")
+		if r.PrimaryBackend == "cpu" {
+			r.PrimaryBackend = "metal"
+		}
+	}
+	if system.HasIntel {
+		r.Backends = append(r.Backends, "intel")
+		if r.PrimaryBackend == "cpu" && system.IntelIsPrimaryGPU() {
+			r.PrimaryBackend = "intel"
+		}
+	}
+	r.Backends = append(r.Backends, "cpu") // Always available
+
+	if _, ok := system.PrimaryGPU(); ok {
+		if system.HasCUDA {
+			r.GPUBrand = "nvidia"
+		} else if system.HasROCm {
+			r.GPUBrand = "amd"
+		} else if system.HasIntel && system.IntelIsPrimaryGPU() {
+			r.GPUBrand = "intel"
+		} else if system.HasMetal || system.HasMLX {
+			r.GPUBrand = "apple"
+		}
+	}
+
+	// Calculate memory values
+	r.TotalRAMGB = system.TotalRAMGB
+	r.TotalVRAMGB = system.TotalVRAMGB
+	r.AvailableRAMGB = r.TotalRAMGB * 0.75 // Conservative estimate
+
+	// Use realtime info if available
+	if realtimeInfo != nil {
+		r.AvailableRAMGB = realtimeInfo.AvailableRAMGB
+		if realtimeInfo.TotalRAMGB > 0 {
+			r.TotalRAMGB = realtimeInfo.TotalRAMGB
+		}
+		if realtimeInfo.TotalVRAMGB > 0 && !system.HasVRAMOverrides() {
+			r.TotalVRAMGB = realtimeInfo.TotalVRAMGB
+		}
+	}
+
+	// Determine recommended context size based on available memory
+	if r.TotalVRAMGB >= 24 {
+		r.SuggestedContextSize = 131072 // 128K
+		r.MaxRecommendedContextSize = 131072
+	} else if r.TotalVRAMGB >= 16 {
+		r.SuggestedContextSize = 65536 // 64K
+		r.MaxRecommendedContextSize = 131072
+	} else if r.TotalVRAMGB >= 8 {
+		r.SuggestedContextSize = 32768 // 32K
+		r.MaxRecommendedContextSize = 65536
+	} else {
+		r.SuggestedContextSize = 16384 // 16K
+		r.MaxRecommendedContextSize = 32768
+	}
+
+	// Performance priority recommendation
+	r.ThroughputFirst = r.TotalVRAMGB >= 8
+
+	return r
+}
+
+func (r systemRecommendation) suggestedVRAMAllocation() int {
+	return int(r.TotalVRAMGB * 0.8)
+}
+
+func (r systemRecommendation) suggestedRAMAllocation() int {
+	return int(r.TotalRAMGB * 0.5)
+}
+
+// systemSettings returns the recommendation as settings that can be saved as is
+func (r systemRecommendation) systemSettings() SystemSettings {
+	gpuType := r.GPUBrand
+	if gpuType == "unknown" {
+		gpuType = "none"
+	}
+	return SystemSettings{
+		GPUType:          gpuType,
+		Backend:          r.PrimaryBackend,
+		VRAMGB:           float64(r.suggestedVRAMAllocation()),
+		RAMGB:            float64(r.suggestedRAMAllocation()),
+		PreferredContext: r.SuggestedContextSize,
+		ThroughputFirst:  r.ThroughputFirst,
+		EnableJinja:      true,
+	}
+}
+
+// apiGetRecommendedSettings detects the system and returns recommended settings,
+// ready to be sent to POST /api/settings/system
+func (pm *ProxyManager) apiGetRecommendedSettings(c *gin.Context) {
+	system, err := pm.detectSystem()
+	if err != nil {
+		pm.proxyLogger.Errorf("Failed to enhance system info: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect system information"})
+		return
+	}
+
+	realtimeInfo, err := autosetup.GetRealtimeHardwareInfo()
+	if err != nil {
+		pm.proxyLogger.Warnf("Failed to get realtime hardware info: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": recommendForSystem(system, realtimeInfo).systemSettings()})
+}
@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// kvCacheTypes are the values llama-server accepts for --cache-type-k and -v
+var kvCacheTypes = map[string]bool{
+	"f32": true, "f16": true, "bf16": true,
+	"q8_0": true, "q4_0": true, "q4_1": true, "iq4_nl": true, "q5_0": true, "q5_1": true,
+}
+
+var (
+	cacheTypeKPattern = regexp.MustCompile(`(^|\s)(--cache-type-k|-ctk)(=|\s+)(\S+)`)
+	cacheTypeVPattern = regexp.MustCompile(`(^|\s)(--cache-type-v|-ctv)(=|\s+)(\S+)`)
+)
+
+// launchCmd is the cmd the next start runs, the try-params override if one is
+// set and the configured cmd otherwise
+func (p *Process) launchCmd() string {
+	p.launchMutex.Lock()
+	defer p.launchMutex.Unlock()
+	if p.nextLaunchCmd != "" {
+		return p.nextLaunchCmd
+	}
+	return p.config.Cmd
+}
+
+// launchStarted records the cmd of a launch and drops the override, so it only
+// applies to the one launch and the next normal load uses the config again
+func (p *Process) launchStarted(cmd string) {
+	p.launchMutex.Lock()
+	defer p.launchMutex.Unlock()
+	p.launchedCmd = cmd
+	p.nextLaunchCmd = ""
+}
+
+// setNextLaunchCmd makes the next start run cmd instead of the configured cmd
+func (p *Process) setNextLaunchCmd(cmd string) {
+	p.launchMutex.Lock()
+	defer p.launchMutex.Unlock()
+	p.nextLaunchCmd = cmd
+}
+
+// runningCmd is the cmd of the current launch and if it differs from the config
+func (p *Process) runningCmd() (cmd string, overridden bool) {
+	p.launchMutex.Lock()
+	defer p.launchMutex.Unlock()
+	if p.launchedCmd == "" {
+		return p.config.Cmd, false
+	}
+	return p.launchedCmd, p.launchedCmd != p.config.Cmd
+}
+
+// cmdCacheTypes returns the K and V cache types a cmd sets, empty when it
+// keeps the llama-server default
+func cmdCacheTypes(cmd string) (cacheTypeK, cacheTypeV string) {
+	cmd = StripComments(cmd)
+	if match := cacheTypeKPattern.FindStringSubmatch(cmd); match != nil {
+		cacheTypeK = match[4]
+	}
+	if match := cacheTypeVPattern.FindStringSubmatch(cmd); match != nil {
+		cacheTypeV = match[4]
+	}
+	return cacheTypeK, cacheTypeV
+}
+
+// withCacheTypes sets the K and V cache types of a cmd, replacing the values of
+// flags it already has and adding the others. An empty type is left unchanged.
+func withCacheTypes(cmd, cacheTypeK, cacheTypeV string) string {
+	set := func(cmd string, pattern *regexp.Regexp, flag, value string) string {
+		if value == "" {
+			return cmd
+		}
+		if pattern.MatchString(cmd) {
+			return pattern.ReplaceAllString(cmd, "${1}${2}${3}"+value)
+		}
+		return strings.TrimRight(cmd, "\n") + "\n" + flag + " " + value
+	}
+	cmd = set(cmd, cacheTypeKPattern, "--cache-type-k", cacheTypeK)
+	return set(cmd, cacheTypeVPattern, "--cache-type-v", cacheTypeV)
+}
+
+// modelProcess returns the process of a model, nil when it has none
+func (pm *ProxyManager) modelProcess(modelID string) *Process {
+	processGroup := pm.findGroupByModelName(modelID)
+	if processGroup == nil {
+		return nil
+	}
+	processGroup.Lock()
+	defer processGroup.Unlock()
+	return processGroup.processes[modelID]
+}
+
+// apiGetTryParams handles GET /api/models/:id/try-params. It reports the cache
+// types the model runs with and the ones of its config, which differ while it
+// runs with overrides from POST /api/models/:id/try-params.
+func (pm *ProxyManager) apiGetTryParams(c *gin.Context) {
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+	process := pm.modelProcess(modelID)
+	if process == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + modelID})
+		return
+	}
+
+	state := process.CurrentState()
+	cmd, overridden := process.runningCmd()
+	if state != StateReady && state != StateStarting {
+		// a stopped model loads with its config again
+		cmd, overridden = process.config.Cmd, false
+	}
+	cacheTypeK, cacheTypeV := cmdCacheTypes(cmd)
+	configK, configV := cmdCacheTypes(process.config.Cmd)
+	c.JSON(http.StatusOK, gin.H{
+		"model":      modelID,
+		"state":      state,
+		"cacheTypeK": cacheTypeK,
+		"cacheTypeV": cacheTypeV,
+		"config":     gin.H{"cacheTypeK": configK, "cacheTypeV": configV},
+		"overridden": overridden,
+	})
+}
+
+// apiTryParams handles POST /api/models/:id/try-params. It restarts the model
+// with other KV cache types without changing the config, so they can be
+// compared quickly. The override only applies to this launch, the next normal
+// load uses the config again, unless persist saves them to the config once the
+// model started with them.
+func (pm *ProxyManager) apiTryParams(c *gin.Context) {
+	var req struct {
+		CacheType  string `json:"cacheType"`
+		CacheTypeK string `json:"cacheTypeK"`
+		CacheTypeV string `json:"cacheTypeV"`
+		Persist    bool   `json:"persist"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if req.CacheTypeK == "" {
+		req.CacheTypeK = req.CacheType
+	}
+	if req.CacheTypeV == "" {
+		req.CacheTypeV = req.CacheType
+	}
+	if req.CacheTypeK == "" && req.CacheTypeV == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cacheType, cacheTypeK or cacheTypeV is required"})
+		return
+	}
+	for _, cacheType := range []string{req.CacheTypeK, req.CacheTypeV} {
+		if cacheType != "" && !kvCacheTypes[cacheType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown cache type: " + cacheType})
+			return
+		}
+	}
+
+	pm.Lock()
+	modelID, found := pm.config.RealModelName(c.Param("id"))
+	modelConfig := pm.config.Models[modelID]
+	pm.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + c.Param("id")})
+		return
+	}
+	if modelConfig.IsRemote() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cache types of remote model " + modelID + " are set on its server"})
+		return
+	}
+	process := pm.modelProcess(modelID)
+	if process == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found: " + modelID})
+		return
+	}
+	if state := process.CurrentState(); state == StateStarting || state == StateStopping {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("model %s is %s, try again once it is ready", modelID, state)})
+		return
+	}
+
+	process.Stop()
+	process.setNextLaunchCmd(withCacheTypes(process.config.Cmd, req.CacheTypeK, req.CacheTypeV))
+	pm.proxyLogger.Infof("<%s> Restarting with cache types k=%s v=%s", modelID, req.CacheTypeK, req.CacheTypeV)
+
+	processGroup, _, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		process.setNextLaunchCmd("")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model: " + err.Error()})
+		return
+	}
+	loadReq, _ := http.NewRequest("GET", "/", nil)
+	processGroup.ProxyRequest(modelID, &DiscardWriter{}, loadReq)
+	if state := process.CurrentState(); state != StateReady {
+		response := gin.H{"error": fmt.Sprintf("model %s did not start with the new cache types, state: %s", modelID, state)}
+		if loadErr, _ := process.loadStatus(); loadErr != nil {
+			response["loadError"] = loadErr
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	cmd, _ := process.runningCmd()
+	cacheTypeK, cacheTypeV := cmdCacheTypes(cmd)
+	response := gin.H{
+		"model":      modelID,
+		"state":      process.CurrentState(),
+		"cacheTypeK": cacheTypeK,
+		"cacheTypeV": cacheTypeV,
+		"persisted":  false,
+	}
+	if req.Persist {
+		if err := pm.persistCacheTypes(modelID, req.CacheTypeK, req.CacheTypeV); err != nil {
+			response["error"] = "Model started but the cache types were not saved: " + err.Error()
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+		pm.proxyLogger.Infof("<%s> Saved cache types k=%s v=%s to the config", modelID, req.CacheTypeK, req.CacheTypeV)
+		response["persisted"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// persistCacheTypes sets the cache types in the cmd of a model in the config
+// file and reloads the config
+func (pm *ProxyManager) persistCacheTypes(modelID, cacheTypeK, cacheTypeV string) error {
+	return pm.updateModelFieldsInConfig(modelID, func(model *yaml.Node) error {
+		var raw struct {
+			Cmd string `yaml:"cmd"`
+		}
+		if err := model.Decode(&raw); err != nil {
+			return err
+		}
+		setMappingField(model, "cmd", cmdNode(withCacheTypes(raw.Cmd, cacheTypeK, cacheTypeV)))
+		return nil
+	})
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// usageNormalizedPaths are the OpenAI endpoints whose non-streaming responses
+// always get a usage object with prompt_tokens, completion_tokens and total_tokens
+var usageNormalizedPaths = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+}
+
+// firstInt returns the first of values that exists
+func firstInt(values ...gjson.Result) (int, bool) {
+	for _, value := range values {
+		if value.Exists() && value.Type == gjson.Number {
+			return int(value.Int()), true
+		}
+	}
+	return 0, false
+}
+
+// promptText is the text of a chat or completion request, what the prompt tokens
+// are counted from when the upstream doesn't report them. The chat template's
+// tokens are left out so the count is a little low.
+func promptText(requestBody []byte) string {
+	request := gjson.ParseBytes(requestBody)
+	var parts []string
+	for _, message := range request.Get("messages").Array() {
+		content := message.Get("content")
+		if content.IsArray() {
+			for _, part := range content.Array() {
+				if text := part.Get("text"); text.Exists() {
+					parts = append(parts, text.String())
+				}
+			}
+			continue
+		}
+		parts = append(parts, content.String())
+	}
+	if prompt := request.Get("prompt"); prompt.Exists() {
+		if prompt.IsArray() {
+			for _, p := range prompt.Array() {
+				parts = append(parts, p.String())
+			}
+		} else {
+			parts = append(parts, prompt.String())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// completionText is the generated text of a chat or completion response
+func completionText(response gjson.Result) string {
+	var parts []string
+	for _, choice := range response.Get("choices").Array() {
+		if content := choice.Get("message.content"); content.Exists() {
+			parts = append(parts, content.String())
+		} else if text := choice.Get("text"); text.Exists() {
+			parts = append(parts, text.String())
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// normalizeUsage makes sure a chat or completion response has usage with
+// prompt_tokens, completion_tokens and total_tokens. Missing counts are taken
+// from the input_tokens/output_tokens names some llama-server versions use,
+// then from llama-server's timings, then counted with countTokens, and are 0
+// when that fails too. Responses that already have all three, and errors, are
+// returned as they are.
+func normalizeUsage(requestBody, body []byte, countTokens func(text string) (int, bool)) ([]byte, bool) {
+	if !gjson.ValidBytes(body) {
+		return body, false
+	}
+	response := gjson.ParseBytes(body)
+	if !response.IsObject() || response.Get("error").Exists() || !response.Get("choices").Exists() {
+		return body, false
+	}
+	usage := response.Get("usage")
+	if usage.Get("prompt_tokens").Exists() && usage.Get("completion_tokens").Exists() && usage.Get("total_tokens").Exists() {
+		return body, false
+	}
+
+	promptTokens, found := firstInt(usage.Get("prompt_tokens"), usage.Get("input_tokens"), response.Get("timings.prompt_n"))
+	if !found {
+		promptTokens, _ = countTokens(promptText(requestBody))
+	}
+	completionTokens, found := firstInt(usage.Get("completion_tokens"), usage.Get("output_tokens"), response.Get("timings.predicted_n"))
+	if !found {
+		completionTokens, _ = countTokens(completionText(response))
+	}
+	totalTokens, found := firstInt(usage.Get("total_tokens"))
+	if !found {
+		totalTokens = promptTokens + completionTokens
+	}
+
+	if !usage.IsObject() {
+		body, _ = sjson.SetRawBytes(body, "usage", []byte("{}"))
+	}
+	for _, field := range []struct {
+		path  string
+		value int
+	}{
+		{"usage.prompt_tokens", promptTokens},
+		{"usage.completion_tokens", completionTokens},
+		{"usage.total_tokens", totalTokens},
+	} {
+		updated, err := sjson.SetBytes(body, field.path, field.value)
+		if err != nil {
+			return body, false
+		}
+		body = updated
+	}
+	return body, true
+}
+
+// upstreamTokenCounter counts tokens with llama-server's /tokenize endpoint
+func upstreamTokenCounter(proxyURL string) func(text string) (int, bool) {
+	return func(text string) (int, bool) {
+		if text == "" {
+			return 0, true
+		}
+		payload, _ := json.Marshal(map[string]any{"content": text, "add_special": false})
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Post(strings.TrimRight(proxyURL, "/")+"/tokenize", "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return 0, false
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Tokens []json.RawMessage `json:"tokens"`
+		}
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&result) != nil {
+			return 0, false
+		}
+		return len(result.Tokens), true
+	}
+}
+
+// usageResponseWriter holds back a non-streaming response so its usage can be
+// normalized before it goes to the client. Streaming responses pass through.
+type usageResponseWriter struct {
+	gin.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (w *usageResponseWriter) WriteHeader(statusCode int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = statusCode
+	if strings.Contains(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *usageResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+func (w *usageResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *usageResponseWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *usageResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+func (w *usageResponseWriter) Status() int {
+	if w.status == 0 {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+// finish sends the held back response, with its usage normalized when it is a
+// successful JSON response
+func (w *usageResponseWriter) finish(requestBody []byte, countTokens func(text string) (int, bool)) {
+	if w.passthrough || w.status == 0 {
+		return
+	}
+	body := w.body.Bytes()
+	if w.status == http.StatusOK && strings.Contains(w.Header().Get("Content-Type"), "application/json") {
+		if normalized, changed := normalizeUsage(requestBody, body, countTokens); changed {
+			body = normalized
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/autosetup"
+)
+
+func (s *SystemSettings) validateGPUVRAMOverrides() error {
+	for key, vram := range s.GPUVRAMOverrides {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("gpuVramOverrides keys must be a GPU index or name")
+		}
+		if vram <= 0 {
+			return fmt.Errorf("gpuVramOverrides %s must be > 0", key)
+		}
+	}
+	return nil
+}
+
+// detectSystem detects the system with the GPU VRAM overrides from the saved
+// settings applied, so memory estimates and generated configs use corrected values
+func (pm *ProxyManager) detectSystem() (autosetup.SystemInfo, error) {
+	system := autosetup.DetectSystem()
+	err := autosetup.EnhanceSystemInfo(&system)
+	if settings, _ := pm.loadSystemSettings(); settings != nil {
+		autosetup.ApplyVRAMOverrides(&system, settings.GPUVRAMOverrides)
+	}
+	return system, err
+}
+
+// gpuDetectionInfo describes a detected GPU for the detection API. An overridden
+// GPU also reports its detected VRAM so the UI can show both values.
+func gpuDetectionInfo(gpu autosetup.GPUInfo, extra gin.H) gin.H {
+	info := gin.H{
+		"name":   gpu.Name,
+		"kind":   gpu.Kind,
+		"vramGB": math.Round(gpu.VRAMGB*10) / 10, // Round to 1 decimal place
+	}
+	if gpu.DetectedVRAMGB > 0 {
+		info["detectedVRAMGB"] = math.Round(gpu.DetectedVRAMGB*10) / 10
+		info["vramOverridden"] = true
+	}
+	for k, v := range extra {
+		info[k] = v
+	}
+	return info
+}
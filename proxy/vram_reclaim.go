@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"os"
+	"time"
+)
+
+// defaultVRAMReclaimTimeout is how many seconds a load waits by default for the
+// VRAM of the models unloaded for it
+const defaultVRAMReclaimTimeout = 10
+
+// vramReclaimShare is the share of the unloaded models' expected VRAM that has
+// to show up as free before the next model loads. The driver rarely returns
+// exactly the file size, partly offloaded models return less.
+const vramReclaimShare = 0.8
+
+// vramReclaimPollInterval is how often free VRAM is measured while waiting,
+// replaceable for testing
+var vramReclaimPollInterval = 250 * time.Millisecond
+
+// runningModelsGB estimates the VRAM the running models of groups hold from the
+// size of their GGUF files
+func (pm *ProxyManager) runningModelsGB(groups []*ProcessGroup) float64 {
+	var size int64
+	for _, group := range groups {
+		group.Lock()
+		for modelID, process := range group.processes {
+			if state := process.CurrentState(); state != StateReady && state != StateStarting {
+				continue
+			}
+			modelPath := pm.extractModelPathFromCmd(pm.config.Models[modelID].Cmd)
+			if modelPath == "" {
+				continue
+			}
+			if info, err := os.Stat(modelPath); err == nil {
+				size += info.Size()
+			}
+		}
+		group.Unlock()
+	}
+	return float64(size) / (1024 * 1024 * 1024)
+}
+
+// stopGroupsForLoad stops process groups to make room for a model load. With
+// vramReclaimTimeout set it then waits until the VRAM of the stopped models is
+// free again, since the driver may hold on to it for a moment after the
+// processes exit and the next load would run out of memory.
+func (pm *ProxyManager) stopGroupsForLoad(groups []*ProcessGroup, strategy StopStrategy) {
+	expectedGB := 0.0
+	freeBeforeGB := 0.0
+	if pm.config.VRAMReclaimTimeout > 0 {
+		expectedGB = pm.runningModelsGB(groups)
+	}
+	if expectedGB > 0 {
+		free, total, err := pm.measureVRAM()
+		if err != nil {
+			pm.proxyLogger.Debugf("Not checking VRAM reclaim, VRAM can't be measured: %v", err)
+			expectedGB = 0
+		} else {
+			freeBeforeGB = free
+			// the models can't free more than is in use
+			if used := total - free; expectedGB > used {
+				expectedGB = used
+			}
+		}
+	}
+
+	for _, group := range groups {
+		group.StopProcesses(strategy)
+	}
+
+	if expectedGB > 0 {
+		pm.waitForVRAMReclaim(freeBeforeGB, expectedGB, time.Duration(pm.config.VRAMReclaimTimeout)*time.Second)
+	}
+}
+
+// waitForVRAMReclaim polls free VRAM until it grew by roughly expectedGB over
+// freeBeforeGB or the timeout passed, and reports if it did
+func (pm *ProxyManager) waitForVRAMReclaim(freeBeforeGB, expectedGB float64, timeout time.Duration) bool {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	freedGB := 0.0
+	for {
+		free, _, err := pm.measureVRAM()
+		if err == nil {
+			freedGB = free - freeBeforeGB
+			if freedGB >= expectedGB*vramReclaimShare {
+				pm.proxyLogger.Debugf("VRAM reclaimed after unload: %.1fGB freed in %v", freedGB, time.Since(start).Round(time.Millisecond))
+				return true
+			}
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(vramReclaimPollInterval)
+	}
+	pm.proxyLogger.Warnf("VRAM not reclaimed after unload: %.1fGB of the expected %.1fGB freed after %v, loading anyway", freedGB, expectedGB, timeout)
+	return false
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prave/FrogLLM/event"
+	"gopkg.in/yaml.v3"
+)
+
+// appendMissingMacros adds the macros a generated model config refers to that
+// config.yaml doesn't define yet. Existing macros are left as they are.
+func appendMissingMacros(configPath string, macros map[string]interface{}) error {
+	if len(macros) == 0 {
+		return nil
+	}
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(configData, &config); err != nil {
+		return fmt.Errorf("failed to parse config YAML: %v", err)
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+
+	existing, _ := config["macros"].(map[string]interface{})
+	if existing == nil {
+		existing = make(map[string]interface{})
+	}
+	added := false
+	for name, value := range macros {
+		if _, found := existing[name]; !found {
+			existing[name] = value
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+	config["macros"] = existing
+
+	newConfigData, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config YAML: %v", err)
+	}
+	return os.WriteFile(configPath, newConfigData, 0644)
+}
+
+// applyAppendedModel reloads configPath into the running config so a model that
+// was just appended to it can be loaded without a restart. Process groups and
+// processes of running models are kept, new ones are added.
+func (pm *ProxyManager) applyAppendedModel(configPath, modelID string) error {
+	newConfig, err := LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %v", err)
+	}
+	if _, found := newConfig.RealModelName(modelID); !found {
+		return fmt.Errorf("model %s not found in reloaded config", modelID)
+	}
+
+	pm.Lock()
+	pm.config = newConfig
+	for groupID, groupConfig := range newConfig.Groups {
+		processGroup, exists := pm.processGroups[groupID]
+		if !exists {
+			pm.processGroups[groupID] = NewProcessGroup(groupID, newConfig, pm.proxyLogger, pm.upstreamLogger)
+			continue
+		}
+		processGroup.Lock()
+		processGroup.config = newConfig
+		for _, member := range groupConfig.Members {
+			if _, hasProcess := processGroup.processes[member]; !hasProcess {
+				processGroup.processes[member] = NewProcess(member, newConfig.HealthCheckTimeout, newConfig.Models[member], pm.upstreamLogger, pm.proxyLogger)
+			}
+		}
+		processGroup.Unlock()
+	}
+	pm.Unlock()
+
+	event.Emit(ConfigFileChangedEvent{ReloadingState: ReloadingStateEnd})
+	return nil
+}
+
+// warmModel loads a model and sends it one small request, so the first real
+// request doesn't pay for the load or the first batch. The result reports if
+// the model is ready to serve.
+func (pm *ProxyManager) warmModel(modelID string, isEmbedding bool) gin.H {
+	startTime := time.Now()
+	result := gin.H{"ready": false}
+
+	processGroup, realModelName, err := pm.swapProcessGroup(modelID)
+	if err != nil {
+		result["durationMs"] = time.Since(startTime).Milliseconds()
+		result["error"] = err.Error()
+		return result
+	}
+
+	path := "/v1/chat/completions"
+	body := map[string]interface{}{
+		"model":      realModelName,
+		"messages":   []map[string]string{{"role": "user", "content": "Hi"}},
+		"max_tokens": 1,
+	}
+	if isEmbedding {
+		path = "/v1/embeddings"
+		body = map[string]interface{}{"model": realModelName, "input": "warmup"}
+	}
+	bodyBytes, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	writer := &DiscardWriter{}
+	if err := processGroup.ProxyRequest(realModelName, writer, req); err != nil {
+		result["error"] = err.Error()
+	} else if writer.status >= http.StatusBadRequest {
+		result["error"] = fmt.Sprintf("warmup request returned status %d", writer.status)
+	}
+
+	processGroup.Lock()
+	process := processGroup.processes[realModelName]
+	processGroup.Unlock()
+	state := StateStopped
+	if process != nil {
+		state = process.CurrentState()
+	}
+
+	result["state"] = state
+	result["ready"] = state == StateReady
+	result["durationMs"] = time.Since(startTime).Milliseconds()
+	if state != StateReady {
+		pm.proxyLogger.Warnf("<%s> Model is not ready after warmup, state: %s", realModelName, state)
+	} else {
+		pm.proxyLogger.Infof("<%s> Model warmed up in %v", realModelName, time.Since(startTime))
+	}
+	return result
+}
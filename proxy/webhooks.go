@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/prave/FrogLLM/autosetup"
+	"github.com/prave/FrogLLM/event"
+)
+
+// webhook event names, what WebhookConfig.Events filters on
+const (
+	WebhookModelLoadFailed   = "model.load_failed"
+	WebhookModelUnhealthy    = "model.unhealthy"
+	WebhookModelRecovered    = "model.recovered"
+	WebhookDownloadCompleted = "download.completed"
+	WebhookDownloadFailed    = "download.failed"
+	WebhookBinaryUpdated     = "binary.updated"
+)
+
+var webhookEventNames = []string{
+	WebhookModelLoadFailed,
+	WebhookModelUnhealthy,
+	WebhookModelRecovered,
+	WebhookDownloadCompleted,
+	WebhookDownloadFailed,
+	WebhookBinaryUpdated,
+}
+
+// webhookRetryBackoff is the wait before the first retry of a failed delivery,
+// doubled for each further retry. Replaceable for testing.
+var webhookRetryBackoff = time.Second
+
+// WebhookConfig posts a JSON payload to URL when one of its events fires
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Events  []string          `yaml:"events"`  // event names, empty is all of them
+	Headers map[string]string `yaml:"headers"` // e.g. Authorization for custom endpoints
+	Timeout *int              `yaml:"timeout"` // seconds per attempt, default 10, 0 waits as long as it takes
+	Retries *int              `yaml:"retries"` // attempts after a failed one, default 3
+}
+
+func (w WebhookConfig) validate() error {
+	parsed, err := url.Parse(w.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("webhook url %q must be an http or https URL", w.URL)
+	}
+	for _, name := range w.Events {
+		if !slices.Contains(webhookEventNames, name) {
+			return fmt.Errorf("webhook %s: unknown event %q, known events are %v", w.URL, name, webhookEventNames)
+		}
+	}
+	if (w.Timeout != nil && *w.Timeout < 0) || (w.Retries != nil && *w.Retries < 0) {
+		return fmt.Errorf("webhook %s: timeout and retries must not be negative", w.URL)
+	}
+	return nil
+}
+
+func (w WebhookConfig) subscribed(name string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, name)
+}
+
+func (w WebhookConfig) timeoutDuration() time.Duration {
+	if w.Timeout == nil {
+		return 10 * time.Second
+	}
+	return time.Duration(*w.Timeout) * time.Second
+}
+
+func (w WebhookConfig) attempts() int {
+	if w.Retries == nil {
+		return 4
+	}
+	return *w.Retries + 1
+}
+
+// webhookPayload is the JSON posted to webhooks. text is a one line summary,
+// which is what Slack incoming webhooks show.
+type webhookPayload struct {
+	Event     string         `json:"event"`
+	Text      string         `json:"text"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data"`
+}
+
+// subscribeWebhooks forwards the events the webhooks of the config subscribe to.
+// The returned function unsubscribes.
+func (pm *ProxyManager) subscribeWebhooks() context.CancelFunc {
+	if len(pm.config.Webhooks) == 0 {
+		return func() {}
+	}
+	cancels := []context.CancelFunc{
+		event.On(func(e ModelLoadFailedEvent) {
+			pm.notifyWebhooks(WebhookModelLoadFailed, fmt.Sprintf("Model %s failed to load: %s", e.ModelName, e.Reason), map[string]any{
+				"model":  e.ModelName,
+				"reason": e.Reason,
+				"code":   e.Code,
+			})
+		}),
+		event.On(func(e ProcessHealthChangedEvent) {
+			name, text := WebhookModelUnhealthy, fmt.Sprintf("Model %s is failing its health checks", e.ProcessName)
+			if e.Healthy {
+				name, text = WebhookModelRecovered, fmt.Sprintf("Model %s is healthy again", e.ProcessName)
+			}
+			pm.notifyWebhooks(name, text, map[string]any{"model": e.ProcessName})
+		}),
+		event.On(func(e DownloadProgressEvent) {
+			if e.Info == nil {
+				return
+			}
+			data := map[string]any{
+				"id":       e.Info.ID,
+				"modelId":  e.Info.ModelID,
+				"filename": e.Info.Filename,
+				"filePath": e.Info.FilePath,
+			}
+			switch e.Info.Status {
+			case StatusCompleted:
+				data["bytes"] = e.Info.DownloadedBytes
+				pm.notifyWebhooks(WebhookDownloadCompleted, fmt.Sprintf("Download of %s completed", e.Info.Filename), data)
+			case StatusFailed:
+				data["error"] = e.Info.Error
+				pm.notifyWebhooks(WebhookDownloadFailed, fmt.Sprintf("Download of %s failed: %s", e.Info.Filename, e.Info.Error), data)
+			}
+		}),
+		event.On(func(e BinaryUpdatedEvent) {
+			pm.notifyWebhooks(WebhookBinaryUpdated, fmt.Sprintf("llama-server updated to %s (%s)", e.Version, e.BinaryType), map[string]any{
+				"version": e.Version,
+				"type":    e.BinaryType,
+			})
+		}),
+	}
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// notifyWebhooks posts an event to the webhooks subscribed to it, each in the
+// background so a slow receiver holds up nothing else
+func (pm *ProxyManager) notifyWebhooks(name, text string, data map[string]any) {
+	payload, err := json.Marshal(webhookPayload{Event: name, Text: text, Timestamp: time.Now().UTC(), Data: data})
+	if err != nil {
+		pm.proxyLogger.Errorf("Failed to encode webhook payload for %s: %v", name, err)
+		return
+	}
+	for _, webhook := range pm.config.Webhooks {
+		if webhook.subscribed(name) {
+			go pm.deliverWebhook(webhook, name, payload)
+		}
+	}
+}
+
+// deliverWebhook posts a payload, retrying with backoff until the receiver
+// answers with a 2xx status or the attempts run out
+func (pm *ProxyManager) deliverWebhook(webhook WebhookConfig, name string, payload []byte) {
+	client := autosetup.NewHTTPClient(webhook.timeoutDuration())
+	backoff := webhookRetryBackoff
+	var lastErr error
+	for attempt := 1; attempt <= webhook.attempts(); attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(backoff):
+			case <-pm.shutdownCtx.Done():
+				return
+			}
+			backoff *= 2
+		}
+
+		req, err := http.NewRequestWithContext(pm.shutdownCtx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+		if err != nil {
+			pm.proxyLogger.Errorf("Webhook %s for %s: %v", webhook.URL, name, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "FrogLLM-Webhook")
+		for key, value := range webhook.Headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		lastErr = err
+	}
+	pm.proxyLogger.Warnf("Webhook %s for %s failed after %d attempts: %v", webhook.URL, name, webhook.attempts(), lastErr)
+}
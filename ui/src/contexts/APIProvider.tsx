@@ -13,6 +13,12 @@ export interface Model {
   description: string;
   unlisted: boolean;
   proxyUrl?: string;
+  failReason?: string;
+  failCode?: string;
+  failSuggestion?: string;
+  unhealthy?: boolean;
+  archived?: boolean;
+  loadingElapsedMs?: number;
 }
 
 interface APIProviderType {
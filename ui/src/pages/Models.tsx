@@ -206,6 +206,21 @@ function ModelCard({ model, showIdorName, onLoad, onUnload }: ModelCardProps) {
                   {model.description}
                 </p>
               )}
+
+              {model.state === "stopped" && model.failReason && (
+                <p className="text-sm text-error-800 dark:text-error-300 line-clamp-2" title={model.failReason}>
+                  Failed to load: {model.failReason}
+                </p>
+              )}
+              {model.state === "ready" && model.unhealthy && (
+                <p className="text-sm text-error-800 dark:text-error-300">Health check failing, requests are refused until it recovers</p>
+              )}
+              {model.archived && (
+                <p className="text-xs text-text-secondary">Archived, restored from the archive on the next load</p>
+              )}
+              {model.state === "stopped" && model.failSuggestion && (
+                <p className="text-xs text-text-secondary">Suggestion: {model.failSuggestion}</p>
+              )}
             </div>
           </div>
 
@@ -37,6 +37,7 @@ interface ModelScanResult {
   contextLength: number;
   numLayers: number;
   isMoE: boolean;
+  tags?: string[];
 }
 
 interface SystemConfig {